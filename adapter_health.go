@@ -0,0 +1,126 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// OnAdapterError adds a hook called with errors of redis adapter, e.g. when subscription of
+// namespace is lost, on each failed attempt to restore it, or when broadcast isn't published,
+// so operators can alert on degraded clustering. Broadcasters are resubscribed with backoff
+// meanwhile, see AdapterHealth.
+func (s *Server) OnAdapterError(f func(error)) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onAdapterError = append(s.onAdapterError, f)
+}
+
+// AdapterHealth gives errors of namespaces whose broadcasts don't reach other nodes, because
+// subscription of their adapter is lost, nil when all of them are healthy.
+func (s *Server) AdapterHealth() error {
+	var namespaces []string
+	for nsp := range s.handlers.All() {
+		namespaces = append(namespaces, nsp)
+	}
+	sort.Strings(namespaces)
+
+	var errs []error
+	for _, nsp := range namespaces {
+		h, ok := s.handlers.Get(nsp)
+		if !ok {
+			continue
+		}
+
+		if monitor, ok := h.getBroadcast().(adapterMonitor); ok {
+			if err := monitor.adapterErr(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) adapterError(err error) {
+	s.hooksLock.RLock()
+	hooks := s.onAdapterError
+	s.hooksLock.RUnlock()
+
+	for _, f := range hooks {
+		f(err)
+	}
+}
+
+// monitorAdapter reports errors of adapter of broadcaster to hooks of server.
+func (s *Server) monitorAdapter(b Broadcast) {
+	if monitor, ok := b.(adapterMonitor); ok {
+		monitor.setOnAdapterError(s.adapterError)
+	}
+}
+
+// adapterMonitor is broadcaster whose adapter reports its health.
+type adapterMonitor interface {
+	setOnAdapterError(f func(error))
+	adapterErr() error
+}
+
+// adapterHealth keeps error of adapter while it's degraded.
+type adapterHealth struct {
+	err     error
+	onError func(error)
+	lock    sync.Mutex
+}
+
+// report passes err to hook without degrading adapter, e.g. of broadcast which isn't published.
+func (h *adapterHealth) report(err error) {
+	h.lock.Lock()
+	onError := h.onError
+	h.lock.Unlock()
+
+	if onError != nil {
+		onError(err)
+	}
+}
+
+// degrade keeps err until restore and reports it.
+func (h *adapterHealth) degrade(err error) {
+	h.lock.Lock()
+	h.err = err
+	h.lock.Unlock()
+
+	h.report(err)
+}
+
+func (h *adapterHealth) restore() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.err = nil
+}
+
+func (bc *redisBroadcast) setOnAdapterError(f func(error)) {
+	bc.health.lock.Lock()
+	defer bc.health.lock.Unlock()
+
+	bc.health.onError = f
+}
+
+func (bc *redisBroadcast) adapterErr() error {
+	bc.health.lock.Lock()
+	defer bc.health.lock.Unlock()
+
+	return bc.health.err
+}
+
+// degrade marks adapter of namespace degraded by err until restore.
+func (bc *redisBroadcast) degrade(err error) {
+	bc.health.degrade(fmt.Errorf("namespace %q: %w", nodeNamespace(bc.nsp), err))
+}
+
+// reportError reports err of adapter of namespace which doesn't degrade it.
+func (bc *redisBroadcast) reportError(err error) {
+	bc.health.report(fmt.Errorf("namespace %q: %w", nodeNamespace(bc.nsp), err))
+}
@@ -0,0 +1,90 @@
+package socketio
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestAdapterHealth(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	subscribed := func(kind string, channels []string) string {
+		var reply string
+		for i, channel := range channels {
+			reply += "*3\r\n" + respArray(kind, channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+		}
+		return reply
+	}
+
+	// subscription is lost right away until redis is healthy
+	var healthy int32
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE":
+			return subscribed("psubscribe", cmd[1:])
+		case "SUBSCRIBE":
+			reply := subscribed("subscribe", cmd[1:])
+			if atomic.LoadInt32(&healthy) == 0 {
+				return reply + "!bad\r\n"
+			}
+			return reply +
+				respArray("pmessage", "socket.io#/chat#*", "socket.io#/chat#other", "not json") +
+				respArray("pmessage", "socket.io#/chat#*", "socket.io#/chat#other",
+					`{"opts":["lobby","notice"],"args":[],"meta":{"origin":"other","id":"1"}}`)
+		}
+		return ":0\r\n"
+	})
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	lost := make(chan error, 16)
+	reported := make(chan error, 16)
+	server.OnAdapterError(func(err error) {
+		if strings.Contains(err.Error(), "subscription lost") {
+			lost <- server.AdapterHealth()
+			return
+		}
+		reported <- err
+	})
+
+	bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{Addr: addr}))
+	must.NoError(err)
+	must.NoError(server.SetBroadcaster("/chat", bc))
+
+	lobby := newLockedRecorder("1", "/chat", bc)
+	bc.lock.Lock()
+	bc.rooms["lobby"] = map[string]Conn{"1": lobby}
+	bc.lock.Unlock()
+
+	select {
+	case err := <-lost:
+		should.ErrorContains(err, `namespace "/chat": redis subscription lost`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lost subscription isn't reported")
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+
+	// invalid broadcast is reported, subscription goes on
+	select {
+	case err := <-reported:
+		should.ErrorContains(err, "redis broadcast of socket.io#/chat#other")
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid broadcast isn't reported")
+	}
+
+	should.Eventually(func() bool {
+		return len(lobby.received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	should.NoError(server.AdapterHealth())
+}
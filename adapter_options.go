@@ -1,6 +1,14 @@
 package socketio
 
-import "fmt"
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
 
 // RedisAdapterOptions is configuration to create new adapter
 type RedisAdapterOptions struct {
@@ -12,10 +20,69 @@ type RedisAdapterOptions struct {
 	Prefix   string
 	Network  string
 	Password string
+	// Username : user of Redis 6 ACL, authenticated with Password.
+	Username string
 	// DB : specifies the database to select when dialing a connection.
 	DB int
+	// TLSConfig : connections use TLS with this configuration when it's set, e.g. for managed
+	// redis like ElastiCache.
+	TLSConfig *tls.Config
+	// DialTimeout : timeout of connecting to redis, including TLS handshake.
+	DialTimeout time.Duration
+	// ReadTimeout and WriteTimeout : timeouts of commands. Subscriptions wait for messages
+	// without ReadTimeout and blocking commands, like XREADGROUP of Streams, wait longer by
+	// their block time.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// PoolSize : maximum number of connections of pools, e.g. of RedisEmitter, callers wait
+	// for a connection once it's reached. Zero, the default, is unlimited.
+	PoolSize int
+	// Dial : connects to redis at addr instead of dialing with options above, e.g. to use
+	// custom dialer or authentication. Addr, sentinel resolution and cluster redirections
+	// still give addr.
+	Dial func(network, addr string) (redis.Conn, error)
+	// RequestTimeout : bounds waiting for other nodes to respond requests like Len and AllRooms,
+	// results of nodes which responded are given then. Default is 5 seconds.
+	RequestTimeout time.Duration
+	// NodeID : stable identity of this server instance in the cluster, generated when empty.
+	NodeID string
+	// HeartbeatInterval : interval of node announcements to the cluster.
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout : nodes without heartbeat for this duration are considered dead,
+	// defaults to three heartbeat intervals.
+	HeartbeatTimeout time.Duration
+	// MaxHops : broadcasts relayed between adapters more times are dropped as looping,
+	// default is 8.
+	MaxHops int
+	// MasterName : name of master monitored by Redis Sentinel. With SentinelAddrs, connections
+	// are made to the current master, resolved through sentinels, instead of Addr.
+	MasterName string
+	// SentinelAddrs : addresses of sentinels, asked in order until one knows the master.
+	SentinelAddrs []string
+	// Streams : broadcasts are delivered through a Redis Stream of each namespace with consumer
+	// group of each node, instead of Pub/Sub, so node catches up on broadcasts it missed while
+	// it was disconnected. Set NodeID to catch up after restart as well. Requests like Len and
+	// AllRooms still use Pub/Sub.
+	Streams bool
+	// StreamMaxLen : streams are trimmed to about this number of broadcasts, default is 10000.
+	StreamMaxLen int64
+	// NodeCompatible : channels and payloads of broadcasts and requests are those of node.js
+	// @socket.io/redis-adapter, so Go and node.js servers share rooms of one cluster. Broadcasts
+	// are encoded with msgpack, their deadlines aren't carried and room sets are limited to unions
+	// of rooms followed by exceptions. Streams aren't used.
+	NodeCompatible bool
+	// Sharded : broadcasts and requests use sharded Pub/Sub of Redis 7, SPUBLISH and SSUBSCRIBE,
+	// so Redis Cluster delivers them only within shard of channels instead of every node of
+	// cluster. Channels of namespace share hash slot, connections follow MOVED redirection to node
+	// which serves it. It isn't used with NodeCompatible.
+	Sharded bool
 }
 
+const (
+	sentinelTimeout            = 2 * time.Second
+	defaultRedisRequestTimeout = 5 * time.Second
+)
+
 func (ro *RedisAdapterOptions) getAddr() string {
 	if ro.Addr == "" {
 		ro.Addr = fmt.Sprintf("%s:%s", ro.Host, ro.Port)
@@ -23,11 +90,177 @@ func (ro *RedisAdapterOptions) getAddr() string {
 	return ro.Addr
 }
 
+func (ro *RedisAdapterOptions) dial() (redis.Conn, error) {
+	if !ro.useSentinel() {
+		return ro.dialAddr(ro.getAddr())
+	}
+
+	addr, err := ro.masterAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ro.dialAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// sentinels may still give the old master while failover is in progress
+	role, err := redis.Values(conn.Do("ROLE"))
+	if err == nil && len(role) == 0 {
+		err = errors.New("empty ROLE reply")
+	}
+	if err == nil {
+		var name string
+		if name, err = redis.String(role[0], nil); err == nil && name != "master" {
+			err = fmt.Errorf("redis at %s is %s, not master %q", addr, name, ro.MasterName)
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// dialAddr connects to redis at addr, e.g. node of cluster given by redirection.
+func (ro *RedisAdapterOptions) dialAddr(addr string) (redis.Conn, error) {
+	if ro.Dial != nil {
+		return ro.Dial(ro.Network, addr)
+	}
+
+	var redisOpts []redis.DialOption
+	if len(ro.Username) > 0 {
+		redisOpts = append(redisOpts, redis.DialUsername(ro.Username))
+	}
+	if len(ro.Password) > 0 {
+		redisOpts = append(redisOpts, redis.DialPassword(ro.Password))
+	}
+	if ro.DB > 0 {
+		redisOpts = append(redisOpts, redis.DialDatabase(ro.DB))
+	}
+	if ro.TLSConfig != nil {
+		redisOpts = append(redisOpts, redis.DialUseTLS(true), redis.DialTLSConfig(ro.TLSConfig))
+	}
+	if ro.DialTimeout > 0 {
+		redisOpts = append(redisOpts, redis.DialConnectTimeout(ro.DialTimeout),
+			redis.DialTLSHandshakeTimeout(ro.DialTimeout))
+	}
+	if ro.ReadTimeout > 0 {
+		redisOpts = append(redisOpts, redis.DialReadTimeout(ro.ReadTimeout))
+	}
+	if ro.WriteTimeout > 0 {
+		redisOpts = append(redisOpts, redis.DialWriteTimeout(ro.WriteTimeout))
+	}
+
+	return redis.Dial(ro.Network, addr, redisOpts...)
+}
+
+// newPool gives pool of connections limited by PoolSize.
+func (ro *RedisAdapterOptions) newPool() *redis.Pool {
+	maxIdle := 2
+	if ro.PoolSize > 0 {
+		maxIdle = min(maxIdle, ro.PoolSize)
+	}
+
+	return &redis.Pool{
+		Dial:        ro.dial,
+		MaxIdle:     maxIdle,
+		MaxActive:   ro.PoolSize,
+		Wait:        ro.PoolSize > 0,
+		IdleTimeout: time.Minute,
+	}
+}
+
+// doBlocking runs command which blocks up to block, so it's given longer than ReadTimeout.
+func (ro *RedisAdapterOptions) doBlocking(conn redis.Conn, block time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if _, ok := conn.(redis.ConnWithTimeout); !ok || ro.ReadTimeout <= 0 {
+		return conn.Do(cmd, args...)
+	}
+
+	return redis.DoWithTimeout(conn, block+ro.ReadTimeout, cmd, args...)
+}
+
+// receive receives notification of subscription, which waits for messages without ReadTimeout.
+func receive(sub *redis.PubSubConn) interface{} {
+	if _, ok := sub.Conn.(redis.ConnWithTimeout); ok {
+		return sub.ReceiveWithTimeout(0)
+	}
+
+	return sub.Receive()
+}
+
+// receiveReply is receive of raw reply, e.g. of sharded subscription.
+func receiveReply(conn redis.Conn) (interface{}, error) {
+	if _, ok := conn.(redis.ConnWithTimeout); ok {
+		return redis.ReceiveWithTimeout(conn, 0)
+	}
+
+	return conn.Receive()
+}
+
+func (ro *RedisAdapterOptions) useSentinel() bool {
+	return ro.MasterName != "" && len(ro.SentinelAddrs) > 0
+}
+
+// masterAddr asks sentinels for address of master.
+func (ro *RedisAdapterOptions) masterAddr() (string, error) {
+	var lastErr error
+	for _, sentinel := range ro.SentinelAddrs {
+		addr, err := sentinelMasterAddr(ro.Network, sentinel, ro.MasterName)
+		if err == nil {
+			return addr, nil
+		}
+
+		lastErr = err
+	}
+
+	return "", fmt.Errorf("resolve redis master %q: %w", ro.MasterName, lastErr)
+}
+
+func sentinelMasterAddr(network, sentinel, masterName string) (string, error) {
+	conn, err := redis.Dial(network, sentinel,
+		redis.DialConnectTimeout(sentinelTimeout),
+		redis.DialReadTimeout(sentinelTimeout),
+		redis.DialWriteTimeout(sentinelTimeout),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	hostPort, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", masterName))
+	if errors.Is(err, redis.ErrNil) {
+		return "", fmt.Errorf("sentinel %s doesn't monitor %q", sentinel, masterName)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(hostPort) != 2 {
+		return "", fmt.Errorf("sentinel %s gave invalid address of %q", sentinel, masterName)
+	}
+
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
+
+func (ro *RedisAdapterOptions) getRequestTimeout() time.Duration {
+	if ro == nil || ro.RequestTimeout <= 0 {
+		return defaultRedisRequestTimeout
+	}
+
+	return ro.RequestTimeout
+}
+
 func defaultOptions() *RedisAdapterOptions {
 	return &RedisAdapterOptions{
 		Addr:    "127.0.0.1:6379",
 		Prefix:  "socket.io",
 		Network: "tcp",
+
+		HeartbeatInterval: 5 * time.Second,
+		MaxHops:           defaultMaxHops,
+		StreamMaxLen:      defaultStreamMaxLen,
 	}
 }
 
@@ -58,6 +291,73 @@ func getOptions(opts *RedisAdapterOptions) *RedisAdapterOptions {
 		if len(opts.Password) > 0 {
 			options.Password = opts.Password
 		}
+
+		if opts.Username != "" {
+			options.Username = opts.Username
+		}
+
+		if opts.DB > 0 {
+			options.DB = opts.DB
+		}
+
+		options.TLSConfig = opts.TLSConfig
+		options.Dial = opts.Dial
+
+		if opts.DialTimeout > 0 {
+			options.DialTimeout = opts.DialTimeout
+		}
+
+		if opts.ReadTimeout > 0 {
+			options.ReadTimeout = opts.ReadTimeout
+		}
+
+		if opts.WriteTimeout > 0 {
+			options.WriteTimeout = opts.WriteTimeout
+		}
+
+		if opts.PoolSize > 0 {
+			options.PoolSize = opts.PoolSize
+		}
+
+		if opts.RequestTimeout > 0 {
+			options.RequestTimeout = opts.RequestTimeout
+		}
+
+		if opts.NodeID != "" {
+			options.NodeID = opts.NodeID
+		}
+
+		if opts.HeartbeatInterval > 0 {
+			options.HeartbeatInterval = opts.HeartbeatInterval
+		}
+
+		if opts.HeartbeatTimeout > 0 {
+			options.HeartbeatTimeout = opts.HeartbeatTimeout
+		}
+
+		if opts.MaxHops > 0 {
+			options.MaxHops = opts.MaxHops
+		}
+
+		if opts.MasterName != "" {
+			options.MasterName = opts.MasterName
+		}
+
+		if len(opts.SentinelAddrs) > 0 {
+			options.SentinelAddrs = opts.SentinelAddrs
+		}
+
+		options.Streams = opts.Streams
+		options.NodeCompatible = opts.NodeCompatible
+		options.Sharded = opts.Sharded
+
+		if opts.StreamMaxLen > 0 {
+			options.StreamMaxLen = opts.StreamMaxLen
+		}
+	}
+
+	if options.HeartbeatTimeout <= 0 {
+		options.HeartbeatTimeout = 3 * options.HeartbeatInterval
 	}
 
 	return options
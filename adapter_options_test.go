@@ -0,0 +1,384 @@
+package socketio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis answers commands with replies given by reply, which gets command in upper case
+// with its arguments.
+func fakeRedis(t *testing.T, reply func(cmd []string) string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+				for {
+					cmd, err := readRESPCommand(r)
+					if err != nil {
+						return
+					}
+					if _, err = conn.Write([]byte(reply(cmd))); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+
+	cmd := make([]string, n)
+	for i := range cmd {
+		var size int
+		if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		cmd[i] = string(buf[:size])
+	}
+	cmd[0] = strings.ToUpper(cmd[0])
+
+	return cmd, nil
+}
+
+func respArray(items ...string) string {
+	reply := "*" + strconv.Itoa(len(items)) + "\r\n"
+	for _, item := range items {
+		reply += "$" + strconv.Itoa(len(item)) + "\r\n" + item + "\r\n"
+	}
+
+	return reply
+}
+
+// fakePubSubRedis relays PUBLISH to connections subscribed to its channel, or to pattern which
+// ends with '*' and prefixes the channel, so adapters of several servers form a cluster. Other
+// commands are answered with ":1".
+type fakePubSubRedis struct {
+	addr string
+
+	channels map[*fakeRedisConn]map[string]struct{}
+	patterns map[*fakeRedisConn]map[string]struct{}
+	mu       sync.Mutex
+}
+
+// fakeRedisConn is connection of fakePubSubRedis, messages are written to it by publishers too.
+type fakeRedisConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *fakeRedisConn) write(reply string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.Write([]byte(reply))
+	return err
+}
+
+func newFakePubSubRedis(t *testing.T) *fakePubSubRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	r := &fakePubSubRedis{
+		addr:     ln.Addr().String(),
+		channels: make(map[*fakeRedisConn]map[string]struct{}),
+		patterns: make(map[*fakeRedisConn]map[string]struct{}),
+	}
+
+	var conns sync.WaitGroup
+	t.Cleanup(func() {
+		_ = ln.Close()
+		r.mu.Lock()
+		for conn := range r.channels {
+			_ = conn.Close()
+		}
+		r.mu.Unlock()
+		conns.Wait()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			c := &fakeRedisConn{Conn: conn}
+			r.mu.Lock()
+			r.channels[c] = make(map[string]struct{})
+			r.patterns[c] = make(map[string]struct{})
+			r.mu.Unlock()
+
+			conns.Add(1)
+			go func() {
+				defer conns.Done()
+				defer r.forget(c)
+
+				br := bufio.NewReader(c)
+				for {
+					cmd, err := readRESPCommand(br)
+					if err != nil {
+						return
+					}
+					if err = c.write(r.reply(c, cmd)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return r
+}
+
+func (r *fakePubSubRedis) forget(c *fakeRedisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.channels, c)
+	delete(r.patterns, c)
+	_ = c.Close()
+}
+
+func (r *fakePubSubRedis) reply(c *fakeRedisConn, cmd []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch cmd[0] {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		subscribed := r.channels[c]
+		if cmd[0] == "PSUBSCRIBE" {
+			subscribed = r.patterns[c]
+		}
+
+		var reply string
+		for _, channel := range cmd[1:] {
+			subscribed[channel] = struct{}{}
+			count := len(r.channels[c]) + len(r.patterns[c])
+			reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(count) + "\r\n"
+		}
+		return reply
+	case "PUBSUB":
+		return "*2\r\n" + respArray(cmd[2])[4:] + ":" + strconv.Itoa(r.subscribers(cmd[2])) + "\r\n"
+	case "PUBLISH":
+		received := 0
+		for conn, channels := range r.channels {
+			if _, ok := channels[cmd[1]]; ok && conn.write(respArray("message", cmd[1], cmd[2])) == nil {
+				received++
+			}
+		}
+		for conn, patterns := range r.patterns {
+			for pattern := range patterns {
+				if matchFakePattern(pattern, cmd[1]) && conn.write(respArray("pmessage", pattern, cmd[1], cmd[2])) == nil {
+					received++
+				}
+			}
+		}
+		return ":" + strconv.Itoa(received) + "\r\n"
+	}
+
+	return ":1\r\n"
+}
+
+// subscribers gives number of connections subscribed to channel, mu must be held.
+func (r *fakePubSubRedis) subscribers(channel string) int {
+	n := 0
+	for _, channels := range r.channels {
+		if _, ok := channels[channel]; ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// patternSubscribers gives number of connections subscribed to pattern.
+func (r *fakePubSubRedis) patternSubscribers(pattern string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, patterns := range r.patterns {
+		if _, ok := patterns[pattern]; ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+func matchFakePattern(pattern, channel string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(channel, prefix)
+	}
+
+	return pattern == channel
+}
+
+// newRedisServers gives two servers of cluster of fakePubSubRedis.
+func newRedisServers(t *testing.T, namespace string) ([]*Server, []*lockedRecorder) {
+	redisServer := newFakePubSubRedis(t)
+
+	return newAdapterServers(t, namespace, func(_ int, server *Server) error {
+		_, err := server.Adapter(&RedisAdapterOptions{Addr: redisServer.addr})
+		return err
+	}, func() bool {
+		return redisServer.patternSubscribers("socket.io#"+namespace+"#*") == 2
+	})
+}
+
+func TestRedisAdapterOptionsSentinel(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	master := fakeRedis(t, func(cmd []string) string {
+		return respArray("master")
+	})
+	replica := fakeRedis(t, func(cmd []string) string {
+		return respArray("slave")
+	})
+
+	sentinel := func(addr string) string {
+		host, port, err := net.SplitHostPort(addr)
+		must.NoError(err)
+
+		return fakeRedis(t, func(cmd []string) string {
+			if cmd[0] != "SENTINEL" || cmd[2] != "mymaster" {
+				return "*-1\r\n"
+			}
+			return respArray(host, port)
+		})
+	}
+
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	must.NoError(err)
+	must.NoError(down.Close())
+
+	opts := getOptions(&RedisAdapterOptions{
+		MasterName:    "mymaster",
+		SentinelAddrs: []string{down.Addr().String(), sentinel(master)},
+	})
+	should.Equal("mymaster", opts.MasterName)
+
+	addr, err := opts.masterAddr()
+	must.NoError(err)
+	should.Equal(master, addr)
+
+	conn, err := opts.dial()
+	must.NoError(err)
+	should.NoError(conn.Close())
+
+	// sentinel gives old master, which is replica already
+	opts.SentinelAddrs = []string{sentinel(replica)}
+	_, err = opts.dial()
+	should.ErrorContains(err, "not master")
+
+	opts.MasterName = "other"
+	_, err = opts.dial()
+	should.ErrorContains(err, `doesn't monitor "other"`)
+}
+
+func TestRedisAdapterOptionsConnection(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		lock     sync.Mutex
+		commands [][]string
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		lock.Lock()
+		commands = append(commands, cmd)
+		lock.Unlock()
+
+		if cmd[0] == "BRPOP" {
+			time.Sleep(100 * time.Millisecond)
+			return "*-1\r\n"
+		}
+		return "+OK\r\n"
+	})
+
+	opts := getOptions(&RedisAdapterOptions{
+		Addr:        addr,
+		Username:    "app",
+		Password:    "secret",
+		DB:          2,
+		DialTimeout: time.Second,
+		ReadTimeout: 50 * time.Millisecond,
+		PoolSize:    4,
+	})
+	should.Equal("app", opts.Username)
+	should.Equal(2, opts.DB)
+	should.Equal(50*time.Millisecond, opts.ReadTimeout)
+
+	conn, err := opts.dial()
+	must.NoError(err)
+	defer conn.Close()
+
+	// blocking command is given its block time on top of read timeout
+	_, err = opts.doBlocking(conn, time.Second, "BRPOP", "jobs", 1)
+	should.NoError(err)
+
+	_, err = conn.Do("BRPOP", "jobs", 1)
+	should.Error(err)
+
+	lock.Lock()
+	should.Equal([]string{"AUTH", "app", "secret"}, commands[0])
+	should.Equal([]string{"SELECT", "2"}, commands[1])
+	lock.Unlock()
+
+	pool := opts.newPool()
+	defer pool.Close()
+	should.Equal(4, pool.MaxActive)
+	should.Equal(2, pool.MaxIdle)
+	should.True(pool.Wait)
+
+	// custom dial
+	var dialed string
+	opts = getOptions(&RedisAdapterOptions{
+		Addr: addr,
+		Dial: func(network, addr string) (redis.Conn, error) {
+			dialed = network + "://" + addr
+			return redis.Dial(network, addr)
+		},
+	})
+	conn, err = opts.dial()
+	must.NoError(err)
+	should.NoError(conn.Close())
+	should.Equal("tcp://"+addr, dialed)
+}
@@ -1,6 +1,9 @@
 package socketio
 
-import "sync"
+import (
+	"errors"
+	"sync"
+)
 
 // EachFunc typed for each callback function
 type EachFunc func(Conn)
@@ -19,67 +22,128 @@ type Broadcast interface {
 	AllRooms() []string                           // Gives list of all the rooms the connection joined
 }
 
+// ErrRoomSetUnsupported is returned when broadcaster of namespace doesn't select connections by
+// room sets.
+var ErrRoomSetUnsupported = errors.New("broadcaster doesn't support room sets")
+
+// RoomSetBroadcast is optional interface of Broadcast which selects connections by room sets,
+// broadcasts of the package implement it. Broadcasts of namespaces which don't implement it
+// aren't used by broadcasts to room sets.
+type RoomSetBroadcast interface {
+	SendRoomSet(set *RoomSet, event string, args ...interface{}) // SendRoomSet will send an event with args to the connections selected by the room set
+	ForEachRoomSet(set *RoomSet, f EachFunc)                     // ForEachRoomSet calls f for every connection selected by the room set
+}
+
+// localRoomsLister is implemented by broadcasts whose AllRooms gives rooms of the whole cluster,
+// allRooms gives rooms with members on this node.
+type localRoomsLister interface {
+	allRooms() []string
+}
+
 // broadcast gives Join, Leave & BroadcastTO server API support to socket.io along with room management
 // map of rooms where each room contains a map of connection id to connections in that room
 type broadcast struct {
 	rooms map[string]map[string]Conn
+	tree  roomTree
 
 	lock sync.RWMutex
+
+	// onEmpty is called once room is left empty, see Server.OnRoomEmpty.
+	onEmpty func(room, lastConnID string)
+
+	// joins wakes tickers of rooms, see Server.RoomTicker.
+	joins *roomWatchers
 }
 
 // newBroadcast creates a new broadcast adapter
 func newBroadcast() *broadcast {
 	return &broadcast{
 		rooms: make(map[string]map[string]Conn),
+		tree:  make(roomTree),
+		joins: &roomWatchers{},
 	}
 }
 
 // Join joins the given connection to the broadcast room
 func (bc *broadcast) Join(room string, connection Conn) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
 	if _, ok := bc.rooms[room]; !ok {
 		bc.rooms[room] = make(map[string]Conn)
+		bc.tree.add(room)
 	}
 
+	_, member := bc.rooms[room][connection.ID()]
 	bc.rooms[room][connection.ID()] = connection
+
+	bc.lock.Unlock()
+
+	if !member {
+		bc.roomJoined(room, connection.ID())
+	}
 }
 
 // Leave leaves the given connection from given room (if exist)
 func (bc *broadcast) Leave(room string, connection Conn) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
+	var left, empty bool
 	if connections, ok := bc.rooms[room]; ok {
+		_, left = connections[connection.ID()]
 		delete(connections, connection.ID())
 
 		if len(connections) == 0 {
 			delete(bc.rooms, room)
+			bc.tree.remove(room)
+			empty = true
 		}
 	}
+
+	bc.lock.Unlock()
+
+	if left {
+		bc.roomLeft(room, connection.ID(), empty)
+	}
 }
 
 // LeaveAll leaves the given connection from all rooms
 func (bc *broadcast) LeaveAll(connection Conn) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
+	left := make(map[string]bool)
 	for room, connections := range bc.rooms {
+		if _, ok := connections[connection.ID()]; !ok {
+			continue
+		}
 		delete(connections, connection.ID())
 
-		if len(connections) == 0 {
+		left[room] = len(connections) == 0
+		if left[room] {
 			delete(bc.rooms, room)
+			bc.tree.remove(room)
 		}
 	}
+
+	bc.lock.Unlock()
+
+	for room, empty := range left {
+		bc.roomLeft(room, connection.ID(), empty)
+	}
 }
 
 // Clear clears the room
 func (bc *broadcast) Clear(room string) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
+	emptied := len(bc.rooms[room]) > 0
 	delete(bc.rooms, room)
+	bc.tree.remove(room)
+
+	bc.lock.Unlock()
+
+	if emptied {
+		bc.roomLeft(room, "", true)
+	}
 }
 
 // Send sends given event & args to all the connections in the specified room
@@ -88,7 +152,7 @@ func (bc *broadcast) Send(room, event string, args ...interface{}) {
 	defer bc.lock.RUnlock()
 
 	for _, connection := range bc.rooms[room] {
-		connection.Emit(event, args...)
+		broadcastEmit(connection, nil, event, args...)
 	}
 }
 
@@ -99,7 +163,7 @@ func (bc *broadcast) SendAll(event string, args ...interface{}) {
 
 	for _, connections := range bc.rooms {
 		for _, connection := range connections {
-			connection.Emit(event, args...)
+			broadcastEmit(connection, nil, event, args...)
 		}
 	}
 }
@@ -119,6 +183,26 @@ func (bc *broadcast) ForEach(room string, f EachFunc) {
 	}
 }
 
+// SendRoomSet sends given event & args to all the connections selected by the room set
+func (bc *broadcast) SendRoomSet(set *RoomSet, event string, args ...interface{}) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	for _, connection := range set.eval(bc.rooms, bc.tree) {
+		broadcastEmit(connection, nil, event, args...)
+	}
+}
+
+// ForEachRoomSet calls f for every connection selected by the room set
+func (bc *broadcast) ForEachRoomSet(set *RoomSet, f EachFunc) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	for _, connection := range set.eval(bc.rooms, bc.tree) {
+		f(connection)
+	}
+}
+
 // Len gives number of connections in the room
 func (bc *broadcast) Len(room string) int {
 	bc.lock.RLock()
@@ -0,0 +1,257 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// ErrBroadcastAckUnsupported is returned by BroadcastToRoomWithAck when broadcaster of namespace
+// doesn't collect acks of other nodes, e.g. adapter of message bus or node.js compatible redis
+// adapter. Event isn't broadcast then.
+var ErrBroadcastAckUnsupported = errors.New("broadcaster doesn't collect acks")
+
+// BroadcastReply is ack of connection to event of BroadcastToRoomWithAck.
+type BroadcastReply struct {
+	ID string `json:"id"`
+	// Node is id of node which serves connection, it's empty without adapter.
+	Node string `json:"node,omitempty"`
+	// Args are arguments of ack, as values of any JSON type.
+	Args []interface{} `json:"args"`
+}
+
+// ackBroadcaster is broadcast which collects acks of connections of room.
+type ackBroadcaster interface {
+	broadcastWithAck(room, event string, args []interface{}, timeout time.Duration) ([]BroadcastReply, error)
+}
+
+// ackChecker is ackBroadcaster which can't collect acks of other nodes in some setups, ackSupport
+// gives ErrBroadcastAckUnsupported then, so event isn't broadcast with partial acks.
+type ackChecker interface {
+	ackSupport() error
+}
+
+// BroadcastToRoomWithAck broadcasts event with args to connections of room on every node and
+// waits up to timeout for their acks, like io.to(room).timeout(timeout).emitWithAck() of node.js.
+// Replies are sorted by id of connection. When some connections don't acknowledge in time, it
+// gives replies which were received with ErrAckTimeout, and with ErrRoomQueryTimeout when some
+// nodes don't respond within RequestTimeout of adapter after timeout.
+//
+// Acks are collected by redis adapter, peer mesh adapter and without adapter. Adapters of message
+// bus, i.e. kafka, mongodb, postgres and nsq, don't know how many nodes would respond, and node.js
+// compatible redis adapter has no such request, so they give ErrBroadcastAckUnsupported without
+// broadcasting event, rather than acks of this node only.
+func (s *Server) BroadcastToRoomWithAck(namespace, room, event string, args []interface{}, timeout time.Duration) ([]BroadcastReply, error) {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return nil, fmt.Errorf("namespace %q doesn't exist", namespace)
+	}
+
+	if err := nspHandler.validateRoomEvent(room, event, args); err != nil {
+		return nil, err
+	}
+
+	if err := nspHandler.allowRoomBroadcast(room); err != nil {
+		return nil, err
+	}
+
+	b, ok := nspHandler.getBroadcast().(ackBroadcaster)
+	if !ok {
+		return nil, ErrBroadcastAckUnsupported
+	}
+
+	if checker, ok := b.(ackChecker); ok {
+		if err := checker.ackSupport(); err != nil {
+			logger.Error("broadcast with ack to room "+room+" of "+namespace+":", err)
+			return nil, err
+		}
+	}
+
+	s.recordBroadcast(namespace, room, nil, event, args)
+
+	return b.broadcastWithAck(room, event, args, timeout)
+}
+
+func (bc *broadcast) broadcastWithAck(room, event string, args []interface{}, timeout time.Duration) ([]BroadcastReply, error) {
+	replies, missing := collectAcks(bc.ForEach, room, "", event, args, timeout)
+
+	return replies, missingAcks(missing, len(replies)+missing)
+}
+
+// collectAcks emits event to connections of room given by forEach and waits up to timeout for
+// their acks, missing counts connections which didn't acknowledge.
+func collectAcks(forEach func(string, EachFunc), room, node, event string, args []interface{}, timeout time.Duration) (replies []BroadcastReply, missing int) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	forEach(room, func(connection Conn) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			reply, err := connection.EmitWithAck(ctx, event, args...)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				missing++
+				return
+			}
+			replies = append(replies, BroadcastReply{ID: connection.ID(), Node: node, Args: reply})
+		}()
+	})
+	wg.Wait()
+
+	sortReplies(replies)
+
+	return replies, missing
+}
+
+func sortReplies(replies []BroadcastReply) {
+	sort.Slice(replies, func(i, j int) bool {
+		return replies[i].ID < replies[j].ID
+	})
+}
+
+// missingAcks gives error of connections which didn't acknowledge broadcast, nil when all did.
+func missingAcks(missing, total int) error {
+	if missing == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d of %d connections didn't acknowledge", ErrAckTimeout, missing, total)
+}
+
+// broadcastAckRequest asks nodes to broadcast event to their connections of room and respond
+// with acks.
+type broadcastAckRequest struct {
+	RequestType    string
+	RequestID      string
+	Room           string
+	Event          string
+	Args           string           // JSON of args
+	Timeout        string           // milliseconds
+	replies        []BroadcastReply `json:"-"`
+	missing        int              `json:"-"`
+	pendingRequest `json:"-"`
+}
+
+type broadcastAckResponse struct {
+	RequestType string
+	RequestID   string
+	NodeID      string
+	Replies     []BroadcastReply
+	Missing     int
+}
+
+func (bc *redisBroadcast) broadcastWithAck(room, event string, args []interface{}, timeout time.Duration) ([]BroadcastReply, error) {
+	if err := bc.ackSupport(); err != nil {
+		return nil, err
+	}
+
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	req := broadcastAckRequest{
+		RequestType: broadcastAckReqType,
+		RequestID:   newV4UUID(),
+		Room:        room,
+		Event:       event,
+		Args:        string(argsJSON),
+		Timeout:     strconv.FormatInt(timeout.Milliseconds(), 10),
+	}
+
+	reqJSON, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	numSub, err := bc.getNumSub(bc.reqChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	// nodes respond once acks of their connections are collected
+	release, err := bc.register(context.Background(), req.RequestID, &req, numSub, timeout+bc.opts.getRequestTimeout())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if _, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON); err != nil {
+		return nil, err
+	}
+
+	err = req.wait()
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	replies := append([]BroadcastReply{}, req.replies...)
+	sortReplies(replies)
+
+	return replies, errors.Join(missingAcks(req.missing, len(replies)+req.missing), err)
+}
+
+// ackSupport gives ErrBroadcastAckUnsupported in node.js compatible mode, whose requests don't
+// broadcast with ack.
+func (bc *redisBroadcast) ackSupport() error {
+	if bc.nodeCompatible() {
+		return fmt.Errorf("%w: node.js compatible redis adapter", ErrBroadcastAckUnsupported)
+	}
+
+	return nil
+}
+
+// onBroadcastAckRequest broadcasts event of request to connections of room on this node and
+// responds their acks.
+func (bc *redisBroadcast) onBroadcastAckRequest(req map[string]string) {
+	var args []interface{}
+	if err := json.Unmarshal([]byte(req["Args"]), &args); err != nil {
+		bc.reportError(fmt.Errorf("broadcast with ack %q: %w", req["Event"], err))
+		return
+	}
+
+	ms, _ := strconv.ParseInt(req["Timeout"], 10, 64)
+
+	// acks are awaited by another goroutine, so messages of subscription are still received
+	go func() {
+		replies, missing := collectAcks(bc.ForEach, req["Room"], bc.uid, req["Event"], args, time.Duration(ms)*time.Millisecond)
+
+		bc.publish(bc.resChannel, &broadcastAckResponse{
+			RequestType: req["RequestType"],
+			RequestID:   req["RequestID"],
+			NodeID:      bc.uid,
+			Replies:     replies,
+			Missing:     missing,
+		})
+	}()
+}
+
+// onBroadcastAckResponse applies acks of connections of node to request.
+func (bc *redisBroadcast) onBroadcastAckResponse(req *broadcastAckRequest, msg []byte) {
+	var res broadcastAckResponse
+	if err := json.Unmarshal(msg, &res); err != nil {
+		return
+	}
+
+	req.respond(res.NodeID, func() {
+		for _, reply := range res.Replies {
+			reply.Node = res.NodeID
+			req.replies = append(req.replies, reply)
+		}
+		req.missing += res.Missing
+	})
+}
@@ -0,0 +1,239 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestServerBroadcastToRoomWithAck(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t, func(server *Server) {
+		server.OnEvent("/", "join", func(c Conn) string {
+			c.Join("lobby")
+			return c.ID()
+		})
+	})
+
+	var clients []*protocolClient
+	var ids []string
+	for i := 0; i < 2; i++ {
+		c := newProtocolClient(t, url)
+		defer c.conn.Close()
+		_, _, _ = c.receive(t)
+
+		c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "join")
+		header, _, args := c.receive(t, reflect.TypeOf(""))
+		must.Equal(parser.Ack, header.Type)
+		must.Len(args, 1)
+
+		clients = append(clients, c)
+		ids = append(ids, args[0].(string))
+	}
+
+	type result struct {
+		replies []BroadcastReply
+		err     error
+	}
+	results := make(chan result, 1)
+	go func() {
+		replies, err := server.BroadcastToRoomWithAck("/", "lobby", "question", []interface{}{"ready?"}, 300*time.Millisecond)
+		results <- result{replies: replies, err: err}
+	}()
+
+	for i, c := range clients {
+		header, event, args := c.receive(t, reflect.TypeOf(""))
+		must.Equal(parser.Event, header.Type)
+		must.True(header.NeedAck)
+		should.Equal("question", event)
+		should.Equal([]interface{}{"ready?"}, args)
+
+		// the second client doesn't acknowledge
+		if i == 0 {
+			c.send(t, parser.Header{Type: parser.Ack, ID: header.ID, NeedAck: true}, "yes", 1)
+		}
+	}
+
+	res := <-results
+	should.True(errors.Is(res.err, ErrAckTimeout))
+	should.Contains(res.err.Error(), "1 of 2 connections")
+	must.Len(res.replies, 1)
+	should.Equal(BroadcastReply{ID: ids[0], Args: []interface{}{"yes", float64(1)}}, res.replies[0])
+
+	replies, err := server.BroadcastToRoomWithAck("/", "empty", "question", nil, time.Second)
+	should.NoError(err)
+	should.Empty(replies)
+
+	_, err = server.BroadcastToRoomWithAck("/missing", "lobby", "question", nil, time.Second)
+	should.Error(err)
+}
+
+func TestServerBroadcastToRoomWithAckConcurrent(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t, func(server *Server) {
+		server.OnEvent("/", "join", func(c Conn) string {
+			c.Join("lobby")
+			return c.ID()
+		})
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "join")
+	_, _, _ = c.receive(t, reflect.TypeOf(""))
+
+	// broadcasts emit to the same connection from their own goroutines
+	const broadcasts = 4
+	replies := make(chan [2]interface{}, broadcasts)
+	for i := 0; i < broadcasts; i++ {
+		go func(i int) {
+			res, err := server.BroadcastToRoomWithAck("/", "lobby", "question", []interface{}{i}, 5*time.Second)
+			if err != nil || len(res) != 1 || len(res[0].Args) != 1 {
+				replies <- [2]interface{}{i, err}
+				return
+			}
+			replies <- [2]interface{}{i, res[0].Args[0]}
+		}(i)
+	}
+
+	ids := make(map[uint64]bool)
+	for i := 0; i < broadcasts; i++ {
+		header, _, args := c.receive(t, reflect.TypeOf(0))
+		must.True(header.NeedAck)
+		should.False(ids[header.ID], "ack id %d is reused", header.ID)
+		ids[header.ID] = true
+
+		c.send(t, parser.Header{Type: parser.Ack, ID: header.ID, NeedAck: true}, args[0])
+	}
+
+	for i := 0; i < broadcasts; i++ {
+		reply := <-replies
+		should.Equal(float64(reply[0].(int)), reply[1])
+	}
+}
+
+func TestRedisBroadcastWithAck(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 16)
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBSUB":
+			return "*2\r\n" + respArray(cmd[2])[4:] + ":2\r\n"
+		case "PUBLISH":
+			published <- cmd[1:]
+		}
+		return ":1\r\n"
+	})
+
+	bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{Addr: addr, NodeID: "node1"}))
+	must.NoError(err)
+
+	c := newConn(addrEngineConn{id: "1"}, newNamespaceHandlers())
+	nc := newNamespaceConn(c, "/chat", bc)
+	nc.Join("lobby")
+
+	// local connection acknowledges event it's written
+	go func() {
+		for pkg := range c.writeChan {
+			should.Equal("question", pkg.Data[0])
+
+			ack, ok := nc.ack.LoadAndDelete(pkg.Header.ID)
+			if should.True(ok) {
+				_, _ = ack.(*funcHandler).Call([]reflect.Value{reflect.ValueOf("from 1")})
+			}
+		}
+	}()
+	defer close(c.quitChan)
+
+	// this node answers request like redis would deliver it, node2 answers with one missing ack
+	go func() {
+		for msg := range published {
+			switch {
+			case strings.HasPrefix(msg[0], "socket.io-request#"):
+				var req map[string]string
+				_ = json.Unmarshal([]byte(msg[1]), &req)
+				should.Equal(broadcastAckReqType, req["RequestType"])
+				should.Equal(`["ready?"]`, req["Args"])
+				should.Equal("200", req["Timeout"])
+
+				bc.onRequest([]byte(msg[1]))
+
+				res, _ := json.Marshal(&broadcastAckResponse{
+					RequestType: req["RequestType"],
+					RequestID:   req["RequestID"],
+					NodeID:      "node2",
+					Replies:     []BroadcastReply{{ID: "0", Args: []interface{}{"from 0"}}},
+					Missing:     1,
+				})
+				bc.onResponse(res)
+			case strings.HasPrefix(msg[0], "socket.io-response#"):
+				bc.onResponse([]byte(msg[1]))
+			}
+		}
+	}()
+	defer close(published)
+
+	replies, err := bc.broadcastWithAck("lobby", "question", []interface{}{"ready?"}, 200*time.Millisecond)
+	should.True(errors.Is(err, ErrAckTimeout))
+	should.Contains(err.Error(), "1 of 3 connections")
+	should.Equal([]BroadcastReply{
+		{ID: "0", Node: "node2", Args: []interface{}{"from 0"}},
+		{ID: "1", Node: "node1", Args: []interface{}{"from 1"}},
+	}, replies)
+}
+
+func TestServerBroadcastToRoomWithAckUnsupported(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(nil)
+	nspHandler := server.createNamespace("/chat")
+
+	var published int
+	bus := newBusAdapter("node1", func(*busRecord, []byte) error {
+		published++
+		return nil
+	})
+	node := &redisBroadcast{
+		rooms: make(map[string]map[string]Conn),
+		tree:  make(roomTree),
+		opts:  getOptions(&RedisAdapterOptions{NodeCompatible: true}),
+	}
+
+	for _, bc := range []Broadcast{bus.newBroadcast("/chat"), node} {
+		nspHandler.setBroadcast(bc)
+
+		recorder := newLockedRecorder("1", "/chat", nil)
+		server.JoinRoom("/chat", "lobby", recorder)
+
+		// event isn't broadcast with acks of this node only
+		replies, err := server.BroadcastToRoomWithAck("/chat", "lobby", "question", nil, time.Second)
+		should.True(errors.Is(err, ErrBroadcastAckUnsupported), "%T", bc)
+		should.Nil(replies)
+		should.Empty(recorder.received())
+	}
+	must.Zero(published)
+}
@@ -0,0 +1,140 @@
+package socketio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// EnableBroadcastDedup drops broadcasts with MessageID of BroadcastOptions which was broadcast
+// within window, so producers which deliver at least once, e.g. retrying queue consumers, don't
+// cause duplicate deliveries to clients. With adapter, ids are claimed in redis, so duplicate is
+// dropped by whichever node receives it, else they're kept by this server. Broadcast is sent when
+// redis fails, so duplicate is preferred to loss.
+func (s *Server) EnableBroadcastDedup(window time.Duration) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.dedup = &broadcastDedup{
+		window: window,
+		local:  &localDedupStore{ids: make(map[string]time.Time)},
+	}
+	s.OnServerShutdownComplete(s.dedup.close)
+
+	return nil
+}
+
+// dedupStore claims ids of broadcasts for window.
+type dedupStore interface {
+	// claim takes id for window, it reports false when id was claimed within window.
+	claim(id string, window time.Duration) (bool, error)
+}
+
+type broadcastDedup struct {
+	window time.Duration
+	local  *localDedupStore
+
+	// remote is made once adapter claims first id, adapter may be set after dedup is enabled.
+	remote     *redisDedupStore
+	remoteOnce sync.Once
+}
+
+func (d *broadcastDedup) store(opts *RedisAdapterOptions) dedupStore {
+	if opts == nil {
+		return d.local
+	}
+
+	d.remoteOnce.Do(func() {
+		d.remote = newRedisDedupStore(opts)
+	})
+
+	return d.remote
+}
+
+func (d *broadcastDedup) close() {
+	d.remoteOnce.Do(func() {})
+
+	if d.remote != nil {
+		_ = d.remote.pool.Close()
+	}
+}
+
+// firstBroadcast tells whether broadcast of message id in namespace wasn't broadcast within window.
+func (s *Server) firstBroadcast(namespace, id string) bool {
+	if s.dedup == nil || id == "" {
+		return true
+	}
+
+	store := s.dedup.store(s.getRedisAdapter())
+
+	if namespace == aliasRootNamespace {
+		namespace = rootNamespace
+	}
+
+	first, err := store.claim(namespace+"#"+id, s.dedup.window)
+	if err != nil {
+		logger.Error("claim broadcast id:", err)
+		return true
+	}
+
+	return first
+}
+
+// localDedupStore keeps ids in memory of server.
+type localDedupStore struct {
+	mu    sync.Mutex
+	ids   map[string]time.Time
+	swept time.Time
+}
+
+func (s *localDedupStore) claim(id string, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) >= window {
+		s.swept = now
+		for seenID, expires := range s.ids {
+			if !now.Before(expires) {
+				delete(s.ids, seenID)
+			}
+		}
+	}
+
+	if expires, ok := s.ids[id]; ok && now.Before(expires) {
+		return false, nil
+	}
+	s.ids[id] = now.Add(window)
+
+	return true, nil
+}
+
+// redisDedupStore claims ids in redis of adapter, so they're shared by the cluster.
+type redisDedupStore struct {
+	opts *RedisAdapterOptions
+	pool *redis.Pool
+}
+
+func newRedisDedupStore(opts *RedisAdapterOptions) *redisDedupStore {
+	return &redisDedupStore{opts: opts, pool: opts.newPool()}
+}
+
+func (s *redisDedupStore) claim(id string, window time.Duration) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", fmt.Sprintf("%s-dedup#%s", s.opts.Prefix, id), 1, "NX", "PX", window.Milliseconds()))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
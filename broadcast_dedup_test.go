@@ -0,0 +1,101 @@
+package socketio
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestBroadcastDedup(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	should.NoError(server.EnableBroadcastDedup(time.Hour))
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", nil)}
+	server.JoinRoom("/", "lobby", recorder)
+
+	lobby := Union("lobby")
+	should.True(server.BroadcastWithOptions("/", lobby, &BroadcastOptions{MessageID: "1"}, "first"))
+	should.False(server.BroadcastWithOptions("/", lobby, &BroadcastOptions{MessageID: "1"}, "duplicate"))
+	should.True(server.BroadcastWithOptions("/", lobby, &BroadcastOptions{MessageID: "2"}, "second"))
+	should.True(server.BroadcastWithOptions("/", lobby, nil, "without id"))
+	should.True(server.BroadcastWithOptions("/", lobby, nil, "without id"))
+	should.True(server.BroadcastWithOptions("/chat", lobby, &BroadcastOptions{MessageID: "1"}, "other namespace"))
+
+	should.Equal([]string{"first", "second", "without id", "without id"}, recorder.events)
+}
+
+func TestLocalDedupStore(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	store := &localDedupStore{ids: make(map[string]time.Time)}
+
+	first, err := store.claim("1", 20*time.Millisecond)
+	must.NoError(err)
+	should.True(first)
+
+	first, err = store.claim("1", 20*time.Millisecond)
+	must.NoError(err)
+	should.False(first)
+
+	time.Sleep(30 * time.Millisecond)
+
+	first, err = store.claim("1", 20*time.Millisecond)
+	must.NoError(err)
+	should.True(first, "id is claimed again once window passed")
+	should.Len(store.ids, 1)
+}
+
+func TestRedisDedupStore(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var mu sync.Mutex
+	claimed := make(map[string]bool)
+	addr := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cmd[0] != "SET" || claimed[cmd[1]] {
+			return "$-1\r\n"
+		}
+		claimed[cmd[1]] = true
+		return "+OK\r\n"
+	})
+
+	var dials int32
+	store := newRedisDedupStore(getOptions(&RedisAdapterOptions{
+		Addr:   addr,
+		Prefix: "app",
+		Dial: func(network, addr string) (redis.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return redis.Dial(network, addr)
+		},
+	}))
+	defer store.pool.Close()
+
+	for i := 0; i < 10; i++ {
+		first, err := store.claim("/#"+strconv.Itoa(i%5), time.Minute)
+		must.NoError(err)
+		should.Equal(i < 5, first)
+	}
+
+	should.True(claimed["app-dedup#/#0"])
+	should.Equal(int32(1), atomic.LoadInt32(&dials), "connection is reused by claims")
+}
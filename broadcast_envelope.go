@@ -0,0 +1,68 @@
+package socketio
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxHops = 8
+
+	// seenEnvelopeTTL is how long ids of received broadcasts are kept to drop their duplicates.
+	seenEnvelopeTTL = time.Minute
+)
+
+// broadcastEnvelope is broadcast published to other nodes by adapter.
+//
+// Meta tracks where broadcast comes from, so relays between adapters, e.g. bridges of
+// regions, can't loop it: node drops broadcast which it originated, which it received
+// already or which was relayed more than MaxHops times. Relay must keep meta of broadcast
+// and increment its hops. Meta is missing in broadcasts of nodes of older versions.
+type broadcastEnvelope struct {
+	Opts []interface{} `json:"opts"`
+	Args []interface{} `json:"args"`
+	Meta *envelopeMeta `json:"meta,omitempty"`
+}
+
+type envelopeMeta struct {
+	// Origin is id of node which published broadcast.
+	Origin string `json:"origin"`
+	// ID identifies broadcast, it's kept by relays.
+	ID string `json:"id"`
+	// Hops counts relays of broadcast, it's zero when it's received from origin.
+	Hops int `json:"hops"`
+}
+
+// seenEnvelopes keeps ids of received broadcasts for seenEnvelopeTTL.
+type seenEnvelopes struct {
+	mu    sync.Mutex
+	ids   map[string]time.Time
+	swept time.Time
+}
+
+// seen records id of broadcast, it reports whether id was seen already.
+func (s *seenEnvelopes) seen(id string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ids == nil {
+		s.ids = make(map[string]time.Time)
+		s.swept = now
+	}
+
+	if now.Sub(s.swept) >= seenEnvelopeTTL {
+		s.swept = now
+		for seenID, at := range s.ids {
+			if now.Sub(at) >= seenEnvelopeTTL {
+				delete(s.ids, seenID)
+			}
+		}
+	}
+
+	if at, ok := s.ids[id]; ok && now.Sub(at) < seenEnvelopeTTL {
+		return true
+	}
+	s.ids[id] = now
+
+	return false
+}
@@ -0,0 +1,77 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBroadcastLoopDetection(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	bc := &redisBroadcast{
+		nsp:   "/",
+		uid:   "node-a",
+		rooms: make(map[string]map[string]Conn),
+		tree:  make(roomTree),
+		opts:  &RedisAdapterOptions{MaxHops: 2},
+	}
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", nil)}
+	bc.rooms["lobby"] = map[string]Conn{"1": recorder}
+
+	receive := func(event string, meta *envelopeMeta) {
+		msg, err := json.Marshal(broadcastEnvelope{
+			Opts: []interface{}{"lobby", event},
+			Meta: meta,
+		})
+		must.NoError(err)
+		must.NoError(bc.onMessage("socket.io#/#node-b", msg))
+	}
+
+	receive("published", &envelopeMeta{Origin: "node-b", ID: "1"})
+	receive("duplicate", &envelopeMeta{Origin: "node-b", ID: "1"})
+	receive("relayed", &envelopeMeta{Origin: "node-c", ID: "2", Hops: 2})
+	receive("looped", &envelopeMeta{Origin: "node-a", ID: "3", Hops: 1})
+	receive("too many hops", &envelopeMeta{Origin: "node-c", ID: "4", Hops: 3})
+	receive("without meta", nil)
+
+	should.Equal([]string{"published", "relayed", "without meta"}, recorder.events)
+	should.Error(bc.onMessage("socket.io#/#node-b", []byte(`{"opts":[]}`)))
+}
+
+func TestSeenEnvelopes(t *testing.T) {
+	should := assert.New(t)
+
+	var seen seenEnvelopes
+	now := time.Now()
+
+	should.False(seen.seen("1", now))
+	should.True(seen.seen("1", now.Add(time.Second)))
+	should.False(seen.seen("2", now.Add(time.Second)))
+
+	should.False(seen.seen("1", now.Add(seenEnvelopeTTL)), "id expires")
+	should.True(seen.seen("2", now.Add(seenEnvelopeTTL)), "id is kept until it expires")
+}
+
+func TestDeliveryFailureOrigin(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	permanent := errors.New("closed")
+	c, failures := newDeliveryConn(&failingEngineConn{failures: 1, err: permanent})
+	nc, _ := c.namespaces.Get("/chat")
+
+	go broadcastEmit(nc, &BroadcastOptions{origin: "node-b"}, "message", "hello")
+	c.writePacket(<-c.writeChan)
+
+	must.Len(failures, 1)
+	failure := <-failures
+	should.Equal("node-b", failure.Origin)
+	should.Equal(permanent, failure.Err)
+}
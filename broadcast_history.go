@@ -0,0 +1,227 @@
+package socketio
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	defaultHistoryMaxRooms = 1024
+
+	historySubscriptionBuffer = 64
+)
+
+// BroadcastHistoryOptions configures history of broadcasts, see Server.EnableBroadcastHistory.
+type BroadcastHistoryOptions struct {
+	// Size is how many last broadcasts are kept for each room, broadcasts are only delivered to
+	// subscribers when it's zero.
+	Size int
+
+	// MaxRooms is how many rooms keep history, default is 1024. History of room which was
+	// broadcast to least recently is dropped to keep history of another room.
+	MaxRooms int
+}
+
+func (o *BroadcastHistoryOptions) getSize() int {
+	if o == nil || o.Size <= 0 {
+		return 0
+	}
+
+	return o.Size
+}
+
+func (o *BroadcastHistoryOptions) getMaxRooms() int {
+	if o == nil || o.MaxRooms <= 0 {
+		return defaultHistoryMaxRooms
+	}
+
+	return o.MaxRooms
+}
+
+// broadcastHistory keeps last broadcasts of rooms and delivers broadcasts to subscribers.
+type broadcastHistory struct {
+	size     int
+	maxRooms int
+
+	rooms map[historyKey]*historyRing
+	// seq orders rooms by their last broadcast.
+	seq uint64
+
+	subscribers map[chan BroadcastRecord]historyKey
+	lock        sync.RWMutex
+}
+
+type historyKey struct {
+	namespace string
+	room      string
+}
+
+func newHistoryKey(namespace, room string) historyKey {
+	if namespace == aliasRootNamespace {
+		namespace = rootNamespace
+	}
+
+	return historyKey{namespace: namespace, room: room}
+}
+
+// historyRing keeps last broadcasts of room.
+type historyRing struct {
+	records []BroadcastRecord
+	next    int
+	full    bool
+	lastSeq uint64
+}
+
+func (r *historyRing) add(record BroadcastRecord) {
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// tail gives up to n last broadcasts, the oldest first.
+func (r *historyRing) tail(n int) []BroadcastRecord {
+	count := r.next
+	if r.full {
+		count = len(r.records)
+	}
+	if n > count {
+		n = count
+	}
+
+	tail := make([]BroadcastRecord, n)
+	for i := 0; i < n; i++ {
+		tail[i] = r.records[(r.next-n+i+len(r.records))%len(r.records)]
+	}
+
+	return tail
+}
+
+// EnableBroadcastHistory keeps last broadcasts of each room, so operators can see what room
+// received recently, see TailBroadcasts and SubscribeBroadcasts. Broadcasts to room sets and to
+// namespace are kept under empty room. Broadcasts of other nodes are kept once adapter delivers
+// them to this node, with id of their node as Origin.
+func (s *Server) EnableBroadcastHistory(opts *BroadcastHistoryOptions) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	h := &broadcastHistory{
+		maxRooms:    opts.getMaxRooms(),
+		size:        opts.getSize(),
+		rooms:       make(map[historyKey]*historyRing),
+		subscribers: make(map[chan BroadcastRecord]historyKey),
+	}
+
+	s.history = h
+	s.observers = append(s.observers, h.observe)
+	s.addRemoteObserver(h.observe)
+
+	return nil
+}
+
+// TailBroadcasts gives up to n last broadcasts of room of namespace, the oldest first. It's nil
+// when history isn't enabled, see EnableBroadcastHistory.
+func (s *Server) TailBroadcasts(namespace, room string, n int) []BroadcastRecord {
+	if s.history == nil || n <= 0 {
+		return nil
+	}
+
+	return s.history.tail(newHistoryKey(namespace, room), n)
+}
+
+// SubscribeBroadcasts gives channel of broadcasts to room of namespace of this server, e.g. for
+// internal consumers, it's closed once ctx is done. Broadcasts which don't fit buffer of slow
+// subscriber are dropped, so subscribers don't hold broadcasts. It's nil when history isn't
+// enabled, see EnableBroadcastHistory.
+func (s *Server) SubscribeBroadcasts(ctx context.Context, namespace, room string) <-chan BroadcastRecord {
+	if s.history == nil {
+		return nil
+	}
+
+	ch := make(chan BroadcastRecord, historySubscriptionBuffer)
+
+	s.history.lock.Lock()
+	s.history.subscribers[ch] = newHistoryKey(namespace, room)
+	s.history.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.history.lock.Lock()
+		delete(s.history.subscribers, ch)
+		s.history.lock.Unlock()
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (h *broadcastHistory) observe(record BroadcastRecord) {
+	key := newHistoryKey(record.Namespace, record.Room)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.size > 0 {
+		h.ring(key).add(record)
+	}
+
+	for ch, subscribed := range h.subscribers {
+		if subscribed != key {
+			continue
+		}
+
+		select {
+		case ch <- record:
+		default:
+		}
+	}
+}
+
+// ring gives history of room, it drops history of room broadcast least recently when there are
+// too many rooms. lock must be held.
+func (h *broadcastHistory) ring(key historyKey) *historyRing {
+	h.seq++
+
+	ring, ok := h.rooms[key]
+	if !ok {
+		if len(h.rooms) >= h.maxRooms {
+			h.evict()
+		}
+
+		ring = &historyRing{records: make([]BroadcastRecord, h.size)}
+		h.rooms[key] = ring
+	}
+	ring.lastSeq = h.seq
+
+	return ring
+}
+
+func (h *broadcastHistory) evict() {
+	var (
+		oldest historyKey
+		seq    uint64
+	)
+	for key, ring := range h.rooms {
+		if seq == 0 || ring.lastSeq < seq {
+			oldest, seq = key, ring.lastSeq
+		}
+	}
+
+	delete(h.rooms, oldest)
+}
+
+func (h *broadcastHistory) tail(key historyKey, n int) []BroadcastRecord {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	ring, ok := h.rooms[key]
+	if !ok {
+		return nil
+	}
+
+	return ring.tail(n)
+}
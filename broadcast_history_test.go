@@ -0,0 +1,97 @@
+package socketio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestBroadcastHistory(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	should.Nil(server.TailBroadcasts("/", "lobby", 10), "history isn't enabled")
+
+	should.NoError(server.EnableBroadcastHistory(&BroadcastHistoryOptions{Size: 2, MaxRooms: 2}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	live := server.SubscribeBroadcasts(ctx, "/", "lobby")
+	must.NotNil(live)
+
+	for _, event := range []string{"first", "second", "third"} {
+		should.True(server.BroadcastToRoom("/", "lobby", event))
+	}
+	should.True(server.BroadcastToNamespace("/", "announcement"))
+
+	events := func(records []BroadcastRecord) []string {
+		var events []string
+		for _, record := range records {
+			events = append(events, record.Event)
+		}
+		return events
+	}
+
+	should.Equal([]string{"second", "third"}, events(server.TailBroadcasts("/", "lobby", 10)))
+	should.Equal([]string{"third"}, events(server.TailBroadcasts("", "lobby", 1)))
+	should.Equal([]string{"announcement"}, events(server.TailBroadcasts("/", "", 10)))
+
+	for _, event := range []string{"first", "second", "third"} {
+		should.Equal(event, (<-live).Event)
+	}
+
+	// history of room broadcast least recently is dropped
+	should.True(server.BroadcastToRoom("/", "games", "start"))
+	should.Nil(server.TailBroadcasts("/", "lobby", 10))
+	should.Equal([]string{"start"}, events(server.TailBroadcasts("/", "games", 10)))
+
+	cancel()
+	_, ok := <-live
+	should.False(ok, "subscription is closed with its context")
+}
+
+func TestBroadcastHistoryCluster(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, _ := newRedisServers(t, "/chat")
+	for _, server := range servers {
+		must.NoError(server.EnableBroadcastHistory(&BroadcastHistoryOptions{Size: 4}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lobby := servers[1].SubscribeBroadcasts(ctx, "/chat", "lobby")
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "message", "hi"))
+	should.True(servers[1].BroadcastToNamespace("/chat", "announce"))
+
+	select {
+	case record := <-lobby:
+		should.Equal("message", record.Event)
+		should.Equal(servers[0].NodeID(), record.Origin)
+	case <-time.After(time.Second):
+		t.Fatal("broadcast of other node isn't delivered to subscriber")
+	}
+
+	// both nodes keep broadcasts of the cluster
+	for _, server := range servers {
+		server := server
+		must.Eventually(func() bool {
+			return len(server.TailBroadcasts("/chat", "lobby", 10)) == 1 && len(server.TailBroadcasts("/chat", "", 10)) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		tail := server.TailBroadcasts("/chat", "lobby", 10)
+		should.Equal([]interface{}{"hi"}, tail[0].Args)
+		should.Equal("announce", server.TailBroadcasts("/chat", "", 10)[0].Event)
+	}
+}
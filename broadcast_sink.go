@@ -0,0 +1,320 @@
+package socketio
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultSinkBufferSize    = 1024
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = time.Second
+)
+
+// BroadcastRecord is broadcast appended to BroadcastSink.
+type BroadcastRecord struct {
+	Namespace string `json:"namespace"`
+	// Room of broadcast, it's empty for broadcast to room set or to namespace.
+	Room string `json:"room,omitempty"`
+	// RoomSet of broadcast, it's nil for broadcast to room or to namespace.
+	RoomSet   *RoomSet      `json:"roomSet,omitempty"`
+	Event     string        `json:"event"`
+	Args      []interface{} `json:"args"`
+	Timestamp time.Time     `json:"timestamp"`
+	// Origin is id of node which broadcast it.
+	Origin string `json:"origin"`
+
+	// bridged is set for broadcast which bridge received from other system, so bridges don't
+	// send it back.
+	bridged bool
+}
+
+// BroadcastSink appends broadcasts to external log, e.g. Kafka topic, file or S3 objects,
+// for replay and analytics.
+type BroadcastSink interface {
+	Append(records []BroadcastRecord) error
+}
+
+// BroadcastSinkFunc is an adapter to allow the use of ordinary function as BroadcastSink.
+type BroadcastSinkFunc func(records []BroadcastRecord) error
+
+// Append calls f(records).
+func (f BroadcastSinkFunc) Append(records []BroadcastRecord) error {
+	return f(records)
+}
+
+// BroadcastSinkOptions configures buffering of broadcasts for sink, see Server.SetBroadcastSink.
+type BroadcastSinkOptions struct {
+	// BufferSize is how many broadcasts wait for sink, default is 1024. Broadcasts which don't
+	// fit are dropped, so slow sink doesn't hold broadcasts.
+	BufferSize int
+
+	// BatchSize is maximum number of broadcasts appended at once, default is 100.
+	BatchSize int
+
+	// FlushInterval is how long broadcasts wait for batch to fill, default is 1 second.
+	FlushInterval time.Duration
+
+	// OnDrop is called with broadcast which is dropped because buffer is full.
+	OnDrop func(record BroadcastRecord)
+}
+
+func (o *BroadcastSinkOptions) getBufferSize() int {
+	if o == nil || o.BufferSize <= 0 {
+		return defaultSinkBufferSize
+	}
+
+	return o.BufferSize
+}
+
+func (o *BroadcastSinkOptions) getBatchSize() int {
+	if o == nil || o.BatchSize <= 0 {
+		return defaultSinkBatchSize
+	}
+
+	return o.BatchSize
+}
+
+func (o *BroadcastSinkOptions) getFlushInterval() time.Duration {
+	if o == nil || o.FlushInterval <= 0 {
+		return defaultSinkFlushInterval
+	}
+
+	return o.FlushInterval
+}
+
+func (o *BroadcastSinkOptions) getOnDrop() func(BroadcastRecord) {
+	if o == nil {
+		return nil
+	}
+
+	return o.OnDrop
+}
+
+// SetBroadcastSink appends every broadcast of this server to sink asynchronously, without
+// touching handlers: broadcasts of Broadcast* methods, EmitToRoom, WithContext, Subscriptions,
+// GraphQLBridge and messages of MQTT clients received by MQTTBridge. Broadcasts received from
+// other nodes through adapter are appended by their origin node. Arguments of broadcasts are appended as
+// they are, so they must not be modified after broadcast. Broadcasts in buffer are appended
+// when server is closed.
+func (s *Server) SetBroadcastSink(sink BroadcastSink, opts *BroadcastSinkOptions) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.sink = newBroadcastSinker(sink, opts)
+
+	s.OnServerShutdownComplete(s.sink.stop)
+
+	return nil
+}
+
+// broadcastSinker buffers broadcasts and appends them to sink in batches.
+type broadcastSinker struct {
+	sink          BroadcastSink
+	batchSize     int
+	flushInterval time.Duration
+	onDrop        func(BroadcastRecord)
+
+	records chan BroadcastRecord
+
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newBroadcastSinker(sink BroadcastSink, opts *BroadcastSinkOptions) *broadcastSinker {
+	bs := &broadcastSinker{
+		sink:          sink,
+		batchSize:     opts.getBatchSize(),
+		flushInterval: opts.getFlushInterval(),
+		onDrop:        opts.getOnDrop(),
+		records:       make(chan BroadcastRecord, opts.getBufferSize()),
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go bs.run()
+
+	return bs
+}
+
+// record buffers broadcast, it's dropped when buffer is full or sinker is stopped.
+func (bs *broadcastSinker) record(record BroadcastRecord) {
+	select {
+	case <-bs.quit:
+		return
+	default:
+	}
+
+	select {
+	case bs.records <- record:
+	default:
+		if bs.onDrop != nil {
+			bs.onDrop(record)
+		}
+	}
+}
+
+// stop appends buffered broadcasts and stops sinker.
+func (bs *broadcastSinker) stop() {
+	bs.once.Do(func() {
+		close(bs.quit)
+	})
+
+	<-bs.done
+}
+
+func (bs *broadcastSinker) run() {
+	defer close(bs.done)
+
+	ticker := time.NewTicker(bs.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]BroadcastRecord, 0, bs.batchSize)
+	for {
+		select {
+		case record := <-bs.records:
+			if batch = append(batch, record); len(batch) >= bs.batchSize {
+				batch = bs.flush(batch)
+			}
+		case <-ticker.C:
+			batch = bs.flush(batch)
+		case <-bs.quit:
+			for {
+				select {
+				case record := <-bs.records:
+					if batch = append(batch, record); len(batch) >= bs.batchSize {
+						batch = bs.flush(batch)
+					}
+				default:
+					bs.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush appends batch to sink, it gives empty batch to fill.
+func (bs *broadcastSinker) flush(batch []BroadcastRecord) []BroadcastRecord {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := bs.sink.Append(batch); err != nil {
+		logger.Error("append broadcasts to sink:", err)
+	}
+
+	return make([]BroadcastRecord, 0, bs.batchSize)
+}
+
+// sendBroadcast sends event with args to room set, to room or, when both are empty, to namespace
+// through broadcast of namespace and records it. Broadcast APIs of server send through it, so
+// each broadcast reaches sink and observers. It returns false when set is given and broadcast of
+// namespace isn't RoomSetBroadcast.
+func (s *Server) sendBroadcast(nspHandler *namespaceHandler, namespace, room string, set *RoomSet, event string, args []interface{}) bool {
+	b := nspHandler.getBroadcast()
+
+	switch {
+	case set != nil:
+		rb, ok := b.(RoomSetBroadcast)
+		if !ok {
+			return false
+		}
+		rb.SendRoomSet(set, event, args...)
+	case room != "":
+		b.Send(room, event, args...)
+	default:
+		b.SendAll(event, args...)
+	}
+
+	s.recordBroadcast(namespace, room, set, event, args)
+
+	return true
+}
+
+// recordBroadcast appends broadcast of this server to sink, if server has one, and passes it to
+// observers.
+func (s *Server) recordBroadcast(namespace, room string, set *RoomSet, event string, args []interface{}) {
+	if s.sink == nil && len(s.observers) == 0 {
+		return
+	}
+
+	s.record(BroadcastRecord{
+		Namespace: namespace,
+		Room:      room,
+		RoomSet:   set,
+		Event:     event,
+		Args:      args,
+		Timestamp: time.Now(),
+		Origin:    s.nodeID,
+	})
+}
+
+func (s *Server) record(record BroadcastRecord) {
+	if s.sink != nil {
+		s.sink.record(record)
+	}
+
+	for _, observe := range s.observers {
+		observe(record)
+	}
+}
+
+// remoteBroadcastReceiver is broadcaster which delivers broadcasts of other nodes to connections
+// of this node.
+type remoteBroadcastReceiver interface {
+	// setOnRemoteBroadcast sets f called with broadcasts of other nodes once they're delivered.
+	setOnRemoteBroadcast(f func(record BroadcastRecord))
+}
+
+// observeRemoteBroadcast passes broadcast of other node delivered by adapter to observers which
+// follow broadcasts of the cluster, e.g. history. It isn't appended to sink, that's done by its
+// origin node.
+func (s *Server) observeRemoteBroadcast(record BroadcastRecord) {
+	// adapter may deliver broadcasts while observers are added
+	s.hooksLock.RLock()
+	observers := s.remoteObservers
+	s.hooksLock.RUnlock()
+
+	for _, observe := range observers {
+		observe(record)
+	}
+}
+
+func (s *Server) addRemoteObserver(f func(BroadcastRecord)) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.remoteObservers = append(s.remoteObservers, f)
+}
+
+// jsonLinesSink writes each broadcast as line of JSON.
+type jsonLinesSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLinesSink returns BroadcastSink which writes each broadcast to w as line of JSON,
+// e.g. to append them to file.
+func NewJSONLinesSink(w io.Writer) BroadcastSink {
+	return &jsonLinesSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonLinesSink) Append(records []BroadcastRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range records {
+		if err := s.enc.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
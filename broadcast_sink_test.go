@@ -0,0 +1,237 @@
+package socketio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestBroadcastSink(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu      sync.Mutex
+		records []BroadcastRecord
+		batches int
+	)
+	sink := BroadcastSinkFunc(func(batch []BroadcastRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		records = append(records, batch...)
+		batches++
+		return nil
+	})
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	should.NoError(server.SetBroadcastSink(sink, &BroadcastSinkOptions{BatchSize: 2, FlushInterval: time.Hour}))
+
+	set := Union("a").Except("b")
+	should.True(server.BroadcastToRoom("/", "lobby", "message", "hello"))
+	should.True(server.BroadcastToNamespace("/", "news", 1))
+	should.True(server.BroadcastToRoomSet("/", set, "alert"))
+	must.NoError(server.WithContext(context.Background()).BroadcastToRoom("/", "lobby", "typing"))
+	should.False(server.BroadcastToRoom("/missing", "lobby", "lost"))
+
+	must.NoError(server.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	must.Len(records, 4, "buffered broadcasts are appended on close")
+	should.Equal(2, batches)
+
+	should.Equal("lobby", records[0].Room)
+	should.Equal("message", records[0].Event)
+	should.Equal([]interface{}{"hello"}, records[0].Args)
+	should.Equal(server.nodeID, records[0].Origin)
+	should.False(records[0].Timestamp.IsZero())
+
+	should.Equal("", records[1].Room)
+	should.Equal("news", records[1].Event)
+
+	should.Equal(set, records[2].RoomSet)
+	should.Equal("typing", records[3].Event)
+	for _, record := range records {
+		should.Equal("/", record.Namespace)
+	}
+}
+
+// TestBroadcastSinkAPIs checks every way to broadcast reaches sink.
+func TestBroadcastSinkAPIs(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	sink := BroadcastSinkFunc(func(batch []BroadcastRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, record := range batch {
+			events = append(events, record.Event)
+		}
+		return nil
+	})
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	must.NoError(server.SetBroadcastSink(sink, &BroadcastSinkOptions{FlushInterval: time.Hour}))
+
+	sub, err := server.EnableSubscriptions("/")
+	must.NoError(err)
+	sub.DefineStream("orders", nil)
+	room, err := SubscriptionRoom("orders", nil)
+	must.NoError(err)
+	server.JoinRoom("/", room, newLockedRecorder("1", "/", nil))
+
+	bridge, err := server.NewGraphQLBridge(map[string]GraphQLTopic{"news": {Namespace: "/", Event: "graphql"}})
+	must.NoError(err)
+
+	broker := &memoryMQTT{subscriptions: make(map[string]func(string, []byte))}
+	_, err = server.NewMQTTBridge(&MQTTBridgeOptions{Client: broker, Namespaces: []string{"/"}})
+	must.NoError(err)
+
+	broadcasts := map[string]func(event string) bool{
+		"BroadcastToRoom": func(event string) bool {
+			return server.BroadcastToRoom("/", "lobby", event)
+		},
+		"EmitToRoom": func(event string) bool {
+			return server.EmitToRoom("/", "lobby", event) == nil
+		},
+		"BroadcastToRoomExcept": func(event string) bool {
+			return server.BroadcastToRoomExcept("/", "lobby", event, []string{"1"})
+		},
+		"BroadcastToRoomTree": func(event string) bool {
+			return server.BroadcastToRoomTree("/", "lobby", event)
+		},
+		"BroadcastToNamespace": func(event string) bool {
+			return server.BroadcastToNamespace("/", event)
+		},
+		"BroadcastToRoomSet": func(event string) bool {
+			return server.BroadcastToRoomSet("/", Union("lobby"), event)
+		},
+		"BroadcastWithOptions": func(event string) bool {
+			return server.BroadcastWithOptions("/", Union("lobby"), nil, event)
+		},
+		"BroadcastToRoomWithAck": func(event string) bool {
+			_, err := server.BroadcastToRoomWithAck("/", "lobby", event, nil, time.Millisecond)
+			return err == nil
+		},
+		"ContextBroadcaster.BroadcastToRoom": func(event string) bool {
+			return server.WithContext(context.Background()).BroadcastToRoom("/", "lobby", event) == nil
+		},
+		"ContextBroadcaster.BroadcastToNamespace": func(event string) bool {
+			return server.WithContext(context.Background()).BroadcastToNamespace("/", event) == nil
+		},
+		"ContextBroadcaster.BroadcastToRoomSet": func(event string) bool {
+			return server.WithContext(context.Background()).BroadcastToRoomSet("/", Union("lobby"), event) == nil
+		},
+		"Subscriptions.Publish": func(string) bool {
+			return sub.Publish("orders", 1) == 1
+		},
+		"GraphQLBridge.Publish": func(string) bool {
+			return bridge.Publish("news", 1) == nil
+		},
+		"MQTTBridge": func(event string) bool {
+			msg, err := json.Marshal(MQTTMessage{Event: event})
+			return err == nil && broker.Publish("socket.io/root/lobby", 0, msg) == nil
+		},
+	}
+
+	want := make([]string, 0, len(broadcasts))
+	for name, broadcast := range broadcasts {
+		event := name
+		switch name {
+		case "Subscriptions.Publish":
+			event = "orders"
+		case "GraphQLBridge.Publish":
+			event = "graphql"
+		}
+
+		should.True(broadcast(event), name)
+		want = append(want, event)
+	}
+
+	must.NoError(server.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	should.ElementsMatch(want, events)
+}
+
+func TestBroadcastSinkDrop(t *testing.T) {
+	should := assert.New(t)
+
+	appending := make(chan struct{})
+	release := make(chan struct{})
+	sink := BroadcastSinkFunc(func([]BroadcastRecord) error {
+		appending <- struct{}{}
+		<-release
+		return nil
+	})
+
+	dropped := make(chan string, 1)
+	bs := newBroadcastSinker(sink, &BroadcastSinkOptions{
+		BufferSize: 1,
+		BatchSize:  1,
+		OnDrop: func(record BroadcastRecord) {
+			dropped <- record.Event
+		},
+	})
+
+	bs.record(BroadcastRecord{Event: "appending"})
+	<-appending
+	bs.record(BroadcastRecord{Event: "buffered"})
+	bs.record(BroadcastRecord{Event: "dropped"})
+
+	should.Equal("dropped", <-dropped)
+
+	close(release)
+	go func() {
+		for range appending {
+		}
+	}()
+	bs.stop()
+	close(appending)
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+
+	must.NoError(sink.Append([]BroadcastRecord{
+		{Namespace: "/", Room: "lobby", Event: "message", Args: []interface{}{"hello"}, Origin: "node"},
+		{Namespace: "/", Event: "news"},
+	}))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	must.Len(lines, 2)
+
+	var record map[string]interface{}
+	must.NoError(json.Unmarshal(lines[0], &record))
+	should.Equal("lobby", record["room"])
+	should.Equal("message", record["event"])
+	should.Equal([]interface{}{"hello"}, record["args"])
+	should.Equal("node", record["origin"])
+}
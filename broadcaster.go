@@ -0,0 +1,149 @@
+package socketio
+
+import "fmt"
+
+// BroadcastFactory gives broadcaster of namespace, root namespace is given as "/".
+type BroadcastFactory func(namespace string) Broadcast
+
+// NewBroadcast gives broadcaster which delivers broadcasts only to connections of this node,
+// e.g. for namespace which doesn't need adapter, see Server.SetBroadcaster.
+func NewBroadcast() Broadcast {
+	return newBroadcast()
+}
+
+// NewRedisBroadcast gives broadcaster of namespace which delivers broadcasts to other nodes
+// through redis, see Server.SetBroadcaster.
+func NewRedisBroadcast(namespace string, opts *RedisAdapterOptions) (Broadcast, error) {
+	if namespace == aliasRootNamespace {
+		namespace = rootNamespace
+	}
+
+	return newRedisBroadcast(namespace, getOptions(opts))
+}
+
+// SetBroadcaster sets broadcaster of namespace, instead of the one given by adapter of server,
+// e.g. NewBroadcast for namespace whose broadcasts stay on this node, or broadcaster of another
+// message bus. Hooks like OnRoomEmpty apply to broadcaster set at the time they're called, so
+// it must be called before them.
+func (s *Server) SetBroadcaster(namespace string, b Broadcast) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.setBroadcast(b)
+	h.pinned = true
+	s.monitorAdapter(b)
+
+	if cluster := s.getCluster(); cluster != nil {
+		if rbc, ok := b.(*redisBroadcast); ok {
+			rbc.watchCluster(cluster)
+		}
+	}
+
+	return nil
+}
+
+// SetAdapter sets factory of broadcasters of namespaces created afterwards, see RebindAdapter,
+// e.g. to plug adapter of another message bus or fake of tests. Like the other adapters, it's used
+// instead of adapter set before, nil broadcaster of factory falls back to local broadcaster.
+func (s *Server) SetAdapter(factory BroadcastFactory) error {
+	s.adapterLock.Lock()
+	defer s.adapterLock.Unlock()
+
+	s.adapter = factory
+	s.broadcasts = func(nsp string) (Broadcast, error) {
+		name := nsp
+		if name == rootNamespace {
+			name = aliasRootNamespace
+		}
+
+		if b := factory(name); b != nil {
+			return b, nil
+		}
+
+		return newBroadcast(), nil
+	}
+
+	return nil
+}
+
+// RebindAdapter replaces broadcasters of existing namespaces with broadcasters of current adapter,
+// since Adapter, KafkaAdapter and the other adapters apply only to namespaces created afterwards,
+// e.g. once adapter is configured after handlers are registered, or adapter is swapped while
+// server is serving. Adapter set last gives broadcasters, replaced adapters keep running until
+// server is closed, so broadcasts of other nodes reach namespaces which aren't rebound yet.
+// Members of rooms on this node and hooks of rooms, like OnRoomEmpty and RoomTicker, are moved to
+// new broadcasters, joins and leaves wait until they're moved. Old broadcasters are closed then.
+// Broadcasters set by SetBroadcaster are kept.
+func (s *Server) RebindAdapter() error {
+	s.rebindLock.Lock()
+	defer s.rebindLock.Unlock()
+
+	for nsp, h := range s.handlers.All() {
+		if h.pinned {
+			continue
+		}
+
+		b, err := s.newNamespaceBroadcast(nsp)
+		if err != nil {
+			return fmt.Errorf("rebind namespace %q: %w", nsp, err)
+		}
+
+		h.rebind(b)
+	}
+
+	return nil
+}
+
+// newNamespaceBroadcast gives broadcaster of namespace by adapter of server.
+func (s *Server) newNamespaceBroadcast(nsp string) (Broadcast, error) {
+	s.adapterLock.RLock()
+	broadcasts := s.broadcasts
+	s.adapterLock.RUnlock()
+
+	if broadcasts == nil {
+		return newBroadcast(), nil
+	}
+
+	return broadcasts(nsp)
+}
+
+// getRedisAdapter gives options of redis adapter, nil when it isn't set.
+func (s *Server) getRedisAdapter() *RedisAdapterOptions {
+	s.adapterLock.RLock()
+	defer s.adapterLock.RUnlock()
+
+	return s.redisAdapter
+}
+
+// getCluster gives cluster nodes of redis adapter, nil when it isn't set.
+func (s *Server) getCluster() *clusterNodes {
+	s.adapterLock.RLock()
+	defer s.adapterLock.RUnlock()
+
+	return s.cluster
+}
+
+// getMesh gives peer mesh adapter, nil when it isn't set.
+func (s *Server) getMesh() *peerMesh {
+	s.adapterLock.RLock()
+	defer s.adapterLock.RUnlock()
+
+	return s.mesh
+}
+
+// setBus makes bus adapter give broadcasters of namespaces, see RebindAdapter.
+func (s *Server) setBus(bus *busAdapter) {
+	s.adapterLock.Lock()
+	defer s.adapterLock.Unlock()
+
+	s.bus = bus
+	s.broadcasts = func(nsp string) (Broadcast, error) {
+		return bus.newBroadcast(nsp), nil
+	}
+}
@@ -0,0 +1,228 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// sendRecorder is local broadcast which records rooms of sent events.
+type sendRecorder struct {
+	*broadcast
+	rooms []string
+}
+
+func (bc *sendRecorder) Send(room, event string, args ...interface{}) {
+	bc.rooms = append(bc.rooms, room)
+	bc.broadcast.Send(room, event, args...)
+}
+
+func TestServerSetBroadcaster(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+
+	chat := &sendRecorder{broadcast: newBroadcast()}
+	should.NoError(server.SetBroadcaster("/chat", chat))
+	should.NoError(server.SetBroadcaster("/metrics", NewBroadcast()))
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/chat", nil)}
+	should.True(server.JoinRoom("/chat", "lobby", recorder))
+
+	should.True(server.BroadcastToRoom("/chat", "lobby", "message"))
+	should.True(server.BroadcastToRoom("/metrics", "lobby", "tick"))
+
+	should.Equal([]string{"lobby"}, chat.rooms)
+	should.Equal([]string{"message"}, recorder.events)
+	should.Equal(1, server.RoomLen("/chat", "lobby"))
+	should.Equal(0, server.RoomLen("/metrics", "lobby"))
+}
+
+func TestServerSetAdapter(t *testing.T) {
+	should := assert.New(t)
+
+	var namespaces []string
+	chat := &sendRecorder{broadcast: newBroadcast()}
+
+	server := NewServer(&engineio.Options{})
+	should.NoError(server.SetAdapter(func(namespace string) Broadcast {
+		namespaces = append(namespaces, namespace)
+		if namespace == "/chat" {
+			return chat
+		}
+		return nil
+	}))
+
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+
+	should.Equal([]string{"/", "/chat"}, namespaces)
+	should.True(server.BroadcastToRoom("/chat", "lobby", "message"))
+	should.Equal([]string{"lobby"}, chat.rooms)
+	should.IsType(&broadcast{}, server.getNamespace("/").broadcast)
+}
+
+func TestServerRebindAdapter(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	should.NoError(server.SetBroadcaster("/metrics", NewBroadcast()))
+
+	old := server.getNamespace("/chat").getBroadcast()
+	metrics := server.getNamespace("/metrics").getBroadcast()
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/chat", nil)}
+	server.JoinRoom("/chat", "lobby", recorder)
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "2"}}, "/chat", old)
+	nc.Join("news")
+
+	chat := &sendRecorder{broadcast: newBroadcast()}
+	should.NoError(server.SetAdapter(func(namespace string) Broadcast {
+		return chat
+	}))
+	should.NoError(server.RebindAdapter())
+
+	should.Equal(metrics, server.getNamespace("/metrics").getBroadcast(), "broadcaster set by SetBroadcaster is kept")
+	should.Equal(chat, server.getNamespace("/chat").getBroadcast())
+	should.Equal(0, old.Len("lobby"))
+	should.Equal(0, old.Len("news"))
+	should.Equal(1, server.RoomLen("/chat", "lobby"))
+	should.Equal(1, server.RoomLen("/chat", "news"))
+
+	nc.Join("private")
+	should.Equal(1, chat.Len("private"))
+	should.Equal(0, old.Len("private"))
+
+	should.True(server.BroadcastToRoom("/chat", "lobby", "message"))
+	should.Equal([]string{"lobby"}, chat.rooms)
+	should.Equal([]string{"message"}, recorder.events)
+}
+
+// closeRecorder is local broadcast which records whether it's closed.
+type closeRecorder struct {
+	*broadcast
+	closed bool
+}
+
+func (bc *closeRecorder) Close() error {
+	bc.closed = true
+	return nil
+}
+
+func TestServerRebindAdapterHooks(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+
+	old := &closeRecorder{broadcast: newBroadcast()}
+	should.NoError(server.SetAdapter(func(namespace string) Broadcast {
+		return old
+	}))
+
+	var emptied []string
+	should.NoError(server.OnRoomEmpty("/game", func(namespace, room, lastConnID string) {
+		emptied = append(emptied, room)
+	}))
+
+	ticks := make(chan string, 64)
+	ticker := server.RoomTicker("/game", "table:2", 10*time.Millisecond, func(namespace, room string) {
+		ticks <- room
+	})
+	defer ticker.Stop()
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "a"}}, "/game", old)
+	nc.Join("table:1")
+
+	rebound := newBroadcast()
+	should.NoError(server.SetAdapter(func(namespace string) Broadcast {
+		return rebound
+	}))
+	should.NoError(server.RebindAdapter())
+
+	should.True(old.closed, "old broadcast is closed")
+	should.Empty(emptied, "moved room isn't reported empty")
+	should.Equal(1, rebound.Len("table:1"))
+
+	nc.Leave("table:1")
+	should.Equal([]string{"table:1"}, emptied, "hook of room is moved")
+
+	nc.Join("table:2")
+	select {
+	case room := <-ticks:
+		should.Equal("table:2", room)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticker isn't woken by join of new broadcast")
+	}
+}
+
+func TestServerRebindAdapterServing(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+
+	started := make(chan struct{})
+	server.OnServerStart(func() error {
+		close(started)
+		return nil
+	})
+	go func() {
+		_ = server.Serve()
+	}()
+	<-started
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/chat", nil)}
+	server.JoinRoom("/chat", "lobby", recorder)
+
+	// adapter is swapped twice while serving, rooms are joined meanwhile
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for _, id := range []string{"a", "b", "c"} {
+			server.JoinRoom("/chat", "news", &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/chat", nil)})
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		swapped := &sendRecorder{broadcast: newBroadcast()}
+		should.NoError(server.SetAdapter(func(string) Broadcast {
+			return swapped
+		}))
+		should.NoError(server.RebindAdapter())
+		should.Equal(swapped, server.getNamespace("/chat").getBroadcast())
+	}
+	<-done
+
+	chat := server.getNamespace("/chat").getBroadcast().(*sendRecorder)
+	should.Equal(1, server.RoomLen("/chat", "lobby"))
+	should.Equal(3, server.RoomLen("/chat", "news"), "joins made while adapter is swapped are kept")
+
+	chat.rooms = nil
+	should.True(server.BroadcastToRoom("/chat", "lobby", "message"))
+	should.Equal([]string{"lobby"}, chat.rooms, "broadcast goes through new adapter")
+	should.Equal("message", recorder.events[len(recorder.events)-1])
+
+	// namespace created afterwards uses new adapter as well
+	server.OnConnect("/game", func(Conn) error {
+		return nil
+	})
+	should.Equal(chat, server.getNamespace("/game").getBroadcast())
+}
@@ -0,0 +1,268 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// bus record types
+const (
+	busRecordSend  = "send"
+	busRecordClear = "clear"
+	busRecordJoin  = "join"
+)
+
+// busRecord is a broadcast, clear of room or join of connection by sid published to other nodes through message bus, like
+// kafka topic or mongodb collection.
+type busRecord struct {
+	Type      string        `json:"type"`
+	Node      string        `json:"node"`
+	Namespace string        `json:"namespace"`
+	Room      string        `json:"room,omitempty"`
+	RoomSet   *RoomSet      `json:"roomSet,omitempty"`
+	Event     string        `json:"event,omitempty"`
+	Args      []interface{} `json:"args,omitempty"`
+	Deadline  int64         `json:"deadline,omitempty"`
+	// SID is id of connection which joins room of join record.
+	SID string `json:"sid,omitempty"`
+}
+
+// busAdapter publishes broadcasts of namespaces to message bus and applies broadcasts of other
+// nodes. Rooms are tracked locally by each node.
+type busAdapter struct {
+	node string
+
+	// write writes encoded record to message bus.
+	write func(record *busRecord, value []byte) error
+
+	broadcasts map[string]*busBroadcast
+	lock       sync.RWMutex
+}
+
+func newBusAdapter(node string, write func(record *busRecord, value []byte) error) *busAdapter {
+	return &busAdapter{
+		node:       node,
+		write:      write,
+		broadcasts: make(map[string]*busBroadcast),
+	}
+}
+
+func (a *busAdapter) newBroadcast(nsp string) *busBroadcast {
+	bc := &busBroadcast{
+		broadcast: newBroadcast(),
+		adapter:   a,
+		nsp:       nsp,
+	}
+
+	a.lock.Lock()
+	a.broadcasts[nsp] = bc
+	a.lock.Unlock()
+
+	return bc
+}
+
+// handle applies encoded record read from message bus.
+func (a *busAdapter) handle(value []byte) {
+	var record busRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		logger.Error("bus record:", err)
+		return
+	}
+
+	if record.Node == a.node {
+		return
+	}
+
+	a.lock.RLock()
+	bc, ok := a.broadcasts[record.Namespace]
+	a.lock.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	bc.apply(&record)
+}
+
+// consume runs subscribe, which reads message bus, until ctx is done. Subscription which fails
+// or ends is restarted with backoff, so lost connection of bus doesn't stop delivery for good.
+func (a *busAdapter) consume(ctx context.Context, name string, subscribe func(ctx context.Context) error) {
+	backoff := redisResubscribeBackoff
+	for {
+		start := time.Now()
+		err := subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("subscription ended")
+		}
+
+		logger.Error(name+":", err)
+
+		// subscription which fails right away is restarted with backoff as well
+		if time.Since(start) > redisMaxResubscribeBackoff {
+			backoff = redisResubscribeBackoff
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, redisMaxResubscribeBackoff)
+	}
+}
+
+func (a *busAdapter) publish(record *busRecord) error {
+	record.Node = a.node
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return a.write(record, value)
+}
+
+// busBroadcast is broadcast of namespace which delivers broadcasts to other nodes through
+// message bus.
+type busBroadcast struct {
+	*broadcast
+
+	adapter *busAdapter
+	nsp     string
+
+	// onRemote is called with broadcasts of other nodes once they're delivered, it's set by
+	// namespace.
+	onRemote func(record BroadcastRecord)
+}
+
+// Clear removes all the connections from the room on every node.
+func (bc *busBroadcast) Clear(room string) {
+	bc.broadcast.Clear(room)
+	bc.publish(&busRecord{Type: busRecordClear, Room: room})
+}
+
+// Send sends given event & args to all the connections in the room on every node.
+func (bc *busBroadcast) Send(room, event string, args ...interface{}) {
+	bc.broadcast.Send(room, event, args...)
+	bc.publish(&busRecord{Type: busRecordSend, Room: room, Event: event, Args: args})
+}
+
+// SendAll sends given event & args to all the connections of namespace on every node.
+func (bc *busBroadcast) SendAll(event string, args ...interface{}) {
+	bc.broadcast.SendAll(event, args...)
+	bc.publish(&busRecord{Type: busRecordSend, Event: event, Args: args})
+}
+
+// SendRoomSet sends given event & args to all the connections selected by the room set on every node.
+func (bc *busBroadcast) SendRoomSet(set *RoomSet, event string, args ...interface{}) {
+	bc.broadcast.SendRoomSet(set, event, args...)
+	bc.publishRoomSetMessage(set, event, args...)
+}
+
+// broadcastWithAck gives ErrBroadcastAckUnsupported, see ackSupport.
+func (bc *busBroadcast) broadcastWithAck(string, string, []interface{}, time.Duration) ([]BroadcastReply, error) {
+	return nil, bc.ackSupport()
+}
+
+// ackSupport gives ErrBroadcastAckUnsupported, nodes of message bus aren't known, so it can't tell
+// when acks of every node are collected.
+func (bc *busBroadcast) ackSupport() error {
+	return fmt.Errorf("%w: nodes of message bus adapter aren't counted", ErrBroadcastAckUnsupported)
+}
+
+func (bc *busBroadcast) publishRoomSetMessage(set *RoomSet, event string, args ...interface{}) {
+	bc.publish(&busRecord{Type: busRecordSend, RoomSet: set, Event: event, Args: args})
+}
+
+func (bc *busBroadcast) publishBroadcast(room string, set *RoomSet, deadline time.Time, event string, args ...interface{}) error {
+	record := &busRecord{Type: busRecordSend, Room: room, RoomSet: set, Event: event, Args: args}
+	if !deadline.IsZero() {
+		record.Deadline = deadline.UnixMilli()
+	}
+	record.Namespace = bc.nsp
+
+	return bc.adapter.publish(record)
+}
+
+func (bc *busBroadcast) publish(record *busRecord) {
+	record.Namespace = bc.nsp
+
+	if err := bc.adapter.publish(record); err != nil {
+		logger.Error("bus publish:", err)
+	}
+}
+
+// apply delivers record of other node to connections of this node.
+func (bc *busBroadcast) apply(record *busRecord) {
+	switch record.Type {
+	case busRecordClear:
+		bc.broadcast.Clear(record.Room)
+		return
+	case busRecordJoin:
+		if connection := connBySID(bc.broadcast, record.SID); connection != nil {
+			connection.Join(record.Room)
+		}
+		return
+	}
+
+	var opts *BroadcastOptions
+	if record.Deadline != 0 {
+		ctx, cancel := context.WithDeadline(context.Background(), time.UnixMilli(record.Deadline))
+		defer cancel()
+
+		opts = &BroadcastOptions{ctx: ctx}
+	}
+
+	emit := func(connection Conn) {
+		broadcastEmit(connection, opts, record.Event, record.Args...)
+	}
+
+	switch {
+	case record.RoomSet != nil:
+		bc.ForEachRoomSet(record.RoomSet, emit)
+	case record.Room != "":
+		bc.ForEach(record.Room, emit)
+	default:
+		bc.lock.RLock()
+		for _, connections := range bc.rooms {
+			for _, connection := range connections {
+				emit(connection)
+			}
+		}
+		bc.lock.RUnlock()
+	}
+
+	bc.lock.RLock()
+	onRemote := bc.onRemote
+	bc.lock.RUnlock()
+
+	if onRemote != nil {
+		onRemote(BroadcastRecord{
+			Namespace: bc.nsp,
+			Room:      record.Room,
+			RoomSet:   record.RoomSet,
+			Event:     record.Event,
+			Args:      record.Args,
+			Timestamp: time.Now(),
+			Origin:    record.Node,
+		})
+	}
+}
+
+func (bc *busBroadcast) setOnRemoteBroadcast(f func(record BroadcastRecord)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onRemote = f
+}
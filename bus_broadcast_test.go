@@ -0,0 +1,122 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// lockedRecorder records events emitted to connection by goroutines of adapters.
+type lockedRecorder struct {
+	*namespaceConn
+	mu     sync.Mutex
+	events []string
+}
+
+func newLockedRecorder(id, namespace string, broadcast Broadcast) *lockedRecorder {
+	return &lockedRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, namespace, broadcast)}
+}
+
+func (r *lockedRecorder) Emit(event string, _ ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func (r *lockedRecorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.events...)
+}
+
+// newAdapterServers gives two servers of cluster, adapter of i-th server is set by setAdapter.
+// Each server has connection "1" in room "lobby" of namespace, servers are returned once ready
+// reports adapters are subscribed, and they're closed by cleanup of test.
+func newAdapterServers(t *testing.T, namespace string, setAdapter func(i int, server *Server) error, ready func() bool) ([]*Server, []*lockedRecorder) {
+	must := require.New(t)
+
+	servers := make([]*Server, 2)
+	recorders := make([]*lockedRecorder, 2)
+	for i := range servers {
+		server := NewServer(&engineio.Options{})
+		must.NoError(setAdapter(i, server))
+		t.Cleanup(func() {
+			_ = server.Close()
+		})
+
+		server.OnConnect(namespace, func(Conn) error {
+			return nil
+		})
+
+		recorders[i] = newLockedRecorder("1", namespace, nil)
+		server.JoinRoom(namespace, "lobby", recorders[i])
+		servers[i] = server
+	}
+
+	must.Eventually(ready, time.Second, 10*time.Millisecond)
+
+	return servers, recorders
+}
+
+// assertAdapterOptions checks every adapter set by setAdapters is refused by server.
+func assertAdapterOptions(t *testing.T, setAdapters ...func(server *Server) error) {
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	for i, setAdapter := range setAdapters {
+		assert.Error(t, setAdapter(server), "options %d", i)
+	}
+}
+
+func TestBusAdapterConsume(t *testing.T) {
+	should := assert.New(t)
+
+	adapter := newBusAdapter("a", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// subscription which fails or ends is restarted until ctx is done
+	calls := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		adapter.consume(ctx, "test", func(ctx context.Context) error {
+			calls++
+			switch calls {
+			case 1:
+				return errors.New("connection lost")
+			case 2:
+				return nil
+			}
+
+			cancel()
+			<-ctx.Done()
+
+			return ctx.Err()
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consume isn't done")
+	}
+	should.Equal(3, calls)
+
+	// backoff is cut by ctx
+	adapter.consume(ctx, "test", func(context.Context) error {
+		calls++
+
+		return errors.New("connection lost")
+	})
+	should.Equal(4, calls)
+}
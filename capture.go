@@ -0,0 +1,149 @@
+package socketio
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+const defaultCaptureMaxSize = 4 << 10
+
+// CaptureDirection tells whether captured event was received or emitted by server.
+type CaptureDirection string
+
+// capture directions
+const (
+	CaptureInbound  CaptureDirection = "in"
+	CaptureOutbound CaptureDirection = "out"
+)
+
+// CapturedPayload is payload of an event sampled by PayloadCapture.
+type CapturedPayload struct {
+	Direction CaptureDirection
+	Namespace string
+	Event     string
+	SID       string
+	Time      time.Time
+
+	// Payload is JSON of (redacted) event arguments, cut to PayloadCapture.MaxSize.
+	Payload   []byte
+	Truncated bool
+}
+
+// CaptureSink receives captured payloads, it's called from connection goroutines,
+// so it shouldn't block.
+type CaptureSink interface {
+	Capture(payload CapturedPayload)
+}
+
+// PayloadCapture configures sampling of event payloads for debugging, see Server.CapturePayloads.
+type PayloadCapture struct {
+	Sink CaptureSink
+
+	// SampleRate is fraction of events captured, e.g. 0.001 captures 0.1% of events.
+	SampleRate float64
+
+	// Events limits capture to the given events, all events are sampled when it's empty.
+	Events []string
+
+	// MaxSize caps size of captured payload in bytes, default is 4KB.
+	MaxSize int
+
+	// Redact returns arguments to capture instead of args, e.g. without passwords or tokens.
+	// args must not be modified, they are arguments of the event itself.
+	Redact func(namespace, event string, args []interface{}) []interface{}
+}
+
+func (o *PayloadCapture) getMaxSize() int {
+	if o == nil || o.MaxSize <= 0 {
+		return defaultCaptureMaxSize
+	}
+
+	return o.MaxSize
+}
+
+// CapturePayloads samples inbound and outbound event payloads into opts.Sink,
+// nil opts disables capture.
+func (s *Server) CapturePayloads(opts *PayloadCapture) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	if opts == nil || opts.Sink == nil {
+		s.capture = nil
+		return nil
+	}
+
+	s.capture = newPayloadCapture(opts)
+
+	return nil
+}
+
+type payloadCapture struct {
+	sink       CaptureSink
+	sampleRate float64
+	events     map[string]struct{}
+	maxSize    int
+	redact     func(namespace, event string, args []interface{}) []interface{}
+}
+
+func newPayloadCapture(opts *PayloadCapture) *payloadCapture {
+	var events map[string]struct{}
+	if len(opts.Events) > 0 {
+		events = make(map[string]struct{}, len(opts.Events))
+		for _, event := range opts.Events {
+			events[event] = struct{}{}
+		}
+	}
+
+	return &payloadCapture{
+		sink:       opts.Sink,
+		sampleRate: opts.SampleRate,
+		events:     events,
+		maxSize:    opts.getMaxSize(),
+		redact:     opts.Redact,
+	}
+}
+
+func (pc *payloadCapture) sampled(event string) bool {
+	if pc == nil || pc.sampleRate <= 0 {
+		return false
+	}
+
+	if pc.events != nil {
+		if _, ok := pc.events[event]; !ok {
+			return false
+		}
+	}
+
+	return pc.sampleRate >= 1 || rand.Float64() < pc.sampleRate
+}
+
+// capture sends payload of event of namespace connection to sink, event should be sampled
+// first, so arguments aren't converted for events which aren't captured.
+func (pc *payloadCapture) capture(direction CaptureDirection, nc *namespaceConn, event string, args []interface{}) {
+	if pc.redact != nil {
+		args = pc.redact(nc.Namespace(), event, args)
+	}
+
+	payload, err := json.Marshal(args)
+	if err != nil {
+		payload, _ = json.Marshal(err.Error())
+	}
+
+	var truncated bool
+	if len(payload) > pc.maxSize {
+		payload = payload[:pc.maxSize]
+		truncated = true
+	}
+
+	pc.sink.Capture(CapturedPayload{
+		Direction: direction,
+		Namespace: nc.Namespace(),
+		Event:     event,
+		SID:       nc.ID(),
+		Time:      time.Now(),
+		Payload:   payload,
+		Truncated: truncated,
+	})
+}
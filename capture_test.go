@@ -0,0 +1,110 @@
+package socketio
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+type captureSinkFunc func(CapturedPayload)
+
+func (f captureSinkFunc) Capture(payload CapturedPayload) {
+	f(payload)
+}
+
+func TestServerCapturePayloads(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var captured []CapturedPayload
+	var capturedLock sync.Mutex
+
+	_, url := newConformanceServer(t, func(server *Server) {
+		must.NoError(server.CapturePayloads(&PayloadCapture{
+			Sink: captureSinkFunc(func(payload CapturedPayload) {
+				capturedLock.Lock()
+				captured = append(captured, payload)
+				capturedLock.Unlock()
+			}),
+			SampleRate: 1,
+			Events:     []string{"ping", "pong"},
+			MaxSize:    12,
+			Redact: func(namespace, event string, args []interface{}) []interface{} {
+				if event == "ping" {
+					return []interface{}{"redacted"}
+				}
+				return args
+			},
+		}))
+	})
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	pong := make(chan string, 1)
+	client.OnEvent("pong", func(_ Conn, msg string) {
+		pong <- msg
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	client.Emit("echo", "not captured")
+	client.Emit("ping", "secret")
+
+	select {
+	case <-pong:
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing pong")
+	}
+
+	capturedLock.Lock()
+	defer capturedLock.Unlock()
+
+	must.Len(captured, 2)
+
+	should.Equal(CaptureInbound, captured[0].Direction)
+	should.Equal("ping", captured[0].Event)
+	should.Equal("/", captured[0].Namespace)
+	should.Equal(`["redacted"]`, string(captured[0].Payload))
+	should.False(captured[0].Truncated)
+
+	should.Equal(CaptureOutbound, captured[1].Direction)
+	should.Equal("pong", captured[1].Event)
+	should.Equal(`["from /"]`, string(captured[1].Payload))
+	should.Equal(captured[0].SID, captured[1].SID)
+}
+
+func TestPayloadCaptureTruncate(t *testing.T) {
+	should := assert.New(t)
+
+	var got CapturedPayload
+	pc := newPayloadCapture(&PayloadCapture{
+		Sink: captureSinkFunc(func(payload CapturedPayload) {
+			got = payload
+		}),
+		SampleRate: 1,
+		MaxSize:    4,
+	})
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "sid1"}}, "/chat", nil)
+
+	should.True(pc.sampled("any"))
+	pc.capture(CaptureOutbound, nc, "any", []interface{}{"long message"})
+
+	should.Equal(`["lo`, string(got.Payload))
+	should.True(got.Truncated)
+	should.Equal("sid1", got.SID)
+
+	var disabled *payloadCapture
+	should.False(disabled.sampled("any"))
+	should.False(newPayloadCapture(&PayloadCapture{Sink: pc.sink}).sampled("any"))
+}
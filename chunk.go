@@ -0,0 +1,262 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ChunkEvent is event which carries a chunk of large payload, see ChunkOptions.
+const ChunkEvent = "$chunk"
+
+const (
+	defaultChunkThreshold = 64 << 10
+	defaultChunkMaxSize   = 16 << 20
+)
+
+// ErrChunkTooLarge is reported when reassembled payload exceeds ChunkOptions.MaxSize.
+var ErrChunkTooLarge = errors.New("chunked payload too large")
+
+// ChunkOptions configures chunking of large payloads, so they can traverse proxies with small
+// frame limits. Events with JSON arguments larger than Threshold are sent as numbered
+// ChunkEvent events, which are reassembled into the original event by the peer, so chunking
+// must be enabled on both sides, see Server.EnableChunking and ClientOptions.Chunking.
+// Arguments with binary data are never chunked.
+type ChunkOptions struct {
+	// Threshold is size of JSON arguments in bytes above which event is chunked, default is 64KB.
+	Threshold int
+
+	// ChunkSize is size of single chunk in bytes, default is Threshold.
+	ChunkSize int
+
+	// MaxSize caps size of reassembled payload in bytes, default is 16MB.
+	MaxSize int
+}
+
+func (o *ChunkOptions) getThreshold() int {
+	if o == nil || o.Threshold <= 0 {
+		return defaultChunkThreshold
+	}
+
+	return o.Threshold
+}
+
+func (o *ChunkOptions) getChunkSize() int {
+	if o == nil || o.ChunkSize <= 0 {
+		return o.getThreshold()
+	}
+
+	return o.ChunkSize
+}
+
+func (o *ChunkOptions) getMaxSize() int {
+	if o == nil || o.MaxSize <= 0 {
+		return defaultChunkMaxSize
+	}
+
+	return o.MaxSize
+}
+
+// EnableChunking enables chunking of large payloads of events emitted and received by server,
+// nil opts disables it.
+func (s *Server) EnableChunking(opts *ChunkOptions) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.chunking = opts
+
+	return nil
+}
+
+// chunker splits outgoing payloads and reassembles incoming ones of a connection.
+type chunker struct {
+	threshold int
+	chunkSize int
+	maxSize   int
+
+	lastID  uint64
+	pending map[string]*chunkedPayload
+	mu      sync.Mutex
+}
+
+type chunkedPayload struct {
+	event string
+	total int
+	next  int
+	data  []byte
+}
+
+func newChunker(opts *ChunkOptions) *chunker {
+	if opts == nil {
+		return nil
+	}
+
+	return &chunker{
+		threshold: opts.getThreshold(),
+		chunkSize: opts.getChunkSize(),
+		maxSize:   opts.getMaxSize(),
+		pending:   make(map[string]*chunkedPayload),
+	}
+}
+
+// split gives arguments of ChunkEvent events which carry args of event, or nil when
+// args don't need chunking.
+func (ch *chunker) split(event string, args []interface{}) [][]interface{} {
+	if ch == nil || hasBinary(args) {
+		return nil
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil || len(data) <= ch.threshold {
+		return nil
+	}
+
+	id := strconv.FormatUint(atomic.AddUint64(&ch.lastID, 1), 36)
+
+	var parts []string
+	for start := 0; start < len(data); {
+		end := start + ch.chunkSize
+		if end >= len(data) {
+			end = len(data)
+		} else {
+			// chunks are JSON strings, so runes mustn't be split between them
+			for end > start+1 && !utf8.RuneStart(data[end]) {
+				end--
+			}
+		}
+
+		parts = append(parts, string(data[start:end]))
+		start = end
+	}
+
+	chunks := make([][]interface{}, len(parts))
+	for i, part := range parts {
+		chunks[i] = []interface{}{id, i, len(parts), event, part}
+	}
+
+	return chunks
+}
+
+// add appends chunk to payload of namespace, it gives event and its JSON arguments
+// once the last chunk is added.
+func (ch *chunker) add(namespace, id string, index, total int, event, data string) (string, []byte, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	key := namespace + "\n" + id
+
+	p, ok := ch.pending[key]
+	if !ok {
+		if index != 0 {
+			return "", nil, fmt.Errorf("chunk %d of payload %q without first chunk", index, id)
+		}
+
+		p = &chunkedPayload{event: event, total: total}
+		ch.pending[key] = p
+	}
+
+	if index != p.next || total != p.total || event != p.event {
+		delete(ch.pending, key)
+		return "", nil, fmt.Errorf("unexpected chunk %d/%d of payload %q", index, total, id)
+	}
+
+	if len(p.data)+len(data) > ch.maxSize {
+		delete(ch.pending, key)
+		return "", nil, ErrChunkTooLarge
+	}
+
+	p.data = append(p.data, data...)
+	p.next++
+
+	if p.next < p.total {
+		return "", nil, nil
+	}
+
+	delete(ch.pending, key)
+
+	return p.event, p.data, nil
+}
+
+func hasBinary(args []interface{}) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case parser.Buffer, *parser.Buffer, []byte:
+			return true
+		}
+	}
+
+	return false
+}
+
+var chunkTypes = []reflect.Type{
+	reflect.TypeOf(""),
+	reflect.TypeOf(0),
+	reflect.TypeOf(0),
+	reflect.TypeOf(""),
+	reflect.TypeOf(""),
+}
+
+// chunkPacketHandler reassembles ChunkEvent packets, it handles the original event once its
+// last chunk arrives.
+func chunkPacketHandler(c *conn, conn *namespaceConn, handler *namespaceHandler, header parser.Header) error {
+	args, err := c.decoder.DecodeArgs(chunkTypes)
+	if err != nil {
+		c.onError(header.Namespace, err)
+		return errDecodeArgs
+	}
+
+	event, data, err := c.chunking.add(header.Namespace,
+		args[0].String(), int(args[1].Int()), int(args[2].Int()), args[3].String(), args[4].String())
+	if err != nil {
+		c.onError(header.Namespace, err)
+		conn.Logger().Info("Error reassembling chunked payload", "err", err.Error())
+		return nil
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	values, err := decodeJSONArgs(data, handler.eventTypes(conn, event))
+	if err != nil {
+		c.onError(header.Namespace, err)
+		conn.Logger().Info("Error decoding the message type", "event", event, "err", err.Error())
+		return errDecodeArgs
+	}
+
+	return handleEventPacket(c, conn, handler, event, header, values, len(data))
+}
+
+// decodeJSONArgs decodes JSON array of arguments into values of types, like Decoder.DecodeArgs.
+func decodeJSONArgs(data []byte, types []reflect.Type) ([]reflect.Value, error) {
+	ret := make([]reflect.Value, len(types))
+	values := make([]interface{}, len(types))
+
+	for i, typ := range types {
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		ret[i] = reflect.New(typ)
+		values[i] = ret[i].Interface()
+	}
+
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	for i, typ := range types {
+		if typ.Kind() != reflect.Ptr {
+			ret[i] = ret[i].Elem()
+		}
+	}
+
+	return ret, nil
+}
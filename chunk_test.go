@@ -0,0 +1,125 @@
+package socketio
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+func TestChunker(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	ch := newChunker(&ChunkOptions{Threshold: 16, ChunkSize: 8})
+
+	should.Nil(ch.split("small", []interface{}{"short"}))
+	should.Nil(ch.split("binary", []interface{}{[]byte(strings.Repeat("x", 64))}))
+
+	chunks := ch.split("doc", []interface{}{strings.Repeat("x", 30)})
+	must.Len(chunks, 5)
+
+	var event string
+	var data []byte
+	for _, chunk := range chunks {
+		var err error
+		event, data, err = ch.add("/", chunk[0].(string), chunk[1].(int), chunk[2].(int), chunk[3].(string), chunk[4].(string))
+		must.NoError(err)
+	}
+	should.Equal("doc", event)
+	should.Equal(`["`+strings.Repeat("x", 30)+`"]`, string(data))
+	should.Empty(ch.pending)
+
+	// runes aren't split between chunks
+	chunks = ch.split("doc", []interface{}{strings.Repeat("é", 20)})
+	for _, chunk := range chunks {
+		should.True(utf8.ValidString(chunk[4].(string)))
+		event, data, _ = ch.add("/", chunk[0].(string), chunk[1].(int), chunk[2].(int), chunk[3].(string), chunk[4].(string))
+	}
+	should.Equal(`["`+strings.Repeat("é", 20)+`"]`, string(data))
+
+	_, _, err := ch.add("/", "a", 1, 2, "doc", "x")
+	should.Error(err, "chunk without first chunk")
+
+	small := newChunker(&ChunkOptions{MaxSize: 4})
+	_, _, err = small.add("/", "a", 0, 2, "doc", "12345")
+	should.ErrorIs(err, ErrChunkTooLarge)
+	should.Empty(small.pending)
+
+	var disabled *chunker
+	should.Nil(disabled.split("doc", []interface{}{strings.Repeat("x", defaultChunkThreshold)}))
+}
+
+func TestServerChunking(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	opts := &ChunkOptions{Threshold: 1024}
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(server.EnableChunking(opts))
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	received := make(chan int, 1)
+	server.OnEvent("/", "upload", func(c Conn, doc string) int {
+		c.Emit("download", doc)
+		received <- len(doc)
+		return len(doc)
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+		Chunking:   opts,
+	})
+	must.NoError(err)
+
+	downloaded := make(chan string, 1)
+	client.OnEvent("download", func(_ Conn, doc string) {
+		downloaded <- doc
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	doc := strings.Repeat("0123456789", 1000)
+	acked := make(chan int, 1)
+	client.Emit("upload", doc, func(n int) {
+		acked <- n
+	})
+
+	for _, ch := range []chan int{received, acked} {
+		select {
+		case n := <-ch:
+			should.Equal(len(doc), n)
+		case <-time.After(5 * time.Second):
+			t.Fatal("chunked upload wasn't handled")
+		}
+	}
+
+	select {
+	case got := <-downloaded:
+		should.Equal(doc, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chunked download wasn't received")
+	}
+}
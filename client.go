@@ -1,15 +1,15 @@
 package socketio
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
 	"path"
-
+	"sync"
+	"time"
 
 	"github.com/thisismz/go-socket.io/engineio"
-	"github.com/thisismz/go-socket.io/engineio/transport"
-	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
 	"github.com/thisismz/go-socket.io/logger"
 	"github.com/thisismz/go-socket.io/parser"
 )
@@ -21,10 +21,40 @@ type Client struct {
 	handlers  *namespaceHandlers
 	url       string
 	opts      *engineio.Options
+	options   *ClientOptions
+
+	sockets map[string]*ClientSocket
+	// pids are resume tokens issued by server for namespaces, see Server.EnableResume.
+	pids map[string]string
+	// epochs count resumes of namespaces, they're presented with resume tokens, see ResumeOptions.RequireEpoch.
+	epochs map[string]uint64
+	mu     sync.RWMutex
+
+	onReconnectAttempt func(attempt int)
+	onReconnect        func(attempt int)
+	onReconnectFailed  func()
+	onPing             func()
+	onEmitBufferTrim   func(namespace, event string)
+	hooksLock          sync.RWMutex
+
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 // NewServer returns a server.
 func NewClient(uri string, opts *engineio.Options) (*Client, error) {
+	client, err := NewClientWithOptions(uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client.opts = opts
+
+	return client, nil
+}
+
+// NewClientWithOptions returns a client configured with options.
+func NewClientWithOptions(uri string, options *ClientOptions) (*Client, error) {
 	// uri like http://asd.com:8080/namesapce
 
 	url, err := url.Parse(uri)
@@ -45,7 +75,11 @@ func NewClient(uri string, opts *engineio.Options) (*Client, error) {
 		namespace: namespace,
 		url:       url.String(),
 		handlers:  newNamespaceHandlers(),
-		opts:      opts,
+		options:   options,
+		sockets:   make(map[string]*ClientSocket),
+		pids:      make(map[string]string),
+		epochs:    make(map[string]uint64),
+		closed:    make(chan struct{}),
 	}
 
 	fmt.Println(client)
@@ -53,98 +87,394 @@ func NewClient(uri string, opts *engineio.Options) (*Client, error) {
 	return client, nil
 }
 
+// Connect connects client and its sockets, lost connection is reconnected
+// when reconnection is enabled in client options.
 func (s *Client) Connect() error {
+	return s.ConnectContext(context.Background())
+}
+
+// ConnectContext connects client like Connect, dialing is canceled when ctx is done.
+func (s *Client) ConnectContext(ctx context.Context) error {
+	c, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	go s.reconnect(c)
+
+	return nil
+}
+
+func (s *Client) connect(ctx context.Context) (*conn, error) {
 	dialer := engineio.Dialer{
-		Transports: []transport.Transport{websocket.Default},
+		Transports: s.options.getTransports(),
+		OnPong:     s.ping,
+		Upgrade:    s.options.getUpgrade(),
 	}
-	enginioCon, err := dialer.Dial(s.url, nil)
+	enginioCon, err := dialer.DialContext(ctx, s.url, s.options.getHeader())
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// root namespace is always connected, sockets of other namespaces are multiplexed over it.
+	if s.getNamespace(rootNamespace) == nil {
+		s.createNamespace(rootNamespace)
 	}
 
 	// Set the engine connection
 	c := newConn(enginioCon, s.handlers)
+	c.setParser(s.options.getParser())
+	c.onNamespaceConnect = s.namespaceConnected
+	c.chunking = newChunker(s.options.getChunking())
+	c.edgeCases = s.options.getEdgeCases()
 
+	s.mu.Lock()
 	s.conn = c
+	s.mu.Unlock()
 
-	if err := c.connectClient(); err != nil {
-		_ = c.Close()
-		if root, ok := s.handlers.Get(rootNamespace); ok && root.onError != nil {
-			root.onError(nil, err)
-		}
+	auth := s.options.getAuth()
 
-		return err
+	if err := c.connectClient(s.resumeAuth(rootNamespace, auth)); err != nil {
+		s.abortConnect(c, err)
+		return nil, err
+	}
+
+	// sockets are connected before goroutines of connection start, so connection which fails
+	// doesn't leave them running.
+	for _, socket := range s.getSockets() {
+		if socket.autoConnect() {
+			if err := socket.encodeConnect(c, auth); err != nil {
+				s.abortConnect(c, err)
+				return nil, err
+			}
+		}
 	}
 
 	go s.clientError(c)
 	go s.clientWrite(c)
 	go s.clientRead(c)
-	return nil
+
+	return c, nil
+}
+
+// abortConnect closes connection c which failed to connect, client is left without connection.
+func (s *Client) abortConnect(c *conn, err error) {
+	_ = c.Close()
+
+	s.mu.Lock()
+	if s.conn == c {
+		s.conn = nil
+	}
+	s.mu.Unlock()
+
+	s.onError(err)
 }
 
-// Close closes server.
+// Close closes client and all of its sockets.
 func (s *Client) Close() error {
-	return s.conn.Close()
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
+	c := s.getConn()
+	if c == nil {
+		return nil
+	}
+
+	return c.Close()
 }
 
-func (s *Client) Emit(event string, args ...interface{}) {
-	nsp := s.namespace
+// CloseContext closes client gracefully: it waits for acks of emitted events, sends disconnect
+// packet of every connected namespace and closes connection once written packets are flushed.
+// When ctx is done first, connection is closed right away and ctx error is returned.
+func (s *Client) CloseContext(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
+	c := s.getConn()
+	if c == nil {
+		return nil
+	}
+
+	err := s.shutdown(ctx, c)
+	if closeErr := c.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Socket returns socket of namespace nsp sharing connection of client.
+// The socket is connected when client connects, or by calling its Connect.
+func (s *Client) Socket(nsp string) *ClientSocket {
 	if nsp == aliasRootNamespace {
 		nsp = rootNamespace
 	}
 
-	ns, ok := s.conn.namespaces.Get(nsp)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	socket, ok := s.sockets[nsp]
 	if !ok {
-		logger.Info("Connection Namespace not initialized")
-		return
+		socket = newClientSocket(s, nsp)
+		s.sockets[nsp] = socket
 	}
-	ns.Emit(event, args...)
+
+	return socket
+}
+
+// Emit emits event to namespace of client, see ClientSocket.Emit.
+func (s *Client) Emit(event string, args ...interface{}) {
+	s.Socket(s.namespace).Emit(event, args...)
+}
+
+// EmitWithTimeout emits event to namespace of client, see ClientSocket.EmitWithTimeout.
+func (s *Client) EmitWithTimeout(timeout time.Duration, event string, args ...interface{}) {
+	s.Socket(s.namespace).EmitWithTimeout(timeout, event, args...)
 }
 
 // OnConnect set a handler function f to handle open event for namespace.
 func (s *Client) OnConnect(f func(Conn) error) {
-	h := s.getNamespace(s.namespace)
-	if h == nil {
-		h = s.createNamespace(s.namespace)
-	}
-
-	h.OnConnect(f)
+	s.Socket(s.namespace).OnConnect(f)
 }
 
 // OnDisconnect set a handler function f to handle disconnect event for namespace.
 func (s *Client) OnDisconnect(f func(Conn, string)) {
-	h := s.getNamespace(s.namespace)
-	if h == nil {
-		h = s.createNamespace(s.namespace)
-	}
-
-	h.OnDisconnect(f)
+	s.Socket(s.namespace).OnDisconnect(f)
 }
 
-// OnError set a handler function f to handle error for namespace.
+// OnError set a handler function f to handle error for namespace,
+// connection errors are handled by root namespace handler with nil Conn.
 func (s *Client) OnError(f func(Conn, error)) {
-	h := s.getNamespace(s.namespace)
-	if h == nil {
-		h = s.createNamespace(s.namespace)
-	}
-
-	h.OnError(f)
+	s.Socket(s.namespace).OnError(f)
 }
 
 // OnEvent set a handler function f to handle event for namespace.
 func (s *Client) OnEvent(event string, f interface{}) {
-	h := s.getNamespace(s.namespace)
-	if h == nil {
-		h = s.createNamespace(s.namespace)
-	}
+	s.Socket(s.namespace).OnEvent(event, f)
+}
+
+// OnReconnectAttempt set a handler function f called before every reconnection attempt.
+func (s *Client) OnReconnectAttempt(f func(attempt int)) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onReconnectAttempt = f
+}
+
+// OnReconnect set a handler function f called when reconnection succeeds.
+func (s *Client) OnReconnect(f func(attempt int)) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onReconnect = f
+}
+
+// OnReconnectFailed set a handler function f called when all reconnection attempts failed.
+func (s *Client) OnReconnectFailed(f func()) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onReconnectFailed = f
+}
+
+// OnPing set a handler function f called on every heartbeat answered by server.
+func (s *Client) OnPing(f func()) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
 
-	h.OnEvent(event, f)
+	s.onPing = f
+}
+
+// OnEmitBufferTrim set a handler function f called with every event dropped from full emit buffer of a socket.
+func (s *Client) OnEmitBufferTrim(f func(namespace, event string)) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onEmitBufferTrim = f
 }
 
 /////////////////////////
 // Private Functions
 /////////////////////////
 
+// reconnect waits until c is lost and reconnects client while attempts are left.
+func (s *Client) reconnect(c *conn) {
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-c.quitChan:
+		}
+
+		if !s.options.getReconnection() {
+			return
+		}
+
+		var err error
+		for attempt := 1; ; attempt++ {
+			if attempts := s.options.getReconnectionAttempts(); attempts > 0 && attempt > attempts {
+				s.hooksLock.RLock()
+				onReconnectFailed := s.onReconnectFailed
+				s.hooksLock.RUnlock()
+
+				if onReconnectFailed != nil {
+					onReconnectFailed()
+				}
+				return
+			}
+
+			select {
+			case <-s.closed:
+				return
+			case <-time.After(s.options.getReconnectionDelay(attempt)):
+			}
+
+			s.hooksLock.RLock()
+			onReconnectAttempt := s.onReconnectAttempt
+			s.hooksLock.RUnlock()
+
+			if onReconnectAttempt != nil {
+				onReconnectAttempt(attempt)
+			}
+
+			if c, err = s.connect(context.Background()); err != nil {
+				logger.Error("client reconnect:", err)
+				s.onError(err)
+				continue
+			}
+
+			select {
+			case <-s.closed:
+				_ = c.Close()
+				return
+			default:
+			}
+
+			s.hooksLock.RLock()
+			onReconnect := s.onReconnect
+			s.hooksLock.RUnlock()
+
+			if onReconnect != nil {
+				onReconnect(attempt)
+			}
+			break
+		}
+	}
+}
+
+// shutdown waits for pending acks of c, and disconnects its namespaces.
+func (s *Client) shutdown(ctx context.Context, c *conn) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for c.pendingAcks() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.quitChan:
+			return nil
+		case <-ticker.C:
+		}
+	}
+
+	var nsps []string
+	c.namespaces.Range(func(ns string, _ *namespaceConn) {
+		nsps = append(nsps, ns)
+	})
+
+	for _, ns := range nsps {
+		c.write(parser.Header{
+			Type:      parser.Disconnect,
+			Namespace: ns,
+		})
+	}
+
+	return c.flush(ctx)
+}
+
+func (s *Client) ping() {
+	s.hooksLock.RLock()
+	onPing := s.onPing
+	s.hooksLock.RUnlock()
+
+	if onPing != nil {
+		onPing()
+	}
+}
+
+func (s *Client) emitBufferTrim(namespace, event string) {
+	s.hooksLock.RLock()
+	onEmitBufferTrim := s.onEmitBufferTrim
+	s.hooksLock.RUnlock()
+
+	if onEmitBufferTrim != nil {
+		onEmitBufferTrim(namespace, event)
+	}
+}
+
+// namespaceConnected keeps resume token issued by server for namespace nsp and sends buffered events.
+func (s *Client) namespaceConnected(nsp string, payload map[string]interface{}) {
+	if pid, ok := payload["pid"].(string); ok {
+		s.mu.Lock()
+		s.pids[nsp] = pid
+		s.mu.Unlock()
+	}
+
+	s.flush(nsp)
+}
+
+// resumeAuth returns auth of CONNECT packet of namespace nsp, with resume token of previous connection
+// if any and next epoch of the namespace.
+func (s *Client) resumeAuth(nsp string, auth map[string]interface{}) map[string]interface{} {
+	s.mu.Lock()
+	pid := s.pids[nsp]
+	if pid != "" {
+		s.epochs[nsp]++
+	}
+	epoch := s.epochs[nsp]
+	s.mu.Unlock()
+
+	if pid == "" {
+		return auth
+	}
+
+	ret := make(map[string]interface{}, len(auth)+2)
+	for k, v := range auth {
+		ret[k] = v
+	}
+	ret["pid"] = pid
+	ret["epoch"] = epoch
+
+	return ret
+}
+
+// forgetResume drops resume token of namespace nsp, e.g. when socket disconnects on purpose.
+func (s *Client) forgetResume(nsp string) {
+	s.mu.Lock()
+	delete(s.pids, nsp)
+	s.mu.Unlock()
+}
+
+// flush sends events buffered by socket of namespace nsp once it's connected.
+func (s *Client) flush(nsp string) {
+	s.mu.RLock()
+	socket, ok := s.sockets[nsp]
+	s.mu.RUnlock()
+
+	if ok {
+		socket.flush()
+	}
+}
+
+// onError reports connection error to root namespace error handler with nil Conn.
+func (s *Client) onError(err error) {
+	if root, ok := s.handlers.Get(rootNamespace); ok && root.onError != nil {
+		root.onError(nil, err)
+	}
+}
+
 func (s *Client) clientError(c *conn) {
 	defer func() {
 		if err := c.Close(); err != nil {
@@ -192,9 +522,8 @@ func (s *Client) clientWrite(c *conn) {
 			logger.Info("clientWrite Writer loop has stopped")
 			return
 		case pkg := <-c.writeChan:
-			if err := c.encoder.Encode(pkg.Header, pkg.Data); err != nil {
-				c.onError(pkg.Header.Namespace, err)
-			}
+			c.writePacket(pkg)
+		case <-c.flushChan:
 		}
 	}
 }
@@ -209,6 +538,8 @@ func (s *Client) clientRead(c *conn) {
 	var event string
 
 	for {
+		c.readPause.wait(c.quitChan)
+
 		var header parser.Header
 
 		if err := c.decoder.DecodeHeader(&header, &event); err != nil {
@@ -229,6 +560,8 @@ func (s *Client) clientRead(c *conn) {
 			err = clientConnectPacketHandler(c, header)
 		case parser.Disconnect:
 			err = clientDisconnectPacketHandler(c, header)
+		case parser.Error:
+			err = clientConnectErrorPacketHandler(c, header)
 		case parser.Event:
 			err = eventPacketHandler(c, event, header)
 		}
@@ -240,6 +573,25 @@ func (s *Client) clientRead(c *conn) {
 	}
 }
 
+func (s *Client) getConn() *conn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.conn
+}
+
+func (s *Client) getSockets() []*ClientSocket {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sockets := make([]*ClientSocket, 0, len(s.sockets))
+	for _, socket := range s.sockets {
+		sockets = append(sockets, socket)
+	}
+
+	return sockets
+}
+
 func (s *Client) createNamespace(nsp string) *namespaceHandler {
 	if nsp == aliasRootNamespace {
 		nsp = rootNamespace
@@ -268,16 +620,16 @@ func (s *Client) getNamespace(nsp string) *namespaceHandler {
 // Handlers
 ////
 
-func (c *conn) connectClient() error {
+func (c *conn) connectClient(auth map[string]interface{}) error {
 	rootHandler, ok := c.handlers.Get(rootNamespace)
 	if !ok {
 		return errUnavailableRootHandler
 	}
 
-	root := newNamespaceConn(c, aliasRootNamespace, rootHandler.broadcast)
+	root := newNamespaceConn(c, aliasRootNamespace, rootHandler.getBroadcast())
 	c.namespaces.Set(rootNamespace, root)
 
-	root.Join(root.Conn.ID())
+	rootHandler.bindConn(root)
 
 	c.namespaces.Range(func(ns string, nc *namespaceConn) {
 		nc.SetContext(c.Conn.Context())
@@ -287,7 +639,12 @@ func (c *conn) connectClient() error {
 		Type: parser.Connect,
 	}
 
-	if err := c.encoder.Encode(header); err != nil {
+	var args []interface{}
+	if auth != nil {
+		args = append(args, []interface{}{auth})
+	}
+
+	if err := c.encoder.Encode(header, args...); err != nil {
 		return err
 	}
 
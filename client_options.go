@@ -0,0 +1,257 @@
+package socketio
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+var errCertificateNotPinned = errors.New("server certificate doesn't match any pinned hash")
+
+// ClientOptions is configuration to create new client.
+type ClientOptions struct {
+	// Transports : transports used to dial, websocket with polling fallback is used when empty.
+	Transports []transport.Transport
+	// Upgrade : dials Transports from first to last and upgrades connection to next transports
+	// advertised by server, so with default transports connection starts on polling and is
+	// upgraded to websocket like browser clients.
+	Upgrade bool
+
+	// Header : headers added to every request, e.g. Authorization or User-Agent.
+	Header http.Header
+	// Jar : cookie jar shared by polling requests and websocket dial of the default transports,
+	// e.g. to keep cookie based sticky sessions.
+	Jar http.CookieJar
+
+	// Proxy : returns proxy url for request, http.ProxyFromEnvironment is used when nil.
+	// http (CONNECT) and socks5 proxies are supported, credentials are taken from url user info.
+	// Proxy applies only to the default transports.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// TLSClientConfig : tls configuration of the default transports.
+	TLSClientConfig *tls.Config
+	// PinnedSHA256 : base64 encoded SHA-256 hashes of server certificates or of their SubjectPublicKeyInfo.
+	// When set, handshake fails unless a certificate presented by server matches one of them.
+	PinnedSHA256 []string
+
+	// Auth : credentials sent in connect packet of every namespace.
+	Auth map[string]interface{}
+	// AuthFunc : returns credentials evaluated on every (re)connect, it takes precedence over Auth.
+	AuthFunc func() map[string]interface{}
+
+	// EmitBufferSize : events buffered by socket while it's disconnected, they're sent once it connects.
+	// Oldest events are dropped when buffer is full, it's unlimited when zero and disabled when negative.
+	EmitBufferSize int
+
+	// Reconnection : reconnects automatically when connection is lost, disabled by default.
+	Reconnection bool
+	// ReconnectionAttempts : attempts before giving up reconnecting, unlimited when zero.
+	ReconnectionAttempts int
+	// ReconnectionDelay : delay before first reconnection attempt, 1s by default.
+	// The delay is doubled on every next attempt up to ReconnectionDelayMax.
+	ReconnectionDelay time.Duration
+	// ReconnectionDelayMax : maximum delay between reconnection attempts, 5s by default.
+	ReconnectionDelayMax time.Duration
+
+	// Chunking : splits large payloads into chunks reassembled by server, which must enable
+	// chunking as well, see ChunkOptions. Disabled when nil.
+	Chunking *ChunkOptions
+
+	// EdgeCases : socket.io v5 edge cases handled by client, see ProtocolEdgeCases.
+	EdgeCases ProtocolEdgeCases
+
+	// Parser : parser of packets, it must be the parser of server, see Server.SetParser.
+	// The default JSON parser is used when nil.
+	Parser parser.Parser
+}
+
+// ProxyURL returns a proxy function always returning u, e.g.
+// ProxyURL(&url.URL{Scheme: "socks5", User: url.UserPassword("user", "pass"), Host: "127.0.0.1:1080"}).
+func ProxyURL(u *url.URL) func(*http.Request) (*url.URL, error) {
+	return http.ProxyURL(u)
+}
+
+func (o *ClientOptions) getProxy() func(*http.Request) (*url.URL, error) {
+	if o != nil && o.Proxy != nil {
+		return o.Proxy
+	}
+	return http.ProxyFromEnvironment
+}
+
+func (o *ClientOptions) getHeader() http.Header {
+	if o == nil {
+		return nil
+	}
+	return o.Header.Clone()
+}
+
+func (o *ClientOptions) getJar() http.CookieJar {
+	if o == nil {
+		return nil
+	}
+	return o.Jar
+}
+
+func (o *ClientOptions) getChunking() *ChunkOptions {
+	if o == nil {
+		return nil
+	}
+	return o.Chunking
+}
+
+func (o *ClientOptions) getEdgeCases() ProtocolEdgeCases {
+	if o == nil {
+		return ProtocolEdgeCases{}
+	}
+	return o.EdgeCases
+}
+
+func (o *ClientOptions) getParser() parser.Parser {
+	if o == nil {
+		return nil
+	}
+	return o.Parser
+}
+
+func (o *ClientOptions) getUpgrade() bool {
+	return o != nil && o.Upgrade
+}
+
+func (o *ClientOptions) getAuth() map[string]interface{} {
+	if o == nil {
+		return nil
+	}
+	if o.AuthFunc != nil {
+		return o.AuthFunc()
+	}
+	return o.Auth
+}
+
+func (o *ClientOptions) getEmitBufferSize() int {
+	if o != nil {
+		return o.EmitBufferSize
+	}
+	return 0
+}
+
+func (o *ClientOptions) getReconnection() bool {
+	return o != nil && o.Reconnection
+}
+
+func (o *ClientOptions) getReconnectionAttempts() int {
+	if o != nil {
+		return o.ReconnectionAttempts
+	}
+	return 0
+}
+
+// getReconnectionDelay returns delay before reconnection attempt, attempts start from 1.
+func (o *ClientOptions) getReconnectionDelay(attempt int) time.Duration {
+	delay, delayMax := time.Second, 5*time.Second
+	if o != nil && o.ReconnectionDelay > 0 {
+		delay = o.ReconnectionDelay
+	}
+	if o != nil && o.ReconnectionDelayMax > 0 {
+		delayMax = o.ReconnectionDelayMax
+	}
+
+	for i := 1; i < attempt && delay < delayMax; i++ {
+		delay *= 2
+	}
+
+	if delay > delayMax {
+		return delayMax
+	}
+	return delay
+}
+
+func (o *ClientOptions) getTransports() []transport.Transport {
+	if o != nil && len(o.Transports) != 0 {
+		return o.Transports
+	}
+
+	return []transport.Transport{
+		o.pollingTransport(),
+		o.websocketTransport(),
+	}
+}
+
+func (o *ClientOptions) pollingTransport() *polling.Transport {
+	return &polling.Transport{
+		Client: &http.Client{
+			Timeout: time.Minute,
+			Jar:     o.getJar(),
+			Transport: &http.Transport{
+				Proxy:           o.getProxy(),
+				TLSClientConfig: o.getTLSConfig(),
+			},
+		},
+	}
+}
+
+func (o *ClientOptions) websocketTransport() *websocket.Transport {
+	return &websocket.Transport{
+		Proxy:           o.getProxy(),
+		TLSClientConfig: o.getTLSConfig(),
+		Jar:             o.getJar(),
+	}
+}
+
+func (o *ClientOptions) getTLSConfig() *tls.Config {
+	var config *tls.Config
+	if o != nil && o.TLSClientConfig != nil {
+		config = o.TLSClientConfig.Clone()
+	}
+
+	if o == nil || len(o.PinnedSHA256) == 0 {
+		return config
+	}
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	pins := make(map[string]bool, len(o.PinnedSHA256))
+	for _, pin := range o.PinnedSHA256 {
+		pins[pin] = true
+	}
+
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+
+		return verifyPinnedCertificates(cs.PeerCertificates, pins)
+	}
+
+	return config
+}
+
+func verifyPinnedCertificates(certs []*x509.Certificate, pins map[string]bool) error {
+	for _, cert := range certs {
+		certHash := sha256.Sum256(cert.Raw)
+		if pins[base64.StdEncoding.EncodeToString(certHash[:])] {
+			return nil
+		}
+
+		spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if pins[base64.StdEncoding.EncodeToString(spkiHash[:])] {
+			return nil
+		}
+	}
+
+	return errCertificateNotPinned
+}
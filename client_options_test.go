@@ -0,0 +1,295 @@
+package socketio
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
+)
+
+func TestClientOptionsPinnedSHA256(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer svr.Close()
+
+	cert := svr.Certificate()
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	get := func(pins ...string) error {
+		tlsConfig := svr.Client().Transport.(*http.Transport).TLSClientConfig
+		opts := &ClientOptions{TLSClientConfig: tlsConfig, PinnedSHA256: pins}
+
+		client := opts.pollingTransport().Client
+		resp, err := client.Get(svr.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	must.NoError(get(base64.StdEncoding.EncodeToString(spkiHash[:])))
+
+	err := get(base64.StdEncoding.EncodeToString(make([]byte, sha256.Size)))
+	should.ErrorIs(err, errCertificateNotPinned)
+}
+
+func TestClientOptionsReconnectionDelay(t *testing.T) {
+	should := assert.New(t)
+
+	var opts *ClientOptions
+	should.Equal(time.Second, opts.getReconnectionDelay(1))
+	should.Equal(4*time.Second, opts.getReconnectionDelay(3))
+	should.Equal(5*time.Second, opts.getReconnectionDelay(10))
+
+	opts = &ClientOptions{ReconnectionDelay: 100 * time.Millisecond, ReconnectionDelayMax: time.Second}
+	should.Equal(200*time.Millisecond, opts.getReconnectionDelay(2))
+	should.Equal(time.Second, opts.getReconnectionDelay(100))
+}
+
+func TestClientOptionsHeaderAndJar(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default, websocket.Default},
+	})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	go func() {
+		_ = server.Serve()
+	}()
+
+	type request struct {
+		transport     string
+		authorization string
+		cookie        string
+	}
+	var requests []request
+	var requestsLock sync.Mutex
+
+	httpSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsLock.Lock()
+		req := request{transport: r.URL.Query().Get("transport"), authorization: r.Header.Get("Authorization")}
+		if cookie, err := r.Cookie("sticky"); err == nil {
+			req.cookie = cookie.Value
+		}
+		requests = append(requests, req)
+		requestsLock.Unlock()
+
+		if r.URL.Query().Get("sid") == "" {
+			http.SetCookie(w, &http.Cookie{Name: "sticky", Value: "node-1"})
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer func() {
+		httpSvr.Close()
+		_ = server.Close()
+	}()
+
+	jar, err := cookiejar.New(nil)
+	must.NoError(err)
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Header:  http.Header{"Authorization": []string{"Bearer token"}},
+		Jar:     jar,
+		Upgrade: true,
+	})
+	must.NoError(err)
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	upgraded := func() bool {
+		c, ok := client.getConn().Conn.(interface{ Transport() string })
+		return ok && c.Transport() == "websocket"
+	}
+	must.Eventually(upgraded, 5*time.Second, 10*time.Millisecond)
+
+	requestsLock.Lock()
+	defer requestsLock.Unlock()
+
+	must.NotEmpty(requests)
+	should.Equal("polling", requests[0].transport)
+	should.Equal("", requests[0].cookie)
+
+	var websocketRequests int
+	for _, req := range requests {
+		should.Equal("Bearer token", req.authorization)
+		if req.transport == "websocket" {
+			websocketRequests++
+		}
+	}
+	should.Equal(1, websocketRequests)
+
+	for _, req := range requests[1:] {
+		should.Equal("node-1", req.cookie)
+	}
+}
+
+// httpProxy is HTTP proxy with basic authentication, it forwards plain requests and tunnels
+// CONNECT requests, and records methods of requests it served.
+type httpProxy struct {
+	*httptest.Server
+
+	auth string
+
+	mu      sync.Mutex
+	methods map[string]int
+}
+
+func newHTTPProxy(t *testing.T, user, pass string) *httpProxy {
+	p := &httpProxy{
+		auth:    "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass)),
+		methods: make(map[string]int),
+	}
+	p.Server = httptest.NewServer(p)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *httpProxy) count(method string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.methods[method]
+}
+
+func (p *httpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Proxy-Authorization") != p.auth {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+		return
+	}
+
+	p.mu.Lock()
+	p.methods[r.Method]++
+	p.mu.Unlock()
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Authorization")
+
+	resp, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *httpProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	dst, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer dst.Close()
+
+	src, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	if _, err = src.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(dst, buf)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(src, dst)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func TestClientOptionsProxy(t *testing.T) {
+	for name, method := range map[string]string{"polling": http.MethodGet, "websocket": http.MethodConnect} {
+		t.Run(name, func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			server := NewServer(&engineio.Options{
+				Transports: []transport.Transport{polling.Default, websocket.Default},
+			})
+			server.OnConnect("/", func(Conn) error {
+				return nil
+			})
+			server.OnEvent("/", "echo", func(_ Conn, msg string) string {
+				return msg
+			})
+
+			go func() {
+				_ = server.Serve()
+			}()
+			defer server.Close()
+
+			httpSvr := httptest.NewServer(server)
+			defer httpSvr.Close()
+
+			proxy := newHTTPProxy(t, "user", "pass")
+			proxyURL, err := url.Parse(proxy.URL)
+			must.NoError(err)
+			proxyURL.User = url.UserPassword("user", "pass")
+
+			opts := &ClientOptions{Proxy: ProxyURL(proxyURL)}
+			if name == "polling" {
+				opts.Transports = []transport.Transport{opts.pollingTransport()}
+			} else {
+				opts.Transports = []transport.Transport{opts.websocketTransport()}
+			}
+
+			client, err := NewClientWithOptions(httpSvr.URL, opts)
+			must.NoError(err)
+			must.NoError(client.Connect())
+			defer client.Close()
+
+			replies := make(chan string, 1)
+			client.Emit("echo", "through proxy", func(msg string) {
+				replies <- msg
+			})
+
+			select {
+			case msg := <-replies:
+				should.Equal("through proxy", msg)
+			case <-time.After(5 * time.Second):
+				t.Fatal("missing ack through proxy")
+			}
+
+			should.Positive(proxy.count(method), "transport goes through proxy")
+		})
+	}
+}
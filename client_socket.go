@@ -0,0 +1,359 @@
+package socketio
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ClientSocket is a client namespace socket, all sockets of a client share
+// the same engine.io connection of the client.
+type ClientSocket struct {
+	client    *Client
+	namespace string
+
+	mu           sync.Mutex
+	disconnected bool
+	buffer       []bufferedEmit
+}
+
+// bufferedEmit is event emitted while socket is disconnected.
+type bufferedEmit struct {
+	event string
+	args  []interface{}
+	ack   *timeoutAck
+}
+
+func newClientSocket(client *Client, namespace string) *ClientSocket {
+	return &ClientSocket{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// Namespace returns namespace of socket.
+func (s *ClientSocket) Namespace() string {
+	if s.namespace == rootNamespace {
+		return aliasRootNamespace
+	}
+
+	return s.namespace
+}
+
+// Connect connects socket to its namespace. When client isn't connected yet,
+// socket is connected once client connects.
+func (s *ClientSocket) Connect() error {
+	s.mu.Lock()
+	s.disconnected = false
+	s.mu.Unlock()
+
+	c := s.client.getConn()
+	if c == nil {
+		return nil
+	}
+
+	return s.connect(c, s.client.options.getAuth())
+}
+
+// Disconnect disconnects socket from its namespace, other sockets of client stay connected.
+func (s *ClientSocket) Disconnect() error {
+	s.mu.Lock()
+	s.disconnected = true
+	s.mu.Unlock()
+
+	s.client.forgetResume(s.namespace)
+
+	c := s.client.getConn()
+	if c == nil {
+		return nil
+	}
+
+	nc, ok := c.namespaces.Get(s.namespace)
+	if !ok {
+		return nil
+	}
+
+	c.write(parser.Header{
+		Type:      parser.Disconnect,
+		Namespace: s.namespace,
+	})
+
+	nc.LeaveAll()
+	c.namespaces.Delete(s.namespace)
+
+	if handler, ok := c.handlers.Get(s.namespace); ok && handler.onDisconnect != nil {
+		handler.onDisconnect(nc, clientDisconnectMsg)
+	}
+
+	return nil
+}
+
+// Connected returns whether socket is connected to its namespace.
+func (s *ClientSocket) Connected() bool {
+	return s.namespaceConn() != nil
+}
+
+// Emit emits event to namespace of socket. Events emitted while socket is
+// disconnected are buffered and sent once it connects, see ClientOptions.EmitBufferSize.
+func (s *ClientSocket) Emit(event string, args ...interface{}) {
+	s.emit(bufferedEmit{event: event, args: args})
+}
+
+// EmitWithTimeout emits event to namespace of socket with ack callback like func(error, ...),
+// which is called with ErrAckTimeout when server doesn't acknowledge event within timeout.
+// The callback is called once, on timeout it's called from a separate goroutine.
+func (s *ClientSocket) EmitWithTimeout(timeout time.Duration, event string, args ...interface{}) {
+	l := len(args)
+	if l == 0 {
+		panic("ack callback must be the last argument of EmitWithTimeout.")
+	}
+
+	ack := newTimeoutAck(args[l-1], timeout)
+
+	s.emit(bufferedEmit{event: event, args: append(args[:l-1:l-1], ack.callback()), ack: ack})
+}
+
+func (s *ClientSocket) emit(e bufferedEmit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nc := s.namespaceConn(); nc != nil {
+		s.send(nc, e)
+		return
+	}
+
+	size := s.client.options.getEmitBufferSize()
+	if size < 0 {
+		return
+	}
+
+	s.buffer = append(s.buffer, e)
+
+	for size > 0 && len(s.buffer) > size {
+		dropped := s.buffer[0]
+		s.buffer = s.buffer[1:]
+
+		s.client.emitBufferTrim(s.Namespace(), dropped.event)
+	}
+}
+
+// OnConnect set a handler function f to handle open event for namespace.
+func (s *ClientSocket) OnConnect(f func(Conn) error) {
+	s.handler().OnConnect(f)
+}
+
+// OnDisconnect set a handler function f to handle disconnect event for namespace.
+func (s *ClientSocket) OnDisconnect(f func(Conn, string)) {
+	s.handler().OnDisconnect(f)
+}
+
+// OnError set a handler function f to handle error for namespace.
+func (s *ClientSocket) OnError(f func(Conn, error)) {
+	s.handler().OnError(f)
+}
+
+// OnEvent set a handler function f to handle event for namespace.
+func (s *ClientSocket) OnEvent(event string, f interface{}) {
+	s.handler().OnEvent(event, f)
+}
+
+func (s *ClientSocket) handler() *namespaceHandler {
+	h := s.client.getNamespace(s.namespace)
+	if h == nil {
+		h = s.client.createNamespace(s.namespace)
+	}
+
+	return h
+}
+
+func (s *ClientSocket) namespaceConn() *namespaceConn {
+	c := s.client.getConn()
+	if c == nil {
+		return nil
+	}
+
+	select {
+	case <-c.quitChan:
+		return nil
+	default:
+	}
+
+	nc, ok := c.namespaces.Get(s.namespace)
+	if !ok {
+		return nil
+	}
+
+	return nc
+}
+
+// flush sends buffered events once socket is connected.
+func (s *ClientSocket) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nc := s.namespaceConn()
+	if nc == nil {
+		return
+	}
+
+	for _, e := range s.buffer {
+		s.send(nc, e)
+	}
+	s.buffer = nil
+}
+
+func (s *ClientSocket) send(nc *namespaceConn, e bufferedEmit) {
+	id := nc.emit(e.event, e.args...)
+
+	if e.ack != nil {
+		e.ack.sent(nc, id)
+	}
+}
+
+func (s *ClientSocket) autoConnect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.disconnected
+}
+
+// connect queues CONNECT packet of namespace of socket for the writer of c, unless socket is
+// connected already.
+func (s *ClientSocket) connect(c *conn, auth map[string]interface{}) error {
+	header, args, ok := s.connectPacket(c, auth)
+	if !ok {
+		return nil
+	}
+
+	values := make([]reflect.Value, len(args))
+	for i := range args {
+		values[i] = reflect.ValueOf(args[i])
+	}
+	c.write(header, values...)
+
+	return nil
+}
+
+// encodeConnect writes CONNECT packet like connect, while client connects and the writer of c
+// isn't running yet, so connect fails when packet can't be written.
+func (s *ClientSocket) encodeConnect(c *conn, auth map[string]interface{}) error {
+	header, args, ok := s.connectPacket(c, auth)
+	if !ok {
+		return nil
+	}
+
+	if len(args) == 0 {
+		return c.encoder.Encode(header)
+	}
+
+	return c.encoder.Encode(header, args)
+}
+
+// connectPacket gives CONNECT packet of namespace of socket, ok is false when it's connected.
+func (s *ClientSocket) connectPacket(c *conn, auth map[string]interface{}) (parser.Header, []interface{}, bool) {
+	if _, ok := c.namespaces.Get(s.namespace); ok {
+		return parser.Header{}, nil, false
+	}
+
+	s.handler()
+
+	header := parser.Header{
+		Type:      parser.Connect,
+		Namespace: s.namespace,
+	}
+
+	auth = s.client.resumeAuth(s.namespace, auth)
+	if auth == nil {
+		return header, nil, true
+	}
+
+	return header, []interface{}{auth}, true
+}
+
+// timeoutAck is ack callback like func(error, ...) of EmitWithTimeout.
+type timeoutAck struct {
+	f        reflect.Value
+	argTypes []reflect.Type
+
+	once  sync.Once
+	timer *time.Timer
+
+	// nc and id locate ack of sent event, so it's not pending anymore once timed out.
+	mu      sync.Mutex
+	nc      *namespaceConn
+	id      uint64
+	expired bool
+}
+
+// newTimeoutAck returns ack of callback f, f is called with ErrAckTimeout when timeout elapses
+// before ack is received.
+func newTimeoutAck(f interface{}, timeout time.Duration) *timeoutAck {
+	fv := reflect.ValueOf(f)
+	if fv.Kind() != reflect.Func || fv.Type().NumIn() == 0 || fv.Type().In(0) != errorType {
+		panic("ack callback of EmitWithTimeout should be like func(error, ...)")
+	}
+
+	ft := fv.Type()
+	argTypes := make([]reflect.Type, ft.NumIn()-1)
+	for i := range argTypes {
+		argTypes[i] = ft.In(i + 1)
+	}
+
+	a := &timeoutAck{
+		f:        fv,
+		argTypes: argTypes,
+	}
+	a.timer = time.AfterFunc(timeout, a.timeout)
+
+	return a
+}
+
+// callback returns ack callback without the error argument, which is emitted with event.
+func (a *timeoutAck) callback() interface{} {
+	return reflect.MakeFunc(reflect.FuncOf(a.argTypes, nil, false), func(args []reflect.Value) []reflect.Value {
+		a.timer.Stop()
+		a.call(nil, args)
+
+		return nil
+	}).Interface()
+}
+
+func (a *timeoutAck) sent(nc *namespaceConn, id uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.expired {
+		nc.forgetAck(id)
+		return
+	}
+
+	a.nc, a.id = nc, id
+}
+
+func (a *timeoutAck) timeout() {
+	a.mu.Lock()
+	a.expired = true
+	nc, id := a.nc, a.id
+	a.mu.Unlock()
+
+	if nc != nil {
+		nc.forgetAck(id)
+	}
+
+	args := make([]reflect.Value, len(a.argTypes))
+	for i := range args {
+		args[i] = reflect.Zero(a.argTypes[i])
+	}
+
+	a.call(ErrAckTimeout, args)
+}
+
+func (a *timeoutAck) call(err error, args []reflect.Value) {
+	a.once.Do(func() {
+		a.f.Call(append([]reflect.Value{reflect.ValueOf(&err).Elem()}, args...))
+	})
+}
@@ -0,0 +1,621 @@
+package socketio
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/frame"
+	"github.com/thisismz/go-socket.io/engineio/packet"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/fault"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestClientSocketMultiplexing(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t)
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	chatConnected := make(chan struct{}, 1)
+	chat := client.Socket("/chat")
+	chat.OnConnect(func(Conn) error {
+		chatConnected <- struct{}{}
+		return nil
+	})
+
+	chatDisconnected := make(chan string, 1)
+	chat.OnDisconnect(func(_ Conn, reason string) {
+		chatDisconnected <- reason
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	select {
+	case <-chatConnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat socket wasn't connected")
+	}
+
+	should.True(chat.Connected())
+	should.True(client.Socket("/").Connected())
+	should.Equal("/chat", chat.Namespace())
+
+	replies := make(chan string, 2)
+	client.Socket("/").Emit("echo", "root", func(msg string) {
+		replies <- msg
+	})
+	chat.Emit("echo", "chat", func(msg string) {
+		replies <- msg
+	})
+
+	got := make([]string, 0, 2)
+	for len(got) < 2 {
+		select {
+		case msg := <-replies:
+			got = append(got, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing ack replies")
+		}
+	}
+	should.ElementsMatch([]string{"root", "/chat chat"}, got)
+
+	must.NoError(chat.Disconnect())
+	should.Equal(clientDisconnectMsg, <-chatDisconnected)
+	should.False(chat.Connected())
+	should.True(client.Socket("/").Connected())
+}
+
+func TestClientReconnect(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t)
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports:        []transport.Transport{polling.Default},
+		Reconnection:      true,
+		ReconnectionDelay: 10 * time.Millisecond,
+	})
+	must.NoError(err)
+
+	attempts := make(chan int, 1)
+	client.OnReconnectAttempt(func(attempt int) {
+		attempts <- attempt
+	})
+
+	reconnected := make(chan int, 1)
+	client.OnReconnect(func(attempt int) {
+		reconnected <- attempt
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	// lose underlying engine.io connection
+	must.NoError(client.getConn().Conn.Close())
+
+	select {
+	case attempt := <-reconnected:
+		should.Equal(1, attempt)
+		should.Equal(1, <-attempts)
+	case <-time.After(5 * time.Second):
+		t.Fatal("client wasn't reconnected")
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := engineio.NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+
+	type connect struct {
+		namespace string
+		auth      map[string]interface{}
+	}
+	connects := make(chan connect, 4)
+
+	var conns []engineio.Conn
+	var connsLock sync.Mutex
+
+	go func() {
+		for {
+			c, err := server.Accept()
+			if err != nil {
+				return
+			}
+
+			connsLock.Lock()
+			conns = append(conns, c)
+			connsLock.Unlock()
+
+			go func() {
+				decoder := parser.NewDecoder(c)
+				for {
+					var header parser.Header
+					var event string
+					if err := decoder.DecodeHeader(&header, &event); err != nil {
+						return
+					}
+
+					args, err := decoder.DecodeArgs([]reflect.Type{reflect.TypeOf(map[string]interface{}{})})
+					if err != nil {
+						return
+					}
+
+					connects <- connect{namespace: header.Namespace, auth: args[0].Interface().(map[string]interface{})}
+				}
+			}()
+		}
+	}()
+
+	httpSvr := httptest.NewServer(server)
+	defer func() {
+		connsLock.Lock()
+		for _, c := range conns {
+			_ = c.Close()
+		}
+		connsLock.Unlock()
+
+		httpSvr.Close()
+		_ = server.Close()
+	}()
+
+	var token int
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+		AuthFunc: func() map[string]interface{} {
+			token++
+			return map[string]interface{}{"token": fmt.Sprint(token)}
+		},
+		Reconnection:      true,
+		ReconnectionDelay: 10 * time.Millisecond,
+	})
+	must.NoError(err)
+
+	client.Socket("/chat")
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	receive := func() connect {
+		select {
+		case c := <-connects:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing connect packet")
+		}
+		return connect{}
+	}
+
+	got := []connect{receive(), receive()}
+	should.ElementsMatch([]connect{
+		{namespace: "", auth: map[string]interface{}{"token": "1"}},
+		{namespace: "/chat", auth: map[string]interface{}{"token": "1"}},
+	}, got)
+
+	// refreshed credentials are sent on reconnect
+	must.NoError(client.getConn().Conn.Close())
+
+	got = []connect{receive(), receive()}
+	should.ElementsMatch([]connect{
+		{namespace: "", auth: map[string]interface{}{"token": "2"}},
+		{namespace: "/chat", auth: map[string]interface{}{"token": "2"}},
+	}, got)
+}
+
+func TestClientEmitWithTimeout(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t)
+	server.OnEvent("/", "slow", func(_ Conn, msg string) string {
+		time.Sleep(200 * time.Millisecond)
+		return msg
+	})
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	type reply struct {
+		msg string
+		err error
+	}
+	replies := make(chan reply, 2)
+
+	client.EmitWithTimeout(5*time.Second, "echo", "fast", func(err error, msg string) {
+		replies <- reply{msg: msg, err: err}
+	})
+	should.Equal(reply{msg: "fast"}, <-replies)
+
+	client.EmitWithTimeout(50*time.Millisecond, "slow", "late", func(err error, msg string) {
+		replies <- reply{msg: msg, err: err}
+	})
+	should.Equal(reply{err: ErrAckTimeout}, <-replies)
+}
+
+func TestTimeoutAck(t *testing.T) {
+	should := assert.New(t)
+
+	var errs []error
+	var msgs []string
+	ack := newTimeoutAck(func(err error, msg string) {
+		errs = append(errs, err)
+		msgs = append(msgs, msg)
+	}, time.Millisecond).callback().(func(string))
+
+	time.Sleep(50 * time.Millisecond)
+
+	// late ack is ignored
+	ack("late")
+
+	should.Equal([]error{ErrAckTimeout}, errs)
+	should.Equal([]string{""}, msgs)
+
+	should.Panics(func() {
+		newTimeoutAck(func(msg string) {}, time.Second)
+	})
+}
+
+func TestClientEmitBuffer(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t)
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports:     []transport.Transport{polling.Default},
+		EmitBufferSize: 2,
+	})
+	must.NoError(err)
+
+	var trimmed []string
+	client.OnEmitBufferTrim(func(namespace, event string) {
+		trimmed = append(trimmed, namespace+" "+event)
+	})
+
+	replies := make(chan string, 3)
+	for _, msg := range []string{"a", "b", "c"} {
+		client.Emit("echo", msg, func(msg string) {
+			replies <- msg
+		})
+	}
+	should.Equal([]string{"/ echo"}, trimmed)
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	got := make([]string, 0, 2)
+	for len(got) < 2 {
+		select {
+		case msg := <-replies:
+			got = append(got, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing ack replies")
+		}
+	}
+	should.Equal([]string{"b", "c"}, got)
+}
+
+func TestClientBinary(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t)
+	server.OnEvent("/", "binary echo", func(s Conn, b *parser.Buffer) {
+		s.Emit("binary", b)
+	})
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	received := make(chan []byte, 1)
+	client.OnEvent("binary", func(_ Conn, b *parser.Buffer) {
+		received <- b.Data
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	lengths := make(chan int, 1)
+	client.Emit("binary", &parser.Buffer{Data: []byte{1, 2, 3}}, func(l int) {
+		lengths <- l
+	})
+
+	select {
+	case l := <-lengths:
+		should.Equal(3, l)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing binary ack")
+	}
+
+	client.Emit("binary echo", &parser.Buffer{Data: []byte{4, 5}})
+
+	select {
+	case data := <-received:
+		should.Equal([]byte{4, 5}, data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing binary event")
+	}
+}
+
+func TestClientCloseContext(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t)
+	server.OnEvent("/", "slow", func(_ Conn, msg string) string {
+		time.Sleep(100 * time.Millisecond)
+		return msg
+	})
+
+	disconnected := make(chan string, 2)
+	server.OnDisconnect("/chat", func(s Conn, _ string) {
+		disconnected <- s.Namespace()
+	})
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	chatConnected := make(chan struct{}, 1)
+	client.Socket("/chat").OnConnect(func(Conn) error {
+		chatConnected <- struct{}{}
+		return nil
+	})
+
+	must.NoError(client.Connect())
+
+	select {
+	case <-chatConnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat socket wasn't connected")
+	}
+
+	var reply string
+	client.Emit("slow", "pending", func(msg string) {
+		reply = msg
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	must.NoError(client.CloseContext(ctx))
+	should.Equal("pending", reply)
+
+	select {
+	case nsp := <-disconnected:
+		should.Equal("/chat", nsp)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat socket wasn't disconnected from server")
+	}
+}
+
+func TestClientCloseContextDeadline(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t)
+	server.OnEvent("/", "slow", func(_ Conn, msg string) string {
+		time.Sleep(200 * time.Millisecond)
+		return msg
+	})
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	must.NoError(client.Connect())
+
+	client.Emit("slow", "pending", func(string) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	should.ErrorIs(client.CloseContext(ctx), context.DeadlineExceeded)
+}
+
+func TestClientConnectContext(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t)
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	should.ErrorIs(client.ConnectContext(ctx), context.Canceled)
+}
+
+func TestClientConnectSocketFailure(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{websocket.Default},
+	})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	// CONNECT of root namespace is written, CONNECT of /chat is aborted
+	var mu sync.Mutex
+	messages := 0
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{fault.New(websocket.Default, &fault.Faults{
+			AbortAfter: func(_ frame.Type, pt packet.Type) int {
+				if pt != packet.MESSAGE {
+					return -1
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				messages++
+				if messages == 2 {
+					return 0
+				}
+				return -1
+			},
+		})},
+	})
+	must.NoError(err)
+
+	errs := make(chan error, 1)
+	client.OnError(func(_ Conn, err error) {
+		errs <- err
+	})
+	chatConnected := make(chan struct{}, 1)
+	client.Socket("/chat").OnConnect(func(Conn) error {
+		chatConnected <- struct{}{}
+		return nil
+	})
+
+	should.ErrorIs(client.Connect(), fault.ErrAborted)
+	should.ErrorIs(<-errs, fault.ErrAborted)
+	should.Nil(client.getConn(), "failed connection isn't kept")
+
+	// next connect doesn't reuse failed connection
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	select {
+	case <-chatConnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat socket wasn't connected")
+	}
+}
+
+func TestClientBinaryParsers(t *testing.T) {
+	for name, p := range map[string]parser.Parser{"msgpack": parser.MsgPack, "protobuf": parser.Protobuf} {
+		p := p
+		t.Run(name, func(t *testing.T) {
+			for _, tr := range []transport.Transport{polling.Default, websocket.Default} {
+				t.Run(tr.Name(), func(t *testing.T) {
+					should := assert.New(t)
+					must := require.New(t)
+
+					server := NewServer(&engineio.Options{
+						Transports: []transport.Transport{polling.Default, websocket.Default},
+					})
+					must.NoError(server.SetParser(p))
+
+					auths := make(chan map[string]interface{}, 1)
+					server.Use("/chat", func(_ Conn, auth map[string]interface{}, next func(error)) {
+						auths <- auth
+						next(nil)
+					})
+					server.OnConnect("/", func(Conn) error {
+						return nil
+					})
+					server.OnConnect("/chat", func(Conn) error {
+						return nil
+					})
+					server.OnEvent("/chat", "binary", func(_ Conn, b *parser.Buffer, n int) int {
+						return len(b.Data) + n
+					})
+					server.OnEvent("/chat", "binary echo", func(s Conn, b *parser.Buffer) {
+						s.Emit("binary", b, "echo")
+					})
+
+					go func() {
+						_ = server.Serve()
+					}()
+					defer server.Close()
+
+					httpSvr := httptest.NewServer(server)
+					defer httpSvr.Close()
+
+					client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+						Transports: []transport.Transport{tr},
+						Parser:     p,
+						Auth:       map[string]interface{}{"user": "alice"},
+					})
+					must.NoError(err)
+
+					chat := client.Socket("/chat")
+					received := make(chan []byte, 1)
+					chat.OnEvent("binary", func(_ Conn, b *parser.Buffer, from string) {
+						should.Equal("echo", from)
+						received <- b.Data
+					})
+
+					must.NoError(client.Connect())
+					defer client.Close()
+
+					select {
+					case auth := <-auths:
+						should.Equal("alice", auth["user"])
+					case <-time.After(5 * time.Second):
+						t.Fatal("chat isn't connected")
+					}
+
+					lengths := make(chan int, 1)
+					chat.Emit("binary", &parser.Buffer{Data: []byte{1, 2, 3}}, 10, func(l int) {
+						lengths <- l
+					})
+
+					select {
+					case l := <-lengths:
+						should.Equal(13, l)
+					case <-time.After(5 * time.Second):
+						t.Fatal("missing binary ack")
+					}
+
+					chat.Emit("binary echo", &parser.Buffer{Data: []byte{4, 5}})
+
+					select {
+					case data := <-received:
+						should.Equal([]byte{4, 5}, data)
+					case <-time.After(5 * time.Second):
+						t.Fatal("missing binary event")
+					}
+				})
+			}
+		})
+	}
+}
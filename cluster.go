@@ -0,0 +1,320 @@
+package socketio
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// ClusterNode is a server instance seen through the adapter.
+type ClusterNode struct {
+	ID            string
+	LastHeartbeat time.Time
+	Self          bool
+}
+
+type nodeHeartbeat struct {
+	NodeID string
+}
+
+// clusterNodes announces node identity through redis and tracks peers' heartbeats.
+type clusterNodes struct {
+	// pub and sub are redialed once they're lost, connLock guards them against Close.
+	pub      *redis.PubSubConn
+	sub      *redis.PubSubConn
+	connLock sync.Mutex
+	opts     *RedisAdapterOptions
+
+	nodeID   string
+	channel  string
+	interval time.Duration
+	timeout  time.Duration
+
+	nodes    map[string]time.Time
+	watchers []func(nodeID string)
+	lock     sync.RWMutex
+
+	quitChan  chan struct{}
+	closeOnce sync.Once
+}
+
+func newClusterNodes(opts *RedisAdapterOptions) (*clusterNodes, error) {
+	pub, err := opts.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &clusterNodes{
+		pub:      &redis.PubSubConn{Conn: pub},
+		opts:     opts,
+		nodeID:   opts.NodeID,
+		channel:  fmt.Sprintf("%s-nodes", opts.Prefix),
+		interval: opts.HeartbeatInterval,
+		timeout:  opts.HeartbeatTimeout,
+		nodes:    make(map[string]time.Time),
+		quitChan: make(chan struct{}),
+	}
+
+	if cn.sub, err = cn.subscribe(); err != nil {
+		_ = pub.Close()
+		return nil, err
+	}
+
+	go cn.dispatch()
+	go cn.heartbeat()
+
+	return cn, nil
+}
+
+// Nodes gives list of the known nodes, sorted by id.
+func (cn *clusterNodes) Nodes() []ClusterNode {
+	cn.lock.RLock()
+	defer cn.lock.RUnlock()
+
+	nodes := make([]ClusterNode, 0, len(cn.nodes))
+	for id, last := range cn.nodes {
+		nodes = append(nodes, ClusterNode{
+			ID:            id,
+			LastHeartbeat: last,
+			Self:          id == cn.nodeID,
+		})
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+
+	return nodes
+}
+
+// Watch adds f called with id of every node which stops heart-beating.
+func (cn *clusterNodes) Watch(f func(nodeID string)) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	cn.watchers = append(cn.watchers, f)
+}
+
+// Close stops heartbeats and closes redis connections.
+func (cn *clusterNodes) Close() error {
+	var err error
+
+	cn.closeOnce.Do(func() {
+		close(cn.quitChan)
+
+		cn.connLock.Lock()
+		defer cn.connLock.Unlock()
+
+		if closeErr := cn.sub.Close(); closeErr != nil {
+			err = closeErr
+		}
+		if closeErr := cn.pub.Close(); closeErr != nil {
+			err = closeErr
+		}
+	})
+
+	return err
+}
+
+func (cn *clusterNodes) closed() bool {
+	select {
+	case <-cn.quitChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, it reports false when nodes are closed meanwhile.
+func (cn *clusterNodes) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-cn.quitChan:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (cn *clusterNodes) heartbeat() {
+	ticker := time.NewTicker(cn.interval)
+	defer ticker.Stop()
+
+	for {
+		cn.announce()
+		cn.removeDead()
+
+		select {
+		case <-cn.quitChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cn *clusterNodes) announce() {
+	msg, err := json.Marshal(&nodeHeartbeat{
+		NodeID: cn.nodeID,
+	})
+	if err != nil {
+		return
+	}
+
+	if _, err = cn.pub.Conn.Do("PUBLISH", cn.channel, msg); err == nil || cn.pub.Conn.Err() == nil {
+		if err != nil {
+			logger.Error("publish node heartbeat:", err)
+		}
+		return
+	}
+
+	// lost connection is redialed, heartbeat is published again on the next tick
+	logger.Error("publish node heartbeat:", err)
+
+	conn, err := cn.opts.dial()
+	if err != nil {
+		logger.Error("redial node heartbeat:", err)
+		return
+	}
+
+	cn.connLock.Lock()
+	defer cn.connLock.Unlock()
+
+	if cn.closed() {
+		_ = conn.Close()
+		return
+	}
+	_ = cn.pub.Close()
+	cn.pub = &redis.PubSubConn{Conn: conn}
+}
+
+// removeDead removes nodes without heartbeat for timeout and notifies watchers.
+func (cn *clusterNodes) removeDead() {
+	deadline := time.Now().Add(-cn.timeout)
+
+	cn.lock.Lock()
+	var dead []string
+	for id, last := range cn.nodes {
+		if id != cn.nodeID && last.Before(deadline) {
+			dead = append(dead, id)
+			delete(cn.nodes, id)
+		}
+	}
+	watchers := cn.watchers
+	cn.lock.Unlock()
+
+	for _, id := range dead {
+		logger.Info("cluster node is dead", "node", id)
+
+		for _, f := range watchers {
+			f(id)
+		}
+	}
+}
+
+func (cn *clusterNodes) onHeartbeat(msg []byte) {
+	var hb nodeHeartbeat
+	if err := json.Unmarshal(msg, &hb); err != nil || hb.NodeID == "" {
+		return
+	}
+
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	cn.nodes[hb.NodeID] = time.Now()
+}
+
+// subscribe dials connection subscribed to heartbeats, master is resolved again with sentinel.
+func (cn *clusterNodes) subscribe() (*redis.PubSubConn, error) {
+	conn, err := cn.opts.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &redis.PubSubConn{Conn: conn}
+	if err = sub.Subscribe(cn.channel); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// resubscribe replaces lost subscription, retrying until it succeeds or nodes are closed.
+// Heartbeats published meanwhile are lost, nodes aren't dead until timeout passes.
+func (cn *clusterNodes) resubscribe() bool {
+	backoff := redisResubscribeBackoff
+	for {
+		sub, err := cn.subscribe()
+		if err == nil {
+			cn.connLock.Lock()
+			defer cn.connLock.Unlock()
+
+			if cn.closed() {
+				_ = sub.Close()
+				return false
+			}
+			_ = cn.sub.Close()
+			cn.sub = sub
+			return true
+		}
+
+		logger.Error("redis resubscribe node heartbeats:", err)
+
+		if !cn.sleep(backoff) {
+			return false
+		}
+		backoff = min(2*backoff, redisMaxResubscribeBackoff)
+	}
+}
+
+func (cn *clusterNodes) dispatch() {
+	backoff := redisResubscribeBackoff
+	for {
+		start := time.Now()
+		err := cn.receive()
+		if err == nil || cn.closed() {
+			return
+		}
+
+		logger.Error("redis node heartbeats subscription lost:", err)
+
+		// subscription which is lost right away is restored with backoff as well
+		if time.Since(start) > redisMaxResubscribeBackoff {
+			backoff = redisResubscribeBackoff
+		}
+		if !cn.sleep(backoff) {
+			return
+		}
+		backoff = min(2*backoff, redisMaxResubscribeBackoff)
+
+		if !cn.resubscribe() {
+			return
+		}
+	}
+}
+
+// receive handles heartbeats until subscription ends, it gives error when connection is lost.
+func (cn *clusterNodes) receive() error {
+	for {
+		switch m := receive(cn.sub).(type) {
+		case redis.Message:
+			cn.onHeartbeat(m.Data)
+
+		case redis.Subscription:
+			if m.Count == 0 {
+				return nil
+			}
+
+		case error:
+			return m
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package socketio
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// fakeClusterRedis answers commands of adapter, node2 heart-beats once when nodes channel is
+// subscribed and commands published by this node are sent to published.
+func fakeClusterRedis(t *testing.T, published chan<- []string) string {
+	return fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+				if channel == "app-nodes" {
+					reply += respArray("message", channel, `{"NodeID":"node2"}`)
+				}
+			}
+			return reply
+		case "PUBSUB":
+			return "*2\r\n" + respArray(cmd[2])[4:] + ":2\r\n"
+		case "PUBLISH":
+			select {
+			case published <- cmd[1:]:
+			default:
+			}
+		}
+		return ":1\r\n"
+	})
+}
+
+func TestServerClusterNodes(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 64)
+	addr := fakeClusterRedis(t, published)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	_, err := server.Adapter(&RedisAdapterOptions{
+		Addr:              addr,
+		Prefix:            "app",
+		NodeID:            "node1",
+		HeartbeatInterval: 10 * time.Millisecond,
+		HeartbeatTimeout:  time.Minute,
+	})
+	must.NoError(err)
+	should.Equal("node1", server.NodeID())
+
+	var heartbeat []string
+	for heartbeat == nil {
+		select {
+		case msg := <-published:
+			if msg[0] == "app-nodes" {
+				heartbeat = msg
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("heartbeat isn't published")
+		}
+	}
+	should.JSONEq(`{"NodeID":"node1"}`, heartbeat[1])
+
+	// own heartbeat comes back through the channel like the one of node2
+	server.cluster.onHeartbeat([]byte(heartbeat[1]))
+
+	must.Eventually(func() bool {
+		return len(server.ClusterNodes()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	nodes := server.ClusterNodes()
+	should.Equal("node1", nodes[0].ID)
+	should.True(nodes[0].Self)
+	should.Equal("node2", nodes[1].ID)
+	should.False(nodes[1].Self)
+	should.WithinDuration(time.Now(), nodes[1].LastHeartbeat, time.Minute)
+
+	// broadcaster of namespace is identified by node id, so it drops its own messages
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	rbc, ok := server.getNamespace("/chat").getBroadcast().(*redisBroadcast)
+	must.True(ok)
+	should.Equal("node1", rbc.uid)
+	should.NoError(rbc.onEnvelope("node1", []byte("{}")), "own message is ignored")
+	should.Error(rbc.onEnvelope("node2", []byte("{}")), "message of other node is handled")
+}
+
+func TestServerClusterNodeDead(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 64)
+	addr := fakeClusterRedis(t, published)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	_, err := server.Adapter(&RedisAdapterOptions{
+		Addr:              addr,
+		Prefix:            "app",
+		NodeID:            "node1",
+		HeartbeatInterval: 10 * time.Millisecond,
+		HeartbeatTimeout:  300 * time.Millisecond,
+		RequestTimeout:    time.Minute,
+	})
+	must.NoError(err)
+
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	rbc := server.getNamespace("/").getBroadcast().(*redisBroadcast)
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", rbc)
+	nc.Join("lobby")
+
+	// this node answers request like redis would deliver it, node2 never answers
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case msg := <-published:
+				switch msg[0] {
+				case rbc.reqChannel:
+					rbc.onRequest([]byte(msg[1]))
+				case rbc.resChannel:
+					rbc.onResponse([]byte(msg[1]))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	must.Eventually(func() bool {
+		for _, node := range server.ClusterNodes() {
+			if node.ID == "node2" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	connections, err := rbc.lenContext(context.Background(), "lobby")
+	should.NoError(err, "dead node isn't waited for")
+	should.Equal(1, connections)
+	should.Less(time.Since(start), 5*time.Second, "request finishes once node is dead")
+
+	for _, node := range server.ClusterNodes() {
+		should.NotEqual("node2", node.ID)
+	}
+
+	rbc.requests.lock.Lock()
+	should.Empty(rbc.requests.requests, "finished request is released")
+	rbc.requests.lock.Unlock()
+}
+
+func TestServerClusterNodesReconnect(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var subscribed, publishes int32
+	published := make(chan string, 64)
+	// first subscription and first heartbeat of node1 lose their connections
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch {
+		case cmd[0] == "SUBSCRIBE" && cmd[1] == "app-nodes":
+			reply := "*3\r\n" + respArray("subscribe", cmd[1])[4:] + ":1\r\n"
+			if atomic.AddInt32(&subscribed, 1) == 1 {
+				return reply + "!lost\r\n"
+			}
+			return reply + respArray("message", cmd[1], `{"NodeID":"node2"}`)
+		case cmd[0] == "PUBLISH" && cmd[1] == "app-nodes":
+			if atomic.AddInt32(&publishes, 1) == 1 {
+				return "!lost\r\n"
+			}
+			published <- cmd[2]
+		}
+		return ":1\r\n"
+	})
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	_, err := server.Adapter(&RedisAdapterOptions{
+		Addr:              addr,
+		Prefix:            "app",
+		NodeID:            "node1",
+		HeartbeatInterval: 10 * time.Millisecond,
+		HeartbeatTimeout:  time.Minute,
+	})
+	must.NoError(err)
+
+	select {
+	case heartbeat := <-published:
+		should.JSONEq(`{"NodeID":"node1"}`, heartbeat, "heartbeat is published on redialed connection")
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat isn't published after connection is lost")
+	}
+
+	must.Eventually(func() bool {
+		for _, node := range server.ClusterNodes() {
+			if node.ID == "node2" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "heartbeats are received once subscription is restored")
+	should.GreaterOrEqual(atomic.LoadInt32(&subscribed), int32(2))
+}
@@ -0,0 +1,164 @@
+// Command sioload is a load generation harness for go-socket.io servers.
+//
+// It ramps up N client connections, emits an event from every connection at
+// the configured rate and reports ack latency percentiles. The target server
+// must acknowledge the event, e.g. by returning a value from its handler:
+//
+//	server.OnEvent("/", "bench", func(s socketio.Conn, msg string) string {
+//		return msg
+//	})
+//
+// Usage:
+//
+//	sioload -url http://127.0.0.1:8000 -conns 100 -ramp 10s -rate 5 -duration 1m
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	socketio "github.com/thisismz/go-socket.io"
+)
+
+type stats struct {
+	latencies []time.Duration
+	lock      sync.Mutex
+
+	sent    uint64
+	acked   uint64
+	failed  uint64
+	dropped uint64
+}
+
+func (s *stats) add(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.latencies = append(s.latencies, d)
+}
+
+func (s *stats) report(elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sort.Slice(s.latencies, func(i, j int) bool {
+		return s.latencies[i] < s.latencies[j]
+	})
+
+	fmt.Printf("duration: %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("connections failed: %d\n", atomic.LoadUint64(&s.failed))
+	fmt.Printf("sent: %d, acked: %d, unacked: %d\n",
+		atomic.LoadUint64(&s.sent), atomic.LoadUint64(&s.acked), atomic.LoadUint64(&s.dropped))
+
+	if elapsed > 0 {
+		fmt.Printf("throughput: %.1f acks/s\n", float64(atomic.LoadUint64(&s.acked))/elapsed.Seconds())
+	}
+
+	if len(s.latencies) == 0 {
+		return
+	}
+
+	for _, p := range []float64{50, 90, 95, 99, 99.9} {
+		fmt.Printf("p%-5v %s\n", p, percentile(s.latencies, p))
+	}
+	fmt.Printf("max    %s\n", s.latencies[len(s.latencies)-1])
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+func main() {
+	uri := flag.String("url", "http://127.0.0.1:8000", "server url, path selects the namespace")
+	conns := flag.Int("conns", 10, "number of connections")
+	ramp := flag.Duration("ramp", 5*time.Second, "time to open all the connections")
+	rate := flag.Float64("rate", 1, "emits per second per connection")
+	duration := flag.Duration("duration", 30*time.Second, "duration of the load after ramp up")
+	event := flag.String("event", "bench", "event name to emit")
+	size := flag.Int("size", 64, "payload size in bytes")
+	flag.Parse()
+
+	if *conns <= 0 || *rate <= 0 {
+		log.Fatalln("conns and rate must be positive")
+	}
+
+	payload := strings.Repeat("x", *size)
+	interval := time.Duration(float64(time.Second) / *rate)
+
+	var (
+		st   stats
+		wg   sync.WaitGroup
+		quit = make(chan struct{})
+	)
+
+	step := *ramp / time.Duration(*conns)
+	start := time.Now()
+
+	for i := 0; i < *conns; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			client, err := socketio.NewClient(*uri, nil)
+			if err != nil {
+				atomic.AddUint64(&st.failed, 1)
+				log.Println("new client:", err)
+				return
+			}
+
+			client.OnConnect(func(socketio.Conn) error {
+				return nil
+			})
+
+			if err = client.Connect(); err != nil {
+				atomic.AddUint64(&st.failed, 1)
+				log.Println("connect:", err)
+				return
+			}
+
+			defer func() {
+				_ = client.Close()
+			}()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-quit:
+					return
+				case <-ticker.C:
+				}
+
+				sentAt := time.Now()
+				atomic.AddUint64(&st.sent, 1)
+
+				client.Emit(*event, payload, func(string) {
+					atomic.AddUint64(&st.acked, 1)
+					st.add(time.Since(sentAt))
+				})
+			}
+		}()
+
+		time.Sleep(step)
+	}
+
+	log.Printf("ramped up %d connections in %s\n", *conns, time.Since(start).Round(time.Millisecond))
+
+	loadStart := time.Now()
+	time.Sleep(*duration)
+	close(quit)
+	wg.Wait()
+
+	elapsed := time.Since(loadStart)
+	atomic.StoreUint64(&st.dropped, atomic.LoadUint64(&st.sent)-atomic.LoadUint64(&st.acked))
+	st.report(elapsed)
+}
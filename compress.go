@@ -0,0 +1,75 @@
+package socketio
+
+import (
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// Emitter emits events of connection with modifiers, see Namespace.Compress.
+type Emitter interface {
+	Emit(eventName string, v ...interface{})
+	TryEmit(eventName string, v ...interface{}) error
+}
+
+// Compress gives emitter of events with or without per-message compression, which applies
+// only when transport negotiated it, e.g. websocket with EnableCompression. Events are
+// compressed by default.
+func (nc *namespaceConn) Compress(compress bool) Emitter {
+	return compressEmitter{nc: nc, noCompress: !compress}
+}
+
+type compressEmitter struct {
+	nc         *namespaceConn
+	noCompress bool
+}
+
+func (e compressEmitter) Emit(eventName string, v ...interface{}) {
+	e.nc.emitWith(writeOptions{noCompress: e.noCompress}, eventName, v...)
+}
+
+func (e compressEmitter) TryEmit(eventName string, v ...interface{}) error {
+	if err := e.nc.checkEmit(eventName); err != nil {
+		return err
+	}
+
+	e.Emit(eventName, v...)
+
+	return nil
+}
+
+// setCompression switches compression of engine connection for packet, it's called by the writer.
+// Compression is enabled back after uncompressed packet, so connections which never send one
+// aren't touched.
+func (c *conn) setCompression(pkg outgoingPacket) {
+	if !pkg.noCompress && !c.uncompressed {
+		return
+	}
+
+	if compressor, ok := c.Conn.(engineio.Compressor); ok {
+		compressor.SetWriteCompression(!pkg.noCompress)
+	}
+
+	c.uncompressed = pkg.noCompress
+}
+
+// StreamWrites makes writers of connections on websocket encode JSON of emitted args directly
+// into websocket frames, instead of encoding the whole packet in memory first, which reduces
+// peak memory of large broadcasts. Polling transport buffers payloads anyway, so its packets
+// are encoded as before, so are packets of parsers other than the default one.
+func (s *Server) StreamWrites(enable bool) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.streamWrites = enable
+
+	return nil
+}
+
+// setStreaming switches streaming of encoder for current transport, it's called by the writer.
+func (c *conn) setStreaming() {
+	if c.streamWrites {
+		if e, ok := c.encoder.(interface{ SetStreaming(bool) }); ok {
+			e.SetStreaming(c.Transport() == "websocket")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package socketio
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
+)
+
+type compressorEngineConn struct {
+	failingEngineConn
+	compression []bool
+}
+
+func (c *compressorEngineConn) SetWriteCompression(enable bool) {
+	c.compression = append(c.compression, enable)
+}
+
+func TestConnCompress(t *testing.T) {
+	should := assert.New(t)
+
+	engineConn := &compressorEngineConn{}
+	c := newConn(engineConn, newNamespaceHandlers())
+	nc := newNamespaceConn(c, "/chat", newBroadcast())
+	c.namespaces.Set("/chat", nc)
+
+	written := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			c.writePacket(<-c.writeChan)
+		}
+		close(written)
+	}()
+
+	nc.Emit("compressed")
+	nc.Compress(false).Emit("raw")
+	should.NoError(nc.Compress(false).TryEmit("raw"))
+	nc.Compress(true).Emit("compressed")
+	broadcastEmit(nc, &BroadcastOptions{NoCompress: true}, "raw")
+	<-written
+
+	should.Equal([]bool{false, false, true, false}, engineConn.compression,
+		"compression is switched only around uncompressed packets")
+	should.Equal(5, engineConn.writes)
+
+	should.NoError(c.Close())
+	should.ErrorIs(nc.Compress(false).TryEmit("raw"), ErrConnClosed)
+}
+
+func TestServerStreamWrites(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{websocket.Default},
+	})
+	must.NoError(server.StreamWrites(true))
+
+	rows := make([]map[string]interface{}, 5000)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": float64(i), "name": "row"}
+	}
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnEvent("/", "ask", func(conn Conn) {
+		conn.Emit("rows", rows)
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+	httpSvr := httptest.NewServer(server)
+	defer func() {
+		httpSvr.Close()
+		_ = server.Close()
+	}()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{websocket.Default},
+	})
+	must.NoError(err)
+
+	received := make(chan []map[string]interface{}, 1)
+	client.OnEvent("rows", func(_ Conn, rows []map[string]interface{}) {
+		received <- rows
+	})
+	must.NoError(client.Connect())
+	defer client.Close()
+	client.Emit("ask")
+
+	select {
+	case got := <-received:
+		should.Equal(rows, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamed packet isn't received")
+	}
+}
@@ -0,0 +1,313 @@
+package socketio
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// protocolClient speaks socket.io protocol packets directly, to check server conformance
+// without relying on Client behavior.
+type protocolClient struct {
+	conn    engineio.Conn
+	encoder *parser.Encoder
+	decoder *parser.Decoder
+}
+
+func newProtocolClient(t *testing.T, url string) *protocolClient {
+	dialer := engineio.Dialer{
+		Transports: []transport.Transport{polling.Default},
+	}
+
+	conn, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+
+	return &protocolClient{
+		conn:    conn,
+		encoder: parser.NewEncoder(conn),
+		decoder: parser.NewDecoder(conn),
+	}
+}
+
+func (c *protocolClient) send(t *testing.T, header parser.Header, args ...interface{}) {
+	var err error
+	if len(args) > 0 {
+		err = c.encoder.Encode(header, args)
+	} else {
+		err = c.encoder.Encode(header)
+	}
+	require.NoError(t, err)
+}
+
+func (c *protocolClient) receive(t *testing.T, types ...reflect.Type) (parser.Header, string, []interface{}) {
+	var header parser.Header
+	var event string
+
+	require.NoError(t, c.decoder.DecodeHeader(&header, &event))
+
+	values, err := c.decoder.DecodeArgs(types)
+	require.NoError(t, err)
+
+	return header, event, valuesToInterfaces(values)
+}
+
+func newConformanceServer(t *testing.T, configs ...func(*Server)) (*Server, string) {
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+	for _, config := range configs {
+		config(server)
+	}
+
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnEvent("/", "echo", func(_ Conn, msg string) string {
+		return msg
+	})
+	server.OnEvent("/", "binary", func(_ Conn, b *parser.Buffer) int {
+		return len(b.Data)
+	})
+	server.OnEvent("/", "ping", func(s Conn) {
+		s.Emit("pong", "from "+s.Namespace())
+	})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	server.OnEvent("/chat", "echo", func(s Conn, msg string) string {
+		return s.Namespace() + " " + msg
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+
+	httpSvr := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpSvr.Close()
+		_ = server.Close()
+	})
+
+	return server, httpSvr.URL
+}
+
+func TestConformanceConnect(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	header, _, _ := c.receive(t)
+	assert.Equal(t, parser.Connect, header.Type)
+	assert.Equal(t, "", header.Namespace)
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/chat"})
+
+	header, _, _ = c.receive(t)
+	assert.Equal(t, parser.Connect, header.Type)
+	assert.Equal(t, "/chat", header.Namespace)
+}
+
+func TestConformanceAck(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 7, NeedAck: true}, "echo", "hello")
+
+	header, _, args := c.receive(t, reflect.TypeOf(""))
+	assert.Equal(t, parser.Ack, header.Type)
+	assert.Equal(t, uint64(7), header.ID)
+	assert.Equal(t, []interface{}{"hello"}, args)
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/chat"})
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 8, NeedAck: true, Namespace: "/chat"}, "echo", "hello")
+
+	header, _, args = c.receive(t, reflect.TypeOf(""))
+	assert.Equal(t, parser.Ack, header.Type)
+	assert.Equal(t, "/chat", header.Namespace)
+	assert.Equal(t, uint64(8), header.ID)
+	assert.Equal(t, []interface{}{"/chat hello"}, args)
+}
+
+func TestConformanceBinary(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "binary", &parser.Buffer{Data: []byte{1, 2, 3}})
+
+	header, _, args := c.receive(t, reflect.TypeOf(0))
+	assert.Equal(t, parser.Ack, header.Type)
+	assert.Equal(t, []interface{}{3}, args)
+}
+
+func TestConformanceServerEmit(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event}, "ping")
+
+	header, event, args := c.receive(t, reflect.TypeOf(""))
+	assert.Equal(t, parser.Event, header.Type)
+	assert.Equal(t, "pong", event)
+	assert.Equal(t, []interface{}{"from /"}, args)
+}
+
+func TestConformanceUnknownNamespace(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/unknown"})
+
+	done := make(chan error, 1)
+	go func() {
+		var header parser.Header
+		var event string
+		done <- c.decoder.DecodeHeader(&header, &event)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection to unknown namespace wasn't closed")
+	}
+}
+
+// v5Client speaks socket.io protocol 5 over polling of engine.io protocol 4 as raw text, like
+// socket.io-client 3.x and 4.x.
+type v5Client struct {
+	t   *testing.T
+	url string
+	sid string
+}
+
+func newV5Client(t *testing.T, url string) *v5Client {
+	c := &v5Client{t: t, url: url + "/socket.io/?EIO=4&transport=polling"}
+
+	open := c.get()
+	require.True(t, strings.HasPrefix(open, "0"), open)
+
+	var params struct {
+		SID        string
+		MaxPayload int
+	}
+	require.NoError(t, json.Unmarshal([]byte(open[1:]), &params))
+	require.NotZero(t, params.MaxPayload)
+	c.sid = params.SID
+
+	return c
+}
+
+func (c *v5Client) request(method, body string) string {
+	u := c.url
+	if c.sid != "" {
+		u += "&sid=" + c.sid
+	}
+
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	require.NoError(c.t, err)
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(c.t, err)
+	require.Equal(c.t, http.StatusOK, resp.StatusCode, string(data))
+
+	return string(data)
+}
+
+// get gives the next engine.io packet sent by server, without newline which ends JSON of packet.
+func (c *v5Client) get() string {
+	return strings.TrimSuffix(c.request(http.MethodGet, ""), "\n")
+}
+
+// post sends engine.io packets, which are separated by record separator.
+func (c *v5Client) post(packets ...string) {
+	require.Equal(c.t, "ok", c.request(http.MethodPost, strings.Join(packets, "\x1e")))
+}
+
+func TestConformanceV5Connect(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newV5Client(t, url)
+
+	// root namespace isn't connected until client asks for it
+	c.post("40")
+	assert.Equal(t, `40{"sid":"`+c.sid+`"}`, c.get())
+
+	c.post("40/chat,")
+	assert.Equal(t, `40/chat,{"sid":"`+c.sid+`"}`, c.get())
+
+	c.post(`42/chat,5["echo","hi"]`)
+	assert.Equal(t, `43/chat,5["/chat hi"]`, c.get())
+
+	c.post(`42["ping"]`)
+	assert.Equal(t, `42["pong","from /"]`, c.get())
+}
+
+func TestConformanceV5Binary(t *testing.T) {
+	_, url := newConformanceServer(t)
+
+	c := newV5Client(t, url)
+	c.post("40")
+	_ = c.get()
+
+	// attachments are base64 packets of payload
+	c.post(`451-1["binary",{"_placeholder":true,"num":0}]`, "bAQID")
+	assert.Equal(t, "431[3]", c.get())
+}
+
+func TestConformanceV5ConnectError(t *testing.T) {
+	_, url := newConformanceServer(t, func(server *Server) {
+		// root of client of protocol 5 gets auth of CONNECT packet
+		server.Use("/", func(_ Conn, auth map[string]interface{}, next func(error)) {
+			if auth["token"] != "secret" {
+				next(&ConnectError{Message: "unauthorized"})
+				return
+			}
+			next(nil)
+		})
+	})
+
+	c := newV5Client(t, url)
+
+	c.post("40")
+	assert.Equal(t, `44{"message":"unauthorized"}`, c.get())
+
+	c.post(`40{"token":"secret"}`)
+	assert.Equal(t, `40{"sid":"`+c.sid+`"}`, c.get())
+}
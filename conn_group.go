@@ -0,0 +1,144 @@
+package socketio
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// LeakReport describes goroutines of closed connection which are still running, see
+// Server.DetectLeaks.
+type LeakReport struct {
+	ConnID string
+	// Goroutines are names of goroutines which are running, "read", "write" or "error".
+	Goroutines []string
+	// ClosedFor is time since connection was closed.
+	ClosedFor time.Duration
+}
+
+// DetectLeaks enables debug mode which checks that goroutines of each connection terminate
+// within timeout once connection is closed, e.g. writer which is blocked by transport. report
+// is called for connections whose goroutines are still running, they're logged when it's nil.
+// It costs goroutines for each connection, so it's meant for debugging.
+func (s *Server) DetectLeaks(timeout time.Duration, report func(LeakReport)) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	if report == nil {
+		report = func(r LeakReport) {
+			logger.Info("goroutines of closed connection are running", "sid", r.ConnID,
+				"goroutines", r.Goroutines, "closedFor", r.ClosedFor.String())
+		}
+	}
+
+	s.leakTimeout = timeout
+	s.leakReport = report
+
+	return nil
+}
+
+// ConnGoroutines gives number of running goroutines of connections, three for each served
+// connection until it's closed.
+func (s *Server) ConnGoroutines() int64 {
+	return atomic.LoadInt64(&s.connGoroutines)
+}
+
+// connGroup runs goroutines of connection, the first one which returns cancels the others by
+// closing connection, so they terminate together.
+type connGroup struct {
+	cancel  func()
+	counter *int64
+
+	wg      sync.WaitGroup
+	once    sync.Once
+	err     error
+	running map[string]bool
+	lock    sync.Mutex
+}
+
+func newConnGroup(cancel func(), counter *int64) *connGroup {
+	return &connGroup{
+		cancel:  cancel,
+		counter: counter,
+		running: make(map[string]bool),
+	}
+}
+
+// Go runs f in goroutine of name.
+func (g *connGroup) Go(name string, f func() error) {
+	g.lock.Lock()
+	g.running[name] = true
+	g.lock.Unlock()
+
+	g.wg.Add(1)
+	atomic.AddInt64(g.counter, 1)
+
+	go func() {
+		defer g.wg.Done()
+		defer atomic.AddInt64(g.counter, -1)
+
+		err := f()
+
+		g.lock.Lock()
+		delete(g.running, name)
+		g.lock.Unlock()
+
+		g.once.Do(func() {
+			g.err = err
+			g.cancel()
+		})
+	}()
+}
+
+// Wait waits for goroutines and gives error of the first one which returned.
+func (g *connGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+// Running gives sorted names of running goroutines.
+func (g *connGroup) Running() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	names := make([]string, 0, len(g.running))
+	for name := range g.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// watchLeaks reports goroutines of group which are running timeout after connection closed.
+func (s *Server) watchLeaks(c *conn, g *connGroup) {
+	done := make(chan struct{})
+	go func() {
+		_ = g.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-c.quitChan:
+	}
+
+	closed := time.Now()
+	timer := time.NewTimer(s.leakTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		s.leakReport(LeakReport{
+			ConnID:     c.Conn.ID(),
+			Goroutines: g.Running(),
+			ClosedFor:  time.Since(closed),
+		})
+	}
+}
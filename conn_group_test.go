@@ -0,0 +1,93 @@
+package socketio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnGroup(t *testing.T) {
+	should := assert.New(t)
+
+	var (
+		counter  int64
+		canceled int
+	)
+	quit := make(chan struct{})
+	g := newConnGroup(func() {
+		canceled++
+		close(quit)
+	}, &counter)
+
+	failed := errors.New("decode header")
+	release := make(chan struct{})
+
+	g.Go("read", func() error {
+		<-release
+		return failed
+	})
+	g.Go("write", func() error {
+		<-quit
+		return nil
+	})
+	should.Equal([]string{"read", "write"}, g.Running())
+
+	close(release)
+	should.Equal(failed, g.Wait())
+	should.Equal(1, canceled)
+	should.Empty(g.Running())
+	should.Zero(counter)
+}
+
+func TestDetectLeaks(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := &Server{}
+
+	reports := make(chan LeakReport, 1)
+	should.NoError(server.DetectLeaks(10*time.Millisecond, func(r LeakReport) {
+		reports <- r
+	}))
+
+	c := newConn(addrEngineConn{id: "sid1"}, nil)
+	g := newConnGroup(func() {}, &server.connGoroutines)
+
+	blocked := make(chan struct{})
+	g.Go("error", func() error {
+		<-c.quitChan
+		return nil
+	})
+	g.Go("write", func() error {
+		<-blocked
+		return nil
+	})
+	should.Equal(int64(2), server.ConnGoroutines())
+
+	go server.watchLeaks(c, g)
+
+	// leak is reported once connection is closed
+	select {
+	case <-reports:
+		t.Fatal("open connection is reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(c.quitChan)
+
+	select {
+	case r := <-reports:
+		should.Equal("sid1", r.ConnID)
+		should.Equal([]string{"write"}, r.Goroutines)
+		should.GreaterOrEqual(r.ClosedFor, 10*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("leak isn't reported")
+	}
+
+	close(blocked)
+	must.NoError(g.Wait())
+	should.Zero(server.ConnGoroutines())
+}
@@ -1,12 +1,17 @@
 package socketio
 
 import (
+	"context"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slog"
 
 	"github.com/thisismz/go-socket.io/engineio"
 	"github.com/thisismz/go-socket.io/parser"
@@ -23,6 +28,33 @@ type Conn interface {
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
 	RemoteHeader() http.Header
+
+	// Closed reports whether connection is closed or being closed.
+	Closed() bool
+	// Transport gives name of current engine.io transport, "polling" or "websocket",
+	// it changes once connection is upgraded.
+	Transport() string
+	// ConnectedAt gives time when connection connected to namespace.
+	ConnectedAt() time.Time
+
+	// SetReadOnly makes connection an observer whose events aren't handled, ReadOnly reports it.
+	SetReadOnly(readOnly bool)
+	ReadOnly() bool
+
+	// PauseReads stops reading frames of connection until ResumeReads, ReadsPaused reports it.
+	PauseReads()
+	ResumeReads()
+	ReadsPaused() bool
+
+	// QueueDepth gives number of packets waiting for the writer of connection.
+	QueueDepth() int
+
+	// EmitWithAck emits event and waits for its ack until ctx is done.
+	EmitWithAck(ctx context.Context, eventName string, v ...interface{}) ([]interface{}, error)
+
+	// Logger returns logger bound with sid, namespace and remote address of connection,
+	// it's derived from logger.Log.
+	Logger() *slog.Logger
 }
 
 type conn struct {
@@ -32,13 +64,86 @@ type conn struct {
 	handlers   *namespaceHandlers
 	namespaces *namespaces
 
-	encoder *parser.Encoder
-	decoder *parser.Decoder
+	encoder parser.PacketEncoder
+	decoder parser.PacketDecoder
+
+	executor *keyedExecutor
+
+	// resume is set by server when resume tokens are enabled.
+	resume *resumeStore
+
+	// outgoing transforms arguments of emitted events, it's set by server, see OutgoingHook.
+	outgoing OutgoingHook
+
+	// capture samples event payloads, it's set by server, see PayloadCapture.
+	capture *payloadCapture
+
+	// observeEvent is called by server after each event handler, see MetricsHook.
+	observeEvent func(conn Conn, metrics EventMetrics)
+
+	// deliveryFailure and broadcastTimeout are set by server, see OnDeliveryFailure.
+	deliveryFailure  DeliveryFailureFunc
+	broadcastTimeout time.Duration
+
+	// chunking splits and reassembles large payloads, see ChunkOptions.
+	chunking *chunker
+
+	// egress shapes bandwidth of writer, it's set by server, see EgressShaping.
+	egress *tokenBucket
+
+	// readBudget accounts payloads of received events, it's set by server, see SetReadBudget.
+	readBudget *readBudget
+	// dispatchPool runs event handlers when it's set by server, see SetDispatchPool.
+	dispatchPool *dispatchPool
+	// ackExpiry is set by server, see SetAckExpiry.
+	ackExpiry time.Duration
+
+	// queueDepths records depths of write queue, it's set by server, see Server.QueueDepths.
+	queueDepths *queueDepths
+
+	// readPause blocks reader while application paused reads, see namespaceConn.PauseReads.
+	readPause readPause
 
-	writeChan chan parser.Payload
+	// edgeCases are socket.io v5 edge cases handled by connection, pendingAckIDs are ack ids of
+	// received events which aren't acked yet, see ProtocolEdgeCases.
+	edgeCases     ProtocolEdgeCases
+	pendingAckIDs sync.Map
+
+	// route is namespace which serves root namespace of client, see Server.RouteNamespaces.
+	route string
+
+	// v5 is set for clients of engine.io protocol 4, which speak socket.io protocol 5, they
+	// connect root namespace with CONNECT packet like other namespaces and get sid in reply.
+	v5 bool
+
+	// stats counts traffic for disconnect details, see Server.OnDisconnectDetails.
+	stats connStats
+
+	// uncompressed is set by the writer while compression of engine connection is disabled.
+	uncompressed bool
+	// streamWrites is set by server, see Server.StreamWrites.
+	streamWrites bool
+
+	writeChan chan outgoingPacket
 	errorChan chan error
 	quitChan  chan struct{}
 
+	// flushChan is received by client writer between packets, see flush.
+	flushChan chan struct{}
+
+	// onNamespaceConnect is called by client once server accepts namespace connect.
+	onNamespaceConnect func(namespace string, payload map[string]interface{})
+
+	// tracker counts event handlers in flight, Close waits for them before disconnect
+	// handlers when waitForHandlers is set by server, see Server.WaitForHandlers.
+	tracker         *handlerTracker
+	waitForHandlers bool
+
+	// closing is set once connection starts closing, it's written under closeLock,
+	// so rooms joined by TryJoin are always left by Close.
+	closing   int32
+	closeLock sync.RWMutex
+
 	closeOnce sync.Once
 }
 
@@ -47,44 +152,99 @@ func newConn(engineConn engineio.Conn, handlers *namespaceHandlers) *conn {
 		Conn:       engineConn,
 		encoder:    parser.NewEncoder(engineConn),
 		decoder:    parser.NewDecoder(engineConn),
+		executor:   newKeyedExecutor(defaultKeyedLanes, defaultKeyedQueueSize, nil),
 		errorChan:  make(chan error),
-		writeChan:  make(chan parser.Payload),
+		writeChan:  make(chan outgoingPacket),
 		quitChan:   make(chan struct{}),
+		flushChan:  make(chan struct{}),
 		handlers:   handlers,
 		namespaces: newNamespaces(),
+		tracker:    newHandlerTracker(),
 	}
 }
 
+// setParser makes connection encode and decode packets with p, nil keeps the default parser.
+func (c *conn) setParser(p parser.Parser) {
+	if p == nil {
+		return
+	}
+
+	c.encoder = p.NewEncoder(c.Conn)
+	c.decoder = p.NewDecoder(c.Conn)
+}
+
 func (c *conn) Close() error {
 	var err error
 
 	c.closeOnce.Do(func() {
-		// for each namespace, leave all rooms, and call the disconnect handler.
+		// for each namespace, leave all rooms, so connection can't join them again.
+		c.closeLock.Lock()
+		atomic.StoreInt32(&c.closing, 1)
 		c.namespaces.Range(func(ns string, nc *namespaceConn) {
+			c.resume.save(nc)
 			nc.LeaveAll()
-
-			if nh, _ := c.handlers.Get(ns); nh != nil && nh.onDisconnect != nil {
-				nh.onDisconnect(nc, clientDisconnectMsg)
-			}
 		})
+		c.closeLock.Unlock()
+
+		// then call the disconnect handlers, which see connection as closed.
+		details := c.disconnectDetails()
+		if c.waitForHandlers {
+			go func() {
+				c.waitHandlers()
+				c.disconnected(details)
+			}()
+		} else {
+			c.disconnected(details)
+		}
 		err = c.Conn.Close()
 
 		close(c.quitChan)
+		c.readBudget.wake()
+		c.dispatchPool.wake()
+		c.executor.close()
 	})
 
 	return err
 }
 
+// isClosing reports whether connection is closed or being closed.
+func (c *conn) isClosing() bool {
+	return atomic.LoadInt32(&c.closing) == 1
+}
+
+// Transport gives name of transport of engine connection, empty when it's unknown.
+func (c *conn) Transport() string {
+	if t, ok := c.Conn.(interface{ Transport() string }); ok {
+		return t.Transport()
+	}
+
+	return ""
+}
+
+func (c *conn) closed() bool {
+	select {
+	case <-c.quitChan:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *conn) connect() error {
-	rootHandler, ok := c.handlers.Get(rootNamespace)
+	rootHandler, ok := c.handlers.Await(rootNamespace)
 	if !ok {
 		return errUnavailableRootHandler
 	}
 
-	root := newNamespaceConn(c, aliasRootNamespace, rootHandler.broadcast)
+	if c.v5 {
+		return nil
+	}
+
+	root := newNamespaceConn(c, aliasRootNamespace, rootHandler.getBroadcast())
+	root.variant = rootHandler.pickVariant(root)
 	c.namespaces.Set(rootNamespace, root)
 
-	root.Join(root.Conn.ID())
+	rootHandler.bindConn(root)
 
 	c.namespaces.Range(func(ns string, nc *namespaceConn) {
 		nc.SetContext(c.Conn.Context())
@@ -94,6 +254,15 @@ func (c *conn) connect() error {
 		Type: parser.Connect,
 	}
 
+	if err := rootHandler.runMiddlewares(root, nil, c.quitChan); err != nil {
+		header.Type = parser.Error
+		if encodeErr := c.encoder.Encode(header, []interface{}{middlewareRefusal(err).Interface()}); encodeErr != nil {
+			return encodeErr
+		}
+
+		return err
+	}
+
 	if err := c.encoder.Encode(header); err != nil {
 		return err
 	}
@@ -107,31 +276,84 @@ func (c *conn) connect() error {
 	return nil
 }
 
+// nextID gives id of ack, emits which need ack are made by any goroutine.
 func (c *conn) nextID() uint64 {
-	c.id++
-
-	return c.id
+	return atomic.AddUint64(&c.id, 1)
 }
 
 func (c *conn) write(header parser.Header, args ...reflect.Value) {
+	c.writeWith(header, writeOptions{}, args...)
+}
+
+// writeWith queues packet for the writer, packet which isn't taken by the writer
+// before non-zero deadline of opts is reported as failed delivery.
+func (c *conn) writeWith(header parser.Header, opts writeOptions, args ...reflect.Value) {
 	data := make([]interface{}, len(args))
 
 	for i := range data {
 		data[i] = args[i].Interface()
 	}
 
-	pkg := parser.Payload{
-		Header: header,
-		Data:   data,
+	if header.Type == parser.Ack {
+		c.releaseAckID(header)
+	}
+
+	pkg := outgoingPacket{
+		Payload: parser.Payload{
+			Header: header,
+			Data:   data,
+		},
+		writeOptions: opts,
+	}
+
+	depth := atomic.AddInt64(&c.stats.queued, 1)
+	defer atomic.AddInt64(&c.stats.queued, -1)
+	c.queueDepths.observe(c, header.Namespace, int(depth))
+
+	var timeout <-chan time.Time
+	if !opts.deadline.IsZero() {
+		timer := time.NewTimer(time.Until(opts.deadline))
+		defer timer.Stop()
+
+		timeout = timer.C
 	}
 
 	select {
 	case c.writeChan <- pkg:
+	case <-timeout:
+		c.deliveryFailed(pkg, 0, ErrWriteTimeout)
+	case <-opts.done():
+		c.deliveryFailed(pkg, 0, opts.cancelled())
 	case <-c.quitChan:
 		return
 	}
 }
 
+// flush waits until packets written before are encoded by client writer.
+func (c *conn) flush(ctx context.Context) error {
+	select {
+	case c.flushChan <- struct{}{}:
+		return nil
+	case <-c.quitChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pendingAcks returns count of emitted events waiting for ack.
+func (c *conn) pendingAcks() int {
+	var n int
+	c.namespaces.Range(func(_ string, nc *namespaceConn) {
+		nc.ack.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+	})
+
+	return n
+}
+
 func (c *conn) onError(namespace string, err error) {
 	select {
 	case c.errorChan <- newErrorMessage(namespace, err):
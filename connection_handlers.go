@@ -2,6 +2,8 @@ package socketio
 
 import (
 	"log"
+	"reflect"
+	"time"
 
 	"github.com/thisismz/go-socket.io/logger"
 	"github.com/thisismz/go-socket.io/parser"
@@ -12,29 +14,35 @@ var emtpyFH = newAckFunc(func() {})
 func ackPacketHandler(c *conn, header parser.Header) error {
 	nc, ok := c.namespaces.Get(header.Namespace)
 	if !ok {
-		_ = c.decoder.DiscardLast()
+		_ = c.discardPacket()
 		return nil
 	}
 
-	defer nc.ack.Delete(header.ID)
-
-	rawFunc, ok := nc.ack.Load(header.ID)
+	rawFunc, ok := nc.ack.LoadAndDelete(header.ID)
 	if !ok {
 		// No function for this ack, but still need to read body
 		rawFunc = emtpyFH
+	} else {
+		nc.ackReceived(header.ID)
 	}
 
 	handler, ok := rawFunc.(*funcHandler)
 	if !ok {
 		// This should never get here and would be solved with generic sync.Map
-		logger.Info("Incorrect Ack functinxo type")
+		nc.Logger().Info("Incorrect Ack functinxo type")
 		handler = emtpyFH // keep going
 	}
 
 	// Read the body because Ack can have body as well
-	args, err := c.decoder.DecodeArgs(handler.argTypes)
+	var args []reflect.Value
+	var err error
+	if handler.variadic {
+		args, err = c.decoder.DecodeVariadicArgs(handler.argTypes)
+	} else {
+		args, err = c.decoder.DecodeArgs(handler.argTypes)
+	}
 	if err != nil {
-		logger.Info("Error decoding the ACK message type", "namespace", header.Namespace, "eventType", handler.argTypes, "err", err.Error())
+		nc.Logger().Info("Error decoding the ACK message type", "eventType", handler.argTypes, "err", err.Error())
 		c.onError(header.Namespace, err)
 		return errDecodeArgs
 	}
@@ -42,7 +50,7 @@ func ackPacketHandler(c *conn, header parser.Header) error {
 	// Return value is ignored
 	_, err = handler.Call(args)
 	if err != nil {
-		logger.Info("Error for event type", "namespace", header.Namespace)
+		nc.Logger().Info("Error for event type")
 		c.onError(header.Namespace, err)
 		return errHandleDispatch
 	}
@@ -53,28 +61,125 @@ func ackPacketHandler(c *conn, header parser.Header) error {
 func eventPacketHandler(c *conn, event string, header parser.Header) error {
 	conn, ok := c.namespaces.Get(header.Namespace)
 	if !ok {
-		_ = c.decoder.DiscardLast()
+		_ = c.discardPacket()
 		return nil
 	}
 
 	handler, ok := c.handlers.Get(header.Namespace)
 	if !ok {
-		_ = c.decoder.DiscardLast()
+		_ = c.discardPacket()
 		logger.Info("missing handler for namespace", "namespace", header.Namespace)
 		return nil
 	}
 
-	args, err := c.decoder.DecodeArgs(handler.getEventTypes(event))
+	if event == ChunkEvent && c.chunking != nil {
+		return chunkPacketHandler(c, conn, handler, header)
+	}
+
+	args, err := c.decoder.DecodeArgs(handler.eventTypes(conn, event))
 	if err != nil {
 		c.onError(header.Namespace, err)
-		logger.Info("Error decoding the message type", "namespace", header.Namespace, "event", event, "eventType", handler.getEventTypes(event), "err", err.Error())
+		conn.Logger().Info("Error decoding the message type", "event", event, "eventType", handler.eventTypes(conn, event), "err", err.Error())
 		return errDecodeArgs
 	}
 
+	if !c.claimAckID(header) {
+		c.onError(header.Namespace, ErrAckIDReused)
+		return nil
+	}
+
+	return handleEventPacket(c, conn, handler, event, header, args, c.decoder.LastSize())
+}
+
+// handleEventPacket captures event and dispatches it, in order of its key when event has one.
+func handleEventPacket(c *conn, conn *namespaceConn, handler *namespaceHandler, event string, header parser.Header, args []reflect.Value, size int) error {
+	if conn.ReadOnly() {
+		return rejectReadOnly(c, conn, event, header, size)
+	}
+
+	if !handler.isDeclared(event) {
+		return rejectUndeclaredEvent(c, conn, event, header, size)
+	}
+
+	if limit := handler.getPayloadLimit(conn); limit > 0 && size > limit {
+		return rejectPayload(c, conn, event, header, size, limit)
+	}
+
+	if !handler.allowEvent(conn) {
+		return rejectRateLimited(c, conn, event, header, size)
+	}
+
+	if c.capture.sampled(event) {
+		c.capture.capture(CaptureInbound, conn, event, valuesToInterfaces(args))
+	}
+
+	// events received once connection is closing aren't handled
+	if !c.handlerStarted() {
+		return nil
+	}
+
+	if o := handler.getOffload(event); o != nil {
+		defer c.handlerDone()
+
+		o.offload(c, conn, event, header, args)
+		return nil
+	}
+
+	// payload is in flight until its handler returns
+	c.readBudget.acquire(c, size)
+
+	if keyFunc := handler.getEventKey(event); keyFunc != nil && c.executor != nil {
+		key := keyFunc(conn, event, valuesToInterfaces(args))
+		c.executor.Submit(key, func() {
+			defer c.handlerDone()
+			defer c.readBudget.release(c, size)
+
+			if err := dispatchEventPacket(c, conn, handler, event, header, args, size); err != nil {
+				logger.Error("dispatch event:", err)
+				_ = c.Close()
+			}
+		})
+
+		return nil
+	}
+
+	if c.dispatchPool != nil {
+		c.dispatchPool.submit(c, func() {
+			defer c.handlerDone()
+			defer c.readBudget.release(c, size)
+
+			if err := dispatchEventPacket(c, conn, handler, event, header, args, size); err != nil {
+				logger.Error("dispatch event:", err)
+				_ = c.Close()
+			}
+		})
+
+		return nil
+	}
+
+	defer c.handlerDone()
+	defer c.readBudget.release(c, size)
+
+	return dispatchEventPacket(c, conn, handler, event, header, args, size)
+}
+
+func dispatchEventPacket(c *conn, conn *namespaceConn, handler *namespaceHandler, event string, header parser.Header, args []reflect.Value, size int) error {
+	start := time.Now()
 	ret, err := handler.dispatchEvent(conn, event, args...)
+	if c.observeEvent != nil {
+		c.observeEvent(conn, EventMetrics{
+			Namespace:   conn.Namespace(),
+			Event:       event,
+			Duration:    time.Since(start),
+			PayloadSize: size,
+			Err:         err,
+		})
+	}
+
 	if err != nil {
+		c.releaseAckID(header)
 		c.onError(header.Namespace, err)
-		logger.Info("Error for event type", "namespace", header.Namespace, "event", event)
+		conn.Logger().Info("Error for event type", "event", event)
 		return errHandleDispatch
 	}
 
@@ -86,33 +191,80 @@ func eventPacketHandler(c *conn, event string, header parser.Header) error {
 	return nil
 }
 
+func valuesToInterfaces(values []reflect.Value) []interface{} {
+	ret := make([]interface{}, len(values))
+	for i := range values {
+		ret[i] = values[i].Interface()
+	}
+
+	return ret
+}
+
 func connectPacketHandler(c *conn, header parser.Header) error {
-	if err := c.decoder.DiscardLast(); err != nil {
-		c.onError(header.Namespace, err)
-		logger.Info("connectPacketHandler DiscardLast", err, "namespace", header.Namespace)
-		return nil
+	// auth which isn't an object is ignored, it's nil for middlewares and connection isn't resumed.
+	args, _ := c.decoder.DecodeArgs(connectPayloadType)
+	var claim resumeClaim
+	if c.resume != nil {
+		claim = newResumeClaim(args)
 	}
 
-	handler, ok := c.handlers.Get(header.Namespace)
+	handler, ok := c.handlers.Await(header.Namespace)
 	if !ok {
 		c.onError(header.Namespace, errFailedConnectNamespace)
 		logger.Info("connectPacketHandler get namespace handler", "namespace", header.Namespace)
 		return errFailedConnectNamespace
 	}
 
+	if handler.isDisabled() {
+		return rejectDisabledNamespace(c, header)
+	}
+
+	u := c.Conn.URL()
+	if queryErr := handler.validateQuery(header.Namespace, u.Query()); queryErr != nil {
+		return rejectInvalidQuery(c, header, queryErr)
+	}
+
 	conn, ok := c.namespaces.Get(header.Namespace)
 	if !ok {
-		conn = newNamespaceConn(c, header.Namespace, handler.broadcast)
+		if header.Namespace == rootNamespace {
+			// root of client of protocol 5, which isn't connected by conn.connect
+			conn = newNamespaceConn(c, aliasRootNamespace, handler.getBroadcast())
+			conn.SetContext(c.Conn.Context())
+		} else {
+			conn = newNamespaceConn(c, header.Namespace, handler.getBroadcast())
+		}
+		conn.variant = handler.pickVariant(conn)
 		c.namespaces.Set(header.Namespace, conn)
-		conn.Join(c.Conn.ID())
+		handler.bindConn(conn)
+
+		if err := handler.runMiddlewares(conn, connectPayload(args), c.quitChan); err != nil {
+			return rejectByMiddleware(c, conn, header, err)
+		}
 	}
 
-	_, err := handler.dispatch(conn, header)
-	if err != nil {
-		logger.Info("connectPacketHandler dispatch error", "namespace", header.Namespace)
-		log.Println("dispatch connect packet", err)
-		c.onError(header.Namespace, err)
-		return errHandleDispatch
+	resumed := c.resume.restore(conn, claim)
+
+	if !resumed || !c.resume.skipConnectHandler {
+		_, err := handler.dispatch(conn, header)
+		if err != nil {
+			conn.Logger().Info("connectPacketHandler dispatch error")
+			log.Println("dispatch connect packet", err)
+			c.onError(header.Namespace, err)
+			return errHandleDispatch
+		}
+	}
+
+	if c.resume != nil {
+		c.write(header, reflect.ValueOf(map[string]interface{}{
+			"sid": c.Conn.ID(),
+			"pid": c.resume.issue(conn, claim.epoch),
+		}))
+		return nil
+	}
+
+	if c.v5 {
+		c.write(header, reflect.ValueOf(map[string]interface{}{"sid": c.Conn.ID()}))
+		return nil
 	}
 
 	c.write(header)
@@ -120,8 +272,36 @@ func connectPacketHandler(c *conn, header parser.Header) error {
 	return nil
 }
 
+// connectPayload returns decoded CONNECT packet payload, nil when it isn't an object.
+func connectPayload(args []reflect.Value) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	payload, _ := args[0].Interface().(map[string]interface{})
+
+	return payload
+}
+
+// connectPayloadString returns string value of key in decoded CONNECT packet payload.
+func connectPayloadString(args []reflect.Value, key string) string {
+	v, _ := connectPayload(args)[key].(string)
+
+	return v
+}
+
+// connectPayloadUint returns non-negative integer value of key in decoded CONNECT packet payload.
+func connectPayloadUint(args []reflect.Value, key string) uint64 {
+	v, ok := connectPayload(args)[key].(float64)
+	if !ok || v < 0 {
+		return 0
+	}
+
+	return uint64(v)
+}
+
 func disconnectPacketHandler(c *conn, header parser.Header) error {
-	args, err := c.decoder.DecodeArgs(defaultHeaderType)
+	args, err := c.disconnectArgs()
 	if err != nil {
 		c.onError(header.Namespace, err)
 		return errDecodeArgs
@@ -133,6 +313,8 @@ func disconnectPacketHandler(c *conn, header parser.Header) error {
 		return nil
 	}
 
+	// client left namespace on purpose, so there is nothing to resume.
+	c.resume.forget(conn)
 	conn.LeaveAll()
 
 	c.namespaces.Delete(header.Namespace)
@@ -157,11 +339,8 @@ func disconnectPacketHandler(c *conn, header parser.Header) error {
 // ////////////////////
 
 func clientConnectPacketHandler(c *conn, header parser.Header) error {
-	if err := c.decoder.DiscardLast(); err != nil {
-		logger.Info("connectPacketHandler DiscardLast", err, "namespace", header.Namespace)
-		c.onError(header.Namespace, err)
-		return nil
-	}
+	// payload which isn't an object is ignored, e.g. of servers which don't issue resume tokens.
+	args, _ := c.decoder.DecodeArgs(connectPayloadType)
 
 	handler, ok := c.handlers.Get(header.Namespace)
 	if !ok {
@@ -172,24 +351,28 @@ func clientConnectPacketHandler(c *conn, header parser.Header) error {
 
 	conn, ok := c.namespaces.Get(header.Namespace)
 	if !ok {
-		conn = newNamespaceConn(c, header.Namespace, handler.broadcast)
+		conn = newNamespaceConn(c, header.Namespace, handler.getBroadcast())
 		c.namespaces.Set(header.Namespace, conn)
-		conn.Join(c.Conn.ID())
+		handler.bindConn(conn)
 	}
 
 	_, err := handler.dispatch(conn, header)
 	if err != nil {
-		logger.Info("connectPacketHandler  dispatch", "namespace", header.Namespace)
+		conn.Logger().Info("connectPacketHandler  dispatch")
 		log.Println("dispatch connect packet", err)
 		c.onError(header.Namespace, err)
 		return errHandleDispatch
 	}
 
+	if c.onNamespaceConnect != nil {
+		c.onNamespaceConnect(header.Namespace, connectPayload(args))
+	}
+
 	return nil
 }
 
 func clientDisconnectPacketHandler(c *conn, header parser.Header) error {
-	args, err := c.decoder.DecodeArgs(defaultHeaderType)
+	args, err := c.disconnectArgs()
 	if err != nil {
 		c.onError(header.Namespace, err)
 		return errDecodeArgs
@@ -0,0 +1,167 @@
+package socketio
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ContextBroadcaster broadcasts on behalf of a caller with context, e.g. HTTP handler,
+// see Server.WithContext.
+type ContextBroadcaster struct {
+	server *Server
+	ctx    context.Context
+}
+
+// contextPublisher is implemented by broadcasts which deliver broadcasts to other nodes.
+type contextPublisher interface {
+	publishBroadcast(room string, set *RoomSet, deadline time.Time, event string, args ...interface{}) error
+}
+
+// roomQuerier is implemented by broadcasts which query rooms of other nodes.
+type roomQuerier interface {
+	lenContext(ctx context.Context, room string) (int, error)
+	allRoomsContext(ctx context.Context) ([]string, error)
+}
+
+// WithContext gives broadcaster bound to ctx, so broadcasts triggered by a request respect its
+// cancellation. Writes to connections which aren't taken before ctx is done or its deadline
+// are reported as failed deliveries, see OnDeliveryFailure. Broadcast isn't published through
+// adapter once ctx is done, deadline of ctx is carried to other nodes, which apply it to their
+// writes.
+func (s *Server) WithContext(ctx context.Context) *ContextBroadcaster {
+	return &ContextBroadcaster{server: s, ctx: ctx}
+}
+
+// BroadcastToRoom broadcasts given event & args to all the connections in the room, it returns
+// error when namespace doesn't exist, event doesn't match schema of the room, or ctx is done.
+func (b *ContextBroadcaster) BroadcastToRoom(namespace, room, event string, args ...interface{}) error {
+	nspHandler, err := b.namespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	if err = nspHandler.validateRoomEvent(room, event, args); err != nil {
+		return err
+	}
+
+	nspHandler.getBroadcast().ForEach(room, b.emit(event, args))
+
+	return b.publish(namespace, nspHandler, room, nil, event, args)
+}
+
+// BroadcastToNamespace broadcasts given event & args to all the connections in the namespace,
+// each connection receives the event once.
+func (b *ContextBroadcaster) BroadcastToNamespace(namespace, event string, args ...interface{}) error {
+	nspHandler, err := b.namespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	emit := b.emit(event, args)
+	sent := make(map[string]struct{})
+	for _, room := range nspHandler.getBroadcast().AllRooms() {
+		nspHandler.getBroadcast().ForEach(room, func(connection Conn) {
+			if _, ok := sent[connection.ID()]; ok {
+				return
+			}
+			sent[connection.ID()] = struct{}{}
+
+			emit(connection)
+		})
+	}
+
+	return b.publish(namespace, nspHandler, "", nil, event, args)
+}
+
+// BroadcastToRoomSet broadcasts given event & args to all the connections selected by the room set,
+// it returns ErrRoomSetUnsupported when broadcaster of namespace isn't RoomSetBroadcast.
+func (b *ContextBroadcaster) BroadcastToRoomSet(namespace string, set *RoomSet, event string, args ...interface{}) error {
+	nspHandler, err := b.namespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	bc, ok := nspHandler.getBroadcast().(RoomSetBroadcast)
+	if !ok {
+		return ErrRoomSetUnsupported
+	}
+
+	bc.ForEachRoomSet(set, b.emit(event, args))
+
+	return b.publish(namespace, nspHandler, "", set, event, args)
+}
+
+// RoomLen gives number of connections in the room on every node, it stops waiting for other
+// nodes once ctx is done or RequestTimeout of adapter passed, and returns count of nodes which
+// responded with error then.
+func (b *ContextBroadcaster) RoomLen(namespace, room string) (int, error) {
+	nspHandler, err := b.namespace(namespace)
+	if err != nil {
+		return -1, err
+	}
+
+	if querier, ok := nspHandler.getBroadcast().(roomQuerier); ok {
+		return querier.lenContext(b.ctx, room)
+	}
+
+	return nspHandler.getBroadcast().Len(room), nil
+}
+
+// Rooms gives rooms of every node like RoomLen, they're rooms of nodes which responded with
+// error when ctx is done or RequestTimeout of adapter passed.
+func (b *ContextBroadcaster) Rooms(namespace string) ([]string, error) {
+	nspHandler, err := b.namespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if querier, ok := nspHandler.getBroadcast().(roomQuerier); ok {
+		return querier.allRoomsContext(b.ctx)
+	}
+
+	return nspHandler.getBroadcast().AllRooms(), nil
+}
+
+func (b *ContextBroadcaster) namespace(namespace string) (*namespaceHandler, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	nspHandler := b.server.getNamespace(namespace)
+	if nspHandler == nil {
+		return nil, fmt.Errorf("namespace %q doesn't exist", namespace)
+	}
+
+	return nspHandler, nil
+}
+
+func (b *ContextBroadcaster) emit(event string, args []interface{}) EachFunc {
+	opts := &BroadcastOptions{ctx: b.ctx}
+
+	return func(connection Conn) {
+		if b.ctx.Err() != nil {
+			return
+		}
+
+		broadcastEmit(connection, opts, event, args...)
+	}
+}
+
+// publish delivers broadcast to other nodes through adapter, unless ctx is done.
+func (b *ContextBroadcaster) publish(namespace string, nspHandler *namespaceHandler, room string, set *RoomSet, event string, args []interface{}) error {
+	b.server.recordBroadcast(namespace, room, set, event, args)
+
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	publisher, ok := nspHandler.getBroadcast().(contextPublisher)
+	if !ok {
+		return nil
+	}
+
+	deadline, _ := b.ctx.Deadline()
+
+	return publisher.publishBroadcast(room, set, deadline, event, args...)
+}
@@ -0,0 +1,132 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerWithContext(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(nil)
+	server.OnConnect("/chat", func(Conn) error { return nil })
+
+	c, failures := newDeliveryConn(&failingEngineConn{})
+	nc, _ := c.namespaces.Get("/chat")
+	nc.broadcast = server.getNamespace("/chat").broadcast
+	nc.Join("lobby")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	written := make(chan outgoingPacket, 1)
+	go func() {
+		written <- <-c.writeChan
+	}()
+	must.NoError(server.WithContext(ctx).BroadcastToNamespace("/chat", "news", "hello"))
+	should.Equal([]interface{}{"news", "hello"}, (<-written).Data)
+
+	// nothing serves writes of the connection, so broadcast gives up once request is cancelled
+	done := make(chan error, 1)
+	go func() {
+		done <- server.WithContext(ctx).BroadcastToRoom("/chat", "lobby", "news", "bye")
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		should.ErrorIs(err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast doesn't respect cancellation")
+	}
+	should.Equal(DeliveryFailure{
+		Namespace: "/chat",
+		Event:     "news",
+		Err:       context.Canceled,
+	}, <-failures)
+
+	should.ErrorIs(server.WithContext(ctx).BroadcastToRoomSet("/chat", Union("lobby"), "news"), context.Canceled)
+	should.Error(server.WithContext(context.Background()).BroadcastToRoom("/missing", "lobby", "news"))
+}
+
+func TestContextBroadcasterRoomQueries(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	requests := make(chan string, 4)
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBSUB":
+			return "*2\r\n" + respArray(cmd[2])[4:] + ":3\r\n"
+		case "PUBLISH":
+			if strings.HasPrefix(cmd[1], "socket.io-request#") {
+				requests <- cmd[2]
+			}
+		}
+		return ":1\r\n"
+	})
+
+	server := NewServer(nil)
+	defer server.Close()
+
+	bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{Addr: addr, RequestTimeout: 100 * time.Millisecond}))
+	must.NoError(err)
+	must.NoError(server.SetBroadcaster("/chat", bc))
+
+	// one of three nodes responds
+	go func() {
+		for msg := range requests {
+			var req map[string]string
+			_ = json.Unmarshal([]byte(msg), &req)
+
+			res, _ := json.Marshal(map[string]interface{}{
+				"RequestType": req["RequestType"],
+				"RequestID":   req["RequestID"],
+				"NodeID":      "node2",
+				"Connections": 2,
+				"Rooms":       []string{"lobby"},
+			})
+			bc.onResponse(res)
+		}
+	}()
+	defer close(requests)
+
+	connections, err := server.WithContext(context.Background()).RoomLen("/chat", "lobby")
+	should.ErrorIs(err, ErrRoomQueryTimeout)
+	should.ErrorContains(err, "1 of 3 nodes responded")
+	should.Equal(2, connections)
+
+	rooms, err := server.WithContext(context.Background()).Rooms("/chat")
+	should.ErrorIs(err, ErrRoomQueryTimeout)
+	should.Equal([]string{"lobby"}, rooms)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	connections, err = server.WithContext(ctx).RoomLen("/chat", "lobby")
+	should.ErrorIs(err, context.DeadlineExceeded)
+	should.Less(time.Since(start), 100*time.Millisecond)
+	should.Equal(2, connections)
+
+	// Len without context gives partial count after RequestTimeout
+	should.Equal(2, server.RoomLen("/chat", "lobby"))
+
+	_, err = server.WithContext(context.Background()).RoomLen("/missing", "lobby")
+	should.Error(err)
+}
@@ -0,0 +1,149 @@
+package socketio
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ContextCodec encodes connection context to persist it, e.g. for recovery, migration or offline queues.
+type ContextCodec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONContextCodec encodes context with encoding/json.
+type JSONContextCodec struct{}
+
+// Name of the codec.
+func (JSONContextCodec) Name() string {
+	return "json"
+}
+
+// Marshal encodes v.
+func (JSONContextCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes data into v.
+func (JSONContextCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobContextCodec encodes context with encoding/gob.
+type GobContextCodec struct{}
+
+// Name of the codec.
+func (GobContextCodec) Name() string {
+	return "gob"
+}
+
+// Marshal encodes v.
+func (GobContextCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data into v.
+func (GobContextCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+var (
+	contextCodecs     = map[string]ContextCodec{"json": JSONContextCodec{}, "gob": GobContextCodec{}}
+	contextCodecsLock sync.RWMutex
+)
+
+// RegisterContextCodec registers codec, e.g. msgpack, so stored contexts encoded with it can be decoded.
+func RegisterContextCodec(codec ContextCodec) {
+	contextCodecsLock.Lock()
+	defer contextCodecsLock.Unlock()
+
+	contextCodecs[codec.Name()] = codec
+}
+
+func getContextCodec(name string) (ContextCodec, bool) {
+	contextCodecsLock.RLock()
+	defer contextCodecsLock.RUnlock()
+
+	codec, ok := contextCodecs[name]
+	return codec, ok
+}
+
+// context serializer errors.
+var (
+	errUnknownContextCodec   = errors.New("unknown context codec")
+	errContextVersionUpgrade = errors.New("stored context version needs upgrade")
+)
+
+type contextEnvelope struct {
+	Version int    `json:"v"`
+	Codec   string `json:"c"`
+	Data    []byte `json:"d"`
+}
+
+// ContextSerializer serializes context with a codec and a version, so stored state survives
+// upgrades of application struct definitions.
+type ContextSerializer struct {
+	// Codec encodes new contexts, JSONContextCodec is used when empty.
+	Codec ContextCodec
+	// Version of the current context struct definition.
+	Version int
+	// Upgrade decodes data stored with an older version into v with the current definition.
+	Upgrade func(version int, codec ContextCodec, data []byte, v interface{}) error
+}
+
+// Marshal encodes v with the codec and current version.
+func (cs *ContextSerializer) Marshal(v interface{}) ([]byte, error) {
+	codec := cs.codec()
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&contextEnvelope{
+		Version: cs.Version,
+		Codec:   codec.Name(),
+		Data:    data,
+	})
+}
+
+// Unmarshal decodes data into v, data stored with an older version is passed to Upgrade.
+func (cs *ContextSerializer) Unmarshal(data []byte, v interface{}) error {
+	var env contextEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+
+	codec, ok := getContextCodec(env.Codec)
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownContextCodec, env.Codec)
+	}
+
+	if env.Version == cs.Version {
+		return codec.Unmarshal(env.Data, v)
+	}
+
+	if cs.Upgrade == nil {
+		return fmt.Errorf("%w: from %d to %d", errContextVersionUpgrade, env.Version, cs.Version)
+	}
+
+	return cs.Upgrade(env.Version, codec, env.Data, v)
+}
+
+func (cs *ContextSerializer) codec() ContextCodec {
+	if cs.Codec != nil {
+		return cs.Codec
+	}
+
+	return JSONContextCodec{}
+}
@@ -0,0 +1,55 @@
+package socketio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testContextV1 struct {
+	Name string
+}
+
+type testContextV2 struct {
+	FirstName string
+	Age       int
+}
+
+func TestContextSerializer(t *testing.T) {
+	for _, codec := range []ContextCodec{JSONContextCodec{}, GobContextCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			v1 := &ContextSerializer{Codec: codec, Version: 1}
+
+			data, err := v1.Marshal(&testContextV1{Name: "foo"})
+			must.NoError(err)
+
+			var ctx testContextV1
+			must.NoError(v1.Unmarshal(data, &ctx))
+			should.Equal("foo", ctx.Name)
+
+			v2 := &ContextSerializer{Codec: codec, Version: 2}
+
+			var ctx2 testContextV2
+			err = v2.Unmarshal(data, &ctx2)
+			should.True(errors.Is(err, errContextVersionUpgrade))
+
+			v2.Upgrade = func(version int, codec ContextCodec, data []byte, v interface{}) error {
+				var old testContextV1
+				if err := codec.Unmarshal(data, &old); err != nil {
+					return err
+				}
+
+				v.(*testContextV2).FirstName = old.Name
+				return nil
+			}
+
+			must.NoError(v2.Unmarshal(data, &ctx2))
+			should.Equal("foo", ctx2.FirstName)
+		})
+	}
+}
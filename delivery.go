@@ -0,0 +1,309 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrWriteTimeout is reported when packet isn't written to connection before its write deadline.
+var ErrWriteTimeout = errors.New("write deadline exceeded")
+
+// DeliveryFailure describes packet which couldn't be written to connection.
+type DeliveryFailure struct {
+	Namespace string
+
+	// Event is name of failed event, it's empty for packets other than events.
+	Event string
+
+	// Attempts is number of tries to encode the packet, zero when its deadline passed before
+	// it reached the writer.
+	Attempts int
+
+	// Origin is id of node which published the broadcast of the packet, it's empty for packets
+	// emitted by this node.
+	Origin string
+
+	Err error
+}
+
+// DeliveryFailureFunc is called with connection which failed to receive a packet.
+type DeliveryFailureFunc func(conn Conn, failure DeliveryFailure)
+
+// OnDeliveryFailure sets f called when packet can't be written to connection, instead
+// of reporting the error to OnError handler of namespace.
+func (s *Server) OnDeliveryFailure(f DeliveryFailureFunc) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.deliveryFailure = f
+
+	return nil
+}
+
+// BroadcastOptions configures a single broadcast, see Server.BroadcastWithOptions.
+type BroadcastOptions struct {
+	// WriteTimeout bounds how long the broadcast waits for each connection to take the packet,
+	// slow connections miss the broadcast with ErrWriteTimeout. Zero uses timeout of
+	// Server.SetBroadcastWriteTimeout.
+	WriteTimeout time.Duration
+
+	// NoCompress sends the broadcast without per-message compression, like Compress(false)
+	// of connection, e.g. for payloads which are already compressed.
+	NoCompress bool
+
+	// MessageID identifies message of the broadcast given by application, duplicates of it
+	// are dropped, see Server.EnableBroadcastDedup.
+	MessageID string
+
+	// ctx bounds writes of the broadcast, see Server.WithContext.
+	ctx context.Context
+
+	// origin is id of node which published the broadcast, empty for broadcasts of this node.
+	origin string
+}
+
+func (o *BroadcastOptions) getWriteTimeout() time.Duration {
+	if o == nil {
+		return 0
+	}
+
+	return o.WriteTimeout
+}
+
+func (o *BroadcastOptions) getNoCompress() bool {
+	return o != nil && o.NoCompress
+}
+
+func (o *BroadcastOptions) getMessageID() string {
+	if o == nil {
+		return ""
+	}
+
+	return o.MessageID
+}
+
+func (o *BroadcastOptions) getOrigin() string {
+	if o == nil {
+		return ""
+	}
+
+	return o.origin
+}
+
+func (o *BroadcastOptions) getContext() context.Context {
+	if o == nil {
+		return nil
+	}
+
+	return o.ctx
+}
+
+// SetBroadcastWriteTimeout sets default write timeout of broadcasts, so a slow connection
+// doesn't hold the broadcast to other connections. Zero, the default, waits for every
+// connection.
+func (s *Server) SetBroadcastWriteTimeout(timeout time.Duration) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.broadcastTimeout = timeout
+
+	return nil
+}
+
+// BroadcastWithOptions broadcasts given event & args to all the connections selected by the room
+// set, like BroadcastToRoomSet. Options apply to connections of this server, other nodes deliver
+// the broadcast with their defaults. It returns false when namespace doesn't exist, its
+// broadcaster isn't RoomSetBroadcast or broadcast is duplicate of its MessageID.
+func (s *Server) BroadcastWithOptions(namespace string, set *RoomSet, opts *BroadcastOptions, event string, args ...interface{}) bool {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return false
+	}
+
+	b, ok := nspHandler.getBroadcast().(RoomSetBroadcast)
+	if !ok {
+		return false
+	}
+
+	if !s.firstBroadcast(namespace, opts.getMessageID()) {
+		return false
+	}
+
+	b.ForEachRoomSet(set, func(connection Conn) {
+		broadcastEmit(connection, opts, event, args...)
+	})
+
+	if publisher, ok := nspHandler.getBroadcast().(roomSetPublisher); ok {
+		publisher.publishRoomSetMessage(set, event, args...)
+	}
+	s.recordBroadcast(namespace, "", set, event, args)
+
+	return true
+}
+
+// roomSetPublisher is implemented by broadcasts which deliver room sets to other nodes.
+type roomSetPublisher interface {
+	publishRoomSetMessage(set *RoomSet, event string, args ...interface{})
+}
+
+// broadcastEmit emits event of broadcast to connection with opts, which may be nil,
+// zero write timeout uses broadcast timeout of connection.
+func broadcastEmit(connection Conn, opts *BroadcastOptions, event string, args ...interface{}) {
+	nc, ok := connection.(*namespaceConn)
+	if !ok {
+		connection.Emit(event, args...)
+		return
+	}
+
+	timeout := opts.getWriteTimeout()
+	if timeout <= 0 {
+		timeout = nc.broadcastTimeout
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	ctx := opts.getContext()
+	if ctx != nil {
+		if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+			deadline = ctxDeadline
+		}
+	}
+
+	nc.emitWith(writeOptions{
+		deadline:   deadline,
+		noCompress: opts.getNoCompress(),
+		ctx:        ctx,
+		origin:     opts.getOrigin(),
+	}, event, args...)
+}
+
+// writeOptions modify how packet is written to connection.
+type writeOptions struct {
+	// deadline is zero when packet waits for the writer without limit.
+	deadline time.Time
+
+	// noCompress writes packet without per-message compression, see Namespace.Compress.
+	noCompress bool
+
+	// ctx cancels packet which isn't written yet, it may be nil.
+	ctx context.Context
+
+	// origin is node which published broadcast of packet, see DeliveryFailure.
+	origin string
+}
+
+// done gives channel closed once packet is cancelled, nil when it can't be.
+func (o writeOptions) done() <-chan struct{} {
+	if o.ctx == nil {
+		return nil
+	}
+
+	return o.ctx.Done()
+}
+
+// cancelled gives error of context of packet which is cancelled.
+func (o writeOptions) cancelled() error {
+	if o.ctx == nil {
+		return nil
+	}
+
+	return o.ctx.Err()
+}
+
+// outgoingPacket is packet queued for the writer of connection.
+type outgoingPacket struct {
+	parser.Payload
+	writeOptions
+}
+
+func (p *outgoingPacket) event() string {
+	if p.Header.Type != parser.Event || len(p.Data) == 0 {
+		return ""
+	}
+
+	event, _ := p.Data[0].(string)
+	return event
+}
+
+// temporaryError reports whether writing may succeed when retried, like payload.Error does.
+func temporaryError(err error) bool {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+
+	return false
+}
+
+// writePacket encodes packet, it retries once when encoding fails with temporary error.
+// Failure is reported to delivery failure func, or to error handler without one.
+func (c *conn) writePacket(pkg outgoingPacket) {
+	if !pkg.deadline.IsZero() && time.Now().After(pkg.deadline) {
+		c.deliveryFailed(pkg, 0, ErrWriteTimeout)
+		return
+	}
+	if err := pkg.cancelled(); err != nil {
+		c.deliveryFailed(pkg, 0, err)
+		return
+	}
+
+	c.setCompression(pkg)
+	c.setStreaming()
+
+	header := c.routedOut(pkg.Header)
+
+	var data interface{} = pkg.Data
+	if c.v5 && len(pkg.Data) == 1 && (header.Type == parser.Connect || header.Type == parser.Error) {
+		// payload of CONNECT and CONNECT_ERROR packets of protocol 5 is an object, not a list
+		data = pkg.Data[0]
+	}
+
+	err := c.encoder.Encode(header, data)
+	if err == nil {
+		c.stats.sent(c.encoder.LastSize())
+		c.shapeEgress()
+		return
+	}
+
+	attempts := 1
+	if temporaryError(err) && (pkg.deadline.IsZero() || time.Now().Before(pkg.deadline)) {
+		attempts++
+		if err = c.encoder.Encode(header, data); err == nil {
+			c.stats.sent(c.encoder.LastSize())
+			c.shapeEgress()
+			return
+		}
+	}
+
+	c.deliveryFailed(pkg, attempts, err)
+}
+
+func (c *conn) deliveryFailed(pkg outgoingPacket, attempts int, err error) {
+	ns := pkg.Header.Namespace
+	if ns == aliasRootNamespace {
+		ns = rootNamespace
+	}
+
+	if c.deliveryFailure != nil {
+		if nc, ok := c.namespaces.Get(ns); ok {
+			c.deliveryFailure(nc, DeliveryFailure{
+				Namespace: nc.Namespace(),
+				Event:     pkg.event(),
+				Attempts:  attempts,
+				Origin:    pkg.origin,
+				Err:       err,
+			})
+			return
+		}
+	}
+
+	c.onError(pkg.Header.Namespace, err)
+}
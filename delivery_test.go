@@ -0,0 +1,143 @@
+package socketio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/session"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+type temporaryWriteError struct{}
+
+func (temporaryWriteError) Error() string   { return "temporary write error" }
+func (temporaryWriteError) Temporary() bool { return true }
+
+type nopWriteCloser struct{}
+
+func (nopWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (nopWriteCloser) Close() error                { return nil }
+
+// failingEngineConn fails first failures calls of NextWriter with err.
+type failingEngineConn struct {
+	engineio.Conn
+	failures int
+	err      error
+	writes   int
+}
+
+func (c *failingEngineConn) ID() string {
+	return "sid1"
+}
+
+func (c *failingEngineConn) Close() error {
+	return nil
+}
+
+func (c *failingEngineConn) NextWriter(session.FrameType) (io.WriteCloser, error) {
+	c.writes++
+	if c.writes <= c.failures {
+		return nil, c.err
+	}
+
+	return nopWriteCloser{}, nil
+}
+
+func newDeliveryConn(engineConn engineio.Conn) (*conn, chan DeliveryFailure) {
+	failures := make(chan DeliveryFailure, 1)
+
+	c := newConn(engineConn, newNamespaceHandlers())
+	c.deliveryFailure = func(_ Conn, failure DeliveryFailure) {
+		failures <- failure
+	}
+	c.namespaces.Set("/chat", newNamespaceConn(c, "/chat", nil))
+
+	return c, failures
+}
+
+func TestConnWritePacket(t *testing.T) {
+	permanent := errors.New("closed")
+
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		writes   int
+		attempts int
+		failed   error
+	}{
+		{"written", 0, nil, 1, 0, nil},
+		{"retried temporary error", 1, temporaryWriteError{}, 2, 0, nil},
+		{"temporary error twice", 2, temporaryWriteError{}, 2, 2, temporaryWriteError{}},
+		{"permanent error", 1, permanent, 1, 1, permanent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			engineConn := &failingEngineConn{failures: test.failures, err: test.err}
+			c, failures := newDeliveryConn(engineConn)
+			nc, _ := c.namespaces.Get("/chat")
+
+			go nc.Emit("message", "hello")
+			c.writePacket(<-c.writeChan)
+
+			should.Equal(test.writes, engineConn.writes)
+			if test.failed == nil {
+				should.Len(failures, 0)
+				return
+			}
+
+			must.Len(failures, 1)
+			failure := <-failures
+			should.Equal(DeliveryFailure{
+				Namespace: "/chat",
+				Event:     "message",
+				Attempts:  test.attempts,
+				Err:       test.failed,
+			}, failure)
+		})
+	}
+}
+
+func TestBroadcastEmitWriteTimeout(t *testing.T) {
+	should := assert.New(t)
+
+	c, failures := newDeliveryConn(&failingEngineConn{})
+	c.broadcastTimeout = time.Hour
+	nc, _ := c.namespaces.Get("/chat")
+
+	// nothing serves writes of the connection, so broadcast must give up on it
+	done := make(chan struct{})
+	go func() {
+		broadcastEmit(nc, &BroadcastOptions{WriteTimeout: 10 * time.Millisecond}, "news", "hello")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast is blocked by slow connection")
+	}
+
+	should.Equal(DeliveryFailure{
+		Namespace: "/chat",
+		Event:     "news",
+		Err:       ErrWriteTimeout,
+	}, <-failures)
+
+	// packet which waited in the writer past its deadline isn't written
+	c.writePacket(outgoingPacket{
+		Payload:      parser.Payload{Header: parser.Header{Type: parser.Event, Namespace: "/chat"}},
+		writeOptions: writeOptions{deadline: time.Now().Add(-time.Second)},
+	})
+	should.ErrorIs((<-failures).Err, ErrWriteTimeout)
+}
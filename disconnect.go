@@ -0,0 +1,87 @@
+package socketio
+
+import (
+	"sync"
+)
+
+// WaitForHandlers makes OnDisconnect handlers of closed connection wait until event handlers
+// which are in flight for the connection, including queued handlers of serialized events,
+// complete. Close of connection doesn't wait for them, so handler may close its own connection.
+// By default OnDisconnect handlers are called by Close right away. Either way, they're called
+// after the connection left all its rooms and events received afterwards aren't handled.
+func (s *Server) WaitForHandlers(wait bool) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.waitHandlers = wait
+
+	return nil
+}
+
+// handlerTracker counts event handlers in flight for a connection.
+type handlerTracker struct {
+	inflight int
+	mu       sync.Mutex
+	cond     *sync.Cond
+}
+
+func newHandlerTracker() *handlerTracker {
+	t := &handlerTracker{}
+	t.cond = sync.NewCond(&t.mu)
+
+	return t
+}
+
+// handlerStarted counts event handler of connection, it reports false when connection is
+// closing, so the event shouldn't be handled.
+func (c *conn) handlerStarted() bool {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+
+	if c.isClosing() {
+		return false
+	}
+
+	c.tracker.inflight++
+
+	return true
+}
+
+func (c *conn) handlerDone() {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+
+	if c.tracker.inflight--; c.tracker.inflight == 0 {
+		c.tracker.cond.Broadcast()
+	}
+}
+
+// waitHandlers waits until event handlers in flight complete, connection must be closing,
+// so no other handler starts.
+func (c *conn) waitHandlers() {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+
+	for c.tracker.inflight > 0 {
+		c.tracker.cond.Wait()
+	}
+}
+
+// disconnected calls disconnect handlers of all namespaces of closed connection,
+// details are taken by Close, before writes in flight are dropped.
+func (c *conn) disconnected(details map[string]interface{}) {
+	c.namespaces.Range(func(ns string, nc *namespaceConn) {
+		nh, _ := c.handlers.Get(ns)
+		if nh == nil {
+			return
+		}
+
+		if onDisconnect := nh.disconnectHandler(nc); onDisconnect != nil {
+			onDisconnect(nc, clientDisconnectMsg)
+		}
+		if nh.onDisconnectDetails != nil {
+			nh.onDisconnectDetails(nc, clientDisconnectMsg, copyDetails(details))
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package socketio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DisconnectDetailsFunc is called with connection, reason of disconnect and its diagnostics,
+// see Server.OnDisconnectDetails.
+type DisconnectDetailsFunc func(conn Conn, reason string, details map[string]interface{})
+
+// OnDisconnectDetails sets f called like OnDisconnect handler of namespace, with details
+// of connection for post-mortems of dropped users:
+//   - "queued" is count of emitted packets which are queued but not sent
+//   - "pendingAcks" is count of emitted events waiting for ack
+//   - "ackLatency" is time.Duration between last acked event and its ack, zero when none was acked
+//   - "transport" is name of engine.io transport, e.g. "websocket"
+//   - "bytesSent" and "bytesReceived" are total sizes of packets, including binary attachments
+func (s *Server) OnDisconnectDetails(namespace string, f DisconnectDetailsFunc) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.onDisconnectDetails = f
+}
+
+// connStats counts traffic of connection for its disconnect details.
+type connStats struct {
+	queued        int64
+	bytesSent     int64
+	bytesReceived int64
+	ackLatency    int64
+}
+
+func (s *connStats) sent(size int) {
+	atomic.AddInt64(&s.bytesSent, int64(size))
+}
+
+func (s *connStats) received(size int) {
+	atomic.AddInt64(&s.bytesReceived, int64(size))
+}
+
+func (s *connStats) acked(latency time.Duration) {
+	atomic.StoreInt64(&s.ackLatency, int64(latency))
+}
+
+// storeAck keeps f called by ack of event id, sent now.
+func (nc *namespaceConn) storeAck(id uint64, f *funcHandler) {
+	now := time.Now()
+	nc.expireAcks(now)
+
+	nc.ackSent.Store(id, now)
+	nc.ack.Store(id, f)
+}
+
+// forgetAck drops ack of event id which won't be called.
+func (nc *namespaceConn) forgetAck(id uint64) {
+	nc.ack.Delete(id)
+	nc.ackSent.Delete(id)
+}
+
+// ackReceived records latency of ack of event id.
+func (nc *namespaceConn) ackReceived(id uint64) {
+	if sent, ok := nc.ackSent.LoadAndDelete(id); ok {
+		nc.stats.acked(time.Since(sent.(time.Time)))
+	}
+}
+
+// copyDetails gives each disconnect handler its own details.
+func copyDetails(details map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(details))
+	for k, v := range details {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+func (c *conn) disconnectDetails() map[string]interface{} {
+	return map[string]interface{}{
+		"queued":        int(atomic.LoadInt64(&c.stats.queued)),
+		"pendingAcks":   c.pendingAcks(),
+		"ackLatency":    time.Duration(atomic.LoadInt64(&c.stats.ackLatency)),
+		"transport":     c.Transport(),
+		"bytesSent":     atomic.LoadInt64(&c.stats.bytesSent),
+		"bytesReceived": atomic.LoadInt64(&c.stats.bytesReceived),
+	}
+}
@@ -0,0 +1,69 @@
+package socketio
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type transportEngineConn struct {
+	failingEngineConn
+}
+
+func (c *transportEngineConn) Transport() string {
+	return "websocket"
+}
+
+func TestConnDisconnectDetails(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	handlers := newNamespaceHandlers()
+	nh := newNamespaceHandler("/chat", nil)
+	handlers.Set("/chat", nh)
+
+	c := newConn(&transportEngineConn{}, handlers)
+	nc := newNamespaceConn(c, "/chat", nh.broadcast)
+	c.namespaces.Set("/chat", nc)
+
+	disconnected := make(chan map[string]interface{}, 1)
+	nh.onDisconnectDetails = func(_ Conn, reason string, details map[string]interface{}) {
+		should.Equal(clientDisconnectMsg, reason)
+		disconnected <- details
+	}
+
+	written := make(chan struct{})
+	go func() {
+		for i := 0; i < 2; i++ {
+			c.writePacket(<-c.writeChan)
+		}
+		close(written)
+	}()
+
+	acked := nc.emit("hello", "world", func() {})
+	nc.emit("bye", func() {})
+	<-written
+
+	time.Sleep(10 * time.Millisecond)
+	nc.ackReceived(acked)
+	nc.ack.Delete(acked)
+
+	go nc.Emit("late")
+	must.Eventually(func() bool {
+		return atomic.LoadInt64(&c.stats.queued) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	c.stats.received(10)
+	must.NoError(c.Close())
+
+	details := <-disconnected
+	should.Equal(1, details["queued"])
+	should.Equal(1, details["pendingAcks"])
+	should.GreaterOrEqual(details["ackLatency"], 10*time.Millisecond)
+	should.Equal("websocket", details["transport"])
+	should.Equal(int64(len("2/chat,1[\"hello\",\"world\"]\n")+len("2/chat,2[\"bye\"]\n")), details["bytesSent"])
+	should.Equal(int64(10), details["bytesReceived"])
+}
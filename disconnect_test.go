@@ -0,0 +1,60 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnWaitForHandlers(t *testing.T) {
+	tests := []struct {
+		name string
+		wait bool
+	}{
+		{"wait for handlers", true},
+		{"don't wait", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			should := assert.New(t)
+
+			handlers := newNamespaceHandlers()
+			nh := newNamespaceHandler("/chat", nil)
+			handlers.Set("/chat", nh)
+
+			c := newConn(&failingEngineConn{}, handlers)
+			c.waitForHandlers = test.wait
+			nc := newNamespaceConn(c, "/chat", nh.broadcast)
+			c.namespaces.Set("/chat", nc)
+			nc.Join("lobby")
+
+			disconnected := make(chan []string, 1)
+			nh.OnDisconnect(func(conn Conn, _ string) {
+				disconnected <- conn.Rooms()
+			})
+
+			should.True(c.handlerStarted())
+			should.NoError(c.Close())
+			should.False(c.handlerStarted(), "events of closed connection aren't handled")
+
+			if test.wait {
+				select {
+				case <-disconnected:
+					t.Fatal("disconnect handler didn't wait for event handler")
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				c.handlerDone()
+			}
+
+			select {
+			case rooms := <-disconnected:
+				should.Empty(rooms, "connection left rooms before disconnect handler")
+			case <-time.After(5 * time.Second):
+				t.Fatal("disconnect handler wasn't called")
+			}
+		})
+	}
+}
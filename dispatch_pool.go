@@ -0,0 +1,196 @@
+package socketio
+
+import (
+	"runtime"
+	"sync"
+)
+
+const defaultDispatchMaxInflight = 16
+
+// DispatchPoolOptions configures pool of workers shared by connections, see Server.SetDispatchPool.
+type DispatchPoolOptions struct {
+	// Workers : number of goroutines which run event handlers, default is number of CPUs.
+	Workers int
+	// MaxInflight : maximum number of events of connection which are queued or handled, default
+	// is 16. Reader of connection stops reading frames while it's reached.
+	MaxInflight int
+}
+
+func (o *DispatchPoolOptions) getWorkers() int {
+	if o.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return o.Workers
+}
+
+func (o *DispatchPoolOptions) getMaxInflight() int {
+	if o.MaxInflight <= 0 {
+		return defaultDispatchMaxInflight
+	}
+
+	return o.MaxInflight
+}
+
+// SetDispatchPool makes event handlers of all connections run on pool of workers instead of read
+// loops of connections, so number of busy goroutines is bounded. Events of connection are handled
+// one by one in order they're received, while connections with queued events take turns, so
+// connection which floods events gets one turn per round like any other. Once MaxInflight events
+// of connection are in flight, its reader yields until one of them is handled. Events with
+// serialization key and offloaded events don't run on the pool. Error of handler closes connection
+// like it does in read loop. Handlers of connection run on different workers, one at a time, so
+// state they share, like Context, is handed between goroutines. Once pool is closed, e.g. by
+// server, events run in read loops. Nil opts disables the pool.
+func (s *Server) SetDispatchPool(opts *DispatchPoolOptions) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	if s.dispatchPool != nil {
+		s.dispatchPool.close()
+		s.dispatchPool = nil
+	}
+
+	if opts == nil {
+		return nil
+	}
+
+	s.dispatchPool = newDispatchPool(opts.getWorkers(), opts.getMaxInflight())
+	s.OnServerShutdownComplete(s.dispatchPool.close)
+
+	return nil
+}
+
+// dispatchPool runs tasks of connections on shared workers, tasks of connection run sequentially
+// and connections are served round-robin.
+type dispatchPool struct {
+	maxInflight int
+
+	queues map[*conn]*dispatchQueue
+	// ready are queues with tasks whose connections aren't served by worker, in order of turns.
+	ready  []*dispatchQueue
+	closed bool
+	mu     sync.Mutex
+	// work wakes workers when queue is ready, room wakes readers when task of connection is done.
+	work *sync.Cond
+	room *sync.Cond
+}
+
+// dispatchQueue is queue of tasks of connection, inflight counts queued and running tasks.
+type dispatchQueue struct {
+	c        *conn
+	tasks    []func()
+	inflight int
+	running  bool
+}
+
+func newDispatchPool(workers, maxInflight int) *dispatchPool {
+	p := &dispatchPool{
+		maxInflight: maxInflight,
+		queues:      make(map[*conn]*dispatchQueue),
+	}
+	p.work = sync.NewCond(&p.mu)
+	p.room = sync.NewCond(&p.mu)
+
+	for i := 0; i < workers; i++ {
+		go p.serve()
+	}
+
+	return p
+}
+
+// submit queues task after tasks of connection already submitted. Once pool is closed, task of
+// connection without tasks in flight runs in caller, since workers may be gone.
+func (p *dispatchPool) submit(c *conn, task func()) {
+	p.mu.Lock()
+
+	q := p.queues[c]
+	if p.closed && q == nil {
+		p.mu.Unlock()
+		task()
+		return
+	}
+	defer p.mu.Unlock()
+
+	if q == nil {
+		q = &dispatchQueue{c: c}
+		p.queues[c] = q
+	}
+
+	q.tasks = append(q.tasks, task)
+	q.inflight++
+	if !q.running && len(q.tasks) == 1 {
+		p.ready = append(p.ready, q)
+		p.work.Signal()
+	}
+}
+
+// wait blocks reader of connection while its tasks in flight reach the limit, until one of them
+// is done or connection is closed.
+func (p *dispatchPool) wait(c *conn) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for q := p.queues[c]; q != nil && q.inflight >= p.maxInflight && !c.closed(); q = p.queues[c] {
+		p.room.Wait()
+	}
+}
+
+// wake lets waiting readers check whether their connections were closed.
+func (p *dispatchPool) wake() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.room.Broadcast()
+}
+
+func (p *dispatchPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.work.Broadcast()
+}
+
+// serve runs one task of the next ready connection at a time, then connection goes to the back
+// of ready queues, until pool is closed and queued tasks are done.
+func (p *dispatchPool) serve() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		for len(p.ready) == 0 && !p.closed {
+			p.work.Wait()
+		}
+		if len(p.ready) == 0 {
+			return
+		}
+
+		q := p.ready[0]
+		p.ready = p.ready[1:]
+		task := q.tasks[0]
+		q.tasks = q.tasks[1:]
+		q.running = true
+
+		p.mu.Unlock()
+		task()
+		p.mu.Lock()
+
+		q.running = false
+		q.inflight--
+		if len(q.tasks) > 0 {
+			p.ready = append(p.ready, q)
+		} else if q.inflight == 0 {
+			delete(p.queues, q.c)
+		}
+		p.room.Broadcast()
+	}
+}
@@ -0,0 +1,150 @@
+package socketio
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestDispatchPool(t *testing.T) {
+	should := assert.New(t)
+
+	p := newDispatchPool(1, 2)
+	defer p.close()
+
+	flood := &conn{quitChan: make(chan struct{})}
+	quiet := &conn{quitChan: make(chan struct{})}
+
+	order := make(chan string, 8)
+	gate := make(chan struct{})
+	p.submit(flood, func() {
+		<-gate
+		order <- "flood 1"
+	})
+	p.submit(flood, func() { order <- "flood 2" })
+	p.submit(quiet, func() { order <- "quiet 1" })
+
+	waited := func(c *conn) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			p.wait(c)
+			close(done)
+		}()
+
+		return done
+	}
+
+	select {
+	case <-waited(quiet):
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection under the limit yields")
+	}
+
+	done := waited(flood)
+	select {
+	case <-done:
+		t.Fatal("connection at the limit doesn't yield")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gate)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader isn't resumed once event is handled")
+	}
+
+	should.Equal("flood 1", <-order)
+	should.Equal("quiet 1", <-order, "connections take turns")
+	should.Equal("flood 2", <-order)
+
+	gate = make(chan struct{})
+	p.submit(flood, func() { <-gate })
+	p.submit(flood, func() {})
+	done = waited(flood)
+
+	close(flood.quitChan)
+	p.wake()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader of closed connection yields")
+	}
+	close(gate)
+
+	var disabled *dispatchPool
+	disabled.wait(flood)
+	disabled.wake()
+}
+
+func TestDispatchPoolClosed(t *testing.T) {
+	p := newDispatchPool(1, 2)
+	p.close()
+
+	c := &conn{quitChan: make(chan struct{})}
+
+	ran := false
+	p.submit(c, func() { ran = true })
+
+	assert.True(t, ran, "task submitted after close runs in caller")
+}
+
+func TestServerSetDispatchPool(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	const floods = 30
+
+	var handled int64
+	order := make(chan int, floods)
+	_, url := newConformanceServer(t, func(server *Server) {
+		must.NoError(server.SetDispatchPool(&DispatchPoolOptions{Workers: 1, MaxInflight: 2}))
+		server.OnEvent("/", "slow", func(_ Conn, n int) {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt64(&handled, 1)
+			order <- n
+		})
+	})
+
+	flood := newProtocolClient(t, url)
+	defer flood.conn.Close()
+	_, _, _ = flood.receive(t)
+
+	quiet := newProtocolClient(t, url)
+	defer quiet.conn.Close()
+	_, _, _ = quiet.receive(t)
+
+	go func() {
+		for i := 0; i < floods; i++ {
+			if err := flood.encoder.Encode(parser.Header{Type: parser.Event}, []interface{}{"slow", i}); err != nil {
+				return
+			}
+		}
+	}()
+
+	for atomic.LoadInt64(&handled) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	quiet.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "echo", "hello")
+
+	header, _, args := quiet.receive(t, reflect.TypeOf(""))
+	should.Equal(parser.Ack, header.Type)
+	should.Equal([]interface{}{"hello"}, args)
+	should.Less(atomic.LoadInt64(&handled), int64(floods/2), "flooding connection doesn't hold the worker")
+
+	for i := 0; i < floods; i++ {
+		select {
+		case n := <-order:
+			must.Equal(i, n, "events of connection are handled in order")
+		case <-time.After(5 * time.Second):
+			t.Fatal("events of flooding connection aren't handled")
+		}
+	}
+}
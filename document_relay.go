@@ -0,0 +1,279 @@
+package socketio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+const (
+	defaultDocumentCoalesceWindow = 20 * time.Millisecond
+	defaultDocumentMaxBatchBytes  = 64 << 10
+
+	documentRoomPrefix = "doc:"
+)
+
+// events of document relay, see Server.EnableDocumentRelay.
+const (
+	DocumentJoinEvent    = "doc:join"
+	DocumentLeaveEvent   = "doc:leave"
+	DocumentUpdateEvent  = "doc:update"
+	DocumentStateEvent   = "doc:state"
+	DocumentUpdatesEvent = "doc:updates"
+)
+
+// DocumentStore keeps the latest state of each document, e.g. encoded Yjs state or Automerge
+// document, for peers which join the document later.
+type DocumentStore interface {
+	// LoadState gives state of document, nil when there's none.
+	LoadState(doc string) ([]byte, error)
+	SaveState(doc string, state []byte) error
+}
+
+// DocumentRelayOptions configures relay of document updates, see Server.EnableDocumentRelay.
+type DocumentRelayOptions struct {
+	// CoalesceWindow is how long updates of a document are collected before they're relayed
+	// together, default is 20 milliseconds.
+	CoalesceWindow time.Duration
+
+	// MaxBatchBytes relays collected updates before the window ends once they reach the size,
+	// default is 64 KiB.
+	MaxBatchBytes int
+
+	// Store persists state of documents sent with DocumentStateEvent, it's optional.
+	Store DocumentStore
+
+	// Authorize decides whether conn may join document, nil allows every document.
+	Authorize func(conn Conn, doc string) bool
+}
+
+func (o *DocumentRelayOptions) getCoalesceWindow() time.Duration {
+	if o == nil || o.CoalesceWindow <= 0 {
+		return defaultDocumentCoalesceWindow
+	}
+
+	return o.CoalesceWindow
+}
+
+func (o *DocumentRelayOptions) getMaxBatchBytes() int {
+	if o == nil || o.MaxBatchBytes <= 0 {
+		return defaultDocumentMaxBatchBytes
+	}
+
+	return o.MaxBatchBytes
+}
+
+func (o *DocumentRelayOptions) getStore() DocumentStore {
+	if o == nil {
+		return nil
+	}
+
+	return o.Store
+}
+
+func (o *DocumentRelayOptions) authorize(conn Conn, doc string) bool {
+	if o == nil || o.Authorize == nil {
+		return true
+	}
+
+	return o.Authorize(conn, doc)
+}
+
+// documentAck answers document events, Error is empty when event is accepted.
+type documentAck struct {
+	Error string `json:"error,omitempty"`
+	// State is stored state of document, given to peer which joins it.
+	State *parser.Buffer `json:"state,omitempty"`
+}
+
+// DocumentRelay relays binary updates of collaborative documents, such as CRDT or OT updates,
+// between peers of each document.
+type DocumentRelay struct {
+	server    *Server
+	namespace string
+	opts      *DocumentRelayOptions
+
+	// members are documents joined by each connection, by its id.
+	members map[string]map[string]struct{}
+	pending map[string]*pendingUpdates
+	lock    sync.Mutex
+
+	// flushLock keeps batches of document in order they were collected, it's taken before lock.
+	flushLock sync.Mutex
+}
+
+// pendingUpdates are updates of document waiting to be relayed.
+type pendingUpdates struct {
+	updates [][]byte
+	size    int
+}
+
+// EnableDocumentRelay registers document events on namespace:
+//
+//   - DocumentJoinEvent with document key joins its room, ack has stored state of document.
+//   - DocumentLeaveEvent with document key leaves its room.
+//   - DocumentUpdateEvent with document key and binary update relays update to peers of document.
+//   - DocumentStateEvent with document key and binary state saves the state for late joiners.
+//
+// Updates of a document are coalesced for CoalesceWindow and relayed to all its peers, including
+// senders, with DocumentUpdatesEvent, document key and list of binary updates, in order they
+// were received. CRDT updates are idempotent, so peers apply their own updates again safely.
+// Call DocumentRelay.Disconnect from OnDisconnect handler of namespace.
+func (s *Server) EnableDocumentRelay(namespace string, opts *DocumentRelayOptions) (*DocumentRelay, error) {
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	r := &DocumentRelay{
+		server:    s,
+		namespace: namespace,
+		opts:      opts,
+		members:   make(map[string]map[string]struct{}),
+		pending:   make(map[string]*pendingUpdates),
+	}
+
+	s.OnEvent(namespace, DocumentJoinEvent, r.join)
+	s.OnEvent(namespace, DocumentLeaveEvent, r.leave)
+	s.OnEvent(namespace, DocumentUpdateEvent, r.update)
+	s.OnEvent(namespace, DocumentStateEvent, r.saveState)
+
+	return r, nil
+}
+
+// Disconnect forgets documents joined by conn.
+func (r *DocumentRelay) Disconnect(conn Conn) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.members, conn.ID())
+}
+
+func (r *DocumentRelay) isMember(conn Conn, doc string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.members[conn.ID()][doc]
+	return ok
+}
+
+func (r *DocumentRelay) join(conn Conn, doc string) documentAck {
+	if doc == "" || !r.opts.authorize(conn, doc) {
+		return documentAck{Error: "join is not authorized"}
+	}
+
+	var ack documentAck
+	if store := r.opts.getStore(); store != nil {
+		state, err := store.LoadState(doc)
+		if err != nil {
+			return documentAck{Error: err.Error()}
+		}
+		if state != nil {
+			ack.State = &parser.Buffer{Data: state}
+		}
+	}
+
+	if err := conn.TryJoin(documentRoomPrefix + doc); err != nil {
+		return documentAck{Error: err.Error()}
+	}
+
+	r.lock.Lock()
+	if r.members[conn.ID()] == nil {
+		r.members[conn.ID()] = make(map[string]struct{})
+	}
+	r.members[conn.ID()][doc] = struct{}{}
+	r.lock.Unlock()
+
+	return ack
+}
+
+func (r *DocumentRelay) leave(conn Conn, doc string) documentAck {
+	if !r.isMember(conn, doc) {
+		return documentAck{Error: "not a member of document"}
+	}
+
+	r.lock.Lock()
+	delete(r.members[conn.ID()], doc)
+	if len(r.members[conn.ID()]) == 0 {
+		delete(r.members, conn.ID())
+	}
+	r.lock.Unlock()
+
+	conn.Leave(documentRoomPrefix + doc)
+
+	return documentAck{}
+}
+
+func (r *DocumentRelay) update(conn Conn, doc string, update *parser.Buffer) documentAck {
+	if !r.isMember(conn, doc) {
+		return documentAck{Error: "not a member of document"}
+	}
+	if update == nil || len(update.Data) == 0 {
+		return documentAck{Error: "empty update"}
+	}
+
+	r.flushLock.Lock()
+	defer r.flushLock.Unlock()
+
+	r.lock.Lock()
+	pending, ok := r.pending[doc]
+	if !ok {
+		pending = &pendingUpdates{}
+		r.pending[doc] = pending
+
+		time.AfterFunc(r.opts.getCoalesceWindow(), func() {
+			r.flushLock.Lock()
+			defer r.flushLock.Unlock()
+
+			r.flush(doc, pending)
+		})
+	}
+	pending.updates = append(pending.updates, update.Data)
+	pending.size += len(update.Data)
+	full := pending.size >= r.opts.getMaxBatchBytes()
+	r.lock.Unlock()
+
+	if full {
+		r.flush(doc, pending)
+	}
+
+	return documentAck{}
+}
+
+// flush relays pending updates of document, unless they were relayed already. flushLock must be
+// held.
+func (r *DocumentRelay) flush(doc string, pending *pendingUpdates) {
+	r.lock.Lock()
+	if r.pending[doc] != pending {
+		r.lock.Unlock()
+		return
+	}
+	delete(r.pending, doc)
+	r.lock.Unlock()
+
+	updates := make([]*parser.Buffer, len(pending.updates))
+	for i, update := range pending.updates {
+		updates[i] = &parser.Buffer{Data: update}
+	}
+
+	r.server.BroadcastToRoom(r.namespace, documentRoomPrefix+doc, DocumentUpdatesEvent, doc, updates)
+}
+
+func (r *DocumentRelay) saveState(conn Conn, doc string, state *parser.Buffer) documentAck {
+	store := r.opts.getStore()
+	if store == nil {
+		return documentAck{Error: "document state isn't stored"}
+	}
+	if !r.isMember(conn, doc) {
+		return documentAck{Error: "not a member of document"}
+	}
+	if state == nil {
+		return documentAck{Error: "empty state"}
+	}
+
+	if err := store.SaveState(doc, state.Data); err != nil {
+		return documentAck{Error: err.Error()}
+	}
+
+	return documentAck{}
+}
@@ -0,0 +1,129 @@
+package socketio
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// documentPeer joins rooms of server and records batches of updates relayed to it.
+type documentPeer struct {
+	*namespaceConn
+	server *Server
+
+	batches chan []string
+}
+
+func newDocumentPeer(server *Server, id string) *documentPeer {
+	return &documentPeer{
+		namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/", nil),
+		server:        server,
+		batches:       make(chan []string, 10),
+	}
+}
+
+func (p *documentPeer) TryJoin(room string) error {
+	p.server.JoinRoom("/", room, p)
+	return nil
+}
+
+func (p *documentPeer) Leave(room string) {
+	p.server.LeaveRoom("/", room, p)
+}
+
+func (p *documentPeer) Emit(event string, args ...interface{}) {
+	if event != DocumentUpdatesEvent {
+		return
+	}
+
+	var batch []string
+	for _, update := range args[1].([]*parser.Buffer) {
+		batch = append(batch, string(update.Data))
+	}
+	p.batches <- batch
+}
+
+type memoryDocumentStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+func (s *memoryDocumentStore) LoadState(doc string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states[doc], nil
+}
+
+func (s *memoryDocumentStore) SaveState(doc string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[doc] = state
+	return nil
+}
+
+func TestDocumentRelay(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	store := &memoryDocumentStore{states: make(map[string][]byte)}
+	relay, err := server.EnableDocumentRelay("/", &DocumentRelayOptions{
+		CoalesceWindow: 20 * time.Millisecond,
+		MaxBatchBytes:  6,
+		Store:          store,
+		Authorize: func(_ Conn, doc string) bool {
+			return doc != "secret"
+		},
+	})
+	must.NoError(err)
+
+	alice := newDocumentPeer(server, "alice")
+	bob := newDocumentPeer(server, "bob")
+
+	should.Equal(documentAck{}, relay.join(alice, "notes"))
+	should.NotEmpty(relay.join(bob, "secret").Error)
+	should.NotEmpty(relay.update(bob, "notes", &parser.Buffer{Data: []byte("x")}).Error)
+
+	// updates within window are relayed together, in order
+	should.Equal(documentAck{}, relay.update(alice, "notes", &parser.Buffer{Data: []byte("a")}))
+	should.Equal(documentAck{}, relay.update(alice, "notes", &parser.Buffer{Data: []byte("b")}))
+	should.Equal([]string{"a", "b"}, <-alice.batches)
+
+	// batch which reaches MaxBatchBytes is relayed at once
+	should.Equal(documentAck{}, relay.update(alice, "notes", &parser.Buffer{Data: []byte("cccccc")}))
+	select {
+	case batch := <-alice.batches:
+		should.Equal([]string{"cccccc"}, batch)
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("full batch isn't relayed at once")
+	}
+
+	// late joiner gets stored state
+	should.Equal(documentAck{}, relay.saveState(alice, "notes", &parser.Buffer{Data: []byte("state")}))
+	ack := relay.join(bob, "notes")
+	must.NotNil(ack.State)
+	should.Equal("state", string(ack.State.Data))
+
+	should.Equal(documentAck{}, relay.update(bob, "notes", &parser.Buffer{Data: []byte("d")}))
+	should.Equal([]string{"d"}, <-alice.batches)
+	should.Equal([]string{"d"}, <-bob.batches)
+
+	should.Equal(documentAck{}, relay.leave(bob, "notes"))
+	should.NotEmpty(relay.leave(bob, "notes").Error)
+
+	relay.Disconnect(alice)
+	should.NotEmpty(relay.update(alice, "notes", &parser.Buffer{Data: []byte("e")}).Error)
+	should.Empty(bob.batches)
+}
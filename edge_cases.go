@@ -0,0 +1,120 @@
+package socketio
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrAckIDReused is reported to OnError handler of namespace when peer emits event with ack id
+// of its event which isn't acked yet, see ProtocolEdgeCases.UniqueAckIDs.
+var ErrAckIDReused = errors.New("ack id of pending event is reused")
+
+// ProtocolEdgeCases are edge cases of packets of socket.io protocol v5 which peers may send, like
+// socket.io-client 4.x, which connects with engine.io protocol 4.
+// Each of them is handled only when it's enabled, so existing deployments keep their behavior,
+// see Server.SetProtocolEdgeCases and ClientOptions.EdgeCases.
+type ProtocolEdgeCases struct {
+	// UniqueAckIDs drops events whose ack id is used by event of the namespace which isn't
+	// acked yet, the id can be reused once it's acked.
+	UniqueAckIDs bool
+	// DisconnectPayload accepts DISCONNECT packets with payload of any type, reason is taken
+	// from a string or from "reason" of an object as the first argument, otherwise it's empty.
+	DisconnectPayload bool
+	// ErrorPackets discards CONNECT_ERROR packets sent by peer to any namespace, which aren't
+	// expected from clients, instead of blocking reads of connection.
+	ErrorPackets bool
+	// BinaryAcks skips attachments of BINARY_ACK and BINARY_EVENT packets of namespaces which
+	// aren't connected, so they don't break packets which follow them.
+	BinaryAcks bool
+}
+
+// V5EdgeCases gives all the edge cases enabled.
+func V5EdgeCases() ProtocolEdgeCases {
+	return ProtocolEdgeCases{
+		UniqueAckIDs:      true,
+		DisconnectPayload: true,
+		ErrorPackets:      true,
+		BinaryAcks:        true,
+	}
+}
+
+// SetProtocolEdgeCases sets socket.io v5 edge cases handled by server.
+func (s *Server) SetProtocolEdgeCases(cases ProtocolEdgeCases) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.edgeCases = cases
+
+	return nil
+}
+
+// ackKey identifies ack id of event of namespace.
+type ackKey struct {
+	namespace string
+	id        uint64
+}
+
+// claimAckID reserves ack id of event until it's acked, it's false when id is reserved already.
+func (c *conn) claimAckID(header parser.Header) bool {
+	if !c.edgeCases.UniqueAckIDs || !header.NeedAck {
+		return true
+	}
+
+	_, reused := c.pendingAckIDs.LoadOrStore(ackKey{namespace: header.Namespace, id: header.ID}, struct{}{})
+
+	return !reused
+}
+
+// releaseAckID frees ack id of event once it's acked.
+func (c *conn) releaseAckID(header parser.Header) {
+	if c.edgeCases.UniqueAckIDs {
+		c.pendingAckIDs.Delete(ackKey{namespace: header.Namespace, id: header.ID})
+	}
+}
+
+// discardPacket skips rest of packet which isn't handled, with its attachments when BinaryAcks
+// is enabled.
+func (c *conn) discardPacket() error {
+	if c.edgeCases.BinaryAcks {
+		return c.decoder.Discard()
+	}
+
+	return c.decoder.DiscardLast()
+}
+
+// disconnectArgs decodes payload of DISCONNECT packet into its reason.
+func (c *conn) disconnectArgs() ([]reflect.Value, error) {
+	if !c.edgeCases.DisconnectPayload {
+		return c.decoder.DecodeArgs(defaultHeaderType)
+	}
+
+	// payload which isn't an array of arguments is ignored
+	args, err := c.decoder.DecodeArgs(disconnectPayloadType)
+	if err != nil || len(args) == 0 {
+		return nil, nil
+	}
+
+	var reason string
+	switch payload := args[0].Interface().(type) {
+	case string:
+		reason = payload
+	case map[string]interface{}:
+		reason, _ = payload["reason"].(string)
+	}
+
+	return []reflect.Value{reflect.ValueOf(reason)}, nil
+}
+
+var disconnectPayloadType = []reflect.Type{reflect.TypeOf((*interface{})(nil)).Elem()}
+
+// errorPacketHandler discards CONNECT_ERROR packet sent by client, see ProtocolEdgeCases.ErrorPackets.
+func errorPacketHandler(c *conn, _ parser.Header) error {
+	if !c.edgeCases.ErrorPackets {
+		return nil
+	}
+
+	return c.decoder.DiscardLast()
+}
@@ -0,0 +1,165 @@
+package socketio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func newEdgeCasesServer(t *testing.T, configs ...func(*Server)) (*Server, *protocolClient) {
+	configs = append([]func(*Server){func(s *Server) {
+		require.NoError(t, s.SetProtocolEdgeCases(V5EdgeCases()))
+	}}, configs...)
+	server, url := newConformanceServer(t, configs...)
+
+	c := newProtocolClient(t, url)
+	t.Cleanup(func() {
+		_ = c.conn.Close()
+	})
+
+	_, _, _ = c.receive(t)
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/chat"})
+	_, _, _ = c.receive(t)
+
+	return server, c
+}
+
+// sendRaw sends text packet as it is, e.g. of payload which parser.Encoder doesn't produce.
+func (c *protocolClient) sendRaw(t *testing.T, packet string) {
+	w, err := c.conn.NextWriter(session.TEXT)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte(packet))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+// expectEcho checks that connection still reads packets, by ack of echo event.
+func (c *protocolClient) expectEcho(t *testing.T, id uint64) {
+	c.send(t, parser.Header{Type: parser.Event, ID: id, NeedAck: true}, "echo", "still")
+
+	header, _, args := c.receive(t, reflect.TypeOf(""))
+	assert.Equal(t, parser.Ack, header.Type)
+	assert.Equal(t, id, header.ID)
+	assert.Equal(t, []interface{}{"still"}, args)
+}
+
+func TestConformanceAckIDReuse(t *testing.T) {
+	should := assert.New(t)
+
+	release := make(chan struct{})
+	errs := make(chan error, 1)
+	_, c := newEdgeCasesServer(t, func(s *Server) {
+		s.OnEvent("/", "slow", func(_ Conn, msg string) string {
+			<-release
+			return msg
+		})
+		// events are handled concurrently, so the reused id is read while it's pending
+		s.SerializeEvent("/", "slow", func(_ Conn, _ string, args []interface{}) string {
+			return args[0].(string)
+		})
+		s.OnError("/", func(_ Conn, err error) {
+			errs <- err
+		})
+	})
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "slow", "first")
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "slow", "reused")
+
+	select {
+	case err := <-errs:
+		should.ErrorIs(err, ErrAckIDReused)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reused ack id isn't reported")
+	}
+
+	close(release)
+	header, _, args := c.receive(t, reflect.TypeOf(""))
+	should.Equal(uint64(1), header.ID)
+	should.Equal([]interface{}{"first"}, args)
+
+	// id is free once it's acked
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "slow", "again")
+	header, _, args = c.receive(t, reflect.TypeOf(""))
+	should.Equal(uint64(1), header.ID)
+	should.Equal([]interface{}{"again"}, args)
+}
+
+func TestConformanceDisconnectPayload(t *testing.T) {
+	should := assert.New(t)
+
+	reasons := make(chan string, 2)
+	_, c := newEdgeCasesServer(t, func(s *Server) {
+		s.OnDisconnect("/chat", func(_ Conn, reason string) {
+			reasons <- reason
+		})
+	})
+
+	c.sendRaw(t, `1/chat,{"reason":"bye"}`)
+	c.expectEcho(t, 1)
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/chat"})
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Disconnect, Namespace: "/chat"}, map[string]interface{}{"reason": "bye"})
+	c.expectEcho(t, 2)
+
+	for _, want := range []string{"", "bye"} {
+		select {
+		case reason := <-reasons:
+			should.Equal(want, reason)
+		case <-time.After(5 * time.Second):
+			t.Fatal("namespace isn't disconnected")
+		}
+	}
+}
+
+func TestConformanceErrorPackets(t *testing.T) {
+	_, c := newEdgeCasesServer(t)
+
+	c.send(t, parser.Header{Type: parser.Error, Namespace: "/chat"}, map[string]interface{}{"message": "refused"})
+	c.sendRaw(t, `4{"message":"refused"}`)
+
+	c.expectEcho(t, 1)
+}
+
+func TestConformanceBinaryAck(t *testing.T) {
+	should := assert.New(t)
+
+	acked := make(chan []byte, 1)
+	_, c := newEdgeCasesServer(t, func(s *Server) {
+		s.OnEvent("/chat", "ask", func(conn Conn) {
+			conn.Emit("question", func(b *parser.Buffer) {
+				acked <- b.Data
+			})
+		})
+	})
+
+	c.send(t, parser.Header{Type: parser.Event, Namespace: "/chat"}, "ask")
+	header, event, _ := c.receive(t)
+	should.Equal("question", event)
+	should.True(header.NeedAck)
+
+	c.send(t, parser.Header{Type: parser.Ack, ID: header.ID, NeedAck: true, Namespace: "/chat"},
+		&parser.Buffer{Data: []byte{1, 2}})
+
+	select {
+	case data := <-acked:
+		should.Equal([]byte{1, 2}, data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("binary ack isn't handled")
+	}
+
+	// attachments of namespace which isn't connected are skipped
+	c.send(t, parser.Header{Type: parser.Ack, ID: 9, NeedAck: true, Namespace: "/other"},
+		&parser.Buffer{Data: []byte{3}})
+	c.send(t, parser.Header{Type: parser.Event, Namespace: "/other"}, "binary", &parser.Buffer{Data: []byte{4}})
+
+	c.expectEcho(t, 1)
+}
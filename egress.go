@@ -0,0 +1,102 @@
+package socketio
+
+import (
+	"time"
+)
+
+// EgressShaping limits downstream bandwidth of each connection, see Server.ShapeEgress.
+type EgressShaping struct {
+	// BytesPerSecond is sustained rate of data written to connection.
+	BytesPerSecond int
+
+	// Burst is size in bytes written without delay after connection was idle,
+	// default is BytesPerSecond.
+	Burst int
+}
+
+func (o *EgressShaping) getBurst() int {
+	if o.Burst <= 0 {
+		return o.BytesPerSecond
+	}
+
+	return o.Burst
+}
+
+// ShapeEgress limits bandwidth of packets written to each connection, so a few data-hungry
+// connections can't take all of it. Writer of connection waits once its bucket is spent, so
+// packets wait in the write queue, broadcasts give up on them after their write timeout.
+// Nil opts disables shaping.
+func (s *Server) ShapeEgress(opts *EgressShaping) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	if opts == nil || opts.BytesPerSecond <= 0 {
+		s.egress = nil
+		return nil
+	}
+
+	s.egress = opts
+
+	return nil
+}
+
+// tokenBucket is token bucket of bytes written to connection, it's used by writer only.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(opts *EgressShaping) *tokenBucket {
+	if opts == nil {
+		return nil
+	}
+
+	burst := float64(opts.getBurst())
+
+	return &tokenBucket{
+		rate:   float64(opts.BytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// take spends n bytes, which were already written, and gives how long writer must wait
+// until the bucket refills its debt.
+func (b *tokenBucket) take(n int, now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// shapeEgress delays writer of connection by size of last written packet.
+func (c *conn) shapeEgress() {
+	if c.egress == nil {
+		return
+	}
+
+	wait := c.egress.take(c.encoder.LastSize(), time.Now())
+	if wait <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-c.quitChan:
+	}
+}
@@ -0,0 +1,50 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	should := assert.New(t)
+
+	b := newTokenBucket(&EgressShaping{BytesPerSecond: 1000, Burst: 500})
+	now := b.last
+
+	should.Zero(b.take(500, now), "burst")
+	should.Equal(100*time.Millisecond, b.take(100, now), "debt of 100 bytes")
+
+	now = now.Add(100 * time.Millisecond)
+	should.Zero(b.take(0, now), "debt refilled")
+
+	now = now.Add(time.Hour)
+	should.Zero(b.take(500, now), "refill is capped by burst")
+	should.Equal(time.Second, b.take(1000, now))
+
+	should.Nil(newTokenBucket(nil))
+	should.Equal(1000, (&EgressShaping{BytesPerSecond: 1000}).getBurst())
+}
+
+func TestConnShapeEgress(t *testing.T) {
+	should := assert.New(t)
+
+	c, _ := newDeliveryConn(&failingEngineConn{})
+	c.egress = newTokenBucket(&EgressShaping{BytesPerSecond: 100, Burst: 1})
+	nc, _ := c.namespaces.Get("/chat")
+
+	go nc.Emit("message", "hello")
+
+	start := time.Now()
+	c.writePacket(<-c.writeChan)
+	should.GreaterOrEqual(time.Since(start), 100*time.Millisecond, "writer waits for debt of packet")
+
+	// closed connection doesn't wait for its bucket
+	close(c.quitChan)
+	c.egress.tokens = -1000
+
+	start = time.Now()
+	c.shapeEgress()
+	should.Less(time.Since(start), time.Second)
+}
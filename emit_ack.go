@@ -0,0 +1,83 @@
+package socketio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// SetAckExpiry drops acks of emitted events which aren't received within ttl, so callbacks of
+// clients which never acknowledge don't pile up for the lifetime of connection. Expired acks are
+// collected when connection emits another event which needs ack, their callbacks aren't called.
+// ttl should be longer than timeouts of EmitWithAck. Zero, the default, keeps acks until
+// connection closes.
+func (s *Server) SetAckExpiry(ttl time.Duration) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.ackExpiry = ttl
+
+	return nil
+}
+
+// EmitWithAck emits event and waits for its ack, it gives arguments of ack as values of any JSON
+// type. It fails with ErrAckTimeout once ctx is done, also when event isn't taken by the writer of
+// connection yet, and with ErrConnClosed when connection is closed before ack is received. Ack
+// which is received after timeout is ignored.
+func (nc *namespaceConn) EmitWithAck(ctx context.Context, eventName string, v ...interface{}) ([]interface{}, error) {
+	if err := nc.checkEmit(eventName); err != nil {
+		return nil, err
+	}
+
+	replies := make(chan []interface{}, 1)
+	ack := &funcHandler{
+		f: reflect.ValueOf(func(args ...interface{}) {
+			replies <- args
+		}),
+		variadic: true,
+	}
+
+	// write which waits for stalled writer is cancelled by ctx, so the ack isn't awaited then
+	id := nc.emitWith(writeOptions{ctx: ctx}, eventName, append(v[:len(v):len(v)], ack)...)
+	if id == 0 {
+		return nil, ErrConnClosed
+	}
+	if err := ctx.Err(); err != nil {
+		nc.forgetAck(id)
+		return nil, fmt.Errorf("%w: %w", ErrAckTimeout, err)
+	}
+
+	select {
+	case args := <-replies:
+		return args, nil
+	case <-ctx.Done():
+		nc.forgetAck(id)
+		return nil, fmt.Errorf("%w: %w", ErrAckTimeout, ctx.Err())
+	case <-nc.quitChan:
+		nc.forgetAck(id)
+		return nil, ErrConnClosed
+	}
+}
+
+// expireAcks forgets acks older than expiry of connection, at most once per expiry period.
+func (nc *namespaceConn) expireAcks(now time.Time) {
+	if nc.ackExpiry <= 0 {
+		return
+	}
+
+	swept := atomic.LoadInt64(&nc.acksSwept)
+	if now.UnixNano()-swept < int64(nc.ackExpiry) || !atomic.CompareAndSwapInt64(&nc.acksSwept, swept, now.UnixNano()) {
+		return
+	}
+
+	nc.ackSent.Range(func(id, sent interface{}) bool {
+		if now.Sub(sent.(time.Time)) > nc.ackExpiry {
+			nc.forgetAck(id.(uint64))
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,177 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestEmitWithAck(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	type result struct {
+		reply []interface{}
+		err   error
+	}
+	results := make(chan result, 1)
+	pending := make(chan int, 1)
+	_, url := newConformanceServer(t, func(server *Server) {
+		server.OnEvent("/", "start", func(c Conn, timeout int) {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+				defer cancel()
+
+				reply, err := c.EmitWithAck(ctx, "question", "ready?")
+				pending <- c.(*namespaceConn).pendingAcks()
+				results <- result{reply: reply, err: err}
+			}()
+		})
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event}, "start", 5000)
+
+	header, event, args := c.receive(t, reflect.TypeOf(""))
+	must.Equal(parser.Event, header.Type)
+	must.True(header.NeedAck)
+	should.Equal("question", event)
+	should.Equal([]interface{}{"ready?"}, args)
+
+	c.send(t, parser.Header{Type: parser.Ack, ID: header.ID, NeedAck: true}, "yes", 42, map[string]interface{}{"n": 1})
+
+	res := <-results
+	must.NoError(res.err)
+	should.Equal([]interface{}{"yes", float64(42), map[string]interface{}{"n": float64(1)}}, res.reply)
+	should.Zero(<-pending)
+
+	c.send(t, parser.Header{Type: parser.Event}, "start", 50)
+
+	header, _, _ = c.receive(t, reflect.TypeOf(""))
+	res = <-results
+	should.True(errors.Is(res.err, ErrAckTimeout))
+	should.True(errors.Is(res.err, context.DeadlineExceeded))
+	should.Nil(res.reply)
+	should.Zero(<-pending, "ack of timed out event is forgotten")
+
+	// late ack is ignored
+	c.send(t, parser.Header{Type: parser.Ack, ID: header.ID, NeedAck: true}, "late")
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "echo", "alive")
+	header, _, args = c.receive(t, reflect.TypeOf(""))
+	should.Equal(parser.Ack, header.Type)
+	should.Equal([]interface{}{"alive"}, args)
+}
+
+func TestEmitWithAckConcurrent(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	const emits = 8
+
+	replies := make(chan [2]interface{}, emits)
+	_, url := newConformanceServer(t, func(server *Server) {
+		server.OnEvent("/", "start", func(c Conn) {
+			for i := 0; i < emits; i++ {
+				go func(i int) {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					reply, err := c.EmitWithAck(ctx, "question", i)
+					if err != nil || len(reply) == 0 {
+						replies <- [2]interface{}{i, err}
+						return
+					}
+					replies <- [2]interface{}{i, reply[0]}
+				}(i)
+			}
+		})
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event}, "start")
+
+	// every emit gets its own ack id, so reply reaches its caller
+	ids := make(map[uint64]bool)
+	for i := 0; i < emits; i++ {
+		header, event, args := c.receive(t, reflect.TypeOf(0))
+		must.True(header.NeedAck)
+		should.Equal("question", event)
+		should.False(ids[header.ID], "ack id %d is reused", header.ID)
+		ids[header.ID] = true
+
+		c.send(t, parser.Header{Type: parser.Ack, ID: header.ID, NeedAck: true}, args[0])
+	}
+
+	for i := 0; i < emits; i++ {
+		reply := <-replies
+		should.Equal(float64(reply[0].(int)), reply[1])
+	}
+}
+
+func TestAckExpiry(t *testing.T) {
+	should := assert.New(t)
+
+	c := newConn(addrEngineConn{id: "sid1"}, newNamespaceHandlers())
+	c.ackExpiry = 20 * time.Millisecond
+	nc := newNamespaceConn(c, "/", nil)
+
+	nc.storeAck(1, newAckFunc(func() {}))
+	time.Sleep(30 * time.Millisecond)
+	nc.storeAck(2, newAckFunc(func() {}))
+
+	_, ok := nc.ack.Load(uint64(1))
+	should.False(ok, "expired ack is forgotten")
+	_, ok = nc.ack.Load(uint64(2))
+	should.True(ok)
+
+	nc.storeAck(3, newAckFunc(func() {}))
+	_, ok = nc.ack.Load(uint64(2))
+	should.True(ok, "acks aren't collected again before expiry")
+
+	c.ackExpiry = 0
+	time.Sleep(30 * time.Millisecond)
+	nc.storeAck(4, newAckFunc(func() {}))
+	_, ok = nc.ack.Load(uint64(2))
+	should.True(ok, "acks don't expire by default")
+
+	_, err := (&namespaceConn{conn: &conn{closing: 1}}).EmitWithAck(context.Background(), "question")
+	should.Equal(ErrConnClosed, err)
+}
+
+func TestEmitWithAckStalledWriter(t *testing.T) {
+	should := assert.New(t)
+
+	// writer of connection never takes packets
+	c := newConn(addrEngineConn{id: "sid1"}, newNamespaceHandlers())
+	nc := newNamespaceConn(c, "/", nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- (<-c.errorChan).(*errorMessage).err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	reply, err := nc.EmitWithAck(ctx, "question", "ready?")
+	should.Less(time.Since(start), time.Second)
+	should.True(errors.Is(err, ErrAckTimeout))
+	should.True(errors.Is(err, context.DeadlineExceeded))
+	should.Nil(reply)
+	should.Zero(nc.pendingAcks(), "ack of event which isn't written is forgotten")
+	should.True(errors.Is(<-errs, context.DeadlineExceeded), "failed delivery is reported")
+}
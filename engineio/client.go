@@ -1,6 +1,8 @@
 package engineio
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"net"
 	"net/http"
@@ -10,16 +12,26 @@ import (
 
 	"github.com/thisismz/go-socket.io/engineio/frame"
 	"github.com/thisismz/go-socket.io/engineio/packet"
+	"github.com/thisismz/go-socket.io/engineio/payload"
 	"github.com/thisismz/go-socket.io/engineio/session"
 	"github.com/thisismz/go-socket.io/engineio/transport"
 	"github.com/thisismz/go-socket.io/logger"
 )
 
+const probeMessage = "probe"
+
+var errInvalidProbe = errors.New("invalid probe response")
+
 // Opener is client connection which need receive open message first.
 type Opener interface {
 	Open() (transport.ConnParameters, error)
 }
 
+// Drainer is client connection which can wait until its written frames are sent.
+type Drainer interface {
+	Drain()
+}
+
 type client struct {
 	conn      transport.Conn
 	params    transport.ConnParameters
@@ -27,6 +39,14 @@ type client struct {
 	context   interface{}
 	close     chan struct{}
 	closeOnce sync.Once
+	onPong    func()
+
+	upgradeLocker sync.RWMutex
+
+	// writes are buffered while connection is upgrading, and flushed once upgrading is done.
+	writeLocker sync.RWMutex
+	upgrading   bool
+	buffered    []*bufferedWriter
 }
 
 func (c *client) SetContext(v interface{}) {
@@ -42,31 +62,36 @@ func (c *client) ID() string {
 }
 
 func (c *client) Transport() string {
+	c.upgradeLocker.RLock()
+	defer c.upgradeLocker.RUnlock()
+
 	return c.transport
 }
 
+// Close sends close packet to server and closes connection.
 func (c *client) Close() error {
-	c.closeOnce.Do(func() {
-		close(c.close)
-	})
-	return c.conn.Close()
+	return c.closeConn(true)
 }
 
 func (c *client) NextReader() (session.FrameType, io.ReadCloser, error) {
 	for {
-		ft, pt, r, err := c.conn.NextReader()
+		conn, ft, pt, r, err := c.nextReader()
 		if err != nil {
 			return 0, nil, err
 		}
 
 		switch pt {
 		case packet.PONG:
-			if err = c.conn.SetReadDeadline(time.Now().Add(c.params.PingInterval + c.params.PingTimeout)); err != nil {
+			if err = conn.SetReadDeadline(time.Now().Add(c.params.PingInterval + c.params.PingTimeout)); err != nil {
 				return 0, nil, err
 			}
 
+			if c.onPong != nil {
+				c.onPong()
+			}
+
 		case packet.CLOSE:
-			if err = c.Close(); err != nil {
+			if err = c.closeConn(false); err != nil {
 				logger.Error("close client with packet close:", err)
 			}
 
@@ -83,28 +108,256 @@ func (c *client) NextReader() (session.FrameType, io.ReadCloser, error) {
 }
 
 func (c *client) NextWriter(typ session.FrameType) (io.WriteCloser, error) {
-	return c.conn.NextWriter(frame.Type(typ), packet.MESSAGE)
+	_, w, err := c.nextWriter(frame.Type(typ), packet.MESSAGE)
+	return w, err
 }
 
 func (c *client) URL() url.URL {
-	return c.conn.URL()
+	return c.getConn().URL()
 }
 
 func (c *client) LocalAddr() net.Addr {
-	return c.conn.LocalAddr()
+	return c.getConn().LocalAddr()
 }
 
 func (c *client) RemoteAddr() net.Addr {
-	return c.conn.RemoteAddr()
+	return c.getConn().RemoteAddr()
 }
 
 func (c *client) RemoteHeader() http.Header {
-	return c.conn.RemoteHeader()
+	return c.getConn().RemoteHeader()
+}
+
+// closeConn closes connection, server is notified unless connection is closed by it.
+func (c *client) closeConn(notify bool) error {
+	c.closeOnce.Do(func() {
+		// serve closes connection once close is closed, so close packet is sent first
+		if notify {
+			c.sendClose()
+		}
+
+		close(c.close)
+	})
+
+	return c.getConn().Close()
+}
+
+// sendClose writes close packet, and waits until it's sent by connection.
+func (c *client) sendClose() {
+	conn := c.getConn()
+	if err := conn.SetWriteDeadline(time.Now().Add(c.params.PingTimeout)); err != nil {
+		logger.Error("set close writer deadline:", err)
+
+		return
+	}
+
+	_, w, err := c.nextWriter(frame.String, packet.CLOSE)
+	if err != nil {
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	if d, ok := conn.(Drainer); ok {
+		d.Drain()
+	}
+}
+
+// SetWriteCompression enables or disables compression of subsequent messages of current transport.
+func (c *client) SetWriteCompression(enable bool) {
+	if compressor, ok := c.getConn().(Compressor); ok {
+		compressor.SetWriteCompression(enable)
+	}
+}
+
+func (c *client) getConn() transport.Conn {
+	c.upgradeLocker.RLock()
+	defer c.upgradeLocker.RUnlock()
+
+	return c.conn
+}
+
+// nextReader reads from current connection, retrying while connection is paused or replaced by upgrade.
+func (c *client) nextReader() (transport.Conn, frame.Type, packet.Type, io.ReadCloser, error) {
+	for {
+		conn := c.getConn()
+
+		ft, pt, r, err := conn.NextReader()
+		if err != nil {
+			if op, ok := err.(payload.Error); ok && op.Temporary() {
+				continue
+			}
+			if conn != c.getConn() {
+				continue
+			}
+			return nil, 0, 0, nil, err
+		}
+		return conn, ft, pt, r, nil
+	}
+}
+
+// nextWriter writes to current connection, writes are buffered while connection is upgrading.
+func (c *client) nextWriter(ft frame.Type, pt packet.Type) (transport.Conn, io.WriteCloser, error) {
+	c.writeLocker.RLock()
+
+	conn := c.getConn()
+
+	if c.upgrading {
+		c.writeLocker.RUnlock()
+
+		return conn, &bufferedWriter{client: c, ft: ft, pt: pt}, nil
+	}
+
+	w, err := conn.NextWriter(ft, pt)
+	if err != nil {
+		c.writeLocker.RUnlock()
+
+		return nil, nil, err
+	}
+
+	return conn, &clientWriter{WriteCloser: w, locker: c.writeLocker.RLocker()}, nil
+}
+
+// setUpgrading waits for writers in progress, and starts or stops buffering writes.
+// Buffered writes are flushed to current connection when buffering stops.
+func (c *client) setUpgrading(upgrading bool) {
+	c.writeLocker.Lock()
+	defer c.writeLocker.Unlock()
+
+	c.upgrading = upgrading
+	if upgrading {
+		return
+	}
+
+	conn := c.getConn()
+	for _, b := range c.buffered {
+		if err := b.flush(conn); err != nil {
+			logger.Error("flush buffered write:", err)
+		}
+	}
+	c.buffered = nil
+}
+
+// upgrade probes connection of transport t, and replaces current connection with it
+// when probe succeeds, like browser clients do.
+func (c *client) upgrade(t transport.Transport, u url.URL, requestHeader http.Header) {
+	query := u.Query()
+	query.Set("sid", c.params.SID)
+	u.RawQuery = query.Encode()
+
+	conn, err := t.Dial(&u, requestHeader)
+	if err != nil {
+		logger.Error("dial upgrade transport:", err)
+
+		return
+	}
+
+	// server pauses old connection once it answers the probe, so nothing is written until upgraded.
+	c.setUpgrading(true)
+	defer c.setUpgrading(false)
+
+	old := c.getConn()
+	if d, ok := old.(Drainer); ok {
+		d.Drain()
+	}
+
+	if err = c.probe(conn); err != nil {
+		logger.Error("probe upgrade transport:", err)
+
+		if closeErr := conn.Close(); closeErr != nil {
+			logger.Error("close upgrade connect:", closeErr)
+		}
+
+		return
+	}
+
+	p, ok := old.(session.Pauser)
+	if !ok {
+		// old transport doesn't support upgrading
+		if closeErr := conn.Close(); closeErr != nil {
+			logger.Error("close upgrade connect:", closeErr)
+		}
+
+		return
+	}
+
+	p.Pause()
+
+	w, err := conn.NextWriter(frame.String, packet.UPGRADE)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		logger.Error("write upgrade packet:", err)
+
+		p.Resume()
+
+		if closeErr := conn.Close(); closeErr != nil {
+			logger.Error("close upgrade connect:", closeErr)
+		}
+
+		return
+	}
+
+	c.upgradeLocker.Lock()
+	c.conn = conn
+	c.transport = t.Name()
+	c.upgradeLocker.Unlock()
+
+	if closeErr := old.Close(); closeErr != nil {
+		logger.Error("close old connection:", closeErr)
+	}
+}
+
+// probe sends ping probe to conn and waits for pong probe.
+func (c *client) probe(conn transport.Conn) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(c.params.PingTimeout)); err != nil {
+		return err
+	}
+
+	w, err := conn.NextWriter(frame.String, packet.PING)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write([]byte(probeMessage)); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(c.params.PingTimeout)); err != nil {
+		return err
+	}
+
+	_, pt, r, err := conn.NextReader()
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r)
+	if closeErr := r.Close(); closeErr != nil {
+		logger.Error("close probe reader:", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if pt != packet.PONG || string(data) != probeMessage {
+		return errInvalidProbe
+	}
+
+	return conn.SetReadDeadline(time.Time{})
 }
 
 func (c *client) serve() {
 	defer func() {
-		if closeErr := c.conn.Close(); closeErr != nil {
+		if closeErr := c.getConn().Close(); closeErr != nil {
 			logger.Error("close connect:", closeErr)
 		}
 	}()
@@ -116,7 +369,7 @@ func (c *client) serve() {
 		case <-time.After(c.params.PingInterval):
 		}
 
-		w, err := c.conn.NextWriter(frame.String, packet.PING)
+		conn, w, err := c.nextWriter(frame.String, packet.PING)
 		if err != nil {
 			logger.Error("get next writer with string frame and packet ping:", err)
 
@@ -129,8 +382,60 @@ func (c *client) serve() {
 			return
 		}
 
-		if err = c.conn.SetWriteDeadline(time.Now().Add(c.params.PingInterval + c.params.PingTimeout)); err != nil {
+		if err = conn.SetWriteDeadline(time.Now().Add(c.params.PingInterval + c.params.PingTimeout)); err != nil {
 			logger.Error("set writer deadline:", err)
 		}
 	}
 }
+
+// bufferedWriter buffers a frame written while connection is upgrading.
+type bufferedWriter struct {
+	bytes.Buffer
+
+	client *client
+	ft     frame.Type
+	pt     packet.Type
+}
+
+func (w *bufferedWriter) Close() error {
+	w.client.writeLocker.Lock()
+	defer w.client.writeLocker.Unlock()
+
+	if !w.client.upgrading {
+		return w.flush(w.client.getConn())
+	}
+
+	w.client.buffered = append(w.client.buffered, w)
+
+	return nil
+}
+
+func (w *bufferedWriter) flush(conn transport.Conn) error {
+	fw, err := conn.NextWriter(w.ft, w.pt)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fw.Write(w.Bytes()); err != nil {
+		_ = fw.Close()
+		return err
+	}
+
+	return fw.Close()
+}
+
+// clientWriter releases the write lock of client on Close.
+type clientWriter struct {
+	io.WriteCloser
+
+	locker    sync.Locker
+	closeOnce sync.Once
+}
+
+func (w *clientWriter) Close() error {
+	err := w.WriteCloser.Close()
+
+	w.closeOnce.Do(w.locker.Unlock)
+
+	return err
+}
@@ -22,3 +22,17 @@ type Conn interface {
 	SetContext(v interface{})
 	Context() interface{}
 }
+
+// Compressor is connection which can enable or disable per-message compression of subsequent
+// messages, e.g. websocket with negotiated permessage-deflate. Transports without compression
+// ignore it.
+type Compressor interface {
+	SetWriteCompression(enable bool)
+}
+
+// ReadPauser is connection whose transport can be paused, e.g. polling, which holds requests of
+// client while paused. PauseReads is false when transport can't be paused, e.g. websocket.
+type ReadPauser interface {
+	PauseReads() bool
+	ResumeReads()
+}
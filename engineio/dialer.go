@@ -1,6 +1,7 @@
 package engineio
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -14,10 +15,25 @@ import (
 // Dialer is dialer configure.
 type Dialer struct {
 	Transports []transport.Transport
+
+	// OnPong is called when server answers ping of connection.
+	OnPong func()
+
+	// Upgrade dials Transports from first to last, and upgrades connection to
+	// next transports advertised by server, like browser clients do.
+	// Otherwise Transports are dialed from last to first without upgrading.
+	Upgrade bool
 }
 
 // Dial returns a connection which dials to url with requestHeader.
 func (d *Dialer) Dial(urlStr string, requestHeader http.Header) (Conn, error) {
+	return d.DialContext(context.Background(), urlStr, requestHeader)
+}
+
+// DialContext returns a connection which dials to url with requestHeader, dialing
+// is canceled when ctx is done. Transports not implementing transport.ContextDialer
+// are only checked against ctx before dialing.
+func (d *Dialer) DialContext(ctx context.Context, urlStr string, requestHeader http.Header) (Conn, error) {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		logger.Error("parse url str:", err)
@@ -31,16 +47,29 @@ func (d *Dialer) Dial(urlStr string, requestHeader http.Header) (Conn, error) {
 
 	var conn transport.Conn
 
-	for i := len(d.Transports) - 1; i >= 0; i-- {
+	for n := range d.Transports {
+		i := len(d.Transports) - 1 - n
+		if d.Upgrade {
+			i = n
+		}
+
 		if conn != nil {
 			if closeErr := conn.Close(); closeErr != nil {
 				logger.Error("close connect:", closeErr)
 			}
 		}
 
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		t := d.Transports[i]
 
-		conn, err = t.Dial(u, requestHeader)
+		if cd, ok := t.(transport.ContextDialer); ok {
+			conn, err = cd.DialContext(ctx, u, requestHeader)
+		} else {
+			conn, err = t.Dial(u, requestHeader)
+		}
 		if err != nil {
 			logger.Error("transport dial:", err)
 
@@ -94,12 +123,36 @@ func (d *Dialer) Dial(urlStr string, requestHeader http.Header) (Conn, error) {
 			params:    params,
 			transport: t.Name(),
 			close:     make(chan struct{}),
+			onPong:    d.OnPong,
 		}
 
 		go ret.serve()
 
+		if d.Upgrade {
+			if upgrade := d.upgradeTransport(i, params.Upgrades); upgrade != nil {
+				go ret.upgrade(upgrade, *u, requestHeader)
+			}
+		}
+
 		return ret, nil
 	}
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
 	return nil, err
 }
+
+// upgradeTransport returns first transport after i which is advertised in upgrades.
+func (d *Dialer) upgradeTransport(i int, upgrades []string) transport.Transport {
+	for _, t := range d.Transports[i+1:] {
+		for _, name := range upgrades {
+			if t.Name() == name {
+				return t
+			}
+		}
+	}
+
+	return nil
+}
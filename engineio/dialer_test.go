@@ -0,0 +1,125 @@
+package engineio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
+)
+
+func TestDialerUpgrade(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	svr := NewServer(nil)
+	defer func() {
+		must.NoError(svr.Close())
+	}()
+
+	httpSvr := httptest.NewServer(svr)
+	defer httpSvr.Close()
+
+	go func() {
+		conn, err := svr.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			ft, r, err := conn.NextReader()
+			if err != nil {
+				return
+			}
+
+			b, err := io.ReadAll(r)
+			_ = r.Close()
+			if err != nil {
+				return
+			}
+
+			w, err := conn.NextWriter(ft)
+			if err != nil {
+				return
+			}
+
+			_, _ = w.Write(b)
+			_ = w.Close()
+		}
+	}()
+
+	dialer := Dialer{
+		Transports: []transport.Transport{polling.Default, websocket.Default},
+		Upgrade:    true,
+	}
+
+	conn, err := dialer.Dial(httpSvr.URL, nil)
+	must.NoError(err)
+	defer conn.Close()
+
+	c, ok := conn.(*client)
+	must.True(ok)
+	should.Equal("polling", c.Transport())
+
+	echo := func(msg string) string {
+		w, err := conn.NextWriter(session.TEXT)
+		must.NoError(err)
+
+		_, err = w.Write([]byte(msg))
+		must.NoError(err)
+		must.NoError(w.Close())
+
+		_, r, err := conn.NextReader()
+		must.NoError(err)
+		defer r.Close()
+
+		b, err := io.ReadAll(r)
+		must.NoError(err)
+
+		return string(b)
+	}
+
+	// keep echoing while connection is upgrading, nothing should be lost.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; c.Transport() != "websocket"; i++ {
+		must.True(time.Now().Before(deadline), "connection wasn't upgraded")
+
+		msg := fmt.Sprint("before ", i)
+		should.Equal(msg, echo(msg))
+	}
+
+	should.Equal("after", echo("after"))
+}
+
+func TestDialerDialContext(t *testing.T) {
+	should := assert.New(t)
+
+	// server which never answers open request
+	httpSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer httpSvr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	dialer := Dialer{
+		Transports: []transport.Transport{polling.Default},
+	}
+
+	start := time.Now()
+	_, err := dialer.DialContext(ctx, httpSvr.URL, nil)
+	should.ErrorIs(err, context.DeadlineExceeded)
+	should.Less(time.Since(start), 5*time.Second)
+}
@@ -13,6 +13,8 @@ type FrameReader interface {
 
 type Decoder struct {
 	r FrameReader
+	// rawBinary reads binary frames as messages without packet type, like engine.io protocol 4.
+	rawBinary bool
 }
 
 func NewDecoder(r FrameReader) *Decoder {
@@ -21,11 +23,22 @@ func NewDecoder(r FrameReader) *Decoder {
 	}
 }
 
+// NewDecoderV4 gives decoder of engine.io protocol 4, whose binary frames are messages.
+func NewDecoderV4(r FrameReader) *Decoder {
+	return &Decoder{
+		r:         r,
+		rawBinary: true,
+	}
+}
+
 func (e *Decoder) NextReader() (frame.Type, Type, io.ReadCloser, error) {
 	ft, r, err := e.r.NextReader()
 	if err != nil {
 		return 0, 0, nil, err
 	}
+	if ft == frame.Binary && e.rawBinary {
+		return ft, MESSAGE, r, nil
+	}
 	var b [1]byte
 	if _, err := io.ReadFull(r, b[:]); err != nil {
 		_ = r.Close()
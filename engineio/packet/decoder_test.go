@@ -58,6 +58,22 @@ var tests = []struct {
 	},
 }
 
+// testsV4 are packets of engine.io protocol 4, whose binary frames are messages without type.
+var (
+	testsV4 = []Packet{
+		{frame.String, OPEN, []byte{}},
+		{frame.Binary, MESSAGE, []byte{0, 1, 2}},
+		{frame.String, MESSAGE, []byte("hello")},
+		{frame.String, PING, []byte{}},
+	}
+	framesV4 = []Frame{
+		{frame.String, []byte("0")},
+		{frame.Binary, []byte{0, 1, 2}},
+		{frame.String, []byte("4hello")},
+		{frame.String, []byte("2")},
+	}
+)
+
 func TestDecoder(t *testing.T) {
 	should := assert.New(t)
 	must := require.New(t)
@@ -89,6 +105,27 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestDecoderV4(t *testing.T) {
+	must := require.New(t)
+
+	decoder := NewDecoderV4(NewFakeConnReader(framesV4))
+	var output []Packet
+	for {
+		ft, pt, fr, err := decoder.NextReader()
+		if err != nil {
+			must.Equal(io.EOF, err)
+			break
+		}
+
+		b, err := ioutil.ReadAll(fr)
+		must.NoError(err)
+		must.NoError(fr.Close())
+
+		output = append(output, Packet{FType: ft, PType: pt, Data: b})
+	}
+	must.Equal(testsV4, output)
+}
+
 func BenchmarkDecoder(b *testing.B) {
 	decoder := NewDecoder(NewFakeConstReader())
 
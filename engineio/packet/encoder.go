@@ -14,6 +14,8 @@ type FrameWriter interface {
 
 type Encoder struct {
 	w FrameWriter
+	// rawBinary writes binary messages without packet type, like engine.io protocol 4.
+	rawBinary bool
 }
 
 func NewEncoder(w FrameWriter) *Encoder {
@@ -22,12 +24,25 @@ func NewEncoder(w FrameWriter) *Encoder {
 	}
 }
 
+// NewEncoderV4 gives encoder of engine.io protocol 4, whose binary frames are messages
+// written as they are.
+func NewEncoderV4(w FrameWriter) *Encoder {
+	return &Encoder{
+		w:         w,
+		rawBinary: true,
+	}
+}
+
 func (e *Encoder) NextWriter(ft frame.Type, pt Type) (io.WriteCloser, error) {
 	w, err := e.w.NextWriter(ft)
 	if err != nil {
 		return nil, err
 	}
 
+	if ft == frame.Binary && e.rawBinary {
+		return w, nil
+	}
+
 	var b [1]byte
 	if ft == frame.String {
 		b[0] = pt.StringByte()
@@ -53,3 +53,18 @@ func BenchmarkEncoder(b *testing.B) {
 		}
 	}
 }
+
+func TestEncoderV4(t *testing.T) {
+	at := assert.New(t)
+
+	w := NewFakeConnWriter()
+	encoder := NewEncoderV4(w)
+	for _, p := range testsV4 {
+		fw, err := encoder.NextWriter(p.FType, p.PType)
+		at.Nil(err)
+		_, err = fw.Write(p.Data)
+		at.Nil(err)
+		at.Nil(fw.Close())
+	}
+	at.Equal(framesV4, w.Frames)
+}
@@ -25,10 +25,15 @@ var tests = []struct {
 			{frame.String, packet.MESSAGE, []byte("hello 你好")},
 		},
 	},
-	{true, []byte{0x01, 0x09, 0xff, 0x04, 'h', 'e', 'l', 'l', 'o', ' ', 0xe4, 0xbd, 0xa0, 0xe5, 0xa5, 0xbd}, []Packet{
+	{true, []byte{0x01, 0x01, 0x03, 0xff, 0x04, 'h', 'e', 'l', 'l', 'o', ' ', 0xe4, 0xbd, 0xa0, 0xe5, 0xa5, 0xbd}, []Packet{
 		{frame.Binary, packet.MESSAGE, []byte("hello 你好")},
 	},
 	},
+	// length of binary data is count of bytes, whatever they are
+	{true, []byte{0x01, 0x05, 0xff, 0x04, 0x84, 0xa4, 0xbd, 0xff}, []Packet{
+		{frame.Binary, packet.MESSAGE, []byte{0x84, 0xa4, 0xbd, 0xff}},
+	},
+	},
 	{true, []byte{
 		0x01, 0x07, 0xff, 0x04, 'h', 'e', 'l', 'l', 'o', '\n',
 		0x00, 0x04, 0xff, '4', 0xe4, 0xbd, 0xa0, 0xe5, 0xa5, 0xbd, '\n',
@@ -85,3 +90,30 @@ var tests = []struct {
 	},
 	},
 }
+
+// testsV4 are payloads of engine.io protocol 4 with packets they carry, packets are separated by
+// record separator and binary ones are base64 encoded.
+var testsV4 = []struct {
+	data    []byte
+	packets []Packet
+}{
+	{[]byte("0"), []Packet{
+		{frame.String, packet.OPEN, []byte{}},
+	},
+	},
+	{[]byte("4hello 你好"), []Packet{
+		{frame.String, packet.MESSAGE, []byte("hello 你好")},
+	},
+	},
+	{[]byte("baGVsbG8g5L2g5aW9"), []Packet{
+		{frame.Binary, packet.MESSAGE, []byte("hello 你好")},
+	},
+	},
+	{[]byte("3\x1e451-[\"bin\",{\"_placeholder\":true,\"num\":0}]\x1ebAQID\x1e2probe"), []Packet{
+		{frame.String, packet.PONG, []byte{}},
+		{frame.String, packet.MESSAGE, []byte("51-[\"bin\",{\"_placeholder\":true,\"num\":0}]")},
+		{frame.Binary, packet.MESSAGE, []byte{1, 2, 3}},
+		{frame.String, packet.PING, []byte("probe")},
+	},
+	},
+}
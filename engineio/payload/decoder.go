@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"io"
 	"io/ioutil"
+	"math"
 
 	"github.com/thisismz/go-socket.io/engineio/frame"
 	"github.com/thisismz/go-socket.io/engineio/packet"
@@ -29,6 +30,9 @@ type decoder struct {
 	ft            frame.Type
 	pt            packet.Type
 	supportBinary bool
+
+	// records decodes payload of engine.io protocol 4, see recordRead.
+	records bool
 }
 
 func (d *decoder) NextReader() (frame.Type, packet.Type, io.ReadCloser, error) {
@@ -54,6 +58,10 @@ func (d *decoder) Read(p []byte) (int, error) {
 		return d.b64Reader.Read(p)
 	}
 	dd, err := d.limitReader.Read(p)
+	if d.ft == frame.Binary || d.records {
+		return dd, err
+	}
+
 	unicodeCount := 0
 	for i := range p[:dd] {
 		b := p[i]
@@ -93,9 +101,14 @@ func (d *decoder) Close() error {
 
 func (d *decoder) setNextReader(r byteReader, supportBinary bool) error {
 	var read func(byteReader) (frame.Type, packet.Type, int64, error)
-	if supportBinary {
+	switch {
+	case d.records:
+		// binary packets are base64 whatever the content type of request is
+		supportBinary = false
+		read = d.recordRead
+	case supportBinary:
 		read = d.binaryRead
-	} else {
+	default:
 		read = d.textRead
 	}
 
@@ -109,6 +122,9 @@ func (d *decoder) setNextReader(r byteReader, supportBinary bool) error {
 	d.rawReader = r
 	d.limitReader.R = r
 	d.limitReader.N = l
+	if d.records {
+		d.limitReader.R = &recordReader{r: r}
+	}
 	d.supportBinary = supportBinary
 	if !supportBinary && ft == frame.Binary {
 		d.b64Reader = base64.NewDecoder(base64.StdEncoding, &d.limitReader)
@@ -175,3 +191,54 @@ func (d *decoder) binaryRead(r byteReader) (frame.Type, packet.Type, int64, erro
 
 	return ft, pt, l, nil
 }
+
+// recordRead reads type of packet of engine.io protocol 4, packets are separated by record
+// separator instead of length, and binary ones are "b" followed by base64 of data.
+func (d *decoder) recordRead(r byteReader) (frame.Type, packet.Type, int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if b == 'b' {
+		return frame.Binary, packet.MESSAGE, math.MaxInt64, nil
+	}
+
+	return frame.String, packet.ByteToPacketType(b, frame.String), math.MaxInt64, nil
+}
+
+// recordSeparator ends packet of payload of engine.io protocol 4.
+const recordSeparator = 0x1e
+
+// recordReader reads data of packet of engine.io protocol 4 until record separator, which is
+// consumed, or end of payload.
+type recordReader struct {
+	r   byteReader
+	end bool
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	if r.end {
+		return 0, io.EOF
+	}
+
+	for i := range p {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			r.end = true
+			return i, err
+		}
+
+		if b == recordSeparator {
+			r.end = true
+			if i == 0 {
+				return 0, io.EOF
+			}
+			return i, nil
+		}
+
+		p[i] = b
+	}
+
+	return len(p), nil
+}
@@ -77,6 +77,40 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
+func TestDecoderV4(t *testing.T) {
+	must := require.New(t)
+
+	for _, test := range testsV4 {
+		feeder := fakeReaderFeeder{
+			data: test.data,
+			// clients of protocol 4 post text, binary packets are base64 anyway
+			supportBinary: true,
+		}
+		d := decoder{
+			feeder:  &feeder,
+			records: true,
+		}
+
+		var packets []Packet
+		for {
+			ft, pt, fr, err := d.NextReader()
+			must.NoError(err)
+
+			data, err := ioutil.ReadAll(fr)
+			must.NoError(err)
+			must.NoError(fr.Close())
+
+			packets = append(packets, Packet{ft: ft, pt: pt, data: data})
+			if d.rawReader == nil {
+				break
+			}
+		}
+
+		must.Equal(test.packets, packets)
+		must.Equal(1, feeder.putCounter)
+	}
+}
+
 func TestDecoderNextReaderError(t *testing.T) {
 	assert := assert.New(t)
 
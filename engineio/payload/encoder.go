@@ -19,6 +19,9 @@ type encoder struct {
 	supportBinary bool
 	feeder        writerFeeder
 
+	// records encodes payload of engine.io protocol 4, see writeRecordHeader.
+	records bool
+
 	ft         frame.Type
 	pt         packet.Type
 	header     bytes.Buffer
@@ -28,6 +31,9 @@ type encoder struct {
 }
 
 func (e *encoder) NOOP() []byte {
+	if e.records {
+		return []byte("6")
+	}
 	if e.supportBinary {
 		return []byte{0x00, 0x01, 0xff, '6'}
 	}
@@ -69,14 +75,15 @@ func (e *encoder) Close() error {
 	}
 
 	var writeHeader func() error
-	if e.supportBinary {
+	switch {
+	case e.records:
+		writeHeader = e.writeRecordHeader
+	case e.supportBinary:
 		writeHeader = e.writeBinaryHeader
-	} else {
-		if e.ft == frame.Binary {
-			writeHeader = e.writeB64Header
-		} else {
-			writeHeader = e.writeTextHeader
-		}
+	case e.ft == frame.Binary:
+		writeHeader = e.writeB64Header
+	default:
+		writeHeader = e.writeTextHeader
 	}
 
 	e.header.Reset()
@@ -114,6 +121,16 @@ func (e *encoder) writeB64Header() error {
 	return err
 }
 
+// writeRecordHeader writes header of packet of engine.io protocol 4, which has no length, as
+// every flush carries a single packet, so no record separator is needed either.
+func (e *encoder) writeRecordHeader() error {
+	if e.ft == frame.Binary {
+		return e.header.WriteByte('b')
+	}
+
+	return e.header.WriteByte(e.pt.StringByte())
+}
+
 func (e *encoder) calcCodeUnitLength() int64 {
 	var l int64 = 1
 	var codeUnitSize int64
@@ -144,6 +161,8 @@ func (e *encoder) writeBinaryHeader() error {
 	l := int64(e.calcCodeUnitLength()) // length for packet type
 	b := e.pt.StringByte()
 	if e.ft == frame.Binary {
+		// binary data isn't text, its length is count of bytes
+		l = int64(e.frameCache.Len()) + 1
 		b = e.pt.BinaryByte()
 	}
 	err := e.header.WriteByte(e.ft.Byte())
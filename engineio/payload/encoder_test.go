@@ -62,6 +62,30 @@ func TestEncoder(t *testing.T) {
 	}
 }
 
+func TestEncoderV4(t *testing.T) {
+	must := require.New(t)
+	buf := bytes.NewBuffer(nil)
+
+	for _, test := range testsV4 {
+		// every flush carries a single packet
+		for i, packet := range test.packets {
+			buf.Reset()
+			e := encoder{
+				feeder:  &fakeWriterFeeder{w: buf},
+				records: true,
+			}
+
+			fw, err := e.NextWriter(packet.ft, packet.pt)
+			must.NoError(err)
+			_, err = fw.Write(packet.data)
+			must.NoError(err)
+			must.NoError(fw.Close())
+
+			must.Equal(bytes.Split(test.data, []byte{recordSeparator})[i], buf.Bytes())
+		}
+	}
+}
+
 func TestEncoderBeginError(t *testing.T) {
 	assert := assert.New(t)
 	buf := bytes.NewBuffer(nil)
@@ -132,6 +156,7 @@ func TestEncoderNOOP(t *testing.T) {
 		}
 		assert.Equal(test.data, e.NOOP())
 	}
+	assert.Equal([]byte("6"), (&encoder{records: true}).NOOP())
 
 	// NOOP should be thread-safe
 	var wg sync.WaitGroup
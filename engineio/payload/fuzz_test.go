@@ -0,0 +1,38 @@
+package payload
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func FuzzDecoder(f *testing.F) {
+	for _, test := range tests {
+		f.Add(test.data, test.supportBinary)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, supportBinary bool) {
+		feeder := fakeReaderFeeder{
+			data:          data,
+			supportBinary: supportBinary,
+		}
+		d := decoder{
+			feeder: &feeder,
+		}
+
+		// feeder gives the same data on every call, so stop once it's read again
+		for feeder.getCounter <= 1 {
+			_, _, fr, err := d.NextReader()
+			if err != nil {
+				return
+			}
+
+			if _, err = ioutil.ReadAll(fr); err != nil {
+				return
+			}
+
+			if err = fr.Close(); err != nil {
+				return
+			}
+		}
+	})
+}
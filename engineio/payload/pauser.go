@@ -16,6 +16,8 @@ type pauser struct {
 	worker  int
 	pausing chan struct{}
 	paused  chan struct{}
+	// resumed is closed by Resume, it's closed while pauser isn't paused.
+	resumed chan struct{}
 	status  pauserStatus
 }
 
@@ -23,8 +25,10 @@ func newPauser() *pauser {
 	ret := &pauser{
 		pausing: make(chan struct{}),
 		paused:  make(chan struct{}),
+		resumed: make(chan struct{}),
 		status:  statusNormal,
 	}
+	close(ret.resumed)
 	ret.c = sync.NewCond(&ret.l)
 	return ret
 }
@@ -38,6 +42,7 @@ func (p *pauser) Pause() bool {
 		return false
 	case statusNormal:
 		close(p.pausing)
+		p.resumed = make(chan struct{})
 		p.status = statusPausing
 	}
 
@@ -61,6 +66,12 @@ func (p *pauser) Resume() {
 	p.status = statusNormal
 	p.paused = make(chan struct{})
 	p.pausing = make(chan struct{})
+
+	select {
+	case <-p.resumed:
+	default:
+		close(p.resumed)
+	}
 }
 
 func (p *pauser) Working() bool {
@@ -94,3 +105,11 @@ func (p *pauser) PausedTrigger() <-chan struct{} {
 	defer p.l.Unlock()
 	return p.paused
 }
+
+// ResumedTrigger gives channel which is closed once pauser is resumed, it's closed already while
+// pauser isn't paused.
+func (p *pauser) ResumedTrigger() <-chan struct{} {
+	p.l.Lock()
+	defer p.l.Unlock()
+	return p.resumed
+}
@@ -32,6 +32,7 @@ type Payload struct {
 
 	writerChan    chan io.Writer
 	flushing      int32
+	releasing     chan struct{}
 	writeError    chan error
 	writeDeadline atomic.Value
 	encoder       encoder
@@ -45,6 +46,7 @@ func New(supportBinary bool) *Payload {
 		readerChan: make(chan readArg),
 		readError:  make(chan error),
 		writerChan: make(chan io.Writer),
+		releasing:  make(chan struct{}, 1),
 		writeError: make(chan error),
 	}
 	ret.readDeadline.Store(time.Time{})
@@ -55,6 +57,15 @@ func New(supportBinary bool) *Payload {
 	return ret
 }
 
+// NewV4 returns a new payload of engine.io protocol 4, whose packets are text separated by
+// record separator, binary ones are base64 encoded.
+func NewV4() *Payload {
+	ret := New(false)
+	ret.decoder.records = true
+	ret.encoder.records = true
+	return ret
+}
+
 // FeedIn feeds in a new reader for NextReader.
 // Multi-FeedIn needs be called sync.
 //
@@ -127,6 +138,13 @@ func (p *Payload) FeedIn(r io.Reader, supportBinary bool) error {
 // If NextWriter has timeout, it returns ErrTimeout.
 // If write error while FlushOut, it returns write error.
 func (p *Payload) FlushOut(w io.Writer) error {
+	return p.FlushOutWithin(w, 0)
+}
+
+// FlushOutWithin works like FlushOut, but flushs out a NOOP message and
+// returns nil if no writer comes within hold, or if Release is called before.
+// Zero hold waits till the write deadline.
+func (p *Payload) FlushOutWithin(w io.Writer, hold time.Duration) error {
 	select {
 	case <-p.close:
 		return p.load()
@@ -144,6 +162,19 @@ func (p *Payload) FlushOut(w io.Writer) error {
 	}
 	defer p.pauser.Done()
 
+	// release of former flush
+	select {
+	case <-p.releasing:
+	default:
+	}
+
+	var held <-chan time.Time
+	if hold > 0 {
+		timer := time.NewTimer(hold)
+		defer timer.Stop()
+		held = timer.C
+	}
+
 	for {
 		after, ok := p.writeTimeout()
 		if !ok {
@@ -160,6 +191,14 @@ func (p *Payload) FlushOut(w io.Writer) error {
 			_, err := w.Write(p.encoder.NOOP())
 			return err
 
+		case <-held:
+			_, err := w.Write(p.encoder.NOOP())
+			return err
+
+		case <-p.releasing:
+			_, err := w.Write(p.encoder.NOOP())
+			return err
+
 		case p.writerChan <- w:
 		}
 		break
@@ -225,6 +264,16 @@ func (p *Payload) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+// Release makes FlushOut which is waiting for writer flush out a NOOP message
+// and return, e.g. to serve a newer request instead.
+// It can call in multi-goroutine.
+func (p *Payload) Release() {
+	select {
+	case p.releasing <- struct{}{}:
+	default:
+	}
+}
+
 // Pause pauses the payload. It will wait all reader and writer closed which
 // created from NextReader or NextWriter.
 // It can call in multi-goroutine.
@@ -238,6 +287,16 @@ func (p *Payload) Resume() {
 	p.pauser.Resume()
 }
 
+// WaitResumed blocks while the payload is paused, till it's resumed, closed or done is closed.
+// It can call in multi-goroutine.
+func (p *Payload) WaitResumed(done <-chan struct{}) {
+	select {
+	case <-p.pauser.ResumedTrigger():
+	case <-p.close:
+	case <-done:
+	}
+}
+
 // Close closes the payload.
 // It can call in multi-goroutine.
 func (p *Payload) Close() error {
@@ -369,12 +428,9 @@ func (p *Payload) getWriter() (io.Writer, error) {
 	}
 }
 
+// putWriter hands the writer back to FlushOut, which waits for it even when payload
+// is closed meanwhile, so it's handed back regardless of Close.
 func (p *Payload) putWriter(err error) error {
-	select {
-	case <-p.close:
-		return p.load()
-	default:
-	}
 	for {
 		after, ok := p.writeTimeout()
 		if !ok {
@@ -382,8 +438,6 @@ func (p *Payload) putWriter(err error) error {
 		}
 		ret := p.Store("write", err)
 		select {
-		case <-p.close:
-			return p.load()
 		case <-after:
 			continue
 		case p.writeError <- err:
@@ -531,3 +531,48 @@ func TestPayloadNextClosePause(t *testing.T) {
 	should.Nil(err)
 	should.Equal([]byte{0x0, 0x1, 0xff, '6'}, b.Bytes())
 }
+
+func TestPayloadFlushOutWithin(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	p := New(false)
+
+	// nothing is written within hold
+	b := bytes.NewBuffer(nil)
+	start := time.Now()
+	must.NoError(p.FlushOutWithin(b, time.Second/10))
+	should.GreaterOrEqual(time.Since(start), time.Second/10)
+	should.Equal("1:6", b.String())
+
+	// released before hold
+	released := make(chan error, 1)
+	b = bytes.NewBuffer(nil)
+	go func() {
+		released <- p.FlushOutWithin(b, time.Minute)
+	}()
+
+	time.Sleep(time.Second / 10)
+	p.Release()
+
+	select {
+	case err := <-released:
+		must.NoError(err)
+		should.Equal("1:6", b.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("flush isn't released")
+	}
+
+	// written within hold
+	go func() {
+		w, err := p.NextWriter(frame.String, packet.MESSAGE)
+		must.NoError(err)
+		_, err = w.Write([]byte("hello"))
+		must.NoError(err)
+		must.NoError(w.Close())
+	}()
+
+	b = bytes.NewBuffer(nil)
+	must.NoError(p.FlushOutWithin(b, time.Minute))
+	should.Equal("6:4hello", b.String())
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,6 +17,10 @@ import (
 	"github.com/thisismz/go-socket.io/engineio/transport"
 )
 
+// handshakeMaxPayload is sent to clients of engine.io protocol 4 in handshake, they don't post
+// polling payloads which are larger, like the default of node.js engine.io.
+const handshakeMaxPayload = 1000000
+
 // Server is instance of server
 type Server struct {
 	pingInterval time.Duration
@@ -27,7 +32,19 @@ type Server struct {
 	requestChecker CheckerFunc
 	connInitor     ConnInitorFunc
 
+	onUpgradeFailure UpgradeFailureFunc
+	hooksLock        sync.RWMutex
+
+	handshakes     handshakeCounter
+	handshakeLimit HandshakeLimitFunc
+
+	// acceptPaused rejects new sessions, see PauseAccept.
+	acceptPaused bool
+	retryAfter   time.Duration
+	acceptLock   sync.RWMutex
+
 	connChan  chan Conn
+	closed    chan struct{}
 	closeOnce sync.Once
 }
 
@@ -41,24 +58,101 @@ func NewServer(opts *Options) *Server {
 		connInitor:     opts.getConnInitor(),
 		sessions:       session.NewManager(opts.getSessionIDGenerator()),
 		connChan:       make(chan Conn, 1),
+		closed:         make(chan struct{}),
 	}
 }
 
 // Close closes server.
 func (s *Server) Close() error {
 	s.closeOnce.Do(func() {
-		close(s.connChan)
+		close(s.closed)
 	})
 	return nil
 }
 
 // Accept accepts a connection.
 func (s *Server) Accept() (Conn, error) {
-	c := <-s.connChan
-	if c == nil {
+	select {
+	case c := <-s.connChan:
+		return c, nil
+	case <-s.closed:
 		return nil, io.EOF
 	}
-	return c, nil
+}
+
+// OnUpgradeFailure sets f to be called with reason when upgrade of a session to another
+// transport fails, e.g. ErrUpgradeTimeout.
+func (s *Server) OnUpgradeFailure(f UpgradeFailureFunc) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onUpgradeFailure = f
+}
+
+// HandshakeLimitFunc tells whether handshake request of new session is allowed.
+type HandshakeLimitFunc func(r *http.Request) bool
+
+// LimitHandshakes sets f which rejects handshakes of new sessions with 429 Too Many Requests
+// when it returns false, e.g. rate limit of client addresses. It must be called before server starts serving.
+func (s *Server) LimitHandshakes(f HandshakeLimitFunc) {
+	s.handshakeLimit = f
+}
+
+// PauseAccept rejects new sessions with 503 Service Unavailable until ResumeAccept, while
+// existing sessions are kept alive, e.g. for maintenance. Non-zero retryAfter is sent to
+// clients in Retry-After header, rounded up to seconds.
+func (s *Server) PauseAccept(retryAfter time.Duration) {
+	s.acceptLock.Lock()
+	defer s.acceptLock.Unlock()
+
+	s.acceptPaused = true
+	s.retryAfter = retryAfter
+}
+
+// ResumeAccept accepts new sessions again after PauseAccept.
+func (s *Server) ResumeAccept() {
+	s.acceptLock.Lock()
+	defer s.acceptLock.Unlock()
+
+	s.acceptPaused = false
+	s.retryAfter = 0
+}
+
+// AcceptPaused reports whether new sessions are rejected, see PauseAccept.
+func (s *Server) AcceptPaused() bool {
+	s.acceptLock.RLock()
+	defer s.acceptLock.RUnlock()
+
+	return s.acceptPaused
+}
+
+// rejectPaused answers handshake while accept is paused, it reports false when it's not paused.
+func (s *Server) rejectPaused(w http.ResponseWriter) bool {
+	s.acceptLock.RLock()
+	paused, retryAfter := s.acceptPaused, s.retryAfter
+	s.acceptLock.RUnlock()
+
+	if !paused {
+		return false
+	}
+
+	if retryAfter > 0 {
+		seconds := (retryAfter + time.Second - 1) / time.Second
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+	}
+	http.Error(w, "server is paused", http.StatusServiceUnavailable)
+
+	return true
+}
+
+func (s *Server) upgradeFailed(sid, transport string, err error) {
+	s.hooksLock.RLock()
+	onUpgradeFailure := s.onUpgradeFailure
+	s.hooksLock.RUnlock()
+
+	if onUpgradeFailure != nil {
+		onUpgradeFailure(sid, transport, err)
+	}
 }
 
 func (s *Server) Addr() net.Addr {
@@ -94,6 +188,17 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if s.rejectPaused(w) {
+			return
+		}
+
+		if s.handshakeLimit != nil && !s.handshakeLimit(r) {
+			http.Error(w, "too many handshakes", http.StatusTooManyRequests)
+			return
+		}
+
+		setHostHeader(r)
+
 		transportConn, err := srvTransport.Accept(w, r)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("transport accept err: %s", err.Error()), http.StatusBadGateway)
@@ -111,8 +216,17 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// try upgrade current connection
 	if reqSession.Transport() != reqTransport {
+		if !s.canUpgrade(reqSession.Transport(), reqTransport) {
+			http.Error(w, fmt.Sprintf("invalid transport upgrade: %s to %s", reqSession.Transport(), reqTransport), http.StatusBadRequest)
+			return
+		}
+
+		setHostHeader(r)
+
 		transportConn, err := srvTransport.Accept(w, r)
 		if err != nil {
+			s.upgradeFailed(reqSession.ID(), reqTransport, err)
+
 			// don't call http.Error() for HandshakeErrors because
 			// they get handled by the websocket library internally.
 			if _, ok := err.(websocket.HandshakeError); !ok {
@@ -121,7 +235,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		reqSession.Upgrade(reqTransport, transportConn)
+		sid := reqSession.ID()
+		reqSession.Upgrade(reqTransport, transportConn, func(err error) {
+			s.upgradeFailed(sid, reqTransport, err)
+		})
 
 		if handler, ok := transportConn.(http.Handler); ok {
 			handler.ServeHTTP(w, r)
@@ -132,6 +249,25 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	reqSession.ServeHTTP(w, r)
 }
 
+// setHostHeader keeps host of request in its header, which transports give as RemoteHeader,
+// like handshake headers of node.js engine.io, e.g. to route connections by hostname.
+func setHostHeader(r *http.Request) {
+	if r.Host != "" && r.Header.Get("Host") == "" {
+		r.Header.Set("Host", r.Host)
+	}
+}
+
+// canUpgrade reports whether session of transport from can be upgraded to transport to.
+func (s *Server) canUpgrade(from, to string) bool {
+	for _, name := range s.transports.UpgradeFrom(from) {
+		if name == to {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Count counts connected
 func (s *Server) Count() int {
 	return s.sessions.Count()
@@ -148,6 +284,9 @@ func (s *Server) newSession(_ context.Context, conn transport.Conn, reqTransport
 		PingTimeout:  s.pingTimeout,
 		Upgrades:     s.transports.UpgradeFrom(reqTransport),
 	}
+	if transport.ProtocolVersion(conn.URL()) == 4 {
+		params.MaxPayload = handshakeMaxPayload
+	}
 
 	sid := s.sessions.NewID()
 	newSession, err := session.New(conn, sid, reqTransport, params)
@@ -163,8 +302,15 @@ func (s *Server) newSession(_ context.Context, conn transport.Conn, reqTransport
 		}
 
 		s.sessions.Add(newSession)
+		s.handshakes.add(time.Now())
 
-		s.connChan <- newSession
+		select {
+		case s.connChan <- newSession:
+		case <-s.closed:
+			if closeErr := newSession.Close(); closeErr != nil {
+				log.Println("close new session:", closeErr)
+			}
+		}
 	}(newSession)
 
 	return newSession, nil
@@ -9,7 +9,9 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	gorilla "github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -189,6 +191,238 @@ func TestEngineWebsocket(t *testing.T) {
 	should.Equal(cntInfo, svrInfo)
 }
 
+func TestEnginePollingV4(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	svr := NewServer(&Options{
+		PingInterval: 50 * time.Millisecond,
+		PingTimeout:  time.Second,
+		Transports:   []transport.Transport{polling.Default},
+	})
+	defer func() {
+		must.NoError(svr.Close())
+	}()
+
+	httpSvr := httptest.NewServer(svr)
+	defer httpSvr.Close()
+
+	request := func(method, query, body string) string {
+		req, err := http.NewRequest(method, httpSvr.URL+"/?EIO=4&transport=polling"+query, strings.NewReader(body))
+		must.NoError(err)
+		req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+
+		resp, err := http.DefaultClient.Do(req)
+		must.NoError(err)
+		defer resp.Body.Close()
+
+		data, err := ioutil.ReadAll(resp.Body)
+		must.NoError(err)
+		must.Equal(http.StatusOK, resp.StatusCode, string(data))
+
+		return string(data)
+	}
+
+	open := request(http.MethodGet, "", "")
+	must.True(strings.HasPrefix(open, "0{"), open)
+	params, err := transport.ReadConnParameters(strings.NewReader(open[1:]))
+	must.NoError(err)
+	should.Equal(int64(handshakeMaxPayload), params.MaxPayload)
+	sid := "&sid=" + params.SID
+
+	conn, err := svr.Accept()
+	must.NoError(err)
+	defer func() {
+		must.NoError(conn.Close())
+	}()
+
+	type message struct {
+		ft   session.FrameType
+		data []byte
+	}
+	messages := make(chan message, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			ft, r, err := conn.NextReader()
+			if err != nil {
+				return
+			}
+			b, _ := ioutil.ReadAll(r)
+			_ = r.Close()
+			messages <- message{ft, b}
+		}
+	}()
+
+	// server pings client of protocol 4, which answers with pong
+	should.Equal("2", request(http.MethodGet, sid, ""))
+	should.Equal("ok", request(http.MethodPost, sid, "3\x1e4hello你好\x1ebAQID"))
+	should.Equal(message{session.TEXT, []byte("hello你好")}, <-messages)
+	should.Equal(message{session.BINARY, []byte{1, 2, 3}}, <-messages)
+
+	go func() {
+		w, err := conn.NextWriter(session.BINARY)
+		if err != nil {
+			return
+		}
+		_, _ = w.Write([]byte{4, 5})
+		_ = w.Close()
+	}()
+
+	// binary packets of protocol 4 are base64 without packet type, pings may come first
+	data := request(http.MethodGet, sid, "")
+	for data == "2" {
+		data = request(http.MethodGet, sid, "")
+	}
+	should.Equal("bBAU=", data)
+}
+
+func TestEnginePollingPauseReads(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	svr := NewServer(&Options{
+		PingInterval: 10 * time.Second,
+		PingTimeout:  10 * time.Second,
+		Transports:   []transport.Transport{polling.Default},
+	})
+	defer func() {
+		must.NoError(svr.Close())
+	}()
+
+	httpSvr := httptest.NewServer(svr)
+	defer httpSvr.Close()
+
+	type response struct {
+		status int
+		data   string
+	}
+	request := func(method, query, body string) response {
+		req, err := http.NewRequest(method, httpSvr.URL+"/?EIO=4&transport=polling"+query, strings.NewReader(body))
+		must.NoError(err)
+		req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+
+		resp, err := http.DefaultClient.Do(req)
+		must.NoError(err)
+		defer resp.Body.Close()
+
+		data, err := ioutil.ReadAll(resp.Body)
+		must.NoError(err)
+
+		return response{resp.StatusCode, string(data)}
+	}
+
+	open := request(http.MethodGet, "", "")
+	params, err := transport.ReadConnParameters(strings.NewReader(open.data[1:]))
+	must.NoError(err)
+	sid := "&sid=" + params.SID
+
+	conn, err := svr.Accept()
+	must.NoError(err)
+	defer func() {
+		must.NoError(conn.Close())
+	}()
+
+	messages := make(chan string, 1)
+	go func() {
+		_, r, err := conn.NextReader()
+		if err != nil {
+			return
+		}
+		b, _ := ioutil.ReadAll(r)
+		_ = r.Close()
+		messages <- string(b)
+	}()
+
+	pauser, ok := conn.(ReadPauser)
+	must.True(ok)
+	must.True(pauser.PauseReads())
+	time.Sleep(50 * time.Millisecond)
+
+	// request of client is held, instead of failed, until reads are resumed
+	posted := make(chan response, 1)
+	go func() {
+		posted <- request(http.MethodPost, sid, "4hello")
+	}()
+
+	select {
+	case res := <-posted:
+		t.Fatalf("request isn't held: %v", res)
+	case <-messages:
+		t.Fatal("reads aren't paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	pauser.ResumeReads()
+	should.Equal(response{http.StatusOK, "ok"}, <-posted)
+	should.Equal("hello", <-messages)
+}
+
+func TestEngineWebsocketV4(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	svr := NewServer(&Options{
+		PingInterval: 50 * time.Millisecond,
+		PingTimeout:  time.Second,
+	})
+	defer func() {
+		must.NoError(svr.Close())
+	}()
+
+	httpSvr := httptest.NewServer(svr)
+	defer httpSvr.Close()
+
+	u := strings.Replace(httpSvr.URL, "http", "ws", 1) + "/?EIO=4&transport=websocket"
+	ws, _, err := gorilla.DefaultDialer.Dial(u, nil)
+	must.NoError(err)
+	defer ws.Close()
+
+	typ, data, err := ws.ReadMessage()
+	must.NoError(err)
+	should.Equal(gorilla.TextMessage, typ)
+	should.Contains(string(data), `"maxPayload":1000000`)
+
+	conn, err := svr.Accept()
+	must.NoError(err)
+	defer func() {
+		must.NoError(conn.Close())
+	}()
+
+	// server pings client of protocol 4
+	typ, data, err = ws.ReadMessage()
+	must.NoError(err)
+	should.Equal(gorilla.TextMessage, typ)
+	should.Equal("2", string(data))
+	must.NoError(ws.WriteMessage(gorilla.TextMessage, []byte("3")))
+
+	// binary messages of protocol 4 have no packet type
+	must.NoError(ws.WriteMessage(gorilla.BinaryMessage, []byte{1, 2, 3}))
+
+	ft, r, err := conn.NextReader()
+	must.NoError(err)
+	should.Equal(session.BINARY, ft)
+	b, err := ioutil.ReadAll(r)
+	must.NoError(err)
+	must.NoError(r.Close())
+	should.Equal([]byte{1, 2, 3}, b)
+
+	w, err := conn.NextWriter(session.BINARY)
+	must.NoError(err)
+	_, err = w.Write([]byte{4, 5})
+	must.NoError(err)
+	must.NoError(w.Close())
+
+	for {
+		typ, data, err = ws.ReadMessage()
+		must.NoError(err)
+		if typ == gorilla.BinaryMessage {
+			break
+		}
+		should.Equal("2", string(data))
+	}
+	should.Equal([]byte{4, 5}, data)
+}
+
 func TestEngineUpgrade(t *testing.T) {
 	should := assert.New(t)
 	must := require.New(t)
@@ -323,5 +557,147 @@ func TestEngineUpgrade(t *testing.T) {
 
 	wg.Wait()
 
+	stats := svr.Stats()
+	should.Equal(1, stats.Sessions)
+	should.Equal(map[string]int{"websocket": 1}, stats.ByTransport)
+	should.Equal(1, stats.Upgraded)
+	should.Zero(stats.Upgrading)
+	should.Equal(uint64(1), stats.Handshakes)
+
 	must.NoError(ws.Close())
 }
+
+func TestEngineOnUpgradeFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe func(ws transport.Conn) error
+		want  error
+	}{
+		{"unexpected packet", func(ws transport.Conn) error {
+			w, err := ws.NextWriter(frame.String, packet.MESSAGE)
+			if err != nil {
+				return err
+			}
+			return w.Close()
+		}, ErrUpgradeUnexpectedPacket},
+		{"aborted", func(ws transport.Conn) error {
+			return ws.Close()
+		}, ErrUpgradeAborted},
+		{"timeout", func(transport.Conn) error {
+			return nil
+		}, ErrUpgradeTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			svr := NewServer(&Options{PingTimeout: 200 * time.Millisecond})
+			defer svr.Close()
+
+			type failure struct {
+				sid       string
+				transport string
+				err       error
+			}
+			failures := make(chan failure, 1)
+			svr.OnUpgradeFailure(func(sid, transport string, err error) {
+				failures <- failure{sid, transport, err}
+			})
+
+			httpSvr := httptest.NewServer(svr)
+			defer httpSvr.Close()
+
+			u, err := url.Parse(httpSvr.URL)
+			must.NoError(err)
+
+			p, err := polling.Default.Dial(u, nil)
+			must.NoError(err)
+			defer p.Close()
+
+			params, err := p.(Opener).Open()
+			must.NoError(err)
+
+			upU := *u
+			upU.Scheme = "ws"
+			query := upU.Query()
+			query.Set("sid", params.SID)
+			upU.RawQuery = query.Encode()
+
+			ws, err := websocket.Default.Dial(&upU, nil)
+			must.NoError(err)
+			defer ws.Close()
+
+			must.NoError(test.probe(ws))
+
+			select {
+			case f := <-failures:
+				should.Equal(params.SID, f.sid)
+				should.Equal("websocket", f.transport)
+				should.ErrorIs(f.err, test.want)
+			case <-time.After(5 * time.Second):
+				t.Fatal("upgrade failure wasn't reported")
+			}
+		})
+	}
+}
+
+func TestEnginePauseAccept(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	svr := NewServer(nil)
+	defer func() {
+		must.NoError(svr.Close())
+	}()
+
+	httpSvr := httptest.NewServer(svr)
+	defer httpSvr.Close()
+
+	dialer := Dialer{
+		Transports: []transport.Transport{polling.Default},
+	}
+
+	cnt, err := dialer.Dial(httpSvr.URL, nil)
+	must.NoError(err)
+	defer func() {
+		must.NoError(cnt.Close())
+	}()
+
+	conn, err := svr.Accept()
+	must.NoError(err)
+
+	svr.PauseAccept(1500 * time.Millisecond)
+	should.True(svr.AcceptPaused())
+
+	resp, err := http.Get(httpSvr.URL + "?EIO=3&transport=polling")
+	must.NoError(err)
+	must.NoError(resp.Body.Close())
+	should.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	should.Equal("2", resp.Header.Get("Retry-After"))
+
+	_, err = dialer.Dial(httpSvr.URL, nil)
+	should.Error(err)
+
+	// existing session is kept alive
+	w, err := cnt.NextWriter(session.TEXT)
+	must.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	must.NoError(err)
+	must.NoError(w.Close())
+
+	_, r, err := conn.NextReader()
+	must.NoError(err)
+	b, err := ioutil.ReadAll(r)
+	must.NoError(err)
+	must.NoError(r.Close())
+	should.Equal("hello", string(b))
+
+	svr.ResumeAccept()
+	should.False(svr.AcceptPaused())
+
+	resumed, err := dialer.Dial(httpSvr.URL, nil)
+	must.NoError(err)
+	must.NoError(resumed.Close())
+}
@@ -1,6 +1,8 @@
 package session
 
 import (
+	"errors"
+
 	"github.com/thisismz/go-socket.io/engineio/frame"
 )
 
@@ -13,3 +15,15 @@ const (
 	// BINARY is binary type message.
 	BINARY = FrameType(frame.Binary)
 )
+
+// Reasons of failed upgrade of session to another transport.
+var (
+	// ErrUpgradeTimeout is when client doesn't send probe or upgrade packet in time.
+	ErrUpgradeTimeout = errors.New("upgrade timeout")
+	// ErrUpgradeUnexpectedPacket is when client sends another packet instead of probe or upgrade.
+	ErrUpgradeUnexpectedPacket = errors.New("unexpected packet while upgrading")
+	// ErrUpgradeAborted is when client closes new transport before upgrade is done.
+	ErrUpgradeAborted = errors.New("upgrade aborted by client")
+	// ErrUpgradeUnsupported is when current transport of session can't be paused for upgrade.
+	ErrUpgradeUnsupported = errors.New("transport doesn't support upgrade")
+)
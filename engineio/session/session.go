@@ -1,6 +1,8 @@
 package session
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -26,18 +28,40 @@ type Session struct {
 	params    transport.ConnParameters
 	transport string
 
+	// initialTransport is transport session was opened with.
+	initialTransport string
+	// upgrades counts upgrades of session in flight.
+	upgrades int
+
 	context interface{}
 
+	// protocol is version of engine.io protocol of client, server pings clients of protocol 4,
+	// see heartbeat.
+	protocol  int
+	closed    chan struct{}
+	closeOnce sync.Once
+
 	upgradeLocker sync.RWMutex
+
+	pauseLock sync.Mutex
+	// resumed is closed by ResumeReads, it's nil while reads aren't paused.
+	resumed chan struct{}
+	// pauser is transport paused by PauseReads.
+	pauser Pauser
+	// lastPause is closed once the last pause or resume of transport is done.
+	lastPause chan struct{}
 }
 
-func New(conn transport.Conn, sid, transport string, params transport.ConnParameters) (*Session, error) {
+func New(conn transport.Conn, sid, name string, params transport.ConnParameters) (*Session, error) {
 	params.SID = sid
 
 	ses := &Session{
-		transport: transport,
-		conn:      conn,
-		params:    params,
+		transport:        name,
+		initialTransport: name,
+		conn:             conn,
+		params:           params,
+		protocol:         transport.ProtocolVersion(conn.URL()),
+		closed:           make(chan struct{}),
 	}
 
 	if err := ses.setDeadline(); err != nil {
@@ -70,7 +94,94 @@ func (s *Session) Transport() string {
 	return s.transport
 }
 
+// SetWriteCompression enables or disables compression of subsequent messages of current transport.
+func (s *Session) SetWriteCompression(enable bool) {
+	s.upgradeLocker.RLock()
+	defer s.upgradeLocker.RUnlock()
+
+	if compressor, ok := s.conn.(interface{ SetWriteCompression(bool) }); ok {
+		compressor.SetWriteCompression(enable)
+	}
+}
+
+// PauseReads pauses transport of session until ResumeReads, when it's Pauser, e.g. polling, whose
+// requests of client are held meanwhile. Transport is paused once payload being read is done, so
+// it may be called by reader of session. It's false when transport can't be paused, e.g.
+// websocket, reader must stop reading itself then.
+func (s *Session) PauseReads() bool {
+	s.upgradeLocker.RLock()
+	p, ok := s.conn.(Pauser)
+	s.upgradeLocker.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+
+	if s.resumed == nil {
+		s.resumed = make(chan struct{})
+		s.pauser = p
+		s.pauseTransport(p.Pause)
+	}
+
+	return true
+}
+
+// ResumeReads resumes transport paused by PauseReads.
+func (s *Session) ResumeReads() {
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+
+	if s.resumed == nil {
+		return
+	}
+
+	close(s.resumed)
+	s.resumed = nil
+	s.pauseTransport(s.pauser.Resume)
+	s.pauser = nil
+}
+
+// pauseTransport runs f after pauses and resumes of transport run before, without waiting for it,
+// since pause of transport waits for payload being read. It's called with pauseLock held.
+func (s *Session) pauseTransport(f func()) {
+	last, done := s.lastPause, make(chan struct{})
+	s.lastPause = done
+
+	go func() {
+		defer close(done)
+
+		if last != nil {
+			<-last
+		}
+		f()
+	}()
+}
+
+// waitResumed blocks while reads are paused by PauseReads, until they're resumed or session is
+// closed, so reader and writer don't retry paused transport meanwhile.
+func (s *Session) waitResumed() {
+	s.pauseLock.Lock()
+	resumed := s.resumed
+	s.pauseLock.Unlock()
+
+	if resumed == nil {
+		return
+	}
+
+	select {
+	case <-resumed:
+	case <-s.closed:
+	}
+}
+
 func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
 	s.upgradeLocker.RLock()
 	defer s.upgradeLocker.RUnlock()
 
@@ -130,6 +241,20 @@ func (s *Session) NextReader() (FrameType, io.ReadCloser, error) {
 				return 0, nil, err
 			}
 
+		case packet.PONG:
+			// client of protocol 4 answers ping of heartbeat, which keeps session alive
+			if err = r.Close(); err != nil {
+				logger.Error("close reader on packet pong:", err)
+			}
+
+			if err = s.setDeadline(); err != nil {
+				if closeErr := s.Close(); closeErr != nil {
+					logger.Error("close session after set deadline:", closeErr)
+				}
+
+				return 0, nil, err
+			}
+
 		case packet.CLOSE:
 			// unlocks the wrapped connection's FrameReader
 			if err = r.Close(); err != nil {
@@ -191,8 +316,40 @@ func (s *Session) NextWriter(typ FrameType) (io.WriteCloser, error) {
 	return s.nextWriter(frame.Type(typ), packet.MESSAGE)
 }
 
-func (s *Session) Upgrade(transport string, conn transport.Conn) {
-	go s.upgrading(transport, conn)
+// Upgrade probes conn of transport and switches session to it, onFailure is called with
+// reason of failed upgrade, it may be nil.
+func (s *Session) Upgrade(transport string, conn transport.Conn, onFailure func(err error)) {
+	s.upgradeLocker.Lock()
+	s.upgrades++
+	s.upgradeLocker.Unlock()
+
+	go func() {
+		err := s.upgrading(transport, conn)
+
+		s.upgradeLocker.Lock()
+		s.upgrades--
+		s.upgradeLocker.Unlock()
+
+		if err != nil && onFailure != nil {
+			onFailure(err)
+		}
+	}()
+}
+
+// Upgrading reports whether session is being upgraded to another transport.
+func (s *Session) Upgrading() bool {
+	s.upgradeLocker.RLock()
+	defer s.upgradeLocker.RUnlock()
+
+	return s.upgrades > 0
+}
+
+// Upgraded reports whether session was upgraded from transport it was opened with.
+func (s *Session) Upgraded() bool {
+	s.upgradeLocker.RLock()
+	defer s.upgradeLocker.RUnlock()
+
+	return s.transport != s.initialTransport
 }
 
 func (s *Session) InitSession() error {
@@ -225,9 +382,37 @@ func (s *Session) InitSession() error {
 		return err
 	}
 
+	if s.protocol == 4 {
+		go s.heartbeat()
+	}
+
 	return nil
 }
 
+// heartbeat pings client of protocol 4 every ping interval until session is closed, clients of
+// protocol 3 ping server instead.
+func (s *Session) heartbeat() {
+	ticker := time.NewTicker(s.params.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+		}
+
+		w, err := s.nextWriter(frame.String, packet.PING)
+		if err != nil {
+			return
+		}
+
+		if err = w.Close(); err != nil {
+			return
+		}
+	}
+}
+
 func (s *Session) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.upgradeLocker.RLock()
 	conn := s.conn
@@ -247,6 +432,10 @@ func (s *Session) nextReader() (frame.Type, packet.Type, io.ReadCloser, error) {
 		ft, pt, r, err := conn.NextReader()
 		if err != nil {
 			if op, ok := err.(payload.Error); ok && op.Temporary() {
+				s.waitResumed()
+				continue
+			}
+			if s.upgraded(conn) {
 				continue
 			}
 			return 0, 0, nil, err
@@ -264,6 +453,10 @@ func (s *Session) nextWriter(ft frame.Type, pt packet.Type) (io.WriteCloser, err
 		w, err := conn.NextWriter(ft, pt)
 		if err != nil {
 			if op, ok := err.(payload.Error); ok && op.Temporary() {
+				s.waitResumed()
+				continue
+			}
+			if s.upgraded(conn) {
 				continue
 			}
 			return nil, err
@@ -274,11 +467,24 @@ func (s *Session) nextWriter(ft frame.Type, pt packet.Type) (io.WriteCloser, err
 	}
 }
 
+// upgraded returns whether conn was replaced by upgrade, so errors of conn can be ignored.
+func (s *Session) upgraded(conn transport.Conn) bool {
+	s.upgradeLocker.RLock()
+	defer s.upgradeLocker.RUnlock()
+
+	return s.conn != conn
+}
+
 func (s *Session) setDeadline() error {
 	s.upgradeLocker.RLock()
 	defer s.upgradeLocker.RUnlock()
 
-	deadline := time.Now().Add(s.params.PingTimeout)
+	// client of protocol 4 answers ping which is sent after ping interval
+	window := s.params.PingTimeout
+	if s.protocol == 4 {
+		window += s.params.PingInterval
+	}
+	deadline := time.Now().Add(window)
 
 	err := s.conn.SetReadDeadline(deadline)
 	if err != nil {
@@ -288,7 +494,7 @@ func (s *Session) setDeadline() error {
 	return s.conn.SetWriteDeadline(deadline)
 }
 
-func (s *Session) upgrading(t string, conn transport.Conn) {
+func (s *Session) upgrading(t string, conn transport.Conn) error {
 	// Read a ping from the client.
 	err := conn.SetReadDeadline(time.Now().Add(s.params.PingTimeout))
 	if err != nil {
@@ -298,7 +504,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect after set read deadline:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	ft, pt, r, err := conn.NextReader()
@@ -309,7 +515,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect after get next reader:", closeErr)
 		}
 
-		return
+		return upgradeReadError(err)
 	}
 
 	if pt != packet.PING {
@@ -321,7 +527,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", err)
 		}
 
-		return
+		return fmt.Errorf("%w: %s instead of probe ping", ErrUpgradeUnexpectedPacket, pt)
 	}
 	// Wait to close the reader until after data is read and echoed in the reply.
 
@@ -338,7 +544,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	w, err := conn.NextWriter(ft, packet.PONG)
@@ -353,7 +559,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	// echo
@@ -372,7 +578,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	if err = r.Close(); err != nil {
@@ -386,7 +592,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	if err = w.Close(); err != nil {
@@ -396,7 +602,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	// Pause the old connection.
@@ -411,7 +617,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect after get pauser:", closeErr)
 		}
 
-		return
+		return ErrUpgradeUnsupported
 	}
 
 	p.Pause()
@@ -432,7 +638,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return upgradeReadError(err)
 	}
 
 	if pt != packet.UPGRADE {
@@ -444,7 +650,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return fmt.Errorf("%w: %s instead of upgrade", ErrUpgradeUnexpectedPacket, pt)
 	}
 
 	if err = r.Close(); err != nil {
@@ -454,7 +660,7 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 			logger.Error("close connect:", closeErr)
 		}
 
-		return
+		return err
 	}
 
 	// Successful upgrade.
@@ -468,4 +674,16 @@ func (s *Session) upgrading(t string, conn transport.Conn) {
 	if closeErr := old.Close(); closeErr != nil {
 		logger.Error("close old connection:", closeErr)
 	}
+
+	return nil
+}
+
+// upgradeReadError tells whether client didn't send upgrade packets in time or gave up upgrading.
+func upgradeReadError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", ErrUpgradeTimeout, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrUpgradeAborted, err)
 }
@@ -46,6 +46,16 @@ func (m *Manager) Remove(sid string) {
 	delete(m.sessions, sid)
 }
 
+// Range calls f for each session, f must not add or remove sessions.
+func (m *Manager) Range(f func(s *Session)) {
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+
+	for _, s := range m.sessions {
+		f(s)
+	}
+}
+
 func (m *Manager) Count() int {
 	m.locker.Lock()
 	defer m.locker.Unlock()
@@ -0,0 +1,93 @@
+package engineio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+)
+
+// handshakeWindow is period of handshake rate of Stats.
+const handshakeWindow = time.Minute
+
+// Stats are counts of sessions of server, see Server.Stats.
+type Stats struct {
+	// Sessions is count of open sessions, like Count.
+	Sessions int
+	// ByTransport counts open sessions by their current transport, e.g. "polling" and "websocket".
+	ByTransport map[string]int
+	// Upgrading counts sessions which are being upgraded to another transport.
+	Upgrading int
+	// Upgraded counts sessions which were upgraded from transport they were opened with.
+	Upgraded int
+
+	// Handshakes is total count of sessions opened by server.
+	Handshakes uint64
+	// HandshakeRate is average count of handshakes per second in the last minute.
+	HandshakeRate float64
+}
+
+// Stats gives counts of sessions by transport and upgrade state, and rate of handshakes,
+// e.g. for dashboards or to decide when clients should be switched to websocket only.
+func (s *Server) Stats() Stats {
+	stats := Stats{
+		ByTransport: make(map[string]int),
+	}
+
+	s.sessions.Range(func(ses *session.Session) {
+		stats.Sessions++
+		stats.ByTransport[ses.Transport()]++
+
+		if ses.Upgrading() {
+			stats.Upgrading++
+		}
+		if ses.Upgraded() {
+			stats.Upgraded++
+		}
+	})
+
+	stats.Handshakes, stats.HandshakeRate = s.handshakes.rate(time.Now())
+
+	return stats
+}
+
+// handshakeCounter counts handshakes in buckets of a second over handshakeWindow.
+type handshakeCounter struct {
+	mu      sync.Mutex
+	total   uint64
+	buckets [handshakeWindow / time.Second]uint64
+	// seconds are unix seconds of buckets, so stale buckets are told apart.
+	seconds [handshakeWindow / time.Second]int64
+}
+
+func (c *handshakeCounter) add(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sec := now.Unix()
+	i := sec % int64(len(c.buckets))
+	if c.seconds[i] != sec {
+		c.seconds[i] = sec
+		c.buckets[i] = 0
+	}
+
+	c.buckets[i]++
+	c.total++
+}
+
+// rate gives total of handshakes and their average count per second in the window before now.
+func (c *handshakeCounter) rate(now time.Time) (uint64, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sec := now.Unix()
+
+	var inWindow uint64
+	for i := range c.buckets {
+		if sec-c.seconds[i] < int64(len(c.buckets)) {
+			inWindow += c.buckets[i]
+		}
+	}
+
+	return c.total, float64(inWindow) / handshakeWindow.Seconds()
+}
@@ -0,0 +1,34 @@
+package engineio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandshakeCounter(t *testing.T) {
+	should := assert.New(t)
+
+	var c handshakeCounter
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 30; i++ {
+		c.add(now)
+	}
+	c.add(now.Add(30 * time.Second))
+	c.add(now.Add(59 * time.Second))
+
+	total, rate := c.rate(now.Add(59 * time.Second))
+	should.Equal(uint64(32), total)
+	should.Equal(32.0/60, rate)
+
+	total, rate = c.rate(now.Add(61 * time.Second))
+	should.Equal(uint64(32), total)
+	should.Equal(2.0/60, rate, "handshakes out of window aren't counted")
+
+	c.add(now.Add(120 * time.Second))
+	total, rate = c.rate(now.Add(120 * time.Second))
+	should.Equal(uint64(33), total)
+	should.Equal(1.0/60, rate, "stale bucket is reset")
+}
@@ -0,0 +1,165 @@
+package fault
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/thisismz/go-socket.io/engineio/frame"
+	"github.com/thisismz/go-socket.io/engineio/packet"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+)
+
+type pauser interface {
+	Pause()
+	Resume()
+}
+
+type opener interface {
+	Open() (transport.ConnParameters, error)
+}
+
+// conn injects faults into the wrapped transport.Conn.
+type conn struct {
+	transport.Conn
+
+	faults *Faults
+}
+
+// openerConn is conn of client transports which need receive open message first.
+type openerConn struct {
+	*conn
+}
+
+func wrap(c transport.Conn, faults *Faults) transport.Conn {
+	ret := &conn{
+		Conn:   c,
+		faults: faults,
+	}
+
+	if _, ok := c.(opener); ok {
+		return openerConn{conn: ret}
+	}
+
+	return ret
+}
+
+func (c openerConn) Open() (transport.ConnParameters, error) {
+	return c.Conn.(opener).Open()
+}
+
+func (c *conn) NextReader() (frame.Type, packet.Type, io.ReadCloser, error) {
+	for {
+		ft, pt, r, err := c.Conn.NextReader()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+
+		if c.faults.DropRead == nil || !c.faults.DropRead(ft, pt) {
+			return ft, pt, r, nil
+		}
+
+		if err = r.Close(); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+}
+
+func (c *conn) NextWriter(ft frame.Type, pt packet.Type) (io.WriteCloser, error) {
+	if c.faults.WriteDelay != nil {
+		if d := c.faults.WriteDelay(ft, pt); d > 0 {
+			time.Sleep(d)
+		}
+	}
+
+	if c.faults.DropWrite != nil && c.faults.DropWrite(ft, pt) {
+		return discardWriter{}, nil
+	}
+
+	w, err := c.Conn.NextWriter(ft, pt)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.faults.AbortAfter != nil {
+		if n := c.faults.AbortAfter(ft, pt); n >= 0 {
+			return &abortWriter{WriteCloser: w, conn: c.Conn, left: n}, nil
+		}
+	}
+
+	return w, nil
+}
+
+func (c *conn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := c.Conn.(http.Handler); ok {
+		h.ServeHTTP(w, r)
+	}
+}
+
+func (c *conn) Pause() {
+	if p, ok := c.Conn.(pauser); ok {
+		p.Pause()
+	}
+}
+
+func (c *conn) Resume() {
+	if p, ok := c.Conn.(pauser); ok {
+		p.Resume()
+	}
+}
+
+func (c *conn) SetWriteCompression(enable bool) {
+	if compressor, ok := c.Conn.(interface{ SetWriteCompression(bool) }); ok {
+		compressor.SetWriteCompression(enable)
+	}
+}
+
+func (c *conn) Drain() {
+	if d, ok := c.Conn.(interface{ Drain() }); ok {
+		d.Drain()
+	}
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (discardWriter) Close() error {
+	return nil
+}
+
+// abortWriter closes the connection after left bytes are written.
+type abortWriter struct {
+	io.WriteCloser
+
+	conn    io.Closer
+	left    int
+	aborted bool
+}
+
+func (w *abortWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.left {
+		n, err := w.WriteCloser.Write(p)
+		w.left -= n
+		return n, err
+	}
+
+	n, _ := w.WriteCloser.Write(p[:w.left])
+	w.left = 0
+	w.aborted = true
+
+	_ = w.WriteCloser.Close()
+	_ = w.conn.Close()
+
+	return n, ErrAborted
+}
+
+func (w *abortWriter) Close() error {
+	if w.aborted {
+		return ErrAborted
+	}
+
+	return w.WriteCloser.Close()
+}
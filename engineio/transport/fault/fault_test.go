@@ -0,0 +1,145 @@
+package fault
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/frame"
+	"github.com/thisismz/go-socket.io/engineio/packet"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+)
+
+type fakeFrame struct {
+	bytes.Buffer
+	conn *fakeConn
+}
+
+func (f *fakeFrame) Close() error {
+	f.conn.frames = append(f.conn.frames, f.String())
+	return nil
+}
+
+type fakeConn struct {
+	transport.Conn
+
+	frames []string
+	closed bool
+}
+
+func (c *fakeConn) NextWriter(frame.Type, packet.Type) (io.WriteCloser, error) {
+	return &fakeFrame{conn: c}, nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+type fakeTransport struct {
+	conn *fakeConn
+}
+
+func (t fakeTransport) Name() string {
+	return "fake"
+}
+
+func (t fakeTransport) Accept(http.ResponseWriter, *http.Request) (transport.Conn, error) {
+	return t.conn, nil
+}
+
+func (t fakeTransport) Dial(*url.URL, http.Header) (transport.Conn, error) {
+	return t.conn, nil
+}
+
+func write(c transport.Conn, pt packet.Type, data string) error {
+	w, err := c.NextWriter(frame.String, pt)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write([]byte(data)); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func TestDropWrite(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	inner := &fakeConn{}
+	tran := New(fakeTransport{conn: inner}, &Faults{
+		DropWrite: func(_ frame.Type, pt packet.Type) bool {
+			return pt == packet.PING
+		},
+	})
+	should.Equal("fake", tran.Name())
+
+	c, err := tran.Dial(&url.URL{}, nil)
+	must.NoError(err)
+
+	must.NoError(write(c, packet.PING, "probe"))
+	must.NoError(write(c, packet.MESSAGE, "hello"))
+
+	should.Equal([]string{"hello"}, inner.frames)
+}
+
+func TestWriteDelay(t *testing.T) {
+	must := require.New(t)
+
+	tran := New(fakeTransport{conn: &fakeConn{}}, &Faults{
+		WriteDelay: func(frame.Type, packet.Type) time.Duration {
+			return 50 * time.Millisecond
+		},
+	})
+
+	c, err := tran.Dial(&url.URL{}, nil)
+	must.NoError(err)
+
+	start := time.Now()
+	must.NoError(write(c, packet.MESSAGE, "hello"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestAbortAfter(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	inner := &fakeConn{}
+	tran := New(fakeTransport{conn: inner}, &Faults{
+		AbortAfter: func(frame.Type, packet.Type) int {
+			return 3
+		},
+	})
+
+	c, err := tran.Dial(&url.URL{}, nil)
+	must.NoError(err)
+
+	err = write(c, packet.MESSAGE, "hello")
+	should.Equal(ErrAborted, err)
+	should.True(inner.closed)
+	should.Equal([]string{"hel"}, inner.frames)
+}
+
+func TestFailUpgrade(t *testing.T) {
+	should := assert.New(t)
+
+	tran := New(fakeTransport{conn: &fakeConn{}}, &Faults{FailUpgrade: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/?transport=fake", nil)
+	_, err := tran.Accept(httptest.NewRecorder(), req)
+	should.NoError(err)
+
+	req = httptest.NewRequest(http.MethodGet, "/?transport=fake&sid=1", nil)
+	_, err = tran.Accept(httptest.NewRecorder(), req)
+	should.Equal(ErrUpgradeFailure, err)
+}
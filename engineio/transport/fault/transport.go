@@ -0,0 +1,97 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/thisismz/go-socket.io/engineio/frame"
+	"github.com/thisismz/go-socket.io/engineio/packet"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+)
+
+// ErrUpgradeFailure is returned by Accept when upgrade failure is forced.
+var ErrUpgradeFailure = errors.New("fault: forced upgrade failure")
+
+// ErrAborted is returned by writers aborted in the middle of payload.
+var ErrAborted = errors.New("fault: aborted mid-payload")
+
+// Faults describes faults injected into connections. All fields are optional,
+// functions are called for every frame so they may inject faults deterministically.
+type Faults struct {
+	// DropWrite drops outgoing frame when returns true.
+	DropWrite func(ft frame.Type, pt packet.Type) bool
+	// DropRead drops incoming frame when returns true.
+	DropRead func(ft frame.Type, pt packet.Type) bool
+	// WriteDelay delays every outgoing frame with returned duration.
+	WriteDelay func(ft frame.Type, pt packet.Type) time.Duration
+	// AbortAfter aborts the connection after given bytes of outgoing frame are written,
+	// when returns a non negative value.
+	AbortAfter func(ft frame.Type, pt packet.Type) int
+	// FailUpgrade makes Accept fail for requests upgrading an existing session.
+	FailUpgrade bool
+}
+
+// Transport injects faults into connections of the wrapped transport.
+type Transport struct {
+	transport.Transport
+
+	Faults *Faults
+}
+
+// New wraps t with faults.
+func New(t transport.Transport, faults *Faults) *Transport {
+	return &Transport{
+		Transport: t,
+		Faults:    faults,
+	}
+}
+
+// Accept accepts a http request and creates Conn with faults.
+func (t *Transport) Accept(w http.ResponseWriter, r *http.Request) (transport.Conn, error) {
+	if t.faults().FailUpgrade && r.URL.Query().Get("sid") != "" {
+		return nil, ErrUpgradeFailure
+	}
+
+	conn, err := t.Transport.Accept(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrap(conn, t.faults()), nil
+}
+
+// Dial dials connection to url and creates Conn with faults.
+func (t *Transport) Dial(u *url.URL, requestHeader http.Header) (transport.Conn, error) {
+	conn, err := t.Transport.Dial(u, requestHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrap(conn, t.faults()), nil
+}
+
+// DialContext dials with wrapped transport, ctx is ignored when it doesn't support context.
+func (t *Transport) DialContext(ctx context.Context, u *url.URL, requestHeader http.Header) (transport.Conn, error) {
+	d, ok := t.Transport.(transport.ContextDialer)
+	if !ok {
+		return t.Dial(u, requestHeader)
+	}
+
+	conn, err := d.DialContext(ctx, u, requestHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrap(conn, t.faults()), nil
+}
+
+func (t *Transport) faults() *Faults {
+	if t.Faults != nil {
+		return t.Faults
+	}
+
+	return &Faults{}
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
@@ -12,6 +13,12 @@ type Transport interface {
 	Dial(u *url.URL, requestHeader http.Header) (Conn, error)
 }
 
+// ContextDialer is a transport which dials with context, ctx only bounds dialing
+// and opening of connection, not the connection itself.
+type ContextDialer interface {
+	DialContext(ctx context.Context, u *url.URL, requestHeader http.Header) (Conn, error)
+}
+
 // Manager is a manager of transports.
 type Manager struct {
 	order      []string
@@ -3,15 +3,28 @@ package transport
 import (
 	"encoding/json"
 	"io"
+	"net/url"
 	"time"
 )
 
+// ProtocolVersion gives version of engine.io protocol of client of connection at u, it's given
+// in EIO query, clients which don't give it speak protocol 3.
+func ProtocolVersion(u url.URL) int {
+	if u.Query().Get("EIO") == "4" {
+		return 4
+	}
+
+	return 3
+}
+
 // ConnParameters is connection parameter of server.
 type ConnParameters struct {
 	PingInterval time.Duration
 	PingTimeout  time.Duration
 	SID          string
 	Upgrades     []string
+	// MaxPayload is sent to clients of protocol 4 only, which split polling payloads by it.
+	MaxPayload int64
 }
 
 type jsonParameters struct {
@@ -19,6 +32,7 @@ type jsonParameters struct {
 	Upgrades     []string `json:"upgrades"`
 	PingInterval int      `json:"pingInterval"`
 	PingTimeout  int      `json:"pingTimeout"`
+	MaxPayload   int64    `json:"maxPayload,omitempty"`
 }
 
 // ReadConnParameters reads ConnParameters from r.
@@ -33,6 +47,7 @@ func ReadConnParameters(r io.Reader) (ConnParameters, error) {
 		Upgrades:     param.Upgrades,
 		PingInterval: time.Duration(param.PingInterval) * time.Millisecond,
 		PingTimeout:  time.Duration(param.PingTimeout) * time.Millisecond,
+		MaxPayload:   param.MaxPayload,
 	}, nil
 }
 
@@ -43,6 +58,7 @@ func (p ConnParameters) WriteTo(w io.Writer) (int64, error) {
 		Upgrades:     p.Upgrades,
 		PingInterval: int(p.PingInterval / time.Millisecond),
 		PingTimeout:  int(p.PingTimeout / time.Millisecond),
+		MaxPayload:   p.MaxPayload,
 	}
 	writer := writer{
 		w: w,
@@ -3,6 +3,7 @@ package transport
 import (
 	"bytes"
 	"io/ioutil"
+	"net/url"
 	"testing"
 	"time"
 
@@ -24,9 +25,20 @@ func TestConnParameters(t *testing.T) {
 				time.Second * 5,
 				"vCcJKmYQcIf801WDAAAB",
 				[]string{"websocket", "polling"},
+				0,
 			},
 			"{\"sid\":\"vCcJKmYQcIf801WDAAAB\",\"upgrades\":[\"websocket\",\"polling\"],\"pingInterval\":10000,\"pingTimeout\":5000}\n",
 		},
+		{
+			ConnParameters{
+				time.Second * 25,
+				time.Second * 20,
+				"vCcJKmYQcIf801WDAAAB",
+				[]string{"websocket"},
+				1000000,
+			},
+			"{\"sid\":\"vCcJKmYQcIf801WDAAAB\",\"upgrades\":[\"websocket\"],\"pingInterval\":25000,\"pingTimeout\":20000,\"maxPayload\":1000000}\n",
+		},
 	}
 	for _, test := range tests {
 		buf := bytes.NewBuffer(nil)
@@ -50,6 +62,7 @@ func BenchmarkConnParameters(b *testing.B) {
 		time.Second * 5,
 		"vCcJKmYQcIf801WDAAAB",
 		[]string{"websocket", "polling"},
+		0,
 	}
 
 	b.ResetTimer()
@@ -59,3 +72,15 @@ func BenchmarkConnParameters(b *testing.B) {
 		must.Nil(err)
 	}
 }
+
+func TestProtocolVersion(t *testing.T) {
+	at := assert.New(t)
+
+	for query, version := range map[string]int{
+		"":                        3,
+		"EIO=3&transport=polling": 3,
+		"EIO=4&transport=polling": 4,
+	} {
+		at.Equal(version, ProtocolVersion(url.URL{RawQuery: query}), query)
+	}
+}
@@ -2,6 +2,7 @@ package polling
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"sync/atomic"
 
 	"github.com/thisismz/go-socket.io/engineio/packet"
@@ -24,6 +26,28 @@ type clientConn struct {
 	httpClient   *http.Client
 	request      http.Request
 	remoteHeader atomic.Value
+	openContext  context.Context
+
+	// pausing stops polling, pollLock is held while a poll is in flight.
+	pausing  int32
+	pollLock sync.Mutex
+
+	// posting is set from a payload is flushed out until it's posted.
+	posting     bool
+	postingCond *sync.Cond
+}
+
+// postBuffer marks conn posting once a payload is flushed out into it.
+type postBuffer struct {
+	bytes.Buffer
+
+	conn *clientConn
+}
+
+func (b *postBuffer) Write(p []byte) (int, error) {
+	b.conn.setPosting(true)
+
+	return b.Buffer.Write(p)
 }
 
 func (c *clientConn) Open() (transport.ConnParameters, error) {
@@ -85,7 +109,35 @@ func (c *clientConn) RemoteHeader() http.Header {
 	return ret.(http.Header)
 }
 
+// Drain waits until payloads written so far are posted.
+func (c *clientConn) Drain() {
+	c.postingCond.L.Lock()
+	defer c.postingCond.L.Unlock()
+
+	for c.posting {
+		c.postingCond.Wait()
+	}
+}
+
+func (c *clientConn) setPosting(posting bool) {
+	c.postingCond.L.Lock()
+	defer c.postingCond.L.Unlock()
+
+	c.posting = posting
+	c.postingCond.Broadcast()
+}
+
+// Pause waits the in-flight poll to be fed, then pauses the payload.
+func (c *clientConn) Pause() {
+	atomic.StoreInt32(&c.pausing, 1)
+
+	c.pollLock.Lock()
+	c.Payload.Pause()
+	c.pollLock.Unlock()
+}
+
 func (c *clientConn) Resume() {
+	atomic.StoreInt32(&c.pausing, 0)
 	c.Payload.Resume()
 
 	go c.serveGet()
@@ -99,18 +151,29 @@ func (c *clientConn) servePost() {
 	req.URL = &reqUrl
 	req.Method = http.MethodPost
 
-	var buf bytes.Buffer
+	buf := postBuffer{conn: c}
 	req.Body = io.NopCloser(&buf)
 
+	defer c.setPosting(false)
+
 	query := reqUrl.Query()
-	for {
+	for atomic.LoadInt32(&c.pausing) == 0 {
+		c.setPosting(false)
 		buf.Reset()
 
 		if err := c.Payload.FlushOut(&buf); err != nil {
 			return
 		}
+
+		// server doesn't accept payloads while pausing, drop the flushed out NOOP
+		if atomic.LoadInt32(&c.pausing) == 1 {
+			return
+		}
+
 		query.Set("t", utils.Timestamp())
 		req.URL.RawQuery = query.Encode()
+		// cookie jar of http client adds cookies into request header
+		req.Header = c.request.Header.Clone()
 
 		resp, err := c.httpClient.Do(&req)
 		if err != nil {
@@ -154,6 +217,11 @@ func (c *clientConn) getOpen() {
 
 	query.Set("t", utils.Timestamp())
 	req.URL.RawQuery = query.Encode()
+	req.Header = c.request.Header.Clone()
+
+	if c.openContext != nil {
+		req = *req.WithContext(c.openContext)
+	}
 
 	resp, err := c.httpClient.Do(&req)
 	if err != nil {
@@ -214,58 +282,72 @@ func (c *clientConn) serveGet() {
 	req.Method = http.MethodGet
 
 	query := req.URL.Query()
-	for {
-		query.Set("t", utils.Timestamp())
-		req.URL.RawQuery = query.Encode()
+	for c.poll(&req, query) {
+	}
+}
 
-		resp, err := c.httpClient.Do(&req)
-		if err != nil {
-			if err = c.Payload.Store("get", err); err != nil {
-				logger.Error("serveGet store 1:", err)
-			}
+// poll gets a payload from server, returns false when polling should stop.
+func (c *clientConn) poll(req *http.Request, query url.Values) bool {
+	c.pollLock.Lock()
+	defer c.pollLock.Unlock()
 
-			if err = c.Close(); err != nil {
-				logger.Error("close client connect:", err)
-			}
+	if atomic.LoadInt32(&c.pausing) == 1 {
+		return false
+	}
 
-			return
-		}
+	query.Set("t", utils.Timestamp())
+	req.URL.RawQuery = query.Encode()
+	req.Header = c.request.Header.Clone()
 
-		if resp.StatusCode != http.StatusOK {
-			err = fmt.Errorf("invalid request: %s(%d)", resp.Status, resp.StatusCode)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if err = c.Payload.Store("get", err); err != nil {
+			logger.Error("serveGet store 1:", err)
 		}
 
-		var isSupportBinary bool
-		if err == nil {
-			mime := resp.Header.Get("Content-Type")
-			isSupportBinary, err = mimeIsSupportBinary(mime)
-			if err != nil {
-				logger.Error("check mime support binary:", err)
-			}
+		if err = c.Close(); err != nil {
+			logger.Error("close client connect:", err)
 		}
 
+		return false
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("invalid request: %s(%d)", resp.Status, resp.StatusCode)
+	}
+
+	var isSupportBinary bool
+	if err == nil {
+		mime := resp.Header.Get("Content-Type")
+		isSupportBinary, err = mimeIsSupportBinary(mime)
 		if err != nil {
-			discardBody(resp.Body)
+			logger.Error("check mime support binary:", err)
+		}
+	}
 
-			if err = c.Payload.Store("get", err); err != nil {
-				logger.Error("serveGet store 2:", err)
-			}
+	if err != nil {
+		discardBody(resp.Body)
 
-			if err = c.Close(); err != nil {
-				logger.Error("close client connect:", err)
-			}
+		if err = c.Payload.Store("get", err); err != nil {
+			logger.Error("serveGet store 2:", err)
+		}
 
-			return
+		if err = c.Close(); err != nil {
+			logger.Error("close client connect:", err)
 		}
 
-		if err = c.Payload.FeedIn(resp.Body, isSupportBinary); err != nil {
-			discardBody(resp.Body)
+		return false
+	}
 
-			return
-		}
+	if err = c.Payload.FeedIn(resp.Body, isSupportBinary); err != nil {
+		discardBody(resp.Body)
 
-		c.remoteHeader.Store(resp.Header)
+		return false
 	}
+
+	c.remoteHeader.Store(resp.Header)
+
+	return true
 }
 
 func discardBody(body io.ReadCloser) {
@@ -2,14 +2,17 @@ package polling
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/thisismz/go-socket.io/engineio/payload"
+	"github.com/thisismz/go-socket.io/engineio/transport"
 	"github.com/thisismz/go-socket.io/logger"
 )
 
@@ -23,8 +26,12 @@ type serverConn struct {
 	remoteAddr   Addr
 	url          url.URL
 	jsonp        string
+
+	getLock sync.Mutex
 }
 
+var errOverlap = errors.New("overlap from client")
+
 func newServerConn(t *Transport, r *http.Request) *serverConn {
 	query := r.URL.Query()
 	jsonp := query.Get("j")
@@ -33,8 +40,15 @@ func newServerConn(t *Transport, r *http.Request) *serverConn {
 		supportBinary = false
 	}
 
+	pl := payload.New(supportBinary)
+	if transport.ProtocolVersion(*r.URL) == 4 {
+		// payloads of protocol 4 are text, with base64 binary packets
+		pl = payload.NewV4()
+		supportBinary = false
+	}
+
 	return &serverConn{
-		Payload:       payload.New(supportBinary),
+		Payload:       pl,
 		transport:     t,
 		supportBinary: supportBinary,
 		remoteHeader:  r.Header,
@@ -97,9 +111,16 @@ func (c *serverConn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		c.SetHeaders(w, r)
 
+		release, err := c.hold()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer release()
+
 		if jsonp := r.URL.Query().Get("j"); jsonp != "" {
 			buf := bytes.NewBuffer(nil)
-			if err := c.Payload.FlushOut(buf); err != nil {
+			if err := c.Payload.FlushOutWithin(buf, c.transport.HoldTime); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -118,7 +139,7 @@ func (c *serverConn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
 		}
 
-		if err := c.Payload.FlushOut(w); err != nil {
+		if err := c.Payload.FlushOutWithin(w, c.transport.HoldTime); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 
@@ -133,7 +154,14 @@ func (c *serverConn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := c.Payload.FeedIn(r.Body, isSupportBinary); err != nil {
+		err = c.Payload.FeedIn(r.Body, isSupportBinary)
+		for paused(err) && r.Context().Err() == nil {
+			// request is held while payload is paused, e.g. reads of session are paused, so
+			// client is slowed down instead of failed
+			c.Payload.WaitResumed(r.Context().Done())
+			err = c.Payload.FeedIn(r.Body, isSupportBinary)
+		}
+		if err != nil {
 			logger.Error("Polling Transport MethodPost FeedIn", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -149,3 +177,24 @@ func (c *serverConn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid method", http.StatusBadRequest)
 	}
 }
+
+// paused reports whether err is given by payload which is paused.
+func paused(err error) bool {
+	op, ok := err.(payload.Error)
+	return ok && op.Temporary()
+}
+
+// hold makes GET request the outstanding one of connection following overlap
+// policy of transport, release must be called once it's answered.
+func (c *serverConn) hold() (release func(), err error) {
+	if !c.getLock.TryLock() {
+		if c.transport.Overlap != ReplaceOverlap {
+			return nil, errOverlap
+		}
+
+		c.Payload.Release()
+		c.getLock.Lock()
+	}
+
+	return c.getLock.Unlock, nil
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -107,3 +108,69 @@ func TestServerJSONP(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestServerHoldTime(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	for _, test := range []struct {
+		overlap OverlapPolicy
+		status  int
+	}{
+		{RejectOverlap, http.StatusBadRequest},
+		{ReplaceOverlap, http.StatusOK},
+	} {
+		pollingTransport := &Transport{HoldTime: time.Second / 2, Overlap: test.overlap}
+
+		var sc atomic.Value
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			c := sc.Load()
+			if c == nil {
+				co, err := pollingTransport.Accept(w, r)
+				must.NoError(err)
+				sc.Store(co)
+				c = co
+			}
+			c.(http.Handler).ServeHTTP(w, r)
+		}
+
+		httpSvr := httptest.NewServer(http.HandlerFunc(handler))
+
+		get := func() (int, string, time.Duration) {
+			start := time.Now()
+			resp, err := http.Get(httpSvr.URL + "?b64=1")
+			must.NoError(err)
+			defer resp.Body.Close()
+
+			bs, err := ioutil.ReadAll(resp.Body)
+			must.NoError(err)
+			return resp.StatusCode, string(bs), time.Since(start)
+		}
+
+		// held request is answered with empty payload after hold time
+		status, body, took := get()
+		should.Equal(http.StatusOK, status)
+		should.Equal("1:6", body)
+		should.GreaterOrEqual(took, time.Second/2)
+
+		held := make(chan string, 1)
+		go func() {
+			_, body, _ := get()
+			held <- body
+		}()
+		time.Sleep(time.Second / 10)
+
+		status, _, _ = get()
+		should.Equal(test.status, status, "overlap %d", test.overlap)
+
+		select {
+		case body := <-held:
+			should.Equal("1:6", body)
+		case <-time.After(5 * time.Second):
+			t.Fatal("held request isn't answered")
+		}
+
+		must.NoError(sc.Load().(transport.Conn).Close())
+		httpSvr.Close()
+	}
+}
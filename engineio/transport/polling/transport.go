@@ -1,18 +1,40 @@
 package polling
 
 import (
+	"context"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/thisismz/go-socket.io/engineio/payload"
 	"github.com/thisismz/go-socket.io/engineio/transport"
 )
 
+// OverlapPolicy decides what happens to GET request which comes while
+// former one of connection is held.
+type OverlapPolicy int
+
+const (
+	// RejectOverlap fails the new request with 400, the held one goes on.
+	RejectOverlap OverlapPolicy = iota
+	// ReplaceOverlap ends the held request with empty payload and holds the
+	// new one, e.g. when proxy dropped the former one silently.
+	ReplaceOverlap
+)
+
 // Transport is the transport of polling.
 type Transport struct {
 	Client      *http.Client
 	CheckOrigin func(r *http.Request) bool
+
+	// HoldTime is how long GET request is held waiting for data before it's
+	// answered with empty payload. Keep it below timeouts of proxies, lower it
+	// trades latency for more requests. Zero holds till the write deadline.
+	HoldTime time.Duration
+	// Overlap is policy of GET request which comes while another one is held,
+	// at most one is outstanding.
+	Overlap OverlapPolicy
 }
 
 // Default is the default transport.
@@ -36,6 +58,11 @@ func (t *Transport) Accept(w http.ResponseWriter, r *http.Request) (transport.Co
 
 // Dial dials connection to url.
 func (t *Transport) Dial(u *url.URL, requestHeader http.Header) (transport.Conn, error) {
+	return t.DialContext(context.Background(), u, requestHeader)
+}
+
+// DialContext dials connection to url, ctx bounds the open request of connection.
+func (t *Transport) DialContext(ctx context.Context, u *url.URL, requestHeader http.Header) (transport.Conn, error) {
 	query := u.Query()
 	query.Set("transport", t.Name())
 	u.RawQuery = query.Encode()
@@ -45,7 +72,14 @@ func (t *Transport) Dial(u *url.URL, requestHeader http.Header) (transport.Conn,
 		client = Default.Client
 	}
 
-	return dial(client, u, requestHeader)
+	conn, err := dial(client, u, requestHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.openContext = ctx
+
+	return conn, nil
 }
 
 func dial(client *http.Client, url *url.URL, requestHeader http.Header) (*clientConn, error) {
@@ -67,8 +101,9 @@ func dial(client *http.Client, url *url.URL, requestHeader http.Header) (*client
 	}
 
 	return &clientConn{
-		Payload:    payload.New(supportBinary),
-		httpClient: client,
-		request:    *req,
+		Payload:     payload.New(supportBinary),
+		httpClient:  client,
+		request:     *req,
+		postingCond: sync.NewCond(&sync.Mutex{}),
 	}, nil
 }
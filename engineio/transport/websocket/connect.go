@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -23,15 +24,19 @@ type conn struct {
 	url          url.URL
 	remoteHeader http.Header
 
+	// readWindow is duration of the last read deadline in nanoseconds, pong frames extend
+	// deadline by it.
+	readWindow int64
+
 	closed    chan struct{}
 	closeOnce sync.Once
 }
 
-func newConn(ws *websocket.Conn, url url.URL, header http.Header) *conn {
+func newConn(ws *websocket.Conn, url url.URL, header http.Header, pingInterval time.Duration) *conn {
 	w := newWrapper(ws)
 	closed := make(chan struct{})
 
-	return &conn{
+	c := &conn{
 		url:          url,
 		remoteHeader: header,
 		ws:           w,
@@ -39,6 +44,45 @@ func newConn(ws *websocket.Conn, url url.URL, header http.Header) *conn {
 		FrameReader:  packet.NewDecoder(w),
 		FrameWriter:  packet.NewEncoder(w),
 	}
+	if transport.ProtocolVersion(url) == 4 {
+		c.FrameReader = packet.NewDecoderV4(w)
+		c.FrameWriter = packet.NewEncoderV4(w)
+	}
+
+	if pingInterval > 0 {
+		ws.SetPongHandler(c.onPong)
+		go c.ping(pingInterval)
+	}
+
+	return c
+}
+
+// ping sends ping frames until connection is closed.
+func (c *conn) ping(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			// control frames may be written concurrently with messages
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// onPong extends read deadline, it's called by reader of connection.
+func (c *conn) onPong(string) error {
+	window := time.Duration(atomic.LoadInt64(&c.readWindow))
+	if window <= 0 {
+		return nil
+	}
+
+	return c.ws.SetReadDeadline(time.Now().Add(window))
 }
 
 func (c *conn) URL() url.URL {
@@ -58,6 +102,12 @@ func (c *conn) RemoteAddr() net.Addr {
 }
 
 func (c *conn) SetReadDeadline(t time.Time) error {
+	var window time.Duration
+	if !t.IsZero() {
+		window = time.Until(t)
+	}
+	atomic.StoreInt64(&c.readWindow, int64(window))
+
 	return c.ws.SetReadDeadline(t)
 }
 
@@ -71,6 +121,14 @@ func (c *conn) SetWriteDeadline(t time.Time) error {
 	return err
 }
 
+// SetWriteCompression enables or disables compression of subsequent messages, it has effect only
+// when compression is negotiated, see Transport.EnableCompression.
+func (c *conn) SetWriteCompression(enable bool) {
+	c.ws.writeLocker.Lock()
+	c.ws.EnableWriteCompression(enable)
+	c.ws.writeLocker.Unlock()
+}
+
 func (c *conn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	<-c.closed
 }
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
@@ -33,6 +34,19 @@ type Transport struct {
 	Proxy       func(*http.Request) (*url.URL, error)
 	NetDial     func(network, addr string) (net.Conn, error)
 	CheckOrigin func(r *http.Request) bool
+
+	// Jar stores cookies of dial response and adds them to dial request.
+	Jar http.CookieJar
+
+	// EnableCompression negotiates per-message compression (permessage-deflate) with peer.
+	// Messages are compressed by default once it's negotiated.
+	EnableCompression bool
+
+	// PingInterval sends websocket ping control frames at this interval when it's set, e.g. to
+	// keep NAT mappings of intermediaries alive. They're answered by peer without engine.io
+	// payload, and its pong frames extend read deadline as engine.io pings do, so they keep
+	// connection alive also when engine.io pings are rare.
+	PingInterval time.Duration
 }
 
 // Default is default transport.
@@ -45,14 +59,21 @@ func (t *Transport) Name() string {
 
 // Dial creates a new client connection.
 func (t *Transport) Dial(u *url.URL, requestHeader http.Header) (transport.Conn, error) {
+	return t.DialContext(context.Background(), u, requestHeader)
+}
+
+// DialContext creates a new client connection, ctx bounds the websocket handshake.
+func (t *Transport) DialContext(ctx context.Context, u *url.URL, requestHeader http.Header) (transport.Conn, error) {
 	dialer := websocket.Dialer{
-		ReadBufferSize:   t.ReadBufferSize,
-		WriteBufferSize:  t.WriteBufferSize,
-		NetDial:          t.NetDial,
-		Proxy:            t.Proxy,
-		TLSClientConfig:  t.TLSClientConfig,
-		HandshakeTimeout: t.HandshakeTimeout,
-		Subprotocols:     t.Subprotocols,
+		ReadBufferSize:    t.ReadBufferSize,
+		WriteBufferSize:   t.WriteBufferSize,
+		NetDial:           t.NetDial,
+		Proxy:             t.Proxy,
+		TLSClientConfig:   t.TLSClientConfig,
+		HandshakeTimeout:  t.HandshakeTimeout,
+		Subprotocols:      t.Subprotocols,
+		Jar:               t.Jar,
+		EnableCompression: t.EnableCompression,
 	}
 
 	switch u.Scheme {
@@ -67,7 +88,7 @@ func (t *Transport) Dial(u *url.URL, requestHeader http.Header) (transport.Conn,
 	query.Set("t", utils.Timestamp())
 
 	u.RawQuery = query.Encode()
-	c, resp, err := dialer.Dial(u.String(), requestHeader)
+	c, resp, err := dialer.DialContext(ctx, u.String(), requestHeader)
 	if err != nil {
 		return nil, DialError{
 			error:    err,
@@ -75,20 +96,21 @@ func (t *Transport) Dial(u *url.URL, requestHeader http.Header) (transport.Conn,
 		}
 	}
 
-	return newConn(c, *u, resp.Header), nil
+	return newConn(c, *u, resp.Header, t.PingInterval), nil
 }
 
 // Accept accepts a http request and create Conn.
 func (t *Transport) Accept(w http.ResponseWriter, r *http.Request) (transport.Conn, error) {
 	upgrader := websocket.Upgrader{
-		ReadBufferSize:  t.ReadBufferSize,
-		WriteBufferSize: t.WriteBufferSize,
-		CheckOrigin:     t.CheckOrigin,
+		ReadBufferSize:    t.ReadBufferSize,
+		WriteBufferSize:   t.WriteBufferSize,
+		CheckOrigin:       t.CheckOrigin,
+		EnableCompression: t.EnableCompression,
 	}
 	c, err := upgrader.Upgrade(w, r, w.Header())
 	if err != nil {
 		return nil, err
 	}
 
-	return newConn(c, *r.URL, r.Header), nil
+	return newConn(c, *r.URL, r.Header, t.PingInterval), nil
 }
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -145,3 +146,109 @@ func TestWebsocket(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestWebsocketCompression(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	wsTransport := &Transport{EnableCompression: true}
+
+	conn := make(chan transport.Conn, 1)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		c, err := wsTransport.Accept(w, r)
+		require.NoError(t, err)
+
+		conn <- c
+		c.(http.Handler).ServeHTTP(w, r)
+	}
+	httpSvr := httptest.NewServer(http.HandlerFunc(handler))
+	defer httpSvr.Close()
+
+	u, err := url.Parse(httpSvr.URL)
+	must.NoError(err)
+
+	cc, err := wsTransport.Dial(u, nil)
+	must.NoError(err)
+	defer cc.Close()
+
+	sc := <-conn
+	defer sc.Close()
+
+	should.Contains(cc.RemoteHeader().Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+	for _, compress := range []bool{false, true} {
+		sc.(interface{ SetWriteCompression(bool) }).SetWriteCompression(compress)
+
+		w, err := sc.NextWriter(frame.String, packet.MESSAGE)
+		must.NoError(err)
+		_, err = w.Write([]byte("hello hello hello"))
+		must.NoError(err)
+		must.NoError(w.Close())
+
+		_, _, r, err := cc.NextReader()
+		must.NoError(err)
+		b, err := ioutil.ReadAll(r)
+		must.NoError(err)
+		must.NoError(r.Close())
+
+		should.Equal("hello hello hello", string(b))
+	}
+}
+
+func TestWebsocketPingInterval(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	wsTransport := &Transport{PingInterval: 20 * time.Millisecond}
+
+	conn := make(chan transport.Conn, 1)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		c, err := wsTransport.Accept(w, r)
+		require.NoError(t, err)
+
+		conn <- c
+		c.(http.Handler).ServeHTTP(w, r)
+	}
+	httpSvr := httptest.NewServer(http.HandlerFunc(handler))
+	defer httpSvr.Close()
+
+	u, err := url.Parse(httpSvr.URL)
+	must.NoError(err)
+
+	cc, err := (&Transport{}).Dial(u, nil)
+	must.NoError(err)
+	defer cc.Close()
+
+	sc := <-conn
+	defer sc.Close()
+
+	// client answers pings while it's reading
+	go func() {
+		_, _, _, _ = cc.NextReader()
+	}()
+
+	must.NoError(sc.SetReadDeadline(time.Now().Add(100 * time.Millisecond)))
+
+	read := make(chan error, 1)
+	go func() {
+		_, _, r, err := sc.NextReader()
+		if err == nil {
+			err = r.Close()
+		}
+		read <- err
+	}()
+
+	select {
+	case err := <-read:
+		t.Fatalf("read deadline isn't extended by pongs: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	w, err := cc.NextWriter(frame.String, packet.MESSAGE)
+	must.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	must.NoError(err)
+	must.NoError(w.Close())
+
+	should.NoError(<-read)
+}
@@ -86,6 +86,10 @@ func (r rcWrapper) Close() error {
 
 	// Attempt to drain the Reader.
 	_, err := io.Copy(io.Discard, r)
+	// Compressed reader is released once it's read to EOF, so it's already drained.
+	if err == io.ErrClosedPipe {
+		return nil
+	}
 
 	return err
 }
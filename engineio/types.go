@@ -2,6 +2,8 @@ package engineio
 
 import (
 	"net/http"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
 )
 
 // CheckerFunc is function to check request.
@@ -9,3 +11,14 @@ type CheckerFunc func(*http.Request) (http.Header, error)
 
 // ConnInitorFunc is function to do after create connection.
 type ConnInitorFunc func(*http.Request, Conn)
+
+// UpgradeFailureFunc is function to handle failed upgrade of session sid to transport.
+type UpgradeFailureFunc func(sid, transport string, err error)
+
+// Reasons of failed upgrade passed to UpgradeFailureFunc, see errors.Is.
+var (
+	ErrUpgradeTimeout          = session.ErrUpgradeTimeout
+	ErrUpgradeUnexpectedPacket = session.ErrUpgradeUnexpectedPacket
+	ErrUpgradeAborted          = session.ErrUpgradeAborted
+	ErrUpgradeUnsupported      = session.ErrUpgradeUnsupported
+)
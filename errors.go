@@ -19,6 +19,15 @@ var (
 	errDecodeArgs = errors.New("decode args error")
 )
 
+// ErrAckTimeout is passed to ack callback of EmitWithTimeout when server doesn't acknowledge event in time.
+var ErrAckTimeout = errors.New("ack timeout")
+
+// ErrConnClosed is returned by TryEmit and TryJoin of connection which is closed or being closed.
+var ErrConnClosed = errors.New("connection is closed")
+
+// ErrServing is returned by options of server once server is serving, see Server.
+var ErrServing = errors.New("server is already serving")
+
 type errorMessage struct {
 	namespace string
 
@@ -0,0 +1,107 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrUndeclaredEvent is returned by TryEmit of event which isn't declared in namespace,
+// it's also the error of received events which aren't declared, see Server.DeclareEvents.
+var ErrUndeclaredEvent = errors.New("undeclared event")
+
+// DeclareEvents switches namespace to registry mode, where only declared events may be emitted
+// to its connections or received from them, so typo'd event names don't vanish silently.
+// Emits of undeclared events are dropped and logged, TryEmit returns ErrUndeclaredEvent.
+// Received undeclared events aren't handled, they're logged and their ack gets an error like
+// {"error": "undeclared event", "event": "mesage"}. It may be called several times to declare
+// more events.
+func (s *Server) DeclareEvents(namespace string, events ...string) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.DeclareEvents(events...)
+
+	return nil
+}
+
+func (nh *namespaceHandler) DeclareEvents(events ...string) {
+	nh.eventsLock.Lock()
+	defer nh.eventsLock.Unlock()
+
+	if nh.declaredEvents == nil {
+		nh.declaredEvents = make(map[string]struct{}, len(events))
+	}
+	for _, event := range events {
+		nh.declaredEvents[event] = struct{}{}
+	}
+}
+
+// isDeclared reports whether event may be emitted or received, namespace without declared
+// events allows all of them. Chunks of events are checked once they're reassembled.
+func (nh *namespaceHandler) isDeclared(event string) bool {
+	nh.eventsLock.RLock()
+	defer nh.eventsLock.RUnlock()
+
+	if nh.declaredEvents == nil || event == ChunkEvent {
+		return true
+	}
+
+	_, ok := nh.declaredEvents[event]
+
+	return ok
+}
+
+// checkEmit tells why event can't be emitted to connection.
+func (nc *namespaceConn) checkEmit(event string) error {
+	if nc.isClosing() {
+		return ErrConnClosed
+	}
+
+	if nc.handlers == nil {
+		return nil
+	}
+
+	ns := nc.namespace
+	if ns == aliasRootNamespace {
+		ns = rootNamespace
+	}
+
+	if nh, ok := nc.handlers.Get(ns); ok && !nh.isDeclared(event) {
+		return fmt.Errorf("%w: %q in namespace %q", ErrUndeclaredEvent, event, nc.Namespace())
+	}
+
+	return nil
+}
+
+// rejectUndeclaredEvent answers received event which isn't declared with error ack.
+func rejectUndeclaredEvent(c *conn, conn *namespaceConn, event string, header parser.Header, size int) error {
+	conn.Logger().Info("Undeclared event is received", "event", event)
+
+	if c.observeEvent != nil {
+		c.observeEvent(conn, EventMetrics{
+			Namespace:   conn.Namespace(),
+			Event:       event,
+			PayloadSize: size,
+			Err:         fmt.Errorf("%w: %q", ErrUndeclaredEvent, event),
+		})
+	}
+
+	if header.NeedAck {
+		header.Type = parser.Ack
+		c.write(header, reflect.ValueOf(map[string]interface{}{
+			"error": ErrUndeclaredEvent.Error(),
+			"event": event,
+		}))
+	}
+
+	return nil
+}
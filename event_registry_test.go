@@ -0,0 +1,59 @@
+package socketio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestNamespaceDeclareEvents(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	handlers := newNamespaceHandlers()
+	nh := newNamespaceHandler("/chat", nil)
+	handlers.Set("/chat", nh)
+
+	c := newConn(addrEngineConn{id: "sid1"}, handlers)
+	nc := newNamespaceConn(c, "/chat", nh.broadcast)
+	c.namespaces.Set("/chat", nc)
+
+	should.True(nh.isDeclared("anything"), "namespace without declared events allows all")
+
+	nh.DeclareEvents("message")
+	nh.DeclareEvents("typing")
+	should.True(nh.isDeclared("message"))
+	should.True(nh.isDeclared("typing"))
+	should.True(nh.isDeclared(ChunkEvent))
+	should.False(nh.isDeclared("mesage"))
+
+	should.ErrorIs(nc.TryEmit("mesage", "hi", func() {}), ErrUndeclaredEvent)
+	should.ErrorIs(nc.Compress(false).TryEmit("mesage"), ErrUndeclaredEvent)
+	should.Zero(nc.emit("mesage", func() {}))
+	should.Zero(c.pendingAcks(), "ack of dropped emit isn't kept")
+
+	var handled bool
+	nh.OnEvent("mesage", func(Conn, string) {
+		handled = true
+	})
+
+	written := make(chan outgoingPacket, 1)
+	go func() {
+		written <- <-c.writeChan
+	}()
+
+	header := parser.Header{Type: parser.Event, Namespace: "/chat", ID: 3, NeedAck: true}
+	must.NoError(handleEventPacket(c, nc, nh, "mesage", header, nil, 10))
+	should.False(handled, "undeclared event isn't handled")
+
+	pkg := <-written
+	should.Equal(parser.Ack, pkg.Header.Type)
+	should.Equal(uint64(3), pkg.Header.ID)
+	should.Equal([]interface{}{map[string]interface{}{
+		"error": "undeclared event",
+		"event": "mesage",
+	}}, pkg.Data)
+}
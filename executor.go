@@ -0,0 +1,144 @@
+package socketio
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	// defaultKeyedQueueSize is maximum number of queued tasks of lane of keyedExecutor.
+	defaultKeyedQueueSize = 16
+	// defaultKeyedLanes is number of lanes of keyedExecutor, so connection runs at most that
+	// many goroutines for its keys, however many keys its events have.
+	defaultKeyedLanes = 16
+	// defaultKeyedRunners is maximum number of lanes which run tasks on server at once.
+	defaultKeyedRunners = 4096
+)
+
+// SerializationKeyFunc returns the serialization key of an event, e.g. a document ID.
+// Events with the same key are executed sequentially, events with different keys run concurrently.
+type SerializationKeyFunc func(conn Conn, event string, args []interface{}) string
+
+// keyedExecutor runs tasks sequentially per key and concurrently between keys. Keys are mapped to
+// fixed number of lanes by their hash, tasks of lane run in order, so keys which come from events
+// of clients don't make goroutines or queues without limit. Keys of the same lane wait for each
+// other. Once queue of lane is full, Submit blocks until one of its tasks is done, so reader of
+// connection which floods events stops reading frames. Lane which starts running takes one of
+// runners shared by executors of server, Submit blocks while there are none.
+type keyedExecutor struct {
+	queueSize int
+	runners   chan struct{}
+
+	lanes  []keyedLane
+	closed bool
+	done   chan struct{}
+	mu     sync.Mutex
+	// room wakes submitters when task is done or executor is closed.
+	room *sync.Cond
+}
+
+// keyedLane is queue of tasks of keys of the lane, running is set while its goroutine runs them.
+type keyedLane struct {
+	tasks   []func()
+	running bool
+}
+
+// newKeyedExecutor gives executor with lanes whose queues hold queueSize tasks, nil runners don't
+// limit running lanes.
+func newKeyedExecutor(lanes, queueSize int, runners chan struct{}) *keyedExecutor {
+	e := &keyedExecutor{
+		queueSize: queueSize,
+		runners:   runners,
+		lanes:     make([]keyedLane, lanes),
+		done:      make(chan struct{}),
+	}
+	e.room = sync.NewCond(&e.mu)
+
+	return e
+}
+
+// Submit queues the task after all tasks already submitted with the same key, it waits while
+// queue of lane of the key is full or runners of server are taken, unless executor is closed.
+func (e *keyedExecutor) Submit(key string, task func()) {
+	lane := e.lane(key)
+
+	e.mu.Lock()
+	for len(e.lanes[lane].tasks) >= e.queueSize && !e.closed {
+		e.room.Wait()
+	}
+
+	l := &e.lanes[lane]
+	l.tasks = append(l.tasks, task)
+	start := !l.running
+	l.running = true
+	e.mu.Unlock()
+
+	if start {
+		acquired := e.acquire()
+		go e.run(lane, acquired)
+	}
+}
+
+func (e *keyedExecutor) lane(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(len(e.lanes)))
+}
+
+// acquire takes runner for lane which starts, it tells whether runner is taken. Once executor is
+// closed, lane runs without runner, since its queued tasks are still run.
+func (e *keyedExecutor) acquire() bool {
+	if e.runners == nil {
+		return false
+	}
+
+	select {
+	case e.runners <- struct{}{}:
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+// close stops Submit from waiting, queued tasks are still run.
+func (e *keyedExecutor) close() {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed {
+		e.closed = true
+		close(e.done)
+	}
+	e.room.Broadcast()
+}
+
+func (e *keyedExecutor) run(lane int, acquired bool) {
+	if acquired {
+		defer func() {
+			<-e.runners
+		}()
+	}
+
+	for {
+		e.mu.Lock()
+		l := &e.lanes[lane]
+		if len(l.tasks) == 0 {
+			l.tasks = nil
+			l.running = false
+			e.mu.Unlock()
+			return
+		}
+
+		task := l.tasks[0]
+		l.tasks = l.tasks[1:]
+		e.room.Broadcast()
+		e.mu.Unlock()
+
+		task()
+	}
+}
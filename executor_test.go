@@ -0,0 +1,247 @@
+package socketio
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestKeyedExecutor(t *testing.T) {
+	e := newKeyedExecutor(defaultKeyedLanes, defaultKeyedQueueSize, nil)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		orders = make(map[string][]int)
+	)
+
+	keys := []string{"doc1", "doc2", "doc3"}
+	for i := 0; i < 100; i++ {
+		for _, key := range keys {
+			i, key := i, key
+
+			wg.Add(1)
+			e.Submit(key, func() {
+				defer wg.Done()
+
+				mu.Lock()
+				orders[key] = append(orders[key], i)
+				mu.Unlock()
+			})
+		}
+	}
+
+	wg.Wait()
+
+	for _, key := range keys {
+		for i, v := range orders[key] {
+			assert.Equal(t, i, v)
+		}
+	}
+}
+
+func TestKeyedExecutorQueueSize(t *testing.T) {
+	e := newKeyedExecutor(defaultKeyedLanes, 1, nil)
+	require.NotEqual(t, e.lane("doc1"), e.lane("doc2"), "keys share lane")
+
+	gate := make(chan struct{})
+	e.Submit("doc1", func() { <-gate })
+	e.Submit("doc1", func() {})
+
+	submitted := func(key string) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			e.Submit(key, func() {})
+			close(done)
+		}()
+
+		return done
+	}
+
+	select {
+	case <-submitted("doc2"):
+	case <-time.After(5 * time.Second):
+		t.Fatal("key under the limit waits")
+	}
+
+	done := submitted("doc1")
+	select {
+	case <-done:
+		t.Fatal("key at the limit doesn't wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gate)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit isn't resumed once task is done")
+	}
+
+	gate = make(chan struct{})
+	e.Submit("doc3", func() { <-gate })
+	e.Submit("doc3", func() {})
+	done = submitted("doc3")
+
+	e.close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit to closed executor waits")
+	}
+	close(gate)
+
+	var disabled *keyedExecutor
+	disabled.close()
+}
+
+func TestKeyedExecutorFloodKeys(t *testing.T) {
+	should := assert.New(t)
+
+	// runners are shared by executors of server
+	runners := make(chan struct{}, 4)
+	e := newKeyedExecutor(defaultKeyedLanes, 2, runners)
+	other := newKeyedExecutor(defaultKeyedLanes, 2, runners)
+
+	const keys = 10000
+
+	gate := make(chan struct{})
+	var mu sync.Mutex
+	var running, maxRunning int
+	var handled, submitted int32
+	task := func() {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		<-gate
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		atomic.AddInt32(&handled, 1)
+	}
+
+	flooded := make(chan struct{})
+	go func() {
+		defer close(flooded)
+
+		// client sends events with distinct keys
+		for i := 0; i < keys; i++ {
+			e.Submit(strconv.Itoa(i), task)
+			atomic.AddInt32(&submitted, 1)
+		}
+	}()
+
+	select {
+	case <-flooded:
+		t.Fatal("flood of distinct keys isn't stopped")
+	case <-time.After(50 * time.Millisecond):
+	}
+	should.LessOrEqual(atomic.LoadInt32(&submitted), int32(defaultKeyedLanes*3), "only queues of lanes are filled")
+	should.Len(runners, cap(runners))
+
+	// runners of server are taken, so other connections wait as well
+	otherDone := make(chan struct{})
+	go func() {
+		other.Submit("doc", func() {})
+		close(otherDone)
+	}()
+	select {
+	case <-otherDone:
+		t.Fatal("runners of server aren't limited")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gate)
+	select {
+	case <-flooded:
+	case <-time.After(10 * time.Second):
+		t.Fatal("flood isn't resumed")
+	}
+	<-otherDone
+
+	should.Eventually(func() bool {
+		return atomic.LoadInt32(&handled) == keys && len(runners) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	should.LessOrEqual(maxRunning, cap(runners))
+	mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	should.Len(e.lanes, defaultKeyedLanes)
+	for _, lane := range e.lanes {
+		should.False(lane.running)
+		should.Empty(lane.tasks)
+	}
+}
+
+func TestServerSerializeEvent(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	const edits = 40
+
+	type edit struct {
+		doc string
+		n   int
+	}
+	handled := make(chan edit, edits)
+	disconnected := make(chan string, 1)
+	_, url := newConformanceServer(t, func(server *Server) {
+		server.SerializeEvent("/", "edit", func(_ Conn, _ string, args []interface{}) string {
+			return args[0].(string)
+		})
+		server.OnEvent("/", "edit", func(_ Conn, doc string, n int) {
+			// earlier edits take longer, so they'd finish last without serialization
+			time.Sleep(time.Duration(edits-n) * 100 * time.Microsecond)
+			handled <- edit{doc: doc, n: n}
+		})
+		server.SerializeEvent("/", "crash", func(Conn, string, []interface{}) string {
+			return "crash"
+		})
+		server.OnEvent("/", "crash", func(Conn) {
+			panic("crash")
+		})
+		server.OnDisconnect("/", func(_ Conn, reason string) {
+			disconnected <- reason
+		})
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+	_, _, _ = c.receive(t)
+
+	for i := 0; i < edits; i++ {
+		doc := []string{"doc1", "doc2"}[i%2]
+		c.send(t, parser.Header{Type: parser.Event}, "edit", doc, i)
+	}
+
+	last := map[string]int{"doc1": -1, "doc2": -1}
+	for i := 0; i < edits; i++ {
+		select {
+		case e := <-handled:
+			must.Greater(e.n, last[e.doc], "events of key are handled in order")
+			last[e.doc] = e.n
+		case <-time.After(5 * time.Second):
+			t.Fatal("serialized events aren't handled")
+		}
+	}
+
+	c.send(t, parser.Header{Type: parser.Event}, "crash")
+	select {
+	case reason := <-disconnected:
+		should.NotEmpty(reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection isn't closed on error of serialized handler")
+	}
+}
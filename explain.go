@@ -0,0 +1,164 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// BroadcastExplanation describes audience of a broadcast, see Server.ExplainBroadcast.
+type BroadcastExplanation struct {
+	// Local are connections of this server which would receive the broadcast, sorted by id.
+	Local []Conn
+
+	// Remote are numbers of connections of other nodes which would receive the broadcast,
+	// by node id. It's empty without adapter.
+	Remote map[string]int
+}
+
+// Total gives number of connections which would receive the broadcast.
+func (e *BroadcastExplanation) Total() int {
+	total := len(e.Local)
+	for _, n := range e.Remote {
+		total += n
+	}
+
+	return total
+}
+
+// broadcastExplainer is implemented by broadcasts which can explain their audience.
+type broadcastExplainer interface {
+	explain(rooms, except []string) *BroadcastExplanation
+}
+
+// ExplainBroadcast gives which connections would receive a broadcast to rooms of namespace
+// without sending anything, connections which joined any of except rooms are left out.
+// Broadcast to empty rooms goes to whole namespace. It gives nil when namespace doesn't exist.
+func (s *Server) ExplainBroadcast(namespace string, rooms, except []string) *BroadcastExplanation {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return nil
+	}
+
+	explainer, ok := nspHandler.getBroadcast().(broadcastExplainer)
+	if !ok {
+		return nil
+	}
+
+	return explainer.explain(rooms, except)
+}
+
+func (bc *broadcast) explain(rooms, except []string) *BroadcastExplanation {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return &BroadcastExplanation{
+		Local:  sortedConns(audience(bc.rooms, rooms, except)),
+		Remote: map[string]int{},
+	}
+}
+
+func (bc *redisBroadcast) explain(rooms, except []string) *BroadcastExplanation {
+	bc.lock.RLock()
+	local := sortedConns(audience(bc.rooms, rooms, except))
+	bc.lock.RUnlock()
+
+	return &BroadcastExplanation{
+		Local:  local,
+		Remote: bc.explainRemote(rooms, except),
+	}
+}
+
+// explainRemote asks other nodes for number of their connections in audience of a broadcast.
+func (bc *redisBroadcast) explainRemote(rooms, except []string) map[string]int {
+	if bc.nodeCompatible() {
+		return bc.nodeExplainRemote(rooms, except)
+	}
+
+	roomsJSON, _ := json.Marshal(rooms)
+	exceptJSON, _ := json.Marshal(except)
+
+	req := explainRequest{
+		RequestType: explainReqType,
+		RequestID:   newV4UUID(),
+		Rooms:       string(roomsJSON),
+		Except:      string(exceptJSON),
+	}
+
+	reqJSON, err := json.Marshal(&req)
+	if err != nil {
+		return map[string]int{}
+	}
+
+	numSub, err := bc.getNumSub(bc.reqChannel)
+	if err != nil {
+		return map[string]int{}
+	}
+
+	req.counts = make(map[string]int)
+
+	release, err := bc.register(context.Background(), req.RequestID, &req, numSub, bc.opts.getRequestTimeout())
+	if err != nil {
+		return map[string]int{}
+	}
+	defer release()
+
+	if _, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON); err != nil {
+		return map[string]int{}
+	}
+
+	if err = req.wait(); err != nil {
+		logger.Info("redis adapter explain request is partial", "namespace", bc.nsp, "error", err.Error())
+	}
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	return req.counts
+}
+
+// onExplainRequest counts local connections in audience of request.
+func (bc *redisBroadcast) onExplainRequest(req map[string]string) {
+	var rooms, except []string
+	_ = json.Unmarshal([]byte(req["Rooms"]), &rooms)
+	_ = json.Unmarshal([]byte(req["Except"]), &except)
+
+	bc.lock.RLock()
+	connections := len(audience(bc.rooms, rooms, except))
+	bc.lock.RUnlock()
+
+	bc.publish(bc.resChannel, &roomLenResponse{
+		RequestType: req["RequestType"],
+		RequestID:   req["RequestID"],
+		NodeID:      bc.uid,
+		Connections: connections,
+	})
+}
+
+// audience selects connections of rooms, or of all rooms when rooms are empty, which didn't join
+// any of except rooms. Index of rooms must be locked by caller.
+func audience(index map[string]map[string]Conn, rooms, except []string) map[string]Conn {
+	if len(rooms) == 0 {
+		rooms = make([]string, 0, len(index))
+		for room := range index {
+			rooms = append(rooms, room)
+		}
+	}
+
+	return Union(rooms...).Except(except...).eval(index, nil)
+}
+
+func sortedConns(connections map[string]Conn) []Conn {
+	ret := make([]Conn, 0, len(connections))
+	for _, connection := range connections {
+		ret = append(ret, connection)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID() < ret[j].ID()
+	})
+
+	return ret
+}
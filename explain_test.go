@@ -0,0 +1,65 @@
+package socketio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestServerExplainBroadcast(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+
+	bc := server.getNamespace("/chat").broadcast
+
+	conns := make(map[string]Conn)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		conns[id] = newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/chat", bc)
+		conns[id].Join(id)
+	}
+	conns["1"].Join("lobby")
+	conns["2"].Join("lobby")
+	conns["2"].Join("muted")
+	conns["3"].Join("games")
+
+	ids := func(explanation *BroadcastExplanation) []string {
+		ret := make([]string, 0, len(explanation.Local))
+		for _, c := range explanation.Local {
+			ret = append(ret, c.ID())
+		}
+		return ret
+	}
+
+	tests := []struct {
+		name   string
+		rooms  []string
+		except []string
+		want   []string
+	}{
+		{"room", []string{"lobby"}, nil, []string{"1", "2"}},
+		{"rooms", []string{"lobby", "games"}, nil, []string{"1", "2", "3"}},
+		{"except", []string{"lobby"}, []string{"muted"}, []string{"1"}},
+		{"namespace", nil, nil, []string{"1", "2", "3", "4"}},
+		{"namespace except", nil, []string{"lobby"}, []string{"3", "4"}},
+		{"missing room", []string{"missing"}, nil, []string{}},
+	}
+
+	for _, test := range tests {
+		explanation := server.ExplainBroadcast("/chat", test.rooms, test.except)
+		must.NotNil(explanation, test.name)
+
+		should.Equal(test.want, ids(explanation), test.name)
+		should.Empty(explanation.Remote, test.name)
+		should.Equal(len(test.want), explanation.Total(), test.name)
+	}
+
+	should.Nil(server.ExplainBroadcast("/missing", nil, nil))
+}
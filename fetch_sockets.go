@@ -0,0 +1,216 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RemoteSocket describes connection of a node of cluster, see Server.FetchSockets.
+type RemoteSocket struct {
+	ID string `json:"id"`
+	// Node is id of node which serves connection, it's empty for node.js servers.
+	Node      string          `json:"node,omitempty"`
+	Rooms     []string        `json:"rooms"`
+	Handshake SocketHandshake `json:"handshake"`
+}
+
+// SocketHandshake describes handshake request of connection, like handshake of node.js socket.
+type SocketHandshake struct {
+	Address string `json:"address"`
+	URL     string `json:"url"`
+	// Query and Headers have the first value of each parameter, names of headers are in
+	// lower case.
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+}
+
+// socketFetcher is implemented by broadcasts which describe their connections.
+type socketFetcher interface {
+	fetchSockets(ctx context.Context, room string) ([]RemoteSocket, error)
+}
+
+// FetchSockets gives connections of room of namespace on every node of cluster, or connections
+// of whole namespace when room is empty, like fetchSockets of node.js. Connections of nodes
+// which don't respond within RequestTimeout of adapter are missing, error tells it then.
+func (s *Server) FetchSockets(namespace, room string) ([]RemoteSocket, error) {
+	return s.WithContext(context.Background()).FetchSockets(namespace, room)
+}
+
+// FetchSockets gives connections like Server.FetchSockets, it stops waiting for other nodes once
+// ctx is done.
+func (b *ContextBroadcaster) FetchSockets(namespace, room string) ([]RemoteSocket, error) {
+	nspHandler, err := b.namespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, ok := nspHandler.getBroadcast().(socketFetcher)
+	if !ok {
+		return nil, fmt.Errorf("broadcast of namespace %q doesn't fetch sockets", namespace)
+	}
+
+	return fetcher.fetchSockets(b.ctx, room)
+}
+
+func (bc *broadcast) fetchSockets(_ context.Context, room string) ([]RemoteSocket, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return describeSockets(bc.rooms, room, ""), nil
+}
+
+// describeSockets describes connections of room of index, or of every room when it's empty.
+func describeSockets(index map[string]map[string]Conn, room, node string) []RemoteSocket {
+	var rooms []string
+	if room != "" {
+		rooms = []string{room}
+	}
+
+	selected := sortedConns(audience(index, rooms, nil))
+	sockets := make([]RemoteSocket, len(selected))
+	for i, connection := range selected {
+		sockets[i] = describeSocket(connection, node, roomsOf(index, connection.ID()))
+	}
+
+	return sockets
+}
+
+func roomsOf(index map[string]map[string]Conn, id string) []string {
+	rooms := []string{}
+	for room, connections := range index {
+		if _, ok := connections[id]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.Strings(rooms)
+
+	return rooms
+}
+
+func describeSocket(connection Conn, node string, rooms []string) RemoteSocket {
+	socket := RemoteSocket{
+		ID:    connection.ID(),
+		Node:  node,
+		Rooms: rooms,
+		Handshake: SocketHandshake{
+			Query:   map[string]string{},
+			Headers: map[string]string{},
+		},
+	}
+
+	if addr := connection.RemoteAddr(); addr != nil {
+		socket.Handshake.Address = addr.String()
+	}
+
+	u := connection.URL()
+	socket.Handshake.URL = u.RequestURI()
+	for key, values := range u.Query() {
+		if len(values) > 0 {
+			socket.Handshake.Query[key] = values[0]
+		}
+	}
+
+	for key, values := range connection.RemoteHeader() {
+		if len(values) > 0 {
+			socket.Handshake.Headers[strings.ToLower(key)] = values[0]
+		}
+	}
+
+	return socket
+}
+
+// fetchSocketsRequest asks nodes to describe connections of room.
+type fetchSocketsRequest struct {
+	RequestType    string
+	RequestID      string
+	Room           string
+	sockets        []RemoteSocket `json:"-"`
+	pendingRequest `json:"-"`
+}
+
+type fetchSocketsResponse struct {
+	RequestType string
+	RequestID   string
+	NodeID      string
+	Sockets     []RemoteSocket
+}
+
+func (bc *redisBroadcast) fetchSockets(ctx context.Context, room string) ([]RemoteSocket, error) {
+	if bc.nodeCompatible() {
+		return bc.nodeFetchSockets(ctx, room)
+	}
+
+	req := fetchSocketsRequest{
+		RequestType: fetchSocketsReqType,
+		RequestID:   newV4UUID(),
+		Room:        room,
+	}
+
+	reqJSON, err := json.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	numSub, err := bc.getNumSub(bc.reqChannel)
+	if err != nil {
+		return bc.localSockets(room), err
+	}
+
+	release, err := bc.register(ctx, req.RequestID, &req, numSub, bc.opts.getRequestTimeout())
+	if err != nil {
+		return bc.localSockets(room), err
+	}
+	defer release()
+
+	if _, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON); err != nil {
+		return bc.localSockets(room), err
+	}
+
+	err = req.wait()
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	sockets := append([]RemoteSocket{}, req.sockets...)
+	sort.Slice(sockets, func(i, j int) bool {
+		return sockets[i].ID < sockets[j].ID
+	})
+
+	return sockets, err
+}
+
+// localSockets describes connections of room on this node.
+func (bc *redisBroadcast) localSockets(room string) []RemoteSocket {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return describeSockets(bc.rooms, room, bc.uid)
+}
+
+// onFetchSocketsRequest responds connections of room of request on this node.
+func (bc *redisBroadcast) onFetchSocketsRequest(req map[string]string) {
+	bc.publish(bc.resChannel, &fetchSocketsResponse{
+		RequestType: req["RequestType"],
+		RequestID:   req["RequestID"],
+		NodeID:      bc.uid,
+		Sockets:     bc.localSockets(req["Room"]),
+	})
+}
+
+// onFetchSocketsResponse applies connections of node to request.
+func (bc *redisBroadcast) onFetchSocketsResponse(req *fetchSocketsRequest, msg []byte) {
+	var res fetchSocketsResponse
+	if err := json.Unmarshal(msg, &res); err != nil {
+		return
+	}
+
+	req.respond(res.NodeID, func() {
+		for _, socket := range res.Sockets {
+			socket.Node = res.NodeID
+			req.sockets = append(req.sockets, socket)
+		}
+	})
+}
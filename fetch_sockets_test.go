@@ -0,0 +1,151 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerFetchSockets(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(nil)
+	server.OnConnect("/chat", func(Conn) error { return nil })
+
+	bc := server.getNamespace("/chat").getBroadcast()
+	for _, id := range []string{"2", "1"} {
+		nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/chat", bc)
+		nc.Join(id)
+		nc.Join("lobby")
+	}
+
+	sockets, err := server.FetchSockets("/chat", "lobby")
+	must.NoError(err)
+	must.Len(sockets, 2)
+	should.Equal(RemoteSocket{
+		ID:    "1",
+		Rooms: []string{"1", "lobby"},
+		Handshake: SocketHandshake{
+			URL:     "/socket.io/?token=1",
+			Query:   map[string]string{"token": "1"},
+			Headers: map[string]string{"user-agent": "test"},
+		},
+	}, sockets[0])
+	should.Equal("2", sockets[1].ID)
+
+	sockets, err = server.FetchSockets("/chat", "")
+	must.NoError(err)
+	should.Len(sockets, 2)
+
+	sockets, err = server.FetchSockets("/chat", "missing")
+	must.NoError(err)
+	should.Empty(sockets)
+
+	_, err = server.FetchSockets("/missing", "")
+	should.Error(err)
+}
+
+func TestRedisBroadcastFetchSockets(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 16)
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBSUB":
+			return "*2\r\n" + respArray(cmd[2])[4:] + ":2\r\n"
+		case "PUBLISH":
+			published <- cmd[1:]
+		}
+		return ":1\r\n"
+	})
+
+	bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{Addr: addr, NodeID: "node1"}))
+	must.NoError(err)
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/chat", bc)
+	nc.Join("lobby")
+
+	// this node and node2 respond requests, responses are applied without redis, which
+	// answers request after it's taken
+	go func() {
+		for msg := range published {
+			if !strings.HasPrefix(msg[0], "socket.io-request#") {
+				continue
+			}
+
+			var req map[string]string
+			_ = json.Unmarshal([]byte(msg[1]), &req)
+
+			for node, sockets := range map[string][]RemoteSocket{
+				"node1": bc.localSockets(req["Room"]),
+				"node2": {{ID: "2", Rooms: []string{"lobby"}}},
+			} {
+				res, _ := json.Marshal(&fetchSocketsResponse{
+					RequestType: req["RequestType"],
+					RequestID:   req["RequestID"],
+					NodeID:      node,
+					Sockets:     sockets,
+				})
+				bc.onResponse(res)
+			}
+		}
+	}()
+	defer close(published)
+
+	sockets, err := bc.fetchSockets(context.Background(), "lobby")
+	must.NoError(err)
+	must.Len(sockets, 2)
+	should.Equal("1", sockets[0].ID)
+	should.Equal("node1", sockets[0].Node)
+	should.Equal([]string{"lobby"}, sockets[0].Rooms)
+	should.Equal("test", sockets[0].Handshake.Headers["user-agent"])
+	should.Equal("2", sockets[1].ID)
+	should.Equal("node2", sockets[1].Node)
+
+	// responses aren't waited for once request is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bc.fetchSockets(ctx, "lobby")
+	should.ErrorIs(err, context.Canceled)
+}
+
+func TestNodeSocketDetails(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var res nodeResponse
+	must.NoError(json.Unmarshal([]byte(`{"requestId":"1","sockets":[
+		"plain",
+		{"id":"detailed","rooms":["detailed","lobby"],"handshake":{"address":"::1","url":"/socket.io/?a=1",
+			"query":{"a":"1","b":["2","3"]},"headers":{"host":"example.com"}},"data":{}}
+	]}`), &res))
+
+	must.Len(res.Sockets, 2)
+	should.Equal(RemoteSocket{ID: "plain", Rooms: []string{}, Handshake: SocketHandshake{
+		Query:   map[string]string{},
+		Headers: map[string]string{},
+	}}, res.Sockets[0].remoteSocket())
+	should.Equal(RemoteSocket{
+		ID:    "detailed",
+		Rooms: []string{"detailed", "lobby"},
+		Handshake: SocketHandshake{
+			Address: "::1",
+			URL:     "/socket.io/?a=1",
+			Query:   map[string]string{"a": "1", "b": "2"},
+			Headers: map[string]string{"host": "example.com"},
+		},
+	}, res.Sockets[1].remoteSocket())
+}
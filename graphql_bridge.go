@@ -0,0 +1,149 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const graphQLSubscriptionBuffer = 16
+
+// ErrUnknownTopic is returned by GraphQLBridge for topic which isn't mapped.
+var ErrUnknownTopic = errors.New("unknown graphql topic")
+
+// GraphQLTopic maps GraphQL subscription topic to event of socket.io room,
+// empty Room maps it to event broadcast to the whole namespace.
+type GraphQLTopic struct {
+	Namespace string
+	Room      string
+	Event     string
+}
+
+func (t GraphQLTopic) normalize() GraphQLTopic {
+	if t.Namespace == aliasRootNamespace {
+		t.Namespace = rootNamespace
+	}
+
+	return t
+}
+
+// GraphQLBridge serves GraphQL subscriptions and socket.io rooms from the same broadcasts.
+// Broadcasts of socket.io events mapped to topic are delivered to subscribers of the topic, and
+// payloads published to topic are broadcast to socket.io room as event with payload as its
+// only argument.
+type GraphQLBridge struct {
+	server *Server
+
+	topics map[string]GraphQLTopic
+	routes map[GraphQLTopic]string
+
+	subscribers map[string]map[chan interface{}]struct{}
+	lock        sync.RWMutex
+}
+
+// NewGraphQLBridge creates bridge for topics, mapped by their names. Subscribers receive
+// broadcasts of this server and broadcasts of other nodes which adapter delivers to this node,
+// so topics are shared by the cluster.
+func (s *Server) NewGraphQLBridge(topics map[string]GraphQLTopic) (*GraphQLBridge, error) {
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	b := &GraphQLBridge{
+		server:      s,
+		topics:      make(map[string]GraphQLTopic, len(topics)),
+		routes:      make(map[GraphQLTopic]string, len(topics)),
+		subscribers: make(map[string]map[chan interface{}]struct{}),
+	}
+
+	for name, topic := range topics {
+		topic = topic.normalize()
+
+		b.topics[name] = topic
+		b.routes[topic] = name
+	}
+
+	s.observers = append(s.observers, b.observe)
+	s.addRemoteObserver(b.observe)
+
+	return b, nil
+}
+
+// Subscribe gives channel of payloads published to topic, e.g. to return it from subscription
+// resolver. Channel is closed once ctx is done. Payloads which don't fit buffer of slow subscriber
+// are dropped, so subscribers don't hold broadcasts.
+func (b *GraphQLBridge) Subscribe(ctx context.Context, topic string) (<-chan interface{}, error) {
+	if _, ok := b.topics[topic]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTopic, topic)
+	}
+
+	ch := make(chan interface{}, graphQLSubscriptionBuffer)
+
+	b.lock.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[chan interface{}]struct{})
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	b.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		delete(b.subscribers[topic], ch)
+		if len(b.subscribers[topic]) == 0 {
+			delete(b.subscribers, topic)
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// Publish broadcasts payload to socket.io room of topic, subscribers of topic receive it too.
+func (b *GraphQLBridge) Publish(topic string, payload interface{}) error {
+	t, ok := b.topics[topic]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTopic, topic)
+	}
+
+	if t.Room != "" {
+		return b.server.EmitToRoom(t.Namespace, t.Room, t.Event, payload)
+	}
+
+	if !b.server.BroadcastToNamespace(t.Namespace, t.Event, payload) {
+		return fmt.Errorf("namespace %q doesn't exist", t.Namespace)
+	}
+
+	return nil
+}
+
+// observe delivers broadcast to subscribers of its topic, broadcasts to room sets aren't mapped.
+func (b *GraphQLBridge) observe(record BroadcastRecord) {
+	if record.RoomSet != nil {
+		return
+	}
+
+	topic, ok := b.routes[GraphQLTopic{Namespace: record.Namespace, Room: record.Room, Event: record.Event}.normalize()]
+	if !ok {
+		return
+	}
+
+	var payload interface{} = record.Args
+	if len(record.Args) == 1 {
+		payload = record.Args[0]
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
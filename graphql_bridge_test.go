@@ -0,0 +1,102 @@
+package socketio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestGraphQLBridge(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	bridge, err := server.NewGraphQLBridge(map[string]GraphQLTopic{
+		"messageAdded": {Namespace: "/", Room: "chat", Event: "message"},
+		"announcement": {Namespace: "/", Event: "announce"},
+	})
+	must.NoError(err)
+
+	recorder := newLockedRecorder("1", "/", nil)
+	server.JoinRoom("/", "chat", recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, err := bridge.Subscribe(ctx, "messageAdded")
+	must.NoError(err)
+	announcements, err := bridge.Subscribe(ctx, "announcement")
+	must.NoError(err)
+
+	_, err = bridge.Subscribe(ctx, "missing")
+	should.ErrorIs(err, ErrUnknownTopic)
+	should.ErrorIs(bridge.Publish("missing", 1), ErrUnknownTopic)
+
+	// GraphQL to socket.io
+	must.NoError(bridge.Publish("messageAdded", "hello"))
+	should.Equal("hello", <-messages)
+
+	// socket.io to GraphQL
+	should.True(server.BroadcastToRoom("/", "chat", "message", "hi", 2))
+	should.Equal([]interface{}{"hi", 2}, <-messages)
+
+	should.True(server.BroadcastToNamespace("/", "announce", "maintenance"))
+	should.Equal("maintenance", <-announcements)
+
+	// other events and room sets aren't mapped
+	should.True(server.BroadcastToRoom("/", "chat", "typing"))
+	should.True(server.BroadcastToRoomSet("/", Union("chat"), "message", "set"))
+	should.Empty(messages)
+
+	should.Equal([]string{"message", "message", "announce", "typing", "message"}, recorder.received())
+
+	cancel()
+	for range messages {
+	}
+	for range announcements {
+	}
+}
+
+func TestGraphQLBridgeCluster(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, _ := newRedisServers(t, "/chat")
+
+	topics := map[string]GraphQLTopic{"messageAdded": {Namespace: "/chat", Room: "lobby", Event: "message"}}
+	bridges := make([]*GraphQLBridge, 2)
+	for i, server := range servers {
+		var err error
+		bridges[i], err = server.NewGraphQLBridge(topics)
+		must.NoError(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, err := bridges[1].Subscribe(ctx, "messageAdded")
+	must.NoError(err)
+
+	// broadcast of other node reaches subscribers once adapter delivers it
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "message", "hi"))
+	select {
+	case payload := <-messages:
+		should.Equal("hi", payload)
+	case <-time.After(time.Second):
+		t.Fatal("broadcast of other node isn't delivered to subscriber")
+	}
+
+	must.NoError(bridges[0].Publish("messageAdded", "hello"))
+	select {
+	case payload := <-messages:
+		should.Equal("hello", payload)
+	case <-time.After(time.Second):
+		t.Fatal("payload published on other node isn't delivered to subscriber")
+	}
+}
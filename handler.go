@@ -12,6 +12,9 @@ const (
 type funcHandler struct {
 	argTypes []reflect.Type
 	f        reflect.Value
+
+	// variadic handler gets arguments after argTypes too, as values of any JSON type.
+	variadic bool
 }
 
 func (h *funcHandler) Call(args []reflect.Value) (ret []reflect.Value, err error) {
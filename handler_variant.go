@@ -0,0 +1,183 @@
+package socketio
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// VariantRouter decides whether connection to namespace is served by handler variant, it's
+// called once when connection connects to namespace.
+type VariantRouter func(conn Conn) bool
+
+// Percent gives router which picks percent of connections, e.g. 5 for canary on 5% of traffic.
+// Connection is picked by hash of its id, so the choice is stable while percent grows.
+func Percent(percent float64) VariantRouter {
+	return func(conn Conn) bool {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(conn.ID()))
+
+		return float64(h.Sum32()%10000) < percent*100
+	}
+}
+
+// HandlerVariant is alternative set of handlers of namespace, e.g. new implementation canaried on
+// fraction of connections, see Server.HandlerVariant. Handlers which aren't set by variant are
+// those of namespace.
+type HandlerVariant struct {
+	name  string
+	route VariantRouter
+
+	onConnect    func(conn Conn) error
+	onDisconnect func(conn Conn, msg string)
+	onError      func(conn Conn, err error)
+
+	events     map[string]*funcHandler
+	eventsLock sync.RWMutex
+}
+
+// HandlerVariant adds variant of handlers of namespace named name, it serves connections picked
+// by route, e.g. Percent(5) or predicate of auth or headers of connection. Variants are asked in
+// order they're added, connections which no variant picks are served by handlers of namespace.
+// Rooms, limits and other settings of namespace are shared by its variants, see ConnVariant.
+func (s *Server) HandlerVariant(namespace, name string, route VariantRouter) (*HandlerVariant, error) {
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	v := &HandlerVariant{
+		name:   name,
+		route:  route,
+		events: make(map[string]*funcHandler),
+	}
+
+	h.variantsLock.Lock()
+	h.variants = append(h.variants, v)
+	h.variantsLock.Unlock()
+
+	return v, nil
+}
+
+// ConnVariant gives name of handler variant which serves conn, empty when it's served by handlers
+// of namespace.
+func ConnVariant(conn Conn) string {
+	if nc, ok := conn.(*namespaceConn); ok && nc.variant != nil {
+		return nc.variant.name
+	}
+
+	return ""
+}
+
+// Name gives name of variant.
+func (v *HandlerVariant) Name() string {
+	return v.name
+}
+
+// OnConnect sets handler of connect to namespace of connections served by variant.
+func (v *HandlerVariant) OnConnect(f func(Conn) error) {
+	v.onConnect = f
+}
+
+// OnDisconnect sets handler of disconnect from namespace of connections served by variant.
+func (v *HandlerVariant) OnDisconnect(f func(Conn, string)) {
+	v.onDisconnect = f
+}
+
+// OnError sets handler of errors of connections served by variant.
+func (v *HandlerVariant) OnError(f func(Conn, error)) {
+	v.onError = f
+}
+
+// OnEvent sets handler of event of connections served by variant, like Server.OnEvent.
+func (v *HandlerVariant) OnEvent(event string, f interface{}) {
+	v.eventsLock.Lock()
+	defer v.eventsLock.Unlock()
+
+	v.events[event] = newEventFunc(f)
+}
+
+func (v *HandlerVariant) getEvent(event string) *funcHandler {
+	v.eventsLock.RLock()
+	defer v.eventsLock.RUnlock()
+
+	return v.events[event]
+}
+
+// pickVariant gives the first variant of namespace which routes conn, nil when there's none.
+func (nh *namespaceHandler) pickVariant(conn Conn) *HandlerVariant {
+	nh.variantsLock.RLock()
+	defer nh.variantsLock.RUnlock()
+
+	for _, v := range nh.variants {
+		if v.route != nil && v.route(conn) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// variantOf gives variant which serves conn, nil for handlers of namespace.
+func variantOf(conn Conn) *HandlerVariant {
+	if nc, ok := conn.(*namespaceConn); ok {
+		return nc.variant
+	}
+
+	return nil
+}
+
+func (nh *namespaceHandler) connectHandler(conn Conn) func(Conn) error {
+	if v := variantOf(conn); v != nil && v.onConnect != nil {
+		return v.onConnect
+	}
+
+	return nh.onConnect
+}
+
+// disconnectHandler gives OnDisconnect handler of conn, it runs disconnect hooks of namespace first.
+func (nh *namespaceHandler) disconnectHandler(conn Conn) func(Conn, string) {
+	onDisconnect := nh.onDisconnect
+	if v := variantOf(conn); v != nil && v.onDisconnect != nil {
+		onDisconnect = v.onDisconnect
+	}
+
+	hooks := nh.getDisconnectHooks()
+	if len(hooks) == 0 {
+		return onDisconnect
+	}
+
+	return func(conn Conn, msg string) {
+		for _, hook := range hooks {
+			hook(conn)
+		}
+		if onDisconnect != nil {
+			onDisconnect(conn, msg)
+		}
+	}
+}
+
+func (nh *namespaceHandler) errorHandler(conn Conn) func(Conn, error) {
+	if v := variantOf(conn); v != nil && v.onError != nil {
+		return v.onError
+	}
+
+	return nh.onError
+}
+
+// eventHandler gives handler of event of conn, handler of namespace when its variant has none.
+func (nh *namespaceHandler) eventHandler(conn Conn, event string) *funcHandler {
+	if v := variantOf(conn); v != nil {
+		if f := v.getEvent(event); f != nil {
+			return f
+		}
+	}
+
+	nh.eventsLock.RLock()
+	defer nh.eventsLock.RUnlock()
+
+	return nh.events[event]
+}
@@ -0,0 +1,111 @@
+package socketio
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestPercent(t *testing.T) {
+	should := assert.New(t)
+
+	ten, quarter := Percent(10), Percent(25)
+
+	var picked int
+	for i := 0; i < 2000; i++ {
+		conn := newNamespaceConn(newConn(addrEngineConn{id: strconv.Itoa(i)}, newNamespaceHandlers()), "/", nil)
+
+		should.Equal(quarter(conn), quarter(conn), "choice is stable")
+		if ten(conn) {
+			should.True(quarter(conn), "connections picked by smaller percent stay picked")
+		}
+		if quarter(conn) {
+			picked++
+		}
+
+		should.False(Percent(0)(conn))
+		should.True(Percent(100)(conn))
+	}
+
+	should.InDelta(500, picked, 100)
+}
+
+func TestServerHandlerVariant(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	connects := make(chan string, 2)
+	disconnects := make(chan string, 2)
+	_, url := newConformanceServer(t, func(server *Server) {
+		server.OnEvent("/chat", "version", func(Conn) string {
+			return "v1"
+		})
+		server.OnDisconnect("/chat", func(c Conn, _ string) {
+			disconnects <- "primary " + ConnVariant(c)
+		})
+
+		canary, err := server.HandlerVariant("/chat", "canary", func(c Conn) bool {
+			u := c.URL()
+			return u.Query().Get("canary") == "1"
+		})
+		must.NoError(err)
+		should.Equal("canary", canary.Name())
+		canary.OnConnect(func(c Conn) error {
+			connects <- ConnVariant(c)
+			return nil
+		})
+		canary.OnEvent("echo", func(_ Conn, msg string) string {
+			return "canary " + msg
+		})
+		canary.OnDisconnect(func(c Conn, _ string) {
+			disconnects <- "canary " + ConnVariant(c)
+		})
+	})
+
+	ask := func(c *protocolClient, event string, args ...interface{}) interface{} {
+		c.send(t, parser.Header{Type: parser.Event, Namespace: "/chat", ID: 1, NeedAck: true}, append([]interface{}{event}, args...)...)
+
+		header, _, ret := c.receive(t, reflect.TypeOf(""))
+		must.Equal(parser.Ack, header.Type)
+		must.Len(ret, 1)
+
+		return ret[0]
+	}
+
+	for _, canary := range []bool{false, true} {
+		query := ""
+		if canary {
+			query = "?canary=1"
+		}
+
+		c := newProtocolClient(t, url+query)
+		_, _, _ = c.receive(t)
+
+		c.send(t, parser.Header{Type: parser.Connect, Namespace: "/chat"})
+		header, _, _ := c.receive(t)
+		must.Equal(parser.Connect, header.Type)
+
+		if canary {
+			should.Equal("canary", <-connects)
+			should.Equal("canary hi", ask(c, "echo", "hi"))
+		} else {
+			should.Empty(connects, "connect handler of namespace serves connection")
+			should.Equal("/chat hi", ask(c, "echo", "hi"))
+		}
+		should.Equal("v1", ask(c, "version"), "handler of namespace serves events which variant doesn't handle")
+
+		c.send(t, parser.Header{Type: parser.Disconnect, Namespace: "/chat"})
+		if canary {
+			should.Equal("canary canary", <-disconnects)
+		} else {
+			should.Equal("primary ", <-disconnects)
+		}
+
+		_ = c.conn.Close()
+	}
+}
@@ -0,0 +1,210 @@
+package socketio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// jsClientDir holds script which drives the official socket.io JS clients 2.x and 4.x, its
+// dependencies are installed by make interop.
+const jsClientDir = "testdata/jsclient"
+
+// jsClients are versions of the JS client with their packages, 2.x speaks engine.io protocol 3
+// and 4.x speaks engine.io protocol 4.
+var jsClients = []struct {
+	version string
+	pkg     string
+}{
+	{"v2", "socket.io-client"},
+	{"v4", "socket.io-client-v4"},
+}
+
+// jsClientNode gives path of node which runs the JS client of pkg, test is skipped when node or
+// the client isn't installed.
+func jsClientNode(t *testing.T, pkg string) string {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node isn't installed")
+	}
+
+	if _, err := os.Stat(filepath.Join(jsClientDir, "node_modules", pkg)); err != nil {
+		t.Skip(pkg + " isn't installed, run make interop")
+	}
+
+	return node
+}
+
+// runJSClient runs scenario of the JS client of version against server at url over transport,
+// and decodes its result into v.
+func runJSClient(t *testing.T, node, version, url, transport, scenario string, v interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, node, "client.js", url, transport, scenario, version)
+	cmd.Dir = jsClientDir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	require.NoError(t, err, stderr.String())
+	require.NoError(t, json.Unmarshal(out, v), string(out))
+}
+
+// newJSClientServer gives url of server with handlers of scenarios of the JS client, served over
+// every transport, with socket.io v5 edge cases handled.
+func newJSClientServer(t *testing.T) string {
+	server := NewServer(nil)
+	require.NoError(t, server.SetProtocolEdgeCases(V5EdgeCases()))
+
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnEvent("/", "echo", func(_ Conn, msg string) string {
+		return msg
+	})
+	server.OnEvent("/", "binary", func(_ Conn, b *parser.Buffer) int {
+		return len(b.Data)
+	})
+	server.OnEvent("/", "reverse", func(_ Conn, b *parser.Buffer) *parser.Buffer {
+		reversed := make([]byte, len(b.Data))
+		for i, c := range b.Data {
+			reversed[len(b.Data)-1-i] = c
+		}
+		return &parser.Buffer{Data: reversed}
+	})
+	server.OnEvent("/", "ask", func(c Conn) {
+		// ack is read by the same goroutine, so it's awaited by another one
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			reply, err := c.EmitWithAck(ctx, "question", "ready?")
+			if err != nil || len(reply) == 0 {
+				c.Emit("answered", "")
+				return
+			}
+			c.Emit("answered", reply[0])
+		}()
+	})
+	server.OnEvent("/", "askBytes", func(c Conn) {
+		c.Emit("bytes", func(b *parser.Buffer) {
+			c.Emit("gotBytes", len(b.Data))
+		})
+	})
+	server.OnEvent("/", "drop", func(c Conn) {
+		go c.Close()
+	})
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	server.OnEvent("/chat", "echo", func(s Conn, msg string) string {
+		return s.Namespace() + " " + msg
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+
+	httpSvr := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpSvr.Close()
+		_ = server.Close()
+	})
+
+	return httpSvr.URL
+}
+
+func TestJSClient(t *testing.T) {
+	for _, client := range jsClients {
+		client := client
+
+		t.Run(client.version, func(t *testing.T) {
+			node := jsClientNode(t, client.pkg)
+
+			for _, transport := range []string{"polling", "websocket"} {
+				transport := transport
+
+				t.Run(transport, func(t *testing.T) {
+					testJSClient(t, node, client.version, transport)
+				})
+			}
+		})
+	}
+}
+
+// testJSClient runs every scenario of the JS client of version over transport.
+func testJSClient(t *testing.T, node, version, transport string) {
+	url := newJSClientServer(t)
+
+	t.Run("connect", func(t *testing.T) {
+		var res struct {
+			Root, Chat bool
+			Transport  string
+		}
+		runJSClient(t, node, version, url, transport, "connect", &res)
+
+		should := assert.New(t)
+		should.True(res.Root)
+		should.True(res.Chat)
+		should.Equal(transport, res.Transport)
+	})
+
+	t.Run("ack", func(t *testing.T) {
+		var res struct {
+			Root, Chat, Answer string
+		}
+		runJSClient(t, node, version, url, transport, "ack", &res)
+
+		should := assert.New(t)
+		should.Equal("hi", res.Root)
+		should.Equal("/chat hi", res.Chat)
+		should.Equal("yes ready?", res.Answer)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		var res struct {
+			Size     int
+			Reversed []int
+		}
+		runJSClient(t, node, version, url, transport, "binary", &res)
+
+		should := assert.New(t)
+		should.Equal(3, res.Size)
+		should.Equal([]int{3, 2, 1}, res.Reversed)
+	})
+
+	t.Run("binaryAck", func(t *testing.T) {
+		var res struct {
+			Size int
+		}
+		runJSClient(t, node, version, url, transport, "binaryAck", &res)
+
+		assert.Equal(t, 3, res.Size)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		var res struct {
+			Attempts int
+			Changed  bool
+			Reply    string
+		}
+		runJSClient(t, node, version, url, transport, "reconnect", &res)
+
+		should := assert.New(t)
+		should.GreaterOrEqual(res.Attempts, 1)
+		should.True(res.Changed, "client gets new session")
+		should.Equal("back", res.Reply)
+	})
+}
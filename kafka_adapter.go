@@ -0,0 +1,86 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+)
+
+const defaultKafkaTopic = "socket.io"
+
+// KafkaMessage is a record of kafka topic, Key selects partition of the record.
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// KafkaProducer writes records to kafka topic. Records of the same key must be written to
+// the same partition, in order, e.g. with hash partitioner of the kafka client.
+type KafkaProducer interface {
+	Produce(topic string, msg KafkaMessage) error
+}
+
+// KafkaConsumer reads records of all the partitions of kafka topic, so every node receives
+// every broadcast, e.g. with unique consumer group of each node. Consume calls handle in order of
+// records of each partition and blocks until ctx is done, it's called again with backoff once it
+// fails.
+type KafkaConsumer interface {
+	Consume(ctx context.Context, topic string, handle func(KafkaMessage)) error
+}
+
+// KafkaAdapterOptions is configuration of kafka broadcast adapter.
+type KafkaAdapterOptions struct {
+	// Topic carries broadcasts of all the namespaces, default is "socket.io".
+	Topic string
+
+	Producer KafkaProducer
+	Consumer KafkaConsumer
+}
+
+func (o *KafkaAdapterOptions) getTopic() string {
+	if o.Topic == "" {
+		return defaultKafkaTopic
+	}
+
+	return o.Topic
+}
+
+// KafkaAdapter sets kafka broadcast adapter, used instead of adapter set before by namespaces
+// created afterwards, see RebindAdapter. Broadcasts are keyed by namespace and room, so broadcasts
+// to a room keep their order across the cluster. Rooms are tracked locally, Len and AllRooms give
+// only connections of this node.
+func (s *Server) KafkaAdapter(opts *KafkaAdapterOptions) error {
+	if opts == nil || opts.Producer == nil || opts.Consumer == nil {
+		return errors.New("kafka adapter needs producer and consumer")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	bus := newBusAdapter(s.nodeID, func(record *busRecord, value []byte) error {
+		return opts.Producer.Produce(opts.getTopic(), KafkaMessage{Key: kafkaKey(record), Value: value})
+	})
+	s.OnServerShutdownBegin(cancel)
+
+	s.setBus(bus)
+
+	go consumeKafka(ctx, opts, bus)
+
+	return nil
+}
+
+func consumeKafka(ctx context.Context, opts *KafkaAdapterOptions, bus *busAdapter) {
+	bus.consume(ctx, "kafka consume", func(ctx context.Context) error {
+		return opts.Consumer.Consume(ctx, opts.getTopic(), func(msg KafkaMessage) {
+			bus.handle(msg.Value)
+		})
+	})
+}
+
+// kafkaKey gives key of record, broadcasts to room sets and namespace have no single room,
+// they're ordered by namespace.
+func kafkaKey(record *busRecord) []byte {
+	if record.RoomSet != nil {
+		return []byte(record.Namespace + "#")
+	}
+
+	return []byte(record.Namespace + "#" + record.Room)
+}
@@ -0,0 +1,124 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryKafka is a topic delivering every record to all the consumers, keys of records are kept
+// to check partitioning.
+type memoryKafka struct {
+	mu        sync.Mutex
+	keys      []string
+	consumers []func(KafkaMessage)
+}
+
+func (k *memoryKafka) Produce(_ string, msg KafkaMessage) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = append(k.keys, string(msg.Key))
+	for _, handle := range k.consumers {
+		handle(msg)
+	}
+
+	return nil
+}
+
+func (k *memoryKafka) Consume(ctx context.Context, _ string, handle func(KafkaMessage)) error {
+	k.mu.Lock()
+	k.consumers = append(k.consumers, handle)
+	k.mu.Unlock()
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func (k *memoryKafka) consumerCount() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return len(k.consumers)
+}
+
+func (k *memoryKafka) producedKeys() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return append([]string(nil), k.keys...)
+}
+
+func TestKafkaAdapter(t *testing.T) {
+	should := assert.New(t)
+
+	bus := &memoryKafka{}
+	servers, recorders := newAdapterServers(t, "/", func(_ int, server *Server) error {
+		return server.KafkaAdapter(&KafkaAdapterOptions{Producer: bus, Consumer: bus})
+	}, func() bool {
+		return bus.consumerCount() == 2
+	})
+
+	should.True(servers[0].BroadcastToRoom("/", "lobby", "first"))
+	should.True(servers[0].BroadcastToRoomSet("/", Union("lobby"), "second"))
+	should.True(servers[1].BroadcastToNamespace("/", "third"))
+	should.True(servers[0].ClearRoom("/", "lobby"))
+	should.True(servers[1].BroadcastToRoom("/", "lobby", "fourth"))
+
+	// every node gets each broadcast once, clear of room applies to all the nodes
+	should.Equal([]string{"first", "second", "third"}, recorders[0].received())
+	should.Equal([]string{"first", "second", "third"}, recorders[1].received())
+	should.Equal([]string{"#lobby", "#", "#", "#lobby", "#lobby"}, bus.producedKeys())
+}
+
+func TestKafkaAdapterOptions(t *testing.T) {
+	should := assert.New(t)
+
+	assertAdapterOptions(t, func(server *Server) error {
+		return server.KafkaAdapter(nil)
+	}, func(server *Server) error {
+		return server.KafkaAdapter(&KafkaAdapterOptions{Producer: &memoryKafka{}})
+	})
+
+	should.Equal("socket.io", (&KafkaAdapterOptions{}).getTopic())
+	should.Equal("events", (&KafkaAdapterOptions{Topic: "events"}).getTopic())
+}
+
+// failingKafka fails first consume of each node, like consumer whose broker is unreachable.
+type failingKafka struct {
+	*memoryKafka
+
+	mu    sync.Mutex
+	fails int
+}
+
+func (k *failingKafka) Consume(ctx context.Context, topic string, handle func(KafkaMessage)) error {
+	k.mu.Lock()
+	k.fails++
+	fail := k.fails <= 2
+	k.mu.Unlock()
+
+	if fail {
+		return errors.New("broker unreachable")
+	}
+
+	return k.memoryKafka.Consume(ctx, topic, handle)
+}
+
+func TestKafkaAdapterConsumeError(t *testing.T) {
+	should := assert.New(t)
+
+	bus := &failingKafka{memoryKafka: &memoryKafka{}}
+	servers, recorders := newAdapterServers(t, "/", func(_ int, server *Server) error {
+		return server.KafkaAdapter(&KafkaAdapterOptions{Producer: bus, Consumer: bus})
+	}, func() bool {
+		return bus.consumerCount() == 2
+	})
+
+	should.True(servers[0].BroadcastToRoom("/", "lobby", "first"))
+	should.Equal([]string{"first"}, recorders[1].received())
+}
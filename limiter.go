@@ -0,0 +1,265 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/time/rate"
+
+	"github.com/thisismz/go-socket.io/logger"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrRateLimited is reported when action exceeds rate of its Limiter, e.g. event of connection
+// limited by Server.LimitEvents or broadcast to room limited by Server.LimitRoomBroadcasts.
+var ErrRateLimited = errors.New("rate limited")
+
+// limiterSweepInterval is how often idle keys are dropped by limiter of NewRateLimiter.
+const limiterSweepInterval = time.Minute
+
+// Limiter decides whether action of key is allowed, e.g. handshake of client address, event of
+// connection or broadcast to room. It's used by all rate limits of server. NewRateLimiter gives
+// limiter of this server, NewRedisLimiter gives limiter shared by the cluster.
+type Limiter interface {
+	Allow(key string) bool
+}
+
+// LimiterFunc is an adapter to allow the use of ordinary function as Limiter.
+type LimiterFunc func(key string) bool
+
+// Allow calls f(key).
+func (f LimiterFunc) Allow(key string) bool {
+	return f(key)
+}
+
+// rateLimiter keeps token bucket of each key in memory.
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+	swept   time.Time
+}
+
+// NewRateLimiter returns Limiter which allows each key limit actions per second with bursts of
+// at most burst actions, by token bucket of golang.org/x/time/rate. Keys which are idle until
+// their bucket is full are dropped, so limiter doesn't grow with keys seen once.
+func NewRateLimiter(limit rate.Limit, burst int) Limiter {
+	return &rateLimiter{
+		limit:   limit,
+		burst:   burst,
+		buckets: make(map[string]*rate.Limiter),
+		swept:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) >= limiterSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = rate.NewLimiter(l.limit, l.burst)
+		l.buckets[key] = bucket
+	}
+
+	return bucket.AllowN(now, 1)
+}
+
+// sweep drops full buckets, they're the same as new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	l.swept = now
+
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// allowTokenScript takes token of bucket, which is refilled by time of redis, so clocks of nodes
+// don't matter. Bucket of zero limit isn't refilled, like in x/time/rate. It returns 1 when token is taken.
+var allowTokenScript = redis.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+if limit > 0 then
+	tokens = math.min(burst, tokens + math.max(0, now - ts) * limit / 1000)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+if limit > 0 then
+	redis.call("PEXPIRE", KEYS[1], math.ceil(burst / limit * 1000) + 1000)
+end
+return allowed
+`)
+
+// redisLimiter keeps token buckets in redis, so they're shared by the cluster.
+type redisLimiter struct {
+	opts  *RedisAdapterOptions
+	pool  *redis.Pool
+	name  string
+	limit rate.Limit
+	burst int
+}
+
+// NewRedisLimiter returns Limiter like NewRateLimiter, which buckets are kept in redis, so
+// the limit is fair across the cluster, e.g. client address gets limit handshakes per second
+// whichever node it hits. name separates buckets of limiters in the same redis. Action is
+// allowed when redis fails, so outage of redis doesn't take the server down. Limiter keeps pool of
+// connections, it implements io.Closer to release them.
+func NewRedisLimiter(opts *RedisAdapterOptions, name string, limit rate.Limit, burst int) Limiter {
+	opts = getOptions(opts)
+
+	return &redisLimiter{
+		opts:  opts,
+		pool:  opts.newPool(),
+		name:  name,
+		limit: limit,
+		burst: burst,
+	}
+}
+
+func (l *redisLimiter) Allow(key string) bool {
+	if l.limit == rate.Inf {
+		return true
+	}
+
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	allowed, err := redis.Int(allowTokenScript.Do(conn, l.bucketKey(key), float64(l.limit), l.burst))
+	if err != nil {
+		logger.Error("take token of limiter:", err)
+		return true
+	}
+
+	return allowed == 1
+}
+
+// Close closes connections of limiter.
+func (l *redisLimiter) Close() error {
+	return l.pool.Close()
+}
+
+func (l *redisLimiter) bucketKey(key string) string {
+	return fmt.Sprintf("%s-limit#%s#%s", l.opts.Prefix, l.name, key)
+}
+
+// LimitHandshakes rejects handshakes of new connections with 429 Too Many Requests when l
+// doesn't allow address of client, which is key of l.
+func (s *Server) LimitHandshakes(l Limiter) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.engine.LimitHandshakes(func(r *http.Request) bool {
+		return l.Allow(clientAddr(r))
+	})
+
+	return nil
+}
+
+// clientAddr gives address of client of request without port.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// LimitEvents limits events received by connections of namespace, id of connection is key of l.
+// Event which isn't allowed isn't handled, its ack gets error like
+// {"error": "rate limited", "event": "message"}.
+func (s *Server) LimitEvents(namespace string, l Limiter) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.eventLimiter = l
+
+	return nil
+}
+
+// LimitRoomBroadcasts limits broadcasts to rooms of namespace by BroadcastToRoom and EmitToRoom,
+// name of room is key of l. EmitToRoom returns ErrRateLimited for broadcast which isn't allowed.
+func (s *Server) LimitRoomBroadcasts(namespace string, l Limiter) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.roomLimiter = l
+
+	return nil
+}
+
+func (nh *namespaceHandler) allowEvent(conn Conn) bool {
+	return nh.eventLimiter == nil || nh.eventLimiter.Allow(conn.ID())
+}
+
+func (nh *namespaceHandler) allowRoomBroadcast(room string) error {
+	if nh.roomLimiter == nil || nh.roomLimiter.Allow(room) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: broadcast to room %q", ErrRateLimited, room)
+}
+
+// rejectRateLimited answers event which isn't allowed by limiter of namespace with error ack.
+func rejectRateLimited(c *conn, conn *namespaceConn, event string, header parser.Header, size int) error {
+	conn.Logger().Info("Event is rate limited", "event", event)
+
+	if c.observeEvent != nil {
+		c.observeEvent(conn, EventMetrics{
+			Namespace:   conn.Namespace(),
+			Event:       event,
+			PayloadSize: size,
+			Err:         fmt.Errorf("%w: event %q", ErrRateLimited, event),
+		})
+	}
+
+	if header.NeedAck {
+		header.Type = parser.Ack
+		c.write(header, reflect.ValueOf(map[string]interface{}{
+			"error": ErrRateLimited.Error(),
+			"event": event,
+		}))
+	}
+
+	return nil
+}
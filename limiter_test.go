@@ -0,0 +1,132 @@
+package socketio
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestRateLimiter(t *testing.T) {
+	should := assert.New(t)
+
+	l := NewRateLimiter(rate.Every(time.Hour), 2)
+
+	should.True(l.Allow("a"))
+	should.True(l.Allow("a"))
+	should.False(l.Allow("a"))
+	should.True(l.Allow("b"), "keys have their own buckets")
+
+	rl := l.(*rateLimiter)
+	rl.mu.Lock()
+	rl.sweep(time.Now().Add(2 * time.Hour))
+	should.Len(rl.buckets, 0, "full buckets are dropped")
+	rl.mu.Unlock()
+
+	should.True(NewRateLimiter(rate.Inf, 0).Allow("a"))
+}
+
+func TestRedisLimiter(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var lock sync.Mutex
+	var keys []string
+	tokens := 2
+	addr := fakeRedis(t, func(cmd []string) string {
+		if cmd[0] != "EVALSHA" {
+			return "-ERR unexpected command\r\n"
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		keys = append(keys, cmd[3])
+		if tokens == 0 {
+			return ":0\r\n"
+		}
+		tokens--
+		return ":1\r\n"
+	})
+
+	l := NewRedisLimiter(&RedisAdapterOptions{Addr: addr, Prefix: "app"}, "events", rate.Every(time.Hour), 2)
+	should.True(l.Allow("a"))
+	should.True(l.Allow("a"))
+	should.False(l.Allow("a"))
+	should.Equal([]string{"app-limit#events#a", "app-limit#events#a", "app-limit#events#a"}, keys)
+
+	rl := l.(*redisLimiter)
+	should.Equal(1, rl.pool.Stats().ActiveCount, "connection is reused by calls")
+
+	closer, ok := l.(io.Closer)
+	must.True(ok)
+	must.NoError(closer.Close())
+	should.True(l.Allow("a"), "action is allowed when redis fails")
+}
+
+func TestLimitEvents(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t, func(server *Server) {
+		must.NoError(server.LimitEvents("/", NewRateLimiter(rate.Every(time.Hour), 1)))
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, "echo", "hello")
+	_, _, args := c.receive(t, reflect.TypeOf(""))
+	should.Equal([]interface{}{"hello"}, args)
+
+	c.send(t, parser.Header{Type: parser.Event, ID: 2, NeedAck: true}, "echo", "hello")
+	header, _, args := c.receive(t, reflect.TypeOf(map[string]interface{}{}))
+	should.Equal(parser.Ack, header.Type)
+	should.Equal(uint64(2), header.ID)
+	should.Equal([]interface{}{map[string]interface{}{
+		"error": ErrRateLimited.Error(),
+		"event": "echo",
+	}}, args)
+}
+
+func TestLimitRoomBroadcasts(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	should.NoError(server.LimitRoomBroadcasts("/", NewRateLimiter(rate.Every(time.Hour), 1)))
+
+	should.NoError(server.EmitToRoom("/", "room", "event"))
+	should.ErrorIs(server.EmitToRoom("/", "room", "event"), ErrRateLimited)
+	should.False(server.BroadcastToRoom("/", "room", "event"))
+	should.NoError(server.EmitToRoom("/", "other", "event"))
+}
+
+func TestLimitHandshakes(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t, func(server *Server) {
+		must.NoError(server.LimitHandshakes(NewRateLimiter(rate.Every(time.Hour), 1)))
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	resp, err := http.Get(url + "?EIO=3&transport=polling")
+	must.NoError(err)
+	must.NoError(resp.Body.Close())
+	should.Equal(http.StatusTooManyRequests, resp.StatusCode)
+}
@@ -0,0 +1,38 @@
+package socketio
+
+import "time"
+
+// EventMetrics describes handling of an event by its handler, see Server.AddMetricsHook.
+type EventMetrics struct {
+	Namespace string
+	Event     string
+
+	// Duration is time spent in event handler.
+	Duration time.Duration
+	// PayloadSize is size in bytes of event packet, including its binary attachments.
+	PayloadSize int
+	// Err is error returned by handler call, e.g. when it panics.
+	Err error
+}
+
+// MetricsHook receives metrics of each handled event. Labels of user's own metrics pipeline,
+// e.g. tenant or user id, can be taken from conn, e.g. from its Context.
+type MetricsHook func(conn Conn, metrics EventMetrics)
+
+// AddMetricsHook registers hook called after each event handler of any namespace.
+func (s *Server) AddMetricsHook(hook MetricsHook) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.metricsHooks = append(s.metricsHooks, hook)
+}
+
+func (s *Server) observeEvent(conn Conn, metrics EventMetrics) {
+	s.hooksLock.RLock()
+	hooks := s.metricsHooks
+	s.hooksLock.RUnlock()
+
+	for _, hook := range hooks {
+		hook(conn, metrics)
+	}
+}
@@ -0,0 +1,58 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+func TestServerMetricsHook(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t)
+	server.OnEvent("/", "fail", func(Conn) {
+		panic("fail")
+	})
+
+	observed := make(chan EventMetrics, 2)
+	server.AddMetricsHook(func(conn Conn, metrics EventMetrics) {
+		should.NotNil(conn)
+		observed <- metrics
+	})
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	client.Emit("echo", "hello")
+
+	select {
+	case metrics := <-observed:
+		should.Equal("/", metrics.Namespace)
+		should.Equal("echo", metrics.Event)
+		should.GreaterOrEqual(metrics.PayloadSize, len(`2["echo","hello"]`))
+		should.NoError(metrics.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing metrics of echo")
+	}
+
+	client.Emit("fail")
+
+	select {
+	case metrics := <-observed:
+		should.Equal("fail", metrics.Event)
+		should.Error(metrics.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing metrics of fail")
+	}
+}
@@ -0,0 +1,90 @@
+package socketio
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// Middleware runs on connect to namespace before its OnConnect handler, see Server.Use. It calls
+// next with nil to continue, or with error to refuse connect, e.g. once auth is checked. next
+// may be called later by another goroutine, reads of connection wait for it meanwhile.
+type Middleware func(conn Conn, auth map[string]interface{}, next func(error))
+
+// Use adds middleware of namespace, middlewares run in order they're added. Auth is payload of
+// CONNECT packet of client. It's nil for root namespace of clients of engine.io protocol 3, which
+// is connected with handshake, so its middlewares check URL and headers of connection instead,
+// and refused connection is closed. Connect refused by middleware gets CONNECT_ERROR packet,
+// which message is message of error and data is Data of error when it's *ConnectError.
+func (s *Server) Use(namespace string, mw Middleware) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.use(mw)
+}
+
+func (nh *namespaceHandler) use(mw Middleware) {
+	nh.middlewaresLock.Lock()
+	defer nh.middlewaresLock.Unlock()
+
+	nh.middlewares = append(nh.middlewares, mw)
+}
+
+// runMiddlewares runs middlewares of namespace for conn, it gives error of the first one which
+// refuses connect.
+func (nh *namespaceHandler) runMiddlewares(conn Conn, auth map[string]interface{}, quit <-chan struct{}) error {
+	nh.middlewaresLock.RLock()
+	middlewares := nh.middlewares
+	nh.middlewaresLock.RUnlock()
+
+	for _, mw := range middlewares {
+		done := make(chan error, 1)
+		var once sync.Once
+		mw(conn, auth, func(err error) {
+			once.Do(func() {
+				done <- err
+			})
+		})
+
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+		case <-quit:
+			return ErrConnClosed
+		}
+	}
+
+	return nil
+}
+
+// middlewareRefusal gives payload of CONNECT_ERROR packet of connect refused by middleware.
+func middlewareRefusal(err error) reflect.Value {
+	payload := map[string]interface{}{"message": err.Error()}
+
+	var connectErr *ConnectError
+	if errors.As(err, &connectErr) {
+		payload["message"] = connectErr.Message
+		if connectErr.Data != nil {
+			payload["data"] = connectErr.Data
+		}
+	}
+
+	return reflect.ValueOf(payload)
+}
+
+// rejectByMiddleware refuses CONNECT of namespace whose middleware returned err.
+func rejectByMiddleware(c *conn, conn *namespaceConn, header parser.Header, err error) error {
+	conn.LeaveAll()
+	c.namespaces.Delete(header.Namespace)
+
+	header.Type = parser.Error
+	c.write(header, middlewareRefusal(err))
+
+	return nil
+}
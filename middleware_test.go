@@ -0,0 +1,117 @@
+package socketio
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestServerUse(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	calls := make(chan string, 8)
+	connects := make(chan string, 2)
+	_, url := newConformanceServer(t, func(server *Server) {
+		server.OnConnect("/admin", func(c Conn) error {
+			connects <- c.Namespace()
+			return nil
+		})
+		server.Use("/admin", func(_ Conn, _ map[string]interface{}, next func(error)) {
+			calls <- "first"
+			// next may be called later by another goroutine
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				next(nil)
+			}()
+		})
+		server.Use("/admin", func(_ Conn, auth map[string]interface{}, next func(error)) {
+			calls <- "second"
+			if auth["token"] != "secret" {
+				next(&ConnectError{Message: "unauthorized", Data: map[string]interface{}{"code": "AUTH"}})
+				return
+			}
+			next(nil)
+			next(errors.New("ignored"))
+		})
+		server.Use("/admin", func(_ Conn, _ map[string]interface{}, next func(error)) {
+			calls <- "third"
+			next(nil)
+		})
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	payloadType := reflect.TypeOf(map[string]interface{}{})
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/admin"}, map[string]interface{}{"token": "guess"})
+
+	header, _, args := c.receive(t, payloadType)
+	should.Equal(parser.Error, header.Type)
+	should.Equal("/admin", header.Namespace)
+	must.Len(args, 1)
+	should.Equal(map[string]interface{}{
+		"message": "unauthorized",
+		"data":    map[string]interface{}{"code": "AUTH"},
+	}, args[0])
+	should.Equal("first", <-calls)
+	should.Equal("second", <-calls)
+	should.Empty(calls, "middlewares after refusal don't run")
+	should.Empty(connects)
+
+	// refused namespace isn't connected, so its events are ignored
+	c.send(t, parser.Header{Type: parser.Event, Namespace: "/admin", ID: 1, NeedAck: true}, "echo", "hi")
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/admin"}, map[string]interface{}{"token": "secret"})
+
+	header, _, _ = c.receive(t)
+	should.Equal(parser.Connect, header.Type)
+	should.Equal("/admin", header.Namespace)
+	should.Equal("/admin", <-connects)
+	should.Equal("first", <-calls)
+	should.Equal("second", <-calls)
+	should.Equal("third", <-calls)
+}
+
+func TestServerUseRootNamespace(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, url := newConformanceServer(t, func(server *Server) {
+		server.Use("/", func(c Conn, auth map[string]interface{}, next func(error)) {
+			u := c.URL()
+			if auth != nil || u.Query().Get("token") != "secret" {
+				next(errors.New("unauthorized"))
+				return
+			}
+			next(nil)
+		})
+	})
+
+	c := newProtocolClient(t, url+"?token=secret")
+	header, _, _ := c.receive(t)
+	should.Equal(parser.Connect, header.Type)
+	_ = c.conn.Close()
+
+	c = newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	header, _, args := c.receive(t, reflect.TypeOf(map[string]interface{}{}))
+	should.Equal(parser.Error, header.Type)
+	must.Len(args, 1)
+	should.Equal(map[string]interface{}{"message": "unauthorized"}, args[0])
+
+	// refused connection is closed
+	var h parser.Header
+	var event string
+	should.Error(c.decoder.DecodeHeader(&h, &event))
+}
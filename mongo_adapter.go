@@ -0,0 +1,89 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const defaultMongoInsertTimeout = 5 * time.Second
+
+// MongoDocument is document of capped collection carrying a broadcast, Data is the encoded
+// broadcast.
+type MongoDocument struct {
+	Namespace string    `bson:"nsp"`
+	Node      string    `bson:"uid"`
+	Data      []byte    `bson:"data"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// MongoCollection is capped collection carrying broadcasts between nodes, e.g. wrapping
+// collection of mongodb driver created with capped option, so old broadcasts are removed
+// and documents keep insertion order.
+type MongoCollection interface {
+	InsertOne(ctx context.Context, doc MongoDocument) error
+
+	// Watch follows change stream of inserts into collection and calls handle with every inserted
+	// document, in insertion order, until ctx is done. It's expected to resume stream with last
+	// resume token when it's interrupted, it's called again with backoff once it fails.
+	Watch(ctx context.Context, handle func(MongoDocument)) error
+}
+
+// MongoAdapterOptions is configuration of mongodb broadcast adapter.
+type MongoAdapterOptions struct {
+	Collection MongoCollection
+
+	// InsertTimeout bounds insert of each broadcast, default is 5 seconds.
+	InsertTimeout time.Duration
+}
+
+func (o *MongoAdapterOptions) getInsertTimeout() time.Duration {
+	if o.InsertTimeout <= 0 {
+		return defaultMongoInsertTimeout
+	}
+
+	return o.InsertTimeout
+}
+
+// MongoAdapter sets mongodb broadcast adapter, for deployments without redis, used instead of
+// adapter set before by namespaces created afterwards, see RebindAdapter. Broadcasts are inserted
+// into capped collection and delivered to other nodes through its change stream. Rooms are tracked
+// locally, Len and AllRooms give only connections of this node.
+func (s *Server) MongoAdapter(opts *MongoAdapterOptions) error {
+	if opts == nil || opts.Collection == nil {
+		return errors.New("mongo adapter needs collection")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	bus := newBusAdapter(s.nodeID, func(record *busRecord, value []byte) error {
+		insertCtx, cancel := context.WithTimeout(ctx, opts.getInsertTimeout())
+		defer cancel()
+
+		return opts.Collection.InsertOne(insertCtx, MongoDocument{
+			Namespace: record.Namespace,
+			Node:      record.Node,
+			Data:      value,
+			CreatedAt: time.Now(),
+		})
+	})
+	s.OnServerShutdownBegin(cancel)
+
+	s.setBus(bus)
+
+	go watchMongo(ctx, opts, bus)
+
+	return nil
+}
+
+func watchMongo(ctx context.Context, opts *MongoAdapterOptions, bus *busAdapter) {
+	bus.consume(ctx, "mongo watch", func(ctx context.Context) error {
+		return opts.Collection.Watch(ctx, func(doc MongoDocument) {
+			if doc.Node == bus.node {
+				return
+			}
+
+			bus.handle(doc.Data)
+		})
+	})
+}
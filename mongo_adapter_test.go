@@ -0,0 +1,82 @@
+package socketio
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryMongo is a capped collection delivering every inserted document to all the watchers.
+type memoryMongo struct {
+	mu       sync.Mutex
+	docs     []MongoDocument
+	watchers []func(MongoDocument)
+}
+
+func (m *memoryMongo) InsertOne(_ context.Context, doc MongoDocument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs = append(m.docs, doc)
+	for _, handle := range m.watchers {
+		handle(doc)
+	}
+
+	return nil
+}
+
+func (m *memoryMongo) Watch(ctx context.Context, handle func(MongoDocument)) error {
+	m.mu.Lock()
+	m.watchers = append(m.watchers, handle)
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func (m *memoryMongo) watcherCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.watchers)
+}
+
+func TestMongoAdapter(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	collection := &memoryMongo{}
+	servers, recorders := newAdapterServers(t, "/chat", func(_ int, server *Server) error {
+		return server.MongoAdapter(&MongoAdapterOptions{Collection: collection})
+	}, func() bool {
+		return collection.watcherCount() == 2
+	})
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "first"))
+	should.True(servers[1].BroadcastToNamespace("/chat", "second"))
+	should.True(servers[1].ClearRoom("/chat", "lobby"))
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "third"))
+
+	should.Equal([]string{"first", "second"}, recorders[0].received())
+	should.Equal([]string{"first", "second"}, recorders[1].received())
+
+	must.Len(collection.docs, 4)
+	should.Equal("/chat", collection.docs[0].Namespace)
+	should.Equal(servers[0].NodeID(), collection.docs[0].Node)
+}
+
+func TestMongoAdapterOptions(t *testing.T) {
+	should := assert.New(t)
+
+	assertAdapterOptions(t, func(server *Server) error {
+		return server.MongoAdapter(nil)
+	}, func(server *Server) error {
+		return server.MongoAdapter(&MongoAdapterOptions{})
+	})
+
+	should.Equal(defaultMongoInsertTimeout, (&MongoAdapterOptions{}).getInsertTimeout())
+}
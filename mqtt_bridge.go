@@ -0,0 +1,227 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultMQTTTopicPrefix = "socket.io"
+	defaultMQTTEvent       = "message"
+
+	// mqttRootNamespace names root namespace in topics.
+	mqttRootNamespace = "root"
+)
+
+// MQTTClient publishes to and subscribes to topics of MQTT broker, e.g. wrapping paho client.
+// Publish must not wait for broker, it's called while broadcasting.
+type MQTTClient interface {
+	Publish(topic string, qos byte, payload []byte) error
+	Subscribe(topic string, qos byte, handle func(topic string, payload []byte)) error
+	Unsubscribe(topics ...string) error
+}
+
+// MQTTMessage is payload of MQTT messages published by bridge. Messages of devices which aren't
+// such JSON object are broadcast as DefaultEvent with payload as its only string argument.
+type MQTTMessage struct {
+	Event string        `json:"event"`
+	Args  []interface{} `json:"args"`
+	// Origin is id of node which published message, it's empty for messages of devices.
+	Origin string `json:"origin,omitempty"`
+}
+
+// MQTTBridgeOptions configures MQTTBridge, see Server.NewMQTTBridge.
+type MQTTBridgeOptions struct {
+	Client MQTTClient
+
+	// Namespaces are bridged namespaces.
+	Namespaces []string
+
+	// TopicPrefix of topics of rooms, default is "socket.io". Room of namespace is mapped to
+	// topic "<prefix>/<namespace>/<room>", where namespace has no leading slash and root
+	// namespace is named "root", e.g. room "sensors" of "/iot" is "socket.io/iot/sensors".
+	TopicPrefix string
+
+	// QoS of published messages and subscriptions.
+	QoS byte
+
+	// DefaultEvent is event of messages of devices which don't name it, default is "message".
+	DefaultEvent string
+}
+
+func (o *MQTTBridgeOptions) getTopicPrefix() string {
+	if o.TopicPrefix == "" {
+		return defaultMQTTTopicPrefix
+	}
+
+	return strings.TrimSuffix(o.TopicPrefix, "/")
+}
+
+func (o *MQTTBridgeOptions) getDefaultEvent() string {
+	if o.DefaultEvent == "" {
+		return defaultMQTTEvent
+	}
+
+	return o.DefaultEvent
+}
+
+// MQTTBridge bridges broadcasts to rooms of namespaces with topics of MQTT broker, so devices
+// speaking MQTT receive socket.io broadcasts and vice versa.
+type MQTTBridge struct {
+	server *Server
+	opts   *MQTTBridgeOptions
+	prefix string
+
+	// namespaces are bridged namespaces by their names in topics.
+	namespaces map[string]string
+	topics     []string
+}
+
+// NewMQTTBridge starts bridge of rooms of namespaces with MQTT topics. Broadcasts of this server
+// to rooms are published to topics of rooms, broadcasts to room sets and to namespace aren't
+// bridged. Messages of topics are delivered to connections of room on each node running bridge,
+// they aren't published through adapter. Messages of MQTT clients are recorded like broadcasts of
+// this server, so each node running bridge appends them to its sink, messages of other nodes are
+// recorded by those nodes.
+func (s *Server) NewMQTTBridge(opts *MQTTBridgeOptions) (*MQTTBridge, error) {
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	if opts == nil || opts.Client == nil {
+		return nil, errors.New("mqtt bridge needs client")
+	}
+
+	b := &MQTTBridge{
+		server:     s,
+		opts:       opts,
+		prefix:     opts.getTopicPrefix(),
+		namespaces: make(map[string]string, len(opts.Namespaces)),
+	}
+
+	for _, nsp := range opts.Namespaces {
+		if nsp == aliasRootNamespace {
+			nsp = rootNamespace
+		}
+
+		topic := b.prefix + "/" + mqttNamespace(nsp) + "/#"
+		if err := opts.Client.Subscribe(topic, opts.QoS, b.receive); err != nil {
+			_ = b.Close()
+			return nil, err
+		}
+
+		b.namespaces[mqttNamespace(nsp)] = nsp
+		b.topics = append(b.topics, topic)
+	}
+
+	s.observers = append(s.observers, b.observe)
+
+	return b, nil
+}
+
+// Close unsubscribes from topics of bridged namespaces.
+func (b *MQTTBridge) Close() error {
+	if len(b.topics) == 0 {
+		return nil
+	}
+
+	return b.opts.Client.Unsubscribe(b.topics...)
+}
+
+func mqttNamespace(nsp string) string {
+	if nsp == rootNamespace {
+		return mqttRootNamespace
+	}
+
+	return strings.TrimPrefix(nsp, aliasRootNamespace)
+}
+
+// topic gives topic of room of namespace, it reports false when namespace isn't bridged.
+func (b *MQTTBridge) topic(nsp, room string) (string, bool) {
+	if nsp == aliasRootNamespace {
+		nsp = rootNamespace
+	}
+
+	name := mqttNamespace(nsp)
+	if _, ok := b.namespaces[name]; !ok {
+		return "", false
+	}
+
+	return b.prefix + "/" + name + "/" + room, true
+}
+
+// observe publishes broadcast of this server to topic of its room.
+func (b *MQTTBridge) observe(record BroadcastRecord) {
+	if record.Room == "" || record.RoomSet != nil || record.bridged {
+		return
+	}
+
+	topic, ok := b.topic(record.Namespace, record.Room)
+	if !ok {
+		return
+	}
+
+	payload, err := json.Marshal(MQTTMessage{Event: record.Event, Args: record.Args, Origin: b.server.nodeID})
+	if err != nil {
+		logger.Error("mqtt bridge encode:", err)
+		return
+	}
+
+	if err = b.opts.Client.Publish(topic, b.opts.QoS, payload); err != nil {
+		logger.Error("mqtt bridge publish:", err)
+	}
+}
+
+// receive delivers message of topic to connections of its room on this node.
+func (b *MQTTBridge) receive(topic string, payload []byte) {
+	name, room, ok := strings.Cut(strings.TrimPrefix(topic, b.prefix+"/"), "/")
+	if !ok || room == "" {
+		return
+	}
+
+	nsp, ok := b.namespaces[name]
+	if !ok {
+		return
+	}
+
+	var msg MQTTMessage
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Event == "" {
+		msg = MQTTMessage{Event: b.opts.getDefaultEvent(), Args: []interface{}{string(payload)}}
+	}
+
+	// broadcasts of this node are delivered already
+	if msg.Origin == b.server.nodeID {
+		return
+	}
+
+	h := b.server.getNamespace(nsp)
+	if h == nil {
+		return
+	}
+
+	h.getBroadcast().ForEach(room, func(connection Conn) {
+		broadcastEmit(connection, nil, msg.Event, msg.Args...)
+	})
+
+	record := BroadcastRecord{
+		Namespace: nsp,
+		Room:      room,
+		Event:     msg.Event,
+		Args:      msg.Args,
+		Timestamp: time.Now(),
+		Origin:    msg.Origin,
+		bridged:   true,
+	}
+
+	// broadcast of other node is appended to sink by that node
+	if msg.Origin != "" {
+		b.server.observeRemoteBroadcast(record)
+		return
+	}
+
+	b.server.record(record)
+}
@@ -0,0 +1,97 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// memoryMQTT delivers published messages to subscriptions with matching "#" filter.
+type memoryMQTT struct {
+	mu            sync.Mutex
+	published     []string
+	subscriptions map[string]func(topic string, payload []byte)
+}
+
+func (m *memoryMQTT) Publish(topic string, _ byte, payload []byte) error {
+	m.mu.Lock()
+	m.published = append(m.published, topic)
+	var handlers []func(string, []byte)
+	for filter, handle := range m.subscriptions {
+		if strings.HasPrefix(topic, strings.TrimSuffix(filter, "#")) {
+			handlers = append(handlers, handle)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, handle := range handlers {
+		handle(topic, payload)
+	}
+
+	return nil
+}
+
+func (m *memoryMQTT) Subscribe(topic string, _ byte, handle func(string, []byte)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscriptions[topic] = handle
+	return nil
+}
+
+func (m *memoryMQTT) Unsubscribe(topics ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(m.subscriptions, topic)
+	}
+	return nil
+}
+
+func TestMQTTBridge(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	broker := &memoryMQTT{subscriptions: make(map[string]func(string, []byte))}
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnConnect("/iot", func(Conn) error {
+		return nil
+	})
+
+	bridge, err := server.NewMQTTBridge(&MQTTBridgeOptions{Client: broker, Namespaces: []string{"/", "/iot"}})
+	must.NoError(err)
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/iot", nil)}
+	server.JoinRoom("/iot", "sensors/kitchen", recorder)
+
+	// broadcast of server is published once, its echo isn't delivered again
+	should.True(server.BroadcastToRoom("/iot", "sensors/kitchen", "configure", "celsius"))
+	should.True(server.BroadcastToRoom("/", "lobby", "hello"))
+	should.True(server.BroadcastToNamespace("/iot", "calibrate"))
+	should.Equal([]string{"socket.io/iot/sensors/kitchen", "socket.io/root/lobby"}, broker.published)
+	should.Equal([]string{"configure", "calibrate"}, recorder.events)
+
+	// messages of devices are delivered to room
+	msg, err := json.Marshal(MQTTMessage{Event: "temperature", Args: []interface{}{21.5}})
+	must.NoError(err)
+	must.NoError(broker.Publish("socket.io/iot/sensors/kitchen", 0, msg))
+	must.NoError(broker.Publish("socket.io/iot/sensors/kitchen", 0, []byte("21.7")))
+	should.Equal([]string{"configure", "calibrate", "temperature", "message"}, recorder.events)
+
+	must.NoError(bridge.Close())
+	should.Empty(broker.subscriptions)
+
+	_, err = server.NewMQTTBridge(&MQTTBridgeOptions{})
+	should.Error(err)
+}
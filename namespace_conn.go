@@ -3,7 +3,11 @@ package socketio
 import (
 	"reflect"
 	"sync"
+	"time"
 
+	"golang.org/x/exp/slog"
+
+	"github.com/thisismz/go-socket.io/logger"
 	"github.com/thisismz/go-socket.io/parser"
 )
 
@@ -11,16 +15,24 @@ import (
 // over a single shared connection.
 type Namespace interface {
 	// Context of this connection. You can save one context for one
-	// connection, and share it between all handlers. The handlers
-	// are called in one goroutine, so no need to lock context if it
-	// only accessed in one connection.
+	// connection, and share it between all handlers. Handlers of a
+	// connection may be called in several goroutines, e.g. events
+	// with keys of Server.SerializeEvent, so context is set and read
+	// atomically, but its value must be safe for concurrent use.
 	Context() interface{}
 	SetContext(ctx interface{})
 
 	Namespace() string
+	// Emit and Join of closed connection do nothing, TryEmit and TryJoin return ErrConnClosed instead.
+	// TryEmit of event which isn't declared in namespace returns ErrUndeclaredEvent.
 	Emit(eventName string, v ...interface{})
+	TryEmit(eventName string, v ...interface{}) error
+	// Compress gives emitter of events with or without per-message compression, e.g.
+	// conn.Compress(false).Emit(event, data) for data which is already compressed.
+	Compress(compress bool) Emitter
 	EmitByNameSpace(namespace, eventName string, v ...interface{})
 	Join(room string)
+	TryJoin(room string) error
 	Leave(room string)
 	LeaveAll()
 	Rooms() []string
@@ -28,27 +40,51 @@ type Namespace interface {
 
 type namespaceConn struct {
 	*conn
-	broadcast Broadcast
+	broadcast     Broadcast
+	broadcastLock sync.RWMutex
 
-	namespace string
-	context   interface{}
+	namespace   string
+	context     interface{}
+	contextLock sync.RWMutex
 
 	ack sync.Map
+	// ackSent keeps when events waiting for ack were emitted.
+	ackSent sync.Map
+	// acksSwept is time in nanoseconds when expired acks were collected, see SetAckExpiry.
+	acksSwept int64
+
+	// resumeID identifies resume session issued for connection by server.
+	resumeID string
+
+	connectedAt time.Time
+
+	// readOnly is set for observers whose events are rejected, see SetReadOnly.
+	readOnly int32
+
+	// variant serves connection instead of handlers of namespace, see Server.HandlerVariant.
+	variant *HandlerVariant
 }
 
 func newNamespaceConn(conn *conn, namespace string, broadcast Broadcast) *namespaceConn {
 	return &namespaceConn{
-		conn:      conn,
-		namespace: namespace,
-		broadcast: broadcast,
+		conn:        conn,
+		namespace:   namespace,
+		broadcast:   broadcast,
+		connectedAt: time.Now(),
 	}
 }
 
 func (nc *namespaceConn) SetContext(ctx interface{}) {
+	nc.contextLock.Lock()
+	defer nc.contextLock.Unlock()
+
 	nc.context = ctx
 }
 
 func (nc *namespaceConn) Context() interface{} {
+	nc.contextLock.RLock()
+	defer nc.contextLock.RUnlock()
+
 	return nc.context
 }
 
@@ -56,7 +92,55 @@ func (nc *namespaceConn) Namespace() string {
 	return nc.namespace
 }
 
+func (nc *namespaceConn) Closed() bool {
+	return nc.isClosing()
+}
+
+func (nc *namespaceConn) ConnectedAt() time.Time {
+	return nc.connectedAt
+}
+
+func (nc *namespaceConn) Logger() *slog.Logger {
+	return nc.bindLogger(logger.Log)
+}
+
+func (nc *namespaceConn) bindLogger(l *slog.Logger) *slog.Logger {
+	var remoteAddr string
+	if addr := nc.RemoteAddr(); addr != nil {
+		remoteAddr = addr.String()
+	}
+
+	return l.With("sid", nc.ID(), "namespace", nc.Namespace(), "remote_addr", remoteAddr)
+}
+
 func (nc *namespaceConn) Emit(eventName string, v ...interface{}) {
+	nc.emit(eventName, v...)
+}
+
+func (nc *namespaceConn) TryEmit(eventName string, v ...interface{}) error {
+	if err := nc.checkEmit(eventName); err != nil {
+		return err
+	}
+
+	nc.emit(eventName, v...)
+
+	return nil
+}
+
+// emit emits event and returns id of its ack, which is zero when event doesn't need ack.
+func (nc *namespaceConn) emit(eventName string, v ...interface{}) uint64 {
+	return nc.emitWith(writeOptions{}, eventName, v...)
+}
+
+// emitWith emits event like emit, written with opts.
+func (nc *namespaceConn) emitWith(opts writeOptions, eventName string, v ...interface{}) uint64 {
+	if err := nc.checkEmit(eventName); err != nil {
+		if err != ErrConnClosed {
+			nc.Logger().Info("Emit is dropped", "event", eventName, "err", err.Error())
+		}
+		return 0
+	}
+
 	header := parser.Header{
 		Type: parser.Event,
 	}
@@ -69,17 +153,34 @@ func (nc *namespaceConn) Emit(eventName string, v ...interface{}) {
 		last := v[l-1]
 		lastV := reflect.TypeOf(last)
 
-		if lastV.Kind() == reflect.Func {
-			f := newAckFunc(last)
+		// ack of EmitWithAck is built already
+		f, ok := last.(*funcHandler)
+		if !ok && lastV.Kind() == reflect.Func {
+			f = newAckFunc(last)
+		}
 
+		if f != nil {
 			header.ID = nc.conn.nextID()
 			header.NeedAck = true
 
-			nc.ack.Store(header.ID, f)
+			nc.storeAck(header.ID, f)
 			v = v[:l-1]
 		}
 	}
 
+	if nc.outgoing != nil {
+		v = nc.outgoing(nc.Namespace(), eventName, v)
+	}
+
+	if nc.capture.sampled(eventName) {
+		nc.capture.capture(CaptureOutbound, nc, eventName, v)
+	}
+
+	if chunks := nc.chunking.split(eventName, v); chunks != nil {
+		nc.writeChunks(header, opts, chunks)
+		return header.ID
+	}
+
 	args := make([]reflect.Value, len(v)+1)
 	args[0] = reflect.ValueOf(eventName)
 
@@ -87,51 +188,107 @@ func (nc *namespaceConn) Emit(eventName string, v ...interface{}) {
 		args[i] = reflect.ValueOf(v[i-1])
 	}
 
-	nc.conn.write(header, args...)
+	nc.conn.writeWith(header, opts, args...)
+
+	return header.ID
 }
+
+// writeChunks writes ChunkEvent events in order, ack of the event is requested by the last chunk.
+func (nc *namespaceConn) writeChunks(header parser.Header, opts writeOptions, chunks [][]interface{}) {
+	for i, chunk := range chunks {
+		chunkHeader := header
+		if i < len(chunks)-1 {
+			chunkHeader.ID = 0
+			chunkHeader.NeedAck = false
+		}
+
+		args := make([]reflect.Value, len(chunk)+1)
+		args[0] = reflect.ValueOf(ChunkEvent)
+		for j := range chunk {
+			args[j+1] = reflect.ValueOf(chunk[j])
+		}
+
+		nc.conn.writeWith(chunkHeader, opts, args...)
+	}
+}
+
+// EmitByNameSpace emits event to namespace of the same connection, like Emit of connection of the
+// namespace. Event is dropped when connection isn't connected to namespace.
 func (nc *namespaceConn) EmitByNameSpace(namespace, eventName string, v ...interface{}) {
-	header := parser.Header{
-		Type: parser.Event,
+	if namespace == aliasRootNamespace {
+		namespace = rootNamespace
 	}
 
-	header.Namespace = namespace
-	if l := len(v); l > 0 {
-		last := v[l-1]
-		lastV := reflect.TypeOf(last)
+	target, ok := nc.conn.namespaces.Get(namespace)
+	if !ok {
+		nc.Logger().Info("Emit is dropped", "event", eventName, "err", "namespace "+namespace+" isn't connected")
+		return
+	}
 
-		if lastV.Kind() == reflect.Func {
-			f := newAckFunc(last)
+	target.emit(eventName, v...)
+}
 
-			header.ID = nc.conn.nextID()
-			header.NeedAck = true
+func (nc *namespaceConn) getBroadcast() Broadcast {
+	nc.broadcastLock.RLock()
+	defer nc.broadcastLock.RUnlock()
 
-			nc.ack.Store(header.ID, f)
-			v = v[:l-1]
-		}
-	}
+	return nc.broadcast
+}
 
-	args := make([]reflect.Value, len(v)+1)
-	args[0] = reflect.ValueOf(eventName)
+func (nc *namespaceConn) setBroadcast(b Broadcast) {
+	nc.broadcastLock.Lock()
+	defer nc.broadcastLock.Unlock()
 
-	for i := 1; i < len(args); i++ {
-		args[i] = reflect.ValueOf(v[i-1])
-	}
+	nc.broadcast = b
+}
 
-	nc.conn.write(header, args...)
+// moveBroadcast moves rooms of connection from old to broadcast b, joins and leaves of connection
+// wait until it's moved, see Server.RebindAdapter.
+func (nc *namespaceConn) moveBroadcast(old, b Broadcast) {
+	nc.broadcastLock.Lock()
+	defer nc.broadcastLock.Unlock()
+
+	moveRooms(old, b, nc)
+	nc.broadcast = b
 }
 
 func (nc *namespaceConn) Join(room string) {
+	_ = nc.TryJoin(room)
+}
+
+func (nc *namespaceConn) TryJoin(room string) error {
+	nc.closeLock.RLock()
+	defer nc.closeLock.RUnlock()
+
+	if nc.isClosing() {
+		return ErrConnClosed
+	}
+
+	nc.broadcastLock.RLock()
+	defer nc.broadcastLock.RUnlock()
+
 	nc.broadcast.Join(room, nc)
+
+	return nil
 }
 
 func (nc *namespaceConn) Leave(room string) {
+	nc.broadcastLock.RLock()
+	defer nc.broadcastLock.RUnlock()
+
 	nc.broadcast.Leave(room, nc)
 }
 
 func (nc *namespaceConn) LeaveAll() {
+	nc.broadcastLock.RLock()
+	defer nc.broadcastLock.RUnlock()
+
 	nc.broadcast.LeaveAll(nc)
 }
 
 func (nc *namespaceConn) Rooms() []string {
+	nc.broadcastLock.RLock()
+	defer nc.broadcastLock.RUnlock()
+
 	return nc.broadcast.Rooms(nc)
 }
@@ -0,0 +1,189 @@
+package socketio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+type addrEngineConn struct {
+	engineio.Conn
+	id   string
+	addr net.Addr
+}
+
+func (c addrEngineConn) ID() string {
+	return c.id
+}
+
+func (c addrEngineConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c addrEngineConn) URL() url.URL {
+	return url.URL{Path: "/socket.io/", RawQuery: "token=" + c.id}
+}
+
+func (c addrEngineConn) RemoteHeader() http.Header {
+	return http.Header{"User-Agent": {"test"}}
+}
+
+func TestNamespaceConnLogger(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var buf bytes.Buffer
+
+	c := &conn{Conn: addrEngineConn{
+		id:   "sid1",
+		addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8000},
+	}}
+	nc := newNamespaceConn(c, "/chat", nil)
+
+	nc.bindLogger(slog.New(slog.NewJSONHandler(&buf, nil))).Info("hello", "event", "message")
+
+	var record map[string]interface{}
+	must.NoError(json.Unmarshal(buf.Bytes(), &record))
+	should.Equal("hello", record["msg"])
+	should.Equal("sid1", record["sid"])
+	should.Equal("/chat", record["namespace"])
+	should.Equal("127.0.0.1:8000", record["remote_addr"])
+	should.Equal("message", record["event"])
+}
+
+func TestNamespaceConnClosed(t *testing.T) {
+	should := assert.New(t)
+
+	c := newConn(&failingEngineConn{}, newNamespaceHandlers())
+	nc := newNamespaceConn(c, "/chat", newBroadcast())
+	c.namespaces.Set("/chat", nc)
+
+	should.NoError(nc.TryJoin("lobby"))
+
+	// handlers of the connection may still join rooms while it's being closed
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nc.Join(fmt.Sprintf("room%d", i))
+		}(i)
+	}
+	should.NoError(c.Close())
+	wg.Wait()
+
+	should.Empty(nc.Rooms(), "closed connection doesn't stay in rooms")
+	should.ErrorIs(nc.TryJoin("lobby"), ErrConnClosed)
+	should.Empty(nc.Rooms())
+
+	should.ErrorIs(nc.TryEmit("message", "hello"), ErrConnClosed)
+	nc.Emit("message", func() {})
+
+	var acks int
+	nc.ack.Range(func(_, _ interface{}) bool {
+		acks++
+		return true
+	})
+	should.Zero(acks, "ack of event which isn't sent isn't kept")
+}
+
+func TestNamespaceConnState(t *testing.T) {
+	should := assert.New(t)
+
+	before := time.Now()
+	c := newConn(&transportEngineConn{}, newNamespaceHandlers())
+	nc := newNamespaceConn(c, "/chat", newBroadcast())
+	c.namespaces.Set("/chat", nc)
+
+	var connection Conn = nc
+	should.Equal("websocket", connection.Transport())
+	should.False(connection.ConnectedAt().Before(before))
+	should.False(connection.Closed())
+
+	should.NoError(c.Close())
+	should.True(connection.Closed())
+
+	should.Empty(newNamespaceConn(&conn{Conn: addrEngineConn{}}, "/chat", nil).Transport(),
+		"transport of engine connection isn't known")
+}
+
+func TestNamespaceConnContextConcurrent(t *testing.T) {
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/chat", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			nc.SetContext(i)
+			_ = nc.Context()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.IsType(t, 0, nc.Context())
+}
+
+func TestNamespaceConnEmitByNameSpace(t *testing.T) {
+	should := assert.New(t)
+
+	handlers := newNamespaceHandlers()
+	chatHandler := newNamespaceHandler("/chat", nil)
+	chatHandler.DeclareEvents("doc")
+	handlers.Set("/chat", chatHandler)
+
+	var captured []CapturedPayload
+	c := newConn(addrEngineConn{id: "sid1"}, handlers)
+	c.chunking = newChunker(&ChunkOptions{Threshold: 16})
+	c.capture = newPayloadCapture(&PayloadCapture{
+		Sink: captureSinkFunc(func(p CapturedPayload) {
+			captured = append(captured, p)
+		}),
+		SampleRate: 1,
+	})
+
+	root := newNamespaceConn(c, aliasRootNamespace, nil)
+	c.namespaces.Set(rootNamespace, root)
+	chat := newNamespaceConn(c, "/chat", nil)
+	c.namespaces.Set("/chat", chat)
+
+	written := make(chan outgoingPacket, 8)
+	go func() {
+		for pkg := range c.writeChan {
+			written <- pkg
+		}
+	}()
+
+	// emit to other namespace is checked, captured and chunked like emit of its connection
+	root.EmitByNameSpace("/chat", "mesage", "hi")
+	root.EmitByNameSpace("/missing", "doc", "hi")
+	root.EmitByNameSpace("/chat", "doc", strings.Repeat("x", 32), func() {})
+
+	var chunks []outgoingPacket
+	for len(chunks) == 0 || !chunks[len(chunks)-1].Header.NeedAck {
+		pkg := <-written
+		should.Equal("/chat", pkg.Header.Namespace)
+		should.Equal(ChunkEvent, pkg.event())
+		chunks = append(chunks, pkg)
+	}
+	should.Greater(len(chunks), 1)
+	should.Equal(1, chat.pendingAcks(), "ack is kept by connection of namespace")
+
+	should.Len(captured, 1)
+	should.Equal("/chat", captured[0].Namespace)
+	should.Equal("doc", captured[0].Event)
+}
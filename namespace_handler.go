@@ -2,21 +2,73 @@ package socketio
 
 import (
 	"errors"
+	"io"
 	"reflect"
 	"sync"
 
+	"github.com/thisismz/go-socket.io/logger"
 	"github.com/thisismz/go-socket.io/parser"
 )
 
 type namespaceHandler struct {
-	broadcast Broadcast
+	broadcast     Broadcast
+	broadcastLock sync.RWMutex
+
+	// pinned is set when broadcast is set by Server.SetBroadcaster, it's kept by RebindAdapter.
+	pinned bool
 
 	events     map[string]*funcHandler
+	eventKeys  map[string]SerializationKeyFunc
 	eventsLock sync.RWMutex
 
+	// offloads are events handed to workers, see Server.OffloadEvent.
+	offloads map[string]*eventOffloader
+
+	// serverSideEvents are handlers of events of other nodes, see Server.OnServerSideEvent.
+	serverSideEvents map[string]*funcHandler
+
+	// onRemoteBroadcast is called with broadcasts of other nodes delivered by broadcast.
+	onRemoteBroadcast func(record BroadcastRecord)
+
+	// declaredEvents are the only events allowed when it's set, see Server.DeclareEvents.
+	declaredEvents map[string]struct{}
+
+	roomSchemas     map[string]*RoomSchema
+	roomSchemasLock sync.RWMutex
+
+	// querySchema is checked against handshake query on CONNECT, see Server.SetQuerySchema.
+	querySchema     *QuerySchema
+	querySchemaLock sync.RWMutex
+
+	// middlewares run on connect before onConnect, see Server.Use.
+	middlewares     []Middleware
+	middlewaresLock sync.RWMutex
+
+	// variants are alternative handlers picked for connections, see Server.HandlerVariant.
+	variants     []*HandlerVariant
+	variantsLock sync.RWMutex
+
+	payloadLimit      int
+	roomPayloadLimits map[string]int
+	payloadLimitsLock sync.RWMutex
+
 	onConnect    func(conn Conn) error
 	onDisconnect func(conn Conn, msg string)
 	onError      func(conn Conn, err error)
+
+	onDisconnectDetails DisconnectDetailsFunc
+
+	// disconnectHooks clean up state of features of the package for disconnected connections,
+	// they run before OnDisconnect handler.
+	disconnectHooks     []func(conn Conn)
+	disconnectHooksLock sync.RWMutex
+
+	// eventLimiter and roomLimiter are rate limits of events of connections and broadcasts to rooms.
+	eventLimiter Limiter
+	roomLimiter  Limiter
+
+	// disabled is set while namespace refuses new connections, see Server.DisableNamespace.
+	disabled int32
 }
 
 func newNamespaceHandler(nsp string, adapterOpts *RedisAdapterOptions) *namespaceHandler {
@@ -30,7 +82,102 @@ func newNamespaceHandler(nsp string, adapterOpts *RedisAdapterOptions) *namespac
 	return &namespaceHandler{
 		broadcast: broadcast,
 		events:    make(map[string]*funcHandler),
+		eventKeys: make(map[string]SerializationKeyFunc),
+
+		roomSchemas: make(map[string]*RoomSchema),
+
+		roomPayloadLimits: make(map[string]int),
+	}
+}
+
+func (nh *namespaceHandler) getBroadcast() Broadcast {
+	nh.broadcastLock.RLock()
+	defer nh.broadcastLock.RUnlock()
+
+	return nh.broadcast
+}
+
+func (nh *namespaceHandler) setBroadcast(b Broadcast) Broadcast {
+	nh.broadcastLock.Lock()
+	defer nh.broadcastLock.Unlock()
+
+	return nh.swapBroadcast(b)
+}
+
+// swapBroadcast replaces broadcast with b, caller holds broadcastLock.
+func (nh *namespaceHandler) swapBroadcast(b Broadcast) Broadcast {
+	old := nh.broadcast
+	nh.broadcast = b
+
+	if emitter, ok := b.(serverSideEmitter); ok {
+		emitter.setOnServerSideEvent(nh.dispatchServerSideEvent)
+	}
+	if receiver, ok := b.(remoteBroadcastReceiver); ok && nh.onRemoteBroadcast != nil {
+		receiver.setOnRemoteBroadcast(nh.onRemoteBroadcast)
 	}
+
+	return old
+}
+
+// withBroadcast calls f with broadcast, which isn't replaced by rebind until f returns.
+func (nh *namespaceHandler) withBroadcast(f func(b Broadcast)) {
+	nh.broadcastLock.RLock()
+	defer nh.broadcastLock.RUnlock()
+
+	f(nh.broadcast)
+}
+
+// bindConn sets broadcast of new connection and joins it to room of its id, so connection
+// made while rebind moves members isn't left in old broadcast.
+func (nh *namespaceHandler) bindConn(nc *namespaceConn) {
+	nh.withBroadcast(func(b Broadcast) {
+		nc.setBroadcast(b)
+		nc.Join(nc.Conn.ID())
+	})
+}
+
+// rebind replaces broadcast of namespace with b, hooks of rooms and members of rooms on this
+// node are moved to b. Joins and leaves wait until they're moved, then old is closed.
+func (nh *namespaceHandler) rebind(b Broadcast) {
+	nh.broadcastLock.Lock()
+	defer nh.broadcastLock.Unlock()
+
+	old := nh.swapBroadcast(b)
+	moveRoomHooks(old, b)
+
+	rooms := old.AllRooms()
+	if local, ok := old.(localRoomsLister); ok {
+		rooms = local.allRooms()
+	}
+
+	members := make(map[string]Conn)
+	for _, room := range rooms {
+		old.ForEach(room, func(connection Conn) {
+			members[connection.ID()] = connection
+		})
+	}
+
+	for _, connection := range members {
+		if nc, ok := connection.(*namespaceConn); ok {
+			nc.moveBroadcast(old, b)
+			continue
+		}
+		moveRooms(old, b, connection)
+	}
+
+	if closer, ok := old.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("close rebound broadcast:", err)
+		}
+	}
+}
+
+// moveRooms joins connection to its rooms of old in b, then it leaves old.
+func moveRooms(old, b Broadcast, connection Conn) {
+	for _, room := range old.Rooms(connection) {
+		b.Join(room, connection)
+	}
+	old.LeaveAll(connection)
 }
 
 func (nh *namespaceHandler) OnConnect(f func(Conn) error) {
@@ -41,6 +188,21 @@ func (nh *namespaceHandler) OnDisconnect(f func(Conn, string)) {
 	nh.onDisconnect = f
 }
 
+// addDisconnectHook adds f to hooks called for every connection which leaves namespace.
+func (nh *namespaceHandler) addDisconnectHook(f func(Conn)) {
+	nh.disconnectHooksLock.Lock()
+	defer nh.disconnectHooksLock.Unlock()
+
+	nh.disconnectHooks = append(nh.disconnectHooks, f)
+}
+
+func (nh *namespaceHandler) getDisconnectHooks() []func(Conn) {
+	nh.disconnectHooksLock.RLock()
+	defer nh.disconnectHooksLock.RUnlock()
+
+	return nh.disconnectHooks
+}
+
 func (nh *namespaceHandler) OnError(f func(Conn, error)) {
 	nh.onError = f
 }
@@ -52,39 +214,132 @@ func (nh *namespaceHandler) OnEvent(event string, f interface{}) {
 	nh.events[event] = newEventFunc(f)
 }
 
-func (nh *namespaceHandler) getEventTypes(event string) []reflect.Type {
+func (nh *namespaceHandler) SerializeEvent(event string, f SerializationKeyFunc) {
+	nh.eventsLock.Lock()
+	defer nh.eventsLock.Unlock()
+
+	nh.eventKeys[event] = f
+}
+
+func (nh *namespaceHandler) SetRoomSchema(roomType string, schema *RoomSchema) {
+	nh.roomSchemasLock.Lock()
+	defer nh.roomSchemasLock.Unlock()
+
+	if schema == nil {
+		delete(nh.roomSchemas, roomType)
+		return
+	}
+
+	nh.roomSchemas[roomType] = schema
+}
+
+// validateRoomEvent checks event emitted into room against schema of room type, if any.
+func (nh *namespaceHandler) validateRoomEvent(room, event string, args []interface{}) error {
+	typ := roomType(room)
+	if typ == "" {
+		return nil
+	}
+
+	nh.roomSchemasLock.RLock()
+	schema := nh.roomSchemas[typ]
+	nh.roomSchemasLock.RUnlock()
+
+	if schema == nil {
+		return nil
+	}
+
+	return schema.validate(event, args)
+}
+
+func (nh *namespaceHandler) SetPayloadLimit(limit int) {
+	nh.payloadLimitsLock.Lock()
+	defer nh.payloadLimitsLock.Unlock()
+
+	nh.payloadLimit = limit
+}
+
+func (nh *namespaceHandler) SetRoomPayloadLimit(room string, limit int) {
+	nh.payloadLimitsLock.Lock()
+	defer nh.payloadLimitsLock.Unlock()
+
+	if limit <= 0 {
+		delete(nh.roomPayloadLimits, room)
+		return
+	}
+
+	nh.roomPayloadLimits[room] = limit
+}
+
+// getPayloadLimit gives the tightest payload limit of namespace and rooms of conn, zero when
+// there's no limit. Limit of room is looked up by its name, then by its type.
+func (nh *namespaceHandler) getPayloadLimit(conn Conn) int {
+	nh.payloadLimitsLock.RLock()
+	defer nh.payloadLimitsLock.RUnlock()
+
+	limit := nh.payloadLimit
+	if len(nh.roomPayloadLimits) == 0 {
+		return limit
+	}
+
+	for _, room := range conn.Rooms() {
+		roomLimit, ok := nh.roomPayloadLimits[room]
+		if !ok {
+			roomLimit = nh.roomPayloadLimits[roomType(room)]
+		}
+
+		if roomLimit > 0 && (limit <= 0 || roomLimit < limit) {
+			limit = roomLimit
+		}
+	}
+
+	return limit
+}
+
+func (nh *namespaceHandler) getEventKey(event string) SerializationKeyFunc {
 	nh.eventsLock.RLock()
-	namespaceHandler := nh.events[event]
-	nh.eventsLock.RUnlock()
+	defer nh.eventsLock.RUnlock()
+
+	return nh.eventKeys[event]
+}
+
+func (nh *namespaceHandler) getEventTypes(event string) []reflect.Type {
+	return nh.eventTypes(nil, event)
+}
 
-	if namespaceHandler != nil {
+// eventTypes gives types of arguments of event of conn, which may be served by variant.
+func (nh *namespaceHandler) eventTypes(conn Conn, event string) []reflect.Type {
+	if namespaceHandler := nh.eventHandler(conn, event); namespaceHandler != nil {
 		return namespaceHandler.argTypes
 	}
 
+	if o := nh.getOffload(event); o != nil {
+		return o.argTypes
+	}
+
 	return nil
 }
 
 func (nh *namespaceHandler) dispatch(conn Conn, header parser.Header, args ...reflect.Value) ([]reflect.Value, error) {
 	switch header.Type {
 	case parser.Connect:
-		if nh.onConnect != nil {
-			return nil, nh.onConnect(conn)
+		if onConnect := nh.connectHandler(conn); onConnect != nil {
+			return nil, onConnect(conn)
 		}
 		return nil, nil
 
 	case parser.Disconnect:
-		if nh.onDisconnect != nil {
-			nh.onDisconnect(conn, getDispatchMessage(args...))
+		if onDisconnect := nh.disconnectHandler(conn); onDisconnect != nil {
+			onDisconnect(conn, getDispatchMessage(args...))
 		}
 		return nil, nil
 
 	case parser.Error:
-		if nh.onError != nil {
+		if onError := nh.errorHandler(conn); onError != nil {
 			msg := getDispatchMessage(args...)
 			if msg == "" {
 				msg = "parser error dispatch"
 			}
-			nh.onError(conn, errors.New(msg))
+			onError(conn, errors.New(msg))
 		}
 	}
 
@@ -92,10 +347,7 @@ func (nh *namespaceHandler) dispatch(conn Conn, header parser.Header, args ...re
 }
 
 func (nh *namespaceHandler) dispatchEvent(conn Conn, event string, args ...reflect.Value) ([]reflect.Value, error) {
-	nh.eventsLock.RLock()
-	namespaceHandler := nh.events[event]
-	nh.eventsLock.RUnlock()
-
+	namespaceHandler := nh.eventHandler(conn, event)
 	if namespaceHandler == nil {
 		return nil, nil
 	}
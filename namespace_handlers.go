@@ -1,10 +1,23 @@
 package socketio
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type namespaceHandlers struct {
 	handlers map[string]*namespaceHandler
 	mu       sync.RWMutex
+
+	hold *connectHold
+}
+
+// connectHold holds connects to namespaces without handler until handlers are ready.
+type connectHold struct {
+	timeout time.Duration
+
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
 func newNamespaceHandlers() *namespaceHandlers {
@@ -27,3 +40,65 @@ func (h *namespaceHandlers) Get(nsp string) (*namespaceHandler, bool) {
 	handler, ok := h.handlers[nsp]
 	return handler, ok
 }
+
+// All gives copy of handlers by namespace.
+func (h *namespaceHandlers) All() map[string]*namespaceHandler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	handlers := make(map[string]*namespaceHandler, len(h.handlers))
+	for nsp, handler := range h.handlers {
+		handlers[nsp] = handler
+	}
+
+	return handlers
+}
+
+// Hold makes Await wait up to timeout for missing handlers until Release is called.
+func (h *namespaceHandlers) Hold(timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hold = &connectHold{
+		timeout: timeout,
+		ready:   make(chan struct{}),
+	}
+}
+
+// Release releases all the held connects.
+func (h *namespaceHandlers) Release() {
+	h.mu.RLock()
+	hold := h.hold
+	h.mu.RUnlock()
+
+	if hold == nil {
+		return
+	}
+
+	hold.readyOnce.Do(func() {
+		close(hold.ready)
+	})
+}
+
+// Await returns handler of namespace, if handler is missing and connects are held,
+// it waits until handlers are released or hold timeout.
+func (h *namespaceHandlers) Await(nsp string) (*namespaceHandler, bool) {
+	h.mu.RLock()
+	handler, ok := h.handlers[nsp]
+	hold := h.hold
+	h.mu.RUnlock()
+
+	if ok || hold == nil {
+		return handler, ok
+	}
+
+	timer := time.NewTimer(hold.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-hold.ready:
+	case <-timer.C:
+	}
+
+	return h.Get(nsp)
+}
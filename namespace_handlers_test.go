@@ -0,0 +1,169 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestNamespaceHandlersHold(t *testing.T) {
+	should := assert.New(t)
+
+	h := newNamespaceHandlers()
+
+	// missing handler isn't waited for without hold
+	_, ok := h.Await("/late")
+	should.False(ok)
+
+	h.Hold(time.Minute)
+
+	type result struct {
+		handler *namespaceHandler
+		ok      bool
+	}
+	awaited := make(chan result, 1)
+	go func() {
+		handler, ok := h.Await("/late")
+		awaited <- result{handler, ok}
+	}()
+
+	select {
+	case <-awaited:
+		t.Fatal("connect isn't held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	handler := newNamespaceHandler("/late", nil)
+	h.Set("/late", handler)
+	h.Release()
+
+	select {
+	case r := <-awaited:
+		should.True(r.ok)
+		should.Equal(handler, r.handler)
+	case <-time.After(5 * time.Second):
+		t.Fatal("held connect isn't released")
+	}
+
+	// registered handler is returned at once, the other ones aren't held after release
+	_, ok = h.Await("/late")
+	should.True(ok)
+	_, ok = h.Await("/missing")
+	should.False(ok)
+	h.Release()
+
+	h.Hold(50 * time.Millisecond)
+	start := time.Now()
+	_, ok = h.Await("/missing")
+	should.False(ok)
+	should.GreaterOrEqual(time.Since(start), 50*time.Millisecond, "connect is held until timeout")
+}
+
+func TestServerHoldConnects(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t, func(server *Server) {
+		must.NoError(server.HoldConnects(time.Minute))
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	// root namespace is registered, so its connect isn't held
+	header, _, _ := c.receive(t)
+	should.Equal(parser.Connect, header.Type)
+
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/late"})
+
+	received := make(chan parser.Header, 1)
+	go func() {
+		var header parser.Header
+		var event string
+		if c.decoder.DecodeHeader(&header, &event) == nil {
+			received <- header
+		}
+	}()
+
+	select {
+	case <-received:
+		t.Fatal("connect of missing namespace isn't held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.OnConnect("/late", func(Conn) error {
+		return nil
+	})
+	server.Ready()
+
+	select {
+	case header = <-received:
+		should.Equal(parser.Connect, header.Type)
+		should.Equal("/late", header.Namespace)
+	case <-time.After(5 * time.Second):
+		t.Fatal("held connect isn't released by Ready")
+	}
+}
+
+func TestServerHoldConnectsTimeout(t *testing.T) {
+	must := require.New(t)
+
+	_, url := newConformanceServer(t, func(server *Server) {
+		must.NoError(server.HoldConnects(100 * time.Millisecond))
+	})
+
+	c := newProtocolClient(t, url)
+	defer c.conn.Close()
+
+	_, _, _ = c.receive(t)
+
+	start := time.Now()
+	c.send(t, parser.Header{Type: parser.Connect, Namespace: "/late"})
+
+	done := make(chan error, 1)
+	go func() {
+		var header parser.Header
+		var event string
+		done <- c.decoder.DecodeHeader(&header, &event)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err, "connect is refused once hold times out")
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("held connect doesn't time out")
+	}
+}
+
+func TestServerHoldConnectsClose(t *testing.T) {
+	must := require.New(t)
+
+	server := NewServer(nil)
+	must.NoError(server.HoldConnects(time.Minute))
+
+	awaited := make(chan bool, 1)
+	go func() {
+		_, ok := server.handlers.Await("/late")
+		awaited <- ok
+	}()
+
+	select {
+	case <-awaited:
+		t.Fatal("connect isn't held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	must.NoError(server.Close())
+
+	select {
+	case ok := <-awaited:
+		must.False(ok, "missing namespace is refused")
+	case <-time.After(5 * time.Second):
+		t.Fatal("held connect isn't released by Close")
+	}
+}
@@ -0,0 +1,89 @@
+package socketio
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// NamespaceRouter gives namespace which serves connection to root namespace by origin and host
+// of its handshake request, e.g. "/customer1" for "https://customer1.example.com". Connection
+// is served by root namespace when it gives "" or "/".
+type NamespaceRouter func(origin, host string) string
+
+// RouteNamespaces routes connections to root namespace to namespaces given by router, so one
+// server serves white-labeled frontends without namespace logic of clients. Clients keep using
+// root namespace, their packets are served by the routed namespace and its packets reach them
+// as packets of root namespace. Other namespaces aren't routed.
+func (s *Server) RouteNamespaces(router NamespaceRouter) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.namespaceRouter = router
+
+	return nil
+}
+
+// HostNamespaces gives router which maps hostnames to namespaces, hostname of origin is looked
+// up before host of request. Ports are ignored and hostnames are case insensitive.
+func HostNamespaces(routes map[string]string) NamespaceRouter {
+	byHost := make(map[string]string, len(routes))
+	for host, nsp := range routes {
+		byHost[strings.ToLower(host)] = nsp
+	}
+
+	return func(origin, host string) string {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			if nsp, ok := byHost[hostname(u.Host)]; ok {
+				return nsp
+			}
+		}
+
+		return byHost[hostname(host)]
+	}
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
+
+// routeNamespace gives namespace which serves root namespace of client of engine connection.
+func (s *Server) routeNamespace(conn engineio.Conn) string {
+	if s.namespaceRouter == nil {
+		return rootNamespace
+	}
+
+	header := conn.RemoteHeader()
+	nsp := s.namespaceRouter(header.Get("Origin"), header.Get("Host"))
+	if nsp == aliasRootNamespace {
+		return rootNamespace
+	}
+
+	return nsp
+}
+
+// routedIn gives namespace which serves namespace of received packet.
+func (c *conn) routedIn(nsp string) string {
+	if c.route != rootNamespace && nsp == rootNamespace {
+		return c.route
+	}
+
+	return nsp
+}
+
+// routedOut gives header of packet with namespace which client knows.
+func (c *conn) routedOut(header parser.Header) parser.Header {
+	if c.route != rootNamespace && header.Namespace == c.route {
+		header.Namespace = rootNamespace
+	}
+
+	return header
+}
@@ -0,0 +1,90 @@
+package socketio
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+func TestHostNamespaces(t *testing.T) {
+	should := assert.New(t)
+
+	router := HostNamespaces(map[string]string{
+		"Customer1.example.com": "/customer1",
+		"customer2.example.com": "/customer2",
+	})
+
+	should.Equal("/customer1", router("https://customer1.example.com", "api.example.com"))
+	should.Equal("/customer1", router("https://CUSTOMER1.example.com:8443", ""))
+	should.Equal("/customer2", router("", "customer2.example.com:443"))
+	should.Equal("/customer2", router("https://unknown.example.com", "customer2.example.com"))
+	should.Empty(router("https://unknown.example.com", "api.example.com"))
+}
+
+func TestServerRouteNamespaces(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(server.RouteNamespaces(HostNamespaces(map[string]string{"127.0.0.1": "/customer1"})))
+
+	connected := make(chan string, 2)
+	for _, nsp := range []string{"/", "/customer1"} {
+		server.OnConnect(nsp, func(c Conn) error {
+			connected <- c.Namespace()
+			return nil
+		})
+		server.OnEvent(nsp, "hello", func(c Conn) string {
+			c.Emit("welcome", c.Namespace())
+			return c.Namespace()
+		})
+	}
+
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	welcome := make(chan string, 1)
+	client.OnEvent("welcome", func(_ Conn, nsp string) {
+		welcome <- nsp
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	acked := make(chan string, 1)
+	client.Emit("hello", func(nsp string) {
+		acked <- nsp
+	})
+
+	for _, ch := range []chan string{acked, welcome} {
+		select {
+		case nsp := <-ch:
+			should.Equal("/customer1", nsp)
+		case <-time.After(5 * time.Second):
+			t.Fatal("routed namespace didn't respond")
+		}
+	}
+
+	should.Eventually(func() bool {
+		return server.Count() == 1 && len(connected) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
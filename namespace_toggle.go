@@ -0,0 +1,145 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrNamespaceUnavailable is message of CONNECT_ERROR packet sent to clients which connect to
+// disabled namespace, see Server.DisableNamespace.
+var ErrNamespaceUnavailable = errors.New("namespace is temporarily unavailable")
+
+// NamespaceUnavailableCode is code in data of CONNECT_ERROR packet of disabled namespace.
+const NamespaceUnavailableCode = "NAMESPACE_UNAVAILABLE"
+
+// namespaceDisabledMsg is reason of disconnect of connections drained from disabled namespace.
+const namespaceDisabledMsg = "namespace disabled"
+
+// ConnectError is error of CONNECT refused by server with CONNECT_ERROR packet, it's passed
+// to OnError handler of client namespace.
+type ConnectError struct {
+	Namespace string
+	Message   string
+	Data      interface{}
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("connect to namespace (%s) refused: %s", e.Namespace, e.Message)
+}
+
+// DisableNamespace marks namespace temporarily disabled, e.g. for feature-flagged rollout of its
+// realtime features. New CONNECTs to it are refused with CONNECT_ERROR packet, which message is
+// ErrNamespaceUnavailable and data is {"code": NamespaceUnavailableCode, "namespace": namespace}.
+// Existing connections are kept, unless drain is set, then they're disconnected from namespace,
+// which calls its OnDisconnect handler with "namespace disabled" reason.
+func (s *Server) DisableNamespace(namespace string, drain bool) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	atomic.StoreInt32(&h.disabled, 1)
+
+	if drain {
+		h.drain()
+	}
+}
+
+// EnableNamespace accepts new CONNECTs to namespace again after DisableNamespace.
+func (s *Server) EnableNamespace(namespace string) {
+	if h := s.getNamespace(namespace); h != nil {
+		atomic.StoreInt32(&h.disabled, 0)
+	}
+}
+
+// NamespaceEnabled tells whether namespace accepts new CONNECTs, see DisableNamespace.
+func (s *Server) NamespaceEnabled(namespace string) bool {
+	h := s.getNamespace(namespace)
+
+	return h == nil || !h.isDisabled()
+}
+
+func (nh *namespaceHandler) isDisabled() bool {
+	return atomic.LoadInt32(&nh.disabled) == 1
+}
+
+// drain disconnects local connections of namespace.
+func (nh *namespaceHandler) drain() {
+	conns := make(map[string]*namespaceConn)
+	for _, room := range nh.getBroadcast().AllRooms() {
+		nh.getBroadcast().ForEach(room, func(connection Conn) {
+			if nc, ok := connection.(*namespaceConn); ok {
+				conns[nc.ID()] = nc
+			}
+		})
+	}
+
+	for _, nc := range conns {
+		nc.disconnectNamespace(namespaceDisabledMsg)
+	}
+}
+
+// disconnectNamespace disconnects connection from its namespace only, client is told by DISCONNECT packet.
+func (nc *namespaceConn) disconnectNamespace(reason string) {
+	c := nc.conn
+	// connection may leave namespace concurrently, e.g. on DISCONNECT packet of client
+	if !c.namespaces.Remove(nc.namespace, nc) {
+		return
+	}
+
+	c.write(parser.Header{Type: parser.Disconnect, Namespace: nc.namespace})
+
+	c.resume.forget(nc)
+	nc.LeaveAll()
+
+	if nh, ok := c.handlers.Get(nc.namespace); ok {
+		if onDisconnect := nh.disconnectHandler(nc); onDisconnect != nil {
+			onDisconnect(nc, reason)
+		}
+	}
+}
+
+// rejectDisabledNamespace refuses CONNECT to disabled namespace with CONNECT_ERROR packet.
+func rejectDisabledNamespace(c *conn, header parser.Header) error {
+	header.Type = parser.Error
+	c.write(header, reflect.ValueOf(map[string]interface{}{
+		"message": ErrNamespaceUnavailable.Error(),
+		"data": map[string]interface{}{
+			"code":      NamespaceUnavailableCode,
+			"namespace": header.Namespace,
+		},
+	}))
+
+	return nil
+}
+
+// clientConnectErrorPacketHandler passes ConnectError of refused CONNECT to OnError handler of namespace.
+func clientConnectErrorPacketHandler(c *conn, header parser.Header) error {
+	// payload which isn't an object is ignored, e.g. of servers which send plain message.
+	args, _ := c.decoder.DecodeArgs(connectPayloadType)
+
+	connectErr := &ConnectError{Namespace: header.Namespace}
+	if len(args) > 0 {
+		payload, _ := args[0].Interface().(map[string]interface{})
+		connectErr.Message, _ = payload["message"].(string)
+		connectErr.Data = payload["data"]
+	}
+
+	handler, ok := c.handlers.Get(header.Namespace)
+	if !ok || handler.onError == nil {
+		return nil
+	}
+
+	nc, ok := c.namespaces.Get(header.Namespace)
+	if !ok {
+		// namespace isn't connected, so handler gets connection which isn't joined
+		nc = newNamespaceConn(c, header.Namespace, handler.getBroadcast())
+	}
+	handler.onError(nc, connectErr)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+func TestDisableNamespace(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, url := newConformanceServer(t)
+
+	serverDisconnected := make(chan string, 1)
+	server.OnDisconnect("/chat", func(_ Conn, reason string) {
+		serverDisconnected <- reason
+	})
+
+	server.DisableNamespace("/chat", false)
+	should.False(server.NamespaceEnabled("/chat"))
+	should.True(server.NamespaceEnabled("/"))
+
+	client, err := NewClientWithOptions(url, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	chat := client.Socket("/chat")
+
+	connectErrs := make(chan error, 1)
+	chat.OnError(func(_ Conn, err error) {
+		connectErrs <- err
+	})
+
+	chatConnected := make(chan struct{}, 1)
+	chat.OnConnect(func(Conn) error {
+		chatConnected <- struct{}{}
+		return nil
+	})
+
+	chatDisconnected := make(chan struct{}, 1)
+	chat.OnDisconnect(func(Conn, string) {
+		chatDisconnected <- struct{}{}
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	select {
+	case err := <-connectErrs:
+		var connectErr *ConnectError
+		must.ErrorAs(err, &connectErr)
+		should.Equal("/chat", connectErr.Namespace)
+		should.Equal(ErrNamespaceUnavailable.Error(), connectErr.Message)
+		should.Equal(map[string]interface{}{
+			"code":      NamespaceUnavailableCode,
+			"namespace": "/chat",
+		}, connectErr.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connect to disabled namespace wasn't refused")
+	}
+	should.False(chat.Connected())
+	should.True(client.Socket("/").Connected())
+
+	server.EnableNamespace("/chat")
+	should.True(server.NamespaceEnabled("/chat"))
+
+	must.NoError(chat.Connect())
+	select {
+	case <-chatConnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat socket wasn't connected once namespace was enabled")
+	}
+
+	server.DisableNamespace("/chat", true)
+
+	select {
+	case reason := <-serverDisconnected:
+		should.Equal(namespaceDisabledMsg, reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat connection wasn't drained")
+	}
+
+	select {
+	case <-chatDisconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client wasn't told about drain")
+	}
+	should.False(chat.Connected())
+	should.True(client.Socket("/").Connected())
+}
@@ -35,6 +35,19 @@ func (n *namespaces) Delete(ns string) {
 	delete(n.namespaces, ns)
 }
 
+// Remove deletes conn of namespace ns, it reports false when ns has another or no conn.
+func (n *namespaces) Remove(ns string, conn *namespaceConn) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.namespaces[ns] != conn {
+		return false
+	}
+	delete(n.namespaces, ns)
+
+	return true
+}
+
 func (n *namespaces) Range(fn func(ns string, nc *namespaceConn)) {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
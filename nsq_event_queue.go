@@ -0,0 +1,130 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultNSQTopic   = "socket.io-jobs"
+	defaultNSQChannel = "workers"
+)
+
+// NSQPublisher publishes message to NSQ topic, e.g. with Publish of go-nsq producer.
+type NSQPublisher interface {
+	Publish(topic string, body []byte) error
+}
+
+// NSQSubscriber consumes messages of channel of NSQ topic, e.g. with go-nsq consumer. Subscribe
+// calls handle with body of each message, which is finished once handle returns, and blocks until
+// ctx is done.
+type NSQSubscriber interface {
+	Subscribe(ctx context.Context, topic, channel string, handle func(body []byte)) error
+}
+
+// NSQEventQueueOptions is configuration of NSQEventQueue.
+type NSQEventQueueOptions struct {
+	// Topic carries jobs, default is "socket.io-jobs". Results of jobs are published to
+	// ephemeral topic of their node, Topic-node#ephemeral, so ids of nodes must be valid in
+	// names of topics, like the default ones.
+	Topic string
+
+	// Channel is channel of Topic which workers share, default is "workers".
+	Channel string
+
+	Publisher  NSQPublisher
+	Subscriber NSQSubscriber
+}
+
+func (o *NSQEventQueueOptions) getTopic() string {
+	if o.Topic == "" {
+		return defaultNSQTopic
+	}
+
+	return o.Topic
+}
+
+func (o *NSQEventQueueOptions) getChannel() string {
+	if o.Channel == "" {
+		return defaultNSQChannel
+	}
+
+	return o.Channel
+}
+
+// NSQEventQueue is EventQueue of NSQ topic, workers of channel take jobs with Work. NSQ delivers
+// messages at least once, so job may be handled again once worker fails before it's finished.
+type NSQEventQueue struct {
+	opts *NSQEventQueueOptions
+}
+
+// NewNSQEventQueue gives queue of NSQ of options.
+func NewNSQEventQueue(opts *NSQEventQueueOptions) (*NSQEventQueue, error) {
+	if opts == nil || opts.Publisher == nil || opts.Subscriber == nil {
+		return nil, errors.New("nsq event queue needs publisher and subscriber")
+	}
+
+	return &NSQEventQueue{opts: opts}, nil
+}
+
+func (q *NSQEventQueue) results(node string) string {
+	return q.opts.getTopic() + "-" + node + "#ephemeral"
+}
+
+// Enqueue publishes job to topic of jobs.
+func (q *NSQEventQueue) Enqueue(job *EventJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return q.opts.Publisher.Publish(q.opts.getTopic(), data)
+}
+
+// Results consumes topic of results of node.
+func (q *NSQEventQueue) Results(ctx context.Context, node string, handle func(*EventResult)) error {
+	return q.opts.Subscriber.Subscribe(ctx, q.results(node), "results#ephemeral", func(body []byte) {
+		var result EventResult
+		if err := json.Unmarshal(body, &result); err != nil {
+			logger.Error("offloaded event result:", err)
+			return
+		}
+
+		handle(&result)
+	})
+}
+
+// Work takes jobs from channel of workers and publishes their results until ctx is done, e.g. in
+// worker process. Result of job which doesn't need ack isn't published.
+func (q *NSQEventQueue) Work(ctx context.Context, handle func(job *EventJob) ([]interface{}, error)) error {
+	return q.opts.Subscriber.Subscribe(ctx, q.opts.getTopic(), q.opts.getChannel(), func(body []byte) {
+		var job EventJob
+		if err := json.Unmarshal(body, &job); err != nil {
+			logger.Error("offloaded event job:", err)
+			return
+		}
+
+		args, err := handle(&job)
+		if !job.NeedAck {
+			return
+		}
+
+		result := EventResult{ID: job.ID, Args: args}
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		data, err := json.Marshal(&result)
+		if err != nil {
+			logger.Error("offloaded event result:", err)
+			return
+		}
+
+		if err = q.opts.Publisher.Publish(q.results(job.Node), data); err != nil {
+			logger.Error("publish offloaded event result:", err)
+		}
+	})
+}
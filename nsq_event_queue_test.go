@@ -0,0 +1,90 @@
+package socketio
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryNSQ delivers messages of each topic to one subscriber.
+type memoryNSQ struct {
+	topics map[string]chan []byte
+	lock   sync.Mutex
+}
+
+func (q *memoryNSQ) topic(name string) chan []byte {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.topics[name] == nil {
+		q.topics[name] = make(chan []byte, 8)
+	}
+
+	return q.topics[name]
+}
+
+func (q *memoryNSQ) Publish(topic string, body []byte) error {
+	q.topic(topic) <- body
+	return nil
+}
+
+func (q *memoryNSQ) Subscribe(ctx context.Context, topic, _ string, handle func(body []byte)) error {
+	messages := q.topic(topic)
+	for {
+		select {
+		case body := <-messages:
+			handle(body)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestNSQEventQueue(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	_, err := NewNSQEventQueue(nil)
+	should.Error(err)
+	_, err = NewNSQEventQueue(&NSQEventQueueOptions{Publisher: &memoryNSQ{}})
+	should.Error(err)
+
+	nsq := &memoryNSQ{topics: make(map[string]chan []byte)}
+	queue, err := NewNSQEventQueue(&NSQEventQueueOptions{Publisher: nsq, Subscriber: nsq})
+	must.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan *EventResult, 2)
+	go func() {
+		_ = queue.Results(ctx, "node1", func(result *EventResult) {
+			results <- result
+		})
+	}()
+	go func() {
+		_ = queue.Work(ctx, func(job *EventJob) ([]interface{}, error) {
+			should.Equal("render", job.Event)
+			return []interface{}{job.Args[0].(string) + " done"}, nil
+		})
+	}()
+
+	must.NoError(queue.Enqueue(&EventJob{ID: "1", Node: "node1", Event: "render", Args: []interface{}{"scene"}}))
+	must.NoError(queue.Enqueue(&EventJob{ID: "2", Node: "node1", Event: "render", Args: []interface{}{"scene"}, NeedAck: true}))
+
+	select {
+	case result := <-results:
+		should.Equal(&EventResult{ID: "2", Args: []interface{}{"scene done"}}, result, "result of job without ack isn't published")
+	case <-time.After(time.Second):
+		should.Fail("missing result")
+	}
+
+	nsq.lock.Lock()
+	defer nsq.lock.Unlock()
+
+	should.Contains(nsq.topics, "socket.io-jobs-node1#ephemeral", "results are published to topic of node")
+}
@@ -0,0 +1,342 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+const defaultOffloadTimeout = 30 * time.Second
+
+// ErrOffloadTimeout is the error of offloaded event whose worker didn't complete it in time.
+var ErrOffloadTimeout = errors.New("offloaded event timed out")
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// EventJob is event handed to workers, see Server.OffloadEvent.
+type EventJob struct {
+	ID        string        `json:"id"`
+	Node      string        `json:"node"`
+	Namespace string        `json:"namespace"`
+	ConnID    string        `json:"connId"`
+	Event     string        `json:"event"`
+	Args      []interface{} `json:"args"`
+	NeedAck   bool          `json:"needAck,omitempty"`
+}
+
+// EventResult is result of EventJob given by worker, Args are arguments of ack.
+type EventResult struct {
+	ID    string        `json:"id"`
+	Args  []interface{} `json:"args,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// EventQueue carries events to workers and their results back, e.g. redis list or NSQ topic.
+type EventQueue interface {
+	Enqueue(job *EventJob) error
+
+	// Results calls handle with results of jobs enqueued by node until ctx is done.
+	Results(ctx context.Context, node string, handle func(*EventResult)) error
+}
+
+// OffloadOptions configures offloaded event, see Server.OffloadEvent.
+type OffloadOptions struct {
+	Queue EventQueue
+
+	// Args are examples of arguments of event, like of ExpectArgs, arguments are decoded into
+	// values of the same types. Event has single argument of any JSON type when it's empty.
+	Args []interface{}
+
+	// Timeout bounds waiting for worker to complete event which needs ack, default is 30
+	// seconds. Ack gets an error once it elapses.
+	Timeout time.Duration
+}
+
+func (o *OffloadOptions) getTimeout() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultOffloadTimeout
+	}
+
+	return o.Timeout
+}
+
+func (o *OffloadOptions) argTypes() []reflect.Type {
+	if len(o.Args) == 0 {
+		return []reflect.Type{anyType}
+	}
+
+	types := make([]reflect.Type, len(o.Args))
+	for i, arg := range o.Args {
+		types[i] = reflect.TypeOf(arg)
+		if types[i] == nil {
+			types[i] = anyType
+		}
+	}
+
+	return types
+}
+
+// OffloadEvent hands event of namespace to workers through queue instead of handling it inline,
+// so CPU-heavy processing doesn't hold read loop of connection. Ack of event is sent once worker
+// completes it, it gets an error like {"error": "...", "event": "render"} when worker fails or
+// times out. Handler of event, if any, isn't called.
+func (s *Server) OffloadEvent(namespace, event string, opts *OffloadOptions) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	if opts == nil || opts.Queue == nil {
+		return errors.New("offloaded event needs queue")
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	o := &eventOffloader{
+		node:     s.nodeID,
+		opts:     opts,
+		argTypes: opts.argTypes(),
+		pending:  make(map[string]*offloadedEvent),
+	}
+	s.OnServerShutdownBegin(cancel)
+
+	go func() {
+		if err := opts.Queue.Results(ctx, o.node, o.complete); err != nil && ctx.Err() == nil {
+			logger.Error("offloaded event results:", err)
+		}
+	}()
+
+	h.OffloadEvent(event, o)
+
+	return nil
+}
+
+func (nh *namespaceHandler) OffloadEvent(event string, o *eventOffloader) {
+	nh.eventsLock.Lock()
+	defer nh.eventsLock.Unlock()
+
+	if nh.offloads == nil {
+		nh.offloads = make(map[string]*eventOffloader)
+	}
+	nh.offloads[event] = o
+}
+
+func (nh *namespaceHandler) getOffload(event string) *eventOffloader {
+	nh.eventsLock.RLock()
+	defer nh.eventsLock.RUnlock()
+
+	return nh.offloads[event]
+}
+
+// eventOffloader enqueues events and acks them with results of workers.
+type eventOffloader struct {
+	node     string
+	opts     *OffloadOptions
+	argTypes []reflect.Type
+
+	pending map[string]*offloadedEvent
+	lock    sync.Mutex
+}
+
+// offloadedEvent is enqueued event waiting for ack.
+type offloadedEvent struct {
+	c      *conn
+	header parser.Header
+	event  string
+	timer  *time.Timer
+}
+
+func (o *eventOffloader) offload(c *conn, conn *namespaceConn, event string, header parser.Header, args []reflect.Value) {
+	job := &EventJob{
+		ID:        newV4UUID(),
+		Node:      o.node,
+		Namespace: conn.Namespace(),
+		ConnID:    conn.ID(),
+		Event:     event,
+		Args:      valuesToInterfaces(args),
+		NeedAck:   header.NeedAck,
+	}
+
+	if header.NeedAck {
+		o.lock.Lock()
+		o.pending[job.ID] = &offloadedEvent{
+			c:      c,
+			header: header,
+			event:  event,
+			timer: time.AfterFunc(o.opts.getTimeout(), func() {
+				o.complete(&EventResult{ID: job.ID, Error: ErrOffloadTimeout.Error()})
+			}),
+		}
+		o.lock.Unlock()
+	}
+
+	if err := o.opts.Queue.Enqueue(job); err != nil {
+		conn.Logger().Info("Error enqueueing offloaded event", "event", event, "err", err.Error())
+		o.complete(&EventResult{ID: job.ID, Error: fmt.Sprintf("enqueue: %s", err)})
+	}
+}
+
+// complete acks event with result of worker.
+func (o *eventOffloader) complete(result *EventResult) {
+	o.lock.Lock()
+	pending, ok := o.pending[result.ID]
+	delete(o.pending, result.ID)
+	o.lock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	pending.timer.Stop()
+
+	header := pending.header
+	header.Type = parser.Ack
+
+	if result.Error != "" {
+		pending.c.write(header, reflect.ValueOf(map[string]interface{}{
+			"error": result.Error,
+			"event": pending.event,
+		}))
+		return
+	}
+
+	args := make([]reflect.Value, len(result.Args))
+	for i, arg := range result.Args {
+		args[i] = reflect.ValueOf(arg)
+	}
+	pending.c.write(header, args...)
+}
+
+// RedisEventQueue is EventQueue of redis list, results of jobs are published to channel of
+// their node. Workers take jobs with Work.
+type RedisEventQueue struct {
+	pool   *redis.Pool
+	opts   *RedisAdapterOptions
+	prefix string
+}
+
+// NewRedisEventQueue gives queue of redis of options.
+func NewRedisEventQueue(opts *RedisAdapterOptions) *RedisEventQueue {
+	opts = getOptions(opts)
+
+	return &RedisEventQueue{
+		pool:   opts.newPool(),
+		opts:   opts,
+		prefix: opts.Prefix,
+	}
+}
+
+func (q *RedisEventQueue) jobs() string {
+	return q.prefix + "-jobs"
+}
+
+func (q *RedisEventQueue) results(node string) string {
+	return q.prefix + "-results#" + node
+}
+
+// Enqueue pushes job to list of jobs.
+func (q *RedisEventQueue) Enqueue(job *EventJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("LPUSH", q.jobs(), data)
+	return err
+}
+
+// Results subscribes to channel of results of node.
+func (q *RedisEventQueue) Results(ctx context.Context, node string, handle func(*EventResult)) error {
+	sub := redis.PubSubConn{Conn: q.pool.Get()}
+	defer sub.Close()
+
+	if err := sub.Subscribe(q.results(node)); err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = sub.Unsubscribe()
+	}()
+
+	for {
+		switch m := receive(&sub).(type) {
+		case redis.Message:
+			var result EventResult
+			if err := json.Unmarshal(m.Data, &result); err != nil {
+				logger.Error("offloaded event result:", err)
+				continue
+			}
+			handle(&result)
+		case redis.Subscription:
+			if m.Count == 0 {
+				return nil
+			}
+		case error:
+			return m
+		}
+	}
+}
+
+// Work takes jobs from list of jobs and publishes their results until ctx is done, e.g. in
+// worker process. Result of job which doesn't need ack isn't published.
+func (q *RedisEventQueue) Work(ctx context.Context, handle func(job *EventJob) ([]interface{}, error)) error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	for ctx.Err() == nil {
+		reply, err := redis.ByteSlices(q.opts.doBlocking(conn, time.Second, "BRPOP", q.jobs(), 1))
+		if errors.Is(err, redis.ErrNil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(reply) != 2 {
+			continue
+		}
+
+		var job EventJob
+		if err = json.Unmarshal(reply[1], &job); err != nil {
+			logger.Error("offloaded event job:", err)
+			continue
+		}
+
+		args, err := handle(&job)
+		if !job.NeedAck {
+			continue
+		}
+
+		result := EventResult{ID: job.ID, Args: args}
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		data, err := json.Marshal(&result)
+		if err != nil {
+			return err
+		}
+
+		if _, err = conn.Do("PUBLISH", q.results(job.Node), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// memoryQueue hands jobs to test as worker.
+type memoryQueue struct {
+	jobs chan *EventJob
+
+	handle func(*EventResult)
+	lock   sync.Mutex
+}
+
+func (q *memoryQueue) Enqueue(job *EventJob) error {
+	q.jobs <- job
+	return nil
+}
+
+func (q *memoryQueue) Results(ctx context.Context, _ string, handle func(*EventResult)) error {
+	q.lock.Lock()
+	q.handle = handle
+	q.lock.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+func (q *memoryQueue) complete(result *EventResult) bool {
+	q.lock.Lock()
+	handle := q.handle
+	q.lock.Unlock()
+
+	if handle == nil {
+		return false
+	}
+
+	handle(result)
+	return true
+}
+
+func TestOffloadEvent(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	queue := &memoryQueue{jobs: make(chan *EventJob, 1)}
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	must.NoError(server.OffloadEvent("/render", "render", &OffloadOptions{
+		Queue:   queue,
+		Args:    []interface{}{""},
+		Timeout: 50 * time.Millisecond,
+	}))
+	should.Error(server.OffloadEvent("/render", "render", nil))
+
+	var handled int
+	server.OnEvent("/render", "render", func(Conn, string) {
+		handled++
+	})
+
+	nh := server.getNamespace("/render")
+	c := newConn(addrEngineConn{id: "sid1"}, server.handlers)
+	nc := newNamespaceConn(c, "/render", nh.getBroadcast())
+	c.namespaces.Set("/render", nc)
+
+	header := parser.Header{Type: parser.Event, Namespace: "/render", ID: 3, NeedAck: true}
+	must.NoError(handleEventPacket(c, nc, nh, "render", header, []reflect.Value{reflect.ValueOf("scene")}, 10))
+
+	job := <-queue.jobs
+	should.Equal("render", job.Event)
+	should.Equal("/render", job.Namespace)
+	should.Equal("sid1", job.ConnID)
+	should.Equal([]interface{}{"scene"}, job.Args)
+	should.True(job.NeedAck)
+	should.Zero(handled, "offloaded event isn't handled inline")
+
+	go func() {
+		for !queue.complete(&EventResult{ID: job.ID, Args: []interface{}{"done"}}) {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	pkg := <-c.writeChan
+	should.Equal(parser.Ack, pkg.Header.Type)
+	should.Equal(uint64(3), pkg.Header.ID)
+	should.Equal([]interface{}{"done"}, pkg.Data)
+
+	// worker which doesn't complete event in time
+	must.NoError(handleEventPacket(c, nc, nh, "render", header, []reflect.Value{reflect.ValueOf("scene")}, 10))
+	<-queue.jobs
+
+	pkg = <-c.writeChan
+	should.Equal([]interface{}{map[string]interface{}{
+		"error": ErrOffloadTimeout.Error(),
+		"event": "render",
+	}}, pkg.Data)
+}
+
+func TestOffloadOptions(t *testing.T) {
+	should := assert.New(t)
+
+	should.Equal(defaultOffloadTimeout, (&OffloadOptions{}).getTimeout())
+	should.Equal([]reflect.Type{anyType}, (&OffloadOptions{}).argTypes())
+	should.Equal([]reflect.Type{reflect.TypeOf(""), anyType}, (&OffloadOptions{Args: []interface{}{"", nil}}).argTypes())
+}
+
+func TestRedisEventQueue(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	job, err := json.Marshal(&EventJob{ID: "1", Node: "node1", Event: "render", Args: []interface{}{"scene"}, NeedAck: true})
+	must.NoError(err)
+
+	var (
+		lock     sync.Mutex
+		commands [][]string
+		popped   bool
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		lock.Lock()
+		defer lock.Unlock()
+
+		commands = append(commands, cmd)
+
+		switch cmd[0] {
+		case "BRPOP":
+			if popped {
+				return "*-1\r\n"
+			}
+			popped = true
+			return respArray("socket.io-jobs", string(job))
+		default:
+			return ":1\r\n"
+		}
+	})
+
+	queue := NewRedisEventQueue(&RedisAdapterOptions{Addr: addr})
+	must.NoError(queue.Enqueue(&EventJob{ID: "2", Event: "render"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = queue.Work(ctx, func(job *EventJob) ([]interface{}, error) {
+		defer cancel()
+
+		should.Equal("render", job.Event)
+		should.Equal([]interface{}{"scene"}, job.Args)
+		return []interface{}{"done"}, nil
+	})
+	must.NoError(err)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	must.Len(commands, 3)
+	should.Equal([]string{"LPUSH", "socket.io-jobs"}, commands[0][:2])
+	should.Equal([]string{"BRPOP", "socket.io-jobs", "1"}, commands[1])
+	should.Equal([]string{"PUBLISH", "socket.io-results#node1", `{"id":"1","args":["done"]}`}, commands[2])
+}
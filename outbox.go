@@ -0,0 +1,424 @@
+package socketio
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultOutboxInterval  = time.Second
+	defaultOutboxBatchSize = 100
+	defaultOutboxTable     = "socketio_outbox"
+)
+
+// OutboxMessage is emit kept in outbox until it's published, see Server.StartOutbox.
+type OutboxMessage struct {
+	ID        string
+	Namespace string
+	// Room of the emit, it's broadcast to the namespace when it's empty.
+	Room  string
+	Event string
+	// Args are encoded in JSON by stores, so they're published as decoded JSON values.
+	Args      []interface{}
+	CreatedAt time.Time
+}
+
+// NewOutboxMessage returns message of emit of event with args to room of namespace,
+// empty room broadcasts it to the namespace.
+func NewOutboxMessage(namespace, room, event string, args ...interface{}) OutboxMessage {
+	return OutboxMessage{
+		ID:        newV4UUID(),
+		Namespace: namespace,
+		Room:      room,
+		Event:     event,
+		Args:      args,
+		CreatedAt: time.Now(),
+	}
+}
+
+// OutboxStore keeps emits which are pending until they're published. Application adds them
+// in its own transaction by method of the store, e.g. SQLOutbox.Add, so emits of transaction
+// which is rolled back are never published.
+type OutboxStore interface {
+	// Pending gives at most limit messages which aren't published, oldest first.
+	Pending(limit int) ([]OutboxMessage, error)
+	// MarkPublished removes messages which are published.
+	MarkPublished(ids []string) error
+}
+
+// OutboxOptions configures relay of outbox, see Server.StartOutbox.
+type OutboxOptions struct {
+	// Interval is how often store is polled for pending messages, default is 1 second.
+	// OutboxRelay.Notify polls it right away, e.g. after commit.
+	Interval time.Duration
+
+	// BatchSize is maximum number of messages published at once, default is 100.
+	BatchSize int
+}
+
+func (o *OutboxOptions) getInterval() time.Duration {
+	if o == nil || o.Interval <= 0 {
+		return defaultOutboxInterval
+	}
+
+	return o.Interval
+}
+
+func (o *OutboxOptions) getBatchSize() int {
+	if o == nil || o.BatchSize <= 0 {
+		return defaultOutboxBatchSize
+	}
+
+	return o.BatchSize
+}
+
+// OutboxRelay publishes pending messages of outbox.
+type OutboxRelay struct {
+	server    *Server
+	store     OutboxStore
+	interval  time.Duration
+	batchSize int
+
+	notify chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// StartOutbox starts relay which publishes pending messages of store through the adapter, like
+// BroadcastToRoom or BroadcastToNamespace, and then removes them from store. Messages are
+// published at least once, a message may be published again when relay stops before it's
+// removed, unless EnableBroadcastDedup drops it by its ID. Message which can't be emitted, e.g. to namespace which doesn't exist, is logged
+// and removed. Relay runs until Stop or until server is closed.
+func (s *Server) StartOutbox(store OutboxStore, opts *OutboxOptions) *OutboxRelay {
+	r := &OutboxRelay{
+		server:    s,
+		store:     store,
+		interval:  opts.getInterval(),
+		batchSize: opts.getBatchSize(),
+		notify:    make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	s.OnServerShutdownBegin(r.Stop)
+
+	go r.run()
+
+	return r
+}
+
+// Notify makes relay poll store right away, e.g. after transaction which added messages commits.
+func (r *OutboxRelay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops relay and waits for batch in flight.
+func (r *OutboxRelay) Stop() {
+	r.once.Do(func() {
+		close(r.quit)
+	})
+
+	<-r.done
+}
+
+func (r *OutboxRelay) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// full batch means more messages may be pending
+		for r.publish() == r.batchSize {
+			select {
+			case <-r.quit:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		case <-r.notify:
+		}
+	}
+}
+
+// publish publishes batch of pending messages, it gives size of the batch.
+func (r *OutboxRelay) publish() int {
+	messages, err := r.store.Pending(r.batchSize)
+	if err != nil {
+		logger.Error("load pending outbox messages:", err)
+		return 0
+	}
+
+	if len(messages) == 0 {
+		return 0
+	}
+
+	ids := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		if err := r.emit(msg); err != nil {
+			logger.Error("publish outbox message "+msg.ID+":", err)
+		}
+
+		ids = append(ids, msg.ID)
+	}
+
+	if err := r.store.MarkPublished(ids); err != nil {
+		logger.Error("mark outbox messages published:", err)
+		return 0
+	}
+
+	return len(messages)
+}
+
+func (r *OutboxRelay) emit(msg OutboxMessage) error {
+	if !r.server.firstBroadcast(msg.Namespace, msg.ID) {
+		return nil
+	}
+
+	if msg.Room != "" {
+		return r.server.EmitToRoom(msg.Namespace, msg.Room, msg.Event, msg.Args...)
+	}
+
+	if !r.server.BroadcastToNamespace(msg.Namespace, msg.Event, msg.Args...) {
+		return fmt.Errorf("namespace %q doesn't exist", msg.Namespace)
+	}
+
+	return nil
+}
+
+// SQLOutboxOptions configures SQLOutbox.
+type SQLOutboxOptions struct {
+	// Table keeps the messages, default is "socketio_outbox".
+	Table string
+
+	// DollarPlaceholders uses $1, $2... placeholders, e.g. for PostgreSQL, instead of ?.
+	DollarPlaceholders bool
+}
+
+func (o *SQLOutboxOptions) getTable() string {
+	if o == nil || o.Table == "" {
+		return defaultOutboxTable
+	}
+
+	return o.Table
+}
+
+func (o *SQLOutboxOptions) getDollarPlaceholders() bool {
+	return o != nil && o.DollarPlaceholders
+}
+
+// SQLOutbox is OutboxStore in SQL table, which is created by application like:
+//
+//	CREATE TABLE socketio_outbox (
+//		id         VARCHAR(36) PRIMARY KEY,
+//		namespace  VARCHAR(255) NOT NULL,
+//		room       VARCHAR(255) NOT NULL,
+//		event      VARCHAR(255) NOT NULL,
+//		args       TEXT NOT NULL,
+//		created_at BIGINT NOT NULL
+//	)
+type SQLOutbox struct {
+	db     *sql.DB
+	table  string
+	dollar bool
+}
+
+// NewSQLOutbox returns OutboxStore in table of db.
+func NewSQLOutbox(db *sql.DB, opts *SQLOutboxOptions) *SQLOutbox {
+	return &SQLOutbox{
+		db:     db,
+		table:  opts.getTable(),
+		dollar: opts.getDollarPlaceholders(),
+	}
+}
+
+// Add inserts message in transaction of application, it's published once tx commits.
+func (o *SQLOutbox) Add(tx *sql.Tx, msg OutboxMessage) error {
+	args, err := json.Marshal(msg.Args)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (id, namespace, room, event, args, created_at) VALUES (%s)",
+		o.table, o.placeholders(6))
+	_, err = tx.Exec(query, msg.ID, msg.Namespace, msg.Room, msg.Event, string(args), msg.CreatedAt.UnixMilli())
+
+	return err
+}
+
+func (o *SQLOutbox) Pending(limit int) ([]OutboxMessage, error) {
+	query := fmt.Sprintf("SELECT id, namespace, room, event, args, created_at FROM %s ORDER BY created_at, id LIMIT %d",
+		o.table, limit)
+	rows, err := o.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var (
+			msg       OutboxMessage
+			args      string
+			createdAt int64
+		)
+		if err = rows.Scan(&msg.ID, &msg.Namespace, &msg.Room, &msg.Event, &args, &createdAt); err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal([]byte(args), &msg.Args); err != nil {
+			return nil, err
+		}
+		msg.CreatedAt = time.UnixMilli(createdAt)
+
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+func (o *SQLOutbox) MarkPublished(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", o.table, o.placeholders(len(ids)))
+	_, err := o.db.Exec(query, args...)
+
+	return err
+}
+
+// placeholders gives placeholders of n arguments.
+func (o *SQLOutbox) placeholders(n int) string {
+	ret := make([]string, n)
+	for i := range ret {
+		if o.dollar {
+			ret[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			ret[i] = "?"
+		}
+	}
+
+	return strings.Join(ret, ", ")
+}
+
+// RedisOutbox is OutboxStore in redis, messages are kept in hash ordered by sorted set.
+type RedisOutbox struct {
+	opts *RedisAdapterOptions
+}
+
+// NewRedisOutbox returns OutboxStore in redis of opts.
+func NewRedisOutbox(opts *RedisAdapterOptions) *RedisOutbox {
+	return &RedisOutbox{opts: getOptions(opts)}
+}
+
+func (o *RedisOutbox) ordersKey() string {
+	return fmt.Sprintf("%s-outbox", o.opts.Prefix)
+}
+
+func (o *RedisOutbox) messagesKey() string {
+	return fmt.Sprintf("%s-outbox#messages", o.opts.Prefix)
+}
+
+// Add queues commands which add message on conn, they're sent with transaction of application,
+// e.g. between its MULTI and EXEC.
+func (o *RedisOutbox) Add(conn redis.Conn, msg OutboxMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if err = conn.Send("HSET", o.messagesKey(), msg.ID, data); err != nil {
+		return err
+	}
+
+	return conn.Send("ZADD", o.ordersKey(), msg.CreatedAt.UnixMilli(), msg.ID)
+}
+
+func (o *RedisOutbox) Pending(limit int) ([]OutboxMessage, error) {
+	conn, err := o.opts.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ids, err := redis.Values(conn.Do("ZRANGE", o.ordersKey(), 0, limit-1))
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	values, err := redis.ByteSlices(conn.Do("HMGET", append([]interface{}{o.messagesKey()}, ids...)...))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]OutboxMessage, 0, len(values))
+	for _, data := range values {
+		// message without data was removed meanwhile
+		if data == nil {
+			continue
+		}
+
+		var msg OutboxMessage
+		if err = json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+func (o *RedisOutbox) MarkPublished(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	conn, err := o.opts.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	if err = conn.Send("MULTI"); err != nil {
+		return err
+	}
+	if err = conn.Send("ZREM", append([]interface{}{o.ordersKey()}, args...)...); err != nil {
+		return err
+	}
+	if err = conn.Send("HDEL", append([]interface{}{o.messagesKey()}, args...)...); err != nil {
+		return err
+	}
+
+	_, err = conn.Do("EXEC")
+
+	return err
+}
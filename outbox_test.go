@@ -0,0 +1,111 @@
+package socketio
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// memoryOutbox is OutboxStore which commits messages of transactions in memory.
+type memoryOutbox struct {
+	mu       sync.Mutex
+	messages []OutboxMessage
+	marked   int
+}
+
+func (o *memoryOutbox) commit(messages ...OutboxMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, messages...)
+}
+
+func (o *memoryOutbox) Pending(limit int) ([]OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.messages) < limit {
+		limit = len(o.messages)
+	}
+
+	return append([]OutboxMessage(nil), o.messages[:limit]...), nil
+}
+
+func (o *memoryOutbox) MarkPublished(ids []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	published := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	pending := o.messages[:0]
+	for _, msg := range o.messages {
+		if !published[msg.ID] {
+			pending = append(pending, msg)
+		}
+	}
+	o.messages = pending
+	o.marked += len(ids)
+
+	return nil
+}
+
+func (o *memoryOutbox) pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.messages)
+}
+
+func TestOutboxRelay(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	recorder := newLockedRecorder("1", "/", nil)
+	server.JoinRoom("/", "lobby", recorder)
+
+	store := &memoryOutbox{}
+	relay := server.StartOutbox(store, &OutboxOptions{Interval: time.Hour, BatchSize: 2})
+	defer relay.Stop()
+
+	// rolled back transaction never reaches the store
+	store.commit(
+		NewOutboxMessage("/", "lobby", "first"),
+		NewOutboxMessage("/", "", "second"),
+		NewOutboxMessage("/missing", "lobby", "dropped"),
+		NewOutboxMessage("/", "lobby", "third"),
+	)
+	relay.Notify()
+
+	must.Eventually(func() bool {
+		return store.pending() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	should.Equal([]string{"first", "second", "third"}, recorder.received())
+	should.Equal(4, store.marked)
+
+	relay.Stop()
+	store.commit(NewOutboxMessage("/", "lobby", "after stop"))
+	relay.Notify()
+	should.Equal(1, store.pending())
+}
+
+func TestSQLOutboxPlaceholders(t *testing.T) {
+	should := assert.New(t)
+
+	should.Equal("?, ?, ?", NewSQLOutbox(nil, nil).placeholders(3))
+	should.Equal("$1, $2, $3", NewSQLOutbox(nil, &SQLOutboxOptions{DollarPlaceholders: true}).placeholders(3))
+	should.Equal(defaultOutboxTable, NewSQLOutbox(nil, nil).table)
+}
@@ -0,0 +1,16 @@
+package socketio
+
+import "github.com/thisismz/go-socket.io/parser"
+
+// SetParser sets parser of packets of connections, e.g. parser.MsgPack or parser.Protobuf instead
+// of the default JSON parser, so Go servers and clients skip JSON on the wire. Parser isn't negotiated, clients
+// must use the same parser, see ClientOptions.Parser. Nil restores the default parser.
+func (s *Server) SetParser(p parser.Parser) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.parser = p
+
+	return nil
+}
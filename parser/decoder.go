@@ -36,6 +36,42 @@ type Decoder struct {
 
 	bufferCount uint64
 	isEvent     bool
+	// isSingle is set for CONNECT and CONNECT_ERROR packets, whose payload is a single value.
+	isSingle bool
+
+	// size is count of bytes read of last packet, see LastSize.
+	size int
+}
+
+// countingReader counts bytes read by decoder from packet.
+type countingReader struct {
+	byteReader
+	n *int
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.byteReader.Read(p)
+	*r.n += n
+
+	return n, err
+}
+
+func (r countingReader) ReadByte() (byte, error) {
+	b, err := r.byteReader.ReadByte()
+	if err == nil {
+		*r.n++
+	}
+
+	return b, err
+}
+
+func (r countingReader) UnreadByte() error {
+	err := r.byteReader.UnreadByte()
+	if err == nil {
+		*r.n--
+	}
+
+	return err
 }
 
 func NewDecoder(r FrameReader) *Decoder {
@@ -64,6 +100,29 @@ func (d *Decoder) DiscardLast() (err error) {
 	return err
 }
 
+// Discard skips rest of last packet, including its binary attachments, which DiscardLast leaves
+// in the stream.
+func (d *Decoder) Discard() error {
+	if err := d.DiscardLast(); err != nil {
+		return err
+	}
+
+	for ; d.bufferCount > 0; d.bufferCount-- {
+		ft, r, err := d.r.NextReader()
+		if err != nil {
+			return err
+		}
+
+		data, err := d.readBuffer(ft, r)
+		if err != nil {
+			return err
+		}
+		d.size += len(data)
+	}
+
+	return nil
+}
+
 func (d *Decoder) DecodeHeader(header *Header, event *string) error {
 	ft, r, err := d.r.NextReader()
 	if err != nil {
@@ -79,7 +138,8 @@ func (d *Decoder) DecodeHeader(header *Header, event *string) error {
 	if !ok {
 		br = bufio.NewReader(r)
 	}
-	d.packetReader = br
+	d.size = 0
+	d.packetReader = countingReader{byteReader: br, n: &d.size}
 
 	bufferCount, err := d.readHeader(header)
 	if err != nil {
@@ -92,6 +152,7 @@ func (d *Decoder) DecodeHeader(header *Header, event *string) error {
 	}
 
 	d.isEvent = header.Type == Event
+	d.isSingle = header.Type == Connect || header.Type == Error
 	if d.isEvent {
 		if err := d.readEvent(event); err != nil {
 			return err
@@ -102,10 +163,29 @@ func (d *Decoder) DecodeHeader(header *Header, event *string) error {
 }
 
 func (d *Decoder) DecodeArgs(types []reflect.Type) ([]reflect.Value, error) {
+	return d.decodeArgs(types, false)
+}
+
+// DecodeVariadicArgs decodes arguments like DecodeArgs, arguments after types are decoded into
+// values of any JSON type, so no argument of packet is dropped.
+func (d *Decoder) DecodeVariadicArgs(types []reflect.Type) ([]reflect.Value, error) {
+	return d.decodeArgs(types, true)
+}
+
+func (d *Decoder) decodeArgs(types []reflect.Type, variadic bool) ([]reflect.Value, error) {
 	r := d.packetReader.(io.Reader)
 	if d.isEvent {
 		r = io.MultiReader(strings.NewReader("["), r)
 	}
+	if d.isSingle {
+		// the value is the only argument, peers which send it in a list are accepted too
+		if b, err := d.packetReader.ReadByte(); err == nil {
+			_ = d.packetReader.UnreadByte()
+			if b != '[' {
+				r = io.MultiReader(strings.NewReader("["), r, strings.NewReader("]"))
+			}
+		}
+	}
 
 	ret := make([]reflect.Value, len(types))
 	values := make([]interface{}, len(types))
@@ -137,21 +217,31 @@ func (d *Decoder) DecodeArgs(types []reflect.Type) ([]reflect.Value, error) {
 		}
 	}
 
-	buffers := make([]Buffer, d.bufferCount)
-	for i := range buffers {
+	if variadic {
+		for i := len(types); i < len(values); i++ {
+			ret = append(ret, reflect.ValueOf(&values[i]).Elem())
+		}
+	}
+
+	// buffer count comes from peer, so buffers are allocated as they're read
+	var buffers []Buffer
+	for i := uint64(0); i < d.bufferCount; i++ {
 		ft, r, err := d.r.NextReader()
 		if err != nil {
 			return nil, err
 		}
 
-		buffers[i].Data, err = d.readBuffer(ft, r)
+		data, err := d.readBuffer(ft, r)
 		if err != nil {
 			return nil, err
 		}
+		d.size += len(data)
+
+		buffers = append(buffers, Buffer{Data: data})
 	}
 
 	for i := range ret {
-		if err := d.detachBuffer(ret[i], buffers); err != nil {
+		if err := detachBuffer(ret[i], buffers); err != nil {
 			return nil, err
 		}
 	}
@@ -159,6 +249,11 @@ func (d *Decoder) DecodeArgs(types []reflect.Type) ([]reflect.Value, error) {
 	return ret, nil
 }
 
+// LastSize returns size in bytes of last decoded packet, including its binary attachments.
+func (d *Decoder) LastSize() int {
+	return d.size
+}
+
 func (d *Decoder) readUint64FromText(r byteReader) (uint64, bool, error) {
 	var ret uint64
 	var hasRead bool
@@ -342,7 +437,7 @@ func (d *Decoder) readBuffer(ft session.FrameType, r io.ReadCloser) ([]byte, err
 	return ioutil.ReadAll(r)
 }
 
-func (d *Decoder) detachBuffer(v reflect.Value, buffers []Buffer) error {
+func detachBuffer(v reflect.Value, buffers []Buffer) error {
 	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
 		v = v.Elem()
 	}
@@ -355,26 +450,29 @@ func (d *Decoder) detachBuffer(v reflect.Value, buffers []Buffer) error {
 			}
 			buffer := v.Addr().Interface().(*Buffer)
 			if buffer.isBinary {
+				if buffer.num >= uint64(len(buffers)) {
+					return errInvalidBufferNum
+				}
 				*buffer = buffers[buffer.num]
 			}
 			return nil
 		}
 		for i := 0; i < v.NumField(); i++ {
-			if err := d.detachBuffer(v.Field(i), buffers); err != nil {
+			if err := detachBuffer(v.Field(i), buffers); err != nil {
 				return err
 			}
 		}
 
 	case reflect.Map:
 		for _, key := range v.MapKeys() {
-			if err := d.detachBuffer(v.MapIndex(key), buffers); err != nil {
+			if err := detachBuffer(v.MapIndex(key), buffers); err != nil {
 				return err
 			}
 		}
 
 	case reflect.Array, reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
-			if err := d.detachBuffer(v.Index(i), buffers); err != nil {
+			if err := detachBuffer(v.Index(i), buffers); err != nil {
 				return err
 			}
 		}
@@ -80,6 +80,104 @@ func TestDecoder(t *testing.T) {
 			}
 
 			should.Equal(test.Var, vars)
+
+			size := 0
+			for _, data := range test.Data {
+				size += len(data)
+			}
+			should.Equal(size, decoder.LastSize())
 		})
 	}
 }
+
+func BenchmarkDecoder(b *testing.B) {
+	data := [][]byte{[]byte("2/bench,[\"msg\",{\"id\":1,\"text\":\"hello\"}]\n")}
+	types := []reflect.Type{reflect.TypeOf(map[string]interface{}{})}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		decoder := NewDecoder(&fakeReader{data: data})
+
+		var header Header
+		var event string
+
+		if err := decoder.DecodeHeader(&header, &event); err != nil {
+			b.Error(err)
+		}
+
+		if _, err := decoder.DecodeArgs(types); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func TestDecoderDiscard(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	r := fakeReader{data: [][]byte{
+		[]byte(`61-/chat,7[{"_placeholder":true,"num":0}]`),
+		{1, 2, 3},
+	}}
+	decoder := NewDecoder(&r)
+
+	var header Header
+	var event string
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Ack, ID: 7, NeedAck: true, Namespace: "/chat"}, header)
+
+	must.NoError(decoder.Discard())
+
+	// attachment is skipped, so no frames are left
+	should.Equal(io.EOF, decoder.DecodeHeader(&header, &event))
+}
+
+func TestDecoderVariadicArgs(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	decoder := NewDecoder(&fakeReader{data: [][]byte{[]byte(`37["ok",2,{"n":1}]`)}})
+
+	var header Header
+	var event string
+	must.NoError(decoder.DecodeHeader(&header, &event))
+
+	args, err := decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf("")})
+	must.NoError(err)
+	must.Len(args, 3)
+	should.Equal("ok", args[0].Interface())
+	should.Equal(float64(2), args[1].Interface())
+	should.Equal(map[string]interface{}{"n": float64(1)}, args[2].Interface())
+
+	decoder = NewDecoder(&fakeReader{data: [][]byte{[]byte(`2["event","ok"]`)}})
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal("event", event)
+
+	args, err = decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf(""), reflect.TypeOf(0)})
+	must.NoError(err)
+	must.Len(args, 2, "missing args are zero values")
+	should.Equal("ok", args[0].Interface())
+	should.Equal(0, args[1].Interface())
+}
+
+func TestDecoderSingleValue(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// payloads of CONNECT and CONNECT_ERROR packets of socket.io protocol 5 aren't lists
+	authType := []reflect.Type{reflect.TypeOf(map[string]interface{}{})}
+	for _, data := range []string{`0{"token":"t"}`, `0/chat,[{"token":"t"}]`, `4{"token":"t"}`} {
+		decoder := NewDecoder(&fakeReader{data: [][]byte{[]byte(data)}})
+
+		var header Header
+		var event string
+		must.NoError(decoder.DecodeHeader(&header, &event), data)
+
+		args, err := decoder.DecodeArgs(authType)
+		must.NoError(err, data)
+		must.Len(args, 1, data)
+		should.Equal(map[string]interface{}{"token": "t"}, args[0].Interface(), data)
+	}
+}
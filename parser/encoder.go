@@ -16,6 +16,25 @@ type FrameWriter interface {
 
 type Encoder struct {
 	w FrameWriter
+
+	// size is count of bytes written of last packet, see LastSize.
+	size int
+
+	// streaming is set by SetStreaming.
+	streaming bool
+}
+
+// countingWriter counts bytes written by encoder to frame.
+type countingWriter struct {
+	io.WriteCloser
+	n *int
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	*w.n += n
+
+	return n, err
 }
 
 func NewEncoder(w FrameWriter) *Encoder {
@@ -25,6 +44,8 @@ func NewEncoder(w FrameWriter) *Encoder {
 }
 
 func (e *Encoder) Encode(h Header, args ...interface{}) (err error) {
+	e.size = 0
+
 	var w io.WriteCloser
 	w, err = e.w.NextWriter(session.TEXT)
 	if err != nil {
@@ -32,6 +53,7 @@ func (e *Encoder) Encode(h Header, args ...interface{}) (err error) {
 
 		return
 	}
+	w = countingWriter{WriteCloser: w, n: &e.size}
 
 	var buffers [][]byte
 	buffers, err = e.writePacket(w, h, args)
@@ -48,6 +70,7 @@ func (e *Encoder) Encode(h Header, args ...interface{}) (err error) {
 
 			return
 		}
+		w = countingWriter{WriteCloser: w, n: &e.size}
 
 		err = e.writeBuffer(w, b)
 		if err != nil {
@@ -60,6 +83,11 @@ func (e *Encoder) Encode(h Header, args ...interface{}) (err error) {
 	return
 }
 
+// LastSize returns size in bytes of last encoded packet, including its binary attachments.
+func (e *Encoder) LastSize() int {
+	return e.size
+}
+
 type byteWriter interface {
 	io.Writer
 	WriteByte(byte) error
@@ -82,7 +110,7 @@ func (e *Encoder) writePacket(w io.WriteCloser, h Header, args []interface{}) ([
 	}
 
 	max := uint64(0)
-	buffers, err := e.attachBuffer(reflect.ValueOf(args), &max)
+	buffers, err := attachBuffer(reflect.ValueOf(args), &max)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +150,12 @@ func (e *Encoder) writePacket(w io.WriteCloser, h Header, args []interface{}) ([
 	}
 
 	if len(args) > 0 {
-		if err := json.NewEncoder(bw).Encode(args[0]); err != nil {
+		if e.streaming {
+			err = writeJSON(bw, args[0])
+		} else {
+			err = json.NewEncoder(bw).Encode(args[0])
+		}
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -152,7 +185,7 @@ func (e *Encoder) writeUint64(w byteWriter, i uint64) error {
 	return nil
 }
 
-func (e *Encoder) attachBuffer(v reflect.Value, index *uint64) ([][]byte, error) {
+func attachBuffer(v reflect.Value, index *uint64) ([][]byte, error) {
 	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
 		v = v.Elem()
 	}
@@ -171,7 +204,7 @@ func (e *Encoder) attachBuffer(v reflect.Value, index *uint64) ([][]byte, error)
 			*index++
 		} else {
 			for i := 0; i < v.NumField(); i++ {
-				b, err := e.attachBuffer(v.Field(i), index)
+				b, err := attachBuffer(v.Field(i), index)
 				if err != nil {
 					return nil, err
 				}
@@ -181,7 +214,7 @@ func (e *Encoder) attachBuffer(v reflect.Value, index *uint64) ([][]byte, error)
 
 	case reflect.Array, reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
-			b, err := e.attachBuffer(v.Index(i), index)
+			b, err := attachBuffer(v.Index(i), index)
 			if err != nil {
 				return nil, err
 			}
@@ -191,7 +224,7 @@ func (e *Encoder) attachBuffer(v reflect.Value, index *uint64) ([][]byte, error)
 
 	case reflect.Map:
 		for _, key := range v.MapKeys() {
-			b, err := e.attachBuffer(v.MapIndex(key), index)
+			b, err := attachBuffer(v.MapIndex(key), index)
 			if err != nil {
 				return nil, err
 			}
@@ -70,6 +70,12 @@ func TestEncoder(t *testing.T) {
 				should.Equal(session.BINARY, w.types[i])
 				should.Equal(test.Data[i], w.data[i].Bytes())
 			}
+
+			size := 0
+			for _, data := range w.data {
+				size += data.Len()
+			}
+			should.Equal(size, encoder.LastSize())
 		})
 	}
 }
@@ -105,15 +111,13 @@ func TestAttachBuffer(t *testing.T) {
 		}, 1, [][]byte{{1, 2}}},
 	}
 
-	e := Encoder{}
-
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			should := assert.New(t)
 			must := require.New(t)
 
 			index := uint64(0)
-			b, err := e.attachBuffer(reflect.ValueOf(test.data), &index)
+			b, err := attachBuffer(reflect.ValueOf(test.data), &index)
 
 			must.NoError(err)
 
@@ -122,3 +126,43 @@ func TestAttachBuffer(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkEncoder(b *testing.B) {
+	w := fakeWriter{}
+	encoder := NewEncoder(&w)
+
+	header := Header{Type: Event, Namespace: "/bench"}
+	args := []interface{}{"msg", map[string]interface{}{"id": 1, "text": "hello"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := encoder.Encode(header, args); err != nil {
+			b.Error(err)
+		}
+
+		w.types = w.types[:0]
+		w.data = w.data[:0]
+	}
+}
+
+func BenchmarkEncoderBinary(b *testing.B) {
+	w := fakeWriter{}
+	encoder := NewEncoder(&w)
+
+	header := Header{Type: Event, Namespace: "/bench"}
+	args := []interface{}{"msg", &Buffer{Data: make([]byte, 1024)}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := encoder.Encode(header, args); err != nil {
+			b.Error(err)
+		}
+
+		w.types = w.types[:0]
+		w.data = w.data[:0]
+	}
+}
@@ -10,4 +10,6 @@ var (
 	errInvalidFirstPacketType = errors.New("first packet should be text frame")
 
 	errFailedBufferAddress = errors.New("can't get Buffer address")
+
+	errInvalidBufferNum = errors.New("buffer placeholder refers to missing attachment")
 )
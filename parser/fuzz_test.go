@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+)
+
+func FuzzDecoder(f *testing.F) {
+	for _, test := range tests {
+		var attachment []byte
+		if len(test.Data) > 1 {
+			attachment = test.Data[1]
+		}
+		f.Add(test.Data[0], attachment)
+	}
+	// attachments which peer announces, but doesn't send
+	f.Add([]byte(`599999999999-["a",{"_placeholder":true,"num":0}]`), []byte{1})
+	f.Add([]byte(`51-["a",{"_placeholder":true,"num":7}]`), []byte{1})
+
+	anyType := reflect.TypeOf((*interface{})(nil)).Elem()
+	types := []reflect.Type{reflect.TypeOf(""), anyType, reflect.TypeOf(&Buffer{}), anyType}
+
+	f.Fuzz(func(t *testing.T, text, attachment []byte) {
+		r := fakeReader{data: [][]byte{text, attachment}}
+		decoder := NewDecoder(&r)
+
+		defer func() {
+			_ = decoder.DiscardLast()
+			_ = decoder.Close()
+		}()
+
+		var header Header
+		var event string
+
+		if err := decoder.DecodeHeader(&header, &event); err != nil {
+			return
+		}
+
+		_, _ = decoder.DecodeArgs(types)
+	})
+}
+
+func FuzzMsgpackDecoder(f *testing.F) {
+	addBinarySeeds(f, MsgPack)
+	// packets of socket.io-msgpack-parser, see TestMsgPackParserDecode
+	f.Add([]byte("\x84\xa4type\x02\xa3nsp\xa9/chat?x=1\xa4data\x93\xa3msg\x01\x81\xa1a\xc3\xa2id\x03"))
+	f.Add([]byte("\x83\xa4type\x00\xa3nsp\xa1/\xa4data\x81\xa5token\xa1t"))
+	// lengths which peer announces, but doesn't send
+	f.Add([]byte("\x83\xa4type\x02\xa3nsp\xa1/\xa4data\xdd\xff\xff\xff\xff"))
+	// data nested deeper than allowed
+	f.Add([]byte("\x83\xa4type\x02\xa3nsp\xa1/\xa4data\x92\xa1a" + strings.Repeat("\x91", maxValueDepth+1)))
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		fuzzBinaryDecoder(MsgPack, frame)
+	})
+}
+
+func FuzzProtobufDecoder(f *testing.F) {
+	addBinarySeeds(f, Protobuf)
+	// packets of protoc generated code of packet.proto, see TestProtobufParserDecode
+	f.Add([]byte("\x08\x02\x12\x09/chat?x=1\x18\x03\x22\x1a\x42\x18\x0a\x05\x32\x03msg\x0a\x02\x18\x02\x0a\x0b\x4a\x09\x0a\x07\x0a\x01a\x12\x02\x10\x01"))
+	f.Add([]byte("\x08\x00\x12\x01/\x22\x10\x4a\x0e\x0a\x0c\x0a\x05token\x12\x03\x32\x01t"))
+	// lengths which peer announces, but doesn't send
+	f.Add([]byte("\x08\x02\x12\x01/\x22\xff\xff\xff\xff\x0f"))
+	// data nested deeper than allowed
+	var nested interface{} = "a"
+	for i := 0; i <= maxValueDepth; i++ {
+		nested = []interface{}{nested}
+	}
+	data, err := appendProtoValue(nil, []interface{}{"a", nested}, nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(appendProtoBytes(appendProtoBytes(appendProtoVarint(nil, 1, uint64(Event)), 2, []byte("/")), 4, data))
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		fuzzBinaryDecoder(Protobuf, frame)
+	})
+}
+
+// addBinarySeeds adds packets of tests encoded by parser p as seeds.
+func addBinarySeeds(f *testing.F, p Parser) {
+	for _, test := range tests {
+		w := fakeWriter{}
+		v := test.Var
+		if test.Header.Type == Event {
+			v = append([]interface{}{test.Event}, test.Var...)
+		}
+
+		var err error
+		if v != nil {
+			err = p.NewEncoder(&w).Encode(test.Header, v)
+		} else {
+			err = p.NewEncoder(&w).Encode(test.Header)
+		}
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(w.data[0].Bytes())
+	}
+}
+
+// fuzzBinaryDecoder decodes frame with decoder of parser p, it must not panic.
+func fuzzBinaryDecoder(p Parser, frame []byte) {
+	decoder := p.NewDecoder(&framesReader{types: []session.FrameType{session.BINARY}, data: [][]byte{frame}})
+	defer func() {
+		_ = decoder.DiscardLast()
+		_ = decoder.Close()
+	}()
+
+	var header Header
+	var event string
+	if err := decoder.DecodeHeader(&header, &event); err != nil {
+		return
+	}
+
+	anyType := reflect.TypeOf((*interface{})(nil)).Elem()
+	_, _ = decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf(""), anyType, reflect.TypeOf(&Buffer{})})
+}
@@ -0,0 +1,397 @@
+package parser
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// maxValueDepth is maximum nesting of arrays and objects in values of peers, so decoding them
+// doesn't exhaust stack.
+const maxValueDepth = 128
+
+var (
+	errMsgpackShort = errors.New("msgpack: unexpected end of data")
+	errMsgpackDepth = fmt.Errorf("msgpack: values are nested deeper than %d", maxValueDepth)
+)
+
+// MarshalMsgpack encodes value like notepack.io used by node.js redis adapter and msgpack parser.
+// Buffers are encoded as binary. Values other than nil, bool, numbers, strings, bytes, buffers,
+// slices and maps are encoded as their JSON representation. Keys of maps are sorted, so encoding
+// is deterministic.
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	return appendMsgpack(nil, v, nil)
+}
+
+// appendMsgpack appends encoded value, placeholders of buffers found in JSON representation of
+// values are replaced by their attachments.
+func appendMsgpack(b []byte, v interface{}, attachments [][]byte) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []byte:
+		return appendMsgpackBytes(b, v), nil
+	case Buffer:
+		return appendMsgpackBytes(b, v.Data), nil
+	case *Buffer:
+		if v == nil {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgpackBytes(b, v.Data), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case []interface{}:
+		b = appendMsgpackLen(b, len(v), 0x90, 0xdc)
+		for _, item := range v {
+			var err error
+			if b, err = appendMsgpack(b, item, attachments); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		if data, ok := placeholderAttachment(v, attachments); ok {
+			return appendMsgpackBytes(b, data), nil
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b = appendMsgpackLen(b, len(v), 0x80, 0xde)
+		for _, key := range keys {
+			b = appendMsgpackString(b, key)
+
+			var err error
+			if b, err = appendMsgpack(b, v[key], attachments); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return binary.BigEndian.AppendUint64(append(b, 0xcf), rv.Uint()), nil
+		}
+		return appendMsgpackInt(b, int64(rv.Uint())), nil
+	}
+
+	// other values, like structs, are encoded as they're seen by JSON clients
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	return appendMsgpack(b, generic, attachments)
+}
+
+// placeholderAttachment gives attachment of buffer placeholder, see Buffer.
+func placeholderAttachment(v map[string]interface{}, attachments [][]byte) ([]byte, bool) {
+	if len(v) != 2 || v["_placeholder"] != true {
+		return nil, false
+	}
+
+	num, ok := v["num"].(float64)
+	if !ok || num < 0 || int(num) >= len(attachments) {
+		return nil, false
+	}
+
+	return attachments[int(num)], true
+}
+
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		return append(b, 0xd0, byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(n))
+	}
+
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(n))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+func appendMsgpackBytes(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, data...)
+}
+
+// appendMsgpackLen appends header of array or map, fix is header of its fixed form and wide
+// of its 16 bits form.
+func appendMsgpackLen(b []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, wide), uint16(n))
+	}
+
+	return binary.BigEndian.AppendUint32(append(b, wide+1), uint32(n))
+}
+
+// UnmarshalMsgpack decodes value into nil, bool, int64, uint64, float64, string, []byte,
+// []interface{} or map[string]interface{}.
+func UnmarshalMsgpack(data []byte) (interface{}, error) {
+	d := msgpackReader{data: data}
+
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(d.data) {
+		return nil, errors.New("msgpack: trailing data")
+	}
+
+	return v, nil
+}
+
+type msgpackReader struct {
+	data []byte
+	pos  int
+	// depth is nesting of array or object being read.
+	depth int
+}
+
+func (d *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+
+	return b, nil
+}
+
+func (d *msgpackReader) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+
+	return binary.BigEndian.Uint64(b), nil
+}
+
+func (d *msgpackReader) value() (interface{}, error) {
+	head, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+
+	c := head[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.object(int(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce:
+		n, err := d.uint(1 << (c - 0xcc))
+		return int64(n), err
+	case 0xcf:
+		n, err := d.uint(8)
+		if n <= math.MaxInt64 {
+			return int64(n), err
+		}
+		return n, err
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n))
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+// nest enters array or object, it fails when they're nested too deeply.
+func (d *msgpackReader) nest() error {
+	if d.depth >= maxValueDepth {
+		return errMsgpackDepth
+	}
+	d.depth++
+
+	return nil
+}
+
+func (d *msgpackReader) unnest() {
+	d.depth--
+}
+
+func (d *msgpackReader) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (d *msgpackReader) array(n int) (interface{}, error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	defer d.unnest()
+
+	// length comes from peer, so items are allocated as they're read
+	var items []interface{}
+	for i := 0; i < n; i++ {
+		item, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if items == nil {
+		items = []interface{}{}
+	}
+
+	return items, nil
+}
+
+func (d *msgpackReader) object(n int) (interface{}, error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	defer d.unnest()
+
+	m := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := key.(string); ok {
+			m[s] = value
+		} else {
+			m[fmt.Sprint(key)] = value
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+var errInvalidMsgpackPacket = errors.New("msgpack packet should be a map of type, nsp and data")
+
+// MsgPack is parser compatible with socket.io-msgpack-parser, each packet is one binary frame
+// of msgpack map {type, nsp, data, id}. Buffers are binary values of msgpack, so they aren't
+// sent as attachments. Arguments are decoded into handler types like JSON arguments of
+// Default parser, so handlers don't depend on parser.
+var MsgPack Parser = msgpackParser{}
+
+type msgpackParser struct{}
+
+func (msgpackParser) NewEncoder(w FrameWriter) PacketEncoder {
+	return &msgpackEncoder{w: w}
+}
+
+func (msgpackParser) NewDecoder(r FrameReader) PacketDecoder {
+	return &msgpackDecoder{r: r}
+}
+
+type msgpackEncoder struct {
+	w    FrameWriter
+	size int
+}
+
+func (e *msgpackEncoder) Encode(h Header, args ...interface{}) error {
+	e.size = 0
+
+	nsp := h.Namespace
+	if nsp == "" {
+		nsp = "/"
+	}
+	packet := map[string]interface{}{"type": int(h.Type), "nsp": nsp}
+	if h.NeedAck {
+		packet["id"] = h.ID
+	}
+
+	// buffers which are encoded through JSON, e.g. fields of structs, are replaced by
+	// placeholders, so they're attached like in Default parser
+	var attachments [][]byte
+	if len(args) > 0 {
+		var err error
+		var index uint64
+		if attachments, err = attachBuffer(reflect.ValueOf(args), &index); err != nil {
+			return err
+		}
+
+		packet["data"] = args[0]
+	}
+
+	data, err := appendMsgpack(nil, packet, attachments)
+	if err != nil {
+		return err
+	}
+
+	e.size, err = writeBinaryFrame(e.w, data)
+
+	return err
+}
+
+// writeBinaryFrame writes packet of parser which sends each packet as one binary frame.
+func writeBinaryFrame(fw FrameWriter, data []byte) (int, error) {
+	w, err := fw.NextWriter(session.BINARY)
+	if err != nil {
+		logger.Error("next writer session binary:", err)
+
+		return 0, err
+	}
+	defer func() {
+		if closeErr := w.Close(); closeErr != nil {
+			logger.Error("close writer:", closeErr)
+		}
+	}()
+
+	return w.Write(data)
+}
+
+func (e *msgpackEncoder) LastSize() int {
+	return e.size
+}
+
+type msgpackDecoder struct {
+	r FrameReader
+
+	// args are arguments of last packet until they're decoded or discarded.
+	args []interface{}
+	size int
+}
+
+func (d *msgpackDecoder) DecodeHeader(header *Header, event *string) error {
+	d.args = nil
+	d.size = 0
+
+	ft, r, err := d.r.NextReader()
+	if err != nil {
+		return err
+	}
+
+	data, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	d.size = len(data)
+
+	if ft != session.BINARY {
+		return errInvalidMsgpackPacket
+	}
+
+	v, err := UnmarshalMsgpack(data)
+	if err != nil {
+		return err
+	}
+
+	packet, ok := v.(map[string]interface{})
+	if !ok {
+		return errInvalidMsgpackPacket
+	}
+
+	typ, ok := packet["type"].(int64)
+	if !ok || typ < int64(Connect) || typ > int64(Error) {
+		return ErrInvalidPacketType
+	}
+	header.Type = Type(typ)
+
+	nsp, _ := packet["nsp"].(string)
+	if queryPos := strings.IndexByte(nsp, '?'); queryPos > -1 {
+		header.Query = nsp[queryPos+1:]
+		nsp = nsp[:queryPos]
+	}
+	if nsp != "/" {
+		header.Namespace = nsp
+	}
+
+	switch id := packet["id"].(type) {
+	case int64:
+		if id < 0 {
+			return errInvalidMsgpackPacket
+		}
+		header.ID, header.NeedAck = uint64(id), true
+	case uint64:
+		header.ID, header.NeedAck = id, true
+	}
+
+	switch payload := packet["data"].(type) {
+	case nil:
+	case []interface{}:
+		d.args = payload
+	default:
+		// e.g. auth of CONNECT packet
+		d.args = []interface{}{payload}
+	}
+
+	if header.Type == Event {
+		if len(d.args) == 0 {
+			return errInvalidMsgpackPacket
+		}
+
+		name, ok := d.args[0].(string)
+		if !ok {
+			return errInvalidMsgpackPacket
+		}
+
+		*event = name
+		d.args = d.args[1:]
+	}
+
+	return nil
+}
+
+func (d *msgpackDecoder) DecodeArgs(types []reflect.Type) ([]reflect.Value, error) {
+	return d.decodeArgs(types, false)
+}
+
+func (d *msgpackDecoder) DecodeVariadicArgs(types []reflect.Type) ([]reflect.Value, error) {
+	return d.decodeArgs(types, true)
+}
+
+func (d *msgpackDecoder) decodeArgs(types []reflect.Type, variadic bool) ([]reflect.Value, error) {
+	args := d.args
+	d.args = nil
+
+	return convertArgs(args, types, variadic)
+}
+
+// convertArgs stores decoded arguments of packet of binary parser into values of types, like
+// JSON arguments of Default parser are decoded.
+func convertArgs(args []interface{}, types []reflect.Type, variadic bool) ([]reflect.Value, error) {
+	ret := make([]reflect.Value, len(types))
+	for i, typ := range types {
+		if typ.Kind() == reflect.Ptr {
+			ret[i] = reflect.New(typ.Elem())
+		} else {
+			ret[i] = reflect.New(typ)
+		}
+
+		if i < len(args) {
+			if err := convertBinaryValue(args[i], ret[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		if typ.Kind() != reflect.Ptr {
+			ret[i] = ret[i].Elem()
+		}
+	}
+
+	if variadic {
+		for i := len(types); i < len(args); i++ {
+			v := reflect.New(reflect.TypeOf((*interface{})(nil)).Elem())
+			if err := convertBinaryValue(args[i], v); err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, v.Elem())
+		}
+	}
+
+	return ret, nil
+}
+
+func (d *msgpackDecoder) DiscardLast() error {
+	d.args = nil
+
+	return nil
+}
+
+func (d *msgpackDecoder) Discard() error {
+	return d.DiscardLast()
+}
+
+func (d *msgpackDecoder) LastSize() int {
+	return d.size
+}
+
+func (d *msgpackDecoder) Close() error {
+	return nil
+}
+
+// convertBinaryValue stores value decoded by binary parser, e.g. MsgPack, into pointer v like JSON
+// argument of Default parser is decoded, binary values are detached into buffers.
+func convertBinaryValue(value interface{}, v reflect.Value) error {
+	var buffers []Buffer
+	data, err := json.Marshal(detachBinary(value, &buffers))
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(data, v.Interface()); err != nil {
+		return err
+	}
+
+	return detachBuffer(v, buffers)
+}
+
+// detachBinary replaces binary values by buffer placeholders.
+func detachBinary(value interface{}, buffers *[]Buffer) interface{} {
+	switch value := value.(type) {
+	case []byte:
+		*buffers = append(*buffers, Buffer{Data: value})
+
+		return map[string]interface{}{"_placeholder": true, "num": len(*buffers) - 1}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = detachBinary(item, buffers)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = detachBinary(item, buffers)
+		}
+	}
+
+	return value
+}
+
+func readFrame(r io.ReadCloser) ([]byte, error) {
+	defer func() {
+		if err := r.Close(); err != nil {
+			logger.Error("close reader:", err)
+		}
+	}()
+
+	return ioutil.ReadAll(r)
+}
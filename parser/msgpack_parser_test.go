@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+)
+
+// framesReader reads frames written by fakeWriter.
+type framesReader struct {
+	types []session.FrameType
+	data  [][]byte
+}
+
+func (r *framesReader) NextReader() (session.FrameType, io.ReadCloser, error) {
+	if len(r.data) == 0 {
+		return 0, nil, io.EOF
+	}
+
+	ft, data := r.types[0], r.data[0]
+	r.types, r.data = r.types[1:], r.data[1:]
+
+	return ft, io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func newFramesReader(w *fakeWriter) *framesReader {
+	r := &framesReader{types: w.types}
+	for _, data := range w.data {
+		r.data = append(r.data, data.Bytes())
+	}
+
+	return r
+}
+
+func TestMsgPackParser(t *testing.T) {
+	// packets of Default parser are the same with MsgPack, but they're single binary frames
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			w := fakeWriter{}
+			encoder := MsgPack.NewEncoder(&w)
+			v := test.Var
+			if test.Header.Type == Event {
+				v = append([]interface{}{test.Event}, test.Var...)
+			}
+
+			var err error
+			if v != nil {
+				err = encoder.Encode(test.Header, v)
+			} else {
+				err = encoder.Encode(test.Header)
+			}
+			must.NoError(err)
+			must.Equal([]session.FrameType{session.BINARY}, w.types)
+			should.Equal(w.data[0].Len(), encoder.LastSize())
+
+			decoder := MsgPack.NewDecoder(newFramesReader(&w))
+
+			var header Header
+			var event string
+			must.NoError(decoder.DecodeHeader(&header, &event))
+			should.Equal(test.Header, header)
+			should.Equal(test.Event, event)
+			should.Equal(w.data[0].Len(), decoder.LastSize())
+
+			types := make([]reflect.Type, len(test.Var))
+			for i := range types {
+				types[i] = reflect.TypeOf(test.Var[i])
+			}
+			ret, err := decoder.DecodeArgs(types)
+			must.NoError(err)
+
+			must.Len(ret, len(test.Var))
+			for i, v := range test.Var {
+				// encoding marks buffers of test as attached
+				if buffer, ok := v.(*Buffer); ok {
+					should.Equal(buffer.Data, ret[i].Interface().(*Buffer).Data)
+					continue
+				}
+				should.Equal(v, ret[i].Interface())
+			}
+		})
+	}
+}
+
+func TestMsgPackParserBinary(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	type file struct {
+		Name string  `json:"name"`
+		Data *Buffer `json:"data"`
+	}
+
+	w := fakeWriter{}
+	encoder := MsgPack.NewEncoder(&w)
+	must.NoError(encoder.Encode(Header{Type: Event, ID: 7, NeedAck: true, Namespace: "/files"}, []interface{}{
+		"upload", &Buffer{Data: []byte{1, 2}}, &file{Name: "a.bin", Data: &Buffer{Data: []byte{3}}},
+	}))
+
+	// buffers are binary values of msgpack, including the ones of structs
+	decoded, err := UnmarshalMsgpack(w.data[0].Bytes())
+	must.NoError(err)
+	should.Equal(map[string]interface{}{
+		"type": int64(Event),
+		"nsp":  "/files",
+		"id":   int64(7),
+		"data": []interface{}{
+			"upload",
+			[]byte{1, 2},
+			map[string]interface{}{"name": "a.bin", "data": []byte{3}},
+		},
+	}, decoded)
+
+	decoder := MsgPack.NewDecoder(newFramesReader(&w))
+
+	var header Header
+	var event string
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Event, ID: 7, NeedAck: true, Namespace: "/files"}, header)
+	should.Equal("upload", event)
+
+	args, err := decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf(&Buffer{}), reflect.TypeOf(file{})})
+	must.NoError(err)
+	must.Len(args, 2)
+	should.Equal([]byte{1, 2}, args[0].Interface().(*Buffer).Data)
+	should.Equal("a.bin", args[1].Interface().(file).Name)
+	should.Equal([]byte{3}, args[1].Interface().(file).Data.Data)
+}
+
+func TestMsgPackParserDecode(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// {type: 2, nsp: "/chat?x=1", data: ["msg", 1, {a: true}], id: 3} and
+	// {type: 0, nsp: "/", data: {token: "t"}} encoded by socket.io-msgpack-parser
+	r := &framesReader{
+		types: []session.FrameType{session.BINARY, session.BINARY},
+		data: [][]byte{
+			[]byte("\x84\xa4type\x02\xa3nsp\xa9/chat?x=1\xa4data\x93\xa3msg\x01\x81\xa1a\xc3\xa2id\x03"),
+			[]byte("\x83\xa4type\x00\xa3nsp\xa1/\xa4data\x81\xa5token\xa1t"),
+		},
+	}
+	decoder := MsgPack.NewDecoder(r)
+
+	var header Header
+	var event string
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Event, ID: 3, NeedAck: true, Namespace: "/chat", Query: "x=1"}, header)
+	should.Equal("msg", event)
+
+	args, err := decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf(0)})
+	must.NoError(err)
+	must.Len(args, 2)
+	should.Equal(1, args[0].Interface())
+	should.Equal(map[string]interface{}{"a": true}, args[1].Interface())
+
+	header = Header{}
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Connect}, header)
+
+	args, err = decoder.DecodeArgs([]reflect.Type{reflect.TypeOf(map[string]interface{}{})})
+	must.NoError(err)
+	should.Equal(map[string]interface{}{"token": "t"}, args[0].Interface())
+
+	should.Equal(io.EOF, decoder.DecodeHeader(&header, &event))
+
+	for name, data := range map[string][]byte{
+		"not a map":     []byte("\x92\x02\xa1/"),
+		"unknown type":  []byte("\x82\xa4type\x05\xa3nsp\xa1/"),
+		"event no name": []byte("\x83\xa4type\x02\xa3nsp\xa1/\xa4data\x91\x01"),
+		"truncated":     []byte("\x83\xa4type\x02"),
+	} {
+		decoder = MsgPack.NewDecoder(&framesReader{types: []session.FrameType{session.BINARY}, data: [][]byte{data}})
+		should.Error(decoder.DecodeHeader(&header, &event), name)
+	}
+
+	decoder = MsgPack.NewDecoder(&framesReader{types: []session.FrameType{session.TEXT}, data: [][]byte{[]byte("2")}})
+	should.Error(decoder.DecodeHeader(&header, &event), "text frame")
+}
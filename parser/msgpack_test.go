@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	type point struct {
+		X int `json:"x"`
+	}
+
+	value := []interface{}{
+		nil, true, false,
+		0, 127, -32, -33, 255, 1 << 20, int64(math.MinInt64), uint64(math.MaxUint64),
+		1.5, float32(0.25),
+		"", "short", strings.Repeat("a", 300),
+		[]byte{1, 2, 3},
+		map[string]interface{}{"b": 1, "a": []interface{}{"x"}},
+		point{X: 7},
+		make([]interface{}, 20),
+	}
+
+	data, err := MarshalMsgpack(value)
+	must.NoError(err)
+
+	decoded, err := UnmarshalMsgpack(data)
+	must.NoError(err)
+
+	should.Equal([]interface{}{
+		nil, true, false,
+		int64(0), int64(127), int64(-32), int64(-33), int64(255), int64(1 << 20), int64(math.MinInt64), uint64(math.MaxUint64),
+		1.5, 0.25,
+		"", "short", strings.Repeat("a", 300),
+		[]byte{1, 2, 3},
+		map[string]interface{}{"b": int64(1), "a": []interface{}{"x"}},
+		map[string]interface{}{"x": 7.0},
+		make([]interface{}, 20),
+	}, decoded)
+}
+
+func TestMsgpackDecodeNode(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// ["uid", {type: 2, data: ["hi"], nsp: "/"}, {rooms: ["r"], except: [], flags: {}}]
+	// encoded by notepack.io
+	data := []byte("\x93\xa3uid\x83\xa4type\x02\xa4data\x91\xa2hi\xa3nsp\xa1/\x83\xa5rooms\x91\xa1r\xa6except\x90\xa5flags\x80")
+
+	decoded, err := UnmarshalMsgpack(data)
+	must.NoError(err)
+	should.Equal([]interface{}{
+		"uid",
+		map[string]interface{}{"type": int64(2), "data": []interface{}{"hi"}, "nsp": "/"},
+		map[string]interface{}{"rooms": []interface{}{"r"}, "except": []interface{}{}, "flags": map[string]interface{}{}},
+	}, decoded)
+
+	_, err = UnmarshalMsgpack(data[:len(data)-1])
+	should.Error(err)
+	_, err = UnmarshalMsgpack(append(data, 0))
+	should.Error(err)
+	_, err = UnmarshalMsgpack([]byte{0xc1})
+	should.Error(err)
+	_, err = UnmarshalMsgpack([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	should.Error(err)
+}
+
+func TestMsgpackDepth(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// arrays and maps nested as deep as allowed, then one level deeper
+	nested := strings.Repeat("\x91", maxValueDepth-1) + "\x80"
+	_, err := UnmarshalMsgpack([]byte(nested))
+	must.NoError(err)
+
+	_, err = UnmarshalMsgpack([]byte("\x91" + nested))
+	should.ErrorIs(err, errMsgpackDepth)
+	_, err = UnmarshalMsgpack([]byte(strings.Repeat("\x81\xa1a", maxValueDepth) + "\x90"))
+	should.ErrorIs(err, errMsgpackDepth)
+
+	// deep values of peers don't exhaust stack
+	_, err = UnmarshalMsgpack([]byte(strings.Repeat("\x91", 1<<20)))
+	should.ErrorIs(err, errMsgpackDepth)
+}
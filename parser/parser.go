@@ -0,0 +1,47 @@
+package parser
+
+import "reflect"
+
+// PacketEncoder writes packets into frames of connection.
+type PacketEncoder interface {
+	Encode(h Header, args ...interface{}) error
+	// LastSize returns size in bytes of last encoded packet, including its binary attachments.
+	LastSize() int
+}
+
+// PacketDecoder reads packets from frames of connection, header of packet is decoded first,
+// then its arguments or the rest of packet is discarded.
+type PacketDecoder interface {
+	DecodeHeader(header *Header, event *string) error
+	DecodeArgs(types []reflect.Type) ([]reflect.Value, error)
+	// DecodeVariadicArgs decodes arguments like DecodeArgs, arguments after types are decoded
+	// into values of any JSON type.
+	DecodeVariadicArgs(types []reflect.Type) ([]reflect.Value, error)
+	// DiscardLast skips rest of last packet, Discard skips its binary attachments as well.
+	DiscardLast() error
+	Discard() error
+	// LastSize returns size in bytes of last decoded packet, including its binary attachments.
+	LastSize() int
+	Close() error
+}
+
+// Parser encodes packets of connection, server and its clients must use the same parser, e.g.
+// Default, MsgPack or Protobuf.
+type Parser interface {
+	NewEncoder(w FrameWriter) PacketEncoder
+	NewDecoder(r FrameReader) PacketDecoder
+}
+
+// Default is parser of socket.io protocol, packets are text frames with JSON arguments
+// followed by binary frames of their buffers.
+var Default Parser = defaultParser{}
+
+type defaultParser struct{}
+
+func (defaultParser) NewEncoder(w FrameWriter) PacketEncoder {
+	return NewEncoder(w)
+}
+
+func (defaultParser) NewDecoder(r FrameReader) PacketDecoder {
+	return NewDecoder(r)
+}
@@ -0,0 +1,324 @@
+package parser
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var (
+	errProtobufShort = errors.New("protobuf: unexpected end of data")
+	errProtobufDepth = fmt.Errorf("protobuf: values are nested deeper than %d", maxValueDepth)
+)
+
+// wire types of protobuf
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// fields of Value message, see packet.proto
+const (
+	protoValueNull   = 1
+	protoValueBool   = 2
+	protoValueInt    = 3
+	protoValueUint   = 4
+	protoValueNumber = 5
+	protoValueString = 6
+	protoValueBinary = 7
+	protoValueList   = 8
+	protoValueObject = 9
+)
+
+// appendProtoValue appends body of Value message of v, placeholders of buffers found in JSON
+// representation of values are replaced by their attachments like in appendMsgpack.
+func appendProtoValue(b []byte, v interface{}, attachments [][]byte) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return appendProtoVarint(b, protoValueNull, 1), nil
+	case bool:
+		if v {
+			return appendProtoVarint(b, protoValueBool, 1), nil
+		}
+		return appendProtoVarint(b, protoValueBool, 0), nil
+	case string:
+		return appendProtoBytes(b, protoValueString, []byte(v)), nil
+	case []byte:
+		return appendProtoBytes(b, protoValueBinary, v), nil
+	case Buffer:
+		return appendProtoBytes(b, protoValueBinary, v.Data), nil
+	case *Buffer:
+		if v == nil {
+			return appendProtoVarint(b, protoValueNull, 1), nil
+		}
+		return appendProtoBytes(b, protoValueBinary, v.Data), nil
+	case float32:
+		return appendProtoDouble(b, protoValueNumber, float64(v)), nil
+	case float64:
+		return appendProtoDouble(b, protoValueNumber, v), nil
+	case []interface{}:
+		// List is {repeated Value values = 1}
+		var list []byte
+		for _, item := range v {
+			value, err := appendProtoValue(nil, item, attachments)
+			if err != nil {
+				return nil, err
+			}
+			list = appendProtoBytes(list, 1, value)
+		}
+		return appendProtoBytes(b, protoValueList, list), nil
+	case map[string]interface{}:
+		if data, ok := placeholderAttachment(v, attachments); ok {
+			return appendProtoBytes(b, protoValueBinary, data), nil
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// Object is {map<string, Value> fields = 1}, entries of map are {key = 1, value = 2}
+		var object []byte
+		for _, key := range keys {
+			value, err := appendProtoValue(nil, v[key], attachments)
+			if err != nil {
+				return nil, err
+			}
+			entry := appendProtoBytes(appendProtoBytes(nil, 1, []byte(key)), 2, value)
+			object = appendProtoBytes(object, 1, entry)
+		}
+		return appendProtoBytes(b, protoValueObject, object), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendProtoVarint(b, protoValueInt, zigzag(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return appendProtoVarint(b, protoValueUint, rv.Uint()), nil
+		}
+		return appendProtoVarint(b, protoValueInt, zigzag(int64(rv.Uint()))), nil
+	}
+
+	// other values, like structs, are encoded as they're seen by JSON clients
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	return appendProtoValue(b, generic, attachments)
+}
+
+func appendProtoTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendProtoVarint(b []byte, field int, n uint64) []byte {
+	return binary.AppendUvarint(appendProtoTag(b, field, protoVarint), n)
+}
+
+func appendProtoDouble(b []byte, field int, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(appendProtoTag(b, field, protoFixed64), math.Float64bits(f))
+}
+
+func appendProtoBytes(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, field, protoBytes), uint64(len(data)))
+
+	return append(b, data...)
+}
+
+// zigzag encodes n like sint64 of protobuf, so small negative numbers stay short.
+func zigzag(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+// protoReader reads fields of protobuf message.
+type protoReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *protoReader) done() bool {
+	return r.pos == len(r.data)
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	n, size := binary.Uvarint(r.data[r.pos:])
+	if size <= 0 {
+		return 0, errProtobufShort
+	}
+	r.pos += size
+
+	return n, nil
+}
+
+func (r *protoReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errProtobufShort
+	}
+
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+
+	return b, nil
+}
+
+// field reads next field, value of field is returned as number for varint and fixed wire types
+// and as data for length-delimited ones.
+func (r *protoReader) field() (field, wireType int, n uint64, data []byte, err error) {
+	tag, err := r.varint()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	field, wireType = int(tag>>3), int(tag&7)
+
+	switch wireType {
+	case protoVarint:
+		n, err = r.varint()
+	case protoFixed64:
+		var b []byte
+		if b, err = r.next(8); err == nil {
+			n = binary.LittleEndian.Uint64(b)
+		}
+	case protoFixed32:
+		var b []byte
+		if b, err = r.next(4); err == nil {
+			n = uint64(binary.LittleEndian.Uint32(b))
+		}
+	case protoBytes:
+		if n, err = r.varint(); err == nil {
+			data, err = r.next(n)
+		}
+	default:
+		err = fmt.Errorf("protobuf: unsupported wire type %d", wireType)
+	}
+
+	return field, wireType, n, data, err
+}
+
+// unmarshalProtoValue decodes body of Value message into nil, bool, int64, uint64, float64,
+// string, []byte, []interface{} or map[string]interface{}. Unknown fields are skipped, Value
+// without kind is nil. depth is nesting of lists and objects which contain the value, deeper
+// values than maxValueDepth are refused.
+func unmarshalProtoValue(data []byte, depth int) (interface{}, error) {
+	r := protoReader{data: data}
+
+	var value interface{}
+	for !r.done() {
+		field, wireType, n, b, err := r.field()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case field == protoValueNull && wireType == protoVarint:
+			value = nil
+		case field == protoValueBool && wireType == protoVarint:
+			value = n != 0
+		case field == protoValueInt && wireType == protoVarint:
+			value = int64(n>>1) ^ -int64(n&1)
+		case field == protoValueUint && wireType == protoVarint:
+			value = n
+		case field == protoValueNumber && wireType == protoFixed64:
+			value = math.Float64frombits(n)
+		case field == protoValueString && wireType == protoBytes:
+			value = string(b)
+		case field == protoValueBinary && wireType == protoBytes:
+			value = append([]byte(nil), b...)
+		case field == protoValueList && wireType == protoBytes:
+			if value, err = unmarshalProtoList(b, depth+1); err != nil {
+				return nil, err
+			}
+		case field == protoValueObject && wireType == protoBytes:
+			if value, err = unmarshalProtoObject(b, depth+1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return value, nil
+}
+
+func unmarshalProtoList(data []byte, depth int) ([]interface{}, error) {
+	if depth > maxValueDepth {
+		return nil, errProtobufDepth
+	}
+
+	r := protoReader{data: data}
+
+	items := []interface{}{}
+	for !r.done() {
+		field, wireType, _, b, err := r.field()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 || wireType != protoBytes {
+			continue
+		}
+
+		item, err := unmarshalProtoValue(b, depth)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func unmarshalProtoObject(data []byte, depth int) (map[string]interface{}, error) {
+	if depth > maxValueDepth {
+		return nil, errProtobufDepth
+	}
+
+	r := protoReader{data: data}
+
+	object := map[string]interface{}{}
+	for !r.done() {
+		field, wireType, _, entry, err := r.field()
+		if err != nil {
+			return nil, err
+		}
+		if field != 1 || wireType != protoBytes {
+			continue
+		}
+
+		var key string
+		var value interface{}
+		er := protoReader{data: entry}
+		for !er.done() {
+			field, wireType, _, b, err := er.field()
+			if err != nil {
+				return nil, err
+			}
+			if wireType != protoBytes {
+				continue
+			}
+
+			switch field {
+			case 1:
+				key = string(b)
+			case 2:
+				if value, err = unmarshalProtoValue(b, depth); err != nil {
+					return nil, err
+				}
+			}
+		}
+		object[key] = value
+	}
+
+	return object, nil
+}
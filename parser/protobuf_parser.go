@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+)
+
+var errInvalidProtobufPacket = errors.New("protobuf packet should be a message of type, nsp and data")
+
+// fields of Packet message, see packet.proto
+const (
+	protoPacketType = 1
+	protoPacketNsp  = 2
+	protoPacketID   = 3
+	protoPacketData = 4
+)
+
+// Protobuf is parser whose packets are single binary frames of Packet message of packet.proto,
+// so clients of other languages generate their codec from it. Data of packet is Value message,
+// which is like google.protobuf.Value with integers and binary values, so buffers aren't sent as
+// attachments. Arguments are decoded into handler types like JSON arguments of Default parser,
+// so handlers don't depend on parser.
+var Protobuf Parser = protobufParser{}
+
+type protobufParser struct{}
+
+func (protobufParser) NewEncoder(w FrameWriter) PacketEncoder {
+	return &protobufEncoder{w: w}
+}
+
+func (protobufParser) NewDecoder(r FrameReader) PacketDecoder {
+	return &protobufDecoder{r: r}
+}
+
+type protobufEncoder struct {
+	w    FrameWriter
+	size int
+}
+
+func (e *protobufEncoder) Encode(h Header, args ...interface{}) error {
+	e.size = 0
+
+	nsp := h.Namespace
+	if nsp == "" {
+		nsp = "/"
+	}
+
+	data := appendProtoVarint(nil, protoPacketType, uint64(h.Type))
+	data = appendProtoBytes(data, protoPacketNsp, []byte(nsp))
+	if h.NeedAck {
+		data = appendProtoVarint(data, protoPacketID, h.ID)
+	}
+
+	if len(args) > 0 {
+		// buffers which are encoded through JSON, e.g. fields of structs, are replaced by
+		// placeholders, so they're attached like in Default parser
+		var index uint64
+		attachments, err := attachBuffer(reflect.ValueOf(args), &index)
+		if err != nil {
+			return err
+		}
+
+		value, err := appendProtoValue(nil, args[0], attachments)
+		if err != nil {
+			return err
+		}
+		data = appendProtoBytes(data, protoPacketData, value)
+	}
+
+	var err error
+	e.size, err = writeBinaryFrame(e.w, data)
+
+	return err
+}
+
+func (e *protobufEncoder) LastSize() int {
+	return e.size
+}
+
+type protobufDecoder struct {
+	r FrameReader
+
+	// args are arguments of last packet until they're decoded or discarded.
+	args []interface{}
+	size int
+}
+
+func (d *protobufDecoder) DecodeHeader(header *Header, event *string) error {
+	d.args = nil
+	d.size = 0
+
+	ft, r, err := d.r.NextReader()
+	if err != nil {
+		return err
+	}
+
+	data, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	d.size = len(data)
+
+	if ft != session.BINARY {
+		return errInvalidProtobufPacket
+	}
+
+	var typ uint64
+	var nsp string
+	var payload interface{}
+	hasType := false
+
+	pr := protoReader{data: data}
+	for !pr.done() {
+		field, wireType, n, b, err := pr.field()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case field == protoPacketType && wireType == protoVarint:
+			typ, hasType = n, true
+		case field == protoPacketNsp && wireType == protoBytes:
+			nsp = string(b)
+		case field == protoPacketID && wireType == protoVarint:
+			header.ID, header.NeedAck = n, true
+		case field == protoPacketData && wireType == protoBytes:
+			if payload, err = unmarshalProtoValue(b, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	// type is sent even when it's Connect, which is zero
+	if !hasType || typ > uint64(Error) {
+		return ErrInvalidPacketType
+	}
+	header.Type = Type(typ)
+
+	if queryPos := strings.IndexByte(nsp, '?'); queryPos > -1 {
+		header.Query = nsp[queryPos+1:]
+		nsp = nsp[:queryPos]
+	}
+	if nsp != "/" {
+		header.Namespace = nsp
+	}
+
+	switch payload := payload.(type) {
+	case nil:
+	case []interface{}:
+		d.args = payload
+	default:
+		// e.g. auth of CONNECT packet
+		d.args = []interface{}{payload}
+	}
+
+	if header.Type == Event {
+		if len(d.args) == 0 {
+			return errInvalidProtobufPacket
+		}
+
+		name, ok := d.args[0].(string)
+		if !ok {
+			return errInvalidProtobufPacket
+		}
+
+		*event = name
+		d.args = d.args[1:]
+	}
+
+	return nil
+}
+
+func (d *protobufDecoder) DecodeArgs(types []reflect.Type) ([]reflect.Value, error) {
+	args := d.args
+	d.args = nil
+
+	return convertArgs(args, types, false)
+}
+
+func (d *protobufDecoder) DecodeVariadicArgs(types []reflect.Type) ([]reflect.Value, error) {
+	args := d.args
+	d.args = nil
+
+	return convertArgs(args, types, true)
+}
+
+func (d *protobufDecoder) DiscardLast() error {
+	d.args = nil
+
+	return nil
+}
+
+func (d *protobufDecoder) Discard() error {
+	return d.DiscardLast()
+}
+
+func (d *protobufDecoder) LastSize() int {
+	return d.size
+}
+
+func (d *protobufDecoder) Close() error {
+	return nil
+}
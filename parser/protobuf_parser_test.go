@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+)
+
+func TestProtobufParser(t *testing.T) {
+	// packets of Default parser are the same with Protobuf, but they're single binary frames
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			w := fakeWriter{}
+			encoder := Protobuf.NewEncoder(&w)
+			v := test.Var
+			if test.Header.Type == Event {
+				v = append([]interface{}{test.Event}, test.Var...)
+			}
+
+			var err error
+			if v != nil {
+				err = encoder.Encode(test.Header, v)
+			} else {
+				err = encoder.Encode(test.Header)
+			}
+			must.NoError(err)
+			must.Equal([]session.FrameType{session.BINARY}, w.types)
+			should.Equal(w.data[0].Len(), encoder.LastSize())
+
+			decoder := Protobuf.NewDecoder(newFramesReader(&w))
+
+			var header Header
+			var event string
+			must.NoError(decoder.DecodeHeader(&header, &event))
+			should.Equal(test.Header, header)
+			should.Equal(test.Event, event)
+			should.Equal(w.data[0].Len(), decoder.LastSize())
+
+			types := make([]reflect.Type, len(test.Var))
+			for i := range types {
+				types[i] = reflect.TypeOf(test.Var[i])
+			}
+			ret, err := decoder.DecodeArgs(types)
+			must.NoError(err)
+
+			must.Len(ret, len(test.Var))
+			for i, v := range test.Var {
+				// encoding marks buffers of test as attached
+				if buffer, ok := v.(*Buffer); ok {
+					should.Equal(buffer.Data, ret[i].Interface().(*Buffer).Data)
+					continue
+				}
+				should.Equal(v, ret[i].Interface())
+			}
+		})
+	}
+}
+
+func TestProtobufParserBinary(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	type file struct {
+		Name string  `json:"name"`
+		Data *Buffer `json:"data"`
+	}
+
+	w := fakeWriter{}
+	encoder := Protobuf.NewEncoder(&w)
+	must.NoError(encoder.Encode(Header{Type: Event, ID: 7, NeedAck: true, Namespace: "/files"}, []interface{}{
+		"upload", &Buffer{Data: []byte{1, 2}}, &file{Name: "a.bin", Data: &Buffer{Data: []byte{3}}},
+		-5, uint64(math.MaxUint64), 1.5, nil,
+	}))
+
+	decoder := Protobuf.NewDecoder(newFramesReader(&w))
+
+	var header Header
+	var event string
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Event, ID: 7, NeedAck: true, Namespace: "/files"}, header)
+	should.Equal("upload", event)
+
+	// buffers are binary values of protobuf, including the ones of structs
+	d := decoder.(*protobufDecoder)
+	should.Equal([]interface{}{
+		[]byte{1, 2},
+		map[string]interface{}{"name": "a.bin", "data": []byte{3}},
+		int64(-5), uint64(math.MaxUint64), 1.5, nil,
+	}, d.args)
+
+	args, err := decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf(&Buffer{}), reflect.TypeOf(file{}), reflect.TypeOf(0)})
+	must.NoError(err)
+	must.Len(args, 6)
+	should.Equal([]byte{1, 2}, args[0].Interface().(*Buffer).Data)
+	should.Equal("a.bin", args[1].Interface().(file).Name)
+	should.Equal([]byte{3}, args[1].Interface().(file).Data.Data)
+	should.Equal(-5, args[2].Interface())
+}
+
+func TestProtobufParserDecode(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// {type: 2, nsp: "/chat?x=1", id: 3, data: ["msg", 1, {a: true}]} and
+	// {type: 0, nsp: "/", data: {token: "t"}} encoded by protoc generated code of packet.proto
+	r := &framesReader{
+		types: []session.FrameType{session.BINARY, session.BINARY},
+		data: [][]byte{
+			[]byte("\x08\x02\x12\x09/chat?x=1\x18\x03\x22\x1a\x42\x18\x0a\x05\x32\x03msg\x0a\x02\x18\x02\x0a\x0b\x4a\x09\x0a\x07\x0a\x01a\x12\x02\x10\x01"),
+			[]byte("\x08\x00\x12\x01/\x22\x10\x4a\x0e\x0a\x0c\x0a\x05token\x12\x03\x32\x01t"),
+		},
+	}
+	decoder := Protobuf.NewDecoder(r)
+
+	var header Header
+	var event string
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Event, ID: 3, NeedAck: true, Namespace: "/chat", Query: "x=1"}, header)
+	should.Equal("msg", event)
+
+	args, err := decoder.DecodeVariadicArgs([]reflect.Type{reflect.TypeOf(0)})
+	must.NoError(err)
+	must.Len(args, 2)
+	should.Equal(1, args[0].Interface())
+	should.Equal(map[string]interface{}{"a": true}, args[1].Interface())
+
+	header = Header{}
+	must.NoError(decoder.DecodeHeader(&header, &event))
+	should.Equal(Header{Type: Connect}, header)
+
+	args, err = decoder.DecodeArgs([]reflect.Type{reflect.TypeOf(map[string]interface{}{})})
+	must.NoError(err)
+	should.Equal(map[string]interface{}{"token": "t"}, args[0].Interface())
+
+	should.Equal(io.EOF, decoder.DecodeHeader(&header, &event))
+
+	for name, data := range map[string][]byte{
+		"no type":       []byte("\x12\x01/"),
+		"unknown type":  []byte("\x08\x05\x12\x01/"),
+		"event no name": []byte("\x08\x02\x12\x01/\x22\x06\x42\x04\x0a\x02\x18\x02"),
+		"truncated":     []byte("\x08\x02\x12\x09/ch"),
+	} {
+		decoder = Protobuf.NewDecoder(&framesReader{types: []session.FrameType{session.BINARY}, data: [][]byte{data}})
+		should.Error(decoder.DecodeHeader(&header, &event), name)
+	}
+
+	decoder = Protobuf.NewDecoder(&framesReader{types: []session.FrameType{session.TEXT}, data: [][]byte{[]byte("2")}})
+	should.Error(decoder.DecodeHeader(&header, &event), "text frame")
+}
+
+func TestProtobufDepth(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	nest := func(depth int, v interface{}) interface{} {
+		for i := 0; i < depth; i++ {
+			if i%2 == 0 {
+				v = []interface{}{v}
+			} else {
+				v = map[string]interface{}{"a": v}
+			}
+		}
+		return v
+	}
+
+	// lists and objects nested as deep as allowed, then one level deeper
+	data, err := appendProtoValue(nil, nest(maxValueDepth, true), nil)
+	must.NoError(err)
+	_, err = unmarshalProtoValue(data, 0)
+	must.NoError(err)
+
+	data, err = appendProtoValue(nil, nest(maxValueDepth+1, true), nil)
+	must.NoError(err)
+	_, err = unmarshalProtoValue(data, 0)
+	should.ErrorIs(err, errProtobufDepth)
+}
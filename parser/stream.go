@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"encoding"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+)
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SetStreaming makes encoder write JSON of args into frame while it's encoded, instead of
+// encoding it in memory first, so memory used by large args doesn't double, e.g. when frames
+// are streamed by websocket transport. Output is the same as of json.Encoder.
+func (e *Encoder) SetStreaming(enable bool) {
+	e.streaming = enable
+}
+
+// writeJSON writes JSON of v like json.Encoder, arrays, slices and maps with string keys are
+// written element by element, other values are marshaled by encoding/json.
+func writeJSON(w byteWriter, v interface{}) error {
+	if err := streamValue(w, reflect.ValueOf(v)); err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+func streamValue(w byteWriter, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		if marshaled(v) {
+			return marshalValue(w, v)
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		_, err := io.WriteString(w, "null")
+		return err
+	}
+
+	if marshaled(v) {
+		return marshalValue(w, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		// []byte is base64 string
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return marshalValue(w, v)
+		}
+		return streamArray(w, v)
+
+	case reflect.Array:
+		return streamArray(w, v)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Key().Implements(textMarshalerType) {
+			return marshalValue(w, v)
+		}
+		if v.IsNil() {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		return streamMap(w, v)
+	}
+
+	return marshalValue(w, v)
+}
+
+func streamArray(w byteWriter, v reflect.Value) error {
+	if err := w.WriteByte('['); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if err := streamValue(w, v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return w.WriteByte(']')
+}
+
+func streamMap(w byteWriter, v reflect.Value) error {
+	keys := v.MapKeys()
+	// keys are sorted like encoding/json does
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	if err := w.WriteByte('{'); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if i > 0 {
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if err := marshalValue(w, reflect.ValueOf(key.String())); err != nil {
+			return err
+		}
+		if err := w.WriteByte(':'); err != nil {
+			return err
+		}
+		if err := streamValue(w, v.MapIndex(key)); err != nil {
+			return err
+		}
+	}
+
+	return w.WriteByte('}')
+}
+
+// marshaled reports whether v marshals itself.
+func marshaled(v reflect.Value) bool {
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+
+	pt := reflect.PtrTo(t)
+	return v.CanAddr() && (pt.Implements(marshalerType) || pt.Implements(textMarshalerType))
+}
+
+func marshalValue(w byteWriter, v reflect.Value) error {
+	if v.CanAddr() && v.Kind() != reflect.Ptr {
+		v = v.Addr()
+	}
+
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/session"
+)
+
+// chunkWriter records size of the largest write of frame.
+type chunkWriter struct {
+	bytes.Buffer
+	largest int
+}
+
+func (w *chunkWriter) NextWriter(session.FrameType) (io.WriteCloser, error) {
+	return w, nil
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func (w *chunkWriter) Close() error {
+	return nil
+}
+
+type streamText string
+
+func (t streamText) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(t))), nil
+}
+
+type streamStruct struct {
+	Name    string `json:"name"`
+	Skipped int    `json:"-"`
+	Empty   string `json:",omitempty"`
+}
+
+func TestEncoderStreaming(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			must := require.New(t)
+
+			w := fakeWriter{}
+			encoder := NewEncoder(&w)
+			encoder.SetStreaming(true)
+
+			v := test.Var
+			if test.Header.Type == Event {
+				v = append([]interface{}{test.Event}, test.Var...)
+			}
+
+			var err error
+			if v != nil {
+				err = encoder.Encode(test.Header, v)
+			} else {
+				err = encoder.Encode(test.Header)
+			}
+			must.NoError(err)
+
+			must.Equal(len(test.Data), len(w.data))
+			for i := range w.data {
+				must.Equal(test.Data[i], w.data[i].Bytes())
+			}
+		})
+	}
+
+	values := []interface{}{
+		"<b>&</b>",
+		nil,
+		[]int(nil),
+		map[string]interface{}(nil),
+		[]byte{1, 2, 3},
+		[2]uint8{4, 5},
+		map[int]string{2: "b", 1: "a"},
+		map[string]interface{}{"b": []interface{}{1.5, true, nil}, "a": map[string]int{"z": 1, "y": 2}},
+		map[streamText]int{"k": 1},
+		[]streamText{"a", "b"},
+		[]*streamStruct{{Name: "x", Skipped: 1}, nil},
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		&Buffer{Data: []byte{7}},
+	}
+	for _, v := range values {
+		var expected, streamed chunkWriter
+
+		must := require.New(t)
+		must.NoError(NewEncoder(&expected).Encode(Header{Type: Ack, ID: 1, NeedAck: true}, []interface{}{v}))
+
+		encoder := NewEncoder(&streamed)
+		encoder.SetStreaming(true)
+		must.NoError(encoder.Encode(Header{Type: Ack, ID: 1, NeedAck: true}, []interface{}{v}))
+
+		assert.Equal(t, expected.String(), streamed.String(), "%#v", v)
+	}
+}
+
+func TestEncoderStreamingWrites(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	rows := make([]map[string]interface{}, 10000)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": i, "name": "row"}
+	}
+
+	var buffered, streamed chunkWriter
+	must.NoError(NewEncoder(&buffered).Encode(Header{Type: Event}, []interface{}{"rows", rows}))
+
+	encoder := NewEncoder(&streamed)
+	encoder.SetStreaming(true)
+	must.NoError(encoder.Encode(Header{Type: Event}, []interface{}{"rows", rows}))
+
+	should.Equal(buffered.String(), streamed.String())
+	// JSON isn't held in memory, it's written through buffer of encoder
+	should.Greater(buffered.largest, 100000)
+	should.LessOrEqual(streamed.largest, 4096)
+}
@@ -0,0 +1,65 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrPayloadQuota is reported when payload of received event exceeds limit of its namespace or
+// of rooms of its connection, see Server.SetPayloadLimit.
+var ErrPayloadQuota = errors.New("payload exceeds quota")
+
+// SetPayloadLimit sets maximum size in bytes of payload of events received in namespace,
+// zero removes the limit. It applies on top of MaxPayload of engine. Event which exceeds
+// the limit isn't handled, its ack gets a quota error like
+// {"error": "payload exceeds quota", "size": 2048, "limit": 1024}.
+func (s *Server) SetPayloadLimit(namespace string, limit int) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.SetPayloadLimit(limit)
+}
+
+// SetRoomPayloadLimit sets maximum size in bytes of payload of events received from connections
+// which joined room, e.g. tighter limit for public rooms. room is name of a room or type of rooms,
+// like in SetRoomSchema. The tightest limit of namespace and rooms of connection applies, zero
+// removes limit of room.
+func (s *Server) SetRoomPayloadLimit(namespace, room string, limit int) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.SetRoomPayloadLimit(room, limit)
+}
+
+// rejectPayload answers event which exceeds payload limit with quota error ack.
+func rejectPayload(c *conn, conn *namespaceConn, event string, header parser.Header, size, limit int) error {
+	err := fmt.Errorf("%w: event %q of %d bytes, limit is %d", ErrPayloadQuota, event, size, limit)
+	conn.Logger().Info("Payload exceeds quota", "event", event, "size", size, "limit", limit)
+
+	if c.observeEvent != nil {
+		c.observeEvent(conn, EventMetrics{
+			Namespace:   conn.Namespace(),
+			Event:       event,
+			PayloadSize: size,
+			Err:         err,
+		})
+	}
+
+	if header.NeedAck {
+		header.Type = parser.Ack
+		c.write(header, reflect.ValueOf(map[string]interface{}{
+			"error": ErrPayloadQuota.Error(),
+			"size":  size,
+			"limit": limit,
+		}))
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package socketio
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+func TestServerPayloadLimit(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+	server.SetPayloadLimit("/", 1000)
+	server.SetRoomPayloadLimit("/", "public", 100)
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+	server.OnEvent("/", "join", func(c Conn, room string) string {
+		c.Join(room)
+		return room
+	})
+	server.OnEvent("/", "message", func(_ Conn, msg string) map[string]interface{} {
+		return map[string]interface{}{"size": len(msg)}
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	emit := func(event, arg string) map[string]interface{} {
+		ret := make(chan map[string]interface{}, 1)
+		client.Emit(event, arg, func(v map[string]interface{}) {
+			ret <- v
+		})
+
+		select {
+		case v := <-ret:
+			return v
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %q wasn't acknowledged", event)
+			return nil
+		}
+	}
+
+	should.Equal(map[string]interface{}{"size": float64(500)}, emit("message", strings.Repeat("x", 500)))
+
+	quota := emit("message", strings.Repeat("x", 2000))
+	should.Equal(ErrPayloadQuota.Error(), quota["error"])
+	should.Equal(float64(1000), quota["limit"])
+
+	joined := make(chan string, 1)
+	client.Emit("join", "public:lobby", func(room string) {
+		joined <- room
+	})
+	<-joined
+
+	quota = emit("message", strings.Repeat("x", 500))
+	should.Equal(ErrPayloadQuota.Error(), quota["error"])
+	should.Equal(float64(100), quota["limit"])
+}
+
+func TestNamespaceHandlerPayloadLimit(t *testing.T) {
+	should := assert.New(t)
+
+	nh := newNamespaceHandler("/", nil)
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "sid1"}}, "/", nh.broadcast)
+
+	should.Zero(nh.getPayloadLimit(nc))
+
+	nh.SetRoomPayloadLimit("public", 100)
+	nh.SetRoomPayloadLimit("vip:1", 10)
+	should.Zero(nh.getPayloadLimit(nc))
+
+	nc.Join("public:lobby")
+	should.Equal(100, nh.getPayloadLimit(nc), "limit of room type")
+
+	nh.SetPayloadLimit(50)
+	should.Equal(50, nh.getPayloadLimit(nc), "tighter limit of namespace")
+
+	nc.Join("vip:1")
+	should.Equal(10, nh.getPayloadLimit(nc), "limit of room name")
+
+	nh.SetRoomPayloadLimit("vip:1", 0)
+	should.Equal(50, nh.getPayloadLimit(nc))
+}
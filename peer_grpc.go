@@ -0,0 +1,291 @@
+package socketio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// peerCodecName is content-subtype of streams of peer mesh, gRPC servers pick codec of
+	// streams by it, so codecs of other services of the server aren't changed.
+	peerCodecName    = "socketio-peer"
+	peerServiceName  = "socketio.PeerMesh"
+	peerStreamMethod = "/" + peerServiceName + "/Stream"
+)
+
+// fields of PeerMessage, see peer_mesh.proto
+const (
+	peerFieldType        = 1
+	peerFieldNode        = 2
+	peerFieldRecord      = 3
+	peerFieldRequestID   = 4
+	peerFieldRequestType = 5
+	peerFieldNamespace   = 6
+	peerFieldRoom        = 7
+	peerFieldConnections = 8
+	peerFieldRooms       = 9
+	peerFieldData        = 10
+)
+
+func init() {
+	encoding.RegisterCodec(peerCodec{})
+}
+
+var peerStreamDesc = grpc.StreamDesc{
+	StreamName:    "Stream",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// GRPCPeerDialer opens streams of peer mesh to nodes over gRPC, streams are served by service
+// which Server.RegisterPeerService registers on gRPC servers of those nodes, see peer_mesh.proto.
+// Size of messages is bounded by gRPC, 4 MB by default.
+type GRPCPeerDialer struct {
+	// DialOptions : options of connections to nodes, they must set transport credentials, e.g.
+	// grpc.WithTransportCredentials with TLS credentials of the mesh, or insecure credentials
+	// when nodes talk over private network.
+	DialOptions []grpc.DialOption
+}
+
+// Dial connects to node at addr, ctx bounds opening of the stream.
+func (d GRPCPeerDialer) Dial(ctx context.Context, addr string) (PeerStream, error) {
+	conn, err := grpc.NewClient(addr, d.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	// stream outlives ctx, which only bounds opening it
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+
+	stream, err := conn.NewStream(streamCtx, &peerStreamDesc, peerStreamMethod, grpc.CallContentSubtype(peerCodecName))
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &grpcPeerStream{stream: stream, conn: conn, cancel: cancel}, nil
+}
+
+// grpcPeerStream is PeerStream opened by GRPCPeerDialer.
+type grpcPeerStream struct {
+	stream grpc.ClientStream
+	conn   *grpc.ClientConn
+	cancel context.CancelFunc
+}
+
+func (s *grpcPeerStream) Send(msg *PeerMessage) error {
+	return s.stream.SendMsg(msg)
+}
+
+func (s *grpcPeerStream) Recv() (*PeerMessage, error) {
+	var msg PeerMessage
+	if err := s.stream.RecvMsg(&msg); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+func (s *grpcPeerStream) Close() error {
+	s.cancel()
+	return s.conn.Close()
+}
+
+// peerMeshService is handler of service of peer mesh.
+type peerMeshService interface {
+	servePeerStream(stream grpc.ServerStream) error
+}
+
+// RegisterPeerService registers service of peer mesh on gRPC server r, it serves streams which
+// GRPCPeerDialer of other nodes opens with ServePeer. Nodes are authenticated by credentials and
+// interceptors of r, e.g. grpc.Creds with TLS credentials which require certificates of nodes.
+// Server without them serves any host, so it must not be exposed beyond private network of the
+// cluster.
+func (s *Server) RegisterPeerService(r grpc.ServiceRegistrar) {
+	r.RegisterService(&grpc.ServiceDesc{
+		ServiceName: peerServiceName,
+		HandlerType: (*peerMeshService)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName: peerStreamDesc.StreamName,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(peerMeshService).servePeerStream(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+		Metadata: "peer_mesh.proto",
+	}, s)
+}
+
+func (s *Server) servePeerStream(stream grpc.ServerStream) error {
+	served := &grpcServedPeerStream{stream: stream, closed: make(chan struct{})}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ServePeer(served)
+	}()
+
+	// stream ends once handler returns, which unblocks Recv of ServePeer
+	select {
+	case err := <-errs:
+		return err
+	case <-served.closed:
+		return nil
+	}
+}
+
+// grpcServedPeerStream is PeerStream opened by other node, served by RegisterPeerService.
+type grpcServedPeerStream struct {
+	stream grpc.ServerStream
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *grpcServedPeerStream) Send(msg *PeerMessage) error {
+	return s.stream.SendMsg(msg)
+}
+
+func (s *grpcServedPeerStream) Recv() (*PeerMessage, error) {
+	var msg PeerMessage
+	if err := s.stream.RecvMsg(&msg); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+func (s *grpcServedPeerStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
+	return nil
+}
+
+// peerCodec encodes PeerMessage as protobuf message of peer_mesh.proto.
+type peerCodec struct{}
+
+func (peerCodec) Name() string {
+	return peerCodecName
+}
+
+func (peerCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(*PeerMessage)
+	if !ok {
+		return nil, fmt.Errorf("peer codec: unexpected %T", v)
+	}
+
+	return msg.appendProto(nil), nil
+}
+
+func (peerCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(*PeerMessage)
+	if !ok {
+		return fmt.Errorf("peer codec: unexpected %T", v)
+	}
+
+	return msg.unmarshalProto(data)
+}
+
+func (msg *PeerMessage) appendProto(b []byte) []byte {
+	b = appendPeerString(b, peerFieldType, msg.Type)
+	b = appendPeerString(b, peerFieldNode, msg.Node)
+	if len(msg.Record) > 0 {
+		b = protowire.AppendTag(b, peerFieldRecord, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg.Record)
+	}
+	b = appendPeerString(b, peerFieldRequestID, msg.RequestID)
+	b = appendPeerString(b, peerFieldRequestType, msg.RequestType)
+	b = appendPeerString(b, peerFieldNamespace, msg.Namespace)
+	b = appendPeerString(b, peerFieldRoom, msg.Room)
+	if msg.Connections != 0 {
+		b = protowire.AppendTag(b, peerFieldConnections, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(msg.Connections)))
+	}
+	for _, room := range msg.Rooms {
+		b = protowire.AppendTag(b, peerFieldRooms, protowire.BytesType)
+		b = protowire.AppendString(b, room)
+	}
+	if len(msg.Data) > 0 {
+		b = protowire.AppendTag(b, peerFieldData, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg.Data)
+	}
+
+	return b
+}
+
+func appendPeerString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// unmarshalProto decodes protobuf message, unknown fields are skipped.
+func (msg *PeerMessage) unmarshalProto(data []byte) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == peerFieldConnections && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			msg.Connections = int(int64(v))
+		case typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(data)
+			if n >= 0 {
+				msg.setProtoBytes(num, v)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+
+	return nil
+}
+
+func (msg *PeerMessage) setProtoBytes(num protowire.Number, v []byte) {
+	switch num {
+	case peerFieldType:
+		msg.Type = string(v)
+	case peerFieldNode:
+		msg.Node = string(v)
+	case peerFieldRecord:
+		// data of gRPC may be reused once message is decoded
+		msg.Record = append([]byte(nil), v...)
+	case peerFieldRequestID:
+		msg.RequestID = string(v)
+	case peerFieldRequestType:
+		msg.RequestType = string(v)
+	case peerFieldNamespace:
+		msg.Namespace = string(v)
+	case peerFieldRoom:
+		msg.Room = string(v)
+	case peerFieldRooms:
+		msg.Rooms = append(msg.Rooms, string(v))
+	case peerFieldData:
+		msg.Data = append([]byte(nil), v...)
+	}
+}
@@ -0,0 +1,157 @@
+package socketio
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newGRPCPeerServers gives two servers of peer mesh over gRPC, gRPC servers use serverOpts.
+func newGRPCPeerServers(t *testing.T, dialer GRPCPeerDialer, serverOpts ...grpc.ServerOption) ([]*Server, []*lockedRecorder, []string) {
+	must := require.New(t)
+
+	listeners := make([]net.Listener, 2)
+	addrs := make([]string, 2)
+	for i := range listeners {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		must.NoError(err)
+		listeners[i] = l
+		addrs[i] = l.Addr().String()
+	}
+
+	meshes := make([]*peerMesh, 2)
+	servers, recorders := newAdapterServers(t, "/chat", func(i int, server *Server) error {
+		err := server.PeerMeshAdapter(&PeerMeshOptions{
+			Dialer:          dialer,
+			Discovery:       StaticPeers(addrs),
+			RefreshInterval: 10 * time.Millisecond,
+		})
+		meshes[i] = server.mesh
+
+		grpcServer := grpc.NewServer(serverOpts...)
+		server.RegisterPeerService(grpcServer)
+		t.Cleanup(grpcServer.Stop)
+		go func() {
+			_ = grpcServer.Serve(listeners[i])
+		}()
+
+		return err
+	}, func() bool {
+		return len(meshes[0].connected()) == 1 && len(meshes[1].connected()) == 1
+	})
+
+	return servers, recorders, addrs
+}
+
+func TestGRPCPeerMesh(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, recorders, _ := newGRPCPeerServers(t, GRPCPeerDialer{
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	})
+	servers[1].JoinRoom("/chat", "news", newLockedRecorder("2", "/chat", nil))
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "first"))
+	should.True(servers[1].BroadcastToNamespace("/chat", "second"))
+
+	must.Eventually(func() bool {
+		return len(recorders[0].received()) == 2 && len(recorders[1].received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	should.ElementsMatch([]string{"first", "second"}, recorders[0].received())
+	should.ElementsMatch([]string{"first", "second"}, recorders[1].received())
+
+	should.Equal(2, servers[0].RoomLen("/chat", "lobby"))
+	should.Equal(1, servers[0].RoomLen("/chat", "news"))
+	rooms := servers[0].Rooms("/chat")
+	sort.Strings(rooms)
+	should.Equal([]string{"lobby", "news"}, rooms)
+
+	// closed node closes streams it serves and isn't waited for
+	must.NoError(servers[1].Close())
+	must.Eventually(func() bool {
+		return len(servers[0].mesh.connected()) == 0
+	}, time.Second, 10*time.Millisecond)
+	should.Equal(1, servers[0].RoomLen("/chat", "lobby"))
+}
+
+func TestGRPCPeerMeshTLS(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// certificate of httptest is valid for 127.0.0.1
+	certs := httptest.NewUnstartedServer(nil)
+	certs.StartTLS()
+	defer certs.Close()
+
+	clientTLS := certs.Client().Transport.(*http.Transport).TLSClientConfig
+	servers, recorders, addrs := newGRPCPeerServers(t, GRPCPeerDialer{
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(clientTLS))},
+	}, grpc.Creds(credentials.NewTLS(certs.TLS)))
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "first"))
+	must.Eventually(func() bool {
+		return len(recorders[1].received()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// nodes without TLS are refused
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stream, err := GRPCPeerDialer{
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	}.Dial(ctx, addrs[0])
+	if err == nil {
+		_ = stream.Send(&PeerMessage{Type: peerMessageHello, Node: "intruder"})
+		_, err = stream.Recv()
+		_ = stream.Close()
+	}
+	should.Error(err)
+	should.Len(servers[0].mesh.connected(), 1)
+
+	// dialer without credentials is refused by gRPC
+	_, err = GRPCPeerDialer{}.Dial(ctx, addrs[0])
+	should.Error(err)
+}
+
+func TestPeerCodec(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	codec := peerCodec{}
+	msg := &PeerMessage{
+		Type:        peerMessageResponse,
+		Node:        "a",
+		Record:      []byte(`{"type":"send"}`),
+		RequestID:   "1",
+		RequestType: roomLenReqType,
+		Namespace:   "/chat",
+		Room:        "lobby",
+		Connections: 3,
+		Rooms:       []string{"lobby", "news"},
+		Data:        []byte(`{"Missing":1}`),
+	}
+
+	data, err := codec.Marshal(msg)
+	must.NoError(err)
+
+	// unknown fields of newer nodes are skipped
+	data = append(data, 0x80, 0x01, 0x05)
+
+	var decoded PeerMessage
+	must.NoError(codec.Unmarshal(data, &decoded))
+	should.Equal(msg, &decoded)
+
+	should.Error(codec.Unmarshal(data[:len(data)-1], &decoded), "truncated message")
+	_, err = codec.Marshal("hello")
+	should.Error(err)
+}
@@ -0,0 +1,791 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultPeerRefreshInterval = 10 * time.Second
+	defaultPeerRequestTimeout  = 5 * time.Second
+)
+
+// peer message types
+const (
+	peerMessageHello    = "hello"
+	peerMessageRecord   = "record"
+	peerMessageRequest  = "request"
+	peerMessageResponse = "response"
+)
+
+// PeerMessage is message exchanged by nodes of peer mesh, GRPCPeerDialer sends it as protobuf
+// message of peer_mesh.proto.
+type PeerMessage struct {
+	Type string `json:"type"`
+	Node string `json:"node"`
+
+	// Record is encoded broadcast or clear of room.
+	Record []byte `json:"record,omitempty"`
+
+	// RequestID, RequestType, Namespace and Room select room query and its response.
+	RequestID   string `json:"requestId,omitempty"`
+	RequestType string `json:"requestType,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Room        string `json:"room,omitempty"`
+
+	// Connections and Rooms are results of room query.
+	Connections int      `json:"connections,omitempty"`
+	Rooms       []string `json:"rooms,omitempty"`
+
+	// Data is JSON of other requests and their responses, e.g. acks of connections.
+	Data []byte `json:"data,omitempty"`
+}
+
+// PeerStream is bidirectional stream between two nodes. GRPCPeerDialer gives it over gRPC and
+// TCPPeerDialer over TCP, or applications implement it over their own transport. Send isn't
+// called concurrently, Close unblocks Recv.
+type PeerStream interface {
+	Send(msg *PeerMessage) error
+	Recv() (*PeerMessage, error)
+	Close() error
+}
+
+// PeerDialer opens stream to node at address, which is served by Server.ServePeer of that node.
+type PeerDialer interface {
+	Dial(ctx context.Context, addr string) (PeerStream, error)
+}
+
+// PeerDiscovery gives addresses of nodes of mesh, it may include address of this node.
+type PeerDiscovery interface {
+	Peers(ctx context.Context) ([]string, error)
+}
+
+// StaticPeers is fixed list of addresses of nodes.
+type StaticPeers []string
+
+// Peers gives the list.
+func (p StaticPeers) Peers(context.Context) ([]string, error) {
+	return p, nil
+}
+
+// DNSPeers discovers nodes by addresses of DNS name, e.g. of headless kubernetes service.
+type DNSPeers struct {
+	Host string
+	Port string
+
+	// Resolver is net.DefaultResolver when it's nil.
+	Resolver *net.Resolver
+}
+
+// Peers resolves addresses of host.
+func (d DNSPeers) Peers(ctx context.Context) ([]string, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	hosts, err := resolver.LookupHost(ctx, d.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(hosts))
+	for i, host := range hosts {
+		addrs[i] = net.JoinHostPort(host, d.Port)
+	}
+
+	return addrs, nil
+}
+
+// PeerMeshOptions is configuration of peer mesh adapter.
+type PeerMeshOptions struct {
+	Dialer    PeerDialer
+	Discovery PeerDiscovery
+
+	// RefreshInterval is how often nodes are discovered, default is 10 seconds.
+	RefreshInterval time.Duration
+
+	// RequestTimeout bounds waiting for other nodes to respond room queries and FetchSockets,
+	// and to respond broadcasts with ack after their timeout, default is 5 seconds.
+	RequestTimeout time.Duration
+}
+
+func (o *PeerMeshOptions) getRefreshInterval() time.Duration {
+	if o.RefreshInterval <= 0 {
+		return defaultPeerRefreshInterval
+	}
+
+	return o.RefreshInterval
+}
+
+func (o *PeerMeshOptions) getRequestTimeout() time.Duration {
+	if o.RequestTimeout <= 0 {
+		return defaultPeerRequestTimeout
+	}
+
+	return o.RequestTimeout
+}
+
+// PeerMeshAdapter sets peer mesh broadcast adapter, for deployments without redis, used instead of
+// adapter set before by namespaces created afterwards, see RebindAdapter. Each node opens stream to
+// every discovered node, broadcasts are sent through streams to every node, and Len, AllRooms,
+// FetchSockets, BroadcastToRoomWithAck and ServerSideEmit reach every node, like redis adapter.
+// Transport between nodes is given by Dialer, e.g. GRPCPeerDialer whose streams are served by
+// RegisterPeerService, or TCPPeerDialer whose streams are served by ServePeers, with TLS unless
+// nodes talk over private network. Streams of other transports are served by ServePeer.
+func (s *Server) PeerMeshAdapter(opts *PeerMeshOptions) error {
+	if opts == nil || opts.Dialer == nil || opts.Discovery == nil {
+		return errors.New("peer mesh adapter needs dialer and discovery")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &peerMesh{
+		ctx:        ctx,
+		opts:       opts,
+		peers:      make(map[string]*meshPeer),
+		served:     make(map[PeerStream]bool),
+		self:       make(map[string]bool),
+		broadcasts: make(map[string]*meshBroadcast),
+		requests:   newRequestRegistry(),
+	}
+	m.bus = newBusAdapter(s.nodeID, m.write)
+
+	s.adapterLock.Lock()
+	s.mesh = m
+	s.broadcasts = func(nsp string) (Broadcast, error) {
+		return m.newBroadcast(nsp), nil
+	}
+	s.adapterLock.Unlock()
+	s.OnServerShutdownBegin(cancel)
+
+	go m.run(ctx)
+
+	return nil
+}
+
+// ServePeer serves stream opened by other node of peer mesh until it's closed.
+func (s *Server) ServePeer(stream PeerStream) error {
+	mesh := s.getMesh()
+	if mesh == nil {
+		return errors.New("peer mesh adapter isn't set")
+	}
+
+	return mesh.serve(stream)
+}
+
+// peerMesh keeps streams to other nodes of mesh.
+type peerMesh struct {
+	ctx  context.Context
+	bus  *busAdapter
+	opts *PeerMeshOptions
+
+	// peers are streams opened to other nodes, by their address.
+	peers map[string]*meshPeer
+	// served are streams opened by other nodes.
+	served map[PeerStream]bool
+	// self are addresses of this node.
+	self       map[string]bool
+	broadcasts map[string]*meshBroadcast
+	requests   *requestRegistry
+	lock       sync.RWMutex
+}
+
+type meshPeer struct {
+	addr   string
+	node   string
+	stream PeerStream
+
+	sendLock sync.Mutex
+}
+
+func (p *meshPeer) send(msg *PeerMessage) error {
+	p.sendLock.Lock()
+	defer p.sendLock.Unlock()
+
+	return p.stream.Send(msg)
+}
+
+// peerRequest collects responses of nodes to room query.
+type peerRequest struct {
+	pendingRequest
+
+	connections int
+	rooms       map[string]bool
+}
+
+func (m *peerMesh) run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.getRefreshInterval())
+	defer ticker.Stop()
+
+	for {
+		m.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			m.close()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// close closes streams of this node and streams opened by other nodes.
+func (m *peerMesh) close() {
+	m.lock.RLock()
+	streams := make([]PeerStream, 0, len(m.peers)+len(m.served))
+	for _, peer := range m.peers {
+		streams = append(streams, peer.stream)
+	}
+	for stream := range m.served {
+		streams = append(streams, stream)
+	}
+	m.lock.RUnlock()
+
+	for _, stream := range streams {
+		_ = stream.Close()
+	}
+
+	m.requests.close(errRequestsClosed)
+}
+
+// refresh connects discovered nodes and closes streams of nodes which aren't discovered anymore.
+func (m *peerMesh) refresh(ctx context.Context) {
+	addrs, err := m.opts.Discovery.Peers(ctx)
+	if err != nil {
+		logger.Error("peer discovery:", err)
+		return
+	}
+
+	discovered := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		discovered[addr] = true
+
+		m.lock.RLock()
+		_, ok := m.peers[addr]
+		self := m.self[addr]
+		m.lock.RUnlock()
+
+		if ok || self {
+			continue
+		}
+
+		if err = m.connect(ctx, addr); err != nil {
+			logger.Error("peer connect:", err)
+		}
+	}
+
+	for _, peer := range m.connected() {
+		if !discovered[peer.addr] {
+			_ = peer.stream.Close()
+		}
+	}
+}
+
+// connect opens stream to node at address, nodes exchange their ids first.
+func (m *peerMesh) connect(ctx context.Context, addr string) error {
+	stream, err := m.opts.Dialer.Dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	if err = stream.Send(&PeerMessage{Type: peerMessageHello, Node: m.bus.node}); err != nil {
+		_ = stream.Close()
+		return err
+	}
+
+	hello, err := stream.Recv()
+	if err != nil {
+		_ = stream.Close()
+		return err
+	}
+
+	if hello.Type != peerMessageHello {
+		_ = stream.Close()
+		return fmt.Errorf("peer %s: unexpected %q message", addr, hello.Type)
+	}
+
+	m.lock.Lock()
+	if hello.Node == m.bus.node {
+		m.self[addr] = true
+		m.lock.Unlock()
+
+		return stream.Close()
+	}
+
+	peer := &meshPeer{addr: addr, node: hello.Node, stream: stream}
+	m.peers[addr] = peer
+	m.lock.Unlock()
+
+	go m.read(peer)
+
+	return nil
+}
+
+// read receives responses of node until its stream is closed.
+func (m *peerMesh) read(peer *meshPeer) {
+	for {
+		msg, err := peer.stream.Recv()
+		if err != nil {
+			m.drop(peer)
+			return
+		}
+
+		if msg.Type == peerMessageResponse {
+			m.onResponse(peer.node, msg)
+		}
+	}
+}
+
+// drop forgets node whose stream is closed, so requests don't wait for it.
+func (m *peerMesh) drop(peer *meshPeer) {
+	_ = peer.stream.Close()
+
+	m.lock.Lock()
+	if m.peers[peer.addr] == peer {
+		delete(m.peers, peer.addr)
+	}
+	m.lock.Unlock()
+
+	m.requests.nodeDead(peer.node)
+}
+
+func (m *peerMesh) connected() []*meshPeer {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	peers := make([]*meshPeer, 0, len(m.peers))
+	for _, peer := range m.peers {
+		peers = append(peers, peer)
+	}
+
+	return peers
+}
+
+// write sends encoded broadcast to every node.
+func (m *peerMesh) write(_ *busRecord, value []byte) error {
+	return m.sendAll(&PeerMessage{Type: peerMessageRecord, Node: m.bus.node, Record: value})
+}
+
+// sendAll sends msg to every node, without waiting for responses.
+func (m *peerMesh) sendAll(msg *PeerMessage) error {
+	var errs []error
+	for _, peer := range m.connected() {
+		if err := peer.send(msg); err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", peer.addr, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// serve applies broadcasts and responds requests of node until its stream is closed.
+func (m *peerMesh) serve(stream PeerStream) error {
+	defer stream.Close()
+
+	m.lock.Lock()
+	if m.ctx.Err() != nil {
+		m.lock.Unlock()
+		return m.ctx.Err()
+	}
+	m.served[stream] = true
+	m.lock.Unlock()
+
+	defer func() {
+		m.lock.Lock()
+		delete(m.served, stream)
+		m.lock.Unlock()
+	}()
+
+	hello, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	if hello.Type != peerMessageHello {
+		return fmt.Errorf("unexpected %q message", hello.Type)
+	}
+
+	if err = stream.Send(&PeerMessage{Type: peerMessageHello, Node: m.bus.node}); err != nil {
+		return err
+	}
+
+	// responses of broadcasts with ack are sent by other goroutines
+	peer := &meshPeer{node: hello.Node, stream: stream}
+
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case peerMessageRecord:
+			m.bus.handle(msg.Record)
+		case peerMessageRequest:
+			if err = m.respond(peer, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// respond responds request of node. Broadcast with ack is responded by another goroutine once
+// acks of connections are collected, so stream is read meanwhile, and server-side emit isn't
+// responded at all.
+func (m *peerMesh) respond(peer *meshPeer, req *PeerMessage) error {
+	resp := &PeerMessage{
+		Type:        peerMessageResponse,
+		Node:        m.bus.node,
+		RequestID:   req.RequestID,
+		RequestType: req.RequestType,
+	}
+
+	bc := m.getBroadcast(req.Namespace)
+
+	switch req.RequestType {
+	case serverSideEmitReqType:
+		if bc != nil {
+			bc.onServerSideEmit(req.Data)
+		}
+		return nil
+	case broadcastAckReqType:
+		go func() {
+			if bc != nil {
+				resp.Data = bc.respondAcks(req.Data)
+			}
+			if err := peer.send(resp); err != nil {
+				logger.Error("peer respond:", err)
+			}
+		}()
+		return nil
+	}
+
+	if bc != nil {
+		switch req.RequestType {
+		case roomLenReqType:
+			resp.Connections = bc.broadcast.Len(req.Room)
+		case allRoomReqType:
+			resp.Rooms = bc.broadcast.AllRooms()
+		case fetchSocketsReqType:
+			resp.Data, _ = json.Marshal(bc.localSockets(req.Room))
+		}
+	}
+
+	return peer.send(resp)
+}
+
+func (m *peerMesh) onResponse(node string, msg *PeerMessage) {
+	pending, ok := m.requests.get(msg.RequestID)
+	if !ok {
+		return
+	}
+
+	switch req := pending.(type) {
+	case *peerRequest:
+		req.respond(node, func() {
+			req.connections += msg.Connections
+			for _, room := range msg.Rooms {
+				req.rooms[room] = true
+			}
+		})
+	case *broadcastAckRequest:
+		var res broadcastAckResponse
+		decodePeerData(msg, &res)
+
+		req.respond(node, func() {
+			for _, reply := range res.Replies {
+				reply.Node = node
+				req.replies = append(req.replies, reply)
+			}
+			req.missing += res.Missing
+		})
+	case *fetchSocketsRequest:
+		var sockets []RemoteSocket
+		decodePeerData(msg, &sockets)
+
+		req.respond(node, func() {
+			for _, socket := range sockets {
+				socket.Node = node
+				req.sockets = append(req.sockets, socket)
+			}
+		})
+	}
+}
+
+// decodePeerData decodes data of response into v, node which responded is counted even when
+// data is invalid, so request doesn't wait for it.
+func decodePeerData(msg *PeerMessage, v interface{}) {
+	if len(msg.Data) == 0 {
+		return
+	}
+
+	if err := json.Unmarshal(msg.Data, v); err != nil {
+		logger.Error("peer "+msg.Node+" response:", err)
+	}
+}
+
+// request sends msg to every node and waits for their responses applied to req, until timeout
+// passes or ctx is done. Error tells responses are partial.
+func (m *peerMesh) request(ctx context.Context, msg *PeerMessage, req remoteRequest, timeout time.Duration) error {
+	msg.Type = peerMessageRequest
+	msg.Node = m.bus.node
+	msg.RequestID = newV4UUID()
+
+	peers := m.connected()
+
+	nodes := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		nodes = append(nodes, peer.node)
+	}
+	req.pending().expectNodes(nodes)
+
+	release, err := m.requests.register(ctx, msg.RequestID, req, len(peers), timeout)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for _, peer := range peers {
+		if err := peer.send(msg); err != nil {
+			req.pending().nodeDead(peer.node)
+		}
+	}
+
+	return req.pending().wait()
+}
+
+// query sends room query to every node and waits for their responses, or for timeout.
+func (m *peerMesh) query(nsp, reqType, room string) *peerRequest {
+	req := &peerRequest{rooms: make(map[string]bool)}
+
+	msg := &PeerMessage{RequestType: reqType, Namespace: nsp, Room: room}
+	if err := m.request(context.Background(), msg, req, m.opts.getRequestTimeout()); err != nil {
+		logger.Info("peer mesh room query is partial", "namespace", nsp, "room", room, "error", err.Error())
+	}
+
+	return req
+}
+
+func (m *peerMesh) newBroadcast(nsp string) *meshBroadcast {
+	bc := &meshBroadcast{busBroadcast: m.bus.newBroadcast(nsp), mesh: m}
+
+	m.lock.Lock()
+	m.broadcasts[nsp] = bc
+	m.lock.Unlock()
+
+	return bc
+}
+
+func (m *peerMesh) getBroadcast(nsp string) *meshBroadcast {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.broadcasts[nsp]
+}
+
+// meshBroadcast is broadcast of namespace which delivers broadcasts to other nodes of peer mesh
+// and queries their rooms, like redis adapter.
+type meshBroadcast struct {
+	*busBroadcast
+
+	mesh         *peerMesh
+	onServerSide func(event string, args []json.RawMessage)
+}
+
+// Len gives number of connections in the room on every node.
+func (bc *meshBroadcast) Len(room string) int {
+	req := bc.mesh.query(bc.nsp, roomLenReqType, room)
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	return bc.busBroadcast.Len(room) + req.connections
+}
+
+// AllRooms gives rooms of every node.
+func (bc *meshBroadcast) AllRooms() []string {
+	req := bc.mesh.query(bc.nsp, allRoomReqType, "")
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	for _, room := range bc.busBroadcast.AllRooms() {
+		req.rooms[room] = true
+	}
+
+	rooms := make([]string, 0, len(req.rooms))
+	for room := range req.rooms {
+		rooms = append(rooms, room)
+	}
+
+	return rooms
+}
+
+// Rooms gives rooms of every node when connection is nil, otherwise rooms the connection is
+// joined to.
+func (bc *meshBroadcast) Rooms(connection Conn) []string {
+	if connection == nil {
+		return bc.AllRooms()
+	}
+
+	return bc.busBroadcast.Rooms(connection)
+}
+
+// ackSupport gives nil, nodes of mesh are connected, so each of them is waited for.
+func (bc *meshBroadcast) ackSupport() error {
+	return nil
+}
+
+// broadcastWithAck broadcasts event to connections of room on every node and collects their
+// acks, nodes respond once acks are collected.
+func (bc *meshBroadcast) broadcastWithAck(room, event string, args []interface{}, timeout time.Duration) ([]BroadcastReply, error) {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&broadcastAckRequest{
+		Room:    room,
+		Event:   event,
+		Args:    string(argsJSON),
+		Timeout: strconv.FormatInt(timeout.Milliseconds(), 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// requests aren't sent to this node, acks of its connections are collected meanwhile
+	var local []BroadcastReply
+	var localMissing int
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		local, localMissing = collectAcks(bc.ForEach, room, bc.adapter.node, event, args, timeout)
+	}()
+
+	req := &broadcastAckRequest{}
+	msg := &PeerMessage{RequestType: broadcastAckReqType, Namespace: bc.nsp, Room: room, Data: data}
+	err = bc.mesh.request(context.Background(), msg, req, timeout+bc.mesh.opts.getRequestTimeout())
+	<-collected
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	replies := append(local, req.replies...)
+	missing := localMissing + req.missing
+	sortReplies(replies)
+
+	return replies, errors.Join(missingAcks(missing, len(replies)+missing), err)
+}
+
+// respondAcks broadcasts event of request to connections of room on this node and gives
+// response with their acks.
+func (bc *meshBroadcast) respondAcks(data []byte) []byte {
+	var req broadcastAckRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		logger.Error("peer broadcast with ack:", err)
+		return nil
+	}
+
+	var args []interface{}
+	if err := json.Unmarshal([]byte(req.Args), &args); err != nil {
+		logger.Error("peer broadcast with ack "+req.Event+":", err)
+		return nil
+	}
+
+	ms, _ := strconv.ParseInt(req.Timeout, 10, 64)
+	replies, missing := collectAcks(bc.ForEach, req.Room, bc.adapter.node, req.Event, args, time.Duration(ms)*time.Millisecond)
+
+	resp, _ := json.Marshal(&broadcastAckResponse{Replies: replies, Missing: missing})
+	return resp
+}
+
+// fetchSockets describes connections of room on every node.
+func (bc *meshBroadcast) fetchSockets(ctx context.Context, room string) ([]RemoteSocket, error) {
+	req := &fetchSocketsRequest{}
+	msg := &PeerMessage{RequestType: fetchSocketsReqType, Namespace: bc.nsp, Room: room}
+	err := bc.mesh.request(ctx, msg, req, bc.mesh.opts.getRequestTimeout())
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	sockets := append(bc.localSockets(room), req.sockets...)
+	sort.Slice(sockets, func(i, j int) bool {
+		return sockets[i].ID < sockets[j].ID
+	})
+
+	return sockets, err
+}
+
+// localSockets describes connections of room on this node.
+func (bc *meshBroadcast) localSockets(room string) []RemoteSocket {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return describeSockets(bc.rooms, room, bc.adapter.node)
+}
+
+// serverSideEmit sends event to handlers of namespace on every other node.
+func (bc *meshBroadcast) serverSideEmit(event string, args []interface{}) error {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&serverSideEmitRequest{Event: event, Args: string(argsJSON)})
+	if err != nil {
+		return err
+	}
+
+	return bc.mesh.sendAll(&PeerMessage{
+		Type:        peerMessageRequest,
+		Node:        bc.adapter.node,
+		RequestType: serverSideEmitReqType,
+		Namespace:   bc.nsp,
+		Data:        data,
+	})
+}
+
+func (bc *meshBroadcast) setOnServerSideEvent(f func(event string, args []json.RawMessage)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onServerSide = f
+}
+
+// onServerSideEmit passes message of ServerSideEmit of other node to handlers.
+func (bc *meshBroadcast) onServerSideEmit(data []byte) {
+	var req serverSideEmitRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		logger.Error("peer server-side event:", err)
+		return
+	}
+
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte(req.Args), &args); err != nil {
+		logger.Error("peer server-side event "+req.Event+":", err)
+		return
+	}
+
+	bc.lock.RLock()
+	f := bc.onServerSide
+	bc.lock.RUnlock()
+
+	if f != nil {
+		f(req.Event, args)
+	}
+}
@@ -0,0 +1,274 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipePeerStream is one end of in-memory stream between two nodes.
+type pipePeerStream struct {
+	in  <-chan *PeerMessage
+	out chan<- *PeerMessage
+
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+func newPeerPipe() (*pipePeerStream, *pipePeerStream) {
+	a, b := make(chan *PeerMessage), make(chan *PeerMessage)
+	closed, closeOnce := make(chan struct{}), &sync.Once{}
+
+	return &pipePeerStream{in: a, out: b, closed: closed, closeOnce: closeOnce},
+		&pipePeerStream{in: b, out: a, closed: closed, closeOnce: closeOnce}
+}
+
+func (p *pipePeerStream) Send(msg *PeerMessage) error {
+	select {
+	case p.out <- msg:
+		return nil
+	case <-p.closed:
+		return io.EOF
+	}
+}
+
+func (p *pipePeerStream) Recv() (*PeerMessage, error) {
+	select {
+	case msg := <-p.in:
+		return msg, nil
+	case <-p.closed:
+		return nil, io.EOF
+	}
+}
+
+func (p *pipePeerStream) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+// pipeDialer connects to servers by their address.
+type pipeDialer struct {
+	servers map[string]*Server
+	lock    sync.Mutex
+}
+
+func (d *pipeDialer) add(addr string, server *Server) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.servers[addr] = server
+}
+
+func (d *pipeDialer) Dial(_ context.Context, addr string) (PeerStream, error) {
+	d.lock.Lock()
+	server, ok := d.servers[addr]
+	d.lock.Unlock()
+
+	if !ok {
+		return nil, errors.New("unknown peer")
+	}
+
+	client, remote := newPeerPipe()
+	go func() {
+		_ = server.ServePeer(remote)
+	}()
+
+	return client, nil
+}
+
+// newPeerMeshServers gives two servers of peer mesh connected by in-memory streams.
+func newPeerMeshServers(t *testing.T) ([]*Server, []*lockedRecorder) {
+	addrs := []string{"a:1", "b:1"}
+	dialer := &pipeDialer{servers: make(map[string]*Server)}
+
+	meshes := make([]*peerMesh, 2)
+	return newAdapterServers(t, "/chat", func(i int, server *Server) error {
+		err := server.PeerMeshAdapter(&PeerMeshOptions{
+			Dialer:          dialer,
+			Discovery:       StaticPeers(addrs),
+			RefreshInterval: 10 * time.Millisecond,
+			RequestTimeout:  time.Second,
+		})
+		dialer.add(addrs[i], server)
+		meshes[i] = server.mesh
+
+		return err
+	}, func() bool {
+		return len(meshes[0].connected()) == 1 && len(meshes[1].connected()) == 1
+	})
+}
+
+// newAckConn gives connection of namespace which acknowledges every event it's written with
+// reply until test ends, or doesn't acknowledge them when reply is nil.
+func newAckConn(t *testing.T, id, namespace string, reply interface{}) *namespaceConn {
+	c := newConn(addrEngineConn{id: id}, newNamespaceHandlers())
+	nc := newNamespaceConn(c, namespace, nil)
+
+	go func() {
+		for {
+			select {
+			case pkg := <-c.writeChan:
+				if ack, ok := nc.ack.LoadAndDelete(pkg.Header.ID); ok && reply != nil {
+					_, _ = ack.(*funcHandler).Call([]reflect.Value{reflect.ValueOf(reply)})
+				}
+			case <-c.quitChan:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(c.quitChan)
+	})
+
+	return nc
+}
+
+func TestPeerMeshAdapter(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, recorders := newPeerMeshServers(t)
+	servers[1].JoinRoom("/chat", "news", newLockedRecorder("2", "/chat", nil))
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "first"))
+	should.True(servers[1].BroadcastToNamespace("/chat", "second"))
+
+	must.Eventually(func() bool {
+		return len(recorders[0].received()) == 2 && len(recorders[1].received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	// broadcasts of different nodes aren't ordered
+	should.ElementsMatch([]string{"first", "second"}, recorders[0].received())
+	should.ElementsMatch([]string{"first", "second"}, recorders[1].received())
+
+	should.Equal(2, servers[0].RoomLen("/chat", "lobby"))
+	should.Equal(1, servers[0].RoomLen("/chat", "news"))
+	rooms := servers[0].Rooms("/chat")
+	sort.Strings(rooms)
+	should.Equal([]string{"lobby", "news"}, rooms)
+
+	// closed node isn't waited for
+	must.NoError(servers[1].Close())
+	must.Eventually(func() bool {
+		return len(servers[0].mesh.connected()) == 0
+	}, time.Second, 10*time.Millisecond)
+	should.Equal(1, servers[0].RoomLen("/chat", "lobby"))
+}
+
+func TestPeerMeshAdapterOptions(t *testing.T) {
+	should := assert.New(t)
+
+	assertAdapterOptions(t, func(server *Server) error {
+		return server.PeerMeshAdapter(nil)
+	}, func(server *Server) error {
+		return server.PeerMeshAdapter(&PeerMeshOptions{Dialer: &pipeDialer{}})
+	}, func(server *Server) error {
+		return server.ServePeer(nil)
+	}, func(server *Server) error {
+		return server.ServePeers(nil, nil)
+	})
+
+	opts := &PeerMeshOptions{}
+	should.Equal(defaultPeerRefreshInterval, opts.getRefreshInterval())
+	should.Equal(defaultPeerRequestTimeout, opts.getRequestTimeout())
+
+	peers, err := StaticPeers{"a:1"}.Peers(context.Background())
+	should.NoError(err)
+	should.Equal([]string{"a:1"}, peers)
+}
+
+func TestPeerMeshBroadcastWithAck(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, _ := newPeerMeshServers(t)
+	servers[0].JoinRoom("/chat", "players", newAckConn(t, "a", "/chat", "from a"))
+	servers[1].JoinRoom("/chat", "players", newAckConn(t, "b", "/chat", "from b"))
+	// connection of other node which doesn't acknowledge
+	servers[1].JoinRoom("/chat", "players", newAckConn(t, "c", "/chat", nil))
+
+	replies, err := servers[0].BroadcastToRoomWithAck("/chat", "players", "question", []interface{}{"ready?"}, 200*time.Millisecond)
+	should.ErrorIs(err, ErrAckTimeout)
+	should.Contains(err.Error(), "1 of 3 connections")
+	must.Len(replies, 2)
+	should.Equal(BroadcastReply{ID: "a", Node: servers[0].nodeID, Args: []interface{}{"from a"}}, replies[0])
+	should.Equal(BroadcastReply{ID: "b", Node: servers[1].nodeID, Args: []interface{}{"from b"}}, replies[1])
+
+	// node which is gone isn't waited for
+	must.NoError(servers[1].Close())
+	must.Eventually(func() bool {
+		return len(servers[0].mesh.connected()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	replies, err = servers[0].BroadcastToRoomWithAck("/chat", "players", "question", nil, time.Second)
+	should.NoError(err)
+	should.Len(replies, 1)
+}
+
+func TestPeerMeshFetchSockets(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, _ := newPeerMeshServers(t)
+	servers[1].JoinRoom("/chat", "news", newLockedRecorder("2", "/chat", nil))
+
+	sockets, err := servers[0].FetchSockets("/chat", "")
+	must.NoError(err)
+	must.Len(sockets, 3)
+	should.Equal([]string{"1", "1", "2"}, []string{sockets[0].ID, sockets[1].ID, sockets[2].ID})
+	should.ElementsMatch([]string{servers[0].nodeID, servers[1].nodeID}, []string{sockets[0].Node, sockets[1].Node})
+	should.Equal(servers[1].nodeID, sockets[2].Node)
+	should.Equal([]string{"news"}, sockets[2].Rooms)
+	should.Equal("/socket.io/?token=2", sockets[2].Handshake.URL)
+
+	sockets, err = servers[1].FetchSockets("/chat", "news")
+	must.NoError(err)
+	must.Len(sockets, 1)
+	should.Equal("2", sockets[0].ID)
+
+	// waiting for other nodes stops once ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = servers[0].WithContext(ctx).FetchSockets("/chat", "")
+	should.ErrorIs(err, context.Canceled)
+}
+
+func TestPeerMeshServerSideEmit(t *testing.T) {
+	should := assert.New(t)
+
+	servers, _ := newPeerMeshServers(t)
+
+	reloads := make([]chan string, 2)
+	for i, server := range servers {
+		reloads[i] = make(chan string, 1)
+		ch := reloads[i]
+		server.OnServerSideEvent("/chat", "reload", func(key string, version int) {
+			ch <- fmt.Sprintf("%s@%d", key, version)
+		})
+	}
+
+	should.NoError(servers[0].ServerSideEmit("/chat", "reload", "config", 2))
+
+	select {
+	case reload := <-reloads[1]:
+		should.Equal("config@2", reload)
+	case <-time.After(time.Second):
+		t.Fatal("server-side event isn't delivered to other node")
+	}
+
+	select {
+	case <-reloads[0]:
+		should.Fail("server-side event is delivered to node which sent it")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,266 @@
+package socketio
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultPeerHandshakeTimeout = 10 * time.Second
+	// defaultPeerMaxMessageSize is default limit of messages of TCP streams, like of gRPC.
+	defaultPeerMaxMessageSize = 4 << 20
+	// maxPeerAuthSize bounds secret sent by node before it's authenticated.
+	maxPeerAuthSize = 4 << 10
+)
+
+var (
+	// errPeerSecret is returned by streams of nodes which don't know secret of the mesh.
+	errPeerSecret = errors.New("peer mesh: secret doesn't match")
+	// errPeerMessageSize is returned by streams whose messages exceed limit.
+	errPeerMessageSize = errors.New("peer mesh: message is too large")
+)
+
+// TCPPeerDialer opens streams of peer mesh to nodes over TCP, streams are served by
+// Server.ServePeers of those nodes. Messages are written as JSON, one per line. Without TLSConfig
+// and Secret streams are plaintext and unauthenticated, so any host which reaches listener of
+// ServePeers can inject broadcasts and joins of rooms into the cluster: such listener must not be
+// exposed beyond private network of the cluster.
+type TCPPeerDialer struct {
+	// Timeout bounds connecting to node, including TLS handshake, zero leaves it to ctx.
+	Timeout time.Duration
+
+	// TLSConfig : streams use TLS with this configuration when it's set, e.g. with certificate
+	// of this node verified by PeerListenOptions.TLSConfig of other nodes.
+	TLSConfig *tls.Config
+
+	// Secret : shared secret of nodes of mesh, it's sent first on every stream and must match
+	// PeerListenOptions.Secret of other nodes. Set TLSConfig as well, so it isn't sent in clear.
+	Secret string
+
+	// MaxMessageSize bounds messages received from nodes, default is 4 MB.
+	MaxMessageSize int
+}
+
+// Dial connects to node at addr.
+func (d TCPPeerDialer) Dial(ctx context.Context, addr string) (PeerStream, error) {
+	dialer := net.Dialer{Timeout: d.Timeout}
+
+	var conn net.Conn
+	var err error
+	if d.TLSConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: &dialer, Config: d.TLSConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stream := newTCPPeerStream(conn, peerMaxMessageSize(d.MaxMessageSize))
+	if d.Secret != "" {
+		if err = stream.enc.Encode(peerAuth{Secret: d.Secret}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return stream, nil
+}
+
+// PeerListenOptions is configuration of streams served by Server.ServePeers, it matches
+// TCPPeerDialer of other nodes.
+type PeerListenOptions struct {
+	// TLSConfig : streams are accepted over TLS with this configuration when it's set. Nodes are
+	// authenticated by their certificates with ClientAuth tls.RequireAndVerifyClientCert.
+	TLSConfig *tls.Config
+
+	// Secret : streams which don't send this secret first are closed, see TCPPeerDialer.Secret.
+	Secret string
+
+	// HandshakeTimeout bounds TLS handshake and receiving secret, default is 10 seconds.
+	HandshakeTimeout time.Duration
+
+	// MaxMessageSize bounds messages received from nodes, default is 4 MB. Secret is bounded by
+	// 4 KB, so nodes which aren't authenticated yet can't send large messages.
+	MaxMessageSize int
+}
+
+func (o *PeerListenOptions) getTLSConfig() *tls.Config {
+	if o == nil {
+		return nil
+	}
+
+	return o.TLSConfig
+}
+
+func (o *PeerListenOptions) getSecret() string {
+	if o == nil {
+		return ""
+	}
+
+	return o.Secret
+}
+
+func (o *PeerListenOptions) getHandshakeTimeout() time.Duration {
+	if o == nil || o.HandshakeTimeout <= 0 {
+		return defaultPeerHandshakeTimeout
+	}
+
+	return o.HandshakeTimeout
+}
+
+func (o *PeerListenOptions) getMaxMessageSize() int {
+	if o == nil {
+		return defaultPeerMaxMessageSize
+	}
+
+	return peerMaxMessageSize(o.MaxMessageSize)
+}
+
+func peerMaxMessageSize(size int) int {
+	if size <= 0 {
+		return defaultPeerMaxMessageSize
+	}
+
+	return size
+}
+
+// ServePeers serves streams of nodes which dial l with TCPPeerDialer until l fails, e.g. once
+// server is closed, which closes l. Nil opts serve plaintext streams of any host, see
+// TCPPeerDialer.
+func (s *Server) ServePeers(l net.Listener, opts *PeerListenOptions) error {
+	if s.getMesh() == nil {
+		return errors.New("peer mesh adapter isn't set")
+	}
+
+	if config := opts.getTLSConfig(); config != nil {
+		l = tls.NewListener(l, config)
+	}
+
+	s.OnServerShutdownBegin(func() {
+		_ = l.Close()
+	})
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			stream, err := acceptTCPPeerStream(conn, opts)
+			if err != nil {
+				logger.Error("peer accept:", err)
+				_ = conn.Close()
+				return
+			}
+
+			_ = s.ServePeer(stream)
+		}()
+	}
+}
+
+// acceptTCPPeerStream completes TLS handshake of conn and checks secret of node, within
+// handshake timeout of opts.
+func acceptTCPPeerStream(conn net.Conn, opts *PeerListenOptions) (*tcpPeerStream, error) {
+	if err := conn.SetDeadline(time.Now().Add(opts.getHandshakeTimeout())); err != nil {
+		return nil, err
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+	}
+
+	stream := newTCPPeerStream(conn, opts.getMaxMessageSize())
+
+	if secret := opts.getSecret(); secret != "" {
+		var auth peerAuth
+		if err := stream.read(&auth, maxPeerAuthSize); err != nil {
+			return nil, err
+		}
+
+		// hashes have the same length, so comparison doesn't tell length of secret
+		want, got := sha256.Sum256([]byte(secret)), sha256.Sum256([]byte(auth.Secret))
+		if subtle.ConstantTimeCompare(want[:], got[:]) != 1 {
+			return nil, errPeerSecret
+		}
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// peerAuth is the first message of TCP streams of mesh with secret.
+type peerAuth struct {
+	Secret string `json:"secret"`
+}
+
+// tcpPeerStream is PeerStream over TCP connection, messages are lines of JSON of at most
+// maxSize bytes.
+type tcpPeerStream struct {
+	conn    net.Conn
+	enc     *json.Encoder
+	r       *bufio.Reader
+	maxSize int
+}
+
+func newTCPPeerStream(conn net.Conn, maxSize int) *tcpPeerStream {
+	return &tcpPeerStream{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		r:       bufio.NewReader(conn),
+		maxSize: maxSize,
+	}
+}
+
+func (s *tcpPeerStream) Send(msg *PeerMessage) error {
+	return s.enc.Encode(msg)
+}
+
+func (s *tcpPeerStream) Recv() (*PeerMessage, error) {
+	var msg PeerMessage
+	if err := s.read(&msg, s.maxSize); err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// read decodes next line into v, line longer than max bytes isn't buffered, it fails the stream.
+func (s *tcpPeerStream) read(v interface{}, max int) error {
+	var line []byte
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return errPeerMessageSize
+		}
+		line = append(line, chunk...)
+
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+	}
+
+	return json.Unmarshal(line, v)
+}
+
+func (s *tcpPeerStream) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,186 @@
+package socketio
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPPeerMesh(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	listeners := make([]net.Listener, 2)
+	addrs := make([]string, 2)
+	for i := range listeners {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		must.NoError(err)
+		listeners[i] = l
+		addrs[i] = l.Addr().String()
+	}
+
+	served := make([]chan error, 2)
+	meshes := make([]*peerMesh, 2)
+	servers, recorders := newAdapterServers(t, "/chat", func(i int, server *Server) error {
+		// every node dials the other one and itself, which it leaves out once they exchanged ids
+		err := server.PeerMeshAdapter(&PeerMeshOptions{
+			Dialer:          TCPPeerDialer{Timeout: time.Second},
+			Discovery:       StaticPeers(addrs),
+			RefreshInterval: 10 * time.Millisecond,
+		})
+		meshes[i] = server.mesh
+
+		served[i] = make(chan error, 1)
+		go func() {
+			served[i] <- server.ServePeers(listeners[i], nil)
+		}()
+
+		return err
+	}, func() bool {
+		return len(meshes[0].connected()) == 1 && len(meshes[1].connected()) == 1
+	})
+	servers[1].JoinRoom("/chat", "news", newLockedRecorder("2", "/chat", nil))
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "first"))
+	should.True(servers[1].BroadcastToNamespace("/chat", "second"))
+
+	must.Eventually(func() bool {
+		return len(recorders[0].received()) == 2 && len(recorders[1].received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	should.ElementsMatch([]string{"first", "second"}, recorders[0].received())
+	should.ElementsMatch([]string{"first", "second"}, recorders[1].received())
+
+	should.Equal(2, servers[0].RoomLen("/chat", "lobby"))
+	should.Equal(1, servers[0].RoomLen("/chat", "news"))
+	rooms := servers[0].Rooms("/chat")
+	sort.Strings(rooms)
+	should.Equal([]string{"lobby", "news"}, rooms)
+
+	// closed node stops serving its listener and isn't waited for
+	must.NoError(servers[1].Close())
+	select {
+	case err := <-served[1]:
+		should.ErrorIs(err, net.ErrClosed)
+	case <-time.After(time.Second):
+		should.Fail("listener of closed server is served")
+	}
+	must.Eventually(func() bool {
+		return len(meshes[0].connected()) == 0
+	}, time.Second, 10*time.Millisecond)
+	should.Equal(1, servers[0].RoomLen("/chat", "lobby"))
+}
+
+func TestTCPPeerMeshTLS(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	// certificate of httptest is valid for 127.0.0.1
+	certs := httptest.NewUnstartedServer(nil)
+	certs.StartTLS()
+	defer certs.Close()
+
+	listenOpts := &PeerListenOptions{TLSConfig: certs.TLS, Secret: "mesh secret"}
+	dialer := TCPPeerDialer{
+		Timeout:   time.Second,
+		TLSConfig: certs.Client().Transport.(*http.Transport).TLSClientConfig,
+		Secret:    "mesh secret",
+	}
+
+	listeners := make([]net.Listener, 2)
+	addrs := make([]string, 2)
+	for i := range listeners {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		must.NoError(err)
+		listeners[i] = l
+		addrs[i] = l.Addr().String()
+	}
+
+	meshes := make([]*peerMesh, 2)
+	servers, recorders := newAdapterServers(t, "/chat", func(i int, server *Server) error {
+		err := server.PeerMeshAdapter(&PeerMeshOptions{
+			Dialer:          dialer,
+			Discovery:       StaticPeers(addrs),
+			RefreshInterval: 10 * time.Millisecond,
+		})
+		meshes[i] = server.mesh
+
+		go func() {
+			_ = server.ServePeers(listeners[i], listenOpts)
+		}()
+
+		return err
+	}, func() bool {
+		return len(meshes[0].connected()) == 1 && len(meshes[1].connected()) == 1
+	})
+
+	should.True(servers[0].BroadcastToRoom("/chat", "lobby", "first"))
+	must.Eventually(func() bool {
+		return len(recorders[1].received()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// nodes without secret of the mesh or without TLS are refused
+	for _, d := range []TCPPeerDialer{
+		{Timeout: time.Second, TLSConfig: dialer.TLSConfig, Secret: "guess"},
+		{Timeout: time.Second, TLSConfig: dialer.TLSConfig},
+		{Timeout: time.Second, Secret: "mesh secret"},
+	} {
+		stream, err := d.Dial(context.Background(), addrs[0])
+		must.NoError(err)
+
+		_ = stream.Send(&PeerMessage{Type: peerMessageHello, Node: "intruder"})
+		_, err = stream.Recv()
+		should.Error(err, "stream of %+v is served", d)
+		_ = stream.Close()
+	}
+	should.Len(meshes[0].connected(), 1)
+}
+
+func TestTCPPeerMessageSize(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(nil)
+	defer server.Close()
+	must.NoError(server.PeerMeshAdapter(&PeerMeshOptions{
+		Dialer:    TCPPeerDialer{},
+		Discovery: StaticPeers{},
+	}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	must.NoError(err)
+	go func() {
+		_ = server.ServePeers(l, &PeerListenOptions{Secret: "mesh secret", HandshakeTimeout: 5 * time.Second, MaxMessageSize: 1024})
+	}()
+
+	// node which isn't authenticated yet can't send large secret, it's closed before handshake timeout
+	conn, err := net.Dial("tcp", l.Addr().String())
+	must.NoError(err)
+	defer conn.Close()
+
+	start := time.Now()
+	_, _ = conn.Write([]byte(`{"secret":"` + strings.Repeat("a", 16<<10)))
+	_, err = conn.Read(make([]byte, 1))
+	should.Error(err)
+	should.Less(time.Since(start), time.Second)
+
+	// authenticated node can't send messages larger than limit
+	stream, err := TCPPeerDialer{Timeout: time.Second, Secret: "mesh secret"}.Dial(context.Background(), l.Addr().String())
+	must.NoError(err)
+	defer stream.Close()
+
+	must.NoError(stream.Send(&PeerMessage{Type: peerMessageHello, Node: "b"}))
+	_, err = stream.Recv()
+	must.NoError(err)
+
+	_ = stream.Send(&PeerMessage{Type: peerMessageRequest, Room: strings.Repeat("a", 2048)})
+	_, err = stream.Recv()
+	should.Error(err)
+}
@@ -0,0 +1,95 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultPostgresChannel       = "socket.io"
+	defaultPostgresNotifyTimeout = 5 * time.Second
+
+	// postgresMaxPayload is limit of payload of postgres notification, NOTIFY fails with larger
+	// payloads in default builds of postgres.
+	postgresMaxPayload = 8000
+)
+
+// PostgresNotifier sends and receives notifications of postgres channel, e.g. with pgx, Notify
+// runs "SELECT pg_notify($1, $2)" on pool and Listen runs LISTEN on dedicated connection.
+type PostgresNotifier interface {
+	Notify(ctx context.Context, channel, payload string) error
+
+	// Listen calls handle with payload of every notification of channel until ctx is done, it's
+	// called again with backoff once it fails, e.g. when its connection is lost. Notifications
+	// sent while it's down are missed.
+	Listen(ctx context.Context, channel string, handle func(payload string)) error
+}
+
+// PostgresAdapterOptions is configuration of postgres broadcast adapter.
+type PostgresAdapterOptions struct {
+	// Channel carries broadcasts of all the namespaces, default is "socket.io".
+	Channel string
+
+	// NotifyTimeout bounds notification of each broadcast, default is 5 seconds.
+	NotifyTimeout time.Duration
+
+	Notifier PostgresNotifier
+}
+
+func (o *PostgresAdapterOptions) getChannel() string {
+	if o.Channel == "" {
+		return defaultPostgresChannel
+	}
+
+	return o.Channel
+}
+
+func (o *PostgresAdapterOptions) getNotifyTimeout() time.Duration {
+	if o.NotifyTimeout <= 0 {
+		return defaultPostgresNotifyTimeout
+	}
+
+	return o.NotifyTimeout
+}
+
+// PostgresAdapter sets postgres broadcast adapter, based on LISTEN/NOTIFY, for small deployments
+// which have postgres already, used instead of adapter set before by namespaces created
+// afterwards, see RebindAdapter. Notifications are limited to 8000 bytes: larger broadcasts,
+// including their JSON encoded arguments, aren't chunked, they're logged as errors and reach only
+// this node, so adapters of kafka or redis suit large payloads. Rooms are tracked locally, Len and
+// AllRooms give only connections of this node.
+func (s *Server) PostgresAdapter(opts *PostgresAdapterOptions) error {
+	if opts == nil || opts.Notifier == nil {
+		return errors.New("postgres adapter needs notifier")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	bus := newBusAdapter(s.nodeID, func(_ *busRecord, value []byte) error {
+		if len(value) > postgresMaxPayload {
+			return fmt.Errorf("broadcast of %d bytes exceeds limit of postgres notification", len(value))
+		}
+
+		notifyCtx, cancel := context.WithTimeout(ctx, opts.getNotifyTimeout())
+		defer cancel()
+
+		return opts.Notifier.Notify(notifyCtx, opts.getChannel(), string(value))
+	})
+	s.OnServerShutdownBegin(cancel)
+
+	s.setBus(bus)
+
+	go listenPostgres(ctx, opts, bus)
+
+	return nil
+}
+
+func listenPostgres(ctx context.Context, opts *PostgresAdapterOptions, bus *busAdapter) {
+	bus.consume(ctx, "postgres listen", func(ctx context.Context) error {
+		return opts.Notifier.Listen(ctx, opts.getChannel(), func(payload string) {
+			bus.handle([]byte(payload))
+		})
+	})
+}
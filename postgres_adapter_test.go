@@ -0,0 +1,77 @@
+package socketio
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryPostgres delivers every notification to all the listeners of its channel.
+type memoryPostgres struct {
+	mu        sync.Mutex
+	listeners map[string][]func(string)
+}
+
+func (p *memoryPostgres) Notify(_ context.Context, channel, payload string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, handle := range p.listeners[channel] {
+		handle(payload)
+	}
+
+	return nil
+}
+
+func (p *memoryPostgres) Listen(ctx context.Context, channel string, handle func(string)) error {
+	p.mu.Lock()
+	p.listeners[channel] = append(p.listeners[channel], handle)
+	p.mu.Unlock()
+
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func (p *memoryPostgres) listenerCount(channel string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.listeners[channel])
+}
+
+func TestPostgresAdapter(t *testing.T) {
+	should := assert.New(t)
+
+	notifier := &memoryPostgres{listeners: make(map[string][]func(string))}
+	servers, recorders := newAdapterServers(t, "/", func(_ int, server *Server) error {
+		return server.PostgresAdapter(&PostgresAdapterOptions{Channel: "events", Notifier: notifier})
+	}, func() bool {
+		return notifier.listenerCount("events") == 2
+	})
+
+	should.True(servers[0].BroadcastToRoom("/", "lobby", "first"))
+	should.True(servers[1].BroadcastToRoomSet("/", Union("lobby"), "second"))
+
+	// broadcast which doesn't fit notification reaches only local connections
+	should.True(servers[0].BroadcastToRoom("/", "lobby", "large", strings.Repeat("x", postgresMaxPayload)))
+
+	should.Equal([]string{"first", "second", "large"}, recorders[0].received())
+	should.Equal([]string{"first", "second"}, recorders[1].received())
+}
+
+func TestPostgresAdapterOptions(t *testing.T) {
+	should := assert.New(t)
+
+	assertAdapterOptions(t, func(server *Server) error {
+		return server.PostgresAdapter(nil)
+	}, func(server *Server) error {
+		return server.PostgresAdapter(&PostgresAdapterOptions{})
+	})
+
+	should.Equal("socket.io", (&PostgresAdapterOptions{}).getChannel())
+	should.Equal(defaultPostgresNotifyTimeout, (&PostgresAdapterOptions{}).getNotifyTimeout())
+}
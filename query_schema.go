@@ -0,0 +1,255 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrInvalidQuery is message of CONNECT_ERROR packet sent to clients whose handshake query doesn't
+// match schema of namespace, see Server.SetQuerySchema.
+var ErrInvalidQuery = errors.New("invalid handshake query")
+
+// InvalidQueryCode is code in data of CONNECT_ERROR packet of invalid handshake query.
+const InvalidQueryCode = "INVALID_QUERY"
+
+// QueryType is type of value of handshake query parameter.
+type QueryType int
+
+const (
+	// QueryString accepts any value.
+	QueryString QueryType = iota
+	// QueryInt accepts decimal integers.
+	QueryInt
+	// QueryFloat accepts decimal numbers.
+	QueryFloat
+	// QueryBool accepts values of strconv.ParseBool, e.g. "true", "1" or "false".
+	QueryBool
+)
+
+func (t QueryType) String() string {
+	switch t {
+	case QueryString:
+		return "string"
+	case QueryInt:
+		return "int"
+	case QueryFloat:
+		return "float"
+	case QueryBool:
+		return "bool"
+	}
+
+	return "QueryType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// check gives error of value which isn't of type.
+func (t QueryType) check(value string) error {
+	var err error
+	switch t {
+	case QueryString:
+	case QueryInt:
+		_, err = strconv.Atoi(value)
+	case QueryFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case QueryBool:
+		_, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("unknown type %v", t)
+	}
+
+	if err != nil {
+		return fmt.Errorf("expected %v, got %q", t, value)
+	}
+
+	return nil
+}
+
+// QueryParam is expected parameter of handshake query.
+type QueryParam struct {
+	Type QueryType
+	// Optional parameter may be missing, it's checked only when it's present.
+	Optional bool
+}
+
+// QuerySchema is set of parameters expected in handshake query of namespace, see
+// Server.SetQuerySchema. Parameters which aren't declared are allowed.
+type QuerySchema struct {
+	Params map[string]QueryParam
+}
+
+// InvalidQueryParam describes parameter of handshake query which doesn't match schema.
+type InvalidQueryParam struct {
+	Param  string
+	Reason string
+}
+
+// QueryError is error of handshake query which doesn't match schema of namespace.
+type QueryError struct {
+	Namespace string
+	// Params are sorted by name.
+	Params []InvalidQueryParam
+}
+
+func (e *QueryError) Error() string {
+	msg := fmt.Sprintf("%s of namespace (%s):", ErrInvalidQuery, e.Namespace)
+	for _, p := range e.Params {
+		msg += fmt.Sprintf(" %s: %s;", p.Param, p.Reason)
+	}
+
+	return msg[:len(msg)-1]
+}
+
+func (e *QueryError) Unwrap() error {
+	return ErrInvalidQuery
+}
+
+// Validate checks query against schema, it gives *QueryError of namespace listing each
+// parameter which is missing or invalid.
+func (qs *QuerySchema) Validate(namespace string, query url.Values) error {
+	var invalid []InvalidQueryParam
+	for name, param := range qs.Params {
+		if !query.Has(name) {
+			if !param.Optional {
+				invalid = append(invalid, InvalidQueryParam{Param: name, Reason: "missing"})
+			}
+			continue
+		}
+
+		if err := param.Type.check(query.Get(name)); err != nil {
+			invalid = append(invalid, InvalidQueryParam{Param: name, Reason: err.Error()})
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	sort.Slice(invalid, func(i, j int) bool {
+		return invalid[i].Param < invalid[j].Param
+	})
+
+	return &QueryError{Namespace: namespace, Params: invalid}
+}
+
+// SetQuerySchema sets schema of handshake query of namespace. CONNECTs whose query doesn't match
+// it are refused before OnConnect handler with CONNECT_ERROR packet, which message is
+// ErrInvalidQuery and data is {"code": InvalidQueryCode, "namespace": namespace, "params":
+// [{"param": name, "reason": reason}, ...]}. Nil schema removes it.
+func (s *Server) SetQuerySchema(namespace string, schema *QuerySchema) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.setQuerySchema(schema)
+}
+
+func (nh *namespaceHandler) setQuerySchema(schema *QuerySchema) {
+	nh.querySchemaLock.Lock()
+	defer nh.querySchemaLock.Unlock()
+
+	nh.querySchema = schema
+}
+
+// validateQuery checks handshake query against schema of namespace, if any.
+func (nh *namespaceHandler) validateQuery(namespace string, query url.Values) *QueryError {
+	nh.querySchemaLock.RLock()
+	schema := nh.querySchema
+	nh.querySchemaLock.RUnlock()
+
+	if schema == nil {
+		return nil
+	}
+
+	var queryErr *QueryError
+	errors.As(schema.Validate(namespace, query), &queryErr)
+
+	return queryErr
+}
+
+// rejectInvalidQuery refuses CONNECT of handshake query which doesn't match schema.
+func rejectInvalidQuery(c *conn, header parser.Header, queryErr *QueryError) error {
+	params := make([]interface{}, len(queryErr.Params))
+	for i, p := range queryErr.Params {
+		params[i] = map[string]interface{}{
+			"param":  p.Param,
+			"reason": p.Reason,
+		}
+	}
+
+	header.Type = parser.Error
+	c.write(header, reflect.ValueOf(map[string]interface{}{
+		"message": ErrInvalidQuery.Error(),
+		"data": map[string]interface{}{
+			"code":      InvalidQueryCode,
+			"namespace": header.Namespace,
+			"params":    params,
+		},
+	}))
+
+	return nil
+}
+
+// Query gives typed access to handshake query of connection, see HandshakeQuery.
+type Query struct {
+	url.Values
+}
+
+// HandshakeQuery gives query of handshake of conn.
+func HandshakeQuery(conn Conn) Query {
+	u := conn.URL()
+	return Query{Values: u.Query()}
+}
+
+// String gives value of key, empty when it's missing.
+func (q Query) String(key string) string {
+	return q.Get(key)
+}
+
+// Int gives value of key as QueryInt, error when it's missing or invalid.
+func (q Query) Int(key string) (int, error) {
+	v, err := q.lookup(key, QueryInt)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(v)
+}
+
+// Float gives value of key as QueryFloat, error when it's missing or invalid.
+func (q Query) Float(key string) (float64, error) {
+	v, err := q.lookup(key, QueryFloat)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(v, 64)
+}
+
+// Bool gives value of key as QueryBool, error when it's missing or invalid.
+func (q Query) Bool(key string) (bool, error) {
+	v, err := q.lookup(key, QueryBool)
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(v)
+}
+
+func (q Query) lookup(key string, typ QueryType) (string, error) {
+	if !q.Has(key) {
+		return "", fmt.Errorf("query parameter %q is missing", key)
+	}
+
+	v := q.Get(key)
+	if err := typ.check(v); err != nil {
+		return "", fmt.Errorf("query parameter %q: %w", key, err)
+	}
+
+	return v, nil
+}
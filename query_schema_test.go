@@ -0,0 +1,127 @@
+package socketio
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+func TestQuerySchema(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	schema := &QuerySchema{Params: map[string]QueryParam{
+		"room":  {Type: QueryString},
+		"limit": {Type: QueryInt},
+		"ratio": {Type: QueryFloat, Optional: true},
+		"debug": {Type: QueryBool, Optional: true},
+	}}
+
+	should.NoError(schema.Validate("/", url.Values{"room": {"lobby"}, "limit": {"10"}}))
+
+	err := schema.Validate("/", url.Values{"limit": {"ten"}, "debug": {"maybe"}})
+	should.ErrorIs(err, ErrInvalidQuery)
+
+	var queryErr *QueryError
+	must.ErrorAs(err, &queryErr)
+	should.Equal([]InvalidQueryParam{
+		{Param: "debug", Reason: `expected bool, got "maybe"`},
+		{Param: "limit", Reason: `expected int, got "ten"`},
+		{Param: "room", Reason: "missing"},
+	}, queryErr.Params)
+	should.Equal(`invalid handshake query of namespace (/): debug: expected bool, got "maybe"; `+
+		`limit: expected int, got "ten"; room: missing`, err.Error())
+
+	q := Query{Values: url.Values{"limit": {"10"}, "ratio": {"0.5"}, "debug": {"1"}, "room": {"lobby"}}}
+	should.Equal("lobby", q.String("room"))
+
+	limit, err := q.Int("limit")
+	should.NoError(err)
+	should.Equal(10, limit)
+
+	ratio, err := q.Float("ratio")
+	should.NoError(err)
+	should.Equal(0.5, ratio)
+
+	debug, err := q.Bool("debug")
+	should.NoError(err)
+	should.True(debug)
+
+	_, err = q.Int("room")
+	should.EqualError(err, `query parameter "room": expected int, got "lobby"`)
+	_, err = q.Bool("missing")
+	should.EqualError(err, `query parameter "missing" is missing`)
+}
+
+func TestServerSetQuerySchema(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server, uri := newConformanceServer(t)
+
+	limits := make(chan int, 1)
+	server.OnConnect("/feed", func(c Conn) error {
+		limit, err := HandshakeQuery(c).Int("limit")
+		if err != nil {
+			return err
+		}
+		limits <- limit
+		return nil
+	})
+	server.SetQuerySchema("/feed", &QuerySchema{Params: map[string]QueryParam{
+		"limit": {Type: QueryInt},
+		"room":  {Type: QueryString},
+	}})
+
+	connect := func(query string) (*Client, chan error) {
+		client, err := NewClientWithOptions(uri+"?"+query, &ClientOptions{
+			Transports: []transport.Transport{polling.Default},
+		})
+		must.NoError(err)
+
+		connectErrs := make(chan error, 1)
+		client.Socket("/feed").OnError(func(_ Conn, err error) {
+			connectErrs <- err
+		})
+		must.NoError(client.Connect())
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+
+		return client, connectErrs
+	}
+
+	client, connectErrs := connect("limit=many")
+	select {
+	case err := <-connectErrs:
+		var connectErr *ConnectError
+		must.ErrorAs(err, &connectErr)
+		should.Equal(ErrInvalidQuery.Error(), connectErr.Message)
+		should.Equal(map[string]interface{}{
+			"code":      InvalidQueryCode,
+			"namespace": "/feed",
+			"params": []interface{}{
+				map[string]interface{}{"param": "limit", "reason": `expected int, got "many"`},
+				map[string]interface{}{"param": "room", "reason": "missing"},
+			},
+		}, connectErr.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connect with invalid query wasn't refused")
+	}
+	should.False(client.Socket("/feed").Connected())
+	should.Empty(limits)
+
+	_, _ = connect("limit=20&room=lobby")
+	select {
+	case limit := <-limits:
+		should.Equal(20, limit)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connect with valid query wasn't handled")
+	}
+}
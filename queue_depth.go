@@ -0,0 +1,110 @@
+package socketio
+
+import (
+	"sync/atomic"
+)
+
+// QueueDepthBounds are upper bounds of buckets of QueueDepthHistogram.
+var QueueDepthBounds = []int{1, 2, 4, 8, 16, 32, 64, 128}
+
+// QueueDepthHistogram counts packets queued for writers of connections by depth of write queue
+// which they found, including themselves, see Server.QueueDepths.
+type QueueDepthHistogram struct {
+	// Bounds are QueueDepthBounds.
+	Bounds []int
+	// Counts[i] counts depths up to Bounds[i] and above the previous bound, the last one counts
+	// depths above the last bound.
+	Counts []uint64
+}
+
+// Total gives count of all queued packets.
+func (h QueueDepthHistogram) Total() uint64 {
+	var total uint64
+	for _, n := range h.Counts {
+		total += n
+	}
+
+	return total
+}
+
+// QueueDepthFunc is called with connection whose write queue reached depth, see
+// Server.OnQueueDepth.
+type QueueDepthFunc func(conn Conn, depth int)
+
+// queueDepths records depths of write queues of connections of server.
+type queueDepths struct {
+	counts []uint64
+
+	threshold   int
+	onThreshold QueueDepthFunc
+}
+
+func newQueueDepths() *queueDepths {
+	return &queueDepths{counts: make([]uint64, len(QueueDepthBounds)+1)}
+}
+
+// observe records depth of write queue of c once packet of namespace is queued.
+func (q *queueDepths) observe(c *conn, namespace string, depth int) {
+	if q == nil {
+		return
+	}
+
+	i := 0
+	for i < len(QueueDepthBounds) && depth > QueueDepthBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&q.counts[i], 1)
+
+	if q.onThreshold == nil || depth != q.threshold {
+		return
+	}
+
+	if namespace == aliasRootNamespace {
+		namespace = rootNamespace
+	}
+	if nc, ok := c.namespaces.Get(namespace); ok {
+		q.onThreshold(nc, depth)
+	}
+}
+
+func (q *queueDepths) histogram() QueueDepthHistogram {
+	h := QueueDepthHistogram{
+		Bounds: append([]int(nil), QueueDepthBounds...),
+		Counts: make([]uint64, len(QueueDepthBounds)+1),
+	}
+	if q == nil {
+		return h
+	}
+
+	for i := range q.counts {
+		h.Counts[i] = atomic.LoadUint64(&q.counts[i])
+	}
+
+	return h
+}
+
+// QueueDepth gives number of packets waiting for the writer of connection, it grows when
+// transport doesn't keep up with emitted packets.
+func (nc *namespaceConn) QueueDepth() int {
+	return int(atomic.LoadInt64(&nc.stats.queued))
+}
+
+// QueueDepths gives histogram of depths of write queues of connections, observed each time
+// a packet is queued, so backpressure shows up before clients time out.
+func (s *Server) QueueDepths() QueueDepthHistogram {
+	return s.queueDepths.histogram()
+}
+
+// OnQueueDepth sets f called when write queue of connection reaches threshold, it's called
+// again once the queue drained below and reaches threshold again. f is called by the
+// emitting goroutine, so it must not block.
+func (s *Server) OnQueueDepth(threshold int, f QueueDepthFunc) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.queueDepths.threshold = threshold
+	s.queueDepths.onThreshold = f
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestQueueDepth(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	reached := make(chan int, 4)
+	should.NoError(server.OnQueueDepth(2, func(conn Conn, depth int) {
+		should.Equal(rootNamespace, conn.Namespace())
+		reached <- depth
+	}))
+
+	c := newConn(addrEngineConn{id: "1"}, nil)
+	c.queueDepths = server.queueDepths
+	nc := newNamespaceConn(c, rootNamespace, nil)
+	c.namespaces.Set(rootNamespace, nc)
+
+	// writer doesn't take packets, so they wait in the queue
+	for i := 0; i < 3; i++ {
+		go c.write(parser.Header{Type: parser.Event, Namespace: aliasRootNamespace})
+	}
+	should.Eventually(func() bool {
+		return nc.QueueDepth() == 3
+	}, 5*time.Second, time.Millisecond)
+
+	select {
+	case depth := <-reached:
+		should.Equal(2, depth)
+	case <-time.After(5 * time.Second):
+		t.Fatal("threshold isn't reported")
+	}
+	should.Empty(reached)
+
+	h := server.QueueDepths()
+	should.Equal(QueueDepthBounds, h.Bounds)
+	should.Equal([]uint64{1, 1, 1, 0, 0, 0, 0, 0, 0}, h.Counts)
+	should.Equal(uint64(3), h.Total())
+
+	for i := 0; i < 3; i++ {
+		<-c.writeChan
+	}
+	should.Eventually(func() bool {
+		return nc.QueueDepth() == 0
+	}, 5*time.Second, time.Millisecond)
+}
@@ -0,0 +1,127 @@
+package socketio
+
+import (
+	"sync"
+)
+
+// SetReadBudget sets global budget in bytes of payloads of received events which are in flight,
+// i.e. decoded but not handled yet, e.g. queued for handlers of serialized events. Payload is
+// accounted by its encoded size. Once the budget is exceeded, connections which hold at least
+// average share of in flight payloads stop reading until it's freed. Zero, the default, disables
+// the budget.
+func (s *Server) SetReadBudget(bytes int64) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	if bytes <= 0 {
+		s.readBudget = nil
+		return nil
+	}
+
+	s.readBudget = newReadBudget(bytes)
+
+	return nil
+}
+
+// InflightBytes gives size in bytes of payloads of received events which aren't handled yet.
+// It's tracked only when read budget is set, see SetReadBudget.
+func (s *Server) InflightBytes() int64 {
+	return s.readBudget.inflight()
+}
+
+// readBudget accounts in flight payloads of all connections of server.
+type readBudget struct {
+	limit int64
+
+	used    int64
+	holders map[*conn]int64
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+func newReadBudget(limit int64) *readBudget {
+	b := &readBudget{
+		limit:   limit,
+		holders: make(map[*conn]int64),
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+func (b *readBudget) inflight() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.used
+}
+
+func (b *readBudget) acquire(c *conn, n int) {
+	if b == nil || n <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used += int64(n)
+	b.holders[c] += int64(n)
+}
+
+func (b *readBudget) release(c *conn, n int) {
+	if b == nil || n <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= int64(n)
+	if b.holders[c] -= int64(n); b.holders[c] <= 0 {
+		delete(b.holders, c)
+	}
+
+	b.cond.Broadcast()
+}
+
+// wait blocks reader of connection while budget is exceeded and connection is one of
+// the heaviest holders, until the budget is freed or connection is closed.
+func (b *readBudget) wait(c *conn) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.used > b.limit && b.heavy(c) && !c.closed() {
+		b.cond.Wait()
+	}
+}
+
+// heavy reports whether connection holds at least average of in flight payloads of holders.
+func (b *readBudget) heavy(c *conn) bool {
+	held := b.holders[c]
+	if held == 0 {
+		return false
+	}
+
+	return held*int64(len(b.holders)) >= b.used
+}
+
+// wake lets paused readers check whether their connections were closed.
+func (b *readBudget) wake() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.cond.Broadcast()
+}
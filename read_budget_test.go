@@ -0,0 +1,71 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBudget(t *testing.T) {
+	should := assert.New(t)
+
+	b := newReadBudget(100)
+	heavy := &conn{quitChan: make(chan struct{})}
+	light := &conn{quitChan: make(chan struct{})}
+
+	b.acquire(heavy, 150)
+	b.acquire(light, 10)
+	should.Equal(int64(160), b.inflight())
+
+	waited := func(c *conn) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			b.wait(c)
+			close(done)
+		}()
+
+		return done
+	}
+
+	select {
+	case <-waited(light):
+	case <-time.After(5 * time.Second):
+		t.Fatal("light connection is paused")
+	}
+
+	done := waited(heavy)
+	select {
+	case <-done:
+		t.Fatal("heaviest connection isn't paused over budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.release(heavy, 100)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection isn't resumed once budget is freed")
+	}
+
+	b.acquire(heavy, 200)
+	done = waited(heavy)
+
+	close(heavy.quitChan)
+	b.wake()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closed connection is paused")
+	}
+
+	b.release(heavy, 250)
+	b.release(light, 10)
+	should.Zero(b.inflight())
+	should.Empty(b.holders)
+
+	var disabled *readBudget
+	disabled.acquire(heavy, 10)
+	disabled.wait(heavy)
+	should.Zero(disabled.inflight())
+}
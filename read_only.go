@@ -0,0 +1,55 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// ErrReadOnly is the error of events received from read-only connection, see SetReadOnly.
+var ErrReadOnly = errors.New("read-only connection")
+
+// SetReadOnly makes connection an observer, e.g. of dashboard, which joins rooms and receives
+// events, but events it emits aren't handled, their ack gets an error like
+// {"error": "read-only connection", "event": "message"}. Acks of events emitted to connection
+// are still handled. It's usually set by OnConnect handler.
+func (nc *namespaceConn) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+
+	atomic.StoreInt32(&nc.readOnly, v)
+}
+
+// ReadOnly reports whether connection is read-only, see SetReadOnly.
+func (nc *namespaceConn) ReadOnly() bool {
+	return atomic.LoadInt32(&nc.readOnly) == 1
+}
+
+// rejectReadOnly answers event received from read-only connection with error ack.
+func rejectReadOnly(c *conn, conn *namespaceConn, event string, header parser.Header, size int) error {
+	conn.Logger().Info("Event of read-only connection is rejected", "event", event)
+
+	if c.observeEvent != nil {
+		c.observeEvent(conn, EventMetrics{
+			Namespace:   conn.Namespace(),
+			Event:       event,
+			PayloadSize: size,
+			Err:         fmt.Errorf("%w: event %q", ErrReadOnly, event),
+		})
+	}
+
+	if header.NeedAck {
+		header.Type = parser.Ack
+		c.write(header, reflect.ValueOf(map[string]interface{}{
+			"error": ErrReadOnly.Error(),
+			"event": event,
+		}))
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package socketio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestReadOnlyConn(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	handlers := newNamespaceHandlers()
+	nh := newNamespaceHandler("/dashboard", nil)
+	handlers.Set("/dashboard", nh)
+
+	c := newConn(addrEngineConn{id: "sid1"}, handlers)
+	nc := newNamespaceConn(c, "/dashboard", nh.broadcast)
+	c.namespaces.Set("/dashboard", nc)
+
+	var handled int
+	nh.OnEvent("message", func(Conn) {
+		handled++
+	})
+
+	should.False(nc.ReadOnly())
+	nc.SetReadOnly(true)
+	should.True(nc.ReadOnly())
+
+	nc.Join("stats")
+	should.Equal(1, nh.broadcast.Len("stats"), "read-only connection joins rooms")
+
+	written := make(chan outgoingPacket, 1)
+	go func() {
+		written <- <-c.writeChan
+	}()
+
+	header := parser.Header{Type: parser.Event, Namespace: "/dashboard", ID: 7, NeedAck: true}
+	must.NoError(handleEventPacket(c, nc, nh, "message", header, nil, 10))
+	should.Zero(handled, "event of read-only connection isn't handled")
+
+	pkg := <-written
+	should.Equal(parser.Ack, pkg.Header.Type)
+	should.Equal(uint64(7), pkg.Header.ID)
+	should.Equal([]interface{}{map[string]interface{}{
+		"error": "read-only connection",
+		"event": "message",
+	}}, pkg.Data)
+
+	nc.SetReadOnly(false)
+	must.NoError(handleEventPacket(c, nc, nh, "message", parser.Header{Type: parser.Event, Namespace: "/dashboard"}, nil, 10))
+	should.Equal(1, handled)
+}
@@ -0,0 +1,89 @@
+package socketio
+
+import (
+	"sync"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// PauseReads stops reading frames of connection, e.g. while its big upload is processed, until
+// ResumeReads. Frames stay in transport, so client is slowed down by backpressure instead of
+// being disconnected. It applies to all namespaces of connection. Transport of engine.io session
+// is paused when it supports it, e.g. polling holds requests of client and sends nothing to it
+// meanwhile, otherwise reader of connection stops reading. Engine.io pings aren't answered
+// meanwhile either, so pause must be shorter than ping interval plus ping timeout.
+func (nc *namespaceConn) PauseReads() {
+	nc.conn.readPause.pause(nc.conn.Conn)
+}
+
+// ResumeReads resumes reading frames of connection paused by PauseReads.
+func (nc *namespaceConn) ResumeReads() {
+	nc.conn.readPause.resume()
+}
+
+// ReadsPaused reports whether reads of connection are paused, see PauseReads.
+func (nc *namespaceConn) ReadsPaused() bool {
+	return nc.conn.readPause.paused()
+}
+
+// readPause pauses transport of connection, or blocks its reader when transport can't be paused.
+type readPause struct {
+	mu sync.Mutex
+	// resumed is closed by resume, it's nil while reader isn't blocked.
+	resumed chan struct{}
+	// transport is paused instead of reader, it's nil while it isn't paused.
+	transport engineio.ReadPauser
+}
+
+func (p *readPause) pause(conn engineio.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed != nil || p.transport != nil {
+		return
+	}
+
+	if transport, ok := conn.(engineio.ReadPauser); ok && transport.PauseReads() {
+		p.transport = transport
+		return
+	}
+	p.resumed = make(chan struct{})
+}
+
+func (p *readPause) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.transport != nil {
+		p.transport.ResumeReads()
+		p.transport = nil
+	}
+
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+func (p *readPause) paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resumed != nil || p.transport != nil
+}
+
+// wait blocks reader while reads are paused, until they're resumed or connection is closed.
+func (p *readPause) wait(quit <-chan struct{}) {
+	p.mu.Lock()
+	resumed := p.resumed
+	p.mu.Unlock()
+
+	if resumed == nil {
+		return
+	}
+
+	select {
+	case <-resumed:
+	case <-quit:
+	}
+}
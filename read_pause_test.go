@@ -0,0 +1,118 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestPauseReads(t *testing.T) {
+	should := assert.New(t)
+
+	c := &conn{quitChan: make(chan struct{})}
+	nc := newNamespaceConn(c, "/", nil)
+
+	waited := func() chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			c.readPause.wait(c.quitChan)
+			close(done)
+		}()
+
+		return done
+	}
+
+	select {
+	case <-waited():
+	case <-time.After(5 * time.Second):
+		t.Fatal("reads aren't paused yet")
+	}
+
+	nc.PauseReads()
+	nc.PauseReads()
+	should.True(nc.ReadsPaused())
+
+	done := waited()
+	select {
+	case <-done:
+		t.Fatal("reader isn't paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	nc.ResumeReads()
+	should.False(nc.ReadsPaused())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader isn't resumed")
+	}
+	nc.ResumeReads()
+
+	nc.PauseReads()
+	done = waited()
+	close(c.quitChan)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader of closed connection is paused")
+	}
+}
+
+// pausedEngineConn is engine.io connection whose transport can be paused when supported is set.
+type pausedEngineConn struct {
+	engineio.Conn
+	supported bool
+	paused    int
+}
+
+func (c *pausedEngineConn) PauseReads() bool {
+	if c.supported {
+		c.paused++
+	}
+
+	return c.supported
+}
+
+func (c *pausedEngineConn) ResumeReads() {
+	c.paused--
+}
+
+func TestPauseReadsTransport(t *testing.T) {
+	should := assert.New(t)
+
+	engineConn := &pausedEngineConn{supported: true}
+	c := &conn{Conn: engineConn, quitChan: make(chan struct{})}
+	nc := newNamespaceConn(c, "/", nil)
+
+	// transport is paused instead of reader
+	nc.PauseReads()
+	nc.PauseReads()
+	should.True(nc.ReadsPaused())
+	should.Equal(1, engineConn.paused)
+
+	done := make(chan struct{})
+	go func() {
+		c.readPause.wait(c.quitChan)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader is paused with transport")
+	}
+
+	nc.ResumeReads()
+	nc.ResumeReads()
+	should.False(nc.ReadsPaused())
+	should.Zero(engineConn.paused)
+
+	// reader is paused when transport can't be
+	engineConn.supported = false
+	nc.PauseReads()
+	should.True(nc.ReadsPaused())
+	should.Zero(engineConn.paused)
+	nc.ResumeReads()
+}
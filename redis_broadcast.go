@@ -1,20 +1,37 @@
 package socketio
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
 )
 
 // redisBroadcast gives Join, Leave & BroadcastTO server API support to socket.io along with room management
 // map of rooms where each room contains a map of connection id to connections in that room
 type redisBroadcast struct {
-	pub *redis.PubSubConn
-	sub *redis.PubSubConn
+	// pub is redialed when it's lost, sub is replaced by dispatch.
+	pub     *redis.PubSubConn
+	pubLock sync.RWMutex
+	sub     *redis.PubSubConn
+	subLock sync.Mutex
+
+	// quit stops dispatch and stream of broadcast once it's closed.
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	// doLock serializes commands on pub, redigo connections aren't safe for concurrent use.
+	doLock sync.Mutex
+
+	// shardAddr is node of cluster which serves channels in sharded mode, see redirect.
+	shardAddr string
 
 	nsp        string
 	uid        string
@@ -22,30 +39,62 @@ type redisBroadcast struct {
 	reqChannel string
 	resChannel string
 
-	requests map[string]interface{}
+	requests *requestRegistry
 
 	rooms map[string]map[string]Conn
+	tree  roomTree
 
 	lock sync.RWMutex
+
+	// members gives connections for room members, which are counted across cluster
+	// only when onEmpty is set, see Server.OnRoomEmpty.
+	opts    *RedisAdapterOptions
+	members *redis.Pool
+	onEmpty func(room, lastConnID string)
+
+	// joins wakes tickers of rooms, see Server.RoomTicker.
+	joins *roomWatchers
+
+	// seen drops duplicates of broadcasts relayed back to node.
+	seen seenEnvelopes
+
+	// cluster tells nodes which requests expect to respond, see watchCluster.
+	cluster *clusterNodes
+
+	// health is degraded while subscription is lost, see Server.OnAdapterError.
+	health adapterHealth
+
+	// onServerSide is called with ServerSideEmit of other nodes, it's set by namespace.
+	onServerSide func(event string, args []json.RawMessage)
+	// onRemote is called with broadcasts of other nodes once they're delivered, it's set by
+	// namespace.
+	onRemote func(record BroadcastRecord)
 }
 
+const (
+	redisResubscribeBackoff    = 100 * time.Millisecond
+	redisMaxResubscribeBackoff = 5 * time.Second
+)
+
 // request types
 const (
-	roomLenReqType   = "0"
-	clearRoomReqType = "1"
-	allRoomReqType   = "2"
+	roomLenReqType        = "0"
+	clearRoomReqType      = "1"
+	allRoomReqType        = "2"
+	explainReqType        = "3"
+	fetchSocketsReqType   = "4"
+	serverSideEmitReqType = "5"
+	broadcastAckReqType   = "6"
+	remoteJoinReqType     = "7"
 )
 
 // request structs
 type roomLenRequest struct {
-	RequestType string
-	RequestID   string
-	Room        string
-	numSub      int        `json:"-"`
-	msgCount    int        `json:"-"`
-	connections int        `json:"-"`
-	mutex       sync.Mutex `json:"-"`
-	done        chan bool  `json:"-"`
+	RequestType    string
+	RequestID      string
+	Room           string
+	connections    int `json:"-"`
+	pendingRequest `json:"-"`
 }
 
 type clearRoomRequest struct {
@@ -56,79 +105,231 @@ type clearRoomRequest struct {
 }
 
 type allRoomRequest struct {
-	RequestType string
-	RequestID   string
-	rooms       map[string]bool `json:"-"`
-	numSub      int             `json:"-"`
-	msgCount    int             `json:"-"`
-	mutex       sync.Mutex      `json:"-"`
-	done        chan bool       `json:"-"`
+	RequestType    string
+	RequestID      string
+	rooms          map[string]bool `json:"-"`
+	pendingRequest `json:"-"`
+}
+
+type explainRequest struct {
+	RequestType    string
+	RequestID      string
+	Rooms          string         // JSON of rooms
+	Except         string         // JSON of except rooms
+	counts         map[string]int `json:"-"`
+	pendingRequest `json:"-"`
 }
 
 // response struct
 type roomLenResponse struct {
 	RequestType string
 	RequestID   string
+	NodeID      string
 	Connections int
 }
 
 type allRoomResponse struct {
 	RequestType string
 	RequestID   string
+	NodeID      string
 	Rooms       []string
 }
 
 func newRedisBroadcast(nsp string, opts *RedisAdapterOptions) (*redisBroadcast, error) {
-	addr := opts.getAddr()
-	var redisOpts []redis.DialOption
-	if len(opts.Password) > 0 {
-		redisOpts = append(redisOpts, redis.DialPassword(opts.Password))
-	}
-	if opts.DB > 0 {
-		redisOpts = append(redisOpts, redis.DialDatabase(opts.DB))
-	}
-
-	pub, err := redis.Dial(opts.Network, addr, redisOpts...)
+	pub, err := opts.dial()
 	if err != nil {
 		return nil, err
 	}
 
-	sub, err := redis.Dial(opts.Network, addr, redisOpts...)
-	if err != nil {
-		return nil, err
+	uid := opts.NodeID
+	if uid == "" {
+		uid = newV4UUID()
 	}
 
-	subConn := &redis.PubSubConn{Conn: sub}
-	pubConn := &redis.PubSubConn{Conn: pub}
-
-	if err = subConn.PSubscribe(fmt.Sprintf("%s#%s#*", opts.Prefix, nsp)); err != nil {
-		return nil, err
-	}
-
-	uid := newV4UUID()
 	rbc := &redisBroadcast{
 		rooms:      make(map[string]map[string]Conn),
-		requests:   make(map[string]interface{}),
-		sub:        subConn,
-		pub:        pubConn,
+		tree:       make(roomTree),
+		requests:   newRequestRegistry(),
+		joins:      &roomWatchers{},
+		quit:       make(chan struct{}),
+		members:    opts.newPool(),
+		pub:        &redis.PubSubConn{Conn: pub},
 		key:        fmt.Sprintf("%s#%s#%s", opts.Prefix, nsp, uid),
 		reqChannel: fmt.Sprintf("%s-request#%s", opts.Prefix, nsp),
 		resChannel: fmt.Sprintf("%s-response#%s", opts.Prefix, nsp),
 		nsp:        nsp,
 		uid:        uid,
+		opts:       opts,
+	}
+
+	switch {
+	case opts.NodeCompatible:
+		rbc.key, rbc.reqChannel, rbc.resChannel, _ = nodeChannels(opts.Prefix, nsp, uid)
+	case opts.Sharded:
+		rbc.key, rbc.reqChannel, rbc.resChannel = shardedChannels(opts.Prefix, nsp)
 	}
 
-	if err = subConn.Subscribe(rbc.reqChannel, rbc.resChannel); err != nil {
+	if rbc.sub, err = rbc.subscribe(); err != nil {
+		_ = pub.Close()
 		return nil, err
 	}
 
 	go rbc.dispatch()
+	if opts.Streams && !opts.NodeCompatible {
+		go rbc.readStream()
+	}
 
 	return rbc, nil
 }
 
+// subscribe dials connection subscribed to broadcasts, requests and responses of namespace.
+func (bc *redisBroadcast) subscribe() (*redis.PubSubConn, error) {
+	if bc.sharded() {
+		return bc.shardedSubscribe()
+	}
+
+	conn, err := bc.opts.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &redis.PubSubConn{Conn: conn}
+	if bc.nodeCompatible() {
+		if err = sub.PSubscribe(bc.key + "*"); err == nil {
+			err = sub.Subscribe(bc.reqChannel, bc.resChannel, bc.ownResChannel())
+		}
+	} else if err = sub.PSubscribe(fmt.Sprintf("%s#%s#*", bc.opts.Prefix, bc.nsp)); err == nil {
+		err = sub.Subscribe(bc.reqChannel, bc.resChannel)
+	}
+	if err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// resubscribe replaces lost subscription, e.g. after failover of redis master, retrying until
+// it succeeds or broadcast is closed. Broadcasts published while node is resubscribing are lost.
+func (bc *redisBroadcast) resubscribe() {
+	backoff := redisResubscribeBackoff
+	for {
+		sub, err := bc.subscribe()
+		if err == nil {
+			bc.subLock.Lock()
+			defer bc.subLock.Unlock()
+
+			if bc.closed() {
+				_ = sub.Close()
+				return
+			}
+			bc.sub = sub
+			return
+		}
+
+		logger.Error("redis resubscribe:", err)
+		bc.degrade(fmt.Errorf("redis resubscribe: %w", err))
+
+		if !bc.sleep(backoff) {
+			return
+		}
+		backoff = min(2*backoff, redisMaxResubscribeBackoff)
+	}
+}
+
+// Close stops subscription of broadcast and closes its connections, e.g. once RebindAdapter
+// replaced it.
+func (bc *redisBroadcast) Close() error {
+	bc.closeOnce.Do(func() {
+		close(bc.quit)
+	})
+
+	bc.subLock.Lock()
+	_ = bc.sub.Close()
+	bc.subLock.Unlock()
+
+	_ = bc.members.Close()
+
+	bc.pubLock.Lock()
+	defer bc.pubLock.Unlock()
+
+	return bc.pub.Close()
+}
+
+func (bc *redisBroadcast) closed() bool {
+	select {
+	case <-bc.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, it reports false when broadcast is closed meanwhile.
+func (bc *redisBroadcast) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-bc.quit:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// do runs command on publish connection, which is redialed once when it's lost or redis cluster
+// redirected it.
+func (bc *redisBroadcast) do(cmd string, args ...interface{}) (interface{}, error) {
+	bc.doLock.Lock()
+	defer bc.doLock.Unlock()
+
+	bc.pubLock.RLock()
+	pub := bc.pub
+	bc.pubLock.RUnlock()
+
+	reply, err := pub.Conn.Do(cmd, args...)
+	if addr, moved := movedAddr(err); moved {
+		bc.redirect(addr)
+	} else if err == nil || pub.Conn.Err() == nil {
+		return reply, err
+	}
+
+	conn, dialErr := bc.dial()
+	if dialErr != nil {
+		return nil, err
+	}
+
+	bc.pubLock.Lock()
+	if bc.pub == pub {
+		_ = pub.Close()
+		bc.pub = &redis.PubSubConn{Conn: conn}
+	} else {
+		_ = conn.Close()
+	}
+	pub = bc.pub
+	bc.pubLock.Unlock()
+
+	return pub.Conn.Do(cmd, args...)
+}
+
 // AllRooms gives list of all rooms available for redisBroadcast.
 func (bc *redisBroadcast) AllRooms() []string {
+	rooms, err := bc.allRoomsContext(context.Background())
+	if err != nil {
+		logger.Info("redis adapter rooms query is partial", "namespace", bc.nsp, "error", err.Error())
+	}
+
+	return rooms
+}
+
+// allRoomsContext gives rooms of every node which responded before ctx is done or request
+// timeout, error tells rooms are partial.
+func (bc *redisBroadcast) allRoomsContext(ctx context.Context) ([]string, error) {
+	if bc.nodeCompatible() {
+		return bc.nodeAllRooms(ctx)
+	}
+
 	req := allRoomRequest{
 		RequestType: allRoomReqType,
 		RequestID:   newV4UUID(),
@@ -136,65 +337,99 @@ func (bc *redisBroadcast) AllRooms() []string {
 	reqJSON, _ := json.Marshal(&req)
 
 	req.rooms = make(map[string]bool)
-	numSub, _ := bc.getNumSub(bc.reqChannel)
-	req.numSub = numSub
-	req.done = make(chan bool, 1)
+	numSub, err := bc.getNumSub(bc.reqChannel)
+	if err != nil {
+		return bc.allRooms(), err
+	}
 
-	bc.requests[req.RequestID] = &req
-	_, err := bc.pub.Conn.Do("PUBLISH", bc.reqChannel, reqJSON)
+	release, err := bc.register(ctx, req.RequestID, &req, numSub, bc.opts.getRequestTimeout())
 	if err != nil {
-		return []string{} // if error occurred,return empty
+		return bc.allRooms(), err
+	}
+	defer release()
+
+	if _, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON); err != nil {
+		return bc.allRooms(), err
 	}
 
-	<-req.done
+	err = req.wait()
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
 
 	rooms := make([]string, 0, len(req.rooms))
 	for room := range req.rooms {
 		rooms = append(rooms, room)
 	}
 
-	delete(bc.requests, req.RequestID)
-	return rooms
+	return rooms, err
 }
 
 // Join joins the given connection to the redisBroadcast room.
 func (bc *redisBroadcast) Join(room string, connection Conn) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
 	if _, ok := bc.rooms[room]; !ok {
 		bc.rooms[room] = make(map[string]Conn)
+		bc.tree.add(room)
 	}
 
+	_, member := bc.rooms[room][connection.ID()]
 	bc.rooms[room][connection.ID()] = connection
+
+	bc.lock.Unlock()
+
+	if !member {
+		bc.roomJoined(room, connection.ID())
+	}
 }
 
 // Leave leaves the given connection from given room (if exist)
 func (bc *redisBroadcast) Leave(room string, connection Conn) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
+	var left, empty bool
 	if connections, ok := bc.rooms[room]; ok {
+		_, left = connections[connection.ID()]
 		delete(connections, connection.ID())
 
 		if len(connections) == 0 {
 			delete(bc.rooms, room)
+			bc.tree.remove(room)
+			empty = true
 		}
 	}
+
+	bc.lock.Unlock()
+
+	if left {
+		bc.roomLeft(room, connection.ID(), empty)
+	}
 }
 
 // LeaveAll leaves the given connection from all rooms.
 func (bc *redisBroadcast) LeaveAll(connection Conn) {
 	bc.lock.Lock()
-	defer bc.lock.Unlock()
 
+	left := make(map[string]bool)
 	for room, connections := range bc.rooms {
+		if _, ok := connections[connection.ID()]; !ok {
+			continue
+		}
 		delete(connections, connection.ID())
 
-		if len(connections) == 0 {
+		left[room] = len(connections) == 0
+		if left[room] {
 			delete(bc.rooms, room)
+			bc.tree.remove(room)
 		}
 	}
+
+	bc.lock.Unlock()
+
+	for room, empty := range left {
+		bc.roomLeft(room, connection.ID(), empty)
+	}
 }
 
 // Clear clears the room.
@@ -203,7 +438,9 @@ func (bc *redisBroadcast) Clear(room string) {
 	defer bc.lock.Unlock()
 
 	delete(bc.rooms, room)
+	bc.tree.remove(room)
 	go bc.publishClear(room)
+	go bc.forgetMembers(room)
 }
 
 // Send sends given event & args to all the connections in the specified room.
@@ -214,7 +451,7 @@ func (bc *redisBroadcast) Send(room, event string, args ...interface{}) {
 	connections, ok := bc.rooms[room]
 	if ok {
 		for _, connection := range connections {
-			connection.Emit(event, args...)
+			broadcastEmit(connection, nil, event, args...)
 		}
 	}
 
@@ -228,12 +465,34 @@ func (bc *redisBroadcast) SendAll(event string, args ...interface{}) {
 
 	for _, connections := range bc.rooms {
 		for _, connection := range connections {
-			connection.Emit(event, args...)
+			broadcastEmit(connection, nil, event, args...)
 		}
 	}
 	bc.publishMessage("", event, args...)
 }
 
+// SendRoomSet sends given event & args to all the connections selected by the room set.
+func (bc *redisBroadcast) SendRoomSet(set *RoomSet, event string, args ...interface{}) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	for _, connection := range set.eval(bc.rooms, bc.tree) {
+		broadcastEmit(connection, nil, event, args...)
+	}
+
+	bc.publishRoomSetMessage(set, event, args...)
+}
+
+// ForEachRoomSet calls f for every local connection selected by the room set.
+func (bc *redisBroadcast) ForEachRoomSet(set *RoomSet, f EachFunc) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	for _, connection := range set.eval(bc.rooms, bc.tree) {
+		f(connection)
+	}
+}
+
 // ForEach sends data returned by DataFunc, if room does not exits sends nothing.
 func (bc *redisBroadcast) ForEach(room string, f EachFunc) {
 	bc.lock.RLock()
@@ -251,6 +510,21 @@ func (bc *redisBroadcast) ForEach(room string, f EachFunc) {
 
 // Len gives number of connections in the room.
 func (bc *redisBroadcast) Len(room string) int {
+	connections, err := bc.lenContext(context.Background(), room)
+	if err != nil {
+		logger.Info("redis adapter room length query is partial", "namespace", bc.nsp, "room", room, "error", err.Error())
+	}
+
+	return connections
+}
+
+// lenContext counts connections of room on every node which responded before ctx is done or
+// request timeout, error tells count is partial. It's -1 when request isn't published.
+func (bc *redisBroadcast) lenContext(ctx context.Context, room string) (int, error) {
+	if bc.nodeCompatible() {
+		return bc.nodeLen(ctx, room)
+	}
+
 	req := roomLenRequest{
 		RequestType: roomLenReqType,
 		RequestID:   newV4UUID(),
@@ -259,64 +533,88 @@ func (bc *redisBroadcast) Len(room string) int {
 
 	reqJSON, err := json.Marshal(&req)
 	if err != nil {
-		return -1
+		return -1, err
 	}
 
 	numSub, err := bc.getNumSub(bc.reqChannel)
 	if err != nil {
-		return -1
+		return -1, err
 	}
 
-	req.numSub = numSub
-
-	req.done = make(chan bool, 1)
-
-	bc.requests[req.RequestID] = &req
-	_, err = bc.pub.Conn.Do("PUBLISH", bc.reqChannel, reqJSON)
+	release, err := bc.register(ctx, req.RequestID, &req, numSub, bc.opts.getRequestTimeout())
 	if err != nil {
-		return -1
+		return -1, err
+	}
+	defer release()
+
+	if _, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON); err != nil {
+		return -1, err
 	}
 
-	<-req.done
+	err = req.wait()
 
-	delete(bc.requests, req.RequestID)
-	return req.connections
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	return req.connections, err
 }
 
 // Rooms gives the list of all the rooms available for redisBroadcast in case of
 // no connection is given, in case of a connection is given, it gives
 // list of all the rooms the connection is joined to.
 func (bc *redisBroadcast) Rooms(connection Conn) []string {
-	bc.lock.RLock()
-	defer bc.lock.RUnlock()
-
+	// rooms of other nodes are waited for without lock, so joins and responses go on
 	if connection == nil {
 		return bc.AllRooms()
 	}
 
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
 	return bc.getRoomsByConn(connection)
 }
 
 func (bc *redisBroadcast) onMessage(channel string, msg []byte) error {
+	if bc.sharded() {
+		return bc.onShardedMessage(msg)
+	}
+
 	channelParts := strings.Split(channel, "#")
 	nsp := channelParts[len(channelParts)-2]
 	if bc.nsp != nsp {
 		return nil
 	}
 
-	uid := channelParts[len(channelParts)-1]
+	return bc.onEnvelope(channelParts[len(channelParts)-1], msg)
+}
+
+// onEnvelope delivers broadcast published by node uid to connections of this node.
+func (bc *redisBroadcast) onEnvelope(uid string, msg []byte) error {
 	if bc.uid == uid {
 		return nil
 	}
 
-	var bcMessage map[string][]interface{}
-	err := json.Unmarshal(msg, &bcMessage)
-	if err != nil {
+	var envelope broadcastEnvelope
+	err := json.Unmarshal(msg, &envelope)
+	if err != nil || len(envelope.Opts) < 2 {
 		return errors.New("invalid broadcast message")
 	}
 
-	args := bcMessage["args"]
-	opts := bcMessage["opts"]
+	origin := uid
+	var bcOpts *BroadcastOptions
+	if meta := envelope.Meta; meta != nil {
+		// looping broadcast is dropped, it's not an error of subscription
+		if err := bc.checkLoop(meta); err != nil {
+			logger.Info("drop broadcast", "namespace", bc.nsp, "err", err.Error())
+			return nil
+		}
+
+		bcOpts = &BroadcastOptions{origin: meta.Origin}
+		origin = meta.Origin
+	}
+
+	args := envelope.Args
+	opts := envelope.Opts
 
 	room, ok := opts[0].(string)
 	if !ok {
@@ -328,18 +626,93 @@ func (bc *redisBroadcast) onMessage(channel string, msg []byte) error {
 		return errors.New("invalid event")
 	}
 
+	if len(opts) > 3 {
+		deadline, ok := opts[3].(float64)
+		if !ok {
+			return errors.New("invalid deadline")
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.UnixMilli(int64(deadline)))
+		defer cancel()
+
+		if bcOpts == nil {
+			bcOpts = &BroadcastOptions{}
+		}
+		bcOpts.ctx = ctx
+	}
+
+	if len(opts) > 2 && opts[2] != nil {
+		set, ok := roomSetFromOpt(opts[2])
+		if !ok {
+			return errors.New("invalid room set")
+		}
+
+		bc.sendRoomSet(set, bcOpts, event, args...)
+		bc.remoteBroadcast(BroadcastRecord{RoomSet: set, Event: event, Args: args, Origin: origin})
+		return nil
+	}
+
 	if room != "" {
-		bc.send(room, event, args...)
+		bc.send(room, bcOpts, event, args...)
 	} else {
-		bc.sendAll(event, args...)
+		bc.sendAll(bcOpts, event, args...)
 	}
+	bc.remoteBroadcast(BroadcastRecord{Room: room, Event: event, Args: args, Origin: origin})
 
 	return nil
 }
 
+func (bc *redisBroadcast) setOnRemoteBroadcast(f func(record BroadcastRecord)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onRemote = f
+}
+
+// remoteBroadcast passes broadcast of other node, which is delivered to connections of this node,
+// to namespace.
+func (bc *redisBroadcast) remoteBroadcast(record BroadcastRecord) {
+	bc.lock.RLock()
+	f := bc.onRemote
+	bc.lock.RUnlock()
+
+	if f == nil {
+		return
+	}
+
+	record.Namespace = bc.nsp
+	record.Timestamp = time.Now()
+	f(record)
+}
+
+// checkLoop tells error when broadcast looped back to node, see broadcastEnvelope.
+func (bc *redisBroadcast) checkLoop(meta *envelopeMeta) error {
+	if meta.Origin == bc.uid {
+		return fmt.Errorf("broadcast %s looped back to origin node", meta.ID)
+	}
+
+	if maxHops := bc.maxHops(); meta.Hops > maxHops {
+		return fmt.Errorf("broadcast %s of node %s exceeded %d hops", meta.ID, meta.Origin, maxHops)
+	}
+
+	if meta.ID != "" && bc.seen.seen(meta.ID, time.Now()) {
+		return fmt.Errorf("broadcast %s of node %s is received again", meta.ID, meta.Origin)
+	}
+
+	return nil
+}
+
+func (bc *redisBroadcast) maxHops() int {
+	if bc.opts == nil || bc.opts.MaxHops <= 0 {
+		return defaultMaxHops
+	}
+
+	return bc.opts.MaxHops
+}
+
 // Get the number of subscribers of a channel.
 func (bc *redisBroadcast) getNumSub(channel string) (int, error) {
-	rs, err := bc.pub.Conn.Do("PUBSUB", "NUMSUB", channel)
+	rs, err := bc.do("PUBSUB", bc.numSubCmd(), channel)
 	if err != nil {
 		return 0, err
 	}
@@ -365,7 +738,8 @@ func (bc *redisBroadcast) onRequest(msg []byte) {
 		res = roomLenResponse{
 			RequestType: req["RequestType"],
 			RequestID:   req["RequestID"],
-			Connections: len(bc.rooms[req["Room"]]),
+			NodeID:      bc.uid,
+			Connections: bc.localLen(req["Room"]),
 		}
 		bc.publish(bc.resChannel, &res)
 
@@ -373,10 +747,26 @@ func (bc *redisBroadcast) onRequest(msg []byte) {
 		res := allRoomResponse{
 			RequestType: req["RequestType"],
 			RequestID:   req["RequestID"],
+			NodeID:      bc.uid,
 			Rooms:       bc.allRooms(),
 		}
 		bc.publish(bc.resChannel, &res)
 
+	case explainReqType:
+		bc.onExplainRequest(req)
+
+	case fetchSocketsReqType:
+		bc.onFetchSocketsRequest(req)
+
+	case serverSideEmitReqType:
+		bc.onServerSideEmitRequest(req)
+
+	case broadcastAckReqType:
+		bc.onBroadcastAckRequest(req)
+
+	case remoteJoinReqType:
+		bc.onRemoteJoinRequest(req)
+
 	case clearRoomReqType:
 		if bc.uid == req["UUID"] {
 			return
@@ -393,7 +783,7 @@ func (bc *redisBroadcast) publish(channel string, msg interface{}) {
 		return
 	}
 
-	_, err = bc.pub.Conn.Do("PUBLISH", channel, resJSON)
+	_, err = bc.do(bc.publishCmd(), channel, resJSON)
 	if err != nil {
 		return
 	}
@@ -408,48 +798,101 @@ func (bc *redisBroadcast) onResponse(msg []byte) {
 		return
 	}
 
-	req, ok := bc.requests[res["RequestID"].(string)]
+	requestID, _ := res["RequestID"].(string)
+	req, ok := bc.requests.get(requestID)
 	if !ok {
 		return
 	}
 
-	switch res["RequestType"] {
-	case roomLenReqType:
-		roomLenReq := req.(*roomLenRequest)
+	nodeID, _ := res["NodeID"].(string)
+	connections, _ := res["Connections"].(float64)
 
-		roomLenReq.mutex.Lock()
-		roomLenReq.msgCount++
-		roomLenReq.connections += int(res["Connections"].(float64))
-		roomLenReq.mutex.Unlock()
+	// response of other type than request, e.g. forged, is dropped
+	switch r := req.(type) {
+	case *roomLenRequest:
+		if res["RequestType"] != roomLenReqType {
+			return
+		}
 
-		if roomLenReq.numSub == roomLenReq.msgCount {
-			roomLenReq.done <- true
+		r.respond(nodeID, func() {
+			r.connections += int(connections)
+		})
+
+	case *explainRequest:
+		if res["RequestType"] != explainReqType {
+			return
 		}
 
-	case allRoomReqType:
-		allRoomReq := req.(*allRoomRequest)
-		rooms, ok := res["Rooms"].([]interface{})
-		if !ok {
-			allRoomReq.done <- true
+		r.respond(nodeID, func() {
+			// request is received by this node as well, its connections are local
+			if nodeID != bc.uid {
+				r.counts[nodeID] = int(connections)
+			}
+		})
+
+	case *fetchSocketsRequest:
+		if res["RequestType"] != fetchSocketsReqType {
 			return
 		}
 
-		allRoomReq.mutex.Lock()
-		allRoomReq.msgCount++
-		for _, room := range rooms {
-			allRoomReq.rooms[room.(string)] = true
+		bc.onFetchSocketsResponse(r, msg)
+
+	case *broadcastAckRequest:
+		if res["RequestType"] != broadcastAckReqType {
+			return
 		}
-		allRoomReq.mutex.Unlock()
 
-		if allRoomReq.numSub == allRoomReq.msgCount {
-			allRoomReq.done <- true
+		bc.onBroadcastAckResponse(r, msg)
+
+	case *allRoomRequest:
+		if res["RequestType"] != allRoomReqType {
+			return
 		}
+		rooms, _ := res["Rooms"].([]interface{})
 
-	default:
+		r.respond(nodeID, func() {
+			for _, room := range rooms {
+				if room, ok := room.(string); ok {
+					r.rooms[room] = true
+				}
+			}
+		})
+	}
+}
+
+// watchCluster stops waiting for responses of nodes which cluster reports dead.
+func (bc *redisBroadcast) watchCluster(cluster *clusterNodes) {
+	bc.cluster = cluster
+	cluster.Watch(bc.onNodeDead)
+}
+
+// register tracks request to nodes subscribed to request channel. Nodes known by heartbeats are
+// expected, so dead node which didn't get request isn't stopped waiting for.
+func (bc *redisBroadcast) register(ctx context.Context, id string, req remoteRequest, numSub int, timeout time.Duration) (func(), error) {
+	var nodes []string
+	if bc.cluster != nil {
+		for _, node := range bc.cluster.Nodes() {
+			nodes = append(nodes, node.ID)
+		}
 	}
+	req.pending().expectNodes(nodes)
+
+	return bc.requests.register(ctx, id, req, numSub, timeout)
+}
+
+// onNodeDead stops waiting for responses of the dead node in all pending requests and drops its
+// members of rooms.
+func (bc *redisBroadcast) onNodeDead(nodeID string) {
+	bc.requests.nodeDead(nodeID)
+	bc.forgetNode(nodeID)
 }
 
 func (bc *redisBroadcast) publishClear(room string) {
+	if bc.nodeCompatible() {
+		bc.publishNodeClear(room)
+		return
+	}
+
 	req := clearRoomRequest{
 		RequestType: clearRoomReqType,
 		RequestID:   newV4UUID(),
@@ -465,9 +908,10 @@ func (bc *redisBroadcast) clear(room string) {
 	defer bc.lock.Unlock()
 
 	delete(bc.rooms, room)
+	bc.tree.remove(room)
 }
 
-func (bc *redisBroadcast) send(room string, event string, args ...interface{}) {
+func (bc *redisBroadcast) send(room string, opts *BroadcastOptions, event string, args ...interface{}) {
 	bc.lock.RLock()
 	defer bc.lock.RUnlock()
 
@@ -477,37 +921,72 @@ func (bc *redisBroadcast) send(room string, event string, args ...interface{}) {
 	}
 
 	for _, connection := range connections {
-		connection.Emit(event, args...)
+		broadcastEmit(connection, opts, event, args...)
 	}
 }
 
 func (bc *redisBroadcast) publishMessage(room string, event string, args ...interface{}) {
-	opts := make([]interface{}, 2)
-	opts[0] = room
-	opts[1] = event
+	if err := bc.publishBroadcast(room, nil, time.Time{}, event, args...); err != nil {
+		bc.reportError(fmt.Errorf("publish broadcast: %w", err))
+	}
+}
+
+func (bc *redisBroadcast) publishRoomSetMessage(set *RoomSet, event string, args ...interface{}) {
+	if err := bc.publishBroadcast("", set, time.Time{}, event, args...); err != nil {
+		bc.reportError(fmt.Errorf("publish broadcast: %w", err))
+	}
+}
+
+// publishBroadcast publishes broadcast to room, or to room set when it's not nil, to other nodes.
+// Non-zero deadline is carried in milliseconds, other nodes drop writes which miss it.
+func (bc *redisBroadcast) publishBroadcast(room string, set *RoomSet, deadline time.Time, event string, args ...interface{}) error {
+	if bc.nodeCompatible() {
+		return bc.publishNodeBroadcast(room, set, event, args...)
+	}
 
-	bcMessage := map[string][]interface{}{
-		"opts": opts,
-		"args": args,
+	opts := []interface{}{room, event}
+	if set != nil || !deadline.IsZero() {
+		opts = append(opts, set)
+	}
+	if !deadline.IsZero() {
+		opts = append(opts, deadline.UnixMilli())
+	}
+
+	bcMessage := broadcastEnvelope{
+		Opts: opts,
+		Args: args,
+		Meta: &envelopeMeta{Origin: bc.uid, ID: newV4UUID()},
 	}
 	bcMessageJSON, err := json.Marshal(bcMessage)
 	if err != nil {
-		return
+		return err
 	}
 
-	_, err = bc.pub.Conn.Do("PUBLISH", bc.key, bcMessageJSON)
-	if err != nil {
-		return
+	if bc.opts.Streams {
+		return bc.appendStream(bcMessageJSON)
 	}
+
+	_, err = bc.do(bc.publishCmd(), bc.key, bcMessageJSON)
+
+	return err
 }
 
-func (bc *redisBroadcast) sendAll(event string, args ...interface{}) {
+func (bc *redisBroadcast) sendRoomSet(set *RoomSet, opts *BroadcastOptions, event string, args ...interface{}) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	for _, connection := range set.eval(bc.rooms, bc.tree) {
+		broadcastEmit(connection, opts, event, args...)
+	}
+}
+
+func (bc *redisBroadcast) sendAll(opts *BroadcastOptions, event string, args ...interface{}) {
 	bc.lock.RLock()
 	defer bc.lock.RUnlock()
 
 	for _, connections := range bc.rooms {
 		for _, connection := range connections {
-			connection.Emit(event, args...)
+			broadcastEmit(connection, opts, event, args...)
 		}
 	}
 }
@@ -537,9 +1016,53 @@ func (bc *redisBroadcast) getRoomsByConn(connection Conn) []string {
 }
 
 func (bc *redisBroadcast) dispatch() {
+	backoff := redisResubscribeBackoff
 	for {
-		switch m := bc.sub.Receive().(type) {
+		start := time.Now()
+		err := bc.receive()
+		if err == nil || bc.closed() {
+			return
+		}
+
+		logger.Error("redis subscription lost:", err)
+		bc.degrade(fmt.Errorf("redis subscription lost: %w", err))
+
+		_ = bc.sub.Close()
+
+		// subscription which is lost right away is restored with backoff as well
+		if time.Since(start) > redisMaxResubscribeBackoff {
+			backoff = redisResubscribeBackoff
+		}
+		if !bc.sleep(backoff) {
+			return
+		}
+		backoff = min(2*backoff, redisMaxResubscribeBackoff)
+
+		bc.resubscribe()
+		if bc.closed() {
+			return
+		}
+		bc.health.restore()
+	}
+}
+
+// receive handles messages of subscription until it ends, it gives error when connection is lost.
+func (bc *redisBroadcast) receive() error {
+	for {
+		var received interface{}
+		if bc.sharded() {
+			received = receiveSharded(bc.sub.Conn)
+		} else {
+			received = receive(bc.sub)
+		}
+
+		switch m := received.(type) {
 		case redis.Message:
+			if bc.nodeCompatible() {
+				bc.onNodeSubscription(m.Channel, m.Data)
+				break
+			}
+
 			if m.Channel == bc.reqChannel {
 				bc.onRequest(m.Data)
 				break
@@ -548,18 +1071,19 @@ func (bc *redisBroadcast) dispatch() {
 				break
 			}
 
-			err := bc.onMessage(m.Channel, m.Data)
-			if err != nil {
-				return
+			// invalid broadcast is dropped, subscription goes on
+			if err := bc.onMessage(m.Channel, m.Data); err != nil {
+				logger.Error("redis broadcast:", err)
+				bc.reportError(fmt.Errorf("redis broadcast of %s: %w", m.Channel, err))
 			}
 
 		case redis.Subscription:
 			if m.Count == 0 {
-				return
+				return nil
 			}
 
 		case error:
-			return
+			return m
 		}
 	}
 }
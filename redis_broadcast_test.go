@@ -0,0 +1,91 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBroadcastRoomsWithoutLock(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 64)
+	// node2 is subscribed, but it never responds
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBSUB":
+			return "*2\r\n" + respArray(cmd[2])[4:] + ":2\r\n"
+		case "PUBLISH":
+			published <- cmd[1:]
+		}
+		return ":1\r\n"
+	})
+
+	bc, err := newRedisBroadcast("/", getOptions(&RedisAdapterOptions{
+		Addr:           addr,
+		Prefix:         "app",
+		NodeID:         "node1",
+		RequestTimeout: 500 * time.Millisecond,
+	}))
+	must.NoError(err)
+	defer bc.Close()
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", bc)
+	nc.Join("lobby")
+
+	rooms := make(chan []string, 1)
+	go func() {
+		rooms <- bc.Rooms(nil)
+	}()
+
+	// own request is answered while rooms of other nodes are waited for
+	var req []string
+	select {
+	case req = <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rooms request isn't published")
+	}
+	bc.onRequest([]byte(req[1]))
+
+	joined := make(chan struct{})
+	go func() {
+		nc.Join("news")
+		close(joined)
+	}()
+
+	select {
+	case <-joined:
+	case <-rooms:
+		t.Fatal("join waits for rooms of other nodes")
+	}
+
+	msg := (<-published)[1]
+	var res allRoomResponse
+	must.NoError(json.Unmarshal([]byte(msg), &res))
+	should.Equal("node1", res.NodeID)
+	should.Equal([]string{"lobby"}, res.Rooms)
+	bc.onResponse([]byte(msg))
+
+	lenReq, err := json.Marshal(&roomLenRequest{RequestType: roomLenReqType, RequestID: "2", Room: "news"})
+	must.NoError(err)
+	go nc.Leave("news")
+	bc.onRequest(lenReq)
+
+	var lenRes roomLenResponse
+	must.NoError(json.Unmarshal([]byte((<-published)[1]), &lenRes))
+	should.Contains([]int{0, 1}, lenRes.Connections)
+
+	should.Contains(<-rooms, "lobby", "rooms of responded node are given once request times out")
+}
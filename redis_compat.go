@@ -0,0 +1,527 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/thisismz/go-socket.io/logger"
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+// request types of node.js redis adapter
+const (
+	nodeSocketsReq     = 0
+	nodeAllRoomsReq    = 1
+	nodeRemoteJoin     = 2
+	nodeRemoteLeave    = 3
+	nodeRemoteDiscReq  = 4
+	nodeRemoteFetch    = 5
+	nodeServerSideEmit = 6
+)
+
+// socket.io packet types of broadcasts
+const (
+	nodeEventPacket       = 2
+	nodeBinaryEventPacket = 5
+)
+
+// nodeRequest is request of node.js redis adapter, encoded as JSON.
+type nodeRequest struct {
+	UID       string           `json:"uid"`
+	RequestID string           `json:"requestId,omitempty"`
+	Type      int              `json:"type"`
+	Opts      *nodeRequestOpts `json:"opts,omitempty"`
+	Rooms     []string         `json:"rooms,omitempty"`
+	Close     bool             `json:"close,omitempty"`
+	// Data is event and args of SERVER_SIDE_EMIT request.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type nodeRequestOpts struct {
+	Rooms  []string `json:"rooms"`
+	Except []string `json:"except"`
+}
+
+// nodeResponse is response of node.js redis adapter, it doesn't tell responding node.
+type nodeResponse struct {
+	RequestID string       `json:"requestId"`
+	Rooms     []string     `json:"rooms,omitempty"`
+	Sockets   []nodeSocket `json:"sockets,omitempty"`
+}
+
+// nodeSocket decodes both socket ids of SOCKETS responses and socket details of REMOTE_FETCH
+// responses.
+type nodeSocket struct {
+	ID        string        `json:"id"`
+	Rooms     []string      `json:"rooms"`
+	Handshake nodeHandshake `json:"handshake"`
+}
+
+func (s *nodeSocket) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		s.ID = id
+		return nil
+	}
+
+	type details nodeSocket
+	return json.Unmarshal(data, (*details)(s))
+}
+
+// nodeHandshake is handshake of node.js socket, values of its query are strings or arrays.
+type nodeHandshake struct {
+	Address string                     `json:"address"`
+	URL     string                     `json:"url"`
+	Query   map[string]json.RawMessage `json:"query"`
+	Headers map[string]json.RawMessage `json:"headers"`
+}
+
+func (s *nodeSocket) remoteSocket() RemoteSocket {
+	rooms := s.Rooms
+	if rooms == nil {
+		rooms = []string{}
+	}
+
+	return RemoteSocket{
+		ID:    s.ID,
+		Rooms: rooms,
+		Handshake: SocketHandshake{
+			Address: s.Handshake.Address,
+			URL:     s.Handshake.URL,
+			Query:   firstValues(s.Handshake.Query),
+			Headers: firstValues(s.Handshake.Headers),
+		},
+	}
+}
+
+// firstValues gives the first value of each parameter whose value is string or array of them.
+func firstValues(params map[string]json.RawMessage) map[string]string {
+	values := make(map[string]string, len(params))
+	for key, raw := range params {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			values[key] = value
+			continue
+		}
+
+		var list []string
+		if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+			values[key] = list[0]
+		}
+	}
+
+	return values
+}
+
+// nodeRoomRequest collects responses of nodes to request in node.js compatible mode.
+type nodeRoomRequest struct {
+	pendingRequest
+
+	sockets int
+	fetched []RemoteSocket
+	rooms   map[string]bool
+}
+
+func (bc *redisBroadcast) nodeCompatible() bool {
+	return bc.opts != nil && bc.opts.NodeCompatible
+}
+
+// nodeNamespace gives name of namespace used by node.js, where root namespace is "/".
+func nodeNamespace(nsp string) string {
+	if nsp == rootNamespace {
+		return aliasRootNamespace
+	}
+
+	return nsp
+}
+
+// nodeChannels gives channels of node.js redis adapter for namespace: broadcasts to all the
+// rooms, requests, responses and responses to node uid.
+func nodeChannels(prefix, nsp, uid string) (key, req, res, ownRes string) {
+	nsp = nodeNamespace(nsp)
+
+	return fmt.Sprintf("%s#%s#", prefix, nsp),
+		fmt.Sprintf("%s-request#%s#", prefix, nsp),
+		fmt.Sprintf("%s-response#%s#", prefix, nsp),
+		fmt.Sprintf("%s-response#%s#%s#", prefix, nsp, uid)
+}
+
+func (bc *redisBroadcast) ownResChannel() string {
+	_, _, _, ownRes := nodeChannels(bc.opts.Prefix, bc.nsp, bc.uid)
+	return ownRes
+}
+
+// publishNodeBroadcast publishes broadcast like node.js redis adapter, to channel of the room
+// when it's single room. Room set is sent as rooms and except rooms, so only unions of rooms
+// followed by exceptions are supported.
+func (bc *redisBroadcast) publishNodeBroadcast(room string, set *RoomSet, event string, args ...interface{}) error {
+	rooms, except := []interface{}{}, []interface{}{}
+
+	channel := bc.key
+	switch {
+	case set != nil:
+		union, excluded, ok := set.nodeRooms()
+		if !ok {
+			return errors.New("room set isn't supported by node.js redis adapter")
+		}
+		for _, r := range union {
+			rooms = append(rooms, r)
+		}
+		for _, r := range excluded {
+			except = append(except, r)
+		}
+	case room != "":
+		rooms = append(rooms, room)
+		channel += room + "#"
+	}
+
+	data := append([]interface{}{event}, args...)
+
+	msg, err := parser.MarshalMsgpack([]interface{}{
+		bc.uid,
+		map[string]interface{}{"type": nodeEventPacket, "data": data, "nsp": nodeNamespace(bc.nsp)},
+		map[string]interface{}{"rooms": rooms, "except": except, "flags": map[string]interface{}{}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = bc.do("PUBLISH", channel, msg)
+
+	return err
+}
+
+// nodeRooms gives rooms and except rooms of room set which is unions followed by exceptions.
+func (rs *RoomSet) nodeRooms() (rooms, except []string, ok bool) {
+	for _, op := range rs.ops {
+		switch {
+		case op.Op == roomSetUnion && len(except) == 0:
+			rooms = append(rooms, op.Rooms...)
+		case op.Op == roomSetExcept:
+			except = append(except, op.Rooms...)
+		default:
+			return nil, nil, false
+		}
+	}
+
+	return rooms, except, len(rooms) > 0
+}
+
+// onNodeMessage delivers broadcast of node.js redis adapter to connections of this node.
+func (bc *redisBroadcast) onNodeMessage(msg []byte) error {
+	decoded, err := parser.UnmarshalMsgpack(msg)
+	if err != nil {
+		return err
+	}
+
+	parts, ok := decoded.([]interface{})
+	if !ok || len(parts) < 2 {
+		return errors.New("invalid broadcast message")
+	}
+
+	if uid, _ := parts[0].(string); uid == bc.uid {
+		return nil
+	}
+
+	packet, ok := parts[1].(map[string]interface{})
+	if !ok {
+		return errors.New("invalid broadcast packet")
+	}
+
+	if nsp, _ := packet["nsp"].(string); nsp != nodeNamespace(bc.nsp) {
+		return nil
+	}
+
+	if typ, _ := packet["type"].(int64); typ != nodeEventPacket && typ != nodeBinaryEventPacket {
+		return nil
+	}
+
+	data, _ := packet["data"].([]interface{})
+	if len(data) == 0 {
+		return errors.New("invalid broadcast data")
+	}
+
+	event, ok := data[0].(string)
+	if !ok {
+		return errors.New("invalid event")
+	}
+
+	var rooms, except []string
+	if len(parts) > 2 {
+		opts, _ := parts[2].(map[string]interface{})
+		rooms, except = stringList(opts["rooms"]), stringList(opts["except"])
+	}
+
+	bc.lock.RLock()
+	for _, connection := range audience(bc.rooms, rooms, except) {
+		broadcastEmit(connection, nil, event, data[1:]...)
+	}
+	bc.lock.RUnlock()
+
+	record := BroadcastRecord{Event: event, Args: data[1:]}
+	record.Origin, _ = parts[0].(string)
+	switch {
+	case len(except) > 0 || len(rooms) > 1:
+		record.RoomSet = Union(rooms...).Except(except...)
+	case len(rooms) == 1:
+		record.Room = rooms[0]
+	}
+	bc.remoteBroadcast(record)
+
+	return nil
+}
+
+func stringList(v interface{}) []string {
+	items, _ := v.([]interface{})
+
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+
+	return list
+}
+
+// onNodeRequest handles request of node.js redis adapter.
+func (bc *redisBroadcast) onNodeRequest(msg []byte) {
+	var req nodeRequest
+	if err := json.Unmarshal(msg, &req); err != nil || req.UID == bc.uid {
+		return
+	}
+
+	var rooms, except []string
+	if req.Opts != nil {
+		rooms, except = req.Opts.Rooms, req.Opts.Except
+	}
+
+	switch req.Type {
+	case nodeAllRoomsReq:
+		bc.publishNodeResponse(&nodeResponse{RequestID: req.RequestID, Rooms: bc.allRooms()})
+
+	case nodeSocketsReq, nodeRemoteFetch:
+		if req.Type == nodeSocketsReq {
+			rooms = req.Rooms
+		}
+
+		bc.lock.RLock()
+		selected := sortedConns(audience(bc.rooms, rooms, except))
+		joined := make([][]string, len(selected))
+		for i, connection := range selected {
+			joined[i] = roomsOf(bc.rooms, connection.ID())
+		}
+		bc.lock.RUnlock()
+
+		res := map[string]interface{}{"requestId": req.RequestID}
+		sockets := make([]interface{}, len(selected))
+		for i, connection := range selected {
+			if req.Type == nodeSocketsReq {
+				sockets[i] = connection.ID()
+				continue
+			}
+
+			socket := describeSocket(connection, "", joined[i])
+			sockets[i] = map[string]interface{}{
+				"id":        socket.ID,
+				"handshake": socket.Handshake,
+				"rooms":     socket.Rooms,
+				"data":      map[string]interface{}{},
+			}
+		}
+		res["sockets"] = sockets
+
+		bc.publishNodeResponse(res)
+
+	case nodeServerSideEmit:
+		bc.onNodeServerSideEmit(req.Data)
+
+	case nodeRemoteJoin, nodeRemoteLeave, nodeRemoteDiscReq:
+		bc.lock.RLock()
+		selected := audience(bc.rooms, rooms, except)
+		bc.lock.RUnlock()
+
+		for _, connection := range selected {
+			switch req.Type {
+			case nodeRemoteJoin:
+				for _, room := range req.Rooms {
+					connection.Join(room)
+				}
+			case nodeRemoteLeave:
+				for _, room := range req.Rooms {
+					connection.Leave(room)
+				}
+			default:
+				_ = connection.Close()
+			}
+		}
+	}
+}
+
+// publishNodeResponse publishes response to channel of responses, which is subscribed by every
+// version of node.js redis adapter.
+func (bc *redisBroadcast) publishNodeResponse(res interface{}) {
+	bc.publish(bc.resChannel, res)
+}
+
+// onNodeResponse applies response of node.js redis adapter.
+func (bc *redisBroadcast) onNodeResponse(msg []byte) {
+	var res nodeResponse
+	if err := json.Unmarshal(msg, &res); err != nil {
+		return
+	}
+
+	req, ok := bc.requests.get(res.RequestID)
+	if !ok {
+		return
+	}
+
+	roomReq, ok := req.(*nodeRoomRequest)
+	if !ok {
+		return
+	}
+
+	// responses don't tell node, every response counts
+	roomReq.respond("", func() {
+		roomReq.sockets += len(res.Sockets)
+		for i := range res.Sockets {
+			roomReq.fetched = append(roomReq.fetched, res.Sockets[i].remoteSocket())
+		}
+		for _, room := range res.Rooms {
+			roomReq.rooms[room] = true
+		}
+	})
+}
+
+// nodeRequest publishes request to other nodes and waits for their responses, error tells
+// responses are partial.
+func (bc *redisBroadcast) nodeRequest(ctx context.Context, req *nodeRequest) (*nodeRoomRequest, error) {
+	roomReq := &nodeRoomRequest{rooms: make(map[string]bool)}
+
+	// node doesn't respond its own requests
+	numSub, err := bc.getNumSub(bc.reqChannel)
+	if err != nil {
+		return roomReq, err
+	}
+	if numSub <= 1 {
+		return roomReq, nil
+	}
+
+	req.UID = bc.uid
+	req.RequestID = newV4UUID()
+
+	release, err := bc.register(ctx, req.RequestID, roomReq, numSub-1, bc.opts.getRequestTimeout())
+	if err != nil {
+		return roomReq, err
+	}
+	defer release()
+
+	bc.publish(bc.reqChannel, req)
+
+	return roomReq, roomReq.wait()
+}
+
+// nodeLen counts connections of room on every node with REMOTE_FETCH request.
+func (bc *redisBroadcast) nodeLen(ctx context.Context, room string) (int, error) {
+	req, err := bc.nodeRequest(ctx, &nodeRequest{Type: nodeRemoteFetch, Opts: &nodeRequestOpts{Rooms: []string{room}, Except: []string{}}})
+
+	req.mutex.Lock()
+	remote := req.sockets
+	req.mutex.Unlock()
+
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return len(bc.rooms[room]) + remote, err
+}
+
+// nodeFetchSockets describes connections of room on every node with REMOTE_FETCH request.
+func (bc *redisBroadcast) nodeFetchSockets(ctx context.Context, room string) ([]RemoteSocket, error) {
+	rooms := []string{}
+	if room != "" {
+		rooms = append(rooms, room)
+	}
+
+	req, err := bc.nodeRequest(ctx, &nodeRequest{Type: nodeRemoteFetch, Opts: &nodeRequestOpts{Rooms: rooms, Except: []string{}}})
+
+	req.mutex.Lock()
+	sockets := append(bc.localSockets(room), req.fetched...)
+	req.mutex.Unlock()
+
+	sort.Slice(sockets, func(i, j int) bool {
+		return sockets[i].ID < sockets[j].ID
+	})
+
+	return sockets, err
+}
+
+// nodeAllRooms gives rooms of every node with ALL_ROOMS request.
+func (bc *redisBroadcast) nodeAllRooms(ctx context.Context) ([]string, error) {
+	req, err := bc.nodeRequest(ctx, &nodeRequest{Type: nodeAllRoomsReq})
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	for _, room := range bc.allRooms() {
+		req.rooms[room] = true
+	}
+
+	rooms := make([]string, 0, len(req.rooms))
+	for room := range req.rooms {
+		rooms = append(rooms, room)
+	}
+
+	return rooms, err
+}
+
+// nodeExplainRemote counts connections of other nodes in audience, node.js responses don't tell
+// node, so they're counted together under "*".
+func (bc *redisBroadcast) nodeExplainRemote(rooms, except []string) map[string]int {
+	req, err := bc.nodeRequest(context.Background(), &nodeRequest{Type: nodeRemoteFetch, Opts: &nodeRequestOpts{Rooms: rooms, Except: except}})
+	if err != nil {
+		logger.Info("node.js adapter request is partial", "namespace", bc.nsp, "error", err.Error())
+	}
+
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	if req.sockets == 0 {
+		return map[string]int{}
+	}
+
+	return map[string]int{"*": req.sockets}
+}
+
+// publishNodeClear makes connections of room on other nodes leave it with REMOTE_LEAVE request.
+func (bc *redisBroadcast) publishNodeClear(room string) {
+	bc.publish(bc.reqChannel, &nodeRequest{
+		UID:   bc.uid,
+		Type:  nodeRemoteLeave,
+		Opts:  &nodeRequestOpts{Rooms: []string{room}, Except: []string{}},
+		Rooms: []string{room},
+	})
+}
+
+// isNodeBroadcastChannel reports whether channel carries broadcasts of namespace of node.js
+// redis adapter, like "socket.io#/chat#" or "socket.io#/chat#room#".
+func (bc *redisBroadcast) isNodeBroadcastChannel(channel string) bool {
+	return strings.HasPrefix(channel, bc.key) && strings.HasSuffix(channel, "#")
+}
+
+// onNodeSubscription handles message of subscription in node.js compatible mode.
+func (bc *redisBroadcast) onNodeSubscription(channel string, msg []byte) {
+	switch {
+	case channel == bc.reqChannel:
+		bc.onNodeRequest(msg)
+	case channel == bc.resChannel || channel == bc.ownResChannel():
+		bc.onNodeResponse(msg)
+	case bc.isNodeBroadcastChannel(channel):
+		if err := bc.onNodeMessage(msg); err != nil {
+			logger.Error("node.js adapter broadcast:", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package socketio
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+func TestNodeChannels(t *testing.T) {
+	should := assert.New(t)
+
+	key, req, res, ownRes := nodeChannels("socket.io", "", "abc")
+	should.Equal("socket.io#/#", key)
+	should.Equal("socket.io-request#/#", req)
+	should.Equal("socket.io-response#/#", res)
+	should.Equal("socket.io-response#/#abc#", ownRes)
+
+	key, _, _, _ = nodeChannels("socket.io", "/chat", "abc")
+	should.Equal("socket.io#/chat#", key)
+}
+
+func TestRedisBroadcastNodeCompatible(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu        sync.Mutex
+		published [][]string
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch cmd[0] {
+		case "PUBLISH":
+			published = append(published, cmd[1:])
+			return ":1\r\n"
+		case "PUBSUB":
+			return "*2\r\n$" + "19\r\nsocket.io-request#/#\r\n:1\r\n"
+		}
+
+		return "-ERR unknown command\r\n"
+	})
+
+	opts := getOptions(&RedisAdapterOptions{Addr: addr, NodeCompatible: true})
+	should.True(opts.NodeCompatible)
+
+	pub, err := opts.dial()
+	must.NoError(err)
+	defer pub.Close()
+
+	bc := &redisBroadcast{
+		pub:      &redis.PubSubConn{Conn: pub},
+		nsp:      rootNamespace,
+		uid:      "go-node",
+		rooms:    make(map[string]map[string]Conn),
+		tree:     make(roomTree),
+		requests: newRequestRegistry(),
+		opts:     opts,
+	}
+	bc.key, bc.reqChannel, bc.resChannel, _ = nodeChannels(opts.Prefix, bc.nsp, bc.uid)
+
+	lobby := newLockedRecorder("1", "/", bc)
+	muted := newLockedRecorder("2", "/", bc)
+	bc.rooms["lobby"] = map[string]Conn{"1": lobby, "2": muted}
+	bc.rooms["muted"] = map[string]Conn{"2": muted}
+
+	// broadcasts of node.js server
+	msg := func(uid string, rooms, except []interface{}, event string) []byte {
+		data, err := parser.MarshalMsgpack([]interface{}{
+			uid,
+			map[string]interface{}{"type": 2, "data": []interface{}{event, 1}, "nsp": "/"},
+			map[string]interface{}{"rooms": rooms, "except": except, "flags": map[string]interface{}{}},
+		})
+		must.NoError(err)
+
+		return data
+	}
+	bc.onNodeSubscription("socket.io#/#lobby#", msg("node-js", []interface{}{"lobby"}, []interface{}{"muted"}, "first"))
+	bc.onNodeSubscription("socket.io#/#", msg("node-js", nil, nil, "second"))
+	bc.onNodeSubscription("socket.io#/#", msg("go-node", nil, nil, "own"))
+	should.Equal([]string{"first", "second"}, lobby.received())
+	should.Equal([]string{"second"}, muted.received())
+
+	// broadcasts of this node
+	must.NoError(bc.publishBroadcast("lobby", nil, time.Time{}, "hello", "world"))
+	must.NoError(bc.publishBroadcast("", Union("lobby").Except("muted"), time.Time{}, "hello"))
+	should.Error(bc.publishBroadcast("", Union("lobby").Intersect("muted"), time.Time{}, "hello"))
+
+	// requests of node.js server
+	bc.onNodeSubscription("socket.io-request#/#", []byte(`{"uid":"node-js","requestId":"r1","type":1}`))
+	bc.onNodeSubscription("socket.io-request#/#", []byte(`{"uid":"node-js","requestId":"r2","type":5,"opts":{"rooms":["muted"],"except":[]}}`))
+	bc.onNodeSubscription("socket.io-request#/#", []byte(`{"uid":"node-js","type":3,"opts":{"rooms":["muted"],"except":[]},"rooms":["lobby"]}`))
+	should.Len(bc.rooms["lobby"], 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	must.Len(published, 4)
+	should.Equal("socket.io#/#lobby#", published[0][0])
+	decoded, err := parser.UnmarshalMsgpack([]byte(published[0][1]))
+	must.NoError(err)
+	should.Equal([]interface{}{
+		"go-node",
+		map[string]interface{}{"type": int64(2), "data": []interface{}{"hello", "world"}, "nsp": "/"},
+		map[string]interface{}{"rooms": []interface{}{"lobby"}, "except": []interface{}{}, "flags": map[string]interface{}{}},
+	}, decoded)
+
+	should.Equal("socket.io#/#", published[1][0])
+
+	should.Equal("socket.io-response#/#", published[2][0])
+	var rooms nodeResponse
+	must.NoError(json.Unmarshal([]byte(published[2][1]), &rooms))
+	should.Equal("r1", rooms.RequestID)
+	should.ElementsMatch([]string{"lobby", "muted"}, rooms.Rooms)
+
+	var sockets nodeResponse
+	must.NoError(json.Unmarshal([]byte(published[3][1]), &sockets))
+	should.Equal("r2", sockets.RequestID)
+	must.Len(sockets.Sockets, 1)
+	should.Equal("2", sockets.Sockets[0].ID)
+}
+
+func TestNodeRoomSet(t *testing.T) {
+	should := assert.New(t)
+
+	rooms, except, ok := Union("a", "b").Except("c").nodeRooms()
+	should.True(ok)
+	should.Equal([]string{"a", "b"}, rooms)
+	should.Equal([]string{"c"}, except)
+
+	_, _, ok = Union("a").Except("c").Union("b").nodeRooms()
+	should.False(ok)
+	_, _, ok = Union("a").Intersect("b").nodeRooms()
+	should.False(ok)
+}
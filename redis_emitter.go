@@ -0,0 +1,132 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrEmitterExceptAll is returned by RedisEmitter.Emit when Except is given without rooms of To.
+var ErrEmitterExceptAll = errors.New("emitter: except needs rooms of To")
+
+// RedisEmitter publishes broadcasts to servers of redis adapter without running a server, e.g.
+// from workers or cron jobs, like @socket.io/redis-emitter:
+//
+//	emitter := socketio.NewRedisEmitter(&socketio.RedisAdapterOptions{Addr: "127.0.0.1:6379"})
+//	defer emitter.Close()
+//
+//	err := emitter.Of("/chat").To("lobby").Emit("notice", "maintenance at noon")
+//
+// Of, To and Except give new emitters, so emitter can be shared. Options must match the ones of
+// servers, e.g. Prefix, Streams, NodeCompatible and Sharded.
+type RedisEmitter struct {
+	pool *redis.Pool
+	opts *RedisAdapterOptions
+	uid  string
+
+	nsp    string
+	rooms  []string
+	except []string
+}
+
+// NewRedisEmitter gives emitter to root namespace of servers of redis of options.
+func NewRedisEmitter(opts *RedisAdapterOptions) *RedisEmitter {
+	opts = getOptions(opts)
+
+	uid := opts.NodeID
+	if uid == "" {
+		uid = newV4UUID()
+	}
+
+	return &RedisEmitter{
+		pool: opts.newPool(),
+		opts: opts,
+		uid:  uid,
+	}
+}
+
+// Of gives emitter to namespace, root namespace is given as "/".
+func (e *RedisEmitter) Of(namespace string) *RedisEmitter {
+	if namespace == aliasRootNamespace {
+		namespace = rootNamespace
+	}
+
+	c := e.clone()
+	c.nsp = namespace
+	return c
+}
+
+// To gives emitter to connections of any of the rooms, in addition to rooms of emitter.
+func (e *RedisEmitter) To(rooms ...string) *RedisEmitter {
+	c := e.clone()
+	c.rooms = append(c.rooms, rooms...)
+	return c
+}
+
+// Except gives emitter which skips connections of any of the rooms.
+func (e *RedisEmitter) Except(rooms ...string) *RedisEmitter {
+	c := e.clone()
+	c.except = append(c.except, rooms...)
+	return c
+}
+
+// Emit publishes event to connections of rooms of emitter, or all the connections of namespace
+// when no room is given.
+func (e *RedisEmitter) Emit(event string, args ...interface{}) error {
+	var (
+		room string
+		set  *RoomSet
+	)
+	switch {
+	case len(e.rooms) == 1 && len(e.except) == 0:
+		room = e.rooms[0]
+	case len(e.except) > 0:
+		if len(e.rooms) == 0 {
+			return ErrEmitterExceptAll
+		}
+		set = Union(e.rooms...).Except(e.except...)
+	case len(e.rooms) > 1:
+		set = Union(e.rooms...)
+	}
+
+	// pub is replaced when it's redialed
+	bc := e.publisher()
+	defer func() { _ = bc.pub.Close() }()
+
+	return bc.publishBroadcast(room, set, time.Time{}, event, args...)
+}
+
+// Close closes connections of emitter, it's shared by emitters given by Of, To and Except.
+func (e *RedisEmitter) Close() error {
+	return e.pool.Close()
+}
+
+func (e *RedisEmitter) clone() *RedisEmitter {
+	c := *e
+	c.rooms = append([]string(nil), e.rooms...)
+	c.except = append([]string(nil), e.except...)
+	return &c
+}
+
+// publisher gives broadcaster of namespace which only publishes, its connection must be closed.
+func (e *RedisEmitter) publisher() *redisBroadcast {
+	bc := &redisBroadcast{
+		pub:  &redis.PubSubConn{Conn: e.pool.Get()},
+		nsp:  e.nsp,
+		uid:  e.uid,
+		opts: e.opts,
+	}
+
+	switch {
+	case e.opts.NodeCompatible:
+		bc.key, _, _, _ = nodeChannels(e.opts.Prefix, e.nsp, e.uid)
+	case e.opts.Sharded:
+		bc.key, _, _ = shardedChannels(e.opts.Prefix, e.nsp)
+	default:
+		bc.key = fmt.Sprintf("%s#%s#%s", e.opts.Prefix, e.nsp, e.uid)
+	}
+
+	return bc
+}
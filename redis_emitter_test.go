@@ -0,0 +1,95 @@
+package socketio
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEmitter(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu        sync.Mutex
+		published [][]string
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cmd[0] == "PUBLISH" {
+			published = append(published, cmd[1:])
+		}
+		return ":1\r\n"
+	})
+
+	emitter := NewRedisEmitter(&RedisAdapterOptions{Addr: addr, NodeID: "worker"})
+	defer emitter.Close()
+
+	chat := emitter.Of("/chat")
+	must.NoError(chat.To("lobby").Emit("notice", "first"))
+	must.NoError(chat.To("lobby").Except("muted").Emit("notice", "second"))
+	must.NoError(chat.Emit("notice", "third"))
+	should.ErrorIs(chat.Except("muted").Emit("notice"), ErrEmitterExceptAll)
+	must.NoError(emitter.Of("/").Emit("notice", "root"))
+
+	// servers of namespace deliver broadcasts of emitter
+	bc := &redisBroadcast{
+		nsp:   "/chat",
+		uid:   "server",
+		rooms: make(map[string]map[string]Conn),
+		tree:  make(roomTree),
+		opts:  getOptions(nil),
+	}
+	lobby := newLockedRecorder("1", "/chat", bc)
+	muted := newLockedRecorder("2", "/chat", bc)
+	bc.rooms["lobby"] = map[string]Conn{"1": lobby, "2": muted}
+	bc.rooms["muted"] = map[string]Conn{"2": muted}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	must.Len(published, 4)
+	for _, msg := range published[:2] {
+		must.NoError(bc.onMessage(msg[0], []byte(msg[1])))
+	}
+	should.Equal([]string{"notice", "notice"}, lobby.received())
+	should.Equal([]string{"notice"}, muted.received())
+
+	should.Equal("socket.io#/chat#worker", published[2][0])
+	should.Equal("socket.io##worker", published[3][0])
+}
+
+func TestRedisEmitterNodeCompatible(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu       sync.Mutex
+		channels []string
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cmd[0] == "PUBLISH" {
+			channels = append(channels, cmd[1])
+		}
+		return ":1\r\n"
+	})
+
+	emitter := NewRedisEmitter(&RedisAdapterOptions{Addr: addr, NodeCompatible: true})
+	defer emitter.Close()
+
+	must.NoError(emitter.To("lobby").Emit("notice"))
+	must.NoError(emitter.To("lobby").Except("muted").Emit("notice"))
+	must.NoError(emitter.Emit("notice"))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	should.Equal([]string{"socket.io#/#lobby#", "socket.io#/#", "socket.io#/#"}, channels)
+}
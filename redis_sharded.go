@@ -0,0 +1,148 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// shardedChannels gives channels of namespace in sharded mode: broadcasts, requests and
+// responses. They share hash tag, so they're in one slot and one connection subscribes to them.
+// Broadcasts carry their origin node, see envelopeMeta.
+func shardedChannels(prefix, nsp string) (key, reqChannel, resChannel string) {
+	tag := "{" + prefix + "#" + nsp + "}"
+
+	return tag + "#", tag + "-request", tag + "-response"
+}
+
+func (bc *redisBroadcast) sharded() bool {
+	return bc.opts != nil && bc.opts.Sharded && !bc.opts.NodeCompatible
+}
+
+func (bc *redisBroadcast) publishCmd() string {
+	if bc.sharded() {
+		return "SPUBLISH"
+	}
+
+	return "PUBLISH"
+}
+
+func (bc *redisBroadcast) numSubCmd() string {
+	if bc.sharded() {
+		return "SHARDNUMSUB"
+	}
+
+	return "NUMSUB"
+}
+
+// dial connects to redis, or to node of cluster which serves slot of channels once redis
+// redirected node there.
+func (bc *redisBroadcast) dial() (redis.Conn, error) {
+	bc.pubLock.RLock()
+	addr := bc.shardAddr
+	bc.pubLock.RUnlock()
+
+	if addr == "" {
+		return bc.opts.dial()
+	}
+
+	return bc.opts.dialAddr(addr)
+}
+
+func (bc *redisBroadcast) redirect(addr string) {
+	bc.pubLock.Lock()
+	bc.shardAddr = addr
+	bc.pubLock.Unlock()
+}
+
+// movedAddr gives address of MOVED redirection of redis cluster, e.g. "MOVED 3999 127.0.0.1:6381".
+func movedAddr(err error) (string, bool) {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return "", false
+	}
+
+	fields := strings.Fields(string(redisErr))
+	if len(fields) != 3 || fields[0] != "MOVED" {
+		return "", false
+	}
+
+	return fields[2], true
+}
+
+// shardedSubscribe subscribes to channels of namespace, following redirection once.
+func (bc *redisBroadcast) shardedSubscribe() (*redis.PubSubConn, error) {
+	for redirected := false; ; redirected = true {
+		conn, err := bc.dial()
+		if err != nil {
+			return nil, err
+		}
+
+		// confirmations of other channels are received by dispatch
+		err = conn.Send("SSUBSCRIBE", bc.key, bc.reqChannel, bc.resChannel)
+		if err == nil {
+			err = conn.Flush()
+		}
+		if err == nil {
+			_, err = conn.Receive()
+		}
+		if err == nil {
+			return &redis.PubSubConn{Conn: conn}, nil
+		}
+
+		_ = conn.Close()
+
+		addr, moved := movedAddr(err)
+		if !moved || redirected {
+			return nil, err
+		}
+		bc.redirect(addr)
+	}
+}
+
+// receiveSharded receives notification of sharded subscription, which redis.PubSubConn doesn't
+// know, as redis.Message, redis.Subscription or error.
+func receiveSharded(conn redis.Conn) interface{} {
+	reply, err := redis.Values(receiveReply(conn))
+	if err != nil {
+		return err
+	}
+
+	var kind string
+	if reply, err = redis.Scan(reply, &kind); err != nil {
+		return err
+	}
+
+	switch kind {
+	case "smessage":
+		var m redis.Message
+		if _, err = redis.Scan(reply, &m.Channel, &m.Data); err != nil {
+			return err
+		}
+		return m
+
+	case "ssubscribe", "sunsubscribe":
+		s := redis.Subscription{Kind: strings.TrimPrefix(kind, "s")}
+		if _, err = redis.Scan(reply, &s.Channel, &s.Count); err != nil {
+			return err
+		}
+		return s
+	}
+
+	return fmt.Errorf("unknown sharded pubsub notification %q", kind)
+}
+
+// onShardedMessage delivers broadcast of channel of namespace, which is shared by nodes.
+func (bc *redisBroadcast) onShardedMessage(msg []byte) error {
+	var envelope struct {
+		Meta *envelopeMeta `json:"meta"`
+	}
+	if err := json.Unmarshal(msg, &envelope); err != nil || envelope.Meta == nil {
+		return errors.New("invalid broadcast message")
+	}
+
+	return bc.onEnvelope(envelope.Meta.Origin, msg)
+}
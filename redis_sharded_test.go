@@ -0,0 +1,121 @@
+package socketio
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedChannels(t *testing.T) {
+	should := assert.New(t)
+
+	key, req, res := shardedChannels("socket.io", "/chat")
+	should.Equal("{socket.io#/chat}#", key)
+	should.Equal("{socket.io#/chat}-request", req)
+	should.Equal("{socket.io#/chat}-response", res)
+}
+
+func TestRedisBroadcastSharded(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu       sync.Mutex
+		commands [][]string
+	)
+	shard := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		commands = append(commands, cmd)
+
+		switch cmd[0] {
+		case "SSUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n$10\r\nssubscribe\r\n" + respArray(channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBSUB":
+			return "*2\r\n" + respArray(cmd[2])[4:] + ":3\r\n"
+		}
+		return ":1\r\n"
+	})
+	// node of cluster which doesn't serve slot of channels
+	node := fakeRedis(t, func(cmd []string) string {
+		return "-MOVED 5061 " + shard + "\r\n"
+	})
+
+	bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{Addr: node, Sharded: true, NodeID: "node1"}))
+	must.NoError(err)
+	should.Equal(shard, bc.shardAddr)
+
+	must.NoError(bc.publishBroadcast("lobby", nil, time.Time{}, "hello"))
+
+	numSub, err := bc.getNumSub(bc.reqChannel)
+	must.NoError(err)
+	should.Equal(3, numSub)
+
+	mu.Lock()
+	must.Len(commands, 3)
+	should.Equal([]string{"SSUBSCRIBE", "{socket.io#/chat}#", "{socket.io#/chat}-request", "{socket.io#/chat}-response"}, commands[0])
+	should.Equal([]string{"SPUBLISH", "{socket.io#/chat}#"}, commands[1][:2])
+	should.Equal([]string{"PUBSUB", "SHARDNUMSUB", "{socket.io#/chat}-request"}, commands[2])
+	published := commands[1][2]
+	mu.Unlock()
+
+	// broadcasts of other nodes are delivered, own broadcast is skipped
+	lobby := newLockedRecorder("1", "/chat", bc)
+	bc.lock.Lock()
+	bc.rooms["lobby"] = map[string]Conn{"1": lobby}
+	bc.lock.Unlock()
+
+	must.NoError(bc.onMessage(bc.key, []byte(published)))
+
+	emitter := NewRedisEmitter(&RedisAdapterOptions{Addr: shard, Sharded: true})
+	defer emitter.Close()
+	must.NoError(emitter.Of("/chat").To("lobby").Emit("notice"))
+
+	mu.Lock()
+	published = commands[len(commands)-1][2]
+	mu.Unlock()
+
+	must.NoError(bc.onMessage(bc.key, []byte(published)))
+	should.Equal([]string{"notice"}, lobby.received())
+	should.Error(bc.onMessage(bc.key, []byte(`{"opts":["lobby","notice"]}`)))
+}
+
+func TestReceiveSharded(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[1] {
+		case "message":
+			return respArray("smessage", "channel", "data")
+		case "subscription":
+			return "*3\r\n$12\r\nsunsubscribe\r\n$7\r\nchannel\r\n:0\r\n"
+		}
+		return respArray("pmessage", "channel")
+	})
+
+	conn, err := redis.Dial("tcp", addr)
+	must.NoError(err)
+	defer conn.Close()
+
+	receive := func(kind string) interface{} {
+		must.NoError(conn.Send("ECHO", kind))
+		must.NoError(conn.Flush())
+
+		return receiveSharded(conn)
+	}
+
+	should.Equal(redis.Message{Channel: "channel", Data: []byte("data")}, receive("message"))
+	should.Equal(redis.Subscription{Kind: "unsubscribe", Channel: "channel", Count: 0}, receive("subscription"))
+	should.Error(receive("other").(error))
+}
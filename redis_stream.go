@@ -0,0 +1,168 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const (
+	defaultStreamMaxLen = 10000
+
+	redisStreamBlock = 5 * time.Second
+	redisStreamCount = 100
+)
+
+func (bc *redisBroadcast) streamKey() string {
+	return fmt.Sprintf("%s-stream#%s", bc.opts.Prefix, bc.nsp)
+}
+
+func (bc *redisBroadcast) streamMaxLen() int64 {
+	if bc.opts.StreamMaxLen <= 0 {
+		return defaultStreamMaxLen
+	}
+
+	return bc.opts.StreamMaxLen
+}
+
+// appendStream adds broadcast to stream of namespace, trimming stream approximately.
+func (bc *redisBroadcast) appendStream(msg []byte) error {
+	_, err := bc.do("XADD", bc.streamKey(), "MAXLEN", "~", bc.streamMaxLen(), "*", "node", bc.uid, "msg", msg)
+
+	return err
+}
+
+// readStream delivers broadcasts of stream to connections of node, it reconnects when connection
+// is lost. Consumer group of node keeps its position, so broadcasts added meanwhile are delivered
+// once node reconnects, unless stream was trimmed.
+func (bc *redisBroadcast) readStream() {
+	backoff := redisResubscribeBackoff
+	for {
+		start := time.Now()
+		err := bc.consumeStream()
+		if bc.closed() {
+			return
+		}
+
+		logger.Error("redis stream:", err)
+		// it isn't degraded, broadcasts are caught up once stream is read again
+		bc.reportError(fmt.Errorf("redis stream: %w", err))
+
+		if time.Since(start) > redisMaxResubscribeBackoff {
+			backoff = redisResubscribeBackoff
+		}
+
+		if !bc.sleep(backoff) {
+			return
+		}
+		backoff = min(2*backoff, redisMaxResubscribeBackoff)
+	}
+}
+
+// consumeStream reads stream with consumer group of node until connection fails.
+func (bc *redisBroadcast) consumeStream() error {
+	conn, err := bc.opts.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := bc.streamKey()
+
+	_, err = conn.Do("XGROUP", "CREATE", key, bc.uid, "$", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	// broadcasts delivered before connection was lost, but not acknowledged, are read first.
+	id := "0"
+	for !bc.closed() {
+		reply, err := bc.opts.doBlocking(conn, redisStreamBlock, "XREADGROUP", "GROUP", bc.uid, bc.uid,
+			"COUNT", redisStreamCount, "BLOCK", redisStreamBlock.Milliseconds(), "STREAMS", key, id)
+		if err != nil {
+			return err
+		}
+
+		entries, err := streamEntries(reply)
+		if err != nil {
+			return err
+		}
+
+		if id != ">" && len(entries) == 0 {
+			id = ">"
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.fields != nil {
+				if err = bc.onEnvelope(entry.fields["node"], []byte(entry.fields["msg"])); err != nil {
+					logger.Error("redis stream entry "+entry.id+":", err)
+				}
+			}
+
+			if _, err = conn.Do("XACK", key, bc.uid, entry.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type streamEntry struct {
+	id     string
+	fields map[string]string
+}
+
+// streamEntries gives entries of XREADGROUP reply of one stream, nil reply has no entries.
+func streamEntries(reply interface{}) ([]streamEntry, error) {
+	if reply == nil {
+		return nil, nil
+	}
+
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) != 1 {
+		return nil, errors.New("invalid stream reply")
+	}
+
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, errors.New("invalid stream reply")
+	}
+
+	items, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]streamEntry, 0, len(items))
+	for _, item := range items {
+		values, err := redis.Values(item, nil)
+		if err != nil || len(values) != 2 {
+			return nil, errors.New("invalid stream entry")
+		}
+
+		id, err := redis.String(values[0], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// entry which was deleted, e.g. by trimming, while it was pending has no fields.
+		fields, err := redis.StringMap(values[1], nil)
+		if err != nil && !errors.Is(err, redis.ErrNil) {
+			return nil, err
+		}
+
+		entries = append(entries, streamEntry{id: id, fields: fields})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,113 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamReply is XREADGROUP reply of stream key with entries of id, node and message.
+func streamReply(key string, entries ...[3]string) string {
+	reply := "*1\r\n*2\r\n$" + strconv.Itoa(len(key)) + "\r\n" + key + "\r\n*" + strconv.Itoa(len(entries)) + "\r\n"
+	for _, entry := range entries {
+		reply += "*2\r\n$" + strconv.Itoa(len(entry[0])) + "\r\n" + entry[0] + "\r\n"
+		reply += respArray("node", entry[1], "msg", entry[2])
+	}
+
+	return reply
+}
+
+func TestRedisBroadcastStream(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	envelope := func(event string) string {
+		msg, err := json.Marshal(broadcastEnvelope{Opts: []interface{}{"lobby", event}})
+		must.NoError(err)
+
+		return string(msg)
+	}
+
+	var (
+		mu       sync.Mutex
+		pending  = true
+		live     = true
+		acked    []string
+		appended []string
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch cmd[0] {
+		case "XGROUP":
+			return "-BUSYGROUP Consumer Group name already exists\r\n"
+		case "XADD":
+			appended = append(appended, cmd[1:]...)
+			return "$3\r\n1-0\r\n"
+		case "XACK":
+			acked = append(acked, cmd[3])
+			return ":1\r\n"
+		case "XREADGROUP":
+			switch {
+			case cmd[len(cmd)-1] == "0" && pending:
+				pending = false
+				return streamReply(cmd[len(cmd)-2], [3]string{"1-0", "node-b", envelope("missed")})
+			case cmd[len(cmd)-1] == "0":
+				return streamReply(cmd[len(cmd)-2])
+			case live:
+				live = false
+				return streamReply(cmd[len(cmd)-2],
+					[3]string{"2-0", "node-a", envelope("own")},
+					[3]string{"3-0", "node-b", envelope("live")},
+				)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			return "*-1\r\n"
+		}
+
+		return "-ERR unknown command\r\n"
+	})
+
+	opts := getOptions(&RedisAdapterOptions{Addr: addr, Streams: true, StreamMaxLen: 100})
+	should.True(opts.Streams)
+
+	pub, err := opts.dial()
+	must.NoError(err)
+	defer pub.Close()
+
+	bc := &redisBroadcast{
+		pub:   &redis.PubSubConn{Conn: pub},
+		nsp:   "/",
+		uid:   "node-a",
+		rooms: make(map[string]map[string]Conn),
+		tree:  make(roomTree),
+		opts:  opts,
+	}
+
+	recorder := newLockedRecorder("1", "/", nil)
+	bc.rooms["lobby"] = map[string]Conn{"1": recorder}
+
+	go bc.readStream()
+
+	must.Eventually(func() bool {
+		return len(recorder.received()) == 2
+	}, time.Second, 5*time.Millisecond)
+	should.Equal([]string{"missed", "live"}, recorder.received())
+
+	must.NoError(bc.publishBroadcast("lobby", nil, time.Time{}, "hello"))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	should.Equal([]string{"1-0", "2-0", "3-0"}, acked)
+	must.Len(appended, 9)
+	should.Equal([]string{"socket.io-stream#/", "MAXLEN", "~", "100", "*", "node", "node-a", "msg"}, appended[:8])
+}
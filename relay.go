@@ -0,0 +1,277 @@
+package socketio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// RelayOptions configures relay of broadcasts between clusters, see NewRelay.
+type RelayOptions struct {
+	// ID identifies relay in channels of clusters, random id is generated when it's empty.
+	ID string
+
+	// Namespaces are relayed namespaces, all namespaces are relayed when it's empty.
+	Namespaces []string
+
+	// Rooms are relayed rooms, all broadcasts of relayed namespaces are relayed when it's empty.
+	// Otherwise only broadcasts to one of the rooms are relayed, not to room sets or namespace.
+	Rooms []string
+}
+
+func (o *RelayOptions) getID() string {
+	if o == nil || o.ID == "" {
+		return newV4UUID()
+	}
+
+	return o.ID
+}
+
+func (o *RelayOptions) getNamespaces() map[string]struct{} {
+	if o == nil || len(o.Namespaces) == 0 {
+		return nil
+	}
+
+	namespaces := make(map[string]struct{}, len(o.Namespaces))
+	for _, nsp := range o.Namespaces {
+		if nsp == aliasRootNamespace {
+			nsp = rootNamespace
+		}
+		namespaces[nsp] = struct{}{}
+	}
+
+	return namespaces
+}
+
+func (o *RelayOptions) getRooms() map[string]struct{} {
+	if o == nil || len(o.Rooms) == 0 {
+		return nil
+	}
+
+	rooms := make(map[string]struct{}, len(o.Rooms))
+	for _, room := range o.Rooms {
+		rooms[room] = struct{}{}
+	}
+
+	return rooms
+}
+
+// Relay forwards broadcasts between two clusters of redis adapter, e.g. clusters of regions,
+// so they don't need a single global redis. Each broadcast is relayed once, relayed broadcasts
+// keep their origin node and count their hops, so relays can't loop them, see MaxHops of
+// RedisAdapterOptions. Requests of adapter, like AllRooms or Len, aren't relayed.
+type Relay struct {
+	id         string
+	namespaces map[string]struct{}
+	rooms      map[string]struct{}
+
+	seen  seenEnvelopes
+	links []*relayLink
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// relayLink forwards broadcasts of one cluster to the other.
+type relayLink struct {
+	from *RedisAdapterOptions
+	to   *RedisAdapterOptions
+
+	sub *redis.PubSubConn
+	pub *redis.PubSubConn
+}
+
+// NewRelay starts relay of broadcasts between clusters of adapters a and b, in both directions.
+func NewRelay(a, b *RedisAdapterOptions, opts *RelayOptions) (*Relay, error) {
+	a, b = getOptions(a), getOptions(b)
+
+	r := &Relay{
+		id:         opts.getID(),
+		namespaces: opts.getNamespaces(),
+		rooms:      opts.getRooms(),
+	}
+
+	for _, clusters := range [][2]*RedisAdapterOptions{{a, b}, {b, a}} {
+		link, err := r.newLink(clusters[0], clusters[1])
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+
+		r.links = append(r.links, link)
+	}
+
+	for _, link := range r.links {
+		r.wg.Add(1)
+		go r.dispatch(link)
+	}
+
+	return r, nil
+}
+
+func (r *Relay) newLink(from, to *RedisAdapterOptions) (*relayLink, error) {
+	sub, err := from.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := to.dial()
+	if err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	link := &relayLink{
+		from: from,
+		to:   to,
+		sub:  &redis.PubSubConn{Conn: sub},
+		pub:  &redis.PubSubConn{Conn: pub},
+	}
+
+	var patterns []interface{}
+	if r.namespaces == nil {
+		patterns = append(patterns, fmt.Sprintf("%s#*", from.Prefix))
+	}
+	for nsp := range r.namespaces {
+		patterns = append(patterns, fmt.Sprintf("%s#%s#*", from.Prefix, nsp))
+	}
+
+	if err = link.sub.PSubscribe(patterns...); err != nil {
+		_ = link.close()
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// ID gives identity of relay in channels of clusters.
+func (r *Relay) ID() string {
+	return r.id
+}
+
+// Close stops relay and closes its redis connections.
+func (r *Relay) Close() error {
+	var err error
+
+	r.closeOnce.Do(func() {
+		for _, link := range r.links {
+			if closeErr := link.close(); closeErr != nil {
+				err = closeErr
+			}
+		}
+
+		r.wg.Wait()
+	})
+
+	return err
+}
+
+func (l *relayLink) close() error {
+	err := l.sub.Close()
+	if closeErr := l.pub.Close(); closeErr != nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (r *Relay) dispatch(link *relayLink) {
+	defer r.wg.Done()
+
+	for {
+		switch m := receive(link.sub).(type) {
+		case redis.Message:
+			channel, msg, ok := r.forward(link.from, link.to, m.Channel, m.Data)
+			if !ok {
+				break
+			}
+
+			if _, err := link.pub.Conn.Do("PUBLISH", channel, msg); err != nil {
+				logger.Error("relay broadcast:", err)
+			}
+
+		case redis.Subscription:
+			if m.Count == 0 {
+				return
+			}
+
+		case error:
+			return
+		}
+	}
+}
+
+// forward gives channel and message of broadcast relayed from cluster to cluster, ok is false
+// when broadcast isn't relayed.
+func (r *Relay) forward(from, to *RedisAdapterOptions, channel string, msg []byte) (string, []byte, bool) {
+	rest := strings.TrimPrefix(channel, from.Prefix+"#")
+	i := strings.LastIndex(rest, "#")
+	if rest == channel || i < 0 {
+		return "", nil, false
+	}
+
+	nsp, uid := rest[:i], rest[i+1:]
+	// broadcast was relayed by this relay
+	if uid == r.id {
+		return "", nil, false
+	}
+
+	if r.namespaces != nil {
+		if _, ok := r.namespaces[nsp]; !ok {
+			return "", nil, false
+		}
+	}
+
+	var envelope broadcastEnvelope
+	if err := json.Unmarshal(msg, &envelope); err != nil || len(envelope.Opts) < 2 {
+		return "", nil, false
+	}
+
+	if !r.relayRoom(envelope.Opts) {
+		return "", nil, false
+	}
+
+	meta := envelopeMeta{Origin: uid, ID: newV4UUID()}
+	if envelope.Meta != nil {
+		meta = *envelope.Meta
+		meta.Hops++
+	}
+
+	maxHops := to.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+	if meta.Hops > maxHops || r.seen.seen(meta.ID, time.Now()) {
+		return "", nil, false
+	}
+	envelope.Meta = &meta
+
+	relayed, err := json.Marshal(envelope)
+	if err != nil {
+		return "", nil, false
+	}
+
+	return fmt.Sprintf("%s#%s#%s", to.Prefix, nsp, r.id), relayed, true
+}
+
+// relayRoom tells whether broadcast with opts is relayed by rooms of relay.
+func (r *Relay) relayRoom(opts []interface{}) bool {
+	if r.rooms == nil {
+		return true
+	}
+
+	if len(opts) > 2 && opts[2] != nil {
+		return false
+	}
+
+	room, _ := opts[0].(string)
+	_, ok := r.rooms[room]
+
+	return ok
+}
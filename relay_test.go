@@ -0,0 +1,66 @@
+package socketio
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayForward(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	east := getOptions(&RedisAdapterOptions{Prefix: "east"})
+	west := getOptions(&RedisAdapterOptions{Prefix: "west", MaxHops: 2})
+
+	r := &Relay{
+		id:         "relay",
+		namespaces: (&RelayOptions{Namespaces: []string{"/", "/chat"}}).getNamespaces(),
+		rooms:      (&RelayOptions{Rooms: []string{"lobby"}}).getRooms(),
+	}
+
+	message := func(room string, meta *envelopeMeta) []byte {
+		msg, err := json.Marshal(broadcastEnvelope{
+			Opts: []interface{}{room, "message"},
+			Args: []interface{}{"hello"},
+			Meta: meta,
+		})
+		must.NoError(err)
+		return msg
+	}
+
+	channel, msg, ok := r.forward(east, west, "east#/chat#node-a", message("lobby", &envelopeMeta{Origin: "node-a", ID: "1"}))
+	must.True(ok)
+	should.Equal("west#/chat#relay", channel)
+
+	var envelope broadcastEnvelope
+	must.NoError(json.Unmarshal(msg, &envelope))
+	should.Equal(&envelopeMeta{Origin: "node-a", ID: "1", Hops: 1}, envelope.Meta)
+	should.Equal([]interface{}{"lobby", "message"}, envelope.Opts)
+	should.Equal([]interface{}{"hello"}, envelope.Args)
+
+	_, _, ok = r.forward(west, east, "west#/chat#node-b", message("lobby", &envelopeMeta{Origin: "node-a", ID: "1", Hops: 1}))
+	should.False(ok, "broadcast is relayed once")
+
+	_, _, ok = r.forward(west, east, "west#/chat#relay", message("lobby", &envelopeMeta{Origin: "node-a", ID: "2"}))
+	should.False(ok, "broadcast of relay")
+
+	_, _, ok = r.forward(east, west, "east#/chat#node-a", message("lobby", &envelopeMeta{Origin: "node-a", ID: "3", Hops: 2}))
+	should.False(ok, "too many hops")
+
+	_, _, ok = r.forward(east, west, "east#/admin#node-a", message("lobby", nil))
+	should.False(ok, "namespace isn't relayed")
+
+	_, _, ok = r.forward(east, west, "east#/chat#node-a", message("private", nil))
+	should.False(ok, "room isn't relayed")
+
+	channel, msg, ok = r.forward(east, west, "east##node-a", message("lobby", nil))
+	must.True(ok, "broadcast of older node")
+	should.Equal("west##relay", channel)
+	must.NoError(json.Unmarshal(msg, &envelope))
+	should.Equal("node-a", envelope.Meta.Origin)
+	should.NotEmpty(envelope.Meta.ID)
+	should.Zero(envelope.Meta.Hops)
+}
@@ -0,0 +1,101 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownSID is returned by JoinRoomBySID when namespace has no connection with the sid and
+// its broadcaster can't reach connections of other nodes.
+var ErrUnknownSID = errors.New("no connection with sid")
+
+// remoteJoiner is broadcaster which makes connections of other nodes join rooms.
+type remoteJoiner interface {
+	joinRemote(room, sid string) error
+}
+
+// JoinRoomBySID makes connection of namespace with id sid join room, e.g. for REST API which grants
+// room access to user known only by sid. Connection of this node joins at once, otherwise join is
+// propagated through the adapter to the node which serves the connection, without waiting for it
+// to be applied. Without adapter it fails with ErrUnknownSID when there's no such connection.
+func (s *Server) JoinRoomBySID(namespace, room, sid string) error {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return fmt.Errorf("namespace %q doesn't exist", namespace)
+	}
+
+	bc := nspHandler.getBroadcast()
+	if connection := connBySID(bc, sid); connection != nil {
+		return connection.TryJoin(room)
+	}
+
+	if joiner, ok := bc.(remoteJoiner); ok {
+		return joiner.joinRemote(room, sid)
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknownSID, sid)
+}
+
+// connBySID gives connection of this node with id sid, which is member of room of its id, nil
+// when there's none.
+func connBySID(bc Broadcast, sid string) Conn {
+	var found Conn
+	bc.ForEach(sid, func(connection Conn) {
+		if connection.ID() == sid {
+			found = connection
+		}
+	})
+
+	return found
+}
+
+// remoteJoinRequest makes connection with id SID join Room on node which serves it.
+type remoteJoinRequest struct {
+	RequestType string
+	UUID        string
+	Room        string
+	SID         string
+}
+
+func (bc *redisBroadcast) joinRemote(room, sid string) error {
+	var req interface{}
+	if bc.nodeCompatible() {
+		req = &nodeRequest{
+			UID:   bc.uid,
+			Type:  nodeRemoteJoin,
+			Opts:  &nodeRequestOpts{Rooms: []string{sid}, Except: []string{}},
+			Rooms: []string{room},
+		}
+	} else {
+		req = &remoteJoinRequest{
+			RequestType: remoteJoinReqType,
+			UUID:        bc.uid,
+			Room:        room,
+			SID:         sid,
+		}
+	}
+
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	_, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON)
+	return err
+}
+
+// onRemoteJoinRequest makes connection of this node join room of request of other node.
+func (bc *redisBroadcast) onRemoteJoinRequest(req map[string]string) {
+	if req["UUID"] == bc.uid {
+		return
+	}
+
+	if connection := connBySID(bc, req["SID"]); connection != nil {
+		connection.Join(req["Room"])
+	}
+}
+
+func (bc *busBroadcast) joinRemote(room, sid string) error {
+	return bc.adapter.publish(&busRecord{Type: busRecordJoin, Namespace: bc.nsp, Room: room, SID: sid})
+}
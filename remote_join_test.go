@@ -0,0 +1,124 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestServerJoinRoomBySID(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", server.getNamespace("/").getBroadcast())
+	nc.Join("1")
+
+	must.NoError(server.JoinRoomBySID("/", "vip", "1"))
+	should.Equal(1, server.RoomLen("/", "vip"))
+
+	should.ErrorIs(server.JoinRoomBySID("/", "vip", "2"), ErrUnknownSID)
+	should.Error(server.JoinRoomBySID("/missing", "vip", "1"))
+
+	// connection of other node joins through message bus
+	bus := &memoryKafka{}
+	servers, _ := newAdapterServers(t, "/", func(_ int, server *Server) error {
+		return server.KafkaAdapter(&KafkaAdapterOptions{Producer: bus, Consumer: bus})
+	}, func() bool {
+		return bus.consumerCount() == 2
+	})
+
+	remote := newNamespaceConn(&conn{Conn: addrEngineConn{id: "2"}}, "/", servers[1].getNamespace("/").getBroadcast())
+	remote.Join("2")
+
+	must.NoError(servers[0].JoinRoomBySID("/", "vip", "2"))
+	should.Equal(1, servers[1].RoomLen("/", "vip"))
+	should.Zero(servers[0].RoomLen("/", "vip"))
+}
+
+func TestRedisBroadcastJoinRemote(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 16)
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBLISH":
+			published <- cmd[1:]
+		}
+		return ":1\r\n"
+	})
+
+	for _, nodeCompatible := range []bool{false, true} {
+		bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{
+			Addr:           addr,
+			NodeID:         "node1",
+			NodeCompatible: nodeCompatible,
+		}))
+		must.NoError(err)
+
+		server := NewServer(&engineio.Options{})
+		must.NoError(server.SetBroadcaster("/chat", bc))
+
+		nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/chat", bc)
+		nc.Join("1")
+
+		must.NoError(server.JoinRoomBySID("/chat", "vip", "2"))
+
+		var msg []string
+		select {
+		case msg = <-published:
+		case <-time.After(5 * time.Second):
+			t.Fatal("join isn't published")
+		}
+		should.Equal(bc.reqChannel, msg[0])
+
+		var req map[string]interface{}
+		must.NoError(json.Unmarshal([]byte(msg[1]), &req))
+		if nodeCompatible {
+			should.Equal(map[string]interface{}{
+				"uid":   "node1",
+				"type":  float64(nodeRemoteJoin),
+				"opts":  map[string]interface{}{"rooms": []interface{}{"2"}, "except": []interface{}{}},
+				"rooms": []interface{}{"vip"},
+			}, req)
+		} else {
+			should.Equal(map[string]interface{}{
+				"RequestType": remoteJoinReqType,
+				"UUID":        "node1",
+				"Room":        "vip",
+				"SID":         "2",
+			}, req)
+		}
+
+		// own request is ignored, request of other node joins local connection
+		if nodeCompatible {
+			bc.onNodeRequest([]byte(msg[1]))
+			bc.onNodeRequest([]byte(`{"uid":"node2","type":2,"opts":{"rooms":["1"],"except":[]},"rooms":["vip"]}`))
+		} else {
+			bc.onRequest([]byte(msg[1]))
+			bc.onRequest([]byte(`{"RequestType":"7","UUID":"node2","Room":"vip","SID":"1"}`))
+		}
+		should.ElementsMatch([]string{"1", "vip"}, nc.Rooms())
+
+		_ = server.Close()
+	}
+}
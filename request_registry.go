@@ -0,0 +1,209 @@
+package socketio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRoomQueryTimeout is returned when other nodes don't respond room query within
+// RequestTimeout, results are partial then.
+var ErrRoomQueryTimeout = errors.New("room query timed out")
+
+var (
+	errDuplicateRequest = errors.New("duplicate request id")
+	errRequestsClosed   = errors.New("adapter is closed")
+)
+
+// remoteRequest is request to other nodes whose responses are tracked by pendingRequest.
+type remoteRequest interface {
+	pending() *pendingRequest
+}
+
+// requestRegistry tracks pending requests to other nodes by their id. Requests are registered
+// by goroutines of callers and responded by goroutine which receives responses.
+type requestRegistry struct {
+	requests map[string]remoteRequest
+	lock     sync.Mutex
+}
+
+func newRequestRegistry() *requestRegistry {
+	return &requestRegistry{
+		requests: make(map[string]remoteRequest),
+	}
+}
+
+// register tracks req under id until release is called. Request waits for numSub responses,
+// it's cancelled once ctx is done or timeout passes.
+func (reg *requestRegistry) register(ctx context.Context, id string, req remoteRequest, numSub int, timeout time.Duration) (release func(), err error) {
+	r := req.pending()
+
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+
+	if reg.requests == nil {
+		return nil, errRequestsClosed
+	}
+	if _, ok := reg.requests[id]; ok {
+		return nil, fmt.Errorf("%w %q", errDuplicateRequest, id)
+	}
+
+	r.init(ctx, numSub, timeout)
+	reg.requests[id] = req
+
+	return func() {
+		reg.lock.Lock()
+		if reg.requests[id] == req {
+			delete(reg.requests, id)
+		}
+		reg.lock.Unlock()
+
+		r.cancel(context.Canceled)
+		r.stop()
+	}, nil
+}
+
+func (reg *requestRegistry) get(id string) (remoteRequest, bool) {
+	reg.lock.Lock()
+	defer reg.lock.Unlock()
+
+	req, ok := reg.requests[id]
+	return req, ok
+}
+
+// nodeDead stops waiting for responses of the dead node in all pending requests.
+func (reg *requestRegistry) nodeDead(nodeID string) {
+	reg.lock.Lock()
+	requests := make([]remoteRequest, 0, len(reg.requests))
+	for _, req := range reg.requests {
+		requests = append(requests, req)
+	}
+	reg.lock.Unlock()
+
+	for _, req := range requests {
+		req.pending().nodeDead(nodeID)
+	}
+}
+
+// close cancels pending requests with err and rejects new ones.
+func (reg *requestRegistry) close(err error) {
+	reg.lock.Lock()
+	requests := reg.requests
+	reg.requests = nil
+	reg.lock.Unlock()
+
+	for _, req := range requests {
+		req.pending().cancel(err)
+	}
+}
+
+// pendingRequest tracks responses of nodes to a request. Each node is counted once, responses
+// which come after request completed or was cancelled are dropped.
+type pendingRequest struct {
+	numSub    int
+	msgCount  int
+	responded map[string]bool
+
+	// expected are nodes which request was sent to, only they are stopped waiting for once dead.
+	expected map[string]bool
+	finished bool
+	mutex    sync.Mutex
+	done     chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	stop   context.CancelFunc
+}
+
+func (r *pendingRequest) pending() *pendingRequest {
+	return r
+}
+
+// expectNodes sets nodes which request is sent to, it's called before request is registered.
+// Dead node which isn't expected isn't counted in numSub, so it doesn't complete request.
+func (r *pendingRequest) expectNodes(nodeIDs []string) {
+	r.expected = make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		r.expected[id] = true
+	}
+}
+
+func (r *pendingRequest) init(ctx context.Context, numSub int, timeout time.Duration) {
+	r.numSub = numSub
+	r.responded = make(map[string]bool)
+	r.done = make(chan struct{})
+
+	r.ctx, r.cancel = context.WithCancelCause(ctx)
+	r.ctx, r.stop = context.WithTimeoutCause(r.ctx, timeout, ErrRoomQueryTimeout)
+
+	// request which expects no responses is done
+	r.mutex.Lock()
+	r.checkDone()
+	r.mutex.Unlock()
+}
+
+// respond applies the response of node with f, and completes request when all nodes responded.
+// Responses of node.js adapter don't tell node, they have empty nodeID and each one counts.
+func (r *pendingRequest) respond(nodeID string, f func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.finished || r.ctx.Err() != nil {
+		return
+	}
+	if nodeID != "" && r.responded[nodeID] {
+		return
+	}
+
+	f()
+	r.msgCount++
+	r.responded[nodeID] = true
+	r.checkDone()
+}
+
+// nodeDead stops waiting for response of the dead node. Without expected nodes every dead node is
+// assumed to be counted.
+func (r *pendingRequest) nodeDead(nodeID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.finished || r.responded[nodeID] {
+		return
+	}
+	if r.expected != nil && !r.expected[nodeID] {
+		return
+	}
+
+	r.numSub--
+	r.responded[nodeID] = true
+	r.checkDone()
+}
+
+// wait waits until every node responded or request is cancelled, error tells responses are
+// partial. Responses aren't applied after it returns.
+func (r *pendingRequest) wait() error {
+	select {
+	case <-r.done:
+		return nil
+	case <-r.ctx.Done():
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.finished {
+		return nil
+	}
+	r.finished = true
+
+	return fmt.Errorf("%w: %d of %d nodes responded", context.Cause(r.ctx), r.msgCount, r.numSub)
+}
+
+func (r *pendingRequest) checkDone() {
+	if !r.finished && r.msgCount >= r.numSub {
+		r.finished = true
+		close(r.done)
+	}
+}
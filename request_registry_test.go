@@ -0,0 +1,123 @@
+package socketio
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestRegistry(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	reg := newRequestRegistry()
+
+	req := &roomLenRequest{}
+	release, err := reg.register(context.Background(), "1", req, 3, time.Minute)
+	must.NoError(err)
+
+	_, err = reg.register(context.Background(), "1", &roomLenRequest{}, 1, time.Minute)
+	should.ErrorIs(err, errDuplicateRequest)
+
+	// duplicate responses of node count once, dead node isn't waited for
+	req.respond("node1", func() { req.connections += 2 })
+	req.respond("node1", func() { req.connections += 2 })
+	reg.nodeDead("node2")
+	reg.nodeDead("node2")
+	req.respond("node3", func() { req.connections++ })
+
+	must.NoError(req.wait())
+	should.Equal(3, req.connections)
+
+	// responses after request completed are dropped
+	req.respond("node4", func() { req.connections++ })
+	should.Equal(3, req.connections)
+
+	release()
+	_, ok := reg.get("1")
+	should.False(ok)
+
+	// cancelled by context of caller
+	ctx, cancel := context.WithCancel(context.Background())
+	req = &roomLenRequest{}
+	release, err = reg.register(ctx, "2", req, 2, time.Minute)
+	must.NoError(err)
+	req.respond("node1", func() { req.connections++ })
+	cancel()
+	err = req.wait()
+	should.ErrorIs(err, context.Canceled)
+	should.ErrorContains(err, "1 of 2 nodes responded")
+	release()
+
+	// concurrent requests and responses
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			req := &roomLenRequest{}
+			release, err := reg.register(context.Background(), id, req, 2, time.Minute)
+			must.NoError(err)
+			defer release()
+
+			for _, node := range []string{"node1", "node2"} {
+				go func(node string) {
+					if r, ok := reg.get(id); ok {
+						r.pending().respond(node, func() { req.connections++ })
+					}
+				}(node)
+			}
+
+			should.NoError(req.wait())
+			req.mutex.Lock()
+			should.Equal(2, req.connections)
+			req.mutex.Unlock()
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	// pending requests are cancelled once registry is closed
+	req = &roomLenRequest{}
+	_, err = reg.register(context.Background(), "3", req, 1, time.Minute)
+	must.NoError(err)
+	reg.close(errRequestsClosed)
+	should.ErrorIs(req.wait(), errRequestsClosed)
+
+	_, err = reg.register(context.Background(), "4", &roomLenRequest{}, 1, time.Minute)
+	should.ErrorIs(err, errRequestsClosed)
+}
+
+func TestRequestRegistryExpectedNodes(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	reg := newRequestRegistry()
+
+	req := &roomLenRequest{}
+	req.expectNodes([]string{"node1", "node2"})
+	release, err := reg.register(context.Background(), "1", req, 2, 50*time.Millisecond)
+	must.NoError(err)
+	defer release()
+
+	// node which died before request was sent isn't counted in numSub
+	reg.nodeDead("node3")
+	req.respond("node1", func() { req.connections++ })
+
+	should.ErrorIs(req.wait(), ErrRoomQueryTimeout, "response of node2 is still waited for")
+
+	req = &roomLenRequest{}
+	req.expectNodes([]string{"node1", "node2"})
+	release, err = reg.register(context.Background(), "2", req, 2, time.Minute)
+	must.NoError(err)
+	defer release()
+
+	reg.nodeDead("node2")
+	req.respond("node1", func() { req.connections++ })
+	should.NoError(req.wait())
+}
@@ -0,0 +1,490 @@
+package socketio
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const defaultResumeTTL = 2 * time.Minute
+
+// ErrStaleResumeToken rejects resume token presented with epoch of client which isn't greater
+// than the last epoch server accepted for its session, e.g. replayed token, see
+// ResumeOptions.RequireEpoch.
+var ErrStaleResumeToken = errors.New("stale resume token")
+
+// connectPayloadType is type of CONNECT packet payload, e.g. auth of client or sid and pid of server.
+var connectPayloadType = []reflect.Type{reflect.TypeOf(map[string]interface{}{})}
+
+// ResumeOptions configures resume tokens issued by server, see Server.EnableResume.
+type ResumeOptions struct {
+	// Secret signs resume tokens, random secret is generated when it's empty.
+	// Servers behind the same load balancer should share the secret.
+	Secret []byte
+
+	// TTL is how long rooms of a disconnected connection are kept for resumption, default is 2 minutes.
+	TTL time.Duration
+
+	// SkipConnectHandler skips connect handler of namespace when connection is resumed.
+	SkipConnectHandler bool
+
+	// Storage persists rooms of disconnected connections, so they can resume on another server node.
+	// When it's nil, rooms are kept in redis of adapter if server has one, else in memory.
+	Storage ResumeStorage
+
+	// RequireEpoch rejects resume tokens presented without client epoch. Client presents
+	// epoch, a counter of its reconnects, in "epoch" of auth with the token. Server records
+	// epoch of each connect with its session, so token is stale unless client presents a
+	// greater epoch. Epoch is verified once session has one, RequireEpoch makes it mandatory.
+	RequireEpoch bool
+
+	// VerifyResume is called before connection is resumed, error rejects the token,
+	// then connection isn't resumed but connects as a new one.
+	VerifyResume func(conn Conn, attempt ResumeAttempt) error
+}
+
+// ResumeAttempt is resume token presented by client, see ResumeOptions.VerifyResume.
+type ResumeAttempt struct {
+	Namespace string
+	// SessionID identifies resume session of the token.
+	SessionID string
+	// TokenEpoch is the last epoch of client server accepted for the session.
+	TokenEpoch uint64
+	// Epoch is epoch presented by client, zero when it's missing.
+	Epoch uint64
+	// Auth is auth of CONNECT packet of client.
+	Auth map[string]interface{}
+}
+
+// ResumeSession is session of disconnected connection kept by ResumeStorage.
+type ResumeSession struct {
+	Rooms []string `json:"rooms"`
+	// Epoch is the last epoch of client accepted by server.
+	Epoch uint64 `json:"epoch"`
+}
+
+// ResumeStorage persists sessions of disconnected connections by their resume session id.
+type ResumeStorage interface {
+	// Save keeps session for ttl.
+	Save(id string, session ResumeSession, ttl time.Duration) error
+	// Load gives session and removes it, found is false when session doesn't exist or expired.
+	Load(id string) (session ResumeSession, found bool, err error)
+}
+
+func (o *ResumeOptions) getSecret() []byte {
+	if o != nil && len(o.Secret) > 0 {
+		return o.Secret
+	}
+
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	return secret
+}
+
+func (o *ResumeOptions) getTTL() time.Duration {
+	if o == nil || o.TTL <= 0 {
+		return defaultResumeTTL
+	}
+
+	return o.TTL
+}
+
+func (o *ResumeOptions) getSkipConnectHandler() bool {
+	return o != nil && o.SkipConnectHandler
+}
+
+func (o *ResumeOptions) getStorage() ResumeStorage {
+	if o == nil {
+		return nil
+	}
+
+	return o.Storage
+}
+
+func (o *ResumeOptions) getRequireEpoch() bool {
+	return o != nil && o.RequireEpoch
+}
+
+func (o *ResumeOptions) getVerifyResume() func(Conn, ResumeAttempt) error {
+	if o == nil {
+		return nil
+	}
+
+	return o.VerifyResume
+}
+
+// EnableResume makes server issue a resume token ("pid") in CONNECT response of each namespace.
+// Client which presents the token in auth of its CONNECT packet on reconnect rejoins rooms of
+// its previous connection.
+func (s *Server) EnableResume(opts *ResumeOptions) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.resume = newResumeStore(opts)
+	s.useRedisResumeStorage()
+
+	return nil
+}
+
+// useRedisResumeStorage keeps resume sessions in redis of adapter, unless storage is set by
+// ResumeOptions, so sessions are resumed on any node of the cluster.
+func (s *Server) useRedisResumeStorage() {
+	opts := s.getRedisAdapter()
+	if s.resume == nil || s.resume.storage != nil || opts == nil {
+		return
+	}
+
+	storage := newRedisResumeStorage(opts)
+	s.resume.storage = storage
+	s.OnServerShutdownComplete(func() {
+		_ = storage.Close()
+	})
+}
+
+type resumeSession struct {
+	namespace string
+
+	// conn is set while previous connection is alive, rooms and expires once it's gone.
+	conn    *namespaceConn
+	rooms   []string
+	expires time.Time
+	// epoch is the last epoch of client accepted for session.
+	epoch uint64
+}
+
+// resumeStore keeps sessions which may be resumed by their token.
+type resumeStore struct {
+	secret             []byte
+	ttl                time.Duration
+	skipConnectHandler bool
+	storage            ResumeStorage
+	requireEpoch       bool
+	verify             func(Conn, ResumeAttempt) error
+
+	mu       sync.Mutex
+	sessions map[string]*resumeSession
+}
+
+func newResumeStore(opts *ResumeOptions) *resumeStore {
+	return &resumeStore{
+		secret:             opts.getSecret(),
+		ttl:                opts.getTTL(),
+		skipConnectHandler: opts.getSkipConnectHandler(),
+		storage:            opts.getStorage(),
+		requireEpoch:       opts.getRequireEpoch(),
+		verify:             opts.getVerifyResume(),
+		sessions:           make(map[string]*resumeSession),
+	}
+}
+
+// resumeClaim is resume token presented by client in auth of its CONNECT packet.
+type resumeClaim struct {
+	token string
+	// epoch is counter of reconnects of client, zero when it's missing.
+	epoch uint64
+	auth  map[string]interface{}
+}
+
+func newResumeClaim(args []reflect.Value) resumeClaim {
+	return resumeClaim{
+		token: connectPayloadString(args, "pid"),
+		epoch: connectPayloadUint(args, "epoch"),
+		auth:  connectPayload(args),
+	}
+}
+
+// issue returns new resume token for namespace connection and records accepted epoch of client
+// with its session. Token is replaced on each connect, so used token can't be presented again.
+func (r *resumeStore) issue(nc *namespaceConn, epoch uint64) string {
+	if r == nil {
+		return ""
+	}
+
+	id := newV4UUID()
+
+	r.mu.Lock()
+	delete(r.sessions, nc.resumeID)
+	r.sessions[id] = &resumeSession{namespace: nc.namespace, conn: nc, epoch: epoch}
+	r.mu.Unlock()
+
+	nc.resumeID = id
+
+	return id + "." + r.sign(nc.namespace, id)
+}
+
+// save keeps rooms of closed namespace connection until its session expires.
+func (r *resumeStore) save(nc *namespaceConn) {
+	if r == nil || nc.resumeID == "" {
+		return
+	}
+
+	rooms := resumeRooms(nc)
+	now := time.Now()
+
+	r.mu.Lock()
+
+	for id, session := range r.sessions {
+		if session.conn == nil && now.After(session.expires) {
+			delete(r.sessions, id)
+		}
+	}
+
+	session, ok := r.sessions[nc.resumeID]
+	if !ok || session.conn != nc {
+		r.mu.Unlock()
+		return
+	}
+
+	if r.storage == nil {
+		session.conn = nil
+		session.rooms = rooms
+		session.expires = now.Add(r.ttl)
+		r.mu.Unlock()
+		return
+	}
+
+	delete(r.sessions, nc.resumeID)
+	epoch := session.epoch
+	r.mu.Unlock()
+
+	if err := r.storage.Save(nc.resumeID, ResumeSession{Rooms: rooms, Epoch: epoch}, r.ttl); err != nil {
+		logger.Error("save resume session:", err)
+	}
+}
+
+// forget drops session of namespace connection, e.g. when client disconnects on purpose.
+func (r *resumeStore) forget(nc *namespaceConn) {
+	if r == nil || nc.resumeID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	delete(r.sessions, nc.resumeID)
+	r.mu.Unlock()
+}
+
+// restore joins namespace connection to rooms of session of claimed token, it reports whether
+// session was resumed. Each token can be used once, token which is rejected isn't used up,
+// so replayed token doesn't take the session away from its client. Previous connection which
+// is still alive, e.g. over half open transport, is closed, so session has one live connection.
+func (r *resumeStore) restore(nc *namespaceConn, claim resumeClaim) bool {
+	if r == nil || claim.token == "" {
+		return false
+	}
+
+	id, ok := r.verifyToken(nc.namespace, claim.token)
+	if !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	session, ok := r.sessions[id]
+	var epoch uint64
+	if ok {
+		epoch = session.epoch
+		ok = session.namespace == nc.namespace && (session.conn != nil || time.Now().Before(session.expires))
+	}
+	r.mu.Unlock()
+
+	if session == nil {
+		// previous connection may be gone on another node
+		return r.restoreStored(nc, id, claim)
+	}
+
+	if !ok {
+		return false
+	}
+
+	if err := r.verifyEpoch(nc, id, epoch, claim); err != nil {
+		logger.Info("resume token is rejected", "namespace", nc.namespace, "err", err.Error())
+		return false
+	}
+
+	// session is taken by one of connections which present the token at once
+	r.mu.Lock()
+	if r.sessions[id] != session {
+		r.mu.Unlock()
+		return false
+	}
+	delete(r.sessions, id)
+	prev, rooms := session.conn, session.rooms
+	r.mu.Unlock()
+
+	if prev != nil {
+		rooms = resumeRooms(prev)
+		_ = prev.Close()
+	}
+
+	for _, room := range rooms {
+		nc.Join(room)
+	}
+
+	return true
+}
+
+func (r *resumeStore) restoreStored(nc *namespaceConn, id string, claim resumeClaim) bool {
+	if r.storage == nil {
+		return false
+	}
+
+	session, found, err := r.storage.Load(id)
+	if err != nil {
+		logger.Error("load resume session:", err)
+		return false
+	}
+
+	if !found {
+		return false
+	}
+
+	if err = r.verifyEpoch(nc, id, session.Epoch, claim); err != nil {
+		logger.Info("resume token is rejected", "namespace", nc.namespace, "err", err.Error())
+
+		if err = r.storage.Save(id, session, r.ttl); err != nil {
+			logger.Error("save resume session:", err)
+		}
+		return false
+	}
+
+	for _, room := range session.Rooms {
+		nc.Join(room)
+	}
+
+	return true
+}
+
+// resumeRooms gives rooms of namespace connection which are restored on resume, room of its id
+// isn't restored.
+func resumeRooms(nc *namespaceConn) []string {
+	rooms := make([]string, 0)
+	for _, room := range nc.Rooms() {
+		if room != nc.ID() {
+			rooms = append(rooms, room)
+		}
+	}
+
+	return rooms
+}
+
+// verifyToken checks signature of token, it gives session id of the token.
+func (r *resumeStore) verifyToken(namespace, token string) (string, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(r.sign(namespace, token[:i]))) {
+		return "", false
+	}
+
+	return token[:i], true
+}
+
+// verifyEpoch rejects token unless client presents epoch greater than epoch server accepted for
+// its session, epoch is optional until session has one. Then it calls VerifyResume hook.
+func (r *resumeStore) verifyEpoch(nc *namespaceConn, id string, tokenEpoch uint64, claim resumeClaim) error {
+	if (r.requireEpoch || tokenEpoch > 0) && claim.epoch <= tokenEpoch {
+		return fmt.Errorf("%w: client epoch %d, session epoch %d", ErrStaleResumeToken, claim.epoch, tokenEpoch)
+	}
+
+	if r.verify == nil {
+		return nil
+	}
+
+	return r.verify(nc, ResumeAttempt{
+		Namespace:  nc.namespace,
+		SessionID:  id,
+		TokenEpoch: tokenEpoch,
+		Epoch:      claim.epoch,
+		Auth:       claim.auth,
+	})
+}
+
+func (r *resumeStore) sign(namespace, id string) string {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(namespace + "\n" + id))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// redisResumeStorage keeps resume sessions in redis of adapter.
+type redisResumeStorage struct {
+	opts *RedisAdapterOptions
+	pool *redis.Pool
+}
+
+func newRedisResumeStorage(opts *RedisAdapterOptions) *redisResumeStorage {
+	return &redisResumeStorage{opts: opts, pool: opts.newPool()}
+}
+
+// Close closes connections of storage.
+func (s *redisResumeStorage) Close() error {
+	return s.pool.Close()
+}
+
+func (s *redisResumeStorage) key(id string) string {
+	return fmt.Sprintf("%s-resume#%s", s.opts.Prefix, id)
+}
+
+func (s *redisResumeStorage) Save(id string, session ResumeSession, ttl time.Duration) error {
+	data, err := json.Marshal(&session)
+	if err != nil {
+		return err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("SET", s.key(id), data, "PX", ttl.Milliseconds())
+
+	return err
+}
+
+func (s *redisResumeStorage) Load(id string) (ResumeSession, bool, error) {
+	var session ResumeSession
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	// GET and DEL in transaction, so session is resumed once
+	if err := conn.Send("MULTI"); err != nil {
+		return session, false, err
+	}
+	if err := conn.Send("GET", s.key(id)); err != nil {
+		return session, false, err
+	}
+	if err := conn.Send("DEL", s.key(id)); err != nil {
+		return session, false, err
+	}
+
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return session, false, err
+	}
+
+	data, err := redis.Bytes(replies[0], nil)
+	if err == redis.ErrNil {
+		return session, false, nil
+	}
+	if err != nil {
+		return session, false, err
+	}
+
+	if err = json.Unmarshal(data, &session); err != nil {
+		return session, false, err
+	}
+
+	return session, true, nil
+}
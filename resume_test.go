@@ -0,0 +1,402 @@
+package socketio
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+	"github.com/thisismz/go-socket.io/engineio/transport/websocket"
+)
+
+func TestServerResume(t *testing.T) {
+	tests := []struct {
+		name               string
+		skipConnectHandler bool
+		requireEpoch       bool
+		connects           int
+	}{
+		{"rejoin rooms", false, false, 2},
+		{"skip connect handler", true, false, 1},
+		{"require epoch", false, true, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			should := assert.New(t)
+			must := require.New(t)
+
+			server := NewServer(&engineio.Options{
+				Transports: []transport.Transport{polling.Default},
+			})
+			must.NoError(server.EnableResume(&ResumeOptions{
+				SkipConnectHandler: test.skipConnectHandler,
+				RequireEpoch:       test.requireEpoch,
+			}))
+
+			connects := make(chan []string, 2)
+			server.OnConnect("/", func(Conn) error {
+				return nil
+			})
+			server.OnConnect("/chat", func(c Conn) error {
+				connects <- c.Rooms()
+				return nil
+			})
+			server.OnEvent("/chat", "join", func(c Conn, room string) string {
+				c.Join(room)
+				return room
+			})
+
+			go func() {
+				_ = server.Serve()
+			}()
+			defer server.Close()
+
+			httpSvr := httptest.NewServer(server)
+			defer httpSvr.Close()
+
+			client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+				Transports:        []transport.Transport{polling.Default},
+				Reconnection:      true,
+				ReconnectionDelay: 10 * time.Millisecond,
+			})
+			must.NoError(err)
+
+			chat := client.Socket("/chat")
+			chat.OnConnect(func(Conn) error {
+				return nil
+			})
+
+			must.NoError(client.Connect())
+			defer client.Close()
+
+			joined := make(chan string, 1)
+			chat.Emit("join", "lobby", func(room string) {
+				joined <- room
+			})
+
+			select {
+			case room := <-joined:
+				should.Equal("lobby", room)
+			case <-time.After(5 * time.Second):
+				t.Fatal("room wasn't joined")
+			}
+			<-connects
+
+			prevID := client.getConn().ID()
+
+			// lose underlying engine.io connection
+			must.NoError(client.getConn().Conn.Close())
+
+			must.Eventually(func() bool {
+				var ids []string
+				server.ForEach("/chat", "lobby", func(c Conn) {
+					ids = append(ids, c.ID())
+				})
+
+				return len(ids) == 1 && ids[0] != prevID
+			}, 5*time.Second, 10*time.Millisecond)
+
+			if test.connects > 1 {
+				should.Contains(<-connects, "lobby")
+			}
+			should.Len(connects, 0)
+		})
+	}
+}
+
+func TestResumeStore(t *testing.T) {
+	should := assert.New(t)
+
+	store := newResumeStore(&ResumeOptions{TTL: time.Minute})
+	other := newResumeStore(nil)
+
+	nc := &namespaceConn{namespace: "/chat"}
+	token := store.issue(nc, 0)
+	should.NotEmpty(nc.resumeID)
+
+	should.False(other.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token}), "token of other secret")
+	should.False(store.restore(&namespaceConn{namespace: "/"}, resumeClaim{token: token}), "token of other namespace")
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token + "x"}), "tampered token")
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: "garbage"}))
+
+	store.forget(nc)
+	should.Empty(store.sessions)
+
+	var nilStore *resumeStore
+	should.Empty(nilStore.issue(nc, 0))
+	should.False(nilStore.restore(nc, resumeClaim{token: token}))
+}
+
+func TestResumeStoreEpoch(t *testing.T) {
+	should := assert.New(t)
+
+	errRejected := errors.New("rejected")
+	var attempts []ResumeAttempt
+	store := newResumeStore(&ResumeOptions{
+		RequireEpoch: true,
+		VerifyResume: func(_ Conn, attempt ResumeAttempt) error {
+			attempts = append(attempts, attempt)
+			if attempt.Auth["user"] != "alice" {
+				return errRejected
+			}
+			return nil
+		},
+	})
+
+	nc := &namespaceConn{namespace: "/chat"}
+	token := store.issue(nc, 3)
+	// previous connection is gone
+	store.sessions[nc.resumeID].conn = nil
+	store.sessions[nc.resumeID].expires = time.Now().Add(time.Minute)
+
+	auth := map[string]interface{}{"user": "alice"}
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, auth: auth}), "missing epoch")
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 3, auth: auth}), "stale epoch")
+	should.Empty(attempts)
+
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 4}), "rejected by hook")
+	should.Len(store.sessions, 1, "rejected token isn't used up")
+
+	should.True(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 4, auth: auth}))
+	should.Empty(store.sessions)
+	should.Equal(ResumeAttempt{
+		Namespace:  "/chat",
+		SessionID:  nc.resumeID,
+		TokenEpoch: 3,
+		Epoch:      4,
+		Auth:       auth,
+	}, attempts[1])
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 5, auth: auth}), "used token")
+
+	// server records epoch of resumed connection with its new token
+	resumed := &namespaceConn{namespace: "/chat"}
+	token = store.issue(resumed, 4)
+	store.sessions[resumed.resumeID].conn = nil
+	store.sessions[resumed.resumeID].expires = time.Now().Add(time.Minute)
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 4, auth: auth}), "replayed epoch")
+	should.True(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 5, auth: auth}))
+
+	lax := newResumeStore(nil)
+	token = lax.issue(nc, 0)
+	lax.sessions[nc.resumeID].conn = nil
+	lax.sessions[nc.resumeID].expires = time.Now().Add(time.Minute)
+	should.True(lax.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token}), "epoch is optional")
+
+	token = lax.issue(nc, 2)
+	lax.sessions[nc.resumeID].conn = nil
+	lax.sessions[nc.resumeID].expires = time.Now().Add(time.Minute)
+	should.False(lax.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token}), "epoch is verified once session has one")
+}
+
+func TestResumeStoreLiveConn(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+	server.OnConnect("/chat", func(Conn) error {
+		return nil
+	})
+	bc := server.getNamespace("/chat").getBroadcast()
+
+	store := newResumeStore(nil)
+
+	prevConn := newConn(&closeRecorderConn{addrEngineConn: addrEngineConn{id: "1"}}, newNamespaceHandlers())
+	prevConn.resume = store
+	prev := newNamespaceConn(prevConn, "/chat", bc)
+	prevConn.namespaces.Set("/chat", prev)
+	prev.Join("lobby")
+	token := store.issue(prev, 0)
+
+	// client reconnects while its previous connection is still alive, e.g. half open transport
+	nc := newNamespaceConn(newConn(addrEngineConn{id: "2"}, newNamespaceHandlers()), "/chat", bc)
+	must.True(store.restore(nc, resumeClaim{token: token}))
+
+	should.True(prev.Closed())
+	should.True(prevConn.Conn.(*closeRecorderConn).closed)
+	should.Contains(nc.Rooms(), "lobby")
+	should.Equal(1, bc.Len("lobby"))
+	should.Empty(store.sessions)
+}
+
+type closeRecorderConn struct {
+	addrEngineConn
+	closed bool
+}
+
+func (c *closeRecorderConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestServerRoomsSurviveUpgrade(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default, websocket.Default},
+	})
+	server.OnConnect("/", func(c Conn) error {
+		c.Join("lobby")
+		return nil
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{Upgrade: true})
+	must.NoError(err)
+
+	received := make(chan string, 1)
+	client.OnEvent("news", func(_ Conn, msg string) {
+		received <- msg
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	must.Eventually(func() bool {
+		c, ok := client.getConn().Conn.(interface{ Transport() string })
+		return ok && c.Transport() == "websocket"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	should.Equal(1, server.RoomLen("/", "lobby"))
+	should.True(server.BroadcastToRoom("/", "lobby", "news", "upgraded"))
+
+	select {
+	case msg := <-received:
+		should.Equal("upgraded", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast wasn't received after upgrade")
+	}
+}
+
+type memoryResumeStorage struct {
+	sessions map[string]ResumeSession
+	mu       sync.Mutex
+}
+
+func (s *memoryResumeStorage) Save(id string, session ResumeSession, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *memoryResumeStorage) Load(id string) (ResumeSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	delete(s.sessions, id)
+	return session, ok, nil
+}
+
+func TestResumeStoreStorageEpoch(t *testing.T) {
+	should := assert.New(t)
+
+	storage := &memoryResumeStorage{sessions: make(map[string]ResumeSession)}
+	store := newResumeStore(&ResumeOptions{Storage: storage})
+
+	nc := &namespaceConn{namespace: "/chat"}
+	token := store.issue(nc, 3)
+	// previous connection is gone on another node
+	delete(store.sessions, nc.resumeID)
+	storage.sessions[nc.resumeID] = ResumeSession{Rooms: []string{}, Epoch: 3}
+
+	should.False(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 3}), "stale epoch")
+	should.Contains(storage.sessions, nc.resumeID, "rejected token isn't used up")
+
+	should.True(store.restore(&namespaceConn{namespace: "/chat"}, resumeClaim{token: token, epoch: 4}))
+	should.Empty(storage.sessions)
+}
+
+func TestServerResumeStorage(t *testing.T) {
+	must := require.New(t)
+
+	storage := &memoryResumeStorage{sessions: make(map[string]ResumeSession)}
+	opts := &ResumeOptions{Secret: []byte("shared secret"), Storage: storage}
+
+	conns := make(chan Conn, 1)
+	servers := make([]*Server, 2)
+	for i := range servers {
+		servers[i] = NewServer(&engineio.Options{
+			Transports: []transport.Transport{polling.Default},
+		})
+		must.NoError(servers[i].EnableResume(opts))
+		servers[i].OnConnect("/", func(c Conn) error {
+			return nil
+		})
+		servers[i].OnConnect("/chat", func(c Conn) error {
+			c.Join("lobby")
+			conns <- c
+			return nil
+		})
+
+		go func(s *Server) {
+			_ = s.Serve()
+		}(servers[i])
+		defer servers[i].Close()
+	}
+
+	// node serves all requests, like sticky load balancer which moves client once its node is gone
+	var node int32
+	httpSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		servers[atomic.LoadInt32(&node)].ServeHTTP(w, r)
+	}))
+	defer httpSvr.Close()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports:        []transport.Transport{polling.Default},
+		Reconnection:      true,
+		ReconnectionDelay: 10 * time.Millisecond,
+	})
+	must.NoError(err)
+
+	chat := client.Socket("/chat")
+	chat.OnConnect(func(Conn) error {
+		return nil
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	var first Conn
+	select {
+	case first = <-conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat wasn't connected")
+	}
+
+	// wait for resume token of chat
+	must.Eventually(func() bool {
+		return client.resumeAuth("/chat", nil) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	first.Join("games")
+	atomic.StoreInt32(&node, 1)
+	must.NoError(first.Close())
+
+	select {
+	case second := <-conns:
+		must.ElementsMatch([]string{second.ID(), "lobby", "games"}, second.Rooms())
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat wasn't resumed on another node")
+	}
+}
@@ -0,0 +1,263 @@
+package socketio
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// RoomEmptyFunc is called with namespace, room and id of its last member once room is left empty.
+type RoomEmptyFunc func(namespace, room, lastConnID string)
+
+// roomEmptyNotifier is implemented by broadcasts which report rooms left empty.
+type roomEmptyNotifier interface {
+	setOnRoomEmpty(f func(room, lastConnID string))
+}
+
+// roomHooker is implemented by broadcasts which keep hooks of rooms, OnRoomEmpty and watchers
+// of RoomTicker, so RebindAdapter hands them to new broadcast.
+type roomHooker interface {
+	roomHooks() (onEmpty func(room, lastConnID string), joins *roomWatchers)
+	setRoomHooks(onEmpty func(room, lastConnID string), joins *roomWatchers)
+}
+
+// moveRoomHooks hands hooks of rooms of old to b before members are moved. Old keeps uncounting
+// members which leave it, but rooms it leaves empty aren't reported since they're moved to b.
+func moveRoomHooks(old, b Broadcast) {
+	from, ok := old.(roomHooker)
+	if !ok {
+		return
+	}
+
+	onEmpty, joins := from.roomHooks()
+	if to, ok := b.(roomHooker); ok {
+		to.setRoomHooks(onEmpty, joins)
+	}
+
+	if onEmpty != nil {
+		from.setRoomHooks(func(string, string) {}, joins)
+	}
+}
+
+// OnRoomEmpty sets f called once the last connection leaves a room of namespace, e.g. to finalize
+// state of a game. With adapter, members of rooms are counted across the cluster and f is called
+// by the node whose connection left last, once for each time room is left empty. Members of node
+// which stops heart-beating are uncounted by another node, which calls f with empty lastConnID.
+// Room which had members when it is cleared is reported with empty lastConnID too. Private rooms
+// of connections, named by their ids, are left out. It must be called after Adapter.
+func (s *Server) OnRoomEmpty(namespace string, f RoomEmptyFunc) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	notifier, ok := h.getBroadcast().(roomEmptyNotifier)
+	if !ok {
+		return nil
+	}
+
+	if f == nil {
+		notifier.setOnRoomEmpty(nil)
+		return nil
+	}
+
+	notifier.setOnRoomEmpty(func(room, lastConnID string) {
+		f(namespace, room, lastConnID)
+	})
+
+	return nil
+}
+
+func (bc *broadcast) setOnRoomEmpty(f func(room, lastConnID string)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onEmpty = f
+}
+
+func (bc *broadcast) roomHooks() (func(room, lastConnID string), *roomWatchers) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.onEmpty, bc.joins
+}
+
+func (bc *broadcast) setRoomHooks(onEmpty func(room, lastConnID string), joins *roomWatchers) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onEmpty, bc.joins = onEmpty, joins
+}
+
+func (bc *broadcast) roomJoined(room, _ string) {
+	_, joins := bc.roomHooks()
+	joins.joined(room)
+}
+
+// roomLeft is called when connection left room, empty tells whether room is left empty.
+func (bc *broadcast) roomLeft(room, connID string, empty bool) {
+	onEmpty, _ := bc.roomHooks()
+	if empty && onEmpty != nil && room != connID {
+		onEmpty(room, connID)
+	}
+}
+
+// joinRoomScript counts member of room on node. Members are counted by node in hash of room, so
+// counts of dead node can be dropped, set of node keeps rooms it counts members of.
+var joinRoomScript = redis.NewScript(2, `
+redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+redis.call("SADD", KEYS[2], ARGV[2])
+return 1
+`)
+
+// leaveRoomScript uncounts member of room on node and gives number of members of room on every
+// node, room of node is forgotten once node has no members in it.
+var leaveRoomScript = redis.NewScript(2, `
+local n = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+if n <= 0 then
+	redis.call("HDEL", KEYS[1], ARGV[1])
+	redis.call("SREM", KEYS[2], ARGV[2])
+end
+local members = 0
+for _, count in ipairs(redis.call("HVALS", KEYS[1])) do
+	members = members + tonumber(count)
+end
+return members
+`)
+
+// forgetNodeScript drops counts of dead node and gives rooms it left empty. Set of node is
+// deleted, so rooms are given to one node only.
+var forgetNodeScript = redis.NewScript(1, `
+local emptied = {}
+for _, room in ipairs(redis.call("SMEMBERS", KEYS[1])) do
+	local key = ARGV[1] .. room
+	if redis.call("HDEL", key, ARGV[2]) == 1 and redis.call("HLEN", key) == 0 then
+		table.insert(emptied, room)
+	end
+end
+redis.call("DEL", KEYS[1])
+return emptied
+`)
+
+func (bc *redisBroadcast) setOnRoomEmpty(f func(room, lastConnID string)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onEmpty = f
+}
+
+func (bc *redisBroadcast) roomHooks() (func(room, lastConnID string), *roomWatchers) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.onEmpty, bc.joins
+}
+
+func (bc *redisBroadcast) setRoomHooks(onEmpty func(room, lastConnID string), joins *roomWatchers) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onEmpty, bc.joins = onEmpty, joins
+}
+
+// membersKey is key of hash of numbers of members of room on nodes.
+func (bc *redisBroadcast) membersKey(room string) string {
+	return bc.membersPrefix() + room
+}
+
+func (bc *redisBroadcast) membersPrefix() string {
+	return fmt.Sprintf("%s-members#%s#", bc.opts.Prefix, bc.nsp)
+}
+
+// nodeRoomsKey is key of set of rooms whose members are counted on node.
+func (bc *redisBroadcast) nodeRoomsKey(nodeID string) string {
+	return fmt.Sprintf("%s-member-rooms#%s#%s", bc.opts.Prefix, bc.nsp, nodeID)
+}
+
+func (bc *redisBroadcast) roomJoined(room, connID string) {
+	onEmpty, joins := bc.roomHooks()
+	joins.joined(room)
+
+	if onEmpty == nil || room == connID {
+		return
+	}
+
+	conn := bc.members.Get()
+	defer conn.Close()
+
+	if _, err := joinRoomScript.Do(conn, bc.membersKey(room), bc.nodeRoomsKey(bc.uid), bc.uid, room); err != nil {
+		logger.Error("count member of room:", err)
+	}
+}
+
+// roomLeft decrements members of room across cluster, the node which decrements the last member
+// calls onEmpty.
+func (bc *redisBroadcast) roomLeft(room, connID string, _ bool) {
+	onEmpty, _ := bc.roomHooks()
+	if onEmpty == nil || room == connID {
+		return
+	}
+
+	conn := bc.members.Get()
+	defer conn.Close()
+
+	members, err := redis.Int(leaveRoomScript.Do(conn, bc.membersKey(room), bc.nodeRoomsKey(bc.uid), bc.uid, room))
+	if err != nil {
+		logger.Error("uncount member of room:", err)
+		return
+	}
+
+	if members <= 0 {
+		onEmpty(room, connID)
+	}
+}
+
+// forgetNode drops counts of members of dead node, rooms it left empty are reported by the node
+// which drops them, without id of last member.
+func (bc *redisBroadcast) forgetNode(nodeID string) {
+	onEmpty, _ := bc.roomHooks()
+	if onEmpty == nil {
+		return
+	}
+
+	conn := bc.members.Get()
+	defer conn.Close()
+
+	emptied, err := redis.Strings(forgetNodeScript.Do(conn, bc.nodeRoomsKey(nodeID), bc.membersPrefix(), nodeID))
+	if err != nil {
+		logger.Error("uncount members of dead node:", err)
+		return
+	}
+
+	for _, room := range emptied {
+		onEmpty(room, "")
+	}
+}
+
+// forgetMembers drops count of members of room which is cleared, room which had members is
+// reported by the node which clears it.
+func (bc *redisBroadcast) forgetMembers(room string) {
+	onEmpty, _ := bc.roomHooks()
+	if onEmpty == nil {
+		return
+	}
+
+	conn := bc.members.Get()
+	defer conn.Close()
+
+	deleted, err := redis.Int(conn.Do("DEL", bc.membersKey(room)))
+	if err != nil {
+		logger.Error("clear members of room:", err)
+		return
+	}
+
+	if deleted > 0 {
+		onEmpty(room, "")
+	}
+}
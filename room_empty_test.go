@@ -0,0 +1,129 @@
+package socketio
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerOnRoomEmpty(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+
+	type emptied struct {
+		namespace, room, lastConnID string
+	}
+	var calls []emptied
+	should.NoError(server.OnRoomEmpty("/game", func(namespace, room, lastConnID string) {
+		calls = append(calls, emptied{namespace, room, lastConnID})
+	}))
+
+	bc := server.getNamespace("/game").broadcast
+	a := newNamespaceConn(&conn{Conn: addrEngineConn{id: "a"}}, "/game", bc)
+	b := newNamespaceConn(&conn{Conn: addrEngineConn{id: "b"}}, "/game", bc)
+
+	for _, nc := range []*namespaceConn{a, b} {
+		nc.Join(nc.ID())
+		nc.Join("table:1")
+		nc.Join("table:1")
+	}
+	a.Join("lobby")
+
+	a.Leave("table:1")
+	should.Empty(calls, "room has members")
+
+	b.Leave("table:1")
+	b.Leave("table:1")
+	should.Equal([]emptied{{"/game", "table:1", "b"}}, calls)
+
+	b.Join("lobby")
+	a.LeaveAll()
+	should.Len(calls, 1)
+
+	b.LeaveAll()
+	should.Equal([]emptied{{"/game", "table:1", "b"}, {"/game", "lobby", "b"}}, calls,
+		"private room of connection is left out")
+
+	a.Join("cleared")
+	b.Join("cleared")
+	bc.Clear("cleared")
+	should.Equal(emptied{"/game", "cleared", ""}, calls[len(calls)-1], "cleared room is reported once")
+	should.Len(calls, 3)
+
+	bc.Clear("cleared")
+	bc.Clear("missing")
+	should.Len(calls, 3, "room without members isn't reported")
+}
+
+func TestRedisBroadcastRoomEmpty(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var (
+		mu      sync.Mutex
+		evals   [][]string
+		members = 1
+	)
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "EVALSHA":
+			return "-NOSCRIPT No matching script\r\n"
+		case "EVAL":
+			mu.Lock()
+			defer mu.Unlock()
+
+			evals = append(evals, cmd[2:])
+			switch {
+			case strings.Contains(cmd[1], "HVALS"):
+				return ":" + strconv.Itoa(members) + "\r\n"
+			case strings.Contains(cmd[1], "SMEMBERS"):
+				return respArray("table:2")
+			}
+		}
+		return ":1\r\n"
+	})
+
+	bc, err := newRedisBroadcast("/game", getOptions(&RedisAdapterOptions{Addr: addr, Prefix: "app", NodeID: "node1"}))
+	must.NoError(err)
+	defer bc.Close()
+
+	var emptied []string
+	bc.setOnRoomEmpty(func(room, lastConnID string) {
+		emptied = append(emptied, room+" "+lastConnID)
+	})
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "a"}}, "/game", bc)
+	nc.Join("a")
+	nc.Join("table:1")
+	nc.Leave("table:1")
+	should.Empty(emptied, "room has members on other node")
+
+	mu.Lock()
+	members = 0
+	mu.Unlock()
+	nc.Join("table:1")
+	nc.Leave("table:1")
+	should.Equal([]string{"table:1 a"}, emptied)
+
+	bc.onNodeDead("node2")
+	should.Equal([]string{"table:1 a", "table:2 "}, emptied, "room left empty by dead node is reported")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	must.Len(evals, 5, "private room isn't counted")
+	should.Equal([]string{"2", "app-members#/game#table:1", "app-member-rooms#/game#node1", "node1", "table:1"}, evals[0])
+	should.Equal([]string{"2", "app-members#/game#table:1", "app-member-rooms#/game#node1", "node1", "table:1"}, evals[1])
+	should.Equal([]string{"1", "app-member-rooms#/game#node2", "app-members#/game#", "node2"}, evals[4])
+}
@@ -0,0 +1,428 @@
+package socketio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+const defaultLeaderLease = 10 * time.Second
+
+// RoomLeaderOptions configures election of authority of room, see Server.ElectRoomLeader.
+type RoomLeaderOptions struct {
+	// Candidate is identity which campaigns for the room, default is id of server node followed
+	// by id of campaign, so campaigns of one node for the same room don't share leadership.
+	// Use id of a connection to elect one connection of room instead of a node.
+	Candidate string
+
+	// Lease is how long leadership is held without renewal, default is 10 seconds.
+	// Leader renews it and other candidates try to take it over every third of lease,
+	// so failover happens at most a lease after leader is gone.
+	Lease time.Duration
+
+	// OnElected is called when candidate becomes leader of room.
+	OnElected func(namespace, room string)
+
+	// OnRevoked is called when candidate loses leadership of room, because lease couldn't be
+	// renewed or candidate resigned.
+	OnRevoked func(namespace, room string)
+}
+
+func (o *RoomLeaderOptions) getCandidate(nodeID string) string {
+	if o == nil || o.Candidate == "" {
+		return nodeID + "#" + newV4UUID()
+	}
+
+	return o.Candidate
+}
+
+func (o *RoomLeaderOptions) getLease() time.Duration {
+	if o == nil || o.Lease <= 0 {
+		return defaultLeaderLease
+	}
+
+	return o.Lease
+}
+
+func (o *RoomLeaderOptions) getOnElected() func(namespace, room string) {
+	if o == nil {
+		return nil
+	}
+
+	return o.OnElected
+}
+
+func (o *RoomLeaderOptions) getOnRevoked() func(namespace, room string) {
+	if o == nil {
+		return nil
+	}
+
+	return o.OnRevoked
+}
+
+// leaseStore keeps leases of leadership, each held by one candidate until it expires.
+type leaseStore interface {
+	// acquire takes lease for candidate if it's free, it tells whether candidate holds it.
+	acquire(key, candidate string, ttl time.Duration) (bool, error)
+	// renew extends lease held by candidate, it tells false when candidate doesn't hold it.
+	renew(key, candidate string, ttl time.Duration) (bool, error)
+	// release frees lease if candidate holds it.
+	release(key, candidate string) error
+	// holder gives candidate which holds lease, empty when it's free.
+	holder(key string) (string, error)
+}
+
+// RoomLeader campaigns for leadership of a room until it resigns.
+type RoomLeader struct {
+	namespace string
+	room      string
+	candidate string
+	key       string
+	lease     time.Duration
+	store     leaseStore
+
+	onElected func(namespace, room string)
+	onRevoked func(namespace, room string)
+
+	mu     sync.Mutex
+	leader bool
+
+	// campaigns is left once campaign ends, server resigns campaigns which are left when closed.
+	campaigns *stopGroup
+
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// ElectRoomLeader starts campaign of candidate for leadership of room of namespace, e.g. to run
+// authoritative game loop of room on one node. With adapter, leadership is a lease in redis
+// shared by the cluster, else it's shared by campaigns of this server. Leader renews its lease
+// while it campaigns, once it's gone another candidate takes over and its OnElected is called.
+// Campaign ends by Resign or when server is closed.
+func (s *Server) ElectRoomLeader(namespace, room string, opts *RoomLeaderOptions) *RoomLeader {
+	l := &RoomLeader{
+		namespace: namespace,
+		room:      room,
+		candidate: opts.getCandidate(s.nodeID),
+		key:       fmt.Sprintf("%s#%s", namespace, room),
+		lease:     opts.getLease(),
+		store:     s.leaseStore(),
+		onElected: opts.getOnElected(),
+		onRevoked: opts.getOnRevoked(),
+		campaigns: &s.campaigns,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	s.campaigns.add(l, l.Resign)
+
+	go l.campaign()
+
+	return l
+}
+
+// leaseStore gives leases of adapter, its connections are shared by campaigns of server.
+func (s *Server) leaseStore() leaseStore {
+	opts := s.getRedisAdapter()
+	if opts == nil {
+		return s.leases
+	}
+
+	s.redisLeasesOnce.Do(func() {
+		s.redisLeases = newRedisLeaseStore(opts)
+	})
+
+	return s.redisLeases
+}
+
+// closeLeases closes connections of leases of adapter, once campaigns are resigned.
+func (s *Server) closeLeases() {
+	s.redisLeasesOnce.Do(func() {})
+
+	if s.redisLeases != nil {
+		_ = s.redisLeases.pool.Close()
+	}
+}
+
+// stopGroup stops its members which are still running once server is closed, so they don't
+// pile up as shutdown hooks.
+type stopGroup struct {
+	mu      sync.Mutex
+	members map[interface{}]func()
+}
+
+func (g *stopGroup) add(member interface{}, stop func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.members == nil {
+		g.members = make(map[interface{}]func())
+	}
+	g.members[member] = stop
+}
+
+func (g *stopGroup) remove(member interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.members, member)
+}
+
+// stop stops members, they may remove themselves meanwhile.
+func (g *stopGroup) stop() {
+	g.mu.Lock()
+	stops := make([]func(), 0, len(g.members))
+	for _, stop := range g.members {
+		stops = append(stops, stop)
+	}
+	g.mu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+}
+
+// Candidate gives identity which campaigns for the room.
+func (l *RoomLeader) Candidate() string {
+	return l.candidate
+}
+
+// IsLeader tells whether candidate is leader of room.
+func (l *RoomLeader) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.leader
+}
+
+// Leader gives candidate which is leader of room, empty when there is none.
+func (l *RoomLeader) Leader() (string, error) {
+	return l.store.holder(l.key)
+}
+
+// Resign ends campaign and releases leadership, so another candidate may take over.
+// OnRevoked is called if candidate was leader. It must not be called from OnElected or OnRevoked.
+func (l *RoomLeader) Resign() {
+	l.once.Do(func() {
+		close(l.quit)
+	})
+
+	<-l.done
+	l.campaigns.remove(l)
+}
+
+func (l *RoomLeader) campaign() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		l.tick()
+
+		select {
+		case <-l.quit:
+			if l.IsLeader() {
+				if err := l.store.release(l.key, l.candidate); err != nil {
+					logger.Error("release leadership of room:", err)
+				}
+				l.setLeader(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick renews lease of leader or tries to acquire it otherwise.
+func (l *RoomLeader) tick() {
+	if l.IsLeader() {
+		held, err := l.store.renew(l.key, l.candidate, l.lease)
+		if err != nil {
+			logger.Error("renew leadership of room:", err)
+		}
+		// lease may still be held on error, but it can't be told, so leader steps down
+		// rather than risk two leaders
+		if !held || err != nil {
+			l.setLeader(false)
+		}
+		return
+	}
+
+	held, err := l.store.acquire(l.key, l.candidate, l.lease)
+	if err != nil {
+		logger.Error("acquire leadership of room:", err)
+		return
+	}
+	if held {
+		l.setLeader(true)
+	}
+}
+
+func (l *RoomLeader) setLeader(leader bool) {
+	l.mu.Lock()
+	l.leader = leader
+	l.mu.Unlock()
+
+	if leader && l.onElected != nil {
+		l.onElected(l.namespace, l.room)
+	}
+	if !leader && l.onRevoked != nil {
+		l.onRevoked(l.namespace, l.room)
+	}
+}
+
+type localLease struct {
+	candidate string
+	expires   time.Time
+}
+
+// localLeaseStore keeps leases in memory of server.
+type localLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]localLease
+}
+
+func newLocalLeaseStore() *localLeaseStore {
+	return &localLeaseStore{leases: make(map[string]localLease)}
+}
+
+func (s *localLeaseStore) acquire(key, candidate string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if lease, ok := s.leases[key]; ok && lease.candidate != candidate && now.Before(lease.expires) {
+		return false, nil
+	}
+
+	s.leases[key] = localLease{candidate: candidate, expires: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (s *localLeaseStore) renew(key, candidate string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	lease, ok := s.leases[key]
+	if !ok || lease.candidate != candidate || !now.Before(lease.expires) {
+		return false, nil
+	}
+
+	s.leases[key] = localLease{candidate: candidate, expires: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (s *localLeaseStore) release(key, candidate string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lease, ok := s.leases[key]; ok && lease.candidate == candidate {
+		delete(s.leases, key)
+	}
+
+	return nil
+}
+
+func (s *localLeaseStore) holder(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lease, ok := s.leases[key]
+	if !ok || !time.Now().Before(lease.expires) {
+		return "", nil
+	}
+
+	return lease.candidate, nil
+}
+
+// renewLeaseScript extends lease if it's held by candidate.
+var renewLeaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript removes lease if it's held by candidate.
+var releaseLeaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// redisLeaseStore keeps leases in redis of adapter, so they're shared by the cluster.
+type redisLeaseStore struct {
+	opts *RedisAdapterOptions
+	pool *redis.Pool
+}
+
+func newRedisLeaseStore(opts *RedisAdapterOptions) *redisLeaseStore {
+	return &redisLeaseStore{opts: opts, pool: opts.newPool()}
+}
+
+func (s *redisLeaseStore) leaseKey(key string) string {
+	return fmt.Sprintf("%s-leader#%s", s.opts.Prefix, key)
+}
+
+func (s *redisLeaseStore) acquire(key, candidate string, ttl time.Duration) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", s.leaseKey(key), candidate, "NX", "PX", ttl.Milliseconds()))
+	if err == redis.ErrNil {
+		// lease is taken, candidate may hold it already
+		return s.renewConn(conn, key, candidate, ttl)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *redisLeaseStore) renew(key, candidate string, ttl time.Duration) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return s.renewConn(conn, key, candidate, ttl)
+}
+
+func (s *redisLeaseStore) renewConn(conn redis.Conn, key, candidate string, ttl time.Duration) (bool, error) {
+	renewed, err := redis.Int(renewLeaseScript.Do(conn, s.leaseKey(key), candidate, ttl.Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+
+	return renewed == 1, nil
+}
+
+func (s *redisLeaseStore) release(key, candidate string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := releaseLeaseScript.Do(conn, s.leaseKey(key), candidate)
+
+	return err
+}
+
+func (s *redisLeaseStore) holder(key string) (string, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	candidate, err := redis.String(conn.Do("GET", s.leaseKey(key)))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+
+	return candidate, err
+}
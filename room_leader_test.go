@@ -0,0 +1,150 @@
+package socketio
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerElectRoomLeader(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(nil)
+
+	events := make(chan string, 8)
+	campaign := func(candidate string) *RoomLeader {
+		return server.ElectRoomLeader("/game", "table:1", &RoomLeaderOptions{
+			Candidate: candidate,
+			Lease:     60 * time.Millisecond,
+			OnElected: func(namespace, room string) {
+				events <- "elected " + candidate + " " + namespace + " " + room
+			},
+			OnRevoked: func(string, string) {
+				events <- "revoked " + candidate
+			},
+		})
+	}
+	next := func() string {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("leader wasn't elected")
+			return ""
+		}
+	}
+
+	a := campaign("a")
+	must.Equal("elected a /game table:1", next())
+	b := campaign("b")
+
+	time.Sleep(100 * time.Millisecond)
+	should.True(a.IsLeader())
+	should.False(b.IsLeader(), "lease is renewed by leader")
+	leader, err := b.Leader()
+	must.NoError(err)
+	should.Equal("a", leader)
+
+	a.Resign()
+	should.Equal("revoked a", next())
+	should.Equal("elected b /game table:1", next(), "candidate takes over")
+	should.False(a.IsLeader())
+
+	should.NoError(server.Close())
+	should.Equal("revoked b", next(), "campaign ends with server")
+	leader, err = b.Leader()
+	must.NoError(err)
+	should.Empty(leader)
+}
+
+func TestServerElectRoomLeaderCampaigns(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+	defer server.Close()
+
+	a := server.ElectRoomLeader("/game", "table:1", &RoomLeaderOptions{Lease: time.Minute})
+	b := server.ElectRoomLeader("/game", "table:1", &RoomLeaderOptions{Lease: time.Minute})
+
+	should.NotEqual(a.Candidate(), b.Candidate(), "campaigns of node have own candidates")
+	should.True(strings.HasPrefix(a.Candidate(), server.NodeID()+"#"))
+
+	should.Eventually(func() bool {
+		return a.IsLeader() != b.IsLeader()
+	}, 5*time.Second, 10*time.Millisecond, "one campaign of node leads")
+
+	a.Resign()
+	b.Resign()
+
+	server.campaigns.mu.Lock()
+	defer server.campaigns.mu.Unlock()
+
+	should.Empty(server.campaigns.members, "resigned campaign isn't stopped by server")
+}
+
+func TestRedisLeaseStore(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var mu sync.Mutex
+	holder := ""
+	addr := fakeRedis(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch cmd[0] {
+		case "SET":
+			if holder != "" {
+				return "$-1\r\n"
+			}
+			holder = cmd[2]
+			return "+OK\r\n"
+		case "EVALSHA":
+			if holder == cmd[4] {
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		case "GET":
+			return "$" + strconv.Itoa(len(holder)) + "\r\n" + holder + "\r\n"
+		}
+		return ":1\r\n"
+	})
+
+	var dials int32
+	store := newRedisLeaseStore(getOptions(&RedisAdapterOptions{
+		Addr:   addr,
+		Prefix: "app",
+		Dial: func(network, addr string) (redis.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return redis.Dial(network, addr)
+		},
+	}))
+	defer store.pool.Close()
+
+	for i := 0; i < 5; i++ {
+		held, err := store.acquire("/game#table:1", "a", time.Minute)
+		must.NoError(err)
+		should.True(held)
+
+		held, err = store.renew("/game#table:1", "a", time.Minute)
+		must.NoError(err)
+		should.True(held)
+	}
+
+	held, err := store.acquire("/game#table:1", "b", time.Minute)
+	must.NoError(err)
+	should.False(held)
+
+	leader, err := store.holder("/game#table:1")
+	must.NoError(err)
+	should.Equal("a", leader)
+	should.Equal(int32(1), atomic.LoadInt32(&dials), "connection is reused by renewals")
+}
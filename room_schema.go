@@ -0,0 +1,110 @@
+package socketio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrRoomSchema is returned by EmitToRoom when event doesn't match schema of room type.
+var ErrRoomSchema = errors.New("event doesn't match room schema")
+
+// EventValidator checks arguments of event emitted into a room.
+type EventValidator func(args []interface{}) error
+
+// RoomSchema is set of events expected in rooms of a type, see Server.SetRoomSchema.
+type RoomSchema struct {
+	// Events maps expected events to validators of their arguments,
+	// nil validator accepts any arguments.
+	Events map[string]EventValidator
+}
+
+func (rs *RoomSchema) validate(event string, args []interface{}) error {
+	validator, ok := rs.Events[event]
+	if !ok {
+		return fmt.Errorf("%w: unexpected event %q", ErrRoomSchema, event)
+	}
+
+	if validator == nil {
+		return nil
+	}
+
+	if err := validator(args); err != nil {
+		return fmt.Errorf("%w: event %q: %s", ErrRoomSchema, event, err)
+	}
+
+	return nil
+}
+
+// ExpectArgs returns validator of arguments with the same count and types as examples,
+// e.g. ExpectArgs("", 0) accepts a string and an int.
+func ExpectArgs(examples ...interface{}) EventValidator {
+	types := make([]reflect.Type, len(examples))
+	for i := range examples {
+		types[i] = reflect.TypeOf(examples[i])
+	}
+
+	return func(args []interface{}) error {
+		if len(args) != len(types) {
+			return fmt.Errorf("expected %d arguments, got %d", len(types), len(args))
+		}
+
+		for i, arg := range args {
+			if got := reflect.TypeOf(arg); got != types[i] {
+				return fmt.Errorf("expected argument %d of type %v, got %v", i, types[i], got)
+			}
+		}
+
+		return nil
+	}
+}
+
+// roomType gives type of room, which is name of its last level up to ':',
+// e.g. "channel" for "org:42/project:7/channel:general". Room without ':' has no type.
+func roomType(room string) string {
+	if i := strings.LastIndex(room, RoomSeparator); i >= 0 {
+		room = room[i+len(RoomSeparator):]
+	}
+
+	typ, _, ok := strings.Cut(room, ":")
+	if !ok {
+		return ""
+	}
+
+	return typ
+}
+
+// SetRoomSchema sets schema of events emitted into rooms of roomType in namespace, e.g. roomType
+// "chat" applies to rooms like "chat:general". Events which don't match the schema are rejected
+// by EmitToRoom and BroadcastToRoom. Nil schema removes schema of room type.
+func (s *Server) SetRoomSchema(namespace, roomType string, schema *RoomSchema) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.SetRoomSchema(roomType, schema)
+}
+
+// EmitToRoom broadcasts given event & args to all the connections in the room, like BroadcastToRoom,
+// but it returns error when namespace doesn't exist, event doesn't match schema of the room or
+// broadcast to the room is rate limited, see LimitRoomBroadcasts.
+func (s *Server) EmitToRoom(namespace, room, event string, args ...interface{}) error {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return fmt.Errorf("namespace %q doesn't exist", namespace)
+	}
+
+	if err := nspHandler.validateRoomEvent(room, event, args); err != nil {
+		return err
+	}
+
+	if err := nspHandler.allowRoomBroadcast(room); err != nil {
+		return err
+	}
+
+	s.sendBroadcast(nspHandler, namespace, room, nil, event, args)
+
+	return nil
+}
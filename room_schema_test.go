@@ -0,0 +1,59 @@
+package socketio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+type emitRecorder struct {
+	*namespaceConn
+	events []string
+}
+
+func (r *emitRecorder) Emit(event string, _ ...interface{}) {
+	r.events = append(r.events, event)
+}
+
+func TestServerRoomSchema(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.SetRoomSchema("/", "chat", &RoomSchema{
+		Events: map[string]EventValidator{
+			"message": ExpectArgs("", 0),
+			"typing":  nil,
+		},
+	})
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", nil)}
+	for _, room := range []string{"chat:general", "org:42/chat:general", "lobby"} {
+		server.JoinRoom("/", room, recorder)
+	}
+
+	should.NoError(server.EmitToRoom("/", "chat:general", "message", "hello", 1))
+	should.NoError(server.EmitToRoom("/", "chat:general", "typing", true))
+	should.NoError(server.EmitToRoom("/", "lobby", "anything"))
+
+	should.ErrorIs(server.EmitToRoom("/", "chat:general", "presence"), ErrRoomSchema)
+	should.ErrorIs(server.EmitToRoom("/", "chat:general", "message", "hello"), ErrRoomSchema)
+	should.ErrorIs(server.EmitToRoom("/", "org:42/chat:general", "message", 1, "hello"), ErrRoomSchema)
+	should.False(server.BroadcastToRoom("/", "chat:general", "presence"))
+	should.Error(server.EmitToRoom("/missing", "chat:general", "message", "hello", 1))
+
+	should.Equal([]string{"message", "typing", "anything"}, recorder.events)
+
+	server.SetRoomSchema("/", "chat", nil)
+	should.True(server.BroadcastToRoom("/", "chat:general", "presence"))
+}
+
+func TestRoomType(t *testing.T) {
+	should := assert.New(t)
+
+	should.Equal("chat", roomType("chat:general"))
+	should.Equal("channel", roomType("org:42/project:7/channel:general"))
+	should.Equal("", roomType("lobby"))
+	should.Equal("", roomType("org:42/lobby"))
+}
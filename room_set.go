@@ -0,0 +1,131 @@
+package socketio
+
+import "encoding/json"
+
+// room set operations
+const (
+	roomSetUnion     = "union"
+	roomSetIntersect = "intersect"
+	roomSetExcept    = "except"
+	roomSetUnionTree = "union_tree"
+)
+
+type roomSetOp struct {
+	Op    string
+	Rooms []string
+}
+
+// RoomSet selects connections by set operations over rooms, e.g.
+// Union("a", "b").Intersect("premium").Except("muted").
+// Operations are applied from left to right, each connection is selected at most once.
+type RoomSet struct {
+	ops []roomSetOp
+}
+
+// Union creates a room set with connections of all the given rooms.
+func Union(rooms ...string) *RoomSet {
+	return (&RoomSet{}).Union(rooms...)
+}
+
+// Union adds connections of all the given rooms to the set.
+func (rs *RoomSet) Union(rooms ...string) *RoomSet {
+	return rs.add(roomSetUnion, rooms)
+}
+
+// Intersect keeps only connections which joined at least one of the given rooms.
+func (rs *RoomSet) Intersect(rooms ...string) *RoomSet {
+	return rs.add(roomSetIntersect, rooms)
+}
+
+// Except removes connections which joined any of the given rooms.
+func (rs *RoomSet) Except(rooms ...string) *RoomSet {
+	return rs.add(roomSetExcept, rooms)
+}
+
+// MarshalJSON encodes the room set operations, used to forward set across adapter nodes.
+func (rs *RoomSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rs.ops)
+}
+
+// UnmarshalJSON decodes room set operations.
+func (rs *RoomSet) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &rs.ops)
+}
+
+func (rs *RoomSet) add(op string, rooms []string) *RoomSet {
+	rs.ops = append(rs.ops, roomSetOp{Op: op, Rooms: rooms})
+	return rs
+}
+
+// eval evaluates the set against room membership index and tree of hierarchical rooms,
+// both must be locked by caller.
+func (rs *RoomSet) eval(rooms map[string]map[string]Conn, tree roomTree) map[string]Conn {
+	selected := make(map[string]Conn)
+	if rs == nil {
+		return selected
+	}
+
+	for _, op := range rs.ops {
+		switch op.Op {
+		case roomSetUnion:
+			for _, room := range op.Rooms {
+				for id, connection := range rooms[room] {
+					selected[id] = connection
+				}
+			}
+
+		case roomSetUnionTree:
+			for _, room := range op.Rooms {
+				for id, connection := range rooms[room] {
+					selected[id] = connection
+				}
+
+				for child := range tree[room] {
+					for id, connection := range rooms[child] {
+						selected[id] = connection
+					}
+				}
+			}
+
+		case roomSetIntersect:
+			for id := range selected {
+				if !inAnyRoom(rooms, op.Rooms, id) {
+					delete(selected, id)
+				}
+			}
+
+		case roomSetExcept:
+			for _, room := range op.Rooms {
+				for id := range rooms[room] {
+					delete(selected, id)
+				}
+			}
+		}
+	}
+
+	return selected
+}
+
+func inAnyRoom(rooms map[string]map[string]Conn, names []string, id string) bool {
+	for _, room := range names {
+		if _, ok := rooms[room][id]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func roomSetFromOpt(opt interface{}) (*RoomSet, bool) {
+	data, err := json.Marshal(opt)
+	if err != nil {
+		return nil, false
+	}
+
+	var rs RoomSet
+	if err = json.Unmarshal(data, &rs); err != nil {
+		return nil, false
+	}
+
+	return &rs, true
+}
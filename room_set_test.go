@@ -0,0 +1,181 @@
+package socketio
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomSetEval(t *testing.T) {
+	rooms := map[string]map[string]Conn{
+		"a":       {"1": nil, "2": nil},
+		"b":       {"3": nil, "4": nil},
+		"premium": {"1": nil, "3": nil, "4": nil, "5": nil},
+		"muted":   {"4": nil},
+	}
+
+	tests := []struct {
+		name string
+		set  *RoomSet
+		ids  []string
+	}{
+		{"nil", nil, []string{}},
+		{"union", Union("a", "b"), []string{"1", "2", "3", "4"}},
+		{"intersect", Union("a", "b").Intersect("premium"), []string{"1", "3", "4"}},
+		{"except", Union("a", "b").Intersect("premium").Except("muted"), []string{"1", "3"}},
+		{"unknown room", Union("c").Union("a"), []string{"1", "2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids := make([]string, 0)
+			for id := range test.set.eval(rooms, nil) {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+
+			assert.Equal(t, test.ids, ids)
+		})
+	}
+}
+
+func TestRoomSetJSON(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	set := Union("a", "b").Except("muted")
+
+	data, err := json.Marshal(set)
+	must.NoError(err)
+
+	var opt interface{}
+	must.NoError(json.Unmarshal(data, &opt))
+
+	decoded, ok := roomSetFromOpt(opt)
+	must.True(ok)
+	should.Equal(set.ops, decoded.ops)
+}
+
+func TestRoomSetUnionTree(t *testing.T) {
+	should := assert.New(t)
+
+	bc := newBroadcast()
+
+	join := func(id string, rooms ...string) {
+		c := newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/", bc)
+		for _, room := range rooms {
+			bc.Join(room, c)
+		}
+	}
+	join("1", "org:42")
+	join("2", "org:42/project:7")
+	join("3", "org:42/project:7/channel:general", "muted")
+	join("4", "org:42/project:8/channel:general")
+	join("5", "org:43/project:7")
+	join("6", "org:420")
+
+	eval := func(set *RoomSet) []string {
+		bc.lock.RLock()
+		defer bc.lock.RUnlock()
+
+		ids := make([]string, 0)
+		for id := range set.eval(bc.rooms, bc.tree) {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		return ids
+	}
+
+	should.Equal([]string{"1", "2", "3", "4"}, eval(UnionTree("org:42")))
+	should.Equal([]string{"2", "3"}, eval(UnionTree("org:42/project:7")))
+	should.Equal([]string{"1", "2", "4"}, eval(UnionTree("org:42").Except("muted")))
+	should.Equal([]string{"1"}, eval(Union("org:42")))
+
+	// index is updated once child rooms are gone
+	bc.Clear("org:42/project:8/channel:general")
+	bc.LeaveAll(newNamespaceConn(&conn{Conn: addrEngineConn{id: "3"}}, "/", bc))
+	should.Equal([]string{"1", "2"}, eval(UnionTree("org:42")))
+	should.Equal(roomTree{
+		"org:42": {"org:42/project:7": {}},
+		"org:43": {"org:43/project:7": {}},
+	}, bc.tree)
+}
+
+func TestRoomAncestors(t *testing.T) {
+	should := assert.New(t)
+
+	should.Equal([]string{"a", "a/b"}, roomAncestors("a/b/c"))
+	should.Nil(roomAncestors("a"))
+	should.Nil(roomAncestors("/a"))
+}
+
+func TestServerBroadcastToRoomExcept(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+	server.SetRoomSchema("/", "chat", &RoomSchema{
+		Events: map[string]EventValidator{
+			"message": nil,
+		},
+	})
+
+	var recorders []*emitRecorder
+	for _, id := range []string{"1", "2", "3"} {
+		recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/", nil)}
+		server.JoinRoom("/", id, recorder)
+		server.JoinRoom("/", "chat:general", recorder)
+		recorders = append(recorders, recorder)
+	}
+
+	should.True(server.BroadcastToRoomExcept("/", "chat:general", "message", []string{"1"}, "hi"))
+	should.True(server.BroadcastToRoomExcept("/", "chat:general", "message", []string{"1", "3", "missing"}))
+	should.True(server.BroadcastToRoomExcept("/", "chat:general", "message", nil))
+
+	should.False(server.BroadcastToRoomExcept("/", "chat:general", "presence", []string{"1"}))
+	should.False(server.BroadcastToRoomExcept("/missing", "chat:general", "message", []string{"1"}))
+
+	should.Equal([]string{"message"}, recorders[0].events)
+	should.Equal([]string{"message", "message", "message"}, recorders[1].events)
+	should.Equal([]string{"message", "message"}, recorders[2].events)
+}
+
+// plainBroadcast is custom broadcast which doesn't select connections by room sets.
+type plainBroadcast struct {
+	Broadcast
+}
+
+func TestServerRoomSetUnsupported(t *testing.T) {
+	should := assert.New(t)
+
+	var _ RoomSetBroadcast = newBroadcast()
+	var _ RoomSetBroadcast = &redisBroadcast{}
+	var _ RoomSetBroadcast = &busBroadcast{}
+	var _ RoomSetBroadcast = &meshBroadcast{}
+
+	server := NewServer(nil)
+	should.NoError(server.SetBroadcaster("/", plainBroadcast{newBroadcast()}))
+
+	recorder := &emitRecorder{namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: "1"}}, "/", nil)}
+	server.JoinRoom("/", "chat", recorder)
+
+	should.False(server.BroadcastToRoomSet("/", Union("chat"), "message"))
+	should.False(server.BroadcastWithOptions("/", Union("chat"), &BroadcastOptions{}, "message"))
+	should.False(server.ForEachRoomSet("/", Union("chat"), func(Conn) {
+		t.Error("connection is selected")
+	}))
+	should.ErrorIs(server.WithContext(context.Background()).BroadcastToRoomSet("/", Union("chat"), "message"), ErrRoomSetUnsupported)
+
+	// rooms are still reached by broadcasts of Broadcast
+	should.True(server.BroadcastToRoom("/", "chat", "message"))
+	should.Equal([]string{"message"}, recorder.events)
+
+	// connections are left out of room by their ids
+	should.True(server.BroadcastToRoomExcept("/", "chat", "typing", []string{"2"}))
+	should.True(server.BroadcastToRoomExcept("/", "chat", "typing", []string{"1"}))
+	should.Equal([]string{"message", "typing"}, recorder.events)
+}
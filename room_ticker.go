@@ -0,0 +1,220 @@
+package socketio
+
+import (
+	"sync"
+	"time"
+)
+
+// roomTickerPrefix separates leadership of tickers from leadership of rooms they tick.
+const roomTickerPrefix = "$ticker#"
+
+// RoomTickFunc is called with namespace and room on each tick of RoomTicker.
+type RoomTickFunc func(namespace, room string)
+
+// localRoomCounter is implemented by broadcasts which count members of room on this node.
+type localRoomCounter interface {
+	localLen(room string) int
+}
+
+// roomJoinWatcher is implemented by broadcasts which wake watchers of rooms on join.
+type roomJoinWatcher interface {
+	watchJoins(room string) (joined <-chan struct{}, unwatch func())
+}
+
+// RoomTicker calls a function on interval while its room isn't empty.
+type RoomTicker struct {
+	namespace string
+	room      string
+	interval  time.Duration
+	fn        RoomTickFunc
+
+	// h gives current broadcast of namespace, which RebindAdapter replaces.
+	h *namespaceHandler
+
+	// leader is set with adapter, so only one node of cluster ticks.
+	leader *RoomLeader
+
+	// campaigns is left once ticker stops, server stops tickers which are left when closed.
+	campaigns *stopGroup
+
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// RoomTicker calls fn every interval while room of namespace has members, e.g. to run game loop
+// of room. Ticker pauses once room is empty and resumes when a connection joins it. With adapter,
+// fn is called by one node, elected as leader of ticker. Members on this node are counted on each
+// tick and the cluster is asked only when room has none on this node, so leader polls the room
+// across the cluster while it's empty. It ticks until Stop or until server is closed.
+func (s *Server) RoomTicker(namespace, room string, interval time.Duration, fn RoomTickFunc) *RoomTicker {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	t := &RoomTicker{
+		namespace: namespace,
+		room:      room,
+		interval:  interval,
+		fn:        fn,
+		h:         h,
+		campaigns: &s.campaigns,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if s.getRedisAdapter() != nil {
+		t.leader = s.ElectRoomLeader(namespace, roomTickerPrefix+room, nil)
+	}
+
+	s.campaigns.add(t, t.Stop)
+
+	go t.run()
+
+	return t
+}
+
+// Stop stops ticker and waits for fn in flight, so it must not be called from fn.
+func (t *RoomTicker) Stop() {
+	t.once.Do(func() {
+		close(t.quit)
+	})
+
+	<-t.done
+
+	if t.leader != nil {
+		t.leader.Resign()
+	}
+	t.campaigns.remove(t)
+}
+
+func (t *RoomTicker) run() {
+	defer close(t.done)
+
+	var joined <-chan struct{}
+	// watchers of joins are handed to broadcast which replaces this one
+	if watcher, ok := t.h.getBroadcast().(roomJoinWatcher); ok {
+		var unwatch func()
+		joined, unwatch = watcher.watchJoins(t.room)
+		defer unwatch()
+	}
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	paused := false
+	for {
+		tick := ticker.C
+		// local room may be joined only on this node, so paused ticker waits for join
+		if paused && t.leader == nil && joined != nil {
+			tick = nil
+		}
+
+		select {
+		case <-t.quit:
+			return
+		case <-tick:
+		case <-joined:
+			if !paused {
+				continue
+			}
+			ticker.Reset(t.interval)
+		}
+
+		if t.leader != nil && !t.leader.IsLeader() {
+			continue
+		}
+
+		paused = !t.occupied()
+		if paused {
+			continue
+		}
+
+		t.fn(t.namespace, t.room)
+	}
+}
+
+func (bc *broadcast) watchJoins(room string) (<-chan struct{}, func()) {
+	_, joins := bc.roomHooks()
+	return joins.watchJoins(room)
+}
+
+func (bc *redisBroadcast) watchJoins(room string) (<-chan struct{}, func()) {
+	_, joins := bc.roomHooks()
+	return joins.watchJoins(room)
+}
+
+// occupied tells whether room has members. Members on this node are counted first, so cluster
+// is asked only when room has none on this node, e.g. while ticker is paused.
+func (t *RoomTicker) occupied() bool {
+	bc := t.h.getBroadcast()
+	if counter, ok := bc.(localRoomCounter); ok && counter.localLen(t.room) > 0 {
+		return true
+	}
+
+	return bc.Len(t.room) > 0
+}
+
+func (bc *broadcast) localLen(room string) int {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return len(bc.rooms[room])
+}
+
+func (bc *redisBroadcast) localLen(room string) int {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return len(bc.rooms[room])
+}
+
+// roomWatchers wakes watchers of rooms when a connection joins them.
+type roomWatchers struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func (w *roomWatchers) watchJoins(room string) (<-chan struct{}, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watchers == nil {
+		w.watchers = make(map[string]map[chan struct{}]struct{})
+	}
+	if w.watchers[room] == nil {
+		w.watchers[room] = make(map[chan struct{}]struct{})
+	}
+
+	ch := make(chan struct{}, 1)
+	w.watchers[room][ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.watchers[room], ch)
+		if len(w.watchers[room]) == 0 {
+			delete(w.watchers, room)
+		}
+	}
+}
+
+// joined wakes watchers of room, watcher which has pending wake up is skipped. Broadcast which
+// only publishes has no watchers.
+func (w *roomWatchers) joined(room string) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.watchers[room] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package socketio
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerRoomTicker(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+
+	ticks := make(chan string, 64)
+	ticker := server.RoomTicker("/game", "table:1", 10*time.Millisecond, func(namespace, room string) {
+		ticks <- namespace + " " + room
+	})
+	defer ticker.Stop()
+
+	select {
+	case <-ticks:
+		t.Fatal("empty room is ticked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	bc := server.getNamespace("/game").broadcast
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "a"}}, "/game", bc)
+	nc.Join("table:1")
+
+	select {
+	case tick := <-ticks:
+		should.Equal("/game table:1", tick)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticker isn't resumed on join")
+	}
+
+	nc.Leave("table:1")
+	time.Sleep(30 * time.Millisecond)
+	for len(ticks) > 0 {
+		<-ticks
+	}
+
+	select {
+	case <-ticks:
+		t.Fatal("ticker isn't paused once room is empty")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ticker.Stop()
+	nc.Join("table:1")
+
+	select {
+	case <-ticks:
+		t.Fatal("stopped ticker ticks")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// lenRecorder is local broadcast which counts queries of room length.
+type lenRecorder struct {
+	*broadcast
+	lens int32
+}
+
+func (bc *lenRecorder) Len(room string) int {
+	atomic.AddInt32(&bc.lens, 1)
+	return bc.broadcast.Len(room)
+}
+
+func TestServerRoomTickerLocalMembers(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+	defer server.Close()
+
+	bc := &lenRecorder{broadcast: newBroadcast()}
+	should.NoError(server.SetBroadcaster("/game", bc))
+
+	nc := newNamespaceConn(&conn{Conn: addrEngineConn{id: "a"}}, "/game", bc)
+	nc.Join("table:1")
+
+	ticks := make(chan string, 64)
+	ticker := server.RoomTicker("/game", "table:1", 5*time.Millisecond, func(namespace, room string) {
+		ticks <- room
+	})
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ticks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("room with members isn't ticked")
+		}
+	}
+	should.Zero(atomic.LoadInt32(&bc.lens), "members on node are counted locally")
+
+	nc.Leave("table:1")
+	should.Eventually(func() bool {
+		return atomic.LoadInt32(&bc.lens) > 0
+	}, 5*time.Second, 5*time.Millisecond, "room without members on node is queried")
+
+	ticker.Stop()
+
+	server.campaigns.mu.Lock()
+	defer server.campaigns.mu.Unlock()
+
+	should.Empty(server.campaigns.members, "stopped ticker isn't stopped by server")
+	should.Empty(server.onShutdownBegin, "ticker doesn't add shutdown hook")
+}
@@ -0,0 +1,60 @@
+package socketio
+
+import "strings"
+
+// RoomSeparator separates levels of hierarchical rooms, e.g. "org:42/project:7/channel:general"
+// is a child room of "org:42/project:7", which is a child room of "org:42".
+const RoomSeparator = "/"
+
+// roomTree indexes descendants of hierarchical rooms, so broadcast to a parent room
+// doesn't scan all the rooms. Only rooms with connections are indexed.
+type roomTree map[string]map[string]struct{}
+
+// add indexes room under all of its ancestors.
+func (t roomTree) add(room string) {
+	for _, ancestor := range roomAncestors(room) {
+		if _, ok := t[ancestor]; !ok {
+			t[ancestor] = make(map[string]struct{})
+		}
+
+		t[ancestor][room] = struct{}{}
+	}
+}
+
+// remove drops room from index once it has no connections.
+func (t roomTree) remove(room string) {
+	for _, ancestor := range roomAncestors(room) {
+		delete(t[ancestor], room)
+
+		if len(t[ancestor]) == 0 {
+			delete(t, ancestor)
+		}
+	}
+}
+
+// roomAncestors gives parent rooms of room, e.g. "a" and "a/b" for "a/b/c".
+func roomAncestors(room string) []string {
+	var ancestors []string
+	for i := strings.Index(room, RoomSeparator); i > 0; {
+		ancestors = append(ancestors, room[:i])
+
+		next := strings.Index(room[i+1:], RoomSeparator)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+
+	return ancestors
+}
+
+// UnionTree creates a room set with connections of all the given rooms and of their child rooms.
+func UnionTree(rooms ...string) *RoomSet {
+	return (&RoomSet{}).UnionTree(rooms...)
+}
+
+// UnionTree adds connections of all the given rooms and of their child rooms to the set,
+// e.g. UnionTree("org:42") selects connections of "org:42/project:7/channel:general".
+func (rs *RoomSet) UnionTree(rooms ...string) *RoomSet {
+	return rs.add(roomSetUnionTree, rooms)
+}
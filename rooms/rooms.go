@@ -0,0 +1,201 @@
+// Package rooms declares names of rooms and namespaces as typed values, so services emitting
+// into the same cluster build them from shared templates instead of repeating format strings.
+//
+//	var Order = rooms.MustTemplate("order:%d")
+//
+//	server.BroadcastToRoom(Chat.String(), Order.MustRoom(id).String(), "updated", order)
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalid is returned for names of rooms and namespaces which aren't valid.
+var ErrInvalid = errors.New("invalid name")
+
+// Room is validated name of room.
+type Room string
+
+func (r Room) String() string {
+	return string(r)
+}
+
+// Namespace is validated name of namespace.
+type Namespace string
+
+func (n Namespace) String() string {
+	return string(n)
+}
+
+// NewRoom formats name of room, it fails when arguments don't match format or name isn't valid.
+// go vet checks format and arguments of calls like of fmt.Sprintf.
+func NewRoom(format string, args ...interface{}) (Room, error) {
+	if _, err := parseFormat(format); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf(format, args...)
+	if strings.Contains(name, "%!") {
+		return "", fmt.Errorf("%w: room %q doesn't match arguments %v", ErrInvalid, format, args)
+	}
+
+	if err := validate(name); err != nil {
+		return "", err
+	}
+
+	return Room(name), nil
+}
+
+// MustRoom is like NewRoom but panics on error.
+func MustRoom(format string, args ...interface{}) Room {
+	room, err := NewRoom(format, args...)
+	if err != nil {
+		panic(err)
+	}
+
+	return room
+}
+
+// NewNamespace validates name of namespace, which starts with '/'.
+func NewNamespace(name string) (Namespace, error) {
+	if !strings.HasPrefix(name, "/") || strings.ContainsAny(name, "?#,") {
+		return "", fmt.Errorf("%w: namespace %q", ErrInvalid, name)
+	}
+
+	if err := validate(name); err != nil {
+		return "", err
+	}
+
+	return Namespace(name), nil
+}
+
+// MustNamespace is like NewNamespace but panics on error, for declaring namespaces in package
+// variables.
+func MustNamespace(name string) Namespace {
+	nsp, err := NewNamespace(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return nsp
+}
+
+// Template is format of names of rooms of a type, e.g. "order:%d". It's declared once and shared
+// by services, so names of rooms don't drift between them.
+type Template struct {
+	format string
+	verbs  []rune
+}
+
+// NewTemplate validates format of room names. Only %d and %s verbs are allowed, so the same
+// arguments always give the same name.
+func NewTemplate(format string) (Template, error) {
+	verbs, err := parseFormat(format)
+	if err != nil {
+		return Template{}, err
+	}
+
+	return Template{format: format, verbs: verbs}, nil
+}
+
+// MustTemplate is like NewTemplate but panics on error, for declaring templates in package
+// variables.
+func MustTemplate(format string) Template {
+	t, err := NewTemplate(format)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Format gives format of template.
+func (t Template) Format() string {
+	return t.format
+}
+
+// Room gives name of room of arguments, it fails when they don't match verbs of template.
+func (t Template) Room(args ...interface{}) (Room, error) {
+	if len(args) != len(t.verbs) {
+		return "", fmt.Errorf("%w: room %q expects %d arguments, got %d", ErrInvalid, t.format, len(t.verbs), len(args))
+	}
+
+	for i, verb := range t.verbs {
+		if !matchVerb(verb, args[i]) {
+			return "", fmt.Errorf("%w: room %q expects %%%c for argument %d, got %T", ErrInvalid, t.format, verb, i, args[i])
+		}
+	}
+
+	name := fmt.Sprintf(t.format, args...)
+	if err := validate(name); err != nil {
+		return "", err
+	}
+
+	return Room(name), nil
+}
+
+// MustRoom is like Room but panics on error.
+func (t Template) MustRoom(args ...interface{}) Room {
+	room, err := t.Room(args...)
+	if err != nil {
+		panic(err)
+	}
+
+	return room
+}
+
+// parseFormat gives verbs of format, it fails on verbs other than %d and %s, flags and widths.
+func parseFormat(format string) ([]rune, error) {
+	var verbs []rune
+
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			continue
+		}
+
+		if i+1 == len(runes) {
+			return nil, fmt.Errorf("%w: room %q ends with %%", ErrInvalid, format)
+		}
+
+		i++
+		switch runes[i] {
+		case '%':
+		case 'd', 's':
+			verbs = append(verbs, runes[i])
+		default:
+			return nil, fmt.Errorf("%w: room %q has unsupported verb %%%c", ErrInvalid, format, runes[i])
+		}
+	}
+
+	return verbs, nil
+}
+
+func matchVerb(verb rune, arg interface{}) bool {
+	switch arg.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return verb == 'd'
+	case string, fmt.Stringer:
+		return verb == 's'
+	default:
+		return false
+	}
+}
+
+// validate checks that name isn't empty and has no spaces or control characters.
+func validate(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalid)
+	}
+
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("%w: %q has space or control character", ErrInvalid, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package rooms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMustRoom(t *testing.T) {
+	should := assert.New(t)
+
+	should.Equal(Room("order:42"), MustRoom("order:%d", 42))
+	should.Equal("chat:general", MustRoom("chat:%s", "general").String())
+
+	// go vet reports such calls with constant format
+	format := "order:%d"
+	_, err := NewRoom(format, "42")
+	should.ErrorIs(err, ErrInvalid)
+	_, err = NewRoom("order:%v", 4.2)
+	should.ErrorIs(err, ErrInvalid)
+	_, err = NewRoom("chat:%s", "two words")
+	should.ErrorIs(err, ErrInvalid)
+
+	should.Panics(func() {
+		MustRoom("")
+	})
+}
+
+func TestTemplate(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	order := MustTemplate("org:%s/order:%d")
+	should.Equal("org:%s/order:%d", order.Format())
+
+	room, err := order.Room("acme", 42)
+	must.NoError(err)
+	should.Equal(Room("org:acme/order:42"), room)
+
+	_, err = order.Room("acme")
+	should.ErrorIs(err, ErrInvalid)
+	_, err = order.Room("acme", "42")
+	should.ErrorIs(err, ErrInvalid)
+	_, err = order.Room(42, 42)
+	should.ErrorIs(err, ErrInvalid)
+
+	should.Panics(func() {
+		order.MustRoom(42)
+	})
+
+	for _, format := range []string{"order:%5d", "order:%x", "order:%"} {
+		_, err = NewTemplate(format)
+		should.ErrorIs(err, ErrInvalid, format)
+	}
+
+	should.Equal(Room("100%:1"), MustTemplate("100%%:%d").MustRoom(1))
+}
+
+func TestNamespace(t *testing.T) {
+	should := assert.New(t)
+
+	should.Equal(Namespace("/chat"), MustNamespace("/chat"))
+	should.Equal("/", MustNamespace("/").String())
+
+	for _, name := range []string{"", "chat", "/chat?x=1", "/chat room"} {
+		_, err := NewNamespace(name)
+		should.ErrorIs(err, ErrInvalid, name)
+	}
+
+	should.Panics(func() {
+		MustNamespace("chat")
+	})
+}
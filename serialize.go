@@ -0,0 +1,167 @@
+package socketio
+
+import (
+	"reflect"
+	"strings"
+)
+
+// redactedValue replaces value of field tagged with `socketio:"redact"`, see RedactFields.
+const redactedValue = "[REDACTED]"
+
+// OutgoingHook transforms arguments of event emitted to namespace before they're encoded,
+// e.g. to strip internal fields. It must not modify args, but return new arguments instead.
+type OutgoingHook func(namespace, event string, args []interface{}) []interface{}
+
+// OnOutgoing sets hook applied to arguments of each event emitted by server, including
+// events of broadcasts, which are transformed by node which delivers them to connection.
+func (s *Server) OnOutgoing(hook OutgoingHook) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.outgoing = hook
+
+	return nil
+}
+
+// RedactFields is OutgoingHook which redacts struct arguments by their field tags:
+// value of field tagged with `socketio:"redact"` is replaced with "[REDACTED]" and
+// field tagged with `socketio:"omit"` is left out. Structs are converted to maps keyed
+// like encoding/json does, arguments of other types are kept as they are.
+func RedactFields(_, _ string, args []interface{}) []interface{} {
+	ret := make([]interface{}, len(args))
+	for i, arg := range args {
+		ret[i] = redactValue(reflect.ValueOf(arg))
+	}
+
+	return ret
+}
+
+func redactValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v.Interface()
+		}
+		if !hasRedactTags(v.Elem().Type()) {
+			return v.Interface()
+		}
+
+		return redactValue(v.Elem())
+
+	case reflect.Struct:
+		if !hasRedactTags(v.Type()) {
+			return v.Interface()
+		}
+
+		return redactStruct(v)
+
+	case reflect.Slice, reflect.Array:
+		if (v.Kind() == reflect.Slice && v.IsNil()) || !hasRedactTags(v.Type().Elem()) {
+			return v.Interface()
+		}
+
+		ret := make([]interface{}, v.Len())
+		for i := range ret {
+			ret[i] = redactValue(v.Index(i))
+		}
+
+		return ret
+
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String || !hasRedactTags(v.Type().Elem()) {
+			return v.Interface()
+		}
+
+		ret := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			ret[iter.Key().String()] = redactValue(iter.Value())
+		}
+
+		return ret
+	}
+
+	return v.Interface()
+}
+
+func redactStruct(v reflect.Value) map[string]interface{} {
+	t := v.Type()
+	ret := make(map[string]interface{}, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && name == field.Name {
+			// fields of embedded struct are promoted, like encoding/json does
+			for k, fv := range redactStruct(v.Field(i)) {
+				if _, ok := ret[k]; !ok {
+					ret[k] = fv
+				}
+			}
+			continue
+		}
+
+		switch field.Tag.Get("socketio") {
+		case "omit":
+		case "redact":
+			ret[name] = redactedValue
+		default:
+			ret[name] = redactValue(v.Field(i))
+		}
+	}
+
+	return ret
+}
+
+// hasRedactTags reports whether values of type t may have fields to redact.
+func hasRedactTags(t reflect.Type) bool {
+	return hasRedactTagsOf(t, make(map[reflect.Type]bool))
+}
+
+func hasRedactTagsOf(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return hasRedactTagsOf(t.Elem(), seen)
+
+	case reflect.Map:
+		return hasRedactTagsOf(t.Elem(), seen)
+
+	case reflect.Interface:
+		// dynamic values are checked once they're known
+		return true
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Tag.Get("socketio") != "" || hasRedactTagsOf(field.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
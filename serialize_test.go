@@ -0,0 +1,115 @@
+package socketio
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+type redactAudit struct {
+	By string `json:"by"`
+}
+
+type redactUser struct {
+	redactAudit
+	Name     string `json:"name"`
+	Password string `json:"password" socketio:"redact"`
+	Internal string `socketio:"omit"`
+	Friends  []redactUser
+	Ignored  string `json:"-"`
+}
+
+func TestRedactFields(t *testing.T) {
+	should := assert.New(t)
+
+	user := redactUser{
+		redactAudit: redactAudit{By: "admin"},
+		Name:        "alice",
+		Password:    "secret",
+		Internal:    "internal",
+		Friends:     []redactUser{{Name: "bob", Password: "secret"}},
+		Ignored:     "ignored",
+	}
+
+	args := RedactFields("/", "user", []interface{}{"plain", 1, user, &user, nil})
+
+	should.Equal("plain", args[0])
+	should.Equal(1, args[1])
+	should.Nil(args[4])
+
+	for _, arg := range args[2:4] {
+		should.Equal(map[string]interface{}{
+			"by":       "admin",
+			"name":     "alice",
+			"password": "[REDACTED]",
+			"Friends": []interface{}{map[string]interface{}{
+				"by":       "",
+				"name":     "bob",
+				"password": "[REDACTED]",
+				"Friends":  []redactUser(nil),
+			}},
+		}, arg)
+	}
+
+	// arguments itself aren't modified
+	should.Equal("secret", user.Password)
+}
+
+func TestServerOnOutgoing(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(server.OnOutgoing(RedactFields))
+	server.OnConnect("/", func(c Conn) error {
+		c.Join("users")
+		return nil
+	})
+	server.OnEvent("/", "whoami", func(c Conn) {
+		c.Emit("user", redactUser{Name: "alice", Password: "secret"})
+		server.BroadcastToRoom("/", "users", "user", &redactUser{Name: "bob", Password: "secret"})
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Close()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	client, err := NewClientWithOptions(httpSvr.URL, &ClientOptions{
+		Transports: []transport.Transport{polling.Default},
+	})
+	must.NoError(err)
+
+	users := make(chan map[string]interface{}, 2)
+	client.OnEvent("user", func(_ Conn, user map[string]interface{}) {
+		users <- user
+	})
+
+	must.NoError(client.Connect())
+	defer client.Close()
+
+	client.Emit("whoami")
+
+	for _, name := range []string{"alice", "bob"} {
+		select {
+		case user := <-users:
+			should.Equal(name, user["name"])
+			should.Equal("[REDACTED]", user["password"])
+			should.NotContains(user, "Internal")
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing user event")
+		}
+	}
+}
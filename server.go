@@ -2,22 +2,96 @@ package socketio
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
-
-	"github.com/gomodule/redigo/redis"
+	"sync"
+	"time"
 
 	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
 	"github.com/thisismz/go-socket.io/logger"
 	"github.com/thisismz/go-socket.io/parser"
 )
 
-// Server is a go-socket.io server.
+// Server is a go-socket.io server. Options of server, like EnableResume or HoldConnects, are read
+// by connections without locks, so they're set before Serve. Once server is serving, they return
+// ErrServing and leave server as it is. Adapters are swapped while serving, see RebindAdapter.
 type Server struct {
 	engine *engineio.Server
 
+	// serving is set once Serve is called, see configure.
+	serving int32
+
 	handlers *namespaceHandlers
 
+	// adapterLock guards adapters, which are set while serving as well, see RebindAdapter.
+	// broadcasts gives broadcasters of adapter set last, local ones when it's nil.
+	adapterLock  sync.RWMutex
 	redisAdapter *RedisAdapterOptions
+	bus          *busAdapter
+	mesh         *peerMesh
+	adapter      BroadcastFactory
+	broadcasts   func(nsp string) (Broadcast, error)
+	cluster      *clusterNodes
+
+	// rebindLock serializes RebindAdapter.
+	rebindLock sync.Mutex
+
+	nodeID string
+
+	resume   *resumeStore
+	capture  *payloadCapture
+	outgoing OutgoingHook
+
+	deliveryFailure  DeliveryFailureFunc
+	broadcastTimeout time.Duration
+	chunking         *ChunkOptions
+	egress           *EgressShaping
+	readBudget       *readBudget
+	dispatchPool     *dispatchPool
+	// keyedRunners are taken by lanes of serialized events of connections while they run.
+	keyedRunners chan struct{}
+	ackExpiry    time.Duration
+	waitHandlers bool
+	queueDepths  *queueDepths
+	edgeCases    ProtocolEdgeCases
+	streamWrites bool
+	parser       parser.Parser
+
+	// connGoroutines counts goroutines of connections, leakTimeout and leakReport are set
+	// by DetectLeaks.
+	connGoroutines int64
+	leakTimeout    time.Duration
+	leakReport     func(LeakReport)
+
+	namespaceRouter NamespaceRouter
+
+	leases *localLeaseStore
+	sink   *broadcastSinker
+
+	// redisLeases are leases of adapter, campaigns are leaders and tickers of rooms.
+	redisLeases     *redisLeaseStore
+	redisLeasesOnce sync.Once
+	campaigns       stopGroup
+
+	dedup   *broadcastDedup
+	history *broadcastHistory
+
+	// observers are called synchronously with every broadcast of this server.
+	observers []func(BroadcastRecord)
+	// remoteObservers are called with broadcasts of other nodes once adapter delivers them to
+	// this node, they're guarded by hooksLock.
+	remoteObservers []func(BroadcastRecord)
+
+	onStart            []func() error
+	onShutdownBegin    []func()
+	onShutdownComplete []func()
+	metricsHooks       []MetricsHook
+	onAdapterError     []func(error)
+	hooksLock          sync.RWMutex
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewServer returns a server.
@@ -25,33 +99,164 @@ func NewServer(opts *engineio.Options) *Server {
 	return &Server{
 		handlers: newNamespaceHandlers(),
 		engine:   engineio.NewServer(opts),
+		nodeID:   newV4UUID(),
+		leases:   newLocalLeaseStore(),
+
+		queueDepths:  newQueueDepths(),
+		keyedRunners: make(chan struct{}, defaultKeyedRunners),
 	}
 }
 
-// Adapter sets redis broadcast adapter.
+// Adapter sets redis broadcast adapter of namespaces created afterwards, see RebindAdapter. Redis
+// of adapter keeps cluster nodes, leases and resume sessions as well, unless resume storage is set
+// by ResumeOptions. Once server is serving, node id can't be changed and resume sessions stay where
+// they are.
 func (s *Server) Adapter(opts *RedisAdapterOptions) (bool, error) {
+	serving := s.configure() != nil
+
 	opts = getOptions(opts)
-	var redisOpts []redis.DialOption
-	if len(opts.Password) > 0 {
-		redisOpts = append(redisOpts, redis.DialPassword(opts.Password))
+	if opts.NodeID == "" {
+		opts.NodeID = s.nodeID
 	}
-	if opts.DB > 0 {
-		redisOpts = append(redisOpts, redis.DialDatabase(opts.DB))
+	if serving && opts.NodeID != s.nodeID {
+		return false, fmt.Errorf("node id of serving server can't be changed: %w", ErrServing)
 	}
 
-	conn, err := redis.Dial(opts.Network, opts.getAddr(), redisOpts...)
+	conn, err := opts.dial()
 	if err != nil {
 		return false, err
 	}
 
+	cluster, err := newClusterNodes(opts)
+	if err != nil {
+		_ = conn.Close()
+		return false, err
+	}
+
+	s.adapterLock.Lock()
+	if old := s.cluster; old != nil {
+		// broadcasters of replaced adapter watch its nodes until they're rebound
+		s.OnServerShutdownComplete(func() {
+			if err := old.Close(); err != nil {
+				logger.Error("close cluster nodes:", err)
+			}
+		})
+	}
+	if !serving {
+		s.nodeID = opts.NodeID
+	}
 	s.redisAdapter = opts
+	s.cluster = cluster
+	s.broadcasts = func(nsp string) (Broadcast, error) {
+		rbc, err := newRedisBroadcast(nsp, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		rbc.watchCluster(cluster)
+		s.monitorAdapter(rbc)
+
+		return rbc, nil
+	}
+	s.adapterLock.Unlock()
+
+	if !serving {
+		s.useRedisResumeStorage()
+	}
 
 	return true, conn.Close()
 }
 
+// NodeID gives the identity of this server instance in the cluster.
+func (s *Server) NodeID() string {
+	return s.nodeID
+}
+
+// ClusterNodes gives list of the server instances announced through the adapter,
+// with their last heartbeat. Without adapter, it gives only this instance.
+func (s *Server) ClusterNodes() []ClusterNode {
+	cluster := s.getCluster()
+	if cluster == nil {
+		return []ClusterNode{{ID: s.nodeID, LastHeartbeat: time.Now(), Self: true}}
+	}
+
+	return cluster.Nodes()
+}
+
+// HoldConnects holds connects to namespaces which are not registered yet, instead of refusing them,
+// until Ready is called. Every held connect waits at most timeout, then it's refused
+// if the namespace is still missing. Close releases held connects as well.
+func (s *Server) HoldConnects(timeout time.Duration) error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+
+	s.handlers.Hold(timeout)
+
+	return nil
+}
+
+// Ready marks all the handlers are registered and releases held connects.
+func (s *Server) Ready() {
+	s.handlers.Release()
+}
+
+// OnServerStart adds a hook called when Serve starts, before accepting connections.
+// If a hook returns error, Serve returns it.
+func (s *Server) OnServerStart(f func() error) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onStart = append(s.onStart, f)
+}
+
+// OnServerShutdownBegin adds a hook called when Close begins, before engine is closed.
+func (s *Server) OnServerShutdownBegin(f func()) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onShutdownBegin = append(s.onShutdownBegin, f)
+}
+
+// OnServerShutdownComplete adds a hook called when Close completes.
+func (s *Server) OnServerShutdownComplete(f func()) {
+	s.hooksLock.Lock()
+	defer s.hooksLock.Unlock()
+
+	s.onShutdownComplete = append(s.onShutdownComplete, f)
+}
+
 // Close closes server.
 func (s *Server) Close() error {
-	return s.engine.Close()
+	s.closeOnce.Do(func() {
+		s.hooksLock.RLock()
+		onShutdownBegin := s.onShutdownBegin
+		onShutdownComplete := s.onShutdownComplete
+		s.hooksLock.RUnlock()
+
+		for _, f := range onShutdownBegin {
+			f()
+		}
+		s.campaigns.stop()
+		s.closeLeases()
+
+		// held connects don't wait for their timeout, missing namespaces are refused
+		s.handlers.Release()
+
+		s.closeErr = s.engine.Close()
+
+		if cluster := s.getCluster(); cluster != nil {
+			if err := cluster.Close(); err != nil {
+				logger.Error("close cluster nodes:", err)
+			}
+		}
+
+		for _, f := range onShutdownComplete {
+			f()
+		}
+	})
+
+	return s.closeErr
 }
 
 // ServeHTTP dispatches the request to the handler whose pattern most closely matches the request URL.
@@ -59,6 +264,12 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.engine.ServeHTTP(w, r)
 }
 
+// OnUpgradeFailure sets f to be called with reason when upgrade of a connection to another
+// transport fails, e.g. engineio.ErrUpgradeTimeout, so it's visible why clients stay on polling.
+func (s *Server) OnUpgradeFailure(f func(sid, transport string, err error)) {
+	s.engine.OnUpgradeFailure(f)
+}
+
 // OnConnect set a handler function f to handle open event for namespace.
 func (s *Server) OnConnect(namespace string, f func(Conn) error) {
 	h := s.getNamespace(namespace)
@@ -70,6 +281,8 @@ func (s *Server) OnConnect(namespace string, f func(Conn) error) {
 }
 
 // OnDisconnect set a handler function f to handle disconnect event for namespace.
+// f is called once the connection left all its rooms, see WaitForHandlers for its order
+// with event handlers.
 func (s *Server) OnDisconnect(namespace string, f func(Conn, string)) {
 	h := s.getNamespace(namespace)
 	if h == nil {
@@ -99,8 +312,38 @@ func (s *Server) OnEvent(namespace, event string, f interface{}) {
 	h.OnEvent(event, f)
 }
 
+// SerializeEvent sets a serialization key function for event in namespace.
+// Events with the same key are handled sequentially, events with different keys are handled concurrently.
+// Keys of connection are spread over 16 lanes by their hash, so keys of the same lane wait for
+// each other, and at most 4096 lanes of all connections run at once. Error of handler closes
+// connection. Once 16 events of lane of connection are queued, or lanes of server are taken, its
+// reader stops reading frames until one of them is handled.
+func (s *Server) SerializeEvent(namespace, event string, f SerializationKeyFunc) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.SerializeEvent(event, f)
+}
+
 // Serve serves go-socket.io server.
 func (s *Server) Serve() error {
+	// options are refused before start hooks run, so none of them is changed while they do
+	s.startServing()
+
+	s.hooksLock.RLock()
+	onStart := s.onStart
+	s.hooksLock.RUnlock()
+
+	for _, f := range onStart {
+		if err := f(); err != nil {
+			// options may be fixed before Serve is called again
+			s.stopServing()
+			return err
+		}
+	}
+
 	for {
 		conn, err := s.engine.Accept()
 		//todo maybe need check EOF from Accept()
@@ -116,7 +359,9 @@ func (s *Server) Serve() error {
 func (s *Server) JoinRoom(namespace string, room string, connection Conn) bool {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		nspHandler.broadcast.Join(room, connection)
+		nspHandler.withBroadcast(func(b Broadcast) {
+			b.Join(room, connection)
+		})
 		return true
 	}
 
@@ -127,7 +372,9 @@ func (s *Server) JoinRoom(namespace string, room string, connection Conn) bool {
 func (s *Server) LeaveRoom(namespace string, room string, connection Conn) bool {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		nspHandler.broadcast.Leave(room, connection)
+		nspHandler.withBroadcast(func(b Broadcast) {
+			b.Leave(room, connection)
+		})
 		return true
 	}
 
@@ -138,7 +385,9 @@ func (s *Server) LeaveRoom(namespace string, room string, connection Conn) bool
 func (s *Server) LeaveAllRooms(namespace string, connection Conn) bool {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		nspHandler.broadcast.LeaveAll(connection)
+		nspHandler.withBroadcast(func(b Broadcast) {
+			b.LeaveAll(connection)
+		})
 		return true
 	}
 
@@ -149,7 +398,7 @@ func (s *Server) LeaveAllRooms(namespace string, connection Conn) bool {
 func (s *Server) ClearRoom(namespace string, room string) bool {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		nspHandler.broadcast.Clear(room)
+		nspHandler.getBroadcast().Clear(room)
 		return true
 	}
 
@@ -157,32 +406,107 @@ func (s *Server) ClearRoom(namespace string, room string) bool {
 }
 
 // BroadcastToRoom broadcasts given event & args to all the connections in the room.
+// Event which doesn't match schema of the room isn't sent, see EmitToRoom.
 func (s *Server) BroadcastToRoom(namespace string, room, event string, args ...interface{}) bool {
+	return s.EmitToRoom(namespace, room, event, args...) == nil
+}
+
+// BroadcastToRoomExcept broadcasts given event & args to all the connections in the room except
+// connections of exceptSIDs, e.g. to everyone but the sender. Connections are left out by rooms of
+// their ids, so it holds for connections of other nodes as well. Broadcaster of namespace which
+// isn't RoomSetBroadcast, see SetBroadcaster, delivers it to connections given by its ForEach of
+// the room, except connections of exceptSIDs. Event which doesn't match schema of the room isn't
+// sent, see EmitToRoom.
+func (s *Server) BroadcastToRoomExcept(namespace string, room, event string, exceptSIDs []string, args ...interface{}) bool {
+	if len(exceptSIDs) == 0 {
+		return s.BroadcastToRoom(namespace, room, event, args...)
+	}
+
 	nspHandler := s.getNamespace(namespace)
-	if nspHandler != nil {
-		nspHandler.broadcast.Send(room, event, args...)
-		return true
+	if nspHandler == nil {
+		return false
 	}
 
-	return false
+	if nspHandler.validateRoomEvent(room, event, args) != nil || nspHandler.allowRoomBroadcast(room) != nil {
+		return false
+	}
+
+	set := Union(room).Except(exceptSIDs...)
+	if _, ok := nspHandler.getBroadcast().(RoomSetBroadcast); ok {
+		return s.sendBroadcast(nspHandler, namespace, "", set, event, args)
+	}
+
+	except := make(map[string]struct{}, len(exceptSIDs))
+	for _, sid := range exceptSIDs {
+		except[sid] = struct{}{}
+	}
+
+	nspHandler.getBroadcast().ForEach(room, func(connection Conn) {
+		if _, ok := except[connection.ID()]; !ok {
+			broadcastEmit(connection, nil, event, args...)
+		}
+	})
+	s.recordBroadcast(namespace, "", set, event, args)
+
+	return true
+}
+
+// BroadcastToRoomTree broadcasts given event & args to all the connections in the room and in its
+// child rooms, e.g. room "org:42" reaches connections of "org:42/project:7/channel:general".
+// Each connection receives the event once, see UnionTree.
+func (s *Server) BroadcastToRoomTree(namespace string, room, event string, args ...interface{}) bool {
+	return s.BroadcastToRoomSet(namespace, UnionTree(room), event, args...)
 }
 
 // BroadcastToNamespace broadcasts given event & args to all the connections in the same namespace.
 func (s *Server) BroadcastToNamespace(namespace string, event string, args ...interface{}) bool {
 	nspHandler := s.getNamespace(namespace)
-	if nspHandler != nil {
-		nspHandler.broadcast.SendAll(event, args...)
-		return true
+	if nspHandler == nil {
+		return false
 	}
 
-	return false
+	return s.sendBroadcast(nspHandler, namespace, "", nil, event, args)
+}
+
+// BroadcastToRoomSet broadcasts given event & args to all the connections selected by the room set,
+// e.g. Union("a", "b").Intersect("premium").Except("muted"). It returns false when broadcaster
+// of namespace isn't RoomSetBroadcast.
+func (s *Server) BroadcastToRoomSet(namespace string, set *RoomSet, event string, args ...interface{}) bool {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return false
+	}
+
+	return s.sendBroadcast(nspHandler, namespace, "", set, event, args)
+}
+
+// ForEachRoomSet calls f for every connection selected by the room set. It returns false when
+// broadcaster of namespace isn't RoomSetBroadcast.
+func (s *Server) ForEachRoomSet(namespace string, set *RoomSet, f EachFunc) bool {
+	b, ok := s.roomSetBroadcast(namespace)
+	if ok {
+		b.ForEachRoomSet(set, f)
+	}
+
+	return ok
+}
+
+func (s *Server) roomSetBroadcast(namespace string) (RoomSetBroadcast, bool) {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return nil, false
+	}
+
+	b, ok := nspHandler.getBroadcast().(RoomSetBroadcast)
+
+	return b, ok
 }
 
 // RoomLen gives number of connections in the room.
 func (s *Server) RoomLen(namespace string, room string) int {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		return nspHandler.broadcast.Len(room)
+		return nspHandler.getBroadcast().Len(room)
 	}
 
 	return -1
@@ -192,7 +516,7 @@ func (s *Server) RoomLen(namespace string, room string) int {
 func (s *Server) Rooms(namespace string) []string {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		return nspHandler.broadcast.Rooms(nil)
+		return nspHandler.getBroadcast().Rooms(nil)
 	}
 
 	return nil
@@ -203,6 +527,23 @@ func (s *Server) Count() int {
 	return s.engine.Count()
 }
 
+// PauseAccept rejects new connections with 503 Service Unavailable and Retry-After hint,
+// until ResumeAccept is called. Existing connections are kept alive, e.g. during maintenance.
+func (s *Server) PauseAccept(retryAfter time.Duration) {
+	s.engine.PauseAccept(retryAfter)
+}
+
+// ResumeAccept accepts new connections again after PauseAccept.
+func (s *Server) ResumeAccept() {
+	s.engine.ResumeAccept()
+}
+
+// EngineStats gives counts of engine.io sessions by transport and upgrade state, and rate of
+// handshakes, see engineio.Stats.
+func (s *Server) EngineStats() engineio.Stats {
+	return s.engine.Stats()
+}
+
 // Remove session from sessions pool. Fixed the sessions map leak(connections, mem).
 func (s *Server) Remove(sid string) {
 	s.engine.Remove(sid)
@@ -212,7 +553,7 @@ func (s *Server) Remove(sid string) {
 func (s *Server) ForEach(namespace string, room string, f EachFunc) bool {
 	nspHandler := s.getNamespace(namespace)
 	if nspHandler != nil {
-		nspHandler.broadcast.ForEach(room, f)
+		nspHandler.getBroadcast().ForEach(room, f)
 		return true
 	}
 
@@ -221,6 +562,25 @@ func (s *Server) ForEach(namespace string, room string, f EachFunc) bool {
 
 func (s *Server) serveConn(conn engineio.Conn) {
 	c := newConn(conn, s.handlers)
+	c.setParser(s.parser)
+	c.resume = s.resume
+	c.observeEvent = s.observeEvent
+	c.capture = s.capture
+	c.outgoing = s.outgoing
+	c.deliveryFailure = s.deliveryFailure
+	c.broadcastTimeout = s.broadcastTimeout
+	c.chunking = newChunker(s.chunking)
+	c.egress = newTokenBucket(s.egress)
+	c.readBudget = s.readBudget
+	c.dispatchPool = s.dispatchPool
+	c.executor = newKeyedExecutor(defaultKeyedLanes, defaultKeyedQueueSize, s.keyedRunners)
+	c.ackExpiry = s.ackExpiry
+	c.queueDepths = s.queueDepths
+	c.edgeCases = s.edgeCases
+	c.streamWrites = s.streamWrites
+	c.waitForHandlers = s.waitHandlers
+	c.route = s.routeNamespace(conn)
+	c.v5 = transport.ProtocolVersion(conn.URL()) == 4
 	if err := c.connect(); err != nil {
 		_ = c.Close()
 		if root, ok := s.handlers.Get(rootNamespace); ok && root.onError != nil {
@@ -230,24 +590,29 @@ func (s *Server) serveConn(conn engineio.Conn) {
 		return
 	}
 
-	go s.serveError(c)
-	go s.serveWrite(c)
-	go s.serveRead(c)
-}
-
-func (s *Server) serveError(c *conn) {
-	defer func() {
+	// the first goroutine which returns closes connection, so the others terminate
+	g := newConnGroup(func() {
 		if err := c.Close(); err != nil {
 			logger.Error("close connect:", err)
 		}
 
 		s.engine.Remove(c.Conn.ID())
-	}()
+	}, &s.connGoroutines)
 
+	g.Go("error", func() error { return s.serveError(c) })
+	g.Go("write", func() error { return s.serveWrite(c) })
+	g.Go("read", func() error { return s.serveRead(c) })
+
+	if s.leakReport != nil {
+		go s.watchLeaks(c, g)
+	}
+}
+
+func (s *Server) serveError(c *conn) error {
 	for {
 		select {
 		case <-c.quitChan:
-			return
+			return nil
 		case err := <-c.errorChan:
 			var errMsg *errorMessage
 			if !errors.As(err, &errMsg) {
@@ -255,62 +620,49 @@ func (s *Server) serveError(c *conn) {
 			}
 
 			if handler := c.namespace(errMsg.namespace); handler != nil {
-				if handler.onError != nil {
-					nsConn, ok := c.namespaces.Get(errMsg.namespace)
-					if !ok {
-						continue
-					}
-					handler.onError(nsConn, errMsg.err)
+				nsConn, ok := c.namespaces.Get(errMsg.namespace)
+				if !ok {
+					continue
+				}
+				if onError := handler.errorHandler(nsConn); onError != nil {
+					onError(nsConn, errMsg.err)
 				}
 			}
 		}
 	}
 }
 
-func (s *Server) serveWrite(c *conn) {
-	defer func() {
-		if err := c.Close(); err != nil {
-			logger.Error("close connect:", err)
-		}
-
-		s.engine.Remove(c.Conn.ID())
-	}()
-
+func (s *Server) serveWrite(c *conn) error {
 	for {
 		select {
 		case <-c.quitChan:
-			return
+			return nil
 		case pkg := <-c.writeChan:
-			if err := c.encoder.Encode(pkg.Header, pkg.Data); err != nil {
-				c.onError(pkg.Header.Namespace, err)
-			}
+			c.writePacket(pkg)
 		}
 	}
 }
 
-func (s *Server) serveRead(c *conn) {
-	defer func() {
-		if err := c.Close(); err != nil {
-			logger.Error("close connect:", err)
-		}
-
-		s.engine.Remove(c.Conn.ID())
-	}()
-
+func (s *Server) serveRead(c *conn) error {
 	var event string
 
 	for {
+		c.readPause.wait(c.quitChan)
+		c.readBudget.wait(c)
+		c.dispatchPool.wait(c)
+
 		var header parser.Header
 
 		if err := c.decoder.DecodeHeader(&header, &event); err != nil {
 			logger.Error("DecodeHeader Error in serveRead", err)
 			c.onError(rootNamespace, err)
-			return
+			return err
 		}
 
 		if header.Namespace == aliasRootNamespace {
 			header.Namespace = rootNamespace
 		}
+		header.Namespace = c.routedIn(header.Namespace)
 
 		var err error
 		switch header.Type {
@@ -320,14 +672,17 @@ func (s *Server) serveRead(c *conn) {
 			err = connectPacketHandler(c, header)
 		case parser.Disconnect:
 			err = disconnectPacketHandler(c, header)
+		case parser.Error:
+			err = errorPacketHandler(c, header)
 		case parser.Event:
 			err = eventPacketHandler(c, event, header)
 		}
+		c.stats.received(c.decoder.LastSize())
 
 		if err != nil {
 			logger.Error("serve read:", err)
 
-			return
+			return err
 		}
 	}
 }
@@ -337,7 +692,15 @@ func (s *Server) createNamespace(nsp string) *namespaceHandler {
 		nsp = rootNamespace
 	}
 
-	handler := newNamespaceHandler(nsp, s.redisAdapter)
+	handler := newNamespaceHandler(nsp, nil)
+	handler.onRemoteBroadcast = s.observeRemoteBroadcast
+
+	b, err := s.newNamespaceBroadcast(nsp)
+	if err != nil {
+		logger.Error("namespace "+nsp+" broadcasts only to this node:", err)
+	} else {
+		handler.setBroadcast(b)
+	}
 	s.handlers.Set(nsp, handler)
 
 	return handler
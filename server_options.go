@@ -0,0 +1,23 @@
+package socketio
+
+import "sync/atomic"
+
+// configure returns ErrServing once server is serving, so its options aren't changed. Every
+// option of Server which is read by connections without locks calls it first.
+func (s *Server) configure() error {
+	if atomic.LoadInt32(&s.serving) == 1 {
+		return ErrServing
+	}
+
+	return nil
+}
+
+// startServing makes configure refuse options from now on, see Serve.
+func (s *Server) startServing() {
+	atomic.StoreInt32(&s.serving, 1)
+}
+
+// stopServing lets configure change options again, once Serve fails before it accepts connections.
+func (s *Server) stopServing() {
+	atomic.StoreInt32(&s.serving, 0)
+}
@@ -0,0 +1,42 @@
+package socketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerConfigureServing(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(nil)
+	defer server.Close()
+
+	must.NoError(server.SetBroadcastWriteTimeout(time.Second))
+
+	started := make(chan error, 1)
+	server.OnServerStart(func() error {
+		// options are refused by the time start hooks run
+		started <- server.SetBroadcastWriteTimeout(time.Minute)
+		return nil
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+
+	should.ErrorIs(<-started, ErrServing)
+	should.Equal(time.Second, server.broadcastTimeout, "options aren't changed once server is serving")
+
+	// adapters are swapped while serving, but node id isn't changed
+	ok, err := server.Adapter(&RedisAdapterOptions{Addr: "127.0.0.1:1", NodeID: "other"})
+	should.False(ok)
+	should.ErrorIs(err, ErrServing)
+
+	// connects of live server aren't held again
+	should.ErrorIs(server.HoldConnects(time.Minute), ErrServing)
+	should.Nil(server.handlers.hold)
+}
@@ -0,0 +1,179 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/thisismz/go-socket.io/logger"
+)
+
+// ErrServerSideEmitUnsupported is returned by ServerSideEmit when broadcaster of namespace
+// doesn't deliver messages to other nodes.
+var ErrServerSideEmitUnsupported = errors.New("broadcaster doesn't support server-side emit")
+
+// serverSideEmitter is broadcaster which delivers messages of application to other nodes.
+type serverSideEmitter interface {
+	serverSideEmit(event string, args []interface{}) error
+	// setOnServerSideEvent sets f called with messages of other nodes.
+	setOnServerSideEvent(f func(event string, args []json.RawMessage))
+}
+
+// ServerSideEmit sends event with args to OnServerSideEvent handlers of namespace on the other
+// nodes of cluster, e.g. to reload configuration or invalidate caches, through channels of the
+// adapter. Handlers of this node aren't called. Without adapter there are no other nodes, so
+// it does nothing.
+func (s *Server) ServerSideEmit(namespace, event string, args ...interface{}) error {
+	nspHandler := s.getNamespace(namespace)
+	if nspHandler == nil {
+		return fmt.Errorf("namespace %q doesn't exist", namespace)
+	}
+
+	switch b := nspHandler.getBroadcast().(type) {
+	case serverSideEmitter:
+		return b.serverSideEmit(event, args)
+	case *broadcast:
+		return nil
+	}
+
+	return ErrServerSideEmitUnsupported
+}
+
+// OnServerSideEvent sets handler of event sent by ServerSideEmit of other nodes, like
+// func(key string, version int). Arguments are decoded from JSON into parameters of f. It's
+// called by subscription of adapter, so long work should be moved to another goroutine.
+func (s *Server) OnServerSideEvent(namespace, event string, f interface{}) {
+	h := s.getNamespace(namespace)
+	if h == nil {
+		h = s.createNamespace(namespace)
+	}
+
+	h.onServerSideEvent(event, f)
+}
+
+func (nh *namespaceHandler) onServerSideEvent(event string, f interface{}) {
+	nh.eventsLock.Lock()
+	defer nh.eventsLock.Unlock()
+
+	if nh.serverSideEvents == nil {
+		nh.serverSideEvents = make(map[string]*funcHandler)
+	}
+	nh.serverSideEvents[event] = newAckFunc(f)
+}
+
+// dispatchServerSideEvent calls handler of event of other node with args decoded into its
+// parameters, missing args are zero values.
+func (nh *namespaceHandler) dispatchServerSideEvent(event string, args []json.RawMessage) {
+	nh.eventsLock.RLock()
+	handler := nh.serverSideEvents[event]
+	nh.eventsLock.RUnlock()
+
+	if handler == nil {
+		return
+	}
+
+	values := make([]reflect.Value, len(handler.argTypes))
+	for i, typ := range handler.argTypes {
+		v := reflect.New(typ)
+		if i < len(args) {
+			if err := json.Unmarshal(args[i], v.Interface()); err != nil {
+				logger.Error("decode server-side event "+event+":", err)
+				return
+			}
+		}
+		values[i] = v.Elem()
+	}
+
+	if _, err := handler.Call(values); err != nil {
+		logger.Error("handle server-side event "+event+":", err)
+	}
+}
+
+// serverSideEmitRequest is message of ServerSideEmit, published to channel of requests.
+type serverSideEmitRequest struct {
+	RequestType string
+	UUID        string
+	Event       string
+	Args        string // JSON of args
+}
+
+func (bc *redisBroadcast) serverSideEmit(event string, args []interface{}) error {
+	var req interface{}
+	if bc.nodeCompatible() {
+		data, err := json.Marshal(append([]interface{}{event}, args...))
+		if err != nil {
+			return err
+		}
+
+		req = &nodeRequest{UID: bc.uid, Type: nodeServerSideEmit, Data: data}
+	} else {
+		argsJSON, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+
+		req = &serverSideEmitRequest{
+			RequestType: serverSideEmitReqType,
+			UUID:        bc.uid,
+			Event:       event,
+			Args:        string(argsJSON),
+		}
+	}
+
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	_, err = bc.do(bc.publishCmd(), bc.reqChannel, reqJSON)
+	return err
+}
+
+func (bc *redisBroadcast) setOnServerSideEvent(f func(event string, args []json.RawMessage)) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.onServerSide = f
+}
+
+// onServerSideEmitRequest passes message of ServerSideEmit of other node to handlers.
+func (bc *redisBroadcast) onServerSideEmitRequest(req map[string]string) {
+	if req["UUID"] == bc.uid {
+		return
+	}
+
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte(req["Args"]), &args); err != nil {
+		bc.reportError(fmt.Errorf("server-side event %q: %w", req["Event"], err))
+		return
+	}
+
+	bc.serverSideEvent(req["Event"], args)
+}
+
+// onNodeServerSideEmit passes SERVER_SIDE_EMIT request of node.js redis adapter to handlers,
+// its data is event followed by args.
+func (bc *redisBroadcast) onNodeServerSideEmit(raw json.RawMessage) {
+	var data []json.RawMessage
+	if err := json.Unmarshal(raw, &data); err != nil || len(data) == 0 {
+		return
+	}
+
+	var event string
+	if err := json.Unmarshal(data[0], &event); err != nil {
+		return
+	}
+
+	bc.serverSideEvent(event, data[1:])
+}
+
+func (bc *redisBroadcast) serverSideEvent(event string, args []json.RawMessage) {
+	bc.lock.RLock()
+	f := bc.onServerSide
+	bc.lock.RUnlock()
+
+	if f != nil {
+		f(event, args)
+	}
+}
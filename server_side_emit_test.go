@@ -0,0 +1,104 @@
+package socketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+func TestServerSideEmit(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	published := make(chan []string, 16)
+	addr := fakeRedis(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "PSUBSCRIBE", "SUBSCRIBE":
+			var reply string
+			for i, channel := range cmd[1:] {
+				reply += "*3\r\n" + respArray(strings.ToLower(cmd[0]), channel)[4:] + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			return reply
+		case "PUBLISH":
+			published <- cmd[1:]
+		}
+		return ":1\r\n"
+	})
+
+	type reload struct {
+		key     string
+		version int
+	}
+	reloads := make(chan reload, 4)
+
+	server := NewServer(&engineio.Options{})
+	defer server.Close()
+
+	should.Error(server.ServerSideEmit("/chat", "reload"), "namespace doesn't exist")
+	server.OnServerSideEvent("/", "reload", func(string) {})
+	should.NoError(server.ServerSideEmit("/", "reload", "config"), "there are no other nodes")
+
+	for _, nodeCompatible := range []bool{false, true} {
+		bc, err := newRedisBroadcast("/chat", getOptions(&RedisAdapterOptions{
+			Addr:           addr,
+			NodeID:         "node1",
+			NodeCompatible: nodeCompatible,
+		}))
+		must.NoError(err)
+		must.NoError(server.SetBroadcaster("/chat", bc))
+		server.OnServerSideEvent("/chat", "reload", func(key string, version int) {
+			reloads <- reload{key: key, version: version}
+		})
+
+		must.NoError(server.ServerSideEmit("/chat", "reload", "config", 2))
+
+		var msg []string
+		select {
+		case msg = <-published:
+		case <-time.After(5 * time.Second):
+			t.Fatal("server-side event isn't published")
+		}
+		should.Equal(bc.reqChannel, msg[0])
+
+		var req map[string]interface{}
+		must.NoError(json.Unmarshal([]byte(msg[1]), &req))
+		if nodeCompatible {
+			should.Equal(map[string]interface{}{
+				"uid":  "node1",
+				"type": float64(nodeServerSideEmit),
+				"data": []interface{}{"reload", "config", float64(2)},
+			}, req)
+		} else {
+			should.Equal(map[string]interface{}{
+				"RequestType": serverSideEmitReqType,
+				"UUID":        "node1",
+				"Event":       "reload",
+				"Args":        `["config",2]`,
+			}, req)
+		}
+
+		// own message is ignored, message of other node is handled
+		if nodeCompatible {
+			bc.onNodeRequest([]byte(msg[1]))
+			bc.onNodeRequest([]byte(`{"uid":"node2","type":6,"data":["reload","cache",3]}`))
+			bc.onNodeRequest([]byte(`{"uid":"node2","type":6,"data":["unknown"]}`))
+		} else {
+			bc.onRequest([]byte(msg[1]))
+			bc.onRequest([]byte(`{"RequestType":"5","UUID":"node2","Event":"reload","Args":"[\"cache\",3]"}`))
+			bc.onRequest([]byte(`{"RequestType":"5","UUID":"node2","Event":"reload","Args":"[\"cache\"]"}`))
+		}
+
+		should.Equal(reload{key: "cache", version: 3}, <-reloads)
+		if !nodeCompatible {
+			should.Equal(reload{key: "cache"}, <-reloads, "missing args are zero values")
+		}
+		should.Empty(reloads)
+	}
+}
@@ -0,0 +1,121 @@
+package socketio
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+	"github.com/thisismz/go-socket.io/engineio/transport"
+	"github.com/thisismz/go-socket.io/engineio/transport/polling"
+)
+
+// lifecycleRecorder records calls of lifecycle hooks and handlers.
+type lifecycleRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *lifecycleRecorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+}
+
+func (r *lifecycleRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.calls...)
+}
+
+func TestServerLifecycleHooks(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{
+		Transports: []transport.Transport{polling.Default},
+	})
+
+	recorder := &lifecycleRecorder{}
+	connected := make(chan Conn, 1)
+	for _, name := range []string{"1", "2"} {
+		name := name
+		server.OnServerStart(func() error {
+			recorder.record("start" + name)
+			return nil
+		})
+		server.OnServerShutdownBegin(func() {
+			recorder.record("begin" + name)
+		})
+		server.OnServerShutdownComplete(func() {
+			recorder.record("complete" + name)
+		})
+	}
+	server.OnServerShutdownBegin(func() {
+		// connections are still open when shutdown begins
+		s := <-connected
+		if !s.(*namespaceConn).conn.closed() {
+			recorder.record("open")
+		}
+	})
+	server.OnConnect("/", func(s Conn) error {
+		recorder.record("connect")
+		connected <- s
+		return nil
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve()
+	}()
+
+	httpSvr := httptest.NewServer(server)
+	defer httpSvr.Close()
+
+	c := newProtocolClient(t, httpSvr.URL)
+	defer c.conn.Close()
+	_, _, _ = c.receive(t)
+
+	should.Equal([]string{"start1", "start2", "connect"}, recorder.get())
+
+	must.NoError(server.Close())
+	must.NoError(server.Close())
+
+	select {
+	case err := <-served:
+		should.Error(err, "Serve returns once server is closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve doesn't return after Close")
+	}
+
+	should.Equal([]string{"start1", "start2", "connect", "begin1", "begin2", "open", "complete1", "complete2"}, recorder.get())
+}
+
+func TestServerStartHookError(t *testing.T) {
+	should := assert.New(t)
+
+	server := NewServer(nil)
+	defer server.Close()
+
+	errStart := errors.New("warmup failed")
+	var calls []string
+	server.OnServerStart(func() error {
+		calls = append(calls, "start1")
+		return errStart
+	})
+	server.OnServerStart(func() error {
+		calls = append(calls, "start2")
+		return nil
+	})
+
+	should.ErrorIs(server.Serve(), errStart)
+	should.Equal([]string{"start1"}, calls, "hooks after failed one aren't called")
+	should.NoError(server.SetBroadcastWriteTimeout(time.Second), "options may be fixed after failed start")
+}
@@ -0,0 +1,178 @@
+package socketio
+
+import "sync"
+
+// events of WebRTC signaling, see Server.EnableSignaling.
+const (
+	SignalJoinEvent   = "signal:join"
+	SignalLeaveEvent  = "signal:leave"
+	SignalOfferEvent  = "signal:offer"
+	SignalAnswerEvent = "signal:answer"
+	SignalICEEvent    = "signal:ice"
+
+	SignalPeerJoinedEvent = "signal:peer-joined"
+	SignalPeerLeftEvent   = "signal:peer-left"
+)
+
+// SignalMessage is offer, answer or ICE candidate relayed from one peer to another in room.
+// Peers are identified by ids of their connections, From is set by server.
+type SignalMessage struct {
+	Room string      `json:"room"`
+	To   string      `json:"to"`
+	From string      `json:"from,omitempty"`
+	Data interface{} `json:"data"`
+}
+
+// SignalPeer tells peers of room that peer joined or left it.
+type SignalPeer struct {
+	Room string `json:"room"`
+	Peer string `json:"peer"`
+}
+
+// signalAck answers signaling events, Error is empty when event is accepted.
+type signalAck struct {
+	Error string `json:"error,omitempty"`
+}
+
+// SignalingOptions configures WebRTC signaling, see Server.EnableSignaling.
+type SignalingOptions struct {
+	// AuthorizeJoin decides whether conn may join signaling room, nil allows every room.
+	AuthorizeJoin func(conn Conn, room string) bool
+
+	// AuthorizeRelay decides whether from may signal to peer in room, nil allows members of room
+	// to signal each other.
+	AuthorizeRelay func(from Conn, to, room string) bool
+}
+
+func (o *SignalingOptions) authorizeJoin(conn Conn, room string) bool {
+	if o == nil || o.AuthorizeJoin == nil {
+		return true
+	}
+
+	return o.AuthorizeJoin(conn, room)
+}
+
+func (o *SignalingOptions) authorizeRelay(from Conn, to, room string) bool {
+	if o == nil || o.AuthorizeRelay == nil {
+		return true
+	}
+
+	return o.AuthorizeRelay(from, to, room)
+}
+
+// Signaling relays WebRTC offers, answers and ICE candidates between peers of rooms.
+type Signaling struct {
+	server    *Server
+	namespace string
+	opts      *SignalingOptions
+
+	// rooms are signaling rooms of each connection, by its id.
+	rooms map[string]map[string]struct{}
+	lock  sync.Mutex
+}
+
+// EnableSignaling registers WebRTC signaling events on namespace:
+//
+//   - SignalJoinEvent with room joins connection to room, other peers of room receive
+//     SignalPeerJoinedEvent and are expected to send offers to the new peer.
+//   - SignalLeaveEvent with room leaves it, other peers receive SignalPeerLeftEvent.
+//   - SignalOfferEvent, SignalAnswerEvent and SignalICEEvent with SignalMessage are relayed to
+//     peer To only when both peers are members of Room, across the cluster with adapter.
+//
+// Each event is acknowledged with object which has "error" when event is refused. Call
+// Signaling.Disconnect from OnDisconnect handler of namespace to tell peers that connection left.
+func (s *Server) EnableSignaling(namespace string, opts *SignalingOptions) (*Signaling, error) {
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	sig := &Signaling{
+		server:    s,
+		namespace: namespace,
+		opts:      opts,
+		rooms:     make(map[string]map[string]struct{}),
+	}
+
+	s.OnEvent(namespace, SignalJoinEvent, sig.join)
+	s.OnEvent(namespace, SignalLeaveEvent, sig.leave)
+	for _, event := range []string{SignalOfferEvent, SignalAnswerEvent, SignalICEEvent} {
+		event := event
+		s.OnEvent(namespace, event, func(conn Conn, msg SignalMessage) signalAck {
+			return sig.relay(conn, event, msg)
+		})
+	}
+
+	return sig, nil
+}
+
+// Disconnect tells peers of all signaling rooms of conn that it left.
+func (sig *Signaling) Disconnect(conn Conn) {
+	sig.lock.Lock()
+	rooms := sig.rooms[conn.ID()]
+	delete(sig.rooms, conn.ID())
+	sig.lock.Unlock()
+
+	for room := range rooms {
+		sig.peerLeft(conn, room)
+	}
+}
+
+func (sig *Signaling) join(conn Conn, room string) signalAck {
+	if room == "" || !sig.opts.authorizeJoin(conn, room) {
+		return signalAck{Error: "join is not authorized"}
+	}
+
+	if err := conn.TryJoin(room); err != nil {
+		return signalAck{Error: err.Error()}
+	}
+
+	sig.lock.Lock()
+	if sig.rooms[conn.ID()] == nil {
+		sig.rooms[conn.ID()] = make(map[string]struct{})
+	}
+	sig.rooms[conn.ID()][room] = struct{}{}
+	sig.lock.Unlock()
+
+	sig.server.BroadcastToRoomSet(sig.namespace, Union(room).Except(conn.ID()), SignalPeerJoinedEvent, SignalPeer{Room: room, Peer: conn.ID()})
+
+	return signalAck{}
+}
+
+func (sig *Signaling) leave(conn Conn, room string) signalAck {
+	sig.lock.Lock()
+	_, ok := sig.rooms[conn.ID()][room]
+	delete(sig.rooms[conn.ID()], room)
+	if len(sig.rooms[conn.ID()]) == 0 {
+		delete(sig.rooms, conn.ID())
+	}
+	sig.lock.Unlock()
+
+	if !ok {
+		return signalAck{Error: "not in room"}
+	}
+
+	conn.Leave(room)
+	sig.peerLeft(conn, room)
+
+	return signalAck{}
+}
+
+func (sig *Signaling) peerLeft(conn Conn, room string) {
+	sig.server.BroadcastToRoom(sig.namespace, room, SignalPeerLeftEvent, SignalPeer{Room: room, Peer: conn.ID()})
+}
+
+// relay sends msg to peer To, room set selects it only when it's member of room.
+func (sig *Signaling) relay(from Conn, event string, msg SignalMessage) signalAck {
+	sig.lock.Lock()
+	_, member := sig.rooms[from.ID()][msg.Room]
+	sig.lock.Unlock()
+
+	if !member || msg.To == "" || msg.To == from.ID() || !sig.opts.authorizeRelay(from, msg.To, msg.Room) {
+		return signalAck{Error: "relay is not authorized"}
+	}
+
+	msg.From = from.ID()
+	sig.server.BroadcastToRoomSet(sig.namespace, Union(msg.To).Intersect(msg.Room), event, msg)
+
+	return signalAck{}
+}
@@ -0,0 +1,120 @@
+package socketio
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/engineio"
+)
+
+// signalingPeer joins rooms of server and records emitted events with their first argument.
+type signalingPeer struct {
+	*namespaceConn
+	server *Server
+
+	mu     sync.Mutex
+	events []string
+	args   []interface{}
+}
+
+func newSignalingPeer(server *Server, id string) *signalingPeer {
+	p := &signalingPeer{
+		namespaceConn: newNamespaceConn(&conn{Conn: addrEngineConn{id: id}}, "/", nil),
+		server:        server,
+	}
+	server.JoinRoom("/", id, p)
+
+	return p
+}
+
+func (p *signalingPeer) TryJoin(room string) error {
+	p.server.JoinRoom("/", room, p)
+	return nil
+}
+
+func (p *signalingPeer) Leave(room string) {
+	p.server.LeaveRoom("/", room, p)
+}
+
+func (p *signalingPeer) Emit(event string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+	p.args = append(p.args, args[0])
+}
+
+func (p *signalingPeer) received() ([]string, []interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events, args := p.events, p.args
+	p.events, p.args = nil, nil
+
+	return events, args
+}
+
+func TestSignaling(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	server := NewServer(&engineio.Options{})
+	server.OnConnect("/", func(Conn) error {
+		return nil
+	})
+
+	sig, err := server.EnableSignaling("/", &SignalingOptions{
+		AuthorizeJoin: func(_ Conn, room string) bool {
+			return room != "private"
+		},
+		AuthorizeRelay: func(from Conn, to, _ string) bool {
+			return to != "blocked"
+		},
+	})
+	must.NoError(err)
+
+	alice := newSignalingPeer(server, "alice")
+	bob := newSignalingPeer(server, "bob")
+	eve := newSignalingPeer(server, "eve")
+
+	should.Equal(signalAck{}, sig.join(alice, "call"))
+	should.Equal(signalAck{}, sig.join(bob, "call"))
+	should.NotEmpty(sig.join(eve, "private").Error)
+
+	events, args := alice.received()
+	should.Equal([]string{SignalPeerJoinedEvent}, events)
+	should.Equal([]interface{}{SignalPeer{Room: "call", Peer: "bob"}}, args)
+
+	// bob offers to alice, alice answers
+	should.Equal(signalAck{}, sig.relay(bob, SignalOfferEvent, SignalMessage{Room: "call", To: "alice", Data: "sdp"}))
+	events, args = alice.received()
+	should.Equal([]string{SignalOfferEvent}, events)
+	should.Equal([]interface{}{SignalMessage{Room: "call", To: "alice", From: "bob", Data: "sdp"}}, args)
+
+	should.Equal(signalAck{}, sig.relay(alice, SignalAnswerEvent, SignalMessage{Room: "call", To: "bob", Data: "sdp"}))
+	events, _ = bob.received()
+	should.Equal([]string{SignalAnswerEvent}, events)
+
+	// eve isn't member of room, and isn't reached through it
+	should.NotEmpty(sig.relay(eve, SignalICEEvent, SignalMessage{Room: "call", To: "alice"}).Error)
+	should.Equal(signalAck{}, sig.relay(alice, SignalICEEvent, SignalMessage{Room: "call", To: "eve"}))
+	should.NotEmpty(sig.relay(alice, SignalICEEvent, SignalMessage{Room: "call", To: "blocked"}).Error)
+	events, _ = eve.received()
+	should.Empty(events)
+
+	should.Equal(signalAck{}, sig.leave(bob, "call"))
+	should.NotEmpty(sig.leave(bob, "call").Error)
+	events, args = alice.received()
+	should.Equal([]string{SignalPeerLeftEvent}, events)
+	should.Equal([]interface{}{SignalPeer{Room: "call", Peer: "bob"}}, args)
+
+	should.Equal(signalAck{}, sig.join(bob, "call"))
+	alice.received()
+	sig.Disconnect(alice)
+	events, args = bob.received()
+	should.Equal([]string{SignalPeerLeftEvent}, events)
+	should.Equal([]interface{}{SignalPeer{Room: "call", Peer: "alice"}}, args)
+}
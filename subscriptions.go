@@ -0,0 +1,313 @@
+package socketio
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	subscriptionRoomPrefix = "sub:"
+	// defaultSubscriptionLimit is maximum number of distinct subscriptions of connection.
+	defaultSubscriptionLimit = 32
+)
+
+var errSubscriptionLimit = errors.New("too many subscriptions")
+
+// events of subscriptions, see Server.EnableSubscriptions.
+const (
+	SubscribeEvent   = "subscribe"
+	UnsubscribeEvent = "unsubscribe"
+)
+
+// StreamFilter decides whether args published to stream are delivered to subscriptions with
+// params, e.g. params {"status": "open"} of orders stream.
+type StreamFilter func(params map[string]interface{}, args []interface{}) bool
+
+// StreamOptions configures stream of subscriptions, see Subscriptions.DefineStream.
+type StreamOptions struct {
+	// Filter is evaluated once for each distinct params of subscriptions when args are published,
+	// nil matches when every param equals field of the same name of the first arg, compared in
+	// JSON form, so subscription without params gets everything.
+	Filter StreamFilter
+
+	// Authorize decides whether conn may subscribe to stream with params, nil allows every
+	// subscription.
+	Authorize func(conn Conn, params map[string]interface{}) bool
+}
+
+func (o *StreamOptions) authorize(conn Conn, params map[string]interface{}) bool {
+	if o == nil || o.Authorize == nil {
+		return true
+	}
+
+	return o.Authorize(conn, params)
+}
+
+func (o *StreamOptions) getFilter() StreamFilter {
+	if o == nil {
+		return nil
+	}
+
+	return o.Filter
+}
+
+// subscriptionAck answers subscription events, Error is empty when event is accepted.
+type subscriptionAck struct {
+	Error string `json:"error,omitempty"`
+	// Room is virtual room of subscription.
+	Room string `json:"room,omitempty"`
+}
+
+// Subscriptions delivers events of server-defined streams to clients which subscribed with
+// params, filtering them on server instead of clients.
+type Subscriptions struct {
+	server    *Server
+	namespace string
+
+	streams map[string]*StreamOptions
+	// members are virtual rooms subscribed by each connection, by its id.
+	members map[string]map[string]struct{}
+	// limit is maximum number of subscriptions of each connection, zero is no limit.
+	limit int
+	lock  sync.RWMutex
+}
+
+// EnableSubscriptions registers subscription events on namespace:
+//
+//   - SubscribeEvent with stream name and params object subscribes to stream, ack has virtual
+//     room of subscription.
+//   - UnsubscribeEvent with the same stream name and params ends the subscription.
+//
+// Subscriptions of a stream with equal params share a virtual room, e.g. "sub:orders:{"status":
+// "open"}", see SubscriptionRoom, so filter of stream is evaluated once per distinct params. Args
+// published with Subscriptions.Publish are emitted as event named after stream. Streams are
+// defined with Subscriptions.DefineStream, subscriptions to other streams are refused.
+// Connection has at most 32 subscriptions, see Subscriptions.SetLimit, they're forgotten when it
+// disconnects.
+func (s *Server) EnableSubscriptions(namespace string) (*Subscriptions, error) {
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	sub := &Subscriptions{
+		server:    s,
+		namespace: namespace,
+		streams:   make(map[string]*StreamOptions),
+		members:   make(map[string]map[string]struct{}),
+		limit:     defaultSubscriptionLimit,
+	}
+
+	s.OnEvent(namespace, SubscribeEvent, sub.subscribe)
+	s.OnEvent(namespace, UnsubscribeEvent, sub.unsubscribe)
+	s.getNamespace(namespace).addDisconnectHook(sub.Disconnect)
+
+	return sub, nil
+}
+
+// SubscriptionRoom gives virtual room of subscriptions to stream with params, e.g. to broadcast
+// to them directly.
+func SubscriptionRoom(stream string, params map[string]interface{}) (string, error) {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	// keys of object are sorted, so equal params give the same room
+	b, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+
+	// '/' of values would make children of room tree
+	return subscriptionRoomPrefix + stream + ":" + strings.ReplaceAll(string(b), "/", `\/`), nil
+}
+
+// DefineStream allows subscriptions to stream, opts may be nil.
+func (sub *Subscriptions) DefineStream(stream string, opts *StreamOptions) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	sub.streams[stream] = opts
+}
+
+// SetLimit sets maximum number of distinct subscriptions of each connection, zero removes the
+// limit. Subscription beyond it is refused, existing subscriptions are kept.
+func (sub *Subscriptions) SetLimit(limit int) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	sub.limit = limit
+}
+
+// Publish emits event named after stream with args to virtual rooms of subscriptions whose params
+// match args, like EmitToRoom, so it reaches subscriptions of every node of cluster. Virtual rooms
+// and their params are taken from rooms of adapter, which asks other nodes for them. It gives
+// number of virtual rooms which got it.
+func (sub *Subscriptions) Publish(stream string, args ...interface{}) int {
+	sub.lock.RLock()
+	opts, ok := sub.streams[stream]
+	sub.lock.RUnlock()
+
+	nspHandler := sub.server.getNamespace(sub.namespace)
+	if !ok || nspHandler == nil {
+		return 0
+	}
+
+	filter := opts.getFilter()
+	if filter == nil {
+		filter = matchFields(args)
+	}
+
+	var sent int
+	prefix := subscriptionRoomPrefix + stream + ":"
+	for _, room := range nspHandler.getBroadcast().Rooms(nil) {
+		params, ok := subscriptionParams(prefix, room)
+		if !ok || !filter(params, args) {
+			continue
+		}
+
+		if sub.server.EmitToRoom(sub.namespace, room, stream, args...) == nil {
+			sent++
+		}
+	}
+
+	return sent
+}
+
+// subscriptionParams gives params of virtual room of subscriptions with prefix of their stream,
+// see SubscriptionRoom, its escaped '/' are valid JSON.
+func subscriptionParams(prefix, room string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(room, prefix) {
+		return nil, false
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(room[len(prefix):]), &params); err != nil || params == nil {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// Disconnect forgets subscriptions of conn, it's called when conn disconnects from namespace.
+func (sub *Subscriptions) Disconnect(conn Conn) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	delete(sub.members, conn.ID())
+}
+
+func (sub *Subscriptions) subscribe(conn Conn, stream string, params map[string]interface{}) subscriptionAck {
+	sub.lock.RLock()
+	opts, ok := sub.streams[stream]
+	sub.lock.RUnlock()
+
+	if !ok {
+		return subscriptionAck{Error: "unknown stream"}
+	}
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	if !opts.authorize(conn, params) {
+		return subscriptionAck{Error: "subscription is not authorized"}
+	}
+
+	room, err := SubscriptionRoom(stream, params)
+	if err != nil {
+		return subscriptionAck{Error: err.Error()}
+	}
+
+	// subscription is taken before joining, so concurrent subscriptions don't exceed limit
+	added, err := sub.addMember(conn, room)
+	if err != nil {
+		return subscriptionAck{Error: err.Error()}
+	}
+
+	if err := conn.TryJoin(room); err != nil {
+		if added {
+			sub.removeMember(conn, room)
+		}
+		return subscriptionAck{Error: err.Error()}
+	}
+
+	return subscriptionAck{Room: room}
+}
+
+// addMember adds room to subscriptions of conn unless it exceeds limit, it tells whether room
+// wasn't subscribed already.
+func (sub *Subscriptions) addMember(conn Conn, room string) (bool, error) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	rooms := sub.members[conn.ID()]
+	if _, ok := rooms[room]; ok {
+		return false, nil
+	}
+	if sub.limit > 0 && len(rooms) >= sub.limit {
+		return false, errSubscriptionLimit
+	}
+
+	if rooms == nil {
+		rooms = make(map[string]struct{})
+		sub.members[conn.ID()] = rooms
+	}
+	rooms[room] = struct{}{}
+
+	return true, nil
+}
+
+// removeMember removes room from subscriptions of conn, it tells whether conn subscribed it.
+func (sub *Subscriptions) removeMember(conn Conn, room string) bool {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	if _, ok := sub.members[conn.ID()][room]; !ok {
+		return false
+	}
+
+	delete(sub.members[conn.ID()], room)
+	if len(sub.members[conn.ID()]) == 0 {
+		delete(sub.members, conn.ID())
+	}
+
+	return true
+}
+
+func (sub *Subscriptions) unsubscribe(conn Conn, stream string, params map[string]interface{}) subscriptionAck {
+	room, err := SubscriptionRoom(stream, params)
+	if err != nil {
+		return subscriptionAck{Error: err.Error()}
+	}
+
+	if !sub.removeMember(conn, room) {
+		return subscriptionAck{Error: "not subscribed"}
+	}
+
+	conn.Leave(room)
+
+	return subscriptionAck{Room: room}
+}
+
+// matchFields gives default filter of stream, which compares params with fields of the first
+// of args, decoded from JSON once for all subscriptions.
+func matchFields(args []interface{}) StreamFilter {
+	var fields map[string]interface{}
+	if len(args) > 0 {
+		if b, err := json.Marshal(args[0]); err == nil {
+			_ = json.Unmarshal(b, &fields)
+		}
+	}
+
+	return func(params map[string]interface{}, _ []interface{}) bool {
+		for key, want := range params {
+			got, ok := fields[key]
+			if !ok || !reflect.DeepEqual(got, want) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
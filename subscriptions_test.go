@@ -0,0 +1,143 @@
+package socketio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thisismz/go-socket.io/parser"
+)
+
+type subscriptionOrder struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestSubscriptions(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	var sub *Subscriptions
+	server, url := newConformanceServer(t, func(server *Server) {
+		var err error
+		sub, err = server.EnableSubscriptions("/")
+		must.NoError(err)
+		sub.DefineStream("orders", nil)
+		sub.DefineStream("prices", &StreamOptions{
+			Filter: func(params map[string]interface{}, args []interface{}) bool {
+				min, _ := params["min"].(float64)
+				return args[0].(float64) >= min
+			},
+			Authorize: func(_ Conn, params map[string]interface{}) bool {
+				return params["min"] != nil
+			},
+		})
+	})
+
+	ackType := reflect.TypeOf(map[string]interface{}{})
+	subscribe := func(c *protocolClient, event, stream string, params map[string]interface{}) map[string]interface{} {
+		c.send(t, parser.Header{Type: parser.Event, ID: 1, NeedAck: true}, event, stream, params)
+
+		header, _, args := c.receive(t, ackType)
+		must.Equal(parser.Ack, header.Type)
+		must.Len(args, 1)
+
+		return args[0].(map[string]interface{})
+	}
+
+	open := newProtocolClient(t, url)
+	defer open.conn.Close()
+	_, _, _ = open.receive(t)
+
+	closed := newProtocolClient(t, url)
+	defer closed.conn.Close()
+	_, _, _ = closed.receive(t)
+
+	should.Equal(map[string]interface{}{"error": "unknown stream"}, subscribe(open, SubscribeEvent, "users", nil))
+	should.Equal(map[string]interface{}{"error": "subscription is not authorized"}, subscribe(open, SubscribeEvent, "prices", nil))
+	should.Equal(map[string]interface{}{"error": "not subscribed"}, subscribe(open, UnsubscribeEvent, "orders", nil))
+
+	room, err := SubscriptionRoom("orders", map[string]interface{}{"status": "open", "region": "eu/west"})
+	must.NoError(err)
+	should.Equal(`sub:orders:{"region":"eu\/west","status":"open"}`, room)
+
+	should.Equal(map[string]interface{}{"room": room},
+		subscribe(open, SubscribeEvent, "orders", map[string]interface{}{"status": "open", "region": "eu/west"}))
+	subscribe(open, SubscribeEvent, "prices", map[string]interface{}{"min": 10})
+	subscribe(closed, SubscribeEvent, "orders", map[string]interface{}{"status": "closed"})
+
+	should.Equal(1, sub.Publish("orders", subscriptionOrder{ID: 1, Status: "closed"}))
+	should.Zero(sub.Publish("orders", map[string]interface{}{"id": 2, "status": "open", "region": "us"}))
+	should.Equal(1, sub.Publish("orders", map[string]interface{}{"id": 3, "status": "open", "region": "eu/west"}))
+	should.Zero(sub.Publish("prices", 9.5))
+	should.Equal(1, sub.Publish("prices", 12.5))
+	should.Zero(sub.Publish("users", "ignored"))
+
+	header, event, args := closed.receive(t, reflect.TypeOf(subscriptionOrder{}))
+	should.Equal(parser.Event, header.Type)
+	should.Equal("orders", event)
+	should.Equal([]interface{}{subscriptionOrder{ID: 1, Status: "closed"}}, args)
+
+	_, event, args = open.receive(t, reflect.TypeOf(subscriptionOrder{}))
+	should.Equal("orders", event)
+	should.Equal([]interface{}{subscriptionOrder{ID: 3, Status: "open"}}, args)
+
+	_, event, args = open.receive(t, reflect.TypeOf(0.0))
+	should.Equal("prices", event)
+	should.Equal([]interface{}{12.5}, args)
+
+	should.Equal(map[string]interface{}{"room": `sub:orders:{"status":"closed"}`},
+		subscribe(closed, UnsubscribeEvent, "orders", map[string]interface{}{"status": "closed"}))
+	should.Zero(sub.Publish("orders", subscriptionOrder{ID: 4, Status: "closed"}))
+
+	should.NotContains(server.Rooms("/"), `sub:orders:{"status":"closed"}`, "empty virtual room is forgotten")
+
+	// events which weren't delivered don't precede the ack
+	closed.send(t, parser.Header{Type: parser.Event, ID: 2, NeedAck: true}, "echo", "done")
+	header, _, args = closed.receive(t, reflect.TypeOf(""))
+	should.Equal(parser.Ack, header.Type)
+	should.Equal([]interface{}{"done"}, args)
+
+	// connection may have limited number of distinct subscriptions
+	sub.SetLimit(2)
+	should.Equal(map[string]interface{}{"error": "too many subscriptions"},
+		subscribe(open, SubscribeEvent, "orders", map[string]interface{}{"status": "closed"}))
+	should.Equal(map[string]interface{}{"room": room},
+		subscribe(open, SubscribeEvent, "orders", map[string]interface{}{"status": "open", "region": "eu/west"}))
+
+	// subscriptions are forgotten once connection disconnects
+	must.NoError(open.conn.Close())
+	must.Eventually(func() bool {
+		sub.lock.RLock()
+		defer sub.lock.RUnlock()
+
+		return len(sub.members) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSubscriptionsCluster(t *testing.T) {
+	should := assert.New(t)
+	must := require.New(t)
+
+	servers, recorders := newPeerMeshServers(t)
+	sub, err := servers[0].EnableSubscriptions("/chat")
+	must.NoError(err)
+	sub.DefineStream("orders", nil)
+
+	// subscription of other node
+	room, err := SubscriptionRoom("orders", map[string]interface{}{"status": "open"})
+	must.NoError(err)
+	servers[1].JoinRoom("/chat", room, recorders[1])
+
+	should.Zero(sub.Publish("orders", subscriptionOrder{ID: 1, Status: "closed"}))
+	should.Equal(1, sub.Publish("orders", subscriptionOrder{ID: 2, Status: "open"}))
+
+	must.Eventually(func() bool {
+		return len(recorders[1].received()) == 1
+	}, time.Second, 10*time.Millisecond)
+	should.Equal([]string{"orders"}, recorders[1].received())
+	should.Empty(recorders[0].received())
+}
@@ -0,0 +1,130 @@
+'use strict';
+
+// client.js drives the official socket.io client against go-socket.io server, it's run by
+// js_client_test.go as:
+//
+//   node client.js <url> <transport> <scenario> <client>
+//
+// and prints result of scenario as JSON. client is v2 or v4, package.json installs
+// socket.io-client 2.x, which speaks engine.io protocol 3, and 4.x, which speaks engine.io
+// protocol 4 and socket.io protocol 5, under their own names.
+
+const [url, transport, scenario, client] = process.argv.slice(2);
+
+const io = require(client === 'v4' ? 'socket.io-client-v4' : 'socket.io-client');
+
+function once(emitter, event) {
+  return new Promise((resolve) => emitter.once(event, (...args) => resolve(args)));
+}
+
+function emit(socket, event, ...args) {
+  return new Promise((resolve) => socket.emit(event, ...args, (...reply) => resolve(reply)));
+}
+
+async function connected(...sockets) {
+  await Promise.all(sockets.map((socket) => (socket.connected ? [] : once(socket, 'connect'))));
+}
+
+const scenarios = {
+  async connect(manager) {
+    const root = manager.socket('/');
+    const chat = manager.socket('/chat');
+    await connected(root, chat);
+
+    return {
+      root: root.connected,
+      chat: chat.connected,
+      transport: manager.engine.transport.name,
+    };
+  },
+
+  async ack(manager) {
+    const root = manager.socket('/');
+    const chat = manager.socket('/chat');
+    await connected(root, chat);
+
+    const [rootReply] = await emit(root, 'echo', 'hi');
+    const [chatReply] = await emit(chat, 'echo', 'hi');
+
+    // server asks question which needs ack and emits the reply back
+    root.on('question', (msg, ack) => ack('yes ' + msg));
+    const answered = once(root, 'answered');
+    root.emit('ask');
+    const [answer] = await answered;
+
+    return { root: rootReply, chat: chatReply, answer };
+  },
+
+  async binary(manager) {
+    const root = manager.socket('/');
+    await connected(root);
+
+    const [size] = await emit(root, 'binary', Buffer.from([1, 2, 3]));
+    const [reversed] = await emit(root, 'reverse', Buffer.from([1, 2, 3]));
+
+    return { size, reversed: Array.from(reversed) };
+  },
+
+  async binaryAck(manager) {
+    const root = manager.socket('/');
+    await connected(root);
+
+    // ack with buffer is BINARY_ACK packet
+    root.on('bytes', (ack) => ack(Buffer.from([4, 5, 6])));
+    const got = once(root, 'gotBytes');
+    root.emit('askBytes');
+    const [size] = await got;
+
+    return { size };
+  },
+
+  async reconnect(manager) {
+    const root = manager.socket('/');
+    await connected(root);
+    const first = root.id;
+
+    // server closes engine connection, which the client recovers from on its own, clients 3.x
+    // and 4.x emit reconnect on manager only
+    const reconnected = once(manager, 'reconnect');
+    const connectedAgain = once(root, 'connect');
+    root.emit('drop');
+    const [attempts] = await reconnected;
+    await connectedAgain;
+
+    const [reply] = await emit(root, 'echo', 'back');
+
+    return { attempts, changed: root.id !== first, reply };
+  },
+};
+
+async function main() {
+  const run = scenarios[scenario];
+  if (!run) {
+    throw new Error(`unknown scenario ${scenario}`);
+  }
+
+  const manager = new io.Manager(url, {
+    transports: [transport],
+    reconnectionDelay: 50,
+    reconnectionDelayMax: 100,
+    timeout: 5000,
+  });
+
+  const result = await run(manager);
+  manager.close();
+
+  process.stdout.write(JSON.stringify(result) + '\n');
+}
+
+setTimeout(() => {
+  process.stderr.write(`scenario ${scenario} timed out\n`);
+  process.exit(2);
+}, 10000);
+
+main().then(
+  () => process.exit(0),
+  (err) => {
+    process.stderr.write(`${err.stack || err}\n`);
+    process.exit(1);
+  },
+);